@@ -0,0 +1,160 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package conformance describes the semantics of server-side apply as a
+// list of scenarios (sequences of updates and applies, and their expected
+// outcome), which implementations of server-side apply, or wrappers of this
+// one, can run to check that they behave exactly like the merge package.
+package conformance
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
+	"sigs.k8s.io/structured-merge-diff/v4/merge"
+	"sigs.k8s.io/structured-merge-diff/v4/typed"
+	"sigs.k8s.io/structured-merge-diff/v4/value"
+)
+
+// Implementation is the implementation of server-side apply under test. It
+// has the semantics of the methods of the same name of *merge.Updater,
+// which implements it.
+//
+// All the scenarios use the same version, Version, so the implementation
+// doesn't need to convert objects.
+type Implementation interface {
+	Update(liveObject, newObject *typed.TypedValue, version fieldpath.APIVersion, managers fieldpath.ManagedFields, manager string) (*typed.TypedValue, fieldpath.ManagedFields, error)
+	Apply(liveObject, configObject *typed.TypedValue, version fieldpath.APIVersion, managers fieldpath.ManagedFields, manager string, force bool) (*typed.TypedValue, fieldpath.ManagedFields, error)
+}
+
+var _ Implementation = &merge.Updater{}
+
+// Step is an update or an apply of a scenario.
+type Step struct {
+	// Manager is the manager running the step.
+	Manager string
+	// Apply is true for applies, and false for updates.
+	Apply bool
+	// Force is true for applies which take the ownership of the
+	// conflicting fields.
+	Force bool
+	// Object is the updated object, or the applied configuration.
+	Object typed.YAMLObject
+	// Conflicts, if not empty, are the conflicts the apply is expected to
+	// fail with, in any order. The object and managed fields are then left
+	// unchanged.
+	Conflicts merge.Conflicts
+}
+
+// Scenario is a sequence of steps run on an object which doesn't exist
+// yet, and the expected result.
+type Scenario struct {
+	// Name identifies the scenario.
+	Name string
+	// Steps are run in order.
+	Steps []Step
+	// Object is the object expected after all the steps.
+	Object typed.YAMLObject
+	// Managed are the managed fields expected after all the steps.
+	Managed fieldpath.ManagedFields
+}
+
+// Run runs the scenario with impl, and returns an error describing the
+// first difference with the expected behavior.
+func (s Scenario) Run(impl Implementation) error {
+	pt := parser.Type(TypeName)
+	live, err := pt.FromUnstructured(nil)
+	if err != nil {
+		return fmt.Errorf("failed to create new empty object: %v", err)
+	}
+	managers := fieldpath.ManagedFields{}
+	for i, step := range s.Steps {
+		obj, err := pt.FromYAML(step.Object)
+		if err != nil {
+			return fmt.Errorf("step %d: invalid object: %v", i, err)
+		}
+		var newObject *typed.TypedValue
+		var newManagers fieldpath.ManagedFields
+		if step.Apply {
+			newObject, newManagers, err = impl.Apply(live, obj, Version, managers.Copy(), step.Manager, step.Force)
+		} else {
+			newObject, newManagers, err = impl.Update(live, obj, Version, managers.Copy(), step.Manager)
+		}
+		if len(step.Conflicts) > 0 {
+			var conflicts merge.Conflicts
+			if !errors.As(err, &conflicts) {
+				return fmt.Errorf("step %d: expected conflicts:\n%v\ngot: %v", i, step.Conflicts, err)
+			}
+			if !sameConflicts(conflicts, step.Conflicts) {
+				return fmt.Errorf("step %d: expected conflicts:\n%v\ngot:\n%v", i, step.Conflicts, conflicts)
+			}
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("step %d: %v", i, err)
+		}
+		if newObject != nil {
+			live = newObject
+		}
+		managers = newManagers
+	}
+
+	expected, err := pt.FromYAML(s.Object)
+	if err != nil {
+		return fmt.Errorf("invalid expected object: %v", err)
+	}
+	if !value.Equals(live.AsValue(), expected.AsValue()) {
+		return fmt.Errorf("expected object:\n%v\ngot:\n%v", value.ToString(expected.AsValue()), value.ToString(live.AsValue()))
+	}
+	if !managers.Equals(s.Managed) {
+		return fmt.Errorf("expected managed fields:\n%v\ngot:\n%v", s.Managed, managers)
+	}
+	return nil
+}
+
+// sameConflicts returns true if the conflicts are the same, in any order.
+func sameConflicts(lhs, rhs merge.Conflicts) bool {
+	if len(lhs) != len(rhs) {
+		return false
+	}
+	for _, l := range lhs {
+		found := false
+		for _, r := range rhs {
+			if l.Equals(r) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// Test runs every scenario with impl as a subtest of t.
+func Test(t *testing.T, impl Implementation) {
+	for _, s := range Scenarios() {
+		s := s
+		t.Run(s.Name, func(t *testing.T) {
+			if err := s.Run(impl); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package conformance_test
+
+import (
+	"fmt"
+	"testing"
+
+	"sigs.k8s.io/structured-merge-diff/v4/conformance"
+	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
+	"sigs.k8s.io/structured-merge-diff/v4/merge"
+	"sigs.k8s.io/structured-merge-diff/v4/typed"
+)
+
+type sameVersionConverter struct{}
+
+func (sameVersionConverter) Convert(object *typed.TypedValue, version fieldpath.APIVersion) (*typed.TypedValue, error) {
+	if version != conformance.Version {
+		return nil, fmt.Errorf("unknown version: %v", version)
+	}
+	return object, nil
+}
+
+func (sameVersionConverter) IsMissingVersionError(err error) bool {
+	return false
+}
+
+func TestUpdater(t *testing.T) {
+	conformance.Test(t, &merge.Updater{Converter: sameVersionConverter{}})
+}
+
+// alwaysForce is an implementation which doesn't conform, since it never
+// reports conflicts.
+type alwaysForce struct {
+	*merge.Updater
+}
+
+func (a alwaysForce) Apply(liveObject, configObject *typed.TypedValue, version fieldpath.APIVersion, managers fieldpath.ManagedFields, manager string, force bool) (*typed.TypedValue, fieldpath.ManagedFields, error) {
+	return a.Updater.Apply(liveObject, configObject, version, managers, manager, true)
+}
+
+func TestNonConformingImplementation(t *testing.T) {
+	impl := alwaysForce{&merge.Updater{Converter: sameVersionConverter{}}}
+	failed := 0
+	for _, s := range conformance.Scenarios() {
+		if s.Run(impl) != nil {
+			failed++
+		}
+	}
+	if failed == 0 {
+		t.Error("expected the scenarios with conflicts to fail")
+	}
+}
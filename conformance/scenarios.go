@@ -0,0 +1,321 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package conformance
+
+import (
+	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
+	"sigs.k8s.io/structured-merge-diff/v4/merge"
+	"sigs.k8s.io/structured-merge-diff/v4/typed"
+	"sigs.k8s.io/structured-merge-diff/v4/value"
+)
+
+// Schema is the schema of the objects of the scenarios. It has a field of
+// each kind of list and map.
+const Schema typed.YAMLObject = `types:
+- name: object
+  map:
+    fields:
+    - name: name
+      type:
+        scalar: string
+    - name: replicas
+      type:
+        scalar: numeric
+    - name: labels
+      type:
+        map:
+          elementType:
+            scalar: string
+    - name: selector
+      type:
+        map:
+          elementType:
+            scalar: string
+          elementRelationship: atomic
+    - name: args
+      type:
+        list:
+          elementType:
+            scalar: string
+          elementRelationship: atomic
+    - name: finalizers
+      type:
+        list:
+          elementType:
+            scalar: string
+          elementRelationship: associative
+    - name: containers
+      type:
+        list:
+          elementType:
+            namedType: container
+          elementRelationship: associative
+          keys:
+          - name
+- name: container
+  map:
+    fields:
+    - name: name
+      type:
+        scalar: string
+    - name: image
+      type:
+        scalar: string
+`
+
+// TypeName is the name of the type of the objects of the scenarios.
+const TypeName = "object"
+
+// Version is the version of the objects of the scenarios.
+const Version fieldpath.APIVersion = "v1"
+
+var parser = func() *typed.Parser {
+	p, err := typed.NewParser(Schema)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}()
+
+func path(parts ...interface{}) fieldpath.Path {
+	return fieldpath.MakePathOrDie(parts...)
+}
+
+func container(name string) *value.FieldList {
+	return &value.FieldList{{Name: "name", Value: value.NewValueInterface(name)}}
+}
+
+func applied(paths ...fieldpath.Path) fieldpath.VersionedSet {
+	return fieldpath.NewVersionedSet(fieldpath.NewSet(paths...), Version, true)
+}
+
+func updated(paths ...fieldpath.Path) fieldpath.VersionedSet {
+	return fieldpath.NewVersionedSet(fieldpath.NewSet(paths...), Version, false)
+}
+
+// Scenarios returns the scenarios. They can be modified by the caller.
+func Scenarios() []Scenario {
+	return []Scenario{
+		{
+			Name: "apply creates the object",
+			Steps: []Step{
+				{Manager: "a", Apply: true, Object: `{"name": "n", "replicas": 1}`},
+			},
+			Object: `{"name": "n", "replicas": 1}`,
+			Managed: fieldpath.ManagedFields{
+				"a": applied(path("name"), path("replicas")),
+			},
+		},
+		{
+			Name: "apply removes the fields no longer applied",
+			Steps: []Step{
+				{Manager: "a", Apply: true, Object: `{"name": "n", "replicas": 1, "labels": {"p": "x"}}`},
+				{Manager: "a", Apply: true, Object: `{"name": "n"}`},
+			},
+			Object: `{"name": "n"}`,
+			Managed: fieldpath.ManagedFields{
+				"a": applied(path("name")),
+			},
+		},
+		{
+			Name: "apply keeps the fields owned by other managers",
+			Steps: []Step{
+				{Manager: "a", Apply: true, Object: `{"name": "n", "replicas": 1}`},
+				{Manager: "b", Apply: true, Object: `{"replicas": 1}`},
+				{Manager: "a", Apply: true, Object: `{"name": "n"}`},
+			},
+			Object: `{"name": "n", "replicas": 1}`,
+			Managed: fieldpath.ManagedFields{
+				"a": applied(path("name")),
+				"b": applied(path("replicas")),
+			},
+		},
+		{
+			Name: "appliers of the same value share the field",
+			Steps: []Step{
+				{Manager: "a", Apply: true, Object: `{"replicas": 1}`},
+				{Manager: "b", Apply: true, Object: `{"replicas": 1}`},
+			},
+			Object: `{"replicas": 1}`,
+			Managed: fieldpath.ManagedFields{
+				"a": applied(path("replicas")),
+				"b": applied(path("replicas")),
+			},
+		},
+		{
+			Name: "apply conflicts with another applier",
+			Steps: []Step{
+				{Manager: "a", Apply: true, Object: `{"replicas": 1}`},
+				{Manager: "b", Apply: true, Object: `{"replicas": 2}`, Conflicts: merge.Conflicts{
+					{Manager: "a", Path: path("replicas")},
+				}},
+			},
+			Object: `{"replicas": 1}`,
+			Managed: fieldpath.ManagedFields{
+				"a": applied(path("replicas")),
+			},
+		},
+		{
+			Name: "apply conflicts with an updater",
+			Steps: []Step{
+				{Manager: "u", Object: `{"replicas": 3}`},
+				{Manager: "a", Apply: true, Object: `{"replicas": 1}`, Conflicts: merge.Conflicts{
+					{Manager: "u", Path: path("replicas")},
+				}},
+			},
+			Object: `{"replicas": 3}`,
+			Managed: fieldpath.ManagedFields{
+				"u": updated(path("replicas")),
+			},
+		},
+		{
+			Name: "forced apply takes the conflicting fields",
+			Steps: []Step{
+				{Manager: "a", Apply: true, Object: `{"name": "n", "replicas": 1}`},
+				{Manager: "b", Apply: true, Force: true, Object: `{"replicas": 2}`},
+			},
+			Object: `{"name": "n", "replicas": 2}`,
+			Managed: fieldpath.ManagedFields{
+				"a": applied(path("name")),
+				"b": applied(path("replicas")),
+			},
+		},
+		{
+			Name: "update takes the changed fields without conflict",
+			Steps: []Step{
+				{Manager: "a", Apply: true, Object: `{"name": "n", "replicas": 1}`},
+				{Manager: "u", Object: `{"name": "n", "replicas": 2}`},
+			},
+			Object: `{"name": "n", "replicas": 2}`,
+			Managed: fieldpath.ManagedFields{
+				"a": applied(path("name")),
+				"u": updated(path("replicas")),
+			},
+		},
+		{
+			Name: "update replaces the whole object",
+			Steps: []Step{
+				{Manager: "u", Object: `{"name": "n", "replicas": 1}`},
+				{Manager: "u", Object: `{"name": "n"}`},
+			},
+			Object: `{"name": "n"}`,
+			Managed: fieldpath.ManagedFields{
+				"u": updated(path("name")),
+			},
+		},
+		{
+			Name: "granular maps are merged by key",
+			Steps: []Step{
+				{Manager: "a", Apply: true, Object: `{"labels": {"p": "x"}}`},
+				{Manager: "b", Apply: true, Object: `{"labels": {"q": "z"}}`},
+			},
+			Object: `{"labels": {"p": "x", "q": "z"}}`,
+			Managed: fieldpath.ManagedFields{
+				"a": applied(path("labels", "p")),
+				"b": applied(path("labels", "q")),
+			},
+		},
+		{
+			Name: "atomic maps are owned as a whole",
+			Steps: []Step{
+				{Manager: "a", Apply: true, Object: `{"selector": {"p": "x"}}`},
+				{Manager: "b", Apply: true, Object: `{"selector": {"q": "z"}}`, Conflicts: merge.Conflicts{
+					{Manager: "a", Path: path("selector")},
+				}},
+				{Manager: "b", Apply: true, Force: true, Object: `{"selector": {"q": "z"}}`},
+			},
+			Object: `{"selector": {"q": "z"}}`,
+			Managed: fieldpath.ManagedFields{
+				"b": applied(path("selector")),
+			},
+		},
+		{
+			Name: "atomic lists are replaced",
+			Steps: []Step{
+				{Manager: "a", Apply: true, Object: `{"args": ["p", "q"]}`},
+				{Manager: "b", Apply: true, Force: true, Object: `{"args": ["r"]}`},
+			},
+			Object: `{"args": ["r"]}`,
+			Managed: fieldpath.ManagedFields{
+				"b": applied(path("args")),
+			},
+		},
+		{
+			Name: "set items are merged",
+			Steps: []Step{
+				{Manager: "a", Apply: true, Object: `{"finalizers": ["p"]}`},
+				{Manager: "b", Apply: true, Object: `{"finalizers": ["q"]}`},
+			},
+			Object: `{"finalizers": ["p", "q"]}`,
+			Managed: fieldpath.ManagedFields{
+				"a": applied(path("finalizers", value.NewValueInterface("p"))),
+				"b": applied(path("finalizers", value.NewValueInterface("q"))),
+			},
+		},
+		{
+			Name: "associative list items are merged by key",
+			Steps: []Step{
+				{Manager: "a", Apply: true, Object: `{"containers": [{"name": "c1", "image": "i1"}]}`},
+				{Manager: "b", Apply: true, Object: `{"containers": [{"name": "c2", "image": "i2"}]}`},
+			},
+			Object: `{"containers": [{"name": "c1", "image": "i1"}, {"name": "c2", "image": "i2"}]}`,
+			Managed: fieldpath.ManagedFields{
+				"a": applied(
+					path("containers", container("c1")),
+					path("containers", container("c1"), "name"),
+					path("containers", container("c1"), "image"),
+				),
+				"b": applied(
+					path("containers", container("c2")),
+					path("containers", container("c2"), "name"),
+					path("containers", container("c2"), "image"),
+				),
+			},
+		},
+		{
+			Name: "associative list items no longer applied are removed",
+			Steps: []Step{
+				{Manager: "a", Apply: true, Object: `{"containers": [{"name": "c1", "image": "i1"}, {"name": "c2", "image": "i2"}]}`},
+				{Manager: "a", Apply: true, Object: `{"containers": [{"name": "c1", "image": "i1"}]}`},
+			},
+			Object: `{"containers": [{"name": "c1", "image": "i1"}]}`,
+			Managed: fieldpath.ManagedFields{
+				"a": applied(
+					path("containers", container("c1")),
+					path("containers", container("c1"), "name"),
+					path("containers", container("c1"), "image"),
+				),
+			},
+		},
+		{
+			Name: "associative list items owned by others are kept",
+			Steps: []Step{
+				{Manager: "a", Apply: true, Object: `{"containers": [{"name": "c1", "image": "i1"}]}`},
+				{Manager: "b", Apply: true, Object: `{"containers": [{"name": "c1"}]}`},
+				{Manager: "a", Apply: true, Object: `{"name": "n"}`},
+			},
+			Object: `{"name": "n", "containers": [{"name": "c1"}]}`,
+			Managed: fieldpath.ManagedFields{
+				"a": applied(path("name")),
+				"b": applied(
+					path("containers", container("c1")),
+					path("containers", container("c1"), "name"),
+				),
+			},
+		},
+	}
+}
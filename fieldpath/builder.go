@@ -0,0 +1,209 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fieldpath
+
+import (
+	"errors"
+	"fmt"
+
+	"sigs.k8s.io/structured-merge-diff/v4/value"
+)
+
+// PathBuilder builds a Path element by element, checking each of them as
+// it is added, so that paths built from user input fail where they are
+// built rather than when used:
+//
+//	p, err := fieldpath.NewPathBuilder().
+//		Field("spec").
+//		Field("containers").Key("name", "app").
+//		Field("image").
+//		Path()
+//
+// The first invalid element stops the build, and Path returns its error.
+type PathBuilder struct {
+	path Path
+	err  error
+}
+
+// NewPathBuilder returns a builder of an empty path.
+func NewPathBuilder() *PathBuilder {
+	return &PathBuilder{}
+}
+
+// Field adds the element selecting the field name of a map.
+func (b *PathBuilder) Field(name string) *PathBuilder {
+	return b.Element(PathElement{FieldName: &name})
+}
+
+// Key adds the element selecting the item of an associative list whose
+// fields have the given values. nameValues alternates the names of the key
+// fields, which are strings, and their values, which are value.Values or Go
+// values.
+func (b *PathBuilder) Key(nameValues ...interface{}) *PathBuilder {
+	if b.err != nil {
+		return b
+	}
+	key, err := makeKey(nameValues)
+	if err != nil {
+		b.err = fmt.Errorf("element %d: %v", len(b.path), err)
+		return b
+	}
+	return b.Element(PathElement{Key: key})
+}
+
+// Index adds the element selecting the item i of an atomic list.
+func (b *PathBuilder) Index(i int) *PathBuilder {
+	return b.Element(PathElement{Index: &i})
+}
+
+// Value adds the element selecting the item v of a set. v is a value.Value
+// or a Go value.
+func (b *PathBuilder) Value(v interface{}) *PathBuilder {
+	val := toValue(v)
+	return b.Element(PathElement{Value: &val})
+}
+
+// Element adds pe, once checked.
+func (b *PathBuilder) Element(pe PathElement) *PathBuilder {
+	if b.err != nil {
+		return b
+	}
+	if err := pe.Validate(); err != nil {
+		b.err = fmt.Errorf("element %d: %v", len(b.path), err)
+		return b
+	}
+	if err := checkFollows(b.path, pe); err != nil {
+		b.err = fmt.Errorf("element %d (%v): %v", len(b.path), pe, err)
+		return b
+	}
+	b.path = append(b.path, pe)
+	return b
+}
+
+// Path returns the path, or the error of the first invalid element.
+func (b *PathBuilder) Path() (Path, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	return b.path.Copy(), nil
+}
+
+// Validate returns an error if the path element doesn't select exactly one
+// child, or holds values which can't be serialized.
+func (e PathElement) Validate() error {
+	set := 0
+	for _, isSet := range []bool{e.FieldName != nil, e.Key != nil, e.Value != nil, e.Index != nil} {
+		if isSet {
+			set++
+		}
+	}
+	if set != 1 {
+		return fmt.Errorf("exactly one of the field name, key, value and index must be set, got %d", set)
+	}
+	switch {
+	case e.Key != nil:
+		if len(*e.Key) == 0 {
+			return errors.New("keys must have at least one field")
+		}
+		names := map[string]bool{}
+		for _, f := range *e.Key {
+			if f.Name == "" {
+				return errors.New("key fields must have names")
+			}
+			if names[f.Name] {
+				return fmt.Errorf("key field %q is repeated", f.Name)
+			}
+			names[f.Name] = true
+			if err := checkValue(f.Value); err != nil {
+				return fmt.Errorf("key field %q: %v", f.Name, err)
+			}
+		}
+	case e.Value != nil:
+		if *e.Value == nil {
+			return errors.New("value must not be nil")
+		}
+		return checkValue(*e.Value)
+	case e.Index != nil:
+		if *e.Index < 0 {
+			return fmt.Errorf("index %d is negative", *e.Index)
+		}
+	}
+	return nil
+}
+
+// Validate returns an error if an element of the path is invalid, or
+// can't follow the previous one, e.g. an index after a key: the items
+// selected by keys are maps.
+func (fp Path) Validate() error {
+	for i, pe := range fp {
+		if err := pe.Validate(); err != nil {
+			return fmt.Errorf("element %d: %v", i, err)
+		}
+		if err := checkFollows(fp[:i], pe); err != nil {
+			return fmt.Errorf("element %d (%v): %v", i, pe, err)
+		}
+	}
+	return nil
+}
+
+// checkFollows returns an error if pe can't follow the path p.
+func checkFollows(p Path, pe PathElement) error {
+	if len(p) == 0 {
+		return nil
+	}
+	switch last := p[len(p)-1]; {
+	case last.Value != nil:
+		return fmt.Errorf("can't follow the set item %v", last)
+	case last.Key != nil && pe.FieldName == nil:
+		return fmt.Errorf("only fields can follow the map item %v", last)
+	}
+	return nil
+}
+
+// checkValue returns an error if v can't be stored in a path element.
+func checkValue(v value.Value) error {
+	if _, ok := representable(v, MaxPathElementValueLength); !ok {
+		return fmt.Errorf("%v can't be serialized: it must be made of finite numbers and valid UTF-8 of at most %d bytes", value.ToString(v), MaxPathElementValueLength)
+	}
+	return nil
+}
+
+// makeKey returns the key of the names and values of nameValues, sorted.
+func makeKey(nameValues []interface{}) (*value.FieldList, error) {
+	if len(nameValues)%2 != 0 {
+		return nil, fmt.Errorf("key field %v has no value", nameValues[len(nameValues)-1])
+	}
+	key := value.FieldList{}
+	for i := 0; i < len(nameValues); i += 2 {
+		name, ok := nameValues[i].(string)
+		if !ok {
+			return nil, fmt.Errorf("argument %d: key field names must be strings, got %T", i, nameValues[i])
+		}
+		key = append(key, value.Field{Name: name, Value: toValue(nameValues[i+1])})
+	}
+	key.Sort()
+	return &key, nil
+}
+
+// toValue returns v if it is a value.Value, or else the value of the Go
+// value v.
+func toValue(v interface{}) value.Value {
+	if val, ok := v.(value.Value); ok {
+		return val
+	}
+	return value.NewValueInterface(v)
+}
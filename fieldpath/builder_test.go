@@ -0,0 +1,121 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fieldpath
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestPathBuilder(t *testing.T) {
+	table := []struct {
+		name   string
+		build  func(b *PathBuilder) *PathBuilder
+		expect Path
+		err    string
+	}{{
+		name:   "empty",
+		build:  func(b *PathBuilder) *PathBuilder { return b },
+		expect: Path{},
+	}, {
+		name: "all kinds",
+		build: func(b *PathBuilder) *PathBuilder {
+			return b.Field("spec").Field("containers").Key("name", "app", "port", 80).Field("args").Index(1)
+		},
+		expect: MakePathOrDie("spec", "containers", KeyByFields("name", "app", "port", 80), "args", 1),
+	}, {
+		name:   "set item",
+		build:  func(b *PathBuilder) *PathBuilder { return b.Field("finalizers").Value("a") },
+		expect: MakePathOrDie("finalizers", _V("a")),
+	}, {
+		name:   "value.Value",
+		build:  func(b *PathBuilder) *PathBuilder { return b.Field("list").Key("name", _V("a")).Field("x").Value(_V(1)) },
+		expect: MakePathOrDie("list", KeyByFields("name", "a"), "x", _V(1)),
+	}, {
+		name:  "odd key",
+		build: func(b *PathBuilder) *PathBuilder { return b.Field("list").Key("name", "a", "port") },
+		err:   "element 1: key field port has no value",
+	}, {
+		name:  "empty key",
+		build: func(b *PathBuilder) *PathBuilder { return b.Field("list").Key() },
+		err:   "element 1: keys must have at least one field",
+	}, {
+		name:  "non-string key name",
+		build: func(b *PathBuilder) *PathBuilder { return b.Key("name", "a", 1, "b") },
+		err:   "element 0: argument 2: key field names must be strings, got int",
+	}, {
+		name:  "repeated key",
+		build: func(b *PathBuilder) *PathBuilder { return b.Key("name", "a", "name", "b") },
+		err:   `element 0: key field "name" is repeated`,
+	}, {
+		name:  "negative index",
+		build: func(b *PathBuilder) *PathBuilder { return b.Field("list").Index(-1) },
+		err:   "element 1: index -1 is negative",
+	}, {
+		name:  "NaN",
+		build: func(b *PathBuilder) *PathBuilder { return b.Value(math.NaN()) },
+		err:   "element 0: NaN can't be serialized",
+	}, {
+		name:  "index after key",
+		build: func(b *PathBuilder) *PathBuilder { return b.Key("name", "a").Index(0) },
+		err:   `element 1 ([0]): only fields can follow the map item [name="a"]`,
+	}, {
+		name:  "after set item",
+		build: func(b *PathBuilder) *PathBuilder { return b.Value("a").Field("b") },
+		err:   `element 1 (.b): can't follow the set item [="a"]`,
+	}, {
+		name:  "first error wins",
+		build: func(b *PathBuilder) *PathBuilder { return b.Index(-1).Key() },
+		err:   "element 0: index -1 is negative",
+	}, {
+		name:  "invalid element",
+		build: func(b *PathBuilder) *PathBuilder { return b.Element(PathElement{}) },
+		err:   "element 0: exactly one of the field name, key, value and index must be set, got 0",
+	}}
+	for _, tt := range table {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.build(NewPathBuilder()).Path()
+			if tt.err != "" {
+				if err == nil || !strings.HasPrefix(err.Error(), tt.err) {
+					t.Fatalf("expected error %q, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !got.Equals(tt.expect) {
+				t.Errorf("expected %v, got %v", tt.expect, got)
+			}
+			if err := got.Validate(); err != nil {
+				t.Errorf("expected a valid path, got %v", err)
+			}
+		})
+	}
+}
+
+func TestPathValidate(t *testing.T) {
+	name := "a"
+	if err := (Path{{FieldName: &name, Index: new(int)}}).Validate(); err == nil || err.Error() != "element 0: exactly one of the field name, key, value and index must be set, got 2" {
+		t.Errorf("unexpected error %v", err)
+	}
+	if err := MakePathOrDie("list", KeyByFields("name", "a"), 0).Validate(); err == nil || err.Error() != `element 2 ([0]): only fields can follow the map item [name="a"]` {
+		t.Errorf("unexpected error %v", err)
+	}
+}
@@ -20,6 +20,7 @@ import (
 	"fmt"
 	"sort"
 	"strings"
+	"sync"
 
 	"sigs.k8s.io/structured-merge-diff/v4/value"
 )
@@ -177,6 +178,15 @@ func KeyByFields(nameValues ...interface{}) *value.FieldList {
 // TODO: serialize as a list.
 type PathElementSet struct {
 	members sortedPathElements
+
+	// index is only built once the set grows past hashIndexThreshold.
+	index map[string]struct{}
+	// pending is the number of unsorted elements at the end of members,
+	// inserted through the index since the set was last compacted.
+	pending int
+	// mu guards compaction, which readers may trigger concurrently. It is
+	// allocated along with the index.
+	mu *sync.Mutex
 }
 
 func MakePathElementSet(size int) PathElementSet {
@@ -195,6 +205,19 @@ func (spe sortedPathElements) Swap(i, j int)      { spe[i], spe[j] = spe[j], spe
 
 // Insert adds pe to the set.
 func (s *PathElementSet) Insert(pe PathElement) {
+	if s.index == nil && len(s.members) >= hashIndexThreshold {
+		s.buildIndex()
+	}
+	if s.index != nil {
+		key := pathElementKey(pe)
+		if _, ok := s.index[key]; ok {
+			return
+		}
+		s.index[key] = struct{}{}
+		s.members = append(s.members, pe)
+		s.pending++
+		return
+	}
 	loc := sort.Search(len(s.members), func(i int) bool {
 		return !s.members[i].Less(pe)
 	})
@@ -212,6 +235,8 @@ func (s *PathElementSet) Insert(pe PathElement) {
 
 // Union returns a set containing elements that appear in either s or s2.
 func (s *PathElementSet) Union(s2 *PathElementSet) *PathElementSet {
+	s.compact()
+	s2.compact()
 	out := &PathElementSet{}
 
 	i, j := 0, 0
@@ -239,6 +264,8 @@ func (s *PathElementSet) Union(s2 *PathElementSet) *PathElementSet {
 
 // Intersection returns a set containing elements which appear in both s and s2.
 func (s *PathElementSet) Intersection(s2 *PathElementSet) *PathElementSet {
+	s.compact()
+	s2.compact()
 	out := &PathElementSet{}
 
 	i, j := 0, 0
@@ -259,6 +286,8 @@ func (s *PathElementSet) Intersection(s2 *PathElementSet) *PathElementSet {
 
 // Difference returns a set containing elements which appear in s but not in s2.
 func (s *PathElementSet) Difference(s2 *PathElementSet) *PathElementSet {
+	s.compact()
+	s2.compact()
 	out := &PathElementSet{}
 
 	i, j := 0, 0
@@ -279,11 +308,70 @@ func (s *PathElementSet) Difference(s2 *PathElementSet) *PathElementSet {
 	return out
 }
 
+// unionAllPathElementSets returns a set containing elements that appear in
+// any of sets.
+func unionAllPathElementSets(sets []*PathElementSet) *PathElementSet {
+	out := &PathElementSet{}
+	pos := make([]int, len(sets))
+	for _, s := range sets {
+		s.compact()
+	}
+	for {
+		var min *PathElement
+		for i, s := range sets {
+			if pos[i] < len(s.members) && (min == nil || s.members[pos[i]].Less(*min)) {
+				min = &s.members[pos[i]]
+			}
+		}
+		if min == nil {
+			return out
+		}
+		pe := *min
+		out.members = append(out.members, pe)
+		for i, s := range sets {
+			if pos[i] < len(s.members) && s.members[pos[i]].Equals(pe) {
+				pos[i]++
+			}
+		}
+	}
+}
+
+// differenceAll returns a set containing elements which appear in s but not
+// in any of sets.
+func (s *PathElementSet) differenceAll(sets []*PathElementSet) *PathElementSet {
+	s.compact()
+	out := &PathElementSet{}
+	pos := make([]int, len(sets))
+	for _, s2 := range sets {
+		s2.compact()
+	}
+outer:
+	for _, pe := range s.members {
+		for i, s2 := range sets {
+			for pos[i] < len(s2.members) && s2.members[pos[i]].Less(pe) {
+				pos[i]++
+			}
+			if pos[i] < len(s2.members) && s2.members[pos[i]].Equals(pe) {
+				continue outer
+			}
+		}
+		out.members = append(out.members, pe)
+	}
+	return out
+}
+
 // Size retuns the number of elements in the set.
-func (s *PathElementSet) Size() int { return len(s.members) }
+func (s *PathElementSet) Size() int {
+	s.compact()
+	return len(s.members)
+}
 
 // Has returns true if pe is a member of the set.
 func (s *PathElementSet) Has(pe PathElement) bool {
+	if s.index != nil {
+		_, ok := s.index[pathElementKey(pe)]
+		return ok
+	}
 	loc := sort.Search(len(s.members), func(i int) bool {
 		return !s.members[i].Less(pe)
 	})
@@ -298,6 +386,8 @@ func (s *PathElementSet) Has(pe PathElement) bool {
 
 // Equals returns true if s and s2 have exactly the same members.
 func (s *PathElementSet) Equals(s2 *PathElementSet) bool {
+	s.compact()
+	s2.compact()
 	if len(s.members) != len(s2.members) {
 		return false
 	}
@@ -311,6 +401,7 @@ func (s *PathElementSet) Equals(s2 *PathElementSet) bool {
 
 // Iterate calls f for each PathElement in the set. The order is deterministic.
 func (s *PathElementSet) Iterate(f func(PathElement)) {
+	s.compact()
 	for _, pe := range s.members {
 		f(pe)
 	}
@@ -0,0 +1,74 @@
+//go:build go1.18
+// +build go1.18
+
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fieldpath
+
+import (
+	"bytes"
+	"testing"
+)
+
+// The seeds of the fuzz targets are in testdata/fuzz, along with the inputs
+// of the crashes found by fuzzing, which go test runs as regression tests.
+
+// FuzzSetFromJSON checks that decoding untrusted FieldsV1 doesn't panic,
+// and that the sets it decodes survive a round-trip.
+func FuzzSetFromJSON(f *testing.F) {
+	f.Add([]byte(`{"f:a":{},"f:b":{".":{},"k:{\"name\":\"c\"}":{"f:d":{}}},"v:1":{},"i:0":{}}`))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		s := NewSet()
+		if err := s.FromJSON(bytes.NewReader(data)); err != nil {
+			return
+		}
+		out, err := s.ToJSON()
+		if err != nil {
+			t.Fatalf("failed to serialize %v: %v", s, err)
+		}
+		again := NewSet()
+		if err := again.FromJSON(bytes.NewReader(out)); err != nil {
+			t.Fatalf("failed to decode %s: %v", out, err)
+		}
+		if !s.Equals(again) {
+			t.Fatalf("expected:\n%v\nafter a round-trip, got:\n%v", s, again)
+		}
+	})
+}
+
+// FuzzDecodeManagedFields checks that decoding untrusted managed fields
+// doesn't panic, and that the managers it decodes survive a round-trip.
+func FuzzDecodeManagedFields(f *testing.F) {
+	f.Add([]byte(`[{"manager":"a","operation":"Apply","apiVersion":"v1","fieldsType":"FieldsV1","fieldsV1":{"f:a":{}}}]`))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		managers, err := DecodeManagedFields(data)
+		if err != nil {
+			return
+		}
+		out, err := EncodeManagedFields(managers)
+		if err != nil {
+			t.Fatalf("failed to encode %v: %v", managers, err)
+		}
+		again, err := DecodeManagedFields(out)
+		if err != nil {
+			t.Fatalf("failed to decode %s: %v", out, err)
+		}
+		if !managers.Equals(again) {
+			t.Fatalf("expected:\n%v\nafter a round-trip, got:\n%v", managers, again)
+		}
+	})
+}
@@ -0,0 +1,211 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fieldpath
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"sigs.k8s.io/structured-merge-diff/v4/value"
+)
+
+// hashIndexThreshold is the number of elements past which PathElementSet
+// and SetNodeMap stop inserting into their sorted slice one element at a
+// time (which costs a copy of the tail of the slice on every insertion) and
+// switch to a hash index. Once indexed, new elements are appended at the
+// end of the slice and only merged back in order the next time the
+// ordering is needed.
+const hashIndexThreshold = 512
+
+// pathElementKey returns a string that uniquely identifies pe, such that two
+// path elements have the same key iff they are Equal.
+//
+// Numbers are the one exception: value.Equals compares ints and floats as
+// float64, which isn't transitive for integers that can't be represented
+// exactly as a float64. Those are keyed by their exact value.
+func pathElementKey(pe PathElement) string {
+	b := strings.Builder{}
+	switch {
+	case pe.FieldName != nil:
+		b.WriteByte('f')
+		b.WriteString(*pe.FieldName)
+	case pe.Key != nil:
+		b.WriteByte('k')
+		for _, f := range *pe.Key {
+			writeStringKey(&b, f.Name)
+			writeValueKey(&b, f.Value)
+		}
+	case pe.Value != nil:
+		b.WriteByte('v')
+		writeValueKey(&b, *pe.Value)
+	case pe.Index != nil:
+		b.WriteByte('i')
+		b.WriteString(strconv.Itoa(*pe.Index))
+	}
+	return b.String()
+}
+
+// writeStringKey writes a length-prefixed string so that concatenated
+// strings can't be confused with each other.
+func writeStringKey(b *strings.Builder, s string) {
+	b.WriteString(strconv.Itoa(len(s)))
+	b.WriteByte(':')
+	b.WriteString(s)
+}
+
+func writeValueKey(b *strings.Builder, v value.Value) {
+	switch {
+	case v.IsNull():
+		b.WriteByte('n')
+	case v.IsFloat():
+		f := v.AsFloat()
+		if f == math.Trunc(f) && f >= math.MinInt64 && f < math.MaxInt64 {
+			b.WriteByte('#')
+			b.WriteString(strconv.FormatInt(int64(f), 10))
+		} else {
+			b.WriteByte('.')
+			b.WriteString(strconv.FormatFloat(f, 'g', -1, 64))
+		}
+		b.WriteByte(';')
+	case v.IsInt():
+		b.WriteByte('#')
+		b.WriteString(strconv.FormatInt(v.AsInt(), 10))
+		b.WriteByte(';')
+	case v.IsString():
+		b.WriteByte('s')
+		writeStringKey(b, v.AsString())
+	case v.IsBool():
+		if v.AsBool() {
+			b.WriteByte('T')
+		} else {
+			b.WriteByte('F')
+		}
+	case v.IsList():
+		l := v.AsList()
+		b.WriteByte('[')
+		b.WriteString(strconv.Itoa(l.Length()))
+		b.WriteByte(':')
+		for i := 0; i < l.Length(); i++ {
+			writeValueKey(b, l.At(i))
+		}
+	case v.IsMap():
+		m := v.AsMap()
+		keys := make([]string, 0, m.Length())
+		m.Iterate(func(k string, _ value.Value) bool {
+			keys = append(keys, k)
+			return true
+		})
+		sort.Strings(keys)
+		b.WriteByte('{')
+		b.WriteString(strconv.Itoa(len(keys)))
+		b.WriteByte(':')
+		for _, k := range keys {
+			val, _ := m.Get(k)
+			writeStringKey(b, k)
+			writeValueKey(b, val)
+		}
+	}
+}
+
+// compact merges the elements appended through the hash index back into
+// the sorted order. Every read of members must be preceded by a call to
+// compact, since concurrent readers of the same set may be compacting it.
+func (s *PathElementSet) compact() {
+	if s.index == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pending == 0 {
+		return
+	}
+	n := len(s.members) - s.pending
+	tail := s.members[n:]
+	sort.Sort(tail)
+	s.members = mergeSortedPathElements(s.members[:n:n], tail)
+	s.pending = 0
+}
+
+func mergeSortedPathElements(lhs, rhs sortedPathElements) sortedPathElements {
+	out := make(sortedPathElements, 0, len(lhs)+len(rhs))
+	i, j := 0, 0
+	for i < len(lhs) && j < len(rhs) {
+		if lhs[i].Less(rhs[j]) {
+			out = append(out, lhs[i])
+			i++
+		} else {
+			out = append(out, rhs[j])
+			j++
+		}
+	}
+	out = append(out, lhs[i:]...)
+	return append(out, rhs[j:]...)
+}
+
+// buildIndex builds the hash index of the set. It must only be called on a
+// compacted set.
+func (s *PathElementSet) buildIndex() {
+	s.index = make(map[string]struct{}, len(s.members)*2)
+	s.mu = &sync.Mutex{}
+	for _, pe := range s.members {
+		s.index[pathElementKey(pe)] = struct{}{}
+	}
+}
+
+// compact merges the nodes appended through the hash index back into the
+// sorted order. Like for PathElementSet, it must precede any read of members.
+func (s *SetNodeMap) compact() {
+	if s.index == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pending == 0 {
+		return
+	}
+	n := len(s.members) - s.pending
+	tail := s.members[n:]
+	sort.Sort(tail)
+	lhs := s.members[:n:n]
+	out := make(sortedSetNode, 0, len(s.members))
+	i, j := 0, 0
+	for i < len(lhs) && j < len(tail) {
+		if lhs[i].pathElement.Less(tail[j].pathElement) {
+			out = append(out, lhs[i])
+			i++
+		} else {
+			out = append(out, tail[j])
+			j++
+		}
+	}
+	out = append(out, lhs[i:]...)
+	s.members = append(out, tail[j:]...)
+	s.pending = 0
+}
+
+// buildIndex builds the hash index of the map. It must only be called on a
+// compacted map.
+func (s *SetNodeMap) buildIndex() {
+	s.index = make(map[string]*Set, len(s.members)*2)
+	s.mu = &sync.Mutex{}
+	for _, n := range s.members {
+		s.index[pathElementKey(n.pathElement)] = n.set
+	}
+}
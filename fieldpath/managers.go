@@ -15,6 +15,7 @@ package fieldpath
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 )
 
@@ -29,9 +30,10 @@ type VersionedSet interface {
 
 // VersionedSet associates a version to a set.
 type versionedSet struct {
-	set        *Set
-	apiVersion APIVersion
-	applied    bool
+	set         *Set
+	apiVersion  APIVersion
+	applied     bool
+	subresource string
 }
 
 func NewVersionedSet(set *Set, apiVersion APIVersion, applied bool) VersionedSet {
@@ -42,6 +44,35 @@ func NewVersionedSet(set *Set, apiVersion APIVersion, applied bool) VersionedSet
 	}
 }
 
+// NewSubresourceVersionedSet returns the VersionedSet of the fields that a
+// manager owns through a subresource of the object, e.g. "status". Its
+// manager must be named after the subresource, see SubresourceManager.
+func NewSubresourceVersionedSet(set *Set, apiVersion APIVersion, applied bool, subresource string) VersionedSet {
+	return versionedSet{
+		set:         set,
+		apiVersion:  apiVersion,
+		applied:     applied,
+		subresource: subresource,
+	}
+}
+
+// WithSet returns a VersionedSet of set in apiVersion, which was applied and
+// is owned through the same subresource as v.
+func WithSet(v VersionedSet, set *Set, apiVersion APIVersion) VersionedSet {
+	return NewSubresourceVersionedSet(set, apiVersion, v.Applied(), Subresource(v))
+}
+
+// Subresource returns the subresource through which the fields of v are
+// owned, or "" if they are owned through the main resource. The
+// VersionedSets of other implementations than the ones of this package
+// have a Subresource method if they are owned through a subresource.
+func Subresource(v VersionedSet) string {
+	if s, ok := v.(interface{ Subresource() string }); ok {
+		return s.Subresource()
+	}
+	return ""
+}
+
 func (v versionedSet) Set() *Set {
 	return v.set
 }
@@ -54,6 +85,10 @@ func (v versionedSet) Applied() bool {
 	return v.applied
 }
 
+func (v versionedSet) Subresource() string {
+	return v.subresource
+}
+
 // ManagedFields is a map from manager to VersionedSet (what they own in
 // what version).
 type ManagedFields map[string]VersionedSet
@@ -70,7 +105,7 @@ func (lhs ManagedFields) Equals(rhs ManagedFields) bool {
 		if !ok {
 			return false
 		}
-		if left.APIVersion() != right.APIVersion() || left.Applied() != right.Applied() {
+		if left.APIVersion() != right.APIVersion() || left.Applied() != right.Applied() || Subresource(left) != Subresource(right) {
 			return false
 		}
 		if !left.Set().Equals(right.Set()) {
@@ -132,6 +167,31 @@ func (lhs ManagedFields) Difference(rhs ManagedFields) ManagedFields {
 	return diff
 }
 
+// WithOwnership returns a copy of the managers whose sets have the given
+// Ownership. It migrates managers recorded by an Updater with a different
+// Ownership.
+func (lhs ManagedFields) WithOwnership(o Ownership) ManagedFields {
+	out := make(ManagedFields, len(lhs))
+	for manager, set := range lhs {
+		out[manager] = WithSet(set, set.Set().WithOwnership(o), set.APIVersion())
+	}
+	return out
+}
+
+// WithoutFields returns a copy of the managers where no manager owns the
+// fields of removed nor their children, e.g. once they were removed from
+// the object by something else than an Updater. The managers left without
+// fields are dropped. removed must be in the version of all the managers.
+func (lhs ManagedFields) WithoutFields(removed *Set) ManagedFields {
+	out := make(ManagedFields, len(lhs))
+	for manager, set := range lhs {
+		if left := set.Set().RecursiveDifference(removed); !left.Empty() {
+			out[manager] = WithSet(set, left, set.APIVersion())
+		}
+	}
+	return out
+}
+
 func (lhs ManagedFields) String() string {
 	s := strings.Builder{}
 	for k, v := range lhs {
@@ -142,3 +202,96 @@ func (lhs ManagedFields) String() string {
 	}
 	return s.String()
 }
+
+// ManagedFieldsSnapshot is a read-only ManagedFields: its methods return a
+// new snapshot rather than modifying it, so that it can be shared without
+// being modified by accident. Its managers are iterated in alphabetical
+// order. The zero value is an empty snapshot.
+//
+// Snapshots are copied on write: only the map of the managers is copied,
+// their sets are shared between the snapshots and with the ManagedFields
+// they were made from or copied to. Like the Updater, which replaces the
+// sets of managers rather than modifying them, the holders of sets must
+// not modify them.
+type ManagedFieldsSnapshot struct {
+	managers ManagedFields
+	names    []string
+}
+
+// NewManagedFieldsSnapshot returns a snapshot of managers, whose entries
+// can then be added, replaced or deleted without affecting the snapshot.
+func NewManagedFieldsSnapshot(managers ManagedFields) ManagedFieldsSnapshot {
+	s := ManagedFieldsSnapshot{managers: managers.Copy()}
+	s.names = make([]string, 0, len(managers))
+	for manager := range managers {
+		s.names = append(s.names, manager)
+	}
+	sort.Strings(s.names)
+	return s
+}
+
+// Len returns the number of managers.
+func (s ManagedFieldsSnapshot) Len() int {
+	return len(s.names)
+}
+
+// Get returns the VersionedSet of manager, if any.
+func (s ManagedFieldsSnapshot) Get(manager string) (VersionedSet, bool) {
+	set, ok := s.managers[manager]
+	return set, ok
+}
+
+// Managers returns the names of the managers, in alphabetical order.
+func (s ManagedFieldsSnapshot) Managers() []string {
+	return append([]string(nil), s.names...)
+}
+
+// Iterate calls fn for every manager, in alphabetical order, until it
+// returns false.
+func (s ManagedFieldsSnapshot) Iterate(fn func(manager string, set VersionedSet) bool) {
+	for _, manager := range s.names {
+		if !fn(manager, s.managers[manager]) {
+			return
+		}
+	}
+}
+
+// With returns a snapshot where manager owns set.
+func (s ManagedFieldsSnapshot) With(manager string, set VersionedSet) ManagedFieldsSnapshot {
+	managers := s.managers.Copy()
+	managers[manager] = set
+	return NewManagedFieldsSnapshot(managers)
+}
+
+// Without returns a snapshot without manager.
+func (s ManagedFieldsSnapshot) Without(manager string) ManagedFieldsSnapshot {
+	if _, ok := s.managers[manager]; !ok {
+		return s
+	}
+	managers := s.managers.Copy()
+	delete(managers, manager)
+	return NewManagedFieldsSnapshot(managers)
+}
+
+// Copy returns the managers as a ManagedFields, whose entries can be
+// added, replaced or deleted.
+func (s ManagedFieldsSnapshot) Copy() ManagedFields {
+	return s.managers.Copy()
+}
+
+// Equals returns true if the two snapshots have the same managers.
+func (s ManagedFieldsSnapshot) Equals(other ManagedFieldsSnapshot) bool {
+	return s.managers.Equals(other.managers)
+}
+
+func (s ManagedFieldsSnapshot) String() string {
+	b := strings.Builder{}
+	for _, manager := range s.names {
+		v := s.managers[manager]
+		fmt.Fprintf(&b, "%s:\n", manager)
+		fmt.Fprintf(&b, "- Applied: %v\n", v.Applied())
+		fmt.Fprintf(&b, "- APIVersion: %v\n", v.APIVersion())
+		fmt.Fprintf(&b, "- Set: %v\n", v.Set())
+	}
+	return b.String()
+}
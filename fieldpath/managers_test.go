@@ -284,3 +284,77 @@ func TestManagersDifference(t *testing.T) {
 		})
 	}
 }
+
+func TestManagedFieldsSnapshot(t *testing.T) {
+	one := fieldpath.NewVersionedSet(_NS(_P("numeric")), "v1", false)
+	two := fieldpath.NewVersionedSet(_NS(_P("string")), "v2", true)
+	managers := fieldpath.ManagedFields{"two": two, "one": one}
+	snapshot := fieldpath.NewManagedFieldsSnapshot(managers)
+
+	// Modifying the map or the copies doesn't affect the snapshot.
+	delete(managers, "one")
+	snapshot.Copy()["three"] = one
+	if snapshot.Len() != 2 {
+		t.Fatalf("expected 2 managers, got %v", snapshot)
+	}
+
+	var names []string
+	snapshot.Iterate(func(manager string, set fieldpath.VersionedSet) bool {
+		names = append(names, manager)
+		return true
+	})
+	if !reflect.DeepEqual(names, []string{"one", "two"}) || !reflect.DeepEqual(snapshot.Managers(), names) {
+		t.Errorf("expected managers in order, got %v and %v", names, snapshot.Managers())
+	}
+
+	with := snapshot.With("three", one)
+	if _, ok := snapshot.Get("three"); ok {
+		t.Errorf("With modified the snapshot: %v", snapshot)
+	}
+	if set, ok := with.Get("three"); !ok || !set.Set().Equals(one.Set()) {
+		t.Errorf("expected three to own %v, got %v", one.Set(), with)
+	}
+	without := with.Without("three")
+	if with.Len() != 3 || !without.Equals(snapshot) || without.Equals(with) {
+		t.Errorf("unexpected snapshots: %v and %v", with, without)
+	}
+	// Snapshots are copied on write: the sets are shared.
+	if set, _ := with.Get("one"); set.Set() != one.Set() {
+		t.Errorf("expected the set of one to be shared, got a copy")
+	}
+	if !(fieldpath.ManagedFieldsSnapshot{}).Equals(fieldpath.NewManagedFieldsSnapshot(nil)) {
+		t.Error("expected the zero value to be empty")
+	}
+}
+
+func TestManagersWithoutFields(t *testing.T) {
+	managers := fieldpath.ManagedFields{
+		"applier": fieldpath.NewVersionedSet(_NS(
+			_P("spec", "replicas"),
+			_P("spec", "template", "labels", "app"),
+			_P("spec", "template", "image"),
+		), "v1", true),
+		"controller": fieldpath.NewVersionedSet(_NS(
+			_P("spec", "template", "labels", "tier"),
+		), "v1", false),
+		"other": fieldpath.NewVersionedSet(_NS(
+			_P("status"),
+		), "v1", false),
+	}
+	out := managers.WithoutFields(_NS(_P("spec", "template", "labels")))
+	expected := fieldpath.ManagedFields{
+		"applier": fieldpath.NewVersionedSet(_NS(
+			_P("spec", "replicas"),
+			_P("spec", "template", "image"),
+		), "v1", true),
+		"other": fieldpath.NewVersionedSet(_NS(
+			_P("status"),
+		), "v1", false),
+	}
+	if !out.Equals(expected) {
+		t.Errorf("expected:\n%v\ngot:\n%v", expected, out)
+	}
+	if len(managers) != 3 || !managers["controller"].Set().Has(_P("spec", "template", "labels", "tier")) {
+		t.Errorf("expected the managers not to be modified, got:\n%v", managers)
+	}
+}
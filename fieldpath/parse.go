@@ -0,0 +1,189 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fieldpath
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"sigs.k8s.io/structured-merge-diff/v4/value"
+)
+
+// ParsePath parses a path written mostly as Path.String writes it, e.g.
+//
+//	.spec.containers[name="app",port=80].args[0]
+//	.metadata.finalizers[="example.com/cleanup"]
+//	.metadata.annotations."example.com/foo"
+//
+// Fields are written .name, keys [name=value,...], set items [=value] and
+// indices [index]. The values are JSON. The path is checked like by
+// MakeValidPath.
+//
+// Path.String doesn't quote field names nor write maps as JSON, so two of
+// its outputs don't parse back: the names holding dots or brackets, like
+// most label and annotation keys, must be written ."name", and the set
+// items which are maps [={"name":value,...}].
+func ParsePath(s string) (Path, error) {
+	p := pathParser{s: s}
+	var fp Path
+	for p.i < len(s) {
+		start := p.i
+		pe, err := p.element()
+		if err == nil {
+			err = pe.Validate()
+		}
+		if err == nil {
+			err = checkFollows(fp, pe)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse path %q at offset %d: %v", s, start, err)
+		}
+		fp = append(fp, pe)
+	}
+	return fp, nil
+}
+
+type pathParser struct {
+	s string
+	i int
+}
+
+// element parses the path element at p.i.
+func (p *pathParser) element() (PathElement, error) {
+	switch p.s[p.i] {
+	case '.':
+		p.i++
+		name, err := p.fieldName()
+		if err != nil {
+			return PathElement{}, err
+		}
+		return PathElement{FieldName: &name}, nil
+	case '[':
+		p.i++
+		pe, err := p.item()
+		if err != nil {
+			return PathElement{}, err
+		}
+		if p.i >= len(p.s) || p.s[p.i] != ']' {
+			return PathElement{}, fmt.Errorf("missing ]")
+		}
+		p.i++
+		return pe, nil
+	}
+	return PathElement{}, fmt.Errorf("expected . or [, got %q", p.s[p.i])
+}
+
+func (p *pathParser) fieldName() (string, error) {
+	if p.i < len(p.s) && p.s[p.i] == '"' {
+		end := p.scanValue()
+		var name string
+		if err := json.Unmarshal([]byte(p.s[p.i:end]), &name); err != nil {
+			return "", fmt.Errorf("invalid field name %v: %v", p.s[p.i:end], err)
+		}
+		p.i = end
+		return name, nil
+	}
+	end := strings.IndexAny(p.s[p.i:], ".[")
+	if end < 0 {
+		end = len(p.s) - p.i
+	}
+	name := p.s[p.i : p.i+end]
+	p.i += end
+	return name, nil
+}
+
+// item parses the key, value or index of a list item, up to the ].
+func (p *pathParser) item() (PathElement, error) {
+	if p.i < len(p.s) && p.s[p.i] == '=' {
+		p.i++
+		v, err := p.value()
+		if err != nil {
+			return PathElement{}, err
+		}
+		return PathElement{Value: &v}, nil
+	}
+	if end := strings.IndexByte(p.s[p.i:], ']'); end >= 0 {
+		if index, err := strconv.Atoi(p.s[p.i : p.i+end]); err == nil {
+			p.i += end
+			return PathElement{Index: &index}, nil
+		}
+	}
+	key := value.FieldList{}
+	for {
+		eq := strings.IndexByte(p.s[p.i:], '=')
+		if eq < 0 {
+			return PathElement{}, fmt.Errorf("expected name=value")
+		}
+		name := p.s[p.i : p.i+eq]
+		p.i += eq + 1
+		v, err := p.value()
+		if err != nil {
+			return PathElement{}, fmt.Errorf("key field %q: %v", name, err)
+		}
+		key = append(key, value.Field{Name: name, Value: v})
+		if p.i >= len(p.s) || p.s[p.i] != ',' {
+			break
+		}
+		p.i++
+	}
+	key.Sort()
+	return PathElement{Key: &key}, nil
+}
+
+// value parses the JSON value at p.i.
+func (p *pathParser) value() (value.Value, error) {
+	end := p.scanValue()
+	raw := p.s[p.i:end]
+	if !json.Valid([]byte(raw)) {
+		return nil, fmt.Errorf("invalid JSON value %q", raw)
+	}
+	val, err := value.FromJSON([]byte(raw))
+	if err != nil {
+		return nil, fmt.Errorf("invalid value %q: %v", raw, err)
+	}
+	p.i = end
+	return val, nil
+}
+
+// scanValue returns the end of the JSON value at p.i, the first , or ] out
+// of its strings, lists and maps.
+func (p *pathParser) scanValue() int {
+	depth := 0
+	inString := false
+	for i := p.i; i < len(p.s); i++ {
+		c := p.s[i]
+		switch {
+		case inString && c == '\\':
+			i++
+		case c == '"':
+			inString = !inString
+			if !inString && depth == 0 && p.s[p.i] == '"' {
+				return i + 1
+			}
+		case inString:
+		case c == '[' || c == '{':
+			depth++
+		case (c == ']' || c == '}') && depth > 0:
+			depth--
+		case (c == ']' || c == ',') && depth == 0:
+			return i
+		}
+	}
+	return len(p.s)
+}
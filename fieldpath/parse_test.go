@@ -0,0 +1,116 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fieldpath
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestParsePath(t *testing.T) {
+	table := []struct {
+		name   string
+		s      string
+		expect Path
+		// quoted is set if the path has a quoted field name or a map set
+		// item, which Path.String doesn't write back.
+		quoted bool
+	}{
+		{"empty", "", Path{}, false},
+		{"fields", ".spec.replicas", MakePathOrDie("spec", "replicas"), false},
+		{"key", `.spec.containers[name="app"].image`, MakePathOrDie("spec", "containers", KeyByFields("name", "app"), "image"), false},
+		{"multiple-keys", `.ports[protocol="TCP",port=80]`, MakePathOrDie("ports", KeyByFields("port", 80, "protocol", "TCP")), false},
+		{"special-strings", `.list[name="a,b]c\"d"]`, MakePathOrDie("list", KeyByFields("name", `a,b]c"d`)), false},
+		{"value", `.finalizers[="example.com/cleanup"]`, MakePathOrDie("finalizers", _V("example.com/cleanup")), false},
+		{"list-value", `.set[=[1,[2]]]`, MakePathOrDie("set", _V([]interface{}{1, []interface{}{2}})), false},
+		{"index", ".args[1]", MakePathOrDie("args", 1), false},
+		{"quoted-field", `.metadata.labels."app.kubernetes.io/name"`, MakePathOrDie("metadata", "labels", "app.kubernetes.io/name"), true},
+		{"quoted-simple-field", `.metadata."name"`, MakePathOrDie("metadata", "name"), false},
+		{"map-value", `.set[={"x":"y","z":[1]}]`, MakePathOrDie("set", _V(map[string]interface{}{"x": "y", "z": []interface{}{1}})), true},
+		{"floats-and-bools", `.list[a=1.5,b=true]`, MakePathOrDie("list", KeyByFields("a", 1.5, "b", true)), false},
+	}
+	for _, tt := range table {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParsePath(tt.s)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !got.Equals(tt.expect) {
+				t.Errorf("expected %v, got %v", tt.expect, got)
+			}
+			if !tt.quoted {
+				if again, err := ParsePath(got.String()); err != nil || !again.Equals(got) {
+					t.Errorf("%v didn't round trip: %v, %v", got, again, err)
+				}
+			}
+		})
+	}
+}
+
+func TestParsePathDottedNames(t *testing.T) {
+	names := []string{
+		"app.kubernetes.io/name",
+		"example.com/foo",
+		"a[0]",
+	}
+	for _, name := range names {
+		name := name
+		t.Run(name, func(t *testing.T) {
+			expect := MakePathOrDie("metadata", "annotations", name)
+			// Written ."name", dotted names round trip.
+			s := ".metadata.annotations." + strconv.Quote(name)
+			got, err := ParsePath(s)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !got.Equals(expect) {
+				t.Errorf("expected %v, got %v", expect, got)
+			}
+			if ok, err := NewSet(expect).HasString(s); err != nil || !ok {
+				t.Errorf("expected the set of %v to have %v: %v", expect, s, err)
+			}
+			// Written as Path.String writes them, they don't.
+			if again, err := ParsePath(expect.String()); err == nil && again.Equals(expect) {
+				t.Errorf("expected %v not to parse back to the same path", expect)
+			}
+		})
+	}
+}
+
+func TestParsePathErrors(t *testing.T) {
+	table := []struct {
+		s   string
+		err string
+	}{
+		{"spec", `unable to parse path "spec" at offset 0: expected . or [, got 's'`},
+		{".list[name=app]", `unable to parse path ".list[name=app]" at offset 5: key field "name": invalid JSON value "app"`},
+		{`.list[name="a"`, `unable to parse path ".list[name=\"a\"" at offset 5: missing ]`},
+		{".list[-1]", `unable to parse path ".list[-1]" at offset 5: index -1 is negative`},
+		{".list[nokey]", `unable to parse path ".list[nokey]" at offset 5: expected name=value`},
+		{`.list[name="a"][0]`, `unable to parse path ".list[name=\"a\"][0]" at offset 15: only fields can follow the map item [name="a"]`},
+	}
+	for _, tt := range table {
+		tt := tt
+		t.Run(tt.s, func(t *testing.T) {
+			_, err := ParsePath(tt.s)
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("expected error %q, got %v", tt.err, err)
+			}
+		})
+	}
+}
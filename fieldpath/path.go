@@ -20,6 +20,7 @@ import (
 	"fmt"
 	"strings"
 
+	"sigs.k8s.io/structured-merge-diff/v4/schema"
 	"sigs.k8s.io/structured-merge-diff/v4/value"
 )
 
@@ -78,37 +79,179 @@ func (fp Path) Copy() Path {
 	return new
 }
 
-// MakePath constructs a Path. The parts may be PathElements, ints, strings.
-func MakePath(parts ...interface{}) (Path, error) {
-	var fp Path
-	for _, p := range parts {
-		switch t := p.(type) {
-		case PathElement:
-			fp = append(fp, t)
-		case int:
-			// TODO: Understand schema and object and convert this to the
-			// FieldSpecifier below if appropriate.
-			fp = append(fp, PathElement{Index: &t})
-		case string:
-			fp = append(fp, PathElement{FieldName: &t})
-		case *value.FieldList:
-			if len(*t) == 0 {
-				return nil, fmt.Errorf("associative list key type path elements must have at least one key (got zero)")
+// Lookup returns the value at fp in v, if any. Without a schema, the keys
+// of list items are compared byte-wise, see typed.TypedValue.Lookup for
+// the lists whose keys have collations.
+func (fp Path) Lookup(v value.Value) (value.Value, bool) {
+	for _, pe := range fp {
+		if v == nil {
+			return nil, false
+		}
+		switch {
+		case pe.FieldName != nil:
+			if !v.IsMap() {
+				return nil, false
+			}
+			var ok bool
+			if v, ok = v.AsMap().Get(*pe.FieldName); !ok {
+				return nil, false
+			}
+		case pe.Index != nil:
+			if !v.IsList() || *pe.Index < 0 || *pe.Index >= v.AsList().Length() {
+				return nil, false
+			}
+			v = v.AsList().At(*pe.Index)
+		case pe.Key != nil, pe.Value != nil:
+			if !v.IsList() {
+				return nil, false
+			}
+			l := v.AsList()
+			found := false
+			for i := 0; i < l.Length() && !found; i++ {
+				if found = itemMatches(l.At(i), pe, nil); found {
+					v = l.At(i)
+				}
+			}
+			if !found {
+				return nil, false
 			}
-			fp = append(fp, PathElement{Key: t})
-		case value.Value:
-			// TODO: understand schema and verify that this is a set type
-			// TODO: make a copy of t
-			fp = append(fp, PathElement{Value: &t})
 		default:
-			return nil, fmt.Errorf("unable to make %#v into a path element", p)
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+// MatchesItem returns true if pe is a key or a value path element which
+// identifies the given list item.
+func (pe PathElement) MatchesItem(item value.Value) bool {
+	return pe.MatchesItemCollated(item, nil)
+}
+
+// MatchesItemCollated is like MatchesItem, for the items of a list whose
+// keys have collations: the path elements of such lists hold the folded
+// values of the keys, so the ones of item are folded before they are
+// compared.
+func (pe PathElement) MatchesItemCollated(item value.Value, collations []schema.KeyCollation) bool {
+	if pe.Key == nil && pe.Value == nil {
+		return false
+	}
+	return itemMatches(item, pe, collations)
+}
+
+// itemMatches returns true if the list item is the one of pe, a key or a
+// value path element, once the keys with collations are folded.
+func itemMatches(item value.Value, pe PathElement, collations []schema.KeyCollation) bool {
+	if pe.Value != nil {
+		return pathElementValueMatches(item, *pe.Value)
+	}
+	if !item.IsMap() {
+		return false
+	}
+	m := item.AsMap()
+	for _, field := range *pe.Key {
+		fv, ok := m.Get(field.Name)
+		if !ok || !pathElementValueMatches(foldKey(field.Name, fv, collations), field.Value) {
+			return false
+		}
+	}
+	return true
+}
+
+// foldKey returns the value v of the key field name folded by its
+// collation, if it has one.
+func foldKey(name string, v value.Value, collations []schema.KeyCollation) value.Value {
+	for _, kc := range collations {
+		if kc.Key != name || !v.IsString() {
+			continue
+		}
+		if folded, ok := kc.Collation.Fold(v.AsString()); ok {
+			return value.NewValueInterface(folded)
+		}
+	}
+	return v
+}
+
+// PathArgumentError is the error of MakePath and MakeValidPath for the
+// argument which can't be made into a path element.
+type PathArgumentError struct {
+	// Index is the position of the argument.
+	Index int
+	// Arg is the argument.
+	Arg interface{}
+	Err error
+}
+
+func (e *PathArgumentError) Error() string {
+	return fmt.Sprintf("argument %d (%#v): %v", e.Index, e.Arg, e.Err)
+}
+
+func (e *PathArgumentError) Unwrap() error {
+	return e.Err
+}
+
+// MakePath constructs a Path. The parts may be PathElements, ints, strings,
+// *value.FieldLists for keys and value.Values for set items. The errors are
+// *PathArgumentErrors.
+func MakePath(parts ...interface{}) (Path, error) {
+	var fp Path
+	for i, p := range parts {
+		pe, err := makePathElement(p)
+		if err != nil {
+			return nil, &PathArgumentError{Index: i, Arg: p, Err: err}
 		}
+		fp = append(fp, pe)
 	}
 	return fp, nil
 }
 
+// MakeValidPath is MakePath, but also returns an error if an element is
+// invalid, or can't follow the previous one, see Path.Validate. Paths
+// built from user input should be made with it, or with a PathBuilder.
+func MakeValidPath(parts ...interface{}) (Path, error) {
+	var fp Path
+	for i, p := range parts {
+		pe, err := makePathElement(p)
+		if err == nil {
+			err = pe.Validate()
+		}
+		if err == nil {
+			err = checkFollows(fp, pe)
+		}
+		if err != nil {
+			return nil, &PathArgumentError{Index: i, Arg: p, Err: err}
+		}
+		fp = append(fp, pe)
+	}
+	return fp, nil
+}
+
+func makePathElement(p interface{}) (PathElement, error) {
+	switch t := p.(type) {
+	case PathElement:
+		return t, nil
+	case int:
+		// TODO: Understand schema and object and convert this to the
+		// FieldSpecifier below if appropriate.
+		return PathElement{Index: &t}, nil
+	case string:
+		return PathElement{FieldName: &t}, nil
+	case *value.FieldList:
+		if len(*t) == 0 {
+			return PathElement{}, fmt.Errorf("associative list key type path elements must have at least one key (got zero)")
+		}
+		return PathElement{Key: t}, nil
+	case value.Value:
+		// TODO: understand schema and verify that this is a set type
+		// TODO: make a copy of t
+		return PathElement{Value: &t}, nil
+	}
+	return PathElement{}, fmt.Errorf("unable to make %T into a path element", p)
+}
+
 // MakePathOrDie panics if parts can't be turned into a path. Good for things
-// that are known at complie time.
+// that are known at complie time: paths built at runtime, from user input,
+// should be made with MakeValidPath or a PathBuilder, which return errors.
 func MakePathOrDie(parts ...interface{}) Path {
 	fp, err := MakePath(parts...)
 	if err != nil {
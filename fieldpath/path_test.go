@@ -17,6 +17,7 @@ limitations under the License.
 package fieldpath
 
 import (
+	"errors"
 	"testing"
 
 	"sigs.k8s.io/structured-merge-diff/v4/value"
@@ -62,3 +63,81 @@ func TestPathString(t *testing.T) {
 		})
 	}
 }
+
+func TestPathLookup(t *testing.T) {
+	obj := _V(map[string]interface{}{
+		"foo": map[string]interface{}{"bar": "baz"},
+		"list": []interface{}{
+			map[string]interface{}{"name": "a", "value": 1},
+			map[string]interface{}{"name": "b", "value": 2},
+		},
+		"set": []interface{}{"x", "y"},
+	})
+	table := []struct {
+		name   string
+		fp     Path
+		expect interface{}
+		found  bool
+	}{
+		{"root", MakePathOrDie(), obj.Unstructured(), true},
+		{"field", MakePathOrDie("foo", "bar"), "baz", true},
+		{"missing-field", MakePathOrDie("foo", "qux"), nil, false},
+		{"not-a-map", MakePathOrDie("foo", "bar", "baz"), nil, false},
+		{"key", MakePathOrDie("list", KeyByFields("name", "b"), "value"), int64(2), true},
+		{"missing-key", MakePathOrDie("list", KeyByFields("name", "c")), nil, false},
+		{"index", MakePathOrDie("list", 0, "name"), "a", true},
+		{"out-of-range", MakePathOrDie("list", 2), nil, false},
+		{"value", MakePathOrDie("set", _V("y")), "y", true},
+		{"missing-value", MakePathOrDie("set", _V("z")), nil, false},
+	}
+	for _, tt := range table {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			got, found := tt.fp.Lookup(obj)
+			if found != tt.found {
+				t.Fatalf("expected found to be %v, got %v", tt.found, found)
+			}
+			if found && !value.Equals(got, _V(tt.expect)) {
+				t.Errorf("expected %v, got %v", value.ToString(_V(tt.expect)), value.ToString(got))
+			}
+		})
+	}
+}
+
+func TestMakePathErrors(t *testing.T) {
+	table := []struct {
+		name  string
+		make  func(parts ...interface{}) (Path, error)
+		parts []interface{}
+		index int
+		err   string
+	}{
+		{"unknown-type", MakePath, []interface{}{"foo", 1.5}, 1, "argument 1 (1.5): unable to make float64 into a path element"},
+		{"empty-key", MakePath, []interface{}{"foo", "bar", &value.FieldList{}}, 2, "argument 2 (&value.FieldList{}): associative list key type path elements must have at least one key (got zero)"},
+		{"negative-index", MakeValidPath, []interface{}{"foo", -1}, 1, "argument 1 (-1): index -1 is negative"},
+		{"index-after-key", MakeValidPath, []interface{}{"foo", KeyByFields("name", "a"), 0}, 2, `argument 2 (0): only fields can follow the map item [name="a"]`},
+	}
+	for _, tt := range table {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.make(tt.parts...)
+			var argErr *PathArgumentError
+			if !errors.As(err, &argErr) {
+				t.Fatalf("expected a *PathArgumentError, got %v", err)
+			}
+			if argErr.Index != tt.index {
+				t.Errorf("expected the argument %d, got %d", tt.index, argErr.Index)
+			}
+			if err.Error() != tt.err {
+				t.Errorf("expected error %q, got %q", tt.err, err)
+			}
+		})
+	}
+	// Invalid paths can still be made by MakePath.
+	if _, err := MakePath("foo", -1); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+	if _, err := MakeValidPath("foo", KeyByFields("name", "a"), "bar", 0, _V("x")); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+}
@@ -0,0 +1,145 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fieldpath
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"sigs.k8s.io/structured-merge-diff/v4/value"
+)
+
+// MaxPathElementValueLength is the maximum length, in bytes of strings, of
+// the values of keys and set items stored in path elements by
+// SafePathElementValue. Longer values are hashed.
+const MaxPathElementValueLength = 1024
+
+// HashedValuePrefix is the prefix of the hashes of the values which can't be
+// stored in path elements as they are.
+const HashedValuePrefix = "sha256:"
+
+// SafePathElementValue returns v if it can be stored in a path element and
+// serialized without loss, or else a string made of HashedValuePrefix and
+// the hash of v. The values which can't be stored as they are are:
+//   - floats which are NaN or infinite, which JSON can't represent,
+//   - strings which aren't valid UTF-8, which would be altered once
+//     serialized, since JSON replaces the invalid bytes,
+//   - values whose strings add up to more than MaxPathElementValueLength
+//     bytes, which would bloat the managed fields.
+//
+// The values of the keys and set items in the path elements built from
+// objects go through it, so that exotic values can't corrupt the managed
+// fields, and equal values keep matching. So do the ones of deserialized
+// path elements, so that the managed fields recorded before values were
+// hashed, which hold the long values as they are, match the path elements
+// built from objects: they are hashed once decoded, and then encoded
+// hashed.
+func SafePathElementValue(v value.Value) value.Value {
+	if _, ok := representable(v, MaxPathElementValueLength); ok {
+		return v
+	}
+	return hashPathElementValue(v)
+}
+
+func hashPathElementValue(v value.Value) value.Value {
+	sum := sha256.Sum256([]byte(canonicalString(v)))
+	return value.NewValueInterface(HashedValuePrefix + hex.EncodeToString(sum[:]))
+}
+
+// pathElementValueMatches returns true if the value v of an object is the
+// value stored in a path element, either as it is or hashed.
+func pathElementValueMatches(v, stored value.Value) bool {
+	if value.Equals(v, stored) {
+		return true
+	}
+	if _, ok := representable(v, MaxPathElementValueLength); ok {
+		return false
+	}
+	return value.Equals(hashPathElementValue(v), stored)
+}
+
+// representable returns true if v can be serialized without loss with
+// strings of at most budget bytes, and what's left of the budget.
+func representable(v value.Value, budget int) (int, bool) {
+	switch {
+	case v.IsFloat():
+		f := v.AsFloat()
+		return budget, !math.IsNaN(f) && !math.IsInf(f, 0)
+	case v.IsString():
+		s := v.AsString()
+		budget -= len(s)
+		return budget, budget >= 0 && utf8.ValidString(s)
+	case v.IsList():
+		l := v.AsList()
+		for i := 0; i < l.Length(); i++ {
+			var ok bool
+			if budget, ok = representable(l.At(i), budget); !ok {
+				return budget, false
+			}
+		}
+	case v.IsMap():
+		ok := true
+		v.AsMap().Iterate(func(k string, v value.Value) bool {
+			if budget -= len(k); budget < 0 || !utf8.ValidString(k) {
+				ok = false
+				return false
+			}
+			budget, ok = representable(v, budget)
+			return ok
+		})
+		return budget, ok
+	}
+	return budget, true
+}
+
+// canonicalString returns a lossless and deterministic representation of
+// v, for hashing.
+func canonicalString(v value.Value) string {
+	switch {
+	case v.IsNull():
+		return "null"
+	case v.IsBool():
+		return strconv.FormatBool(v.AsBool())
+	case v.IsInt():
+		return strconv.FormatInt(v.AsInt(), 10)
+	case v.IsFloat():
+		return strconv.FormatFloat(v.AsFloat(), 'g', -1, 64)
+	case v.IsString():
+		return strconv.Quote(v.AsString())
+	case v.IsList():
+		l := v.AsList()
+		items := make([]string, l.Length())
+		for i := range items {
+			items[i] = canonicalString(l.At(i))
+		}
+		return "[" + strings.Join(items, ",") + "]"
+	case v.IsMap():
+		var fields []string
+		v.AsMap().Iterate(func(k string, v value.Value) bool {
+			fields = append(fields, strconv.Quote(k)+":"+canonicalString(v))
+			return true
+		})
+		sort.Strings(fields)
+		return "{" + strings.Join(fields, ",") + "}"
+	}
+	return ""
+}
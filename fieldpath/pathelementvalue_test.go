@@ -0,0 +1,102 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fieldpath
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"sigs.k8s.io/structured-merge-diff/v4/value"
+)
+
+func TestSafePathElementValue(t *testing.T) {
+	long := strings.Repeat("a", MaxPathElementValueLength+1)
+	table := []struct {
+		name   string
+		v      interface{}
+		hashed bool
+	}{
+		{"null", nil, false},
+		{"int", 1, false},
+		{"float", 1.5, false},
+		{"string", "foo", false},
+		{"max-length", strings.Repeat("a", MaxPathElementValueLength), false},
+		{"map", map[string]interface{}{"a": "b", "c": []interface{}{1, "d"}}, false},
+		{"nan", math.NaN(), true},
+		{"inf", math.Inf(-1), true},
+		{"invalid-utf8", "invalid \xff utf8", true},
+		{"long", long, true},
+		{"long-map", map[string]interface{}{"a": long[:600], "b": long[:600]}, true},
+		{"invalid-utf8-map-key", map[string]interface{}{"\xff": "a"}, true},
+		{"nan-in-list", []interface{}{1, math.NaN()}, true},
+	}
+	for _, tt := range table {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			v := value.NewValueInterface(tt.v)
+			got := SafePathElementValue(v)
+			hashed := got.IsString() && strings.HasPrefix(got.AsString(), HashedValuePrefix) && !value.Equals(got, v)
+			if hashed != tt.hashed {
+				t.Fatalf("expected hashed to be %v, got %v", tt.hashed, value.ToString(got))
+			}
+			if !hashed {
+				return
+			}
+			if again := SafePathElementValue(value.NewValueInterface(tt.v)); !value.Equals(again, got) {
+				t.Errorf("expected the hash to be stable, got %v and %v", value.ToString(got), value.ToString(again))
+			}
+			// Hashed values round-trip.
+			for _, pe := range []PathElement{{Value: &got}, {Key: &value.FieldList{{Name: "name", Value: got}}}} {
+				serialized, err := SerializePathElement(pe)
+				if err != nil {
+					t.Fatalf("failed to serialize %v: %v", pe, err)
+				}
+				if len(serialized) > 100 {
+					t.Errorf("expected a short serialization, got %q", serialized)
+				}
+				deserialized, err := DeserializePathElement(serialized)
+				if err != nil {
+					t.Fatalf("failed to deserialize %q: %v", serialized, err)
+				}
+				if !deserialized.Equals(pe) {
+					t.Errorf("expected %v to round-trip, got %v", pe, deserialized)
+				}
+			}
+		})
+	}
+}
+
+func TestSafePathElementValueDistinct(t *testing.T) {
+	values := []interface{}{
+		"invalid \xff utf8",
+		"invalid \xfe utf8",
+		strings.Repeat("a", MaxPathElementValueLength+1),
+		strings.Repeat("a", MaxPathElementValueLength+2),
+		math.NaN(),
+		math.Inf(1),
+		math.Inf(-1),
+	}
+	seen := map[string]interface{}{}
+	for _, v := range values {
+		got := SafePathElementValue(value.NewValueInterface(v)).AsString()
+		if prev, ok := seen[got]; ok {
+			t.Errorf("%q and %q have the same hash %v", prev, v, got)
+		}
+		seen[got] = v
+	}
+}
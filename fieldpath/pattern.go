@@ -0,0 +1,292 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fieldpath
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Pattern matches paths, like a glob. Patterns are written like paths, see
+// ParsePath, with wildcards:
+//   - .* matches any field;
+//   - [*] matches any list item, whether selected by key, value or index;
+//   - .** matches any number of elements, none included, so that
+//     .status.** matches .status and everything below it.
+//
+// E.g. .spec.containers[*].resources.** matches the resources of every
+// container. The other elements match path elements which are equal, so
+// keys match whatever the order of their fields, and values whatever their
+// spelling: [port=80] matches [port=80.0] too.
+//
+// Patterns are matched one element at a time (see PatternState), so that
+// walks of sets and objects can stop where nothing can match.
+type Pattern struct {
+	source   string
+	elements []patternElement
+}
+
+type patternKind int
+
+const (
+	matchElement patternKind = iota
+	matchAnyField
+	matchAnyItem
+	matchAnyPath
+)
+
+type patternElement struct {
+	kind patternKind
+	pe   PathElement
+}
+
+func (e patternElement) matches(pe PathElement) bool {
+	switch e.kind {
+	case matchAnyField:
+		return pe.FieldName != nil
+	case matchAnyItem:
+		return pe.FieldName == nil
+	case matchAnyPath:
+		return true
+	}
+	return e.pe.Equals(pe)
+}
+
+// ParsePattern parses a pattern.
+func ParsePattern(s string) (*Pattern, error) {
+	p := pathParser{s: s}
+	pattern := &Pattern{source: s}
+	for p.i < len(s) {
+		start := p.i
+		rest := s[p.i:]
+		var elem patternElement
+		switch {
+		case hasWildcardPrefix(rest, ".**"):
+			elem.kind = matchAnyPath
+			p.i += len(".**")
+		case hasWildcardPrefix(rest, ".*"):
+			elem.kind = matchAnyField
+			p.i += len(".*")
+		case hasWildcardPrefix(rest, "[*]"):
+			elem.kind = matchAnyItem
+			p.i += len("[*]")
+		default:
+			pe, err := p.element()
+			if err == nil {
+				err = pe.Validate()
+			}
+			if err != nil {
+				return nil, fmt.Errorf("unable to parse pattern %q at offset %d: %v", s, start, err)
+			}
+			elem.pe = pe
+		}
+		n := len(pattern.elements)
+		if elem.kind == matchAnyPath && n > 0 && pattern.elements[n-1].kind == matchAnyPath {
+			// .**.** is .**
+			continue
+		}
+		pattern.elements = append(pattern.elements, elem)
+	}
+	return pattern, nil
+}
+
+// hasWildcardPrefix returns true if s starts with the wildcard w, followed
+// by the next element or nothing.
+func hasWildcardPrefix(s, w string) bool {
+	return strings.HasPrefix(s, w) && (len(s) == len(w) || s[len(w)] == '.' || s[len(w)] == '[')
+}
+
+// ParsePatternOrDie panics if s can't be parsed. Good for things that are
+// known at compile time.
+func ParsePatternOrDie(s string) *Pattern {
+	p, err := ParsePattern(s)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// String returns the pattern as it was written.
+func (p *Pattern) String() string {
+	return p.source
+}
+
+// Matches returns true if the pattern matches the whole path.
+func (p *Pattern) Matches(path Path) bool {
+	s := p.Start()
+	for _, pe := range path {
+		if s = s.Next(pe); s.Failed() {
+			return false
+		}
+	}
+	return s.Matched()
+}
+
+// PatternState is where a Pattern is, once it has matched the elements of
+// a path. The zero PatternState has failed.
+type PatternState struct {
+	p *Pattern
+	// positions are the elements of the pattern which can match the next
+	// path element, in order, or len(p.elements) once it has matched.
+	positions []int
+}
+
+// Start returns the state of the pattern before the first element.
+func (p *Pattern) Start() PatternState {
+	return PatternState{p: p, positions: p.closure(nil, 0)}
+}
+
+// closure adds i to positions, and the positions which follow the .**
+// elements matching no path element.
+func (p *Pattern) closure(positions []int, i int) []int {
+	for {
+		if n := len(positions); n == 0 || positions[n-1] < i {
+			positions = append(positions, i)
+		}
+		if i == len(p.elements) || p.elements[i].kind != matchAnyPath {
+			return positions
+		}
+		i++
+	}
+}
+
+// Next returns the state of the pattern once it has matched pe.
+func (s PatternState) Next(pe PathElement) PatternState {
+	var next []int
+	for _, i := range s.positions {
+		if i == len(s.p.elements) || !s.p.elements[i].matches(pe) {
+			continue
+		}
+		if s.p.elements[i].kind == matchAnyPath {
+			next = s.p.closure(next, i)
+		} else {
+			next = s.p.closure(next, i+1)
+		}
+	}
+	return PatternState{p: s.p, positions: next}
+}
+
+// Matched returns true if the pattern matches the path so far.
+func (s PatternState) Matched() bool {
+	n := len(s.positions)
+	return n > 0 && s.positions[n-1] == len(s.p.elements)
+}
+
+// Failed returns true if the pattern can't match the path so far, nor any
+// path which starts with it.
+func (s PatternState) Failed() bool {
+	return len(s.positions) == 0
+}
+
+// PatternStates are the states of several patterns, which match a path if
+// one of them does.
+type PatternStates []PatternState
+
+// StartPatterns returns the states of patterns before the first element.
+func StartPatterns(patterns ...*Pattern) PatternStates {
+	states := make(PatternStates, len(patterns))
+	for i, p := range patterns {
+		states[i] = p.Start()
+	}
+	return states
+}
+
+// Next returns the states of the patterns which haven't failed once they
+// have matched pe.
+func (s PatternStates) Next(pe PathElement) PatternStates {
+	var next PatternStates
+	for _, state := range s {
+		if state = state.Next(pe); !state.Failed() {
+			next = append(next, state)
+		}
+	}
+	return next
+}
+
+// Matched returns true if one of the patterns matches the path so far.
+func (s PatternStates) Matched() bool {
+	for _, state := range s {
+		if state.Matched() {
+			return true
+		}
+	}
+	return false
+}
+
+// Failed returns true if none of the patterns can match the path so far,
+// nor any path which starts with it.
+func (s PatternStates) Failed() bool {
+	for _, state := range s {
+		if !state.Failed() {
+			return false
+		}
+	}
+	return true
+}
+
+// FilterPatterns returns the subset of the paths of s which one of the
+// patterns matches. The children of s which no pattern can match aren't
+// walked.
+func (s *Set) FilterPatterns(patterns ...*Pattern) *Set {
+	return s.filterPatterns(StartPatterns(patterns...))
+}
+
+func (s *Set) filterPatterns(states PatternStates) *Set {
+	out := &Set{}
+	s.Members.Iterate(func(pe PathElement) {
+		if states.Next(pe).Matched() {
+			out.Members.Insert(pe)
+		}
+	})
+	s.Children.compact()
+	for _, n := range s.Children.members {
+		next := states.Next(n.pathElement)
+		if next.Failed() {
+			continue
+		}
+		if child := n.set.filterPatterns(next); !child.Empty() {
+			out.Children.members = append(out.Children.members, setNode{pathElement: n.pathElement, set: child})
+		}
+	}
+	return out
+}
+
+// NewIncludePatternFilter returns a filter that only includes the field
+// paths which one of the patterns matches.
+func NewIncludePatternFilter(patterns ...*Pattern) Filter {
+	return patternFilter{patterns: patterns}
+}
+
+// NewExcludePatternFilter returns a filter that removes the field paths
+// which one of the patterns matches, e.g. .status.** for the status and
+// its children.
+func NewExcludePatternFilter(patterns ...*Pattern) Filter {
+	return patternFilter{patterns: patterns, exclude: true}
+}
+
+type patternFilter struct {
+	patterns []*Pattern
+	exclude  bool
+}
+
+func (f patternFilter) Filter(set *Set) *Set {
+	matched := set.FilterPatterns(f.patterns...)
+	if f.exclude {
+		return set.Difference(matched)
+	}
+	return matched
+}
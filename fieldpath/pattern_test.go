@@ -0,0 +1,150 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fieldpath
+
+import (
+	"testing"
+)
+
+func TestPatternMatches(t *testing.T) {
+	table := []struct {
+		pattern string
+		path    Path
+		matches bool
+	}{
+		{".spec", MakePathOrDie("spec"), true},
+		{".spec", MakePathOrDie("spec", "replicas"), false},
+		{".spec", MakePathOrDie(), false},
+		{"", MakePathOrDie(), true},
+		{".*", MakePathOrDie("spec"), true},
+		{".*", MakePathOrDie(0), false},
+		{".spec.*.image", MakePathOrDie("spec", "main", "image"), true},
+		{".list[*]", MakePathOrDie("list", 0), true},
+		{".list[*]", MakePathOrDie("list", KeyByFields("name", "a")), true},
+		{".list[*]", MakePathOrDie("list", _V("a")), true},
+		{".list[*]", MakePathOrDie("list", "a"), false},
+		{".spec.containers[*].resources.**", MakePathOrDie("spec", "containers", KeyByFields("name", "a"), "resources"), true},
+		{".spec.containers[*].resources.**", MakePathOrDie("spec", "containers", KeyByFields("name", "a"), "resources", "limits", "cpu"), true},
+		{".spec.containers[*].resources.**", MakePathOrDie("spec", "containers", KeyByFields("name", "a"), "image"), false},
+		{".**.image", MakePathOrDie("image"), true},
+		{".**.image", MakePathOrDie("spec", "containers", 1, "image"), true},
+		{".**.image", MakePathOrDie("spec", "image", "tag"), false},
+		{".**.image.**.tag", MakePathOrDie("image", "a", "image", "tag"), true},
+		{".**", MakePathOrDie(), true},
+		{".**.**", MakePathOrDie("a", "b"), true},
+		// Keys match structurally.
+		{`.ports[protocol="TCP",port=80]`, MakePathOrDie("ports", KeyByFields("port", 80, "protocol", "TCP")), true},
+		{`.ports[port=80.0]`, MakePathOrDie("ports", KeyByFields("port", 80)), true},
+		{`.ports[port=80]`, MakePathOrDie("ports", KeyByFields("port", 80, "protocol", "TCP")), false},
+		// Quoted wildcards are fields.
+		{`."*"`, MakePathOrDie("*"), true},
+		{`."*"`, MakePathOrDie("a"), false},
+		{`.*a`, MakePathOrDie("*a"), true},
+	}
+	for _, tt := range table {
+		tt := tt
+		t.Run(tt.pattern+" "+tt.path.String(), func(t *testing.T) {
+			p, err := ParsePattern(tt.pattern)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := p.Matches(tt.path); got != tt.matches {
+				t.Errorf("expected %v, got %v", tt.matches, got)
+			}
+		})
+	}
+}
+
+func TestParsePatternErrors(t *testing.T) {
+	for _, s := range []string{"spec", ".list[-1]", `.list[name=a]`, ".list[*"} {
+		if _, err := ParsePattern(s); err == nil {
+			t.Errorf("expected an error for %q", s)
+		}
+	}
+}
+
+func TestPatternStateFailed(t *testing.T) {
+	s := ParsePatternOrDie(".spec.containers[*].image").Start()
+	for _, pe := range MakePathOrDie("spec", "containers") {
+		if s = s.Next(pe); s.Failed() || s.Matched() {
+			t.Fatalf("unexpected state after %v", pe)
+		}
+	}
+	if !s.Next(MakePathOrDie("other")[0]).Failed() {
+		t.Errorf("expected the pattern to fail")
+	}
+	states := StartPatterns(ParsePatternOrDie(".spec"), ParsePatternOrDie(".status.**"))
+	if !states.Next(MakePathOrDie("metadata")[0]).Failed() {
+		t.Errorf("expected the patterns to fail")
+	}
+	if !states.Next(MakePathOrDie("status")[0]).Next(MakePathOrDie("x")[0]).Matched() {
+		t.Errorf("expected the patterns to match")
+	}
+}
+
+func TestSetFilterPatterns(t *testing.T) {
+	s := NewSet(
+		MakePathOrDie("spec", "containers", KeyByFields("name", "a")),
+		MakePathOrDie("spec", "containers", KeyByFields("name", "a"), "image"),
+		MakePathOrDie("spec", "containers", KeyByFields("name", "a"), "resources", "limits", "cpu"),
+		MakePathOrDie("spec", "containers", KeyByFields("name", "b"), "image"),
+		MakePathOrDie("spec", "replicas"),
+		MakePathOrDie("status", "ready"),
+	)
+	table := []struct {
+		name     string
+		patterns []string
+		expected *Set
+	}{{
+		name:     "images",
+		patterns: []string{".**.image"},
+		expected: NewSet(
+			MakePathOrDie("spec", "containers", KeyByFields("name", "a"), "image"),
+			MakePathOrDie("spec", "containers", KeyByFields("name", "b"), "image"),
+		),
+	}, {
+		name:     "items and status",
+		patterns: []string{".spec.containers[*]", ".status.**"},
+		expected: NewSet(
+			MakePathOrDie("spec", "containers", KeyByFields("name", "a")),
+			MakePathOrDie("status", "ready"),
+		),
+	}, {
+		name:     "none",
+		patterns: []string{".metadata.**"},
+		expected: NewSet(),
+	}}
+	for _, tt := range table {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			var patterns []*Pattern
+			for _, p := range tt.patterns {
+				patterns = append(patterns, ParsePatternOrDie(p))
+			}
+			got := s.FilterPatterns(patterns...)
+			if !got.Equals(tt.expected) {
+				t.Errorf("expected:\n%v\ngot:\n%v", tt.expected, got)
+			}
+			if excluded := NewExcludePatternFilter(patterns...).Filter(s); !excluded.Equals(s.Difference(tt.expected)) {
+				t.Errorf("expected:\n%v\ngot:\n%v", s.Difference(tt.expected), excluded)
+			}
+			if included := NewIncludePatternFilter(patterns...).Filter(s); !included.Equals(tt.expected) {
+				t.Errorf("expected:\n%v\ngot:\n%v", tt.expected, included)
+			}
+		})
+	}
+}
@@ -0,0 +1,156 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fieldpath
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// OwnershipRow is a field owned by a manager, as exported by
+// ManagedFields.OwnershipRows and OwnershipWriter.
+type OwnershipRow struct {
+	// Object identifies the object of the managers, if the rows of several
+	// objects are exported together.
+	Object      string     `json:"object,omitempty"`
+	Manager     string     `json:"manager"`
+	Subresource string     `json:"subresource,omitempty"`
+	Operation   string     `json:"operation"`
+	APIVersion  APIVersion `json:"apiVersion"`
+	Path        string     `json:"path"`
+}
+
+// ownershipColumns are the columns of the CSV export, in the order of the
+// fields of OwnershipRow.
+var ownershipColumns = []string{"object", "manager", "subresource", "operation", "apiVersion", "path"}
+
+func (r OwnershipRow) columns() []string {
+	return []string{r.Object, r.Manager, r.Subresource, r.Operation, string(r.APIVersion), r.Path}
+}
+
+// OwnershipRows flattens the managers into one row per owned field,
+// ordered by manager and then as Set.Iterate walks their sets. The manager
+// and subresource of each row are the ones of EncodeManagedFields.
+func (lhs ManagedFields) OwnershipRows() []OwnershipRow {
+	var rows []OwnershipRow
+	lhs.iterateRows(func(r OwnershipRow) error {
+		rows = append(rows, r)
+		return nil
+	})
+	return rows
+}
+
+func (lhs ManagedFields) iterateRows(fn func(OwnershipRow) error) error {
+	var err error
+	for _, name := range sortedManagers(lhs) {
+		// The rows are only read, so the managers which aren't named after
+		// their subresource are listed with their whole name.
+		entry, _ := newManagedFieldsEntry(name, lhs[name], nil)
+		lhs[name].Set().Iterate(func(p Path) {
+			if err != nil {
+				return
+			}
+			err = fn(OwnershipRow{
+				Manager:     entry.Manager,
+				Subresource: entry.Subresource,
+				Operation:   entry.Operation,
+				APIVersion:  entry.APIVersion,
+				Path:        p.String(),
+			})
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// OwnershipFormat is the format of the rows written by an OwnershipWriter.
+type OwnershipFormat string
+
+const (
+	// OwnershipCSV writes the rows as CSV, after a header naming the
+	// columns.
+	OwnershipCSV OwnershipFormat = "csv"
+	// OwnershipJSON writes the rows as JSON lines, one object per row.
+	OwnershipJSON OwnershipFormat = "json"
+)
+
+// OwnershipWriter exports the ownership of many objects, one at a time, to
+// a single report, e.g. to list every field owned by a manager across all
+// the objects of a cluster.
+type OwnershipWriter struct {
+	format OwnershipFormat
+	csv    *csv.Writer
+	json   *json.Encoder
+	header bool
+}
+
+// NewOwnershipWriter returns a writer of rows to w in format.
+func NewOwnershipWriter(w io.Writer, format OwnershipFormat) (*OwnershipWriter, error) {
+	ow := &OwnershipWriter{format: format}
+	switch format {
+	case OwnershipCSV:
+		ow.csv = csv.NewWriter(w)
+	case OwnershipJSON:
+		ow.json = json.NewEncoder(w)
+	default:
+		return nil, fmt.Errorf("unknown ownership format %q", format)
+	}
+	return ow, nil
+}
+
+// Write writes the rows of the managers of object, see
+// ManagedFields.OwnershipRows.
+func (w *OwnershipWriter) Write(object string, managers ManagedFields) error {
+	return managers.iterateRows(func(r OwnershipRow) error {
+		r.Object = object
+		return w.WriteRow(r)
+	})
+}
+
+// WriteRow writes a single row.
+func (w *OwnershipWriter) WriteRow(r OwnershipRow) error {
+	if w.format == OwnershipJSON {
+		return w.json.Encode(r)
+	}
+	if !w.header {
+		w.header = true
+		if err := w.csv.Write(ownershipColumns); err != nil {
+			return err
+		}
+	}
+	return w.csv.Write(r.columns())
+}
+
+// Flush writes the buffered rows, and must be called once all the rows are
+// written.
+func (w *OwnershipWriter) Flush() error {
+	if w.csv == nil {
+		return nil
+	}
+	if !w.header {
+		w.header = true
+		if err := w.csv.Write(ownershipColumns); err != nil {
+			return err
+		}
+	}
+	w.csv.Flush()
+	return w.csv.Error()
+}
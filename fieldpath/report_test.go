@@ -0,0 +1,91 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fieldpath
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestOwnershipRows(t *testing.T) {
+	managers := ManagedFields{
+		"kubectl": NewVersionedSet(NewSet(
+			MakePathOrDie("spec", "replicas"),
+			MakePathOrDie("spec", "containers", KeyByFields("name", "c"), "image"),
+		), "apps/v1", true),
+		"controller (status)": NewSubresourceVersionedSet(NewSet(
+			MakePathOrDie("status", "replicas"),
+		), "apps/v1", false, "status"),
+	}
+	want := []OwnershipRow{
+		{Manager: "controller", Subresource: "status", Operation: "Update", APIVersion: "apps/v1", Path: ".status.replicas"},
+		{Manager: "kubectl", Operation: "Apply", APIVersion: "apps/v1", Path: ".spec.replicas"},
+		{Manager: "kubectl", Operation: "Apply", APIVersion: "apps/v1", Path: `.spec.containers[name="c"].image`},
+	}
+	if got := managers.OwnershipRows(); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected rows:\n%v\ngot:\n%v", want, got)
+	}
+
+	tests := []struct {
+		format OwnershipFormat
+		want   string
+	}{{
+		format: OwnershipCSV,
+		want: `object,manager,subresource,operation,apiVersion,path
+ns/a,controller,status,Update,apps/v1,.status.replicas
+ns/a,kubectl,,Apply,apps/v1,.spec.replicas
+ns/a,kubectl,,Apply,apps/v1,".spec.containers[name=""c""].image"
+ns/b,kubectl,,Apply,apps/v1,.spec.replicas
+ns/b,kubectl,,Apply,apps/v1,".spec.containers[name=""c""].image"
+`,
+	}, {
+		format: OwnershipJSON,
+		want: `{"object":"ns/a","manager":"controller","subresource":"status","operation":"Update","apiVersion":"apps/v1","path":".status.replicas"}
+{"object":"ns/a","manager":"kubectl","operation":"Apply","apiVersion":"apps/v1","path":".spec.replicas"}
+{"object":"ns/a","manager":"kubectl","operation":"Apply","apiVersion":"apps/v1","path":".spec.containers[name=\"c\"].image"}
+{"object":"ns/b","manager":"kubectl","operation":"Apply","apiVersion":"apps/v1","path":".spec.replicas"}
+{"object":"ns/b","manager":"kubectl","operation":"Apply","apiVersion":"apps/v1","path":".spec.containers[name=\"c\"].image"}
+`,
+	}}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(string(tt.format), func(t *testing.T) {
+			var buf bytes.Buffer
+			w, err := NewOwnershipWriter(&buf, tt.format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := w.Write("ns/a", managers); err != nil {
+				t.Fatal(err)
+			}
+			if err := w.Write("ns/b", ManagedFields{"kubectl": managers["kubectl"]}); err != nil {
+				t.Fatal(err)
+			}
+			if err := w.Flush(); err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); got != tt.want {
+				t.Errorf("expected:\n%v\ngot:\n%v", tt.want, got)
+			}
+		})
+	}
+
+	if _, err := NewOwnershipWriter(&bytes.Buffer{}, "xml"); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
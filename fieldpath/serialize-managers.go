@@ -0,0 +1,383 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fieldpath
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+const (
+	operationApply  = "Apply"
+	operationUpdate = "Update"
+	fieldsTypeV1    = "FieldsV1"
+	// fieldsTypeV1Delta is the fields type of the entries encoded as a
+	// difference with the entry of another manager, see
+	// EncodeManagedFieldsDelta.
+	fieldsTypeV1Delta = "FieldsV1Delta"
+)
+
+// managedFieldsEntry is the encoding of a manager. Its fields have the
+// names of the ones of the Kubernetes ManagedFieldsEntry.
+type managedFieldsEntry struct {
+	Manager     string          `json:"manager"`
+	Operation   string          `json:"operation"`
+	APIVersion  APIVersion      `json:"apiVersion"`
+	FieldsType  string          `json:"fieldsType"`
+	FieldsV1    json.RawMessage `json:"fieldsV1"`
+	Subresource string          `json:"subresource,omitempty"`
+
+	// Base is the name of the manager whose fields the fields of a delta
+	// entry are relative to: FieldsV1 are added to them, and
+	// FieldsV1Removed removed.
+	Base            string          `json:"base,omitempty"`
+	FieldsV1Removed json.RawMessage `json:"fieldsV1Removed,omitempty"`
+}
+
+// SubresourceManager returns the name under which the fields that manager
+// owns through subresource are tracked in ManagedFields, or manager if
+// subresource is empty. The name only keeps the managers apart: whether
+// fields are owned through a subresource is told by their VersionedSet
+// (see NewSubresourceVersionedSet), not by the name, so that the name of a
+// manager may end with parentheses too.
+func SubresourceManager(manager, subresource string) string {
+	if subresource == "" {
+		return manager
+	}
+	return fmt.Sprintf("%s (%s)", manager, subresource)
+}
+
+// EncodeManagedFields encodes managers in JSON, as a list of entries
+// ordered by name and shaped like the Kubernetes ManagedFieldsEntry:
+//
+//	[{"manager": "m", "operation": "Apply", "apiVersion": "v1",
+//	  "fieldsType": "FieldsV1", "fieldsV1": {"f:a": {}},
+//	  "subresource": "status"}]
+//
+// The subresource is the one of the VersionedSet (see Subresource), which
+// is removed from the name of the manager. The encoding is canonical: equal
+// managers have the same encoding.
+func EncodeManagedFields(managers ManagedFields) ([]byte, error) {
+	buf := bytes.Buffer{}
+	if err := EncodeManagedFieldsStream(&buf, managers); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// EncodeManagedFieldsStream writes the encoding of EncodeManagedFields to
+// w. The field sets are written as they are walked, without encoding each
+// of them separately first.
+func EncodeManagedFieldsStream(w io.Writer, managers ManagedFields) error {
+	stream := writePool.BorrowStream(w)
+	defer writePool.ReturnStream(stream)
+
+	var r reusableBuilder
+	stream.WriteArrayStart()
+	for i, name := range sortedManagers(managers) {
+		if i > 0 {
+			stream.WriteMore()
+		}
+		entry, err := newManagedFieldsEntry(name, managers[name], nil)
+		if err != nil {
+			return err
+		}
+		stream.WriteObjectStart()
+		writeEntryField(stream, "manager", entry.Manager)
+		stream.WriteMore()
+		writeEntryField(stream, "operation", entry.Operation)
+		stream.WriteMore()
+		writeEntryField(stream, "apiVersion", string(entry.APIVersion))
+		stream.WriteMore()
+		writeEntryField(stream, "fieldsType", entry.FieldsType)
+		stream.WriteMore()
+		stream.WriteObjectField("fieldsV1")
+		stream.WriteObjectStart()
+		if err := managers[name].Set().emitContentsV1(false, stream, &r); err != nil {
+			return fmt.Errorf("failed to encode the fields of %q: %v", name, err)
+		}
+		stream.WriteObjectEnd()
+		if entry.Subresource != "" {
+			stream.WriteMore()
+			writeEntryField(stream, "subresource", entry.Subresource)
+		}
+		stream.WriteObjectEnd()
+	}
+	stream.WriteArrayEnd()
+	return stream.Flush()
+}
+
+// writeEntryField writes a string field, escaped like encoding/json does.
+func writeEntryField(stream *jsoniter.Stream, field, value string) {
+	stream.WriteObjectField(field)
+	stream.WriteStringWithHTMLEscaped(value)
+}
+
+// EncodeManagedFieldsDelta encodes managers like EncodeManagedFields, but
+// the fields of a manager which mostly overlap with the ones of a manager
+// listed before are encoded as their difference:
+//
+//	{"manager": "m2", "operation": "Update", "apiVersion": "v1",
+//	 "fieldsType": "FieldsV1Delta", "base": "m1",
+//	 "fieldsV1": {"f:b": {}}, "fieldsV1Removed": {"f:a": {}}}
+//
+// which is much smaller when many managers own the same fields, e.g. of a
+// pod template. DecodeManagedFields decodes both encodings.
+//
+// Only a few of the managers listed before are tried as bases, those of
+// the same version whose sets are the closest in size, so that the sets
+// are compared a bounded number of times per manager.
+func EncodeManagedFieldsDelta(managers ManagedFields) ([]byte, error) {
+	names := sortedManagers(managers)
+	sizes := make([]int, len(names))
+	for i, name := range names {
+		sizes[i] = managers[name].Set().Size()
+	}
+	entries := make([]managedFieldsEntry, 0, len(names))
+	for i, name := range names {
+		set := managers[name].Set()
+		fields, err := set.ToJSON()
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode the fields of %q: %v", name, err)
+		}
+		entry, err := newManagedFieldsEntry(name, managers[name], fields)
+		if err != nil {
+			return nil, err
+		}
+		best := len(fields)
+		for _, j := range deltaBases(managers, names, sizes, i) {
+			base := names[j]
+			baseSet := managers[base].Set()
+			added, err := set.Difference(baseSet).ToJSON()
+			if err != nil {
+				return nil, fmt.Errorf("failed to encode the fields of %q: %v", name, err)
+			}
+			removed, err := baseSet.Difference(set).ToJSON()
+			if err != nil {
+				return nil, fmt.Errorf("failed to encode the fields of %q: %v", name, err)
+			}
+			// A delta also costs the name of its base and the extra keys.
+			if size := len(added) + len(removed) + len(base) + len(`,"base":"","fieldsV1Removed":`); size < best {
+				best = size
+				entry.FieldsType = fieldsTypeV1Delta
+				entry.Base = base
+				entry.FieldsV1 = added
+				entry.FieldsV1Removed = removed
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return json.Marshal(entries)
+}
+
+// maxDeltaBases is the number of bases tried for each manager by
+// EncodeManagedFieldsDelta.
+const maxDeltaBases = 3
+
+// deltaBases returns the indexes of the at most maxDeltaBases managers
+// listed before the i-th one which are tried as its base: the ones of the
+// same version first, then the ones whose sets are the closest in size.
+// Managers without fields neither have nor are bases.
+func deltaBases(managers ManagedFields, names []string, sizes []int, i int) []int {
+	if sizes[i] == 0 {
+		return nil
+	}
+	version := managers[names[i]].APIVersion()
+	// Bases are ranked by version, then by distance in size.
+	rank := func(j int) (bool, int) {
+		d := sizes[j] - sizes[i]
+		if d < 0 {
+			d = -d
+		}
+		return managers[names[j]].APIVersion() != version, d
+	}
+	less := func(a, b int) bool {
+		oa, da := rank(a)
+		ob, db := rank(b)
+		if oa != ob {
+			return ob
+		}
+		return da < db
+	}
+	// The best ones are kept sorted by insertion.
+	bases := make([]int, 0, maxDeltaBases)
+	for j := 0; j < i; j++ {
+		if sizes[j] == 0 {
+			continue
+		}
+		if len(bases) == maxDeltaBases {
+			if !less(j, bases[maxDeltaBases-1]) {
+				continue
+			}
+			bases = bases[:maxDeltaBases-1]
+		}
+		k := len(bases)
+		for k > 0 && less(j, bases[k-1]) {
+			k--
+		}
+		bases = append(bases, 0)
+		copy(bases[k+1:], bases[k:])
+		bases[k] = j
+	}
+	return bases
+}
+
+func sortedManagers(managers ManagedFields) []string {
+	names := make([]string, 0, len(managers))
+	for name := range managers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// newManagedFieldsEntry returns the entry of the manager name, without
+// fields if fields is nil. It fails if the manager owns fields through a
+// subresource but isn't named after it, in which case the manager of the
+// entry is the whole name.
+func newManagedFieldsEntry(name string, set VersionedSet, fields []byte) (managedFieldsEntry, error) {
+	entry := managedFieldsEntry{
+		Manager:     name,
+		Operation:   operationUpdate,
+		APIVersion:  set.APIVersion(),
+		FieldsType:  fieldsTypeV1,
+		FieldsV1:    fields,
+		Subresource: Subresource(set),
+	}
+	if entry.Subresource != "" {
+		suffix := SubresourceManager("", entry.Subresource)
+		if !strings.HasSuffix(name, suffix) {
+			return entry, fmt.Errorf("%q owns fields through subresource %q but isn't named after it", name, entry.Subresource)
+		}
+		entry.Manager = strings.TrimSuffix(name, suffix)
+	}
+	if set.Applied() {
+		entry.Operation = operationApply
+	}
+	return entry, nil
+}
+
+// DecodeManagedFields decodes managers encoded by EncodeManagedFields or
+// EncodeManagedFieldsDelta.
+func DecodeManagedFields(data []byte) (ManagedFields, error) {
+	return DecodeManagedFieldsStream(bytes.NewReader(data))
+}
+
+// DecodeManagedFieldsStream decodes managers read from r, like
+// DecodeManagedFields. The field sets are built from the JSON tokens as
+// they are read, without decoding the entries in memory first.
+func DecodeManagedFieldsStream(r io.Reader) (ManagedFields, error) {
+	// The iterator pool is useless for readers, see Set.FromJSON.
+	iter := jsoniter.Parse(jsoniter.ConfigCompatibleWithStandardLibrary, r, 4096)
+
+	managers := ManagedFields{}
+	var err error
+	iter.ReadArrayCB(func(iter *jsoniter.Iterator) bool {
+		var entry decodedEntry
+		iter.ReadMapCB(func(iter *jsoniter.Iterator, key string) bool {
+			switch key {
+			case "manager":
+				entry.Manager = iter.ReadString()
+			case "operation":
+				entry.Operation = iter.ReadString()
+			case "apiVersion":
+				entry.APIVersion = APIVersion(iter.ReadString())
+			case "fieldsType":
+				entry.FieldsType = iter.ReadString()
+			case "subresource":
+				entry.Subresource = iter.ReadString()
+			case "base":
+				entry.Base = iter.ReadString()
+			case "fieldsV1":
+				entry.fields = readFieldsV1(iter)
+			case "fieldsV1Removed":
+				entry.removed = readFieldsV1(iter)
+			default:
+				iter.Skip()
+			}
+			return iter.Error == nil
+		})
+		if iter.Error != nil {
+			return false
+		}
+		err = managers.addEntry(&entry)
+		return err == nil
+	})
+	if iter.Error != nil {
+		return nil, fmt.Errorf("invalid managed fields: %v", iter.Error)
+	}
+	if err != nil {
+		return nil, err
+	}
+	// Only the end of the input is left after the entries.
+	if iter.WhatIsNext(); iter.Error != io.EOF {
+		return nil, fmt.Errorf("invalid managed fields: unexpected data after the entries")
+	}
+	return managers, nil
+}
+
+// decodedEntry is a managedFieldsEntry whose field sets are decoded.
+type decodedEntry struct {
+	managedFieldsEntry
+	fields, removed *Set
+}
+
+func readFieldsV1(iter *jsoniter.Iterator) *Set {
+	set, _ := readIterV1(iter)
+	if set == nil {
+		return &Set{}
+	}
+	return set
+}
+
+// addEntry adds the manager of entry to managers.
+func (managers ManagedFields) addEntry(entry *decodedEntry) error {
+	name := SubresourceManager(entry.Manager, entry.Subresource)
+	if _, ok := managers[name]; ok {
+		return fmt.Errorf("invalid managed fields: %q is listed twice", name)
+	}
+	if entry.Operation != operationApply && entry.Operation != operationUpdate {
+		return fmt.Errorf("invalid managed fields of %q: unknown operation %q", name, entry.Operation)
+	}
+	set := entry.fields
+	if set == nil {
+		return fmt.Errorf("invalid managed fields of %q: missing fieldsV1", name)
+	}
+	switch entry.FieldsType {
+	case fieldsTypeV1:
+	case fieldsTypeV1Delta:
+		base, ok := managers[entry.Base]
+		if !ok {
+			return fmt.Errorf("invalid managed fields of %q: base %q isn't listed before", name, entry.Base)
+		}
+		removed := entry.removed
+		if removed == nil {
+			removed = &Set{}
+		}
+		set = base.Set().Difference(removed).Union(set)
+	default:
+		return fmt.Errorf("invalid managed fields of %q: unsupported fields type %q", name, entry.FieldsType)
+	}
+	managers[name] = NewSubresourceVersionedSet(set, entry.APIVersion, entry.Operation == operationApply, entry.Subresource)
+	return nil
+}
@@ -0,0 +1,270 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fieldpath_test
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
+)
+
+func TestManagedFieldsRoundTrip(t *testing.T) {
+	managers := fieldpath.ManagedFields{
+		"kubectl": fieldpath.NewVersionedSet(_NS(_P("spec", "replicas")), "v1", true),
+		"controller (status)": fieldpath.NewSubresourceVersionedSet(
+			_NS(_P("status", "ready"), _P("status", "replicas")), "v2", false, "status"),
+		"empty": fieldpath.NewVersionedSet(_NS(), "v1", false),
+	}
+	data, err := fieldpath.EncodeManagedFields(managers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `[` +
+		`{"manager":"controller","operation":"Update","apiVersion":"v2","fieldsType":"FieldsV1","fieldsV1":{"f:status":{"f:ready":{},"f:replicas":{}}},"subresource":"status"},` +
+		`{"manager":"empty","operation":"Update","apiVersion":"v1","fieldsType":"FieldsV1","fieldsV1":{}},` +
+		`{"manager":"kubectl","operation":"Apply","apiVersion":"v1","fieldsType":"FieldsV1","fieldsV1":{"f:spec":{"f:replicas":{}}}}` +
+		`]`
+	if string(data) != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, data)
+	}
+	decoded, err := fieldpath.DecodeManagedFields(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !decoded.Equals(managers) {
+		t.Errorf("expected:\n%v\ngot:\n%v", managers, decoded)
+	}
+}
+
+func TestDecodeManagedFieldsErrors(t *testing.T) {
+	for _, data := range []string{
+		`{}`,
+		`[{"manager":"m","operation":"Patch","apiVersion":"v1","fieldsType":"FieldsV1","fieldsV1":{}}]`,
+		`[{"manager":"m","operation":"Apply","apiVersion":"v1","fieldsType":"FieldsV2","fieldsV1":{}}]`,
+		`[{"manager":"m","operation":"Apply","apiVersion":"v1","fieldsType":"FieldsV1","fieldsV1":{"x":{}}}]`,
+		`[{"manager":"m","operation":"Apply","apiVersion":"v1","fieldsType":"FieldsV1","fieldsV1":{}},` +
+			`{"manager":"m","operation":"Update","apiVersion":"v1","fieldsType":"FieldsV1","fieldsV1":{}}]`,
+		`[{"manager":"m","operation":"Apply","apiVersion":"v1","fieldsType":"FieldsV1"}]`,
+		`[] []`,
+		``,
+	} {
+		if _, err := fieldpath.DecodeManagedFields([]byte(data)); err == nil {
+			t.Errorf("expected an error for %s", data)
+		}
+	}
+}
+
+func TestManagedFieldsStream(t *testing.T) {
+	managers := manyManagers(20)
+	var buf bytes.Buffer
+	if err := fieldpath.EncodeManagedFieldsStream(&buf, managers); err != nil {
+		t.Fatal(err)
+	}
+	data, err := fieldpath.EncodeManagedFields(managers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != string(data) {
+		t.Errorf("expected the stream to be encoded like EncodeManagedFields:\n%s\ngot:\n%s", data, buf.String())
+	}
+	// Unknown keys of the entries are ignored.
+	data = bytes.Replace(buf.Bytes(), []byte(`"operation"`), []byte(`"time":{"a":[1]},"operation"`), -1)
+	decoded, err := fieldpath.DecodeManagedFieldsStream(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !decoded.Equals(managers) {
+		t.Errorf("expected:\n%v\ngot:\n%v", managers, decoded)
+	}
+}
+
+// manyManagers returns n managers owning the fields of a pod template
+// and some of their own.
+func manyManagers(n int) fieldpath.ManagedFields {
+	managers := fieldpath.ManagedFields{}
+	for i := 0; i < n; i++ {
+		set := _NS(
+			_P("metadata", "labels", fmt.Sprintf("label-%d", i)),
+			_P("spec", "template", "spec", "containers", fieldpath.KeyByFields("name", "app"), "image"),
+			_P("spec", "template", "spec", "containers", fieldpath.KeyByFields("name", fmt.Sprintf("sidecar-%d", i)), "image"),
+		)
+		managers[fmt.Sprintf("manager-%d", i)] = fieldpath.NewVersionedSet(set, "v1", i%2 == 0)
+	}
+	return managers
+}
+
+func BenchmarkDecodeManagedFields(b *testing.B) {
+	data, err := fieldpath.EncodeManagedFields(manyManagers(200))
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := fieldpath.DecodeManagedFields(data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkEncodeManagedFields(b *testing.B) {
+	managers := manyManagers(200)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := fieldpath.EncodeManagedFields(managers); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestManagedFieldsParenthesizedNames(t *testing.T) {
+	managers := fieldpath.ManagedFields{
+		"helm (v3)": fieldpath.NewVersionedSet(_NS(_P("spec", "replicas")), "v1", true),
+		"a (b) (scale)": fieldpath.NewSubresourceVersionedSet(
+			_NS(_P("spec", "replicas")), "v1", false, "scale"),
+	}
+	data, err := fieldpath.EncodeManagedFields(managers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `[` +
+		`{"manager":"a (b)","operation":"Update","apiVersion":"v1","fieldsType":"FieldsV1","fieldsV1":{"f:spec":{"f:replicas":{}}},"subresource":"scale"},` +
+		`{"manager":"helm (v3)","operation":"Apply","apiVersion":"v1","fieldsType":"FieldsV1","fieldsV1":{"f:spec":{"f:replicas":{}}}}` +
+		`]`
+	if string(data) != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, data)
+	}
+	decoded, err := fieldpath.DecodeManagedFields(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !decoded.Equals(managers) {
+		t.Errorf("expected:\n%v\ngot:\n%v", managers, decoded)
+	}
+	if sub := fieldpath.Subresource(decoded["helm (v3)"]); sub != "" {
+		t.Errorf("expected helm (v3) to own fields through the main resource, got %q", sub)
+	}
+
+	// A manager owning fields through a subresource must be named after it.
+	misnamed := fieldpath.ManagedFields{
+		"controller": fieldpath.NewSubresourceVersionedSet(_NS(_P("status")), "v1", false, "status"),
+	}
+	if _, err := fieldpath.EncodeManagedFields(misnamed); err == nil {
+		t.Error("expected a manager not named after its subresource to be rejected")
+	}
+	// The same name can't be a manager of the main resource and of a
+	// subresource.
+	collision := `[` +
+		`{"manager":"helm","operation":"Apply","apiVersion":"v1","fieldsType":"FieldsV1","fieldsV1":{},"subresource":"v3"},` +
+		`{"manager":"helm (v3)","operation":"Apply","apiVersion":"v1","fieldsType":"FieldsV1","fieldsV1":{}}` +
+		`]`
+	if _, err := fieldpath.DecodeManagedFields([]byte(collision)); err == nil {
+		t.Error("expected colliding managers to be rejected")
+	}
+}
+
+func TestManagedFieldsDeltaRoundTrip(t *testing.T) {
+	template := []fieldpath.Path{
+		_P("spec", "template", "spec", "containers", fieldpath.KeyByFields("name", "app"), "image"),
+		_P("spec", "template", "spec", "containers", fieldpath.KeyByFields("name", "app"), "args"),
+		_P("spec", "template", "spec", "containers", fieldpath.KeyByFields("name", "app"), "resources", "limits", "cpu"),
+		_P("spec", "template", "spec", "containers", fieldpath.KeyByFields("name", "app"), "resources", "limits", "memory"),
+		_P("spec", "template", "metadata", "labels", "app"),
+	}
+	managers := fieldpath.ManagedFields{
+		"a": fieldpath.NewVersionedSet(_NS(template...), "v1", true),
+		"b": fieldpath.NewVersionedSet(_NS(append(template[1:], _P("spec", "replicas"))...), "v1", false),
+		"c": fieldpath.NewVersionedSet(_NS(_P("status", "ready")), "v1", false),
+		"d": fieldpath.NewVersionedSet(_NS(), "v1", false),
+	}
+	full, err := fieldpath.EncodeManagedFields(managers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	delta, err := fieldpath.EncodeManagedFieldsDelta(managers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(delta) >= len(full) {
+		t.Errorf("expected the delta encoding to be smaller, got %v and %v bytes:\n%s", len(delta), len(full), delta)
+	}
+	expected := `{"manager":"b","operation":"Update","apiVersion":"v1","fieldsType":"FieldsV1Delta","fieldsV1":{"f:spec":{"f:replicas":{}}},` +
+		`"base":"a","fieldsV1Removed":{"f:spec":{"f:template":{"f:spec":{"f:containers":{"k:{\"name\":\"app\"}":{"f:image":{}}}}}}}}`
+	if !strings.Contains(string(delta), expected) {
+		t.Errorf("expected %s in:\n%s", expected, delta)
+	}
+	for _, data := range [][]byte{full, delta} {
+		decoded, err := fieldpath.DecodeManagedFields(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !decoded.Equals(managers) {
+			t.Errorf("expected:\n%v\ngot:\n%v", managers, decoded)
+		}
+	}
+}
+
+func TestManagedFieldsDeltaBases(t *testing.T) {
+	template := []fieldpath.Path{
+		_P("spec", "template", "metadata", "labels", "app"),
+		_P("spec", "template", "spec", "containers", fieldpath.KeyByFields("name", "app"), "image"),
+		_P("spec", "template", "spec", "containers", fieldpath.KeyByFields("name", "app"), "args"),
+	}
+	managers := fieldpath.ManagedFields{
+		"a": fieldpath.NewVersionedSet(_NS(template...), "v2", false),
+		"b": fieldpath.NewVersionedSet(_NS(template[:2]...), "v1", false),
+		"c": fieldpath.NewVersionedSet(_NS(), "v1", false),
+	}
+	for i := 0; i < 50; i++ {
+		name := fmt.Sprintf("m%02d", i)
+		managers[name] = fieldpath.NewVersionedSet(_NS(append(template, _P("status", name))...), "v1", false)
+	}
+	full, err := fieldpath.EncodeManagedFields(managers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	delta, err := fieldpath.EncodeManagedFieldsDelta(managers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(delta) >= len(full) {
+		t.Errorf("expected the delta encoding to be smaller, got %v and %v bytes:\n%s", len(delta), len(full), delta)
+	}
+	decoded, err := fieldpath.DecodeManagedFields(delta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !decoded.Equals(managers) {
+		t.Errorf("expected:\n%v\ngot:\n%v", managers, decoded)
+	}
+}
+
+func TestDecodeManagedFieldsDeltaErrors(t *testing.T) {
+	for _, data := range []string{
+		`[{"manager":"m","operation":"Apply","apiVersion":"v1","fieldsType":"FieldsV1Delta","base":"n","fieldsV1":{}}]`,
+		`[{"manager":"n","operation":"Apply","apiVersion":"v1","fieldsType":"FieldsV1","fieldsV1":{}},` +
+			`{"manager":"m","operation":"Update","apiVersion":"v1","fieldsType":"FieldsV1Delta","base":"n","fieldsV1":{},"fieldsV1Removed":{"x":{}}}]`,
+	} {
+		if _, err := fieldpath.DecodeManagedFields([]byte(data)); err == nil {
+			t.Errorf("expected an error for %s", data)
+		}
+	}
+}
@@ -20,10 +20,13 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math"
+	"sort"
 	"strconv"
 	"strings"
 
 	jsoniter "github.com/json-iterator/go"
+	"sigs.k8s.io/structured-merge-diff/v4/internal/bytesconv"
 	"sigs.k8s.io/structured-merge-diff/v4/value"
 )
 
@@ -56,7 +59,8 @@ var (
 
 // DeserializePathElement parses a serialized path element
 func DeserializePathElement(s string) (PathElement, error) {
-	b := []byte(s)
+	// The iterators only read b.
+	b := bytesconv.Bytes(s)
 	if len(b) < 2 {
 		return PathElement{}, errors.New("key must be 2 characters long:")
 	}
@@ -79,6 +83,7 @@ func DeserializePathElement(s string) (PathElement, error) {
 		if err != nil {
 			return PathElement{}, err
 		}
+		v = SafePathElementValue(v)
 		return PathElement{Value: &v}, nil
 	case peKeySepBytes[0]:
 		iter := readPool.BorrowIterator(b)
@@ -91,7 +96,7 @@ func DeserializePathElement(s string) (PathElement, error) {
 				iter.Error = err
 				return false
 			}
-			fields = append(fields, value.Field{Name: key, Value: v})
+			fields = append(fields, value.Field{Name: key, Value: SafePathElementValue(v)})
 			return true
 		})
 		fields.Sort()
@@ -141,14 +146,18 @@ func serializePathElementToWriter(w io.Writer, pe PathElement) error {
 				stream.WriteMore()
 			}
 			stream.WriteObjectField(field.Name)
-			value.WriteJSONStream(field.Value, stream)
+			if err := writeValueJSON(stream, field.Value); err != nil {
+				return err
+			}
 		}
 		stream.WriteObjectEnd()
 	case pe.Value != nil:
 		if _, err := stream.Write(peValueSepBytes); err != nil {
 			return err
 		}
-		value.WriteJSONStream(*pe.Value, stream)
+		if err := writeValueJSON(stream, *pe.Value); err != nil {
+			return err
+		}
 	case pe.Index != nil:
 		if _, err := stream.Write(peIndexSepBytes); err != nil {
 			return err
@@ -166,3 +175,77 @@ func serializePathElementToWriter(w io.Writer, pe PathElement) error {
 	stream.SetBuffer(b[:0])
 	return err
 }
+
+// writeValueJSON writes the value of a key or set item. Serialized path
+// elements are persisted and compared byte for byte, so rather than relying
+// on the JSON library's reflection-based encoding, every value type is
+// written explicitly here: map keys are sorted, strings are HTML-escaped, and
+// floats use a fixed format.
+func writeValueJSON(stream *jsoniter.Stream, v value.Value) error {
+	switch {
+	case v.IsNull():
+		stream.WriteNil()
+	case v.IsBool():
+		stream.WriteBool(v.AsBool())
+	case v.IsInt():
+		stream.WriteInt64(v.AsInt())
+	case v.IsFloat():
+		b, err := appendFloatJSON(nil, v.AsFloat())
+		if err != nil {
+			return err
+		}
+		stream.WriteRaw(bytesconv.String(b))
+	case v.IsString():
+		stream.WriteStringWithHTMLEscaped(v.AsString())
+	case v.IsList():
+		l := v.AsList()
+		stream.WriteArrayStart()
+		for i := 0; i < l.Length(); i++ {
+			if i > 0 {
+				stream.WriteMore()
+			}
+			if err := writeValueJSON(stream, l.At(i)); err != nil {
+				return err
+			}
+		}
+		stream.WriteArrayEnd()
+	case v.IsMap():
+		m := v.AsMap()
+		keys := make([]string, 0, m.Length())
+		m.Iterate(func(k string, _ value.Value) bool {
+			keys = append(keys, k)
+			return true
+		})
+		sort.Strings(keys)
+		stream.WriteObjectStart()
+		for i, k := range keys {
+			if i > 0 {
+				stream.WriteMore()
+			}
+			stream.WriteStringWithHTMLEscaped(k)
+			stream.WriteRaw(":")
+			val, _ := m.Get(k)
+			if err := writeValueJSON(stream, val); err != nil {
+				return err
+			}
+		}
+		stream.WriteObjectEnd()
+	default:
+		return fmt.Errorf("unable to serialize value: %v", v)
+	}
+	return stream.Error
+}
+
+// appendFloatJSON formats f the way it has always been serialized: the
+// shortest representation that round-trips, in exponent form only for very
+// small or very large magnitudes.
+func appendFloatJSON(b []byte, f float64) ([]byte, error) {
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return nil, fmt.Errorf("unsupported value: %v", f)
+	}
+	format := byte('f')
+	if abs := math.Abs(f); abs != 0 && (abs < 1e-6 || abs >= 1e21) {
+		format = 'e'
+	}
+	return strconv.AppendFloat(b, f, format, -1, 64), nil
+}
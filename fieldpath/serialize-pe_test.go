@@ -16,7 +16,15 @@ limitations under the License.
 
 package fieldpath
 
-import "testing"
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+
+	jsoniter "github.com/json-iterator/go"
+	"sigs.k8s.io/structured-merge-diff/v4/value"
+)
 
 func TestPathElementRoundTrip(t *testing.T) {
 	tests := []string{
@@ -82,3 +90,144 @@ func TestDeserializePathElementError(t *testing.T) {
 		})
 	}
 }
+
+// pathologicalStrings are used as field names, key values and set values to
+// check that escaping is stable and round-trips.
+var pathologicalStrings = []string{
+	``,
+	`"`,
+	`\`,
+	`\"`,
+	`a"b`,
+	`:`,
+	`f:`,
+	`{}`,
+	`.`,
+	"new\nline",
+	"carriage\rreturn",
+	"tab\there",
+	"nul\x00byte",
+	"control\x01\x1f\x7f",
+	`<script>&amp;</script>`,
+	"line\u2028separator\u2029",
+	"h\u00e9llo w\u00f6rld",
+	"\u65e5\u672c\u8a9e",
+	"emoji \U0001F600",
+	"invalid \xff utf8",
+	`\u0041`,
+}
+
+func TestPathElementEscapingRoundTrip(t *testing.T) {
+	for _, str := range pathologicalStrings {
+		str := str
+		v := value.NewValueInterface(str)
+		pes := []PathElement{
+			{FieldName: &str},
+			{Key: KeyByFields("name", str)},
+			{Key: KeyByFields(str, "value")},
+			{Value: &v},
+		}
+		for _, pe := range pes {
+			serialized, err := SerializePathElement(pe)
+			if err != nil {
+				t.Fatalf("failed to serialize %#v: %v", pe, err)
+			}
+			got, err := DeserializePathElement(serialized)
+			if err != nil {
+				t.Fatalf("failed to deserialize %q: %v", serialized, err)
+			}
+			// Invalid UTF-8 is replaced when decoding JSON strings.
+			if strings.Contains(str, "\xff") && pe.FieldName == nil {
+				continue
+			}
+			if !got.Equals(pe) {
+				t.Errorf("expected %q to round-trip, got %v from %q", str, got, serialized)
+			}
+			again, err := SerializePathElement(got)
+			if err != nil {
+				t.Fatalf("failed to serialize %#v: %v", got, err)
+			}
+			if again != serialized {
+				t.Errorf("expected serialization to be stable:\n%q\n%q", serialized, again)
+			}
+		}
+	}
+}
+
+func TestPathElementSerializationGolden(t *testing.T) {
+	tests := []struct {
+		pe       PathElement
+		expected string
+	}{
+		{PathElement{FieldName: strPtr(`a"b`)}, `f:a"b`},
+		{PathElement{FieldName: strPtr("a\nb")}, "f:a\nb"},
+		{PathElement{Key: KeyByFields("name", `a"b`)}, `k:{"name":"a\"b"}`},
+		{PathElement{Key: KeyByFields("name", "a\nb")}, `k:{"name":"a\nb"}`},
+		{PathElement{Key: KeyByFields("name", "<&>")}, `k:{"name":"\u003c\u0026\u003e"}`},
+		{PathElement{Key: KeyByFields("name", "\u2028")}, `k:{"name":"\u2028"}`},
+		{PathElement{Key: KeyByFields("name", "\x01")}, `k:{"name":"\u0001"}`},
+		{PathElement{Key: KeyByFields("name", "h\u00e9")}, "k:{\"name\":\"h\u00e9\"}"},
+		{PathElement{Key: KeyByFields("a\"b", 1)}, `k:{"a\"b":1}`},
+		{PathElement{Key: KeyByFields("b", 1, "a", 2)}, `k:{"a":2,"b":1}`},
+		{PathElement{Key: KeyByFields("port", 1.0)}, `k:{"port":1}`},
+		{PathElement{Key: KeyByFields("port", 1.5)}, `k:{"port":1.5}`},
+		{PathElement{Key: KeyByFields("port", -0.0)}, `k:{"port":0}`},
+		{PathElement{Key: KeyByFields("port", 0.000001)}, `k:{"port":0.000001}`},
+		{PathElement{Key: KeyByFields("port", 0.0000001)}, `k:{"port":1e-07}`},
+		{PathElement{Key: KeyByFields("port", 1e20)}, `k:{"port":100000000000000000000}`},
+		{PathElement{Key: KeyByFields("port", 1e21)}, `k:{"port":1e+21}`},
+		{PathElement{Key: KeyByFields("port", math.MaxInt64)}, `k:{"port":9223372036854775807}`},
+		{PathElement{Key: KeyByFields("flag", true)}, `k:{"flag":true}`},
+		{PathElement{Value: valPtr(map[string]interface{}{"b": 1, "a": "<", "c": []interface{}{1.5, nil}})}, `v:{"a":"\u003c","b":1,"c":[1.5,null]}`},
+	}
+	for _, test := range tests {
+		got, err := SerializePathElement(test.pe)
+		if err != nil {
+			t.Fatalf("failed to serialize %v: %v", test.pe, err)
+		}
+		if got != test.expected {
+			t.Errorf("expected %v to serialize as:\n%s\ngot:\n%s", test.pe, test.expected, got)
+		}
+	}
+}
+
+// TestPathElementSerializationCompatibility checks that values are written
+// the same way they were when they were encoded by the JSON library.
+func TestPathElementSerializationCompatibility(t *testing.T) {
+	values := []interface{}{nil, true, false, 0, -1, 1.0, 1.25, 1e-7, 1e-6, 1e21, 1e20, 123456789.123, -3.5e-300}
+	for _, str := range pathologicalStrings {
+		values = append(values, str, map[string]interface{}{str: str}, []interface{}{str})
+	}
+	for _, v := range values {
+		var expected bytes.Buffer
+		stream := writePool.BorrowStream(&expected)
+		value.WriteJSONStream(value.NewValueInterface(v), stream)
+		stream.Flush()
+		writePool.ReturnStream(stream)
+
+		var got bytes.Buffer
+		stream = jsoniter.NewStream(jsoniter.ConfigCompatibleWithStandardLibrary, &got, 64)
+		if err := writeValueJSON(stream, value.NewValueInterface(v)); err != nil {
+			t.Fatalf("failed to write %#v: %v", v, err)
+		}
+		stream.Flush()
+		if expected.String() != got.String() {
+			t.Errorf("expected %#v to serialize as:\n%s\ngot:\n%s", v, expected.String(), got.String())
+		}
+	}
+}
+
+func TestPathElementSerializationInvalidFloat(t *testing.T) {
+	for _, f := range []float64{math.NaN(), math.Inf(1), math.Inf(-1)} {
+		if _, err := SerializePathElement(PathElement{Key: KeyByFields("port", f)}); err == nil {
+			t.Errorf("expected error serializing %v", f)
+		}
+	}
+}
+
+func strPtr(s string) *string { return &s }
+
+func valPtr(i interface{}) *value.Value {
+	v := value.NewValueInterface(i)
+	return &v
+}
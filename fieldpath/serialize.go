@@ -77,6 +77,7 @@ func (r *reusableBuilder) reset() *bytes.Buffer {
 }
 
 func (s *Set) emitContentsV1(includeSelf bool, stream *jsoniter.Stream, r *reusableBuilder) error {
+	s.compact()
 	mi, ci := 0, 0
 	first := true
 	preWrite := func() {
@@ -106,6 +107,12 @@ func (s *Set) emitContentsV1(includeSelf bool, stream *jsoniter.Stream, r *reusa
 			stream.WriteEmptyObject()
 			mi++
 		} else if c > 0 {
+			// Child sets without members would be read back as
+			// members.
+			if s.Children.members[ci].set.Empty() {
+				ci++
+				continue
+			}
 			preWrite()
 			if err := serializePathElementToWriter(r.reset(), cpe); err != nil {
 				return err
@@ -147,6 +154,10 @@ func (s *Set) emitContentsV1(includeSelf bool, stream *jsoniter.Stream, r *reusa
 
 	for ci < len(s.Children.members) {
 		cpe := s.Children.members[ci].pathElement
+		if s.Children.members[ci].set.Empty() {
+			ci++
+			continue
+		}
 
 		preWrite()
 		if err := serializePathElementToWriter(r.reset(), cpe); err != nil {
@@ -207,7 +218,7 @@ func readIterV1(iter *jsoniter.Iterator) (children *Set, isMember bool) {
 			m := &children.Members.members
 			// Since we expect that most of the time these will have been
 			// serialized in the right order, we just verify that and append.
-			appendOK := len(*m) == 0 || (*m)[len(*m)-1].Less(pe)
+			appendOK := children.Members.index == nil && (len(*m) == 0 || (*m)[len(*m)-1].Less(pe))
 			if appendOK {
 				*m = append(*m, pe)
 			} else {
@@ -221,7 +232,7 @@ func readIterV1(iter *jsoniter.Iterator) (children *Set, isMember bool) {
 			// Since we expect that most of the time these will have been
 			// serialized in the right order, we just verify that and append.
 			m := &children.Children.members
-			appendOK := len(*m) == 0 || (*m)[len(*m)-1].pathElement.Less(pe)
+			appendOK := children.Children.index == nil && (len(*m) == 0 || (*m)[len(*m)-1].pathElement.Less(pe))
 			if appendOK {
 				*m = append(*m, setNode{pe, grandchildren})
 			} else {
@@ -21,6 +21,9 @@ import (
 	"fmt"
 	"strings"
 	"testing"
+	"unicode/utf8"
+
+	"sigs.k8s.io/structured-merge-diff/v4/value"
 )
 
 func TestSerializeV1(t *testing.T) {
@@ -70,6 +73,43 @@ func TestSerializeV1GoldenData(t *testing.T) {
 	}
 }
 
+func TestSerializeV1Escaping(t *testing.T) {
+	x := NewSet()
+	for _, str := range pathologicalStrings {
+		if !utf8.ValidString(str) {
+			// Invalid UTF-8 is replaced when decoding JSON strings.
+			continue
+		}
+		x.Insert(MakePathOrDie(str))
+		x.Insert(MakePathOrDie("keys", KeyByFields("name", str), str))
+		x.Insert(MakePathOrDie("values", value.NewValueInterface(str)))
+	}
+	b, err := x.ToJSON()
+	if err != nil {
+		t.Fatalf("Failed to serialize %#v: %v", x, err)
+	}
+	x2 := NewSet()
+	if err := x2.FromJSON(bytes.NewReader(b)); err != nil {
+		t.Fatalf("Failed to deserialize %s: %v", b, err)
+	}
+	b2, err := x2.ToJSON()
+	if err != nil {
+		t.Fatalf("Failed to serialize %#v: %v", x2, err)
+	}
+	if !bytes.Equal(b, b2) {
+		t.Fatalf("expected serialization to be stable:\n%s\n%s", b, b2)
+	}
+	for i := 0; i < 10; i++ {
+		b3, err := x2.ToJSON()
+		if err != nil {
+			t.Fatalf("Failed to serialize %#v: %v", x2, err)
+		}
+		if !bytes.Equal(b, b3) {
+			t.Fatalf("expected repeated serialization to be stable:\n%s\n%s", b, b3)
+		}
+	}
+}
+
 func TestDropUnknown(t *testing.T) {
 	input := `{"f:aaa":{},"r:aab":{}}`
 	expect := `{"f:aaa":{}}`
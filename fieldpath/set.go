@@ -21,11 +21,15 @@ import (
 	"sigs.k8s.io/structured-merge-diff/v4/value"
 	"sort"
 	"strings"
+	"sync"
 
 	"sigs.k8s.io/structured-merge-diff/v4/schema"
 )
 
 // Set identifies a set of fields.
+//
+// A Set may be read from multiple goroutines at once, but must not be
+// modified (e.g. with Insert or FromJSON) while it is being read.
 type Set struct {
 	// Members lists fields that are part of the set.
 	// TODO: will be serialized as a list of path elements.
@@ -98,6 +102,41 @@ func (s *Set) Difference(s2 *Set) *Set {
 	}
 }
 
+// UnionAll returns a Set containing elements which appear in s or in any of
+// sets. It gives the same result as calling Union repeatedly, but walks
+// all the sets at once instead of building every intermediate result.
+func (s *Set) UnionAll(sets ...*Set) *Set {
+	return unionAll(append([]*Set{s}, sets...))
+}
+
+func unionAll(sets []*Set) *Set {
+	members := make([]*PathElementSet, len(sets))
+	children := make([]*SetNodeMap, len(sets))
+	for i := range sets {
+		members[i] = &sets[i].Members
+		children[i] = &sets[i].Children
+	}
+	return &Set{
+		Members:  *unionAllPathElementSets(members),
+		Children: *unionAllSetNodeMaps(children),
+	}
+}
+
+// DifferenceAll returns a Set containing elements which appear in s but
+// not in any of sets. It gives the same result as calling Difference
+// repeatedly, but walks all the sets at once instead of building every
+// intermediate result.
+func (s *Set) DifferenceAll(sets ...*Set) *Set {
+	members := make([]*PathElementSet, len(sets))
+	for i := range sets {
+		members[i] = &sets[i].Members
+	}
+	return &Set{
+		Members:  *s.Members.differenceAll(members),
+		Children: *s.Children.differenceAll(sets),
+	}
+}
+
 // RecursiveDifference returns a Set containing elements which:
 // * appear in s
 // * do not appear in s2
@@ -119,6 +158,7 @@ func (s *Set) RecursiveDifference(s2 *Set) *Set {
 // included. For example, a set made of "a.b.c" will end-up also owning
 // "a" if it's a named fields but not "a.b" if it's a map.
 func (s *Set) EnsureNamedFieldsAreMembers(sc *schema.Schema, tr schema.TypeRef) *Set {
+	s.compact()
 	members := PathElementSet{
 		members: make(sortedPathElements, 0, s.Members.Size()+len(s.Children.members)),
 	}
@@ -157,6 +197,8 @@ func MakePrefixMatcherOrDie(parts ...interface{}) *SetMatcher {
 //   - value.Value - for scalar list elements
 //   - string - For field names
 //   - int - for array indices
+//
+// The errors are *PathArgumentErrors.
 func PrefixMatcher(parts ...interface{}) (*SetMatcher, error) {
 	current := MatchAnySet() // match all field path suffixes
 	for i := len(parts) - 1; i >= 0; i-- {
@@ -172,7 +214,7 @@ func PrefixMatcher(parts ...interface{}) (*SetMatcher, error) {
 		case *value.FieldList:
 			// a listMap key
 			if len(*t) == 0 {
-				return nil, fmt.Errorf("associative list key type path elements must have at least one key (got zero)")
+				return nil, &PathArgumentError{Index: i, Arg: part, Err: fmt.Errorf("associative list key type path elements must have at least one key (got zero)")}
 			}
 			pattern = PathElementMatcher{PathElement: PathElement{Key: t}}
 		case value.Value:
@@ -185,7 +227,7 @@ func PrefixMatcher(parts ...interface{}) (*SetMatcher, error) {
 			// a plain list index
 			pattern = PathElementMatcher{PathElement: PathElement{Index: &t}}
 		default:
-			return nil, fmt.Errorf("unexpected type %T", t)
+			return nil, &PathArgumentError{Index: i, Arg: part, Err: fmt.Errorf("unexpected type %T", t)}
 		}
 		current = &SetMatcher{
 			members: []*SetMemberMatcher{{
@@ -261,6 +303,27 @@ func (s *SetMatcher) Merge(s2 *SetMatcher) *SetMatcher {
 	return NewSetMatcher(false, merged...) // sort happens here
 }
 
+// Matches returns true if the field path p is matched, i.e. if
+// FilterIncludeMatches keeps it. Like there, the parents of the matched
+// fields match too.
+func (s *SetMatcher) Matches(p Path) bool {
+	if s.wildcard {
+		return true
+	}
+	if len(p) == 0 {
+		return false
+	}
+	for _, m := range s.members {
+		if !m.Path.Wildcard && !m.Path.PathElement.Equals(p[0]) {
+			continue
+		}
+		if len(p) == 1 || (m.Child != nil && m.Child.Matches(p[1:])) {
+			return true
+		}
+	}
+	return false
+}
+
 // SetMemberMatcher defines a matcher that matches the members of a Set.
 // SetMemberMatcher is structured much like the elements of a SetNodeMap, but
 // with wildcard support.
@@ -315,6 +378,7 @@ func (s *Set) FilterIncludeMatches(pattern *SetMatcher) *Set {
 		return s
 	}
 
+	s.Members.compact()
 	members := PathElementSet{}
 	for _, m := range s.Members.members {
 		for _, pm := range pattern.members {
@@ -364,11 +428,69 @@ func (s *Set) Has(p Path) bool {
 	}
 }
 
+// HasPrefix returns true if the field referenced by `p`, or one of its
+// children, is a member of the set.
+func (s *Set) HasPrefix(p Path) bool {
+	for i, pe := range p {
+		if i == len(p)-1 && s.Members.Has(pe) {
+			return true
+		}
+		var ok bool
+		if s, ok = s.Children.Get(pe); !ok {
+			return false
+		}
+	}
+	return !s.Empty()
+}
+
+// HasString is Has for path, parsed by ParsePath, e.g.
+// .spec.containers[name="app"].image.
+func (s *Set) HasString(path string) (bool, error) {
+	p, err := ParsePath(path)
+	if err != nil {
+		return false, err
+	}
+	return s.Has(p), nil
+}
+
+// HasPrefixString is HasPrefix for path, parsed by ParsePath: it is
+// true for .spec.containers[name="app"] if the set has the image above.
+func (s *Set) HasPrefixString(path string) (bool, error) {
+	p, err := ParsePath(path)
+	if err != nil {
+		return false, err
+	}
+	return s.HasPrefix(p), nil
+}
+
 // Equals returns true if s and s2 have exactly the same members.
 func (s *Set) Equals(s2 *Set) bool {
 	return s.Members.Equals(&s2.Members) && s.Children.Equals(&s2.Children)
 }
 
+// Normalize returns the canonical minimal form of s: the children of its
+// members are collapsed into them, and the child sets which have no members
+// (e.g. left by Intersection or RecursiveDifference) are dropped. Sets which
+// only differ by these are equal once normalized.
+//
+// A member then stands for all of its children, like owning an atomic map,
+// or a map added as a whole, does. The sets recorded by an Updater must not
+// be normalized: it finds conflicts path by path, so owning a node and
+// owning its leaves differ there (see Ownership).
+func (s *Set) Normalize() *Set {
+	s.compact()
+	out := &Set{Members: PathElementSet{members: append(sortedPathElements(nil), s.Members.members...)}}
+	for _, n := range s.Children.members {
+		if s.Members.Has(n.pathElement) {
+			continue
+		}
+		if set := n.set.Normalize(); !set.Empty() {
+			out.Children.members = append(out.Children.members, setNode{pathElement: n.pathElement, set: set})
+		}
+	}
+	return out
+}
+
 // String returns the set one element per line.
 func (s *Set) String() string {
 	elements := []string{}
@@ -403,6 +525,7 @@ func (s *Set) WithPrefix(pe PathElement) *Set {
 // Leaves returns a set containing only the leaf paths
 // of a set.
 func (s *Set) Leaves() *Set {
+	s.compact()
 	leaves := PathElementSet{}
 	im := 0
 	ic := 0
@@ -434,6 +557,69 @@ outer:
 	}
 }
 
+// FilterLeaves returns the subset of the leaf paths of s, see Leaves, for
+// which keep returns true, e.g. to find the leaves whose last element is
+// .image. The path passed to keep is reused, so make a copy if you wish to
+// keep it.
+func (s *Set) FilterLeaves(keep func(Path) bool) *Set {
+	out := &Set{}
+	s.Leaves().Iterate(func(p Path) {
+		if keep(p) {
+			out.Insert(p)
+		}
+	})
+	return out
+}
+
+// Ownership is how sets represent the ownership of the nodes of an object,
+// i.e. of its maps, lists and list items, as opposed to its leaves.
+type Ownership int
+
+const (
+	// MixedOwnership leaves sets as they are. Sets built from objects
+	// contain their leaves and list items, but usually not the maps and
+	// lists which contain them, and a node owned as a whole (e.g. an
+	// atomic map, or a map added by an update) may or may not have its
+	// leaves as members too.
+	MixedOwnership Ownership = iota
+	// LeafOwnership only keeps the members without children: a node is
+	// owned through its leaves, and only owned as a whole if none of its
+	// children is. Pruning then removes the leaves that are no longer
+	// applied, and the nodes that they leave empty.
+	LeafOwnership
+	// NodeOwnership makes every parent of a member a member too: owning a
+	// leaf means owning the nodes which contain it, so that pruning keeps
+	// them as long as some of their leaves are owned. Owning a node never
+	// makes changes to its children conflict: conflicts are only raised for
+	// the paths which change, i.e. leaves, and nodes added or removed as a
+	// whole.
+	NodeOwnership
+)
+
+// WithOwnership returns s with the given Ownership.
+func (s *Set) WithOwnership(o Ownership) *Set {
+	switch o {
+	case LeafOwnership:
+		return s.Leaves()
+	case NodeOwnership:
+		out := NewSet()
+		s.Iterate(func(p Path) {
+			for i := 1; i <= len(p); i++ {
+				out.Insert(p[:i])
+			}
+		})
+		return out
+	default:
+		return s
+	}
+}
+
+// compact restores the sorted order of the top level of s.
+func (s *Set) compact() {
+	s.Members.compact()
+	s.Children.compact()
+}
+
 // setNode is a pair of PathElement / Set, for the purpose of expressing
 // nested set membership.
 type setNode struct {
@@ -444,6 +630,15 @@ type setNode struct {
 // SetNodeMap is a map of PathElement to subset.
 type SetNodeMap struct {
 	members sortedSetNode
+
+	// index is only built once the map grows past hashIndexThreshold.
+	index map[string]*Set
+	// mu guards compaction, which readers may trigger concurrently. It is
+	// allocated along with the index.
+	mu *sync.Mutex
+	// pending is the number of unsorted nodes at the end of members,
+	// inserted through the index since the map was last compacted.
+	pending int
 }
 
 type sortedSetNode []setNode
@@ -456,6 +651,20 @@ func (s sortedSetNode) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
 
 // Descend adds pe to the set if necessary, returning the associated subset.
 func (s *SetNodeMap) Descend(pe PathElement) *Set {
+	if s.index == nil && len(s.members) >= hashIndexThreshold {
+		s.buildIndex()
+	}
+	if s.index != nil {
+		key := pathElementKey(pe)
+		if set, ok := s.index[key]; ok {
+			return set
+		}
+		set := &Set{}
+		s.index[key] = set
+		s.members = append(s.members, setNode{pathElement: pe, set: set})
+		s.pending++
+		return set
+	}
 	loc := sort.Search(len(s.members), func(i int) bool {
 		return !s.members[i].pathElement.Less(pe)
 	})
@@ -474,6 +683,7 @@ func (s *SetNodeMap) Descend(pe PathElement) *Set {
 
 // Size returns the sum of the number of members of all subsets.
 func (s *SetNodeMap) Size() int {
+	s.compact()
 	count := 0
 	for _, v := range s.members {
 		count += v.set.Size()
@@ -483,6 +693,7 @@ func (s *SetNodeMap) Size() int {
 
 // Empty returns false if there's at least one member in some child set.
 func (s *SetNodeMap) Empty() bool {
+	s.compact()
 	for _, n := range s.members {
 		if !n.set.Empty() {
 			return false
@@ -493,6 +704,10 @@ func (s *SetNodeMap) Empty() bool {
 
 // Get returns (the associated set, true) or (nil, false) if there is none.
 func (s *SetNodeMap) Get(pe PathElement) (*Set, bool) {
+	if s.index != nil {
+		set, ok := s.index[pathElementKey(pe)]
+		return set, ok
+	}
 	loc := sort.Search(len(s.members), func(i int) bool {
 		return !s.members[i].pathElement.Less(pe)
 	})
@@ -506,24 +721,61 @@ func (s *SetNodeMap) Get(pe PathElement) (*Set, bool) {
 }
 
 // Equals returns true if s and s2 have the same structure (same nested
-// child sets).
+// child sets). Child sets without members are ignored, like in Normalize.
 func (s *SetNodeMap) Equals(s2 *SetNodeMap) bool {
-	if len(s.members) != len(s2.members) {
-		return false
+	s.compact()
+	s2.compact()
+	i, j := 0, 0
+	for i < len(s.members) && j < len(s2.members) {
+		switch c := s.members[i].pathElement.Compare(s2.members[j].pathElement); {
+		case c < 0:
+			if !s.members[i].set.Empty() {
+				return false
+			}
+			i++
+		case c > 0:
+			if !s2.members[j].set.Empty() {
+				return false
+			}
+			j++
+		default:
+			if !s.members[i].set.Equals(s2.members[j].set) {
+				return false
+			}
+			i++
+			j++
+		}
 	}
-	for i := range s.members {
-		if !s.members[i].pathElement.Equals(s2.members[i].pathElement) {
+	for ; i < len(s.members); i++ {
+		if !s.members[i].set.Empty() {
 			return false
 		}
-		if !s.members[i].set.Equals(s2.members[i].set) {
+	}
+	for ; j < len(s2.members); j++ {
+		if !s2.members[j].set.Empty() {
 			return false
 		}
 	}
 	return true
 }
 
+// Normalize returns the SetNodeMap of the normalized child sets, without the
+// ones which have no members. See Set.Normalize.
+func (s *SetNodeMap) Normalize() *SetNodeMap {
+	s.compact()
+	out := &SetNodeMap{}
+	for _, n := range s.members {
+		if set := n.set.Normalize(); !set.Empty() {
+			out.members = append(out.members, setNode{pathElement: n.pathElement, set: set})
+		}
+	}
+	return out
+}
+
 // Union returns a SetNodeMap with members that appear in either s or s2.
 func (s *SetNodeMap) Union(s2 *SetNodeMap) *SetNodeMap {
+	s.compact()
+	s2.compact()
 	out := &SetNodeMap{}
 
 	i, j := 0, 0
@@ -551,8 +803,79 @@ func (s *SetNodeMap) Union(s2 *SetNodeMap) *SetNodeMap {
 	return out
 }
 
+// unionAllSetNodeMaps returns a SetNodeMap with members that appear in any
+// of maps.
+func unionAllSetNodeMaps(maps []*SetNodeMap) *SetNodeMap {
+	out := &SetNodeMap{}
+	pos := make([]int, len(maps))
+	for _, m := range maps {
+		m.compact()
+	}
+	var matches []*Set
+	for {
+		var min *PathElement
+		for i, m := range maps {
+			if pos[i] < len(m.members) && (min == nil || m.members[pos[i]].pathElement.Less(*min)) {
+				min = &m.members[pos[i]].pathElement
+			}
+		}
+		if min == nil {
+			return out
+		}
+		pe := *min
+		matches = matches[:0]
+		for i, m := range maps {
+			if pos[i] < len(m.members) && m.members[pos[i]].pathElement.Equals(pe) {
+				matches = append(matches, m.members[pos[i]].set)
+				pos[i]++
+			}
+		}
+		if len(matches) == 1 {
+			out.members = append(out.members, setNode{pathElement: pe, set: matches[0]})
+		} else {
+			out.members = append(out.members, setNode{pathElement: pe, set: unionAll(matches)})
+		}
+	}
+}
+
+// differenceAll returns a SetNodeMap with members that appear in s but not
+// in any of sets.
+func (s *SetNodeMap) differenceAll(sets []*Set) *SetNodeMap {
+	s.compact()
+	out := &SetNodeMap{}
+	pos := make([]int, len(sets))
+	for _, s2 := range sets {
+		s2.Children.compact()
+	}
+	var matches []*Set
+	for _, n := range s.members {
+		matches = matches[:0]
+		for i, s2 := range sets {
+			others := s2.Children.members
+			for pos[i] < len(others) && others[pos[i]].pathElement.Less(n.pathElement) {
+				pos[i]++
+			}
+			if pos[i] < len(others) && others[pos[i]].pathElement.Equals(n.pathElement) {
+				matches = append(matches, others[pos[i]].set)
+			}
+		}
+		if len(matches) == 0 {
+			out.members = append(out.members, n)
+			continue
+		}
+		diff := n.set.DifferenceAll(matches...)
+		// We aren't permitted to add nodes with no elements.
+		if !diff.Empty() {
+			out.members = append(out.members, setNode{pathElement: n.pathElement, set: diff})
+		}
+	}
+	return out
+}
+
 // Intersection returns a SetNodeMap with members that appear in both s and s2.
 func (s *SetNodeMap) Intersection(s2 *SetNodeMap) *SetNodeMap {
+	s.compact()
+	s2.compact()
 	out := &SetNodeMap{}
 
 	i, j := 0, 0
@@ -575,6 +898,8 @@ func (s *SetNodeMap) Intersection(s2 *SetNodeMap) *SetNodeMap {
 
 // Difference returns a SetNodeMap with members that appear in s but not in s2.
 func (s *SetNodeMap) Difference(s2 *Set) *SetNodeMap {
+	s.compact()
+	s2.Children.compact()
 	out := &SetNodeMap{}
 
 	i, j := 0, 0
@@ -611,6 +936,8 @@ func (s *SetNodeMap) Difference(s2 *Set) *SetNodeMap {
 // For example, with s containing `a.b.c` and s2 containing `a.b`,
 // a RecursiveDifference will result in `a`, as the entire node `a.b` gets removed.
 func (s *SetNodeMap) RecursiveDifference(s2 *Set) *SetNodeMap {
+	s.compact()
+	s2.Children.compact()
 	out := &SetNodeMap{}
 
 	i, j := 0, 0
@@ -647,6 +974,7 @@ func (s *SetNodeMap) RecursiveDifference(s2 *Set) *SetNodeMap {
 
 // EnsureNamedFieldsAreMembers returns a set that contains all the named fields along with the leaves.
 func (s *SetNodeMap) EnsureNamedFieldsAreMembers(sc *schema.Schema, tr schema.TypeRef) *SetNodeMap {
+	s.compact()
 	out := make(sortedSetNode, 0, s.Size())
 	atom, _ := sc.Resolve(tr)
 	for _, member := range s.members {
@@ -676,6 +1004,7 @@ func (s *SetNodeMap) FilterIncludeMatches(pattern *SetMatcher) *SetNodeMap {
 		return s
 	}
 
+	s.compact()
 	var out sortedSetNode
 	for _, member := range s.members {
 		for _, c := range pattern.members {
@@ -699,12 +1028,14 @@ func (s *SetNodeMap) FilterIncludeMatches(pattern *SetMatcher) *SetNodeMap {
 
 // Iterate calls f for each PathElement in the set.
 func (s *SetNodeMap) Iterate(f func(PathElement)) {
+	s.compact()
 	for _, n := range s.members {
 		f(n.pathElement)
 	}
 }
 
 func (s *SetNodeMap) iteratePrefix(prefix Path, f func(Path)) {
+	s.compact()
 	for _, n := range s.members {
 		pe := n.pathElement
 		n.set.iteratePrefix(append(prefix, pe), f)
@@ -714,6 +1045,7 @@ func (s *SetNodeMap) iteratePrefix(prefix Path, f func(Path)) {
 // Leaves returns a SetNodeMap containing
 // only setNodes with leaf PathElements.
 func (s *SetNodeMap) Leaves() *SetNodeMap {
+	s.compact()
 	out := &SetNodeMap{}
 	out.members = make(sortedSetNode, len(s.members))
 	for i, n := range s.members {
@@ -758,6 +1090,20 @@ func (t excludeFilter) Filter(set *Set) *Set {
 	return set.RecursiveDifference(t.excludeSet)
 }
 
+// NewIncludeSetFilter returns a filter that only includes the field paths
+// in the include set and all of their children.
+func NewIncludeSetFilter(include *Set) Filter {
+	return includeFilter{include}
+}
+
+type includeFilter struct {
+	includeSet *Set
+}
+
+func (t includeFilter) Filter(set *Set) *Set {
+	return set.Difference(set.RecursiveDifference(t.includeSet))
+}
+
 // NewIncludeMatcherFilter returns a filter that only includes field paths that match.
 // If no matchers are provided, the filter includes all field paths.
 // PrefixMatcher and MakePrefixMatcherOrDie can help create basic matcher.
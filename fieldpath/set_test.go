@@ -21,6 +21,7 @@ import (
 	"fmt"
 	"math/rand"
 	"sigs.k8s.io/structured-merge-diff/v4/value"
+	"sync"
 	"testing"
 
 	"sigs.k8s.io/structured-merge-diff/v4/schema"
@@ -117,6 +118,12 @@ func BenchmarkFieldSet(b *testing.B) {
 			}
 		})
 
+		b.Run(fmt.Sprintf("union-all-%v", here.size), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				randOperand().UnionAll(randOperand(), randOperand(), randOperand())
+			}
+		})
 		b.Run(fmt.Sprintf("union-%v", here.size), func(b *testing.B) {
 			b.ReportAllocs()
 			for i := 0; i < b.N; i++ {
@@ -209,6 +216,147 @@ func TestSetInsertHas(t *testing.T) {
 	}
 }
 
+func TestSetInsertLarge(t *testing.T) {
+	// Insert enough elements to switch to the hash index, in random
+	// order, and compare against the same set built by unions.
+	var paths []Path
+	for i := 0; i < 3*hashIndexThreshold; i++ {
+		paths = append(paths,
+			MakePathOrDie("subsets", KeyByFields("ip", fmt.Sprintf("10.0.%d.%d", i/256, i%256)), "hostname"),
+			MakePathOrDie("values", _V(i)),
+			MakePathOrDie(fmt.Sprintf("field-%d", i)),
+		)
+	}
+	rand.Shuffle(len(paths), func(i, j int) { paths[i], paths[j] = paths[j], paths[i] })
+
+	got := NewSet()
+	expected := NewSet()
+	for _, p := range paths {
+		got.Insert(p)
+		got.Insert(p)
+		expected = expected.Union(NewSet(p))
+	}
+	// Floats that are equal to an int already in the set are duplicates.
+	got.Insert(MakePathOrDie("values", _V(3.0)))
+
+	for _, p := range paths {
+		if !got.Has(p) {
+			t.Errorf("expected set to have %v", p)
+		}
+	}
+	if got.Has(MakePathOrDie("values", _V(3.5))) {
+		t.Errorf("unexpected member %v", MakePathOrDie("values", _V(3.5)))
+	}
+	if e, a := expected.Size(), got.Size(); e != a {
+		t.Errorf("expected size %v, got %v", e, a)
+	}
+	if !got.Equals(expected) {
+		t.Errorf("expected sets to be equal")
+	}
+	if e, a := expected.String(), got.String(); e != a {
+		t.Errorf("expected iteration order to be the same")
+	}
+	eJSON, err := expected.ToJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	aJSON, err := got.ToJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(eJSON, aJSON) {
+		t.Errorf("expected serialization to be the same")
+	}
+
+	// Inserting after reading must keep the order.
+	got.Insert(MakePathOrDie("field-0000"))
+	expected.Insert(MakePathOrDie("field-0000"))
+	if !got.Equals(expected) {
+		t.Errorf("expected sets to be equal after inserting again")
+	}
+}
+
+// TestSetConcurrentReads reads a set that still has elements pending
+// compaction from several goroutines. It's most useful when run with -race.
+func TestSetConcurrentReads(t *testing.T) {
+	build := func() *Set {
+		s := NewSet()
+		for i := 2*hashIndexThreshold - 1; i >= 0; i-- {
+			s.Insert(MakePathOrDie("values", _V(i)))
+			s.Insert(MakePathOrDie(fmt.Sprintf("field-%d", i), "a"))
+		}
+		return s
+	}
+	expected := build()
+	expected.compact()
+	expectedJSON, err := expected.ToJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	shared := build()
+	var wg sync.WaitGroup
+	errs := make(chan error, 64)
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			// Start with different operations so that each of them
+			// gets a chance to be the one compacting the set.
+			switch i % 4 {
+			case 0:
+				if got := shared.Size(); got != 4*hashIndexThreshold {
+					errs <- fmt.Errorf("expected size %v, got %v", 4*hashIndexThreshold, got)
+				}
+			case 1:
+				if !shared.Equals(expected) {
+					errs <- fmt.Errorf("expected set to equal %v", expected)
+				}
+			case 2:
+				count := 0
+				shared.Iterate(func(Path) { count++ })
+				if count != 4*hashIndexThreshold {
+					errs <- fmt.Errorf("expected to iterate over %v paths, got %v", 4*hashIndexThreshold, count)
+				}
+			case 3:
+				b, err := shared.ToJSON()
+				if err != nil {
+					errs <- err
+				} else if !bytes.Equal(b, expectedJSON) {
+					errs <- fmt.Errorf("unexpected serialization:\n%s", b)
+				}
+			}
+			if !shared.Has(MakePathOrDie("values", _V(i))) {
+				errs <- fmt.Errorf("expected set to have value %v", i)
+			}
+			if !shared.Union(expected).Equals(expected) {
+				errs <- fmt.Errorf("expected union with itself to be a no-op")
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+func BenchmarkSetInsertLarge(b *testing.B) {
+	for _, size := range []int{1000, 10000, 50000} {
+		paths := make([]Path, size)
+		for i := range paths {
+			paths[i] = MakePathOrDie("subsets", KeyByFields("ip", fmt.Sprintf("10.%d.%d.%d", i/65536, (i/256)%256, i%256)), "ip")
+		}
+		rand.Shuffle(len(paths), func(i, j int) { paths[i], paths[j] = paths[j], paths[i] })
+		b.Run(fmt.Sprintf("insert-%v", size), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				NewSet(paths...)
+			}
+		})
+	}
+}
+
 func TestSetString(t *testing.T) {
 	p := MakePathOrDie("foo", KeyByFields("name", "first"))
 	s1 := NewSet(p)
@@ -368,6 +516,36 @@ func TestSetUnion(t *testing.T) {
 	}
 }
 
+func TestSetUnionAllDifferenceAll(t *testing.T) {
+	makeSet := func(size int) *Set {
+		s := NewSet()
+		for i := 0; i < size; i++ {
+			s.Insert(randomPathMaker.makePath(1, 4))
+		}
+		return s
+	}
+	for i := 0; i < 50; i++ {
+		s := makeSet(30)
+		var others []*Set
+		for j := 0; j < i%6; j++ {
+			others = append(others, makeSet(20))
+		}
+
+		union := s
+		difference := s
+		for _, o := range others {
+			union = union.Union(o)
+			difference = difference.Difference(o)
+		}
+		if got := s.UnionAll(others...); !got.Equals(union) {
+			t.Errorf("expected UnionAll to be:\n%v\n\ngot:\n%v", union, got)
+		}
+		if got := s.DifferenceAll(others...); !got.Equals(difference) {
+			t.Errorf("expected DifferenceAll to be:\n%v\n\ngot:\n%v", difference, got)
+		}
+	}
+}
+
 func TestSetIntersectionDifference(t *testing.T) {
 	// Even though this is not a table driven test, since the thing under
 	// test is recursive, we should be able to craft a single input that is
@@ -563,6 +741,101 @@ func TestSetLeaves(t *testing.T) {
 
 }
 
+func TestSetWithOwnership(t *testing.T) {
+	input := NewSet(
+		_P("atomic"),
+		_P("root", KeyByFields("name", "a")),
+		_P("root", KeyByFields("name", "a"), "name"),
+		_P("root", KeyByFields("name", "a"), "value", "b"),
+		_P("x"),
+		_P("x", "y"),
+	)
+	table := []struct {
+		ownership Ownership
+		expected  *Set
+	}{
+		{
+			ownership: MixedOwnership,
+			expected:  input,
+		}, {
+			ownership: LeafOwnership,
+			expected: NewSet(
+				_P("atomic"),
+				_P("root", KeyByFields("name", "a"), "name"),
+				_P("root", KeyByFields("name", "a"), "value", "b"),
+				_P("x", "y"),
+			),
+		}, {
+			ownership: NodeOwnership,
+			expected: NewSet(
+				_P("atomic"),
+				_P("root"),
+				_P("root", KeyByFields("name", "a")),
+				_P("root", KeyByFields("name", "a"), "name"),
+				_P("root", KeyByFields("name", "a"), "value"),
+				_P("root", KeyByFields("name", "a"), "value", "b"),
+				_P("x"),
+				_P("x", "y"),
+			),
+		},
+	}
+
+	for _, tt := range table {
+		if got := input.WithOwnership(tt.ownership); !tt.expected.Equals(got) {
+			t.Errorf("%v: expected %v, got %v", tt.ownership, tt.expected, got)
+		}
+	}
+}
+
+func TestSetNormalize(t *testing.T) {
+	// Sets with empty child sets, which are not part of their canonical
+	// form.
+	withEmptyChildren := func() *Set {
+		s := NewSet(_P("a"), _P("b", "c"))
+		s.Children.Descend(_P("a")[0])
+		s.Children.Descend(_P("d")[0]).Children.Descend(_P("e")[0])
+		return s
+	}
+	expected := NewSet(_P("a"), _P("b", "c"))
+
+	s := withEmptyChildren()
+	if !s.Equals(expected) || !expected.Equals(s) {
+		t.Errorf("expected %v to equal %v", s, expected)
+	}
+	normalized := s.Normalize()
+	if len(normalized.Children.members) != 1 {
+		t.Errorf("expected only the children of b, got %v", normalized.Children.members)
+	}
+	if !normalized.Equals(expected) {
+		t.Errorf("expected %v, got %v", expected, normalized)
+	}
+	if s.Equals(NewSet(_P("a"))) {
+		t.Errorf("expected %v to differ from .a", s)
+	}
+
+	// The children of members are collapsed into them.
+	redundant := NewSet(_P("a"), _P("a", "b"), _P("a", "c", "d"), _P("e", "f"), _P("e", "g", "h"))
+	collapsed := NewSet(_P("a"), _P("e", "f"), _P("e", "g", "h"))
+	if got := redundant.Normalize(); !got.Equals(collapsed) {
+		t.Errorf("expected %v, got %v", collapsed, got)
+	}
+	if !redundant.Has(_P("a", "b")) {
+		t.Errorf("expected Normalize not to modify %v", redundant)
+	}
+
+	got, err := withEmptyChildren().ToJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := expected.ToJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(want) {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+}
+
 func TestSetDifference(t *testing.T) {
 	table := []struct {
 		name                      string
@@ -999,6 +1272,25 @@ func TestFilterByPattern(t *testing.T) {
 				MakePathOrDie("spec", "list", 2, "f1"),
 			),
 		},
+		{
+			name: "include set",
+			input: NewSet(
+				MakePathOrDie("spec"),
+				MakePathOrDie("spec", "template"),
+				MakePathOrDie("spec", "template", "image"),
+				MakePathOrDie("spec", "replicas"),
+				MakePathOrDie("status"),
+			),
+			filter: NewIncludeSetFilter(NewSet(
+				MakePathOrDie("spec", "template"),
+				MakePathOrDie("status"),
+			)),
+			expect: NewSet(
+				MakePathOrDie("spec", "template"),
+				MakePathOrDie("spec", "template", "image"),
+				MakePathOrDie("status"),
+			),
+		},
 	}
 
 	for _, tc := range testCases {
@@ -1010,3 +1302,91 @@ func TestFilterByPattern(t *testing.T) {
 		})
 	}
 }
+
+func TestSetMatcherMatches(t *testing.T) {
+	matcher := MakePrefixMatcherOrDie("spec", "list", MatchAnyPathElement(), "f1").Merge(
+		MakePrefixMatcherOrDie("status"))
+	table := []struct {
+		path   Path
+		expect bool
+	}{
+		{MakePathOrDie(), false},
+		{MakePathOrDie("spec"), true},
+		{MakePathOrDie("spec", "list", 0), true},
+		{MakePathOrDie("spec", "list", 0, "f1"), true},
+		{MakePathOrDie("spec", "list", KeyByFields("name", "a"), "f1", "x"), true},
+		{MakePathOrDie("spec", "list", 0, "f2"), false},
+		{MakePathOrDie("spec", "other"), false},
+		{MakePathOrDie("status"), true},
+		{MakePathOrDie("status", "ready"), true},
+	}
+	for _, tt := range table {
+		if got := matcher.Matches(tt.path); got != tt.expect {
+			t.Errorf("Matches(%v): expected %v, got %v", tt.path, tt.expect, got)
+		}
+	}
+}
+
+func TestSetHasString(t *testing.T) {
+	s := NewSet(
+		MakePathOrDie("spec", "containers", KeyByFields("name", "app"), "image"),
+		MakePathOrDie("metadata", "finalizers", _V("a")),
+	)
+	table := []struct {
+		path           string
+		has, hasPrefix bool
+	}{
+		{`.spec.containers[name="app"].image`, true, true},
+		{`.spec.containers[name="app"]`, false, true},
+		{`.spec`, false, true},
+		{``, false, true},
+		{`.spec.containers[name="other"]`, false, false},
+		{`.spec.containers[name="app"].image.tag`, false, false},
+		{`.metadata.finalizers[="a"]`, true, true},
+		{`.metadata.labels`, false, false},
+	}
+	for _, tt := range table {
+		tt := tt
+		t.Run(tt.path, func(t *testing.T) {
+			if has, err := s.HasString(tt.path); err != nil || has != tt.has {
+				t.Errorf("expected HasString to be %v, got %v, %v", tt.has, has, err)
+			}
+			if has, err := s.HasPrefixString(tt.path); err != nil || has != tt.hasPrefix {
+				t.Errorf("expected HasPrefixString to be %v, got %v, %v", tt.hasPrefix, has, err)
+			}
+		})
+	}
+	if _, err := s.HasString("spec"); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+func TestSetFilterLeaves(t *testing.T) {
+	s := NewSet(
+		_P("spec", "containers", KeyByFields("name", "app")),
+		_P("spec", "containers", KeyByFields("name", "app"), "image"),
+		_P("spec", "containers", KeyByFields("name", "sidecar"), "image"),
+		_P("spec", "containers", KeyByFields("name", "sidecar"), "args"),
+		_P("spec", "image"),
+		_P("image"),
+	)
+	got := s.FilterLeaves(func(p Path) bool {
+		last := p[len(p)-1]
+		return last.FieldName != nil && *last.FieldName == "image" && len(p) > 1
+	})
+	expected := NewSet(
+		_P("spec", "containers", KeyByFields("name", "app"), "image"),
+		_P("spec", "containers", KeyByFields("name", "sidecar"), "image"),
+		_P("spec", "image"),
+	)
+	if !got.Equals(expected) {
+		t.Errorf("expected:\n%v\ngot:\n%v", expected, got)
+	}
+	// Only leaves are passed to the predicate.
+	s.FilterLeaves(func(p Path) bool {
+		if len(p) == 3 {
+			t.Errorf("unexpected non-leaf %v", p)
+		}
+		return true
+	})
+}
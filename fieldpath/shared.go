@@ -0,0 +1,74 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fieldpath
+
+import "sort"
+
+// SharedOwnership describes the fields of an object that several managers
+// own, e.g. because they apply the same values, or because they fight over
+// the fields. See ManagedFields.SharedOwnership.
+type SharedOwnership struct {
+	// Fields are the fields owned by more than one manager.
+	Fields *Set
+	// Pairs are the pairs of managers which own fields in common, sorted
+	// by manager names.
+	Pairs []CoOwners
+}
+
+// CoOwners are two managers and the fields they both own.
+type CoOwners struct {
+	// Managers are the names of the two managers, sorted.
+	Managers [2]string
+	// Fields are the fields owned by both managers.
+	Fields *Set
+}
+
+// SharedOwnership returns the fields owned by several managers, grouped by
+// pairs of managers. Sets are compared whatever their versions, so managers
+// should be converted to the same version first, as the Updater does.
+func (lhs ManagedFields) SharedOwnership() SharedOwnership {
+	names := make([]string, 0, len(lhs))
+	for name := range lhs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	shared := SharedOwnership{Fields: NewSet()}
+	for i, a := range names {
+		for _, b := range names[i+1:] {
+			both := lhs[a].Set().Intersection(lhs[b].Set())
+			if both.Empty() {
+				continue
+			}
+			shared.Fields = shared.Fields.Union(both)
+			shared.Pairs = append(shared.Pairs, CoOwners{Managers: [2]string{a, b}, Fields: both})
+		}
+	}
+	return shared
+}
+
+// Owners returns the sorted names of the managers owning p.
+func (lhs ManagedFields) Owners(p Path) []string {
+	var owners []string
+	for name, set := range lhs {
+		if set.Set().Has(p) {
+			owners = append(owners, name)
+		}
+	}
+	sort.Strings(owners)
+	return owners
+}
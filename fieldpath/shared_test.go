@@ -0,0 +1,77 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fieldpath_test
+
+import (
+	"reflect"
+	"testing"
+
+	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
+)
+
+func TestSharedOwnership(t *testing.T) {
+	managers := fieldpath.ManagedFields{
+		"kubectl": fieldpath.NewVersionedSet(
+			_NS(_P("spec", "replicas"), _P("spec", "template"), _P("metadata", "labels", "app")),
+			"v1",
+			true,
+		),
+		"autoscaler": fieldpath.NewVersionedSet(
+			_NS(_P("spec", "replicas")),
+			"v1",
+			false,
+		),
+		"labeler": fieldpath.NewVersionedSet(
+			_NS(_P("metadata", "labels", "app"), _P("spec", "replicas")),
+			"v1",
+			false,
+		),
+		"controller": fieldpath.NewVersionedSet(
+			_NS(_P("status", "replicas")),
+			"v1",
+			false,
+		),
+	}
+
+	shared := managers.SharedOwnership()
+	if expected := _NS(_P("spec", "replicas"), _P("metadata", "labels", "app")); !shared.Fields.Equals(expected) {
+		t.Errorf("expected shared fields:\n%v\ngot:\n%v", expected, shared.Fields)
+	}
+	expected := []struct {
+		managers [2]string
+		fields   *fieldpath.Set
+	}{
+		{[2]string{"autoscaler", "kubectl"}, _NS(_P("spec", "replicas"))},
+		{[2]string{"autoscaler", "labeler"}, _NS(_P("spec", "replicas"))},
+		{[2]string{"kubectl", "labeler"}, _NS(_P("spec", "replicas"), _P("metadata", "labels", "app"))},
+	}
+	if len(shared.Pairs) != len(expected) {
+		t.Fatalf("expected %v pairs, got %v", len(expected), shared.Pairs)
+	}
+	for i, pair := range shared.Pairs {
+		if pair.Managers != expected[i].managers || !pair.Fields.Equals(expected[i].fields) {
+			t.Errorf("expected pair %v to be %v %v, got %v %v", i, expected[i].managers, expected[i].fields, pair.Managers, pair.Fields)
+		}
+	}
+
+	if owners := managers.Owners(_P("spec", "replicas")); !reflect.DeepEqual(owners, []string{"autoscaler", "kubectl", "labeler"}) {
+		t.Errorf("unexpected owners of .spec.replicas: %v", owners)
+	}
+	if owners := managers.Owners(_P("spec")); owners != nil {
+		t.Errorf("expected no owners of .spec, got %v", owners)
+	}
+}
@@ -0,0 +1,102 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fieldpath
+
+import (
+	"unsafe"
+
+	"sigs.k8s.io/structured-merge-diff/v4/value"
+)
+
+// SetStats describes the size of a Set, see Set.Stats.
+type SetStats struct {
+	// Members is the number of paths of the set, like Size.
+	Members int
+	// Nodes is the number of Sets of the tree, including the set itself.
+	Nodes int
+	// Depth is the length of the longest path of the set.
+	Depth int
+	// Fields, Keys, Values and Indexes count the members of the set by the
+	// kind of their last path element.
+	Fields  int
+	Keys    int
+	Values  int
+	Indexes int
+	// EncodedBytes is the size of the set serialized with ToJSON, i.e. its
+	// size in managed fields.
+	EncodedBytes int
+	// EstimatedBytes is a rough estimate of the memory used by the set, not
+	// counting the indexes of large sets.
+	EstimatedBytes int
+}
+
+// Stats returns the size of s, so that callers can enforce quotas on
+// managed fields or model their overhead.
+func (s *Set) Stats() SetStats {
+	var stats SetStats
+	stats.add(s, 0)
+	if b, err := s.ToJSON(); err == nil {
+		stats.EncodedBytes = len(b)
+	}
+	return stats
+}
+
+func (st *SetStats) add(s *Set, depth int) {
+	st.Nodes++
+	st.EstimatedBytes += int(unsafe.Sizeof(*s))
+	for _, pe := range s.Members.members {
+		st.Members++
+		if depth+1 > st.Depth {
+			st.Depth = depth + 1
+		}
+		switch {
+		case pe.FieldName != nil:
+			st.Fields++
+		case pe.Key != nil:
+			st.Keys++
+		case pe.Value != nil:
+			st.Values++
+		case pe.Index != nil:
+			st.Indexes++
+		}
+		st.EstimatedBytes += int(unsafe.Sizeof(pe)) + pathElementBytes(pe)
+	}
+	for _, n := range s.Children.members {
+		st.EstimatedBytes += int(unsafe.Sizeof(n)) + pathElementBytes(n.pathElement)
+		st.add(n.set, depth+1)
+	}
+}
+
+// pathElementBytes estimates the memory referenced by pe.
+func pathElementBytes(pe PathElement) int {
+	const stringHeaderSize, interfaceSize, intSize = 16, 16, 8
+	switch {
+	case pe.FieldName != nil:
+		return stringHeaderSize + len(*pe.FieldName)
+	case pe.Key != nil:
+		n := int(unsafe.Sizeof(*pe.Key))
+		for _, f := range *pe.Key {
+			n += int(unsafe.Sizeof(f)) + len(f.Name) + value.EstimateSize(f.Value)
+		}
+		return n
+	case pe.Value != nil:
+		return interfaceSize + value.EstimateSize(*pe.Value)
+	case pe.Index != nil:
+		return intSize
+	}
+	return 0
+}
@@ -0,0 +1,90 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fieldpath
+
+import (
+	"testing"
+
+	"sigs.k8s.io/structured-merge-diff/v4/value"
+)
+
+func TestSetStats(t *testing.T) {
+	tests := []struct {
+		name  string
+		set   *Set
+		stats SetStats
+	}{{
+		name:  "empty",
+		set:   NewSet(),
+		stats: SetStats{Nodes: 1, EncodedBytes: 2},
+	}, {
+		name: "every kind",
+		set: NewSet(
+			MakePathOrDie("spec"),
+			MakePathOrDie("spec", "replicas"),
+			MakePathOrDie("spec", "containers", KeyByFields("name", "c"), "image"),
+			MakePathOrDie("spec", "finalizers", value.NewValueInterface("f")),
+			MakePathOrDie("spec", "args", 0),
+		),
+		stats: SetStats{
+			Members: 5,
+			Nodes:   6,
+			Depth:   4,
+			Fields:  3,
+			Values:  1,
+			Indexes: 1,
+		},
+	}, {
+		name: "keys",
+		set: NewSet(
+			MakePathOrDie("list", KeyByFields("a", 1)),
+			MakePathOrDie("list", KeyByFields("a", 2)),
+		),
+		stats: SetStats{
+			Members: 2,
+			Nodes:   2,
+			Depth:   2,
+			Keys:    2,
+		},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := test.set.Stats()
+			if got.Members != test.set.Size() {
+				t.Errorf("expected %v members, like Size, got %v", test.set.Size(), got.Members)
+			}
+			if got.EstimatedBytes <= 0 {
+				t.Errorf("expected a positive memory estimate, got %v", got.EstimatedBytes)
+			}
+			b, err := test.set.ToJSON()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.EncodedBytes != len(b) {
+				t.Errorf("expected %v encoded bytes, got %v", len(b), got.EncodedBytes)
+			}
+			got.EstimatedBytes = 0
+			if test.stats.EncodedBytes == 0 {
+				got.EncodedBytes = 0
+			}
+			if got != test.stats {
+				t.Errorf("expected stats %+v, got %+v", test.stats, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,183 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fieldpath
+
+import (
+	"math"
+)
+
+// summaryFalsePositiveRate is the rate of false positives targeted by the
+// Bloom filters of SummarizedSets.
+const summaryFalsePositiveRate = 0.01
+
+// SummarizedSet is a Set along with a Bloom filter of its paths, which tells
+// whether the set may have a path, or touch it, i.e. have it or one of its
+// children, in constant time and without descending the set. The filter
+// has no false negatives, and about 1% of false positives: paths which
+// aren't in the set but which the filter can't tell apart. It is meant for
+// hot paths which mostly check paths which aren't in the set, e.g. whether
+// a manager owns fields of a path, before checking the set itself.
+//
+// Insert updates the set and the filter, which grows when it holds too
+// many paths for its size. The set must not be modified otherwise, or the
+// filter would miss its new paths. A SummarizedSet is safe for concurrent
+// reads, but not for concurrent reads and inserts.
+type SummarizedSet struct {
+	set *Set
+	// bits of the filter, and number of bits set per path.
+	bits   []uint64
+	hashes uint32
+	// entries is the number of entries added to the filter since it was
+	// built, a path and each of its prefixes, and capacity the number it
+	// is sized for.
+	entries, capacity int
+}
+
+// Summarize returns s along with a filter of its paths. s is shared, not
+// copied.
+func Summarize(s *Set) *SummarizedSet {
+	ss := &SummarizedSet{set: s}
+	entries := 0
+	s.Iterate(func(p Path) { entries += len(p) + 1 })
+	ss.rebuild(entries)
+	return ss
+}
+
+// Set returns the set, which must not be modified.
+func (s *SummarizedSet) Set() *Set {
+	return s.set
+}
+
+// Insert adds p to the set and to the filter.
+func (s *SummarizedSet) Insert(p Path) {
+	s.set.Insert(p)
+	s.add(p)
+	if s.entries > s.capacity {
+		s.rebuild(2 * s.capacity)
+	}
+}
+
+// MayHave returns false if p isn't in the set, and true if it may be.
+func (s *SummarizedSet) MayHave(p Path) bool {
+	if len(p) == 0 {
+		return false
+	}
+	return s.hasBits('=', hashPath(p))
+}
+
+// MayTouch returns false if neither p nor any of its children are in the
+// set, and true if some may be.
+func (s *SummarizedSet) MayTouch(p Path) bool {
+	if len(p) == 0 {
+		return !s.set.Empty()
+	}
+	return s.hasBits('/', hashPath(p))
+}
+
+// Has returns whether p is in the set, checking the filter first.
+func (s *SummarizedSet) Has(p Path) bool {
+	return s.MayHave(p) && s.set.Has(p)
+}
+
+// rebuild sizes the filter for capacity entries, and adds the paths of the
+// set.
+func (s *SummarizedSet) rebuild(capacity int) {
+	if capacity < 64 {
+		capacity = 64
+	}
+	n := float64(capacity)
+	m := math.Ceil(-n * math.Log(summaryFalsePositiveRate) / (math.Ln2 * math.Ln2))
+	s.bits = make([]uint64, (int(m)+63)/64)
+	s.hashes = uint32(math.Round(m / n * math.Ln2))
+	s.entries, s.capacity = 0, capacity
+	s.set.Iterate(s.add)
+}
+
+// add adds p, and its prefixes, to the filter. The prefixes shared by
+// several paths are counted as many times as they are added.
+func (s *SummarizedSet) add(p Path) {
+	s.entries += len(p) + 1
+	h := uint64(fnvOffset)
+	for _, pe := range p {
+		h = hashPathElement(h, pe)
+		s.setBits('/', h)
+	}
+	s.setBits('=', h)
+}
+
+// setBits sets the bits of the entry of the given kind and hash.
+func (s *SummarizedSet) setBits(kind byte, sum uint64) {
+	n := uint64(len(s.bits)) * 64
+	h1, h2 := entryHashes(kind, sum)
+	for i := uint32(0); i < s.hashes; i++ {
+		b := (uint64(h1) + uint64(i)*uint64(h2)) % n
+		s.bits[b/64] |= 1 << (b % 64)
+	}
+}
+
+// hasBits returns whether all the bits of the entry of the given kind and hash
+// are set.
+func (s *SummarizedSet) hasBits(kind byte, sum uint64) bool {
+	n := uint64(len(s.bits)) * 64
+	h1, h2 := entryHashes(kind, sum)
+	for i := uint32(0); i < s.hashes; i++ {
+		b := (uint64(h1) + uint64(i)*uint64(h2)) % n
+		if s.bits[b/64]&(1<<(b%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// entryHashes returns the two hashes which the bits of an entry are derived
+// from, by double hashing.
+func entryHashes(kind byte, sum uint64) (uint32, uint32) {
+	sum ^= uint64(kind) * 0x9e3779b97f4a7c15
+	sum ^= sum >> 33
+	sum *= 0xff51afd7ed558ccd
+	sum ^= sum >> 33
+	return uint32(sum), uint32(sum>>32) | 1
+}
+
+const (
+	fnvOffset = 14695981039346656037
+	fnvPrime  = 1099511628211
+)
+
+// hashPath returns the FNV-1a hash of p, see hashPathElement.
+func hashPath(p Path) uint64 {
+	h := uint64(fnvOffset)
+	for _, pe := range p {
+		h = hashPathElement(h, pe)
+	}
+	return h
+}
+
+// hashPathElement adds pe to the FNV-1a hash h, prefixed by its length so
+// that consecutive path elements can't be confused.
+func hashPathElement(h uint64, pe PathElement) uint64 {
+	key := pathElementKey(pe)
+	n := len(key)
+	for ; n >= 0x80; n >>= 7 {
+		h = (h ^ uint64(n&0x7f|0x80)) * fnvPrime
+	}
+	h = (h ^ uint64(n)) * fnvPrime
+	for i := 0; i < len(key); i++ {
+		h = (h ^ uint64(key[i])) * fnvPrime
+	}
+	return h
+}
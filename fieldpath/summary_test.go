@@ -0,0 +1,84 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fieldpath
+
+import (
+	"fmt"
+	"testing"
+)
+
+func summaryTestPath(i int) Path {
+	return MakePathOrDie("spec", "containers", KeyByFields("name", fmt.Sprintf("c%d", i)), "image")
+}
+
+func TestSummarizedSet(t *testing.T) {
+	const n = 1000
+	s := NewSet()
+	for i := 0; i < n/2; i++ {
+		s.Insert(summaryTestPath(i))
+	}
+	ss := Summarize(s)
+	// Grows the filter past its capacity.
+	for i := n / 2; i < n; i++ {
+		ss.Insert(summaryTestPath(i))
+	}
+	for i := 0; i < n; i++ {
+		p := summaryTestPath(i)
+		if !ss.MayHave(p) || !ss.Has(p) {
+			t.Fatalf("expected the set to have %v", p)
+		}
+		if !ss.MayTouch(p) || !ss.MayTouch(p[:3]) || !ss.MayTouch(p[:1]) {
+			t.Fatalf("expected the set to touch the prefixes of %v", p)
+		}
+	}
+	if ss.Has(MakePathOrDie("spec", "containers")) {
+		t.Error("expected the set not to have a prefix of its paths")
+	}
+	falsePositives := 0
+	for i := n; i < 2*n; i++ {
+		p := summaryTestPath(i)
+		if ss.MayTouch(p[:3]) {
+			falsePositives++
+		}
+		if ss.MayHave(p) {
+			falsePositives++
+		}
+		if ss.Has(p) {
+			t.Fatalf("expected the set not to have %v", p)
+		}
+	}
+	if rate := float64(falsePositives) / (2 * n); rate > 3*summaryFalsePositiveRate {
+		t.Errorf("expected about %v of false positives, got %v", summaryFalsePositiveRate, rate)
+	}
+	if Summarize(NewSet()).MayTouch(nil) {
+		t.Error("expected an empty set not to touch the root")
+	}
+}
+
+func BenchmarkSummarizedSetMayTouch(b *testing.B) {
+	s := NewSet()
+	for i := 0; i < 1000; i++ {
+		s.Insert(summaryTestPath(i))
+	}
+	ss := Summarize(s)
+	p := MakePathOrDie("metadata", "labels", "app")
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ss.MayTouch(p)
+	}
+}
@@ -0,0 +1,101 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fieldpath
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// VersionMismatchError is returned by the operations on VersionedSets
+// whose operands are of different versions. Paths depend on the version,
+// so mixing such sets silently corrupts ownership: one of the sets must be
+// converted first.
+type VersionMismatchError struct {
+	// Operation is the name of the failed operation, e.g. "union".
+	Operation string
+	// LHS and RHS are the versions of the operands.
+	LHS, RHS APIVersion
+}
+
+func (e *VersionMismatchError) Error() string {
+	return fmt.Sprintf("%v of sets of different versions %q and %q", e.Operation, e.LHS, e.RHS)
+}
+
+// VersionCheckMode tells how version mismatches are reported.
+type VersionCheckMode int32
+
+const (
+	// ErrorOnVersionMismatch returns a *VersionMismatchError. It is the
+	// default.
+	ErrorOnVersionMismatch VersionCheckMode = iota
+	// PanicOnVersionMismatch panics with a *VersionMismatchError, which is
+	// meant for tests and debugging, to find where sets get mixed.
+	PanicOnVersionMismatch
+)
+
+var versionCheckMode int32
+
+// SetVersionCheckMode sets how version mismatches are reported by the
+// process, and returns the previous mode.
+func SetVersionCheckMode(mode VersionCheckMode) VersionCheckMode {
+	return VersionCheckMode(atomic.SwapInt32(&versionCheckMode, int32(mode)))
+}
+
+// CheckVersions returns a *VersionMismatchError, or panics with it in
+// PanicOnVersionMismatch mode, if lhs and rhs are of different versions.
+// operation names the operation in the error.
+func CheckVersions(operation string, lhs, rhs VersionedSet) error {
+	if lhs.APIVersion() == rhs.APIVersion() {
+		return nil
+	}
+	err := &VersionMismatchError{Operation: operation, LHS: lhs.APIVersion(), RHS: rhs.APIVersion()}
+	if VersionCheckMode(atomic.LoadInt32(&versionCheckMode)) == PanicOnVersionMismatch {
+		panic(err)
+	}
+	return err
+}
+
+// UnionVersioned returns the union of lhs and rhs, which must be of the
+// same version. The result is applied, and owned through a
+// subresource, if lhs is.
+func UnionVersioned(lhs, rhs VersionedSet) (VersionedSet, error) {
+	if err := CheckVersions("union", lhs, rhs); err != nil {
+		return nil, err
+	}
+	return WithSet(lhs, lhs.Set().Union(rhs.Set()), lhs.APIVersion()), nil
+}
+
+// DifferenceVersioned returns the fields of lhs which aren't in rhs, which
+// must be of the same version. The result is applied, and owned through a
+// subresource, if lhs is.
+func DifferenceVersioned(lhs, rhs VersionedSet) (VersionedSet, error) {
+	if err := CheckVersions("difference", lhs, rhs); err != nil {
+		return nil, err
+	}
+	return WithSet(lhs, lhs.Set().Difference(rhs.Set()), lhs.APIVersion()), nil
+}
+
+// IntersectionVersioned returns the fields in both lhs and rhs, which must
+// be of the same version. The result is applied, and owned through a
+// subresource, if lhs is.
+func IntersectionVersioned(lhs, rhs VersionedSet) (VersionedSet, error) {
+	if err := CheckVersions("intersection", lhs, rhs); err != nil {
+		return nil, err
+	}
+	return WithSet(lhs, lhs.Set().Intersection(rhs.Set()), lhs.APIVersion()), nil
+}
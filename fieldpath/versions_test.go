@@ -0,0 +1,104 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fieldpath_test
+
+import (
+	"testing"
+
+	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
+)
+
+func TestVersionedAlgebra(t *testing.T) {
+	v1 := func(s *fieldpath.Set) fieldpath.VersionedSet { return fieldpath.NewVersionedSet(s, "v1", true) }
+	v2 := func(s *fieldpath.Set) fieldpath.VersionedSet { return fieldpath.NewVersionedSet(s, "v2", false) }
+	ops := map[string]func(lhs, rhs fieldpath.VersionedSet) (fieldpath.VersionedSet, error){
+		"union":        fieldpath.UnionVersioned,
+		"difference":   fieldpath.DifferenceVersioned,
+		"intersection": fieldpath.IntersectionVersioned,
+	}
+	tests := []struct {
+		op       string
+		lhs, rhs fieldpath.VersionedSet
+		out      *fieldpath.Set
+		err      string
+	}{
+		{
+			op:  "union",
+			lhs: v1(_NS(_P("a"), _P("b"))),
+			rhs: v1(_NS(_P("b"), _P("c"))),
+			out: _NS(_P("a"), _P("b"), _P("c")),
+		}, {
+			op:  "difference",
+			lhs: v1(_NS(_P("a"), _P("b"))),
+			rhs: v1(_NS(_P("b"), _P("c"))),
+			out: _NS(_P("a")),
+		}, {
+			op:  "intersection",
+			lhs: v1(_NS(_P("a"), _P("b"))),
+			rhs: v1(_NS(_P("b"), _P("c"))),
+			out: _NS(_P("b")),
+		}, {
+			op:  "union",
+			lhs: v1(_NS(_P("a"))),
+			rhs: v2(_NS(_P("a"))),
+			err: `union of sets of different versions "v1" and "v2"`,
+		}, {
+			op:  "difference",
+			lhs: v2(_NS(_P("a"))),
+			rhs: v1(_NS(_P("a"))),
+			err: `difference of sets of different versions "v2" and "v1"`,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.op+" "+string(tt.lhs.APIVersion())+"/"+string(tt.rhs.APIVersion()), func(t *testing.T) {
+			got, err := ops[tt.op](tt.lhs, tt.rhs)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("expected error %q, got %v", tt.err, err)
+				}
+				if _, ok := err.(*fieldpath.VersionMismatchError); !ok {
+					t.Errorf("expected a *VersionMismatchError, got %T", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !got.Set().Equals(tt.out) {
+				t.Errorf("expected %v, got %v", tt.out, got.Set())
+			}
+			if got.APIVersion() != tt.lhs.APIVersion() || got.Applied() != tt.lhs.Applied() {
+				t.Errorf("expected version and applied of lhs, got %v %v", got.APIVersion(), got.Applied())
+			}
+		})
+	}
+}
+
+func TestVersionCheckPanics(t *testing.T) {
+	defer fieldpath.SetVersionCheckMode(fieldpath.SetVersionCheckMode(fieldpath.PanicOnVersionMismatch))
+	defer func() {
+		if _, ok := recover().(*fieldpath.VersionMismatchError); !ok {
+			t.Errorf("expected a panic with a *VersionMismatchError")
+		}
+	}()
+	fieldpath.UnionVersioned(
+		fieldpath.NewVersionedSet(_NS(_P("a")), "v1", true),
+		fieldpath.NewVersionedSet(_NS(_P("a")), "v2", true),
+	)
+}
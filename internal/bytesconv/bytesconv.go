@@ -0,0 +1,33 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package bytesconv converts between strings and byte slices without
+// copying them, for the parsing and serialization hot paths which would
+// otherwise copy whole documents only to read them.
+//
+// The conversions share the memory of their argument, which is safe as
+// long as:
+//   - the bytes given to String aren't modified while the string is in use,
+//     which is usually the case of a buffer which is discarded afterwards;
+//   - the bytes returned by Bytes are never modified, and not retained by
+//     their users, e.g. they are only given to decoders which copy what
+//     they keep, as io.Writer implementations and the YAML and JSON
+//     decoders do.
+//
+// Building with the smd_nounsafe tag replaces them with plain copying
+// conversions, for environments which don't allow the unsafe package or to
+// rule them out when investigating a memory corruption.
+package bytesconv
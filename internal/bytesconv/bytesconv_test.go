@@ -0,0 +1,41 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bytesconv
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestConversions(t *testing.T) {
+	tests := []string{"", "a", "hello, world", string([]byte{0, 1, 2, 255})}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt, func(t *testing.T) {
+			b := Bytes(tt)
+			if !bytes.Equal(b, []byte(tt)) {
+				t.Errorf("expected bytes %q, got %q", tt, b)
+			}
+			if s := String([]byte(tt)); s != tt {
+				t.Errorf("expected string %q, got %q", tt, s)
+			}
+			if s := String(b); s != tt {
+				t.Errorf("expected round trip to %q, got %q", tt, s)
+			}
+		})
+	}
+}
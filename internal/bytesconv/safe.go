@@ -0,0 +1,33 @@
+//go:build smd_nounsafe
+// +build smd_nounsafe
+
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bytesconv
+
+// When built with the smd_nounsafe tag, the conversions copy their
+// argument.
+
+// String returns a copy of b as a string.
+func String(b []byte) string {
+	return string(b)
+}
+
+// Bytes returns a copy of s as bytes.
+func Bytes(s string) []byte {
+	return []byte(s)
+}
@@ -0,0 +1,44 @@
+//go:build !smd_nounsafe
+// +build !smd_nounsafe
+
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bytesconv
+
+import "unsafe"
+
+// String returns b as a string, without copying it: b must not be modified
+// as long as the string is in use.
+func String(b []byte) string {
+	if len(b) == 0 {
+		return ""
+	}
+	return *(*string)(unsafe.Pointer(&b))
+}
+
+// Bytes returns s as bytes, without copying it: the bytes must never be
+// modified, nor retained beyond the lifetime of s.
+func Bytes(s string) []byte {
+	if s == "" {
+		return nil
+	}
+	// A slice is a string header followed by its capacity.
+	return *(*[]byte)(unsafe.Pointer(&struct {
+		string
+		int
+	}{s, len(s)}))
+}
@@ -0,0 +1,172 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+
+	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
+)
+
+// analyzePrefixDepth is the number of path elements of the prefixes that
+// leaves are grouped by.
+const analyzePrefixDepth = 3
+
+// analyzeTopPrefixes is the number of prefixes reported.
+const analyzeTopPrefixes = 10
+
+type analyzeManagedFields struct {
+	managedFields string
+	format        outputFormat
+}
+
+// analysis is the report of analyzeManagedFields.
+type analysis struct {
+	Managers []managerAnalysis `json:"managers"`
+	// FieldsBytes is the total size of the encoded fields of the managers,
+	// ManagedFieldsBytes the size of the managed fields, and DeltaBytes
+	// their size once delta-encoded.
+	FieldsBytes        int              `json:"fieldsBytes"`
+	ManagedFieldsBytes int              `json:"managedFieldsBytes"`
+	DeltaBytes         int              `json:"deltaBytes"`
+	TopPrefixes        []prefixAnalysis `json:"topPrefixes"`
+}
+
+type managerAnalysis struct {
+	Manager string `json:"manager"`
+	Leaves  int    `json:"leaves"`
+	Size    int    `json:"size"`
+	// Overlap is the percentage of the leaves which other managers own too.
+	Overlap float64 `json:"overlap"`
+}
+
+type prefixAnalysis struct {
+	Prefix string `json:"prefix"`
+	Leaves int    `json:"leaves"`
+}
+
+// Execute reports, for each manager, the number of leaves it owns, the size
+// of its encoded fields, and the share of its leaves which other managers
+// own too; then the total size of the fields, also once delta-encoded (see
+// fieldpath.EncodeManagedFieldsDelta), and the prefixes with the most owned
+// leaves.
+func (a analyzeManagedFields) Execute(w io.Writer) error {
+	managers, err := readManagedFields(a.managedFields)
+	if err != nil {
+		return err
+	}
+	report, err := analyze(managers)
+	if err != nil {
+		return err
+	}
+	switch a.format {
+	case formatText, formatTable:
+		return report.write(w)
+	case formatJSON, formatYAML:
+		return writeStructured(w, a.format, report)
+	}
+	return errUnsupportedFormat("analyze-managed-fields", a.format)
+}
+
+func analyze(managers fieldpath.ManagedFields) (*analysis, error) {
+	report := &analysis{Managers: []managerAnalysis{}, TopPrefixes: []prefixAnalysis{}}
+	names := make([]string, 0, len(managers))
+	leaves := make(map[string]*fieldpath.Set, len(managers))
+	for name, set := range managers {
+		names = append(names, name)
+		leaves[name] = set.Set().Leaves()
+	}
+	sort.Strings(names)
+
+	prefixes := map[string]int{}
+	for _, name := range names {
+		fields, err := managers[name].Set().ToJSON()
+		if err != nil {
+			return nil, fmt.Errorf("unable to encode the fields of %q: %v", name, err)
+		}
+		report.FieldsBytes += len(fields)
+		others := fieldpath.NewSet()
+		for _, other := range names {
+			if other != name {
+				others = others.Union(leaves[other])
+			}
+		}
+		own := leaves[name].Size()
+		overlap := 0.0
+		if own > 0 {
+			overlap = 100 * float64(leaves[name].Intersection(others).Size()) / float64(own)
+		}
+		report.Managers = append(report.Managers, managerAnalysis{Manager: name, Leaves: own, Size: len(fields), Overlap: overlap})
+		leaves[name].Iterate(func(p fieldpath.Path) {
+			if len(p) > analyzePrefixDepth {
+				p = p[:analyzePrefixDepth]
+			}
+			prefixes[p.String()]++
+		})
+	}
+
+	delta, err := fieldpath.EncodeManagedFieldsDelta(managers)
+	if err != nil {
+		return nil, err
+	}
+	full, err := fieldpath.EncodeManagedFields(managers)
+	if err != nil {
+		return nil, err
+	}
+	report.ManagedFieldsBytes, report.DeltaBytes = len(full), len(delta)
+
+	top := make([]string, 0, len(prefixes))
+	for prefix := range prefixes {
+		top = append(top, prefix)
+	}
+	sort.Slice(top, func(i, j int) bool {
+		if prefixes[top[i]] != prefixes[top[j]] {
+			return prefixes[top[i]] > prefixes[top[j]]
+		}
+		return top[i] < top[j]
+	})
+	if len(top) > analyzeTopPrefixes {
+		top = top[:analyzeTopPrefixes]
+	}
+	for _, prefix := range top {
+		report.TopPrefixes = append(report.TopPrefixes, prefixAnalysis{Prefix: prefix, Leaves: prefixes[prefix]})
+	}
+	return report, nil
+}
+
+// write writes the report as tables.
+func (a *analysis) write(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "MANAGER\tLEAVES\tSIZE\tOVERLAP")
+	for _, m := range a.Managers {
+		fmt.Fprintf(tw, "%v\t%v\t%v\t%.1f%%\n", m.Manager, m.Leaves, m.Size, m.Overlap)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "\nFields: %v bytes, managed fields: %v bytes, delta-encoded: %v bytes\n\n", a.FieldsBytes, a.ManagedFieldsBytes, a.DeltaBytes)
+
+	fmt.Fprintln(tw, "PREFIX\tLEAVES")
+	for _, p := range a.TopPrefixes {
+		fmt.Fprintf(tw, "%v\t%v\n", p.Prefix, p.Leaves)
+	}
+	return tw.Flush()
+}
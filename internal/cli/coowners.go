@@ -0,0 +1,80 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+)
+
+type coOwners struct {
+	managedFields string
+	format        outputFormat
+}
+
+// coOwnersResult is the report of coOwners.
+type coOwnersResult struct {
+	Shared []string        `json:"shared"`
+	Pairs  []coOwnersEntry `json:"pairs"`
+}
+
+type coOwnersEntry struct {
+	Managers [2]string `json:"managers"`
+	Fields   []string  `json:"fields"`
+}
+
+// Execute reports the fields owned by several managers, grouped by pairs of
+// managers, see fieldpath.ManagedFields.SharedOwnership.
+func (c coOwners) Execute(w io.Writer) error {
+	managers, err := readManagedFields(c.managedFields)
+	if err != nil {
+		return err
+	}
+	shared := managers.SharedOwnership()
+	result := coOwnersResult{Shared: pathStrings(shared.Fields), Pairs: []coOwnersEntry{}}
+	for _, pair := range shared.Pairs {
+		result.Pairs = append(result.Pairs, coOwnersEntry{Managers: pair.Managers, Fields: pathStrings(pair.Fields)})
+	}
+
+	switch c.format {
+	case formatText:
+		if len(result.Pairs) == 0 {
+			_, err := fmt.Fprintln(w, "No field has several owners.")
+			return err
+		}
+		for _, pair := range result.Pairs {
+			fmt.Fprintf(w, "%v and %v:\n", pair.Managers[0], pair.Managers[1])
+			for _, field := range pair.Fields {
+				fmt.Fprintf(w, "  %v\n", field)
+			}
+		}
+		return nil
+	case formatTable:
+		tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(tw, "FIELD\tMANAGER\tMANAGER")
+		for _, pair := range result.Pairs {
+			for _, field := range pair.Fields {
+				fmt.Fprintf(tw, "%v\t%v\t%v\n", field, pair.Managers[0], pair.Managers[1])
+			}
+		}
+		return tw.Flush()
+	case formatJSON, formatYAML:
+		return writeStructured(w, c.format, result)
+	}
+	return errUnsupportedFormat("co-owners", c.format)
+}
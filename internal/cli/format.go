@@ -0,0 +1,117 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
+	"sigs.k8s.io/yaml"
+)
+
+// outputFormat is the format of the output of an operation.
+type outputFormat string
+
+const (
+	// formatText is the default, human-readable output of each operation.
+	formatText outputFormat = ""
+	// formatJSON and formatYAML are machine-readable outputs.
+	formatJSON outputFormat = "json"
+	formatYAML outputFormat = "yaml"
+	// formatTable lists the results in aligned columns.
+	formatTable outputFormat = "table"
+	// formatColorDiff shows the differences between objects as a unified
+	// diff, with ANSI colors.
+	formatColorDiff outputFormat = "color-diff"
+)
+
+func parseOutputFormat(s string) (outputFormat, error) {
+	switch f := outputFormat(s); f {
+	case formatText, formatJSON, formatYAML, formatTable, formatColorDiff:
+		return f, nil
+	}
+	return "", fmt.Errorf("unknown output format %q, must be one of json, yaml, table or color-diff", s)
+}
+
+// errUnsupportedFormat is returned by operations which don't support format.
+func errUnsupportedFormat(operation string, format outputFormat) error {
+	return fmt.Errorf("--format %v is not supported by --%v", format, operation)
+}
+
+// writeStructured writes v, which must be serializable to JSON, as JSON or
+// YAML.
+func writeStructured(w io.Writer, format outputFormat, v interface{}) error {
+	var b []byte
+	var err error
+	if format == formatYAML {
+		b, err = yaml.Marshal(v)
+	} else {
+		b, err = json.MarshalIndent(v, "", "  ")
+		b = append(b, '\n')
+	}
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+// pathStrings lists the paths of set, in the order of Set.Iterate.
+func pathStrings(set *fieldpath.Set) []string {
+	paths := []string{}
+	set.Iterate(func(p fieldpath.Path) {
+		paths = append(paths, p.String())
+	})
+	return paths
+}
+
+const (
+	colorReset = "\x1b[0m"
+	colorRed   = "\x1b[31m"
+	colorGreen = "\x1b[32m"
+	colorCyan  = "\x1b[36m"
+)
+
+// colorizeDiff adds ANSI colors to a unified diff, see typed.TypedValue.Diff.
+func colorizeDiff(diff string) string {
+	var b strings.Builder
+	for _, line := range strings.SplitAfter(diff, "\n") {
+		color := ""
+		switch {
+		case strings.HasPrefix(line, "@@"):
+			color = colorCyan
+		case strings.HasPrefix(line, "-"):
+			color = colorRed
+		case strings.HasPrefix(line, "+"):
+			color = colorGreen
+		}
+		if color == "" {
+			b.WriteString(line)
+			continue
+		}
+		b.WriteString(color)
+		b.WriteString(strings.TrimSuffix(line, "\n"))
+		b.WriteString(colorReset)
+		if strings.HasSuffix(line, "\n") {
+			b.WriteByte('\n')
+		}
+	}
+	return b.String()
+}
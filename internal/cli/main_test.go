@@ -249,3 +249,268 @@ func TestFieldSet(t *testing.T) {
 		})
 	}
 }
+
+func TestExplain(t *testing.T) {
+	cases := []testCase{{
+		options: Options{
+			schemaPath:        testdata("schema.yaml"),
+			explain:           `["f:spec","f:replicas"]`,
+			managedFieldsPath: testdata("managed-fields.yaml"),
+		},
+		expectedOutputPath: testdata("explain-output.txt"),
+	}, {
+		options: Options{
+			schemaPath:        testdata("schema.yaml"),
+			explain:           `["f:spec","f:paused"]`,
+			managedFieldsPath: testdata("managed-fields.yaml"),
+		},
+	}, {
+		options: Options{
+			schemaPath:        testdata("schema.yaml"),
+			explain:           `["f:spec"]`,
+			managedFieldsPath: testdata("missing.yaml"),
+		},
+		expectErr: true,
+	}}
+
+	for _, tt := range cases {
+		tt := tt
+		t.Run(tt.options.explain, func(t *testing.T) {
+			op, err := tt.options.Resolve()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var b bytes.Buffer
+			err = op.Execute(&b)
+			if tt.expectErr {
+				if err == nil {
+					t.Error("unexpected success")
+				}
+				return
+			} else if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			tt.checkOutput(t, b.Bytes())
+		})
+	}
+
+	if _, err := (&Options{schemaPath: testdata("schema.yaml"), explain: `["f:spec"]`}).Resolve(); err != ErrNeedManagedFieldsArg {
+		t.Errorf("expected %v, got %v", ErrNeedManagedFieldsArg, err)
+	}
+	if _, err := (&Options{schemaPath: testdata("schema.yaml"), explain: `.spec`, managedFieldsPath: testdata("managed-fields.yaml")}).Resolve(); err == nil {
+		t.Error("expected invalid path to fail")
+	}
+}
+
+func TestDiff(t *testing.T) {
+	cases := []testCase{{
+		options: Options{
+			schemaPath: testdata("schema.yaml"),
+			diff:       true,
+			lhsPath:    testdata("struct.yaml"),
+			rhsPath:    testdata("struct-changed.yaml"),
+		},
+		expectedOutputPath: testdata("diff-output.txt"),
+	}, {
+		options: Options{
+			schemaPath: testdata("schema.yaml"),
+			diff:       true,
+			sideBySide: true,
+			lhsPath:    testdata("struct.yaml"),
+			rhsPath:    testdata("struct-changed.yaml"),
+		},
+		expectedOutputPath: testdata("diff-side-by-side-output.txt"),
+	}, {
+		options: Options{
+			schemaPath: testdata("schema.yaml"),
+			diff:       true,
+			lhsPath:    testdata("struct.yaml"),
+			rhsPath:    testdata("bad-schema.yaml"),
+		},
+		expectErr: true,
+	}}
+
+	for _, tt := range cases {
+		tt := tt
+		t.Run(tt.options.rhsPath, func(t *testing.T) {
+			op, err := tt.options.Resolve()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var b bytes.Buffer
+			err = op.Execute(&b)
+			if tt.expectErr {
+				if err == nil {
+					t.Error("unexpected success")
+				}
+				return
+			} else if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			tt.checkOutput(t, b.Bytes())
+		})
+	}
+}
+
+func TestFormats(t *testing.T) {
+	cases := []testCase{{
+		options: Options{
+			schemaPath: testdata("schema.yaml"),
+			compare:    true,
+			format:     "json",
+			lhsPath:    testdata("struct.yaml"),
+			rhsPath:    testdata("struct-changed.yaml"),
+		},
+		expectedOutputPath: testdata("compare-json-output.json"),
+	}, {
+		options: Options{
+			schemaPath: testdata("schema.yaml"),
+			compare:    true,
+			format:     "table",
+			lhsPath:    testdata("struct.yaml"),
+			rhsPath:    testdata("struct-changed.yaml"),
+		},
+		expectedOutputPath: testdata("compare-table-output.txt"),
+	}, {
+		options: Options{
+			schemaPath: testdata("schema.yaml"),
+			diff:       true,
+			format:     "color-diff",
+			lhsPath:    testdata("struct.yaml"),
+			rhsPath:    testdata("struct-changed.yaml"),
+		},
+		expectedOutputPath: testdata("diff-color-output.txt"),
+	}, {
+		options: Options{
+			schemaPath:        testdata("schema.yaml"),
+			explain:           `["f:spec","f:replicas"]`,
+			managedFieldsPath: testdata("managed-fields.yaml"),
+			format:            "yaml",
+		},
+		expectedOutputPath: testdata("explain-yaml-output.yaml"),
+	}, {
+		options: Options{
+			analyzeManagedFields: testdata("managed-fields.yaml"),
+			format:               "json",
+		},
+		expectedOutputPath: testdata("analyze-json-output.json"),
+	}, {
+		options: Options{
+			schemaPath: testdata("schema.yaml"),
+			merge:      true,
+			format:     "table",
+			lhsPath:    testdata("struct.yaml"),
+			rhsPath:    testdata("list.yaml"),
+		},
+		expectErr: true,
+	}}
+
+	for _, tt := range cases {
+		tt := tt
+		t.Run(tt.expectedOutputPath, func(t *testing.T) {
+			op, err := tt.options.Resolve()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var b bytes.Buffer
+			err = op.Execute(&b)
+			if tt.expectErr {
+				if err == nil {
+					t.Error("unexpected success")
+				}
+				return
+			} else if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			tt.checkOutput(t, b.Bytes())
+		})
+	}
+
+	if _, err := (&Options{schemaPath: testdata("schema.yaml"), listTypes: true, format: "xml"}).Resolve(); err == nil {
+		t.Error("expected unknown format to fail")
+	}
+}
+
+func TestAnalyzeManagedFields(t *testing.T) {
+	cases := []testCase{{
+		options: Options{
+			analyzeManagedFields: testdata("managed-fields.yaml"),
+		},
+		expectedOutputPath: testdata("analyze-output.txt"),
+	}, {
+		options: Options{
+			analyzeManagedFields: testdata("missing.yaml"),
+		},
+		expectErr: true,
+	}}
+
+	for _, tt := range cases {
+		tt := tt
+		t.Run(tt.options.analyzeManagedFields, func(t *testing.T) {
+			op, err := tt.options.Resolve()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var b bytes.Buffer
+			err = op.Execute(&b)
+			if tt.expectErr {
+				if err == nil {
+					t.Error("unexpected success")
+				}
+				return
+			} else if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			tt.checkOutput(t, b.Bytes())
+		})
+	}
+
+	if _, err := (&Options{analyzeManagedFields: testdata("managed-fields.yaml"), merge: true}).Resolve(); err != ErrTooManyOperations {
+		t.Errorf("expected %v, got %v", ErrTooManyOperations, err)
+	}
+}
+
+func TestCoOwners(t *testing.T) {
+	cases := []testCase{{
+		options: Options{
+			coOwners: testdata("managed-fields.yaml"),
+		},
+		expectedOutputPath: testdata("co-owners-output.txt"),
+	}, {
+		options: Options{
+			coOwners: testdata("managed-fields.yaml"),
+			format:   "json",
+		},
+		expectedOutputPath: testdata("co-owners-json-output.json"),
+	}, {
+		options: Options{
+			coOwners: testdata("missing.yaml"),
+		},
+		expectErr: true,
+	}}
+
+	for _, tt := range cases {
+		tt := tt
+		t.Run(tt.options.coOwners+tt.options.format, func(t *testing.T) {
+			op, err := tt.options.Resolve()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var b bytes.Buffer
+			err = op.Execute(&b)
+			if tt.expectErr {
+				if err == nil {
+					t.Error("unexpected success")
+				}
+				return
+			} else if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			tt.checkOutput(t, b.Bytes())
+		})
+	}
+
+	if _, err := (&Options{coOwners: testdata("managed-fields.yaml"), analyzeManagedFields: testdata("managed-fields.yaml")}).Resolve(); err != ErrTooManyOperations {
+		t.Errorf("expected %v, got %v", ErrTooManyOperations, err)
+	}
+}
@@ -17,12 +17,18 @@ limitations under the License.
 package cli
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"text/tabwriter"
 
+	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
+	smdmerge "sigs.k8s.io/structured-merge-diff/v4/merge"
 	"sigs.k8s.io/structured-merge-diff/v4/typed"
 	"sigs.k8s.io/structured-merge-diff/v4/value"
+	"sigs.k8s.io/yaml"
 )
 
 type Operation interface {
@@ -32,6 +38,7 @@ type Operation interface {
 type operationBase struct {
 	parser   *typed.Parser
 	typeName string
+	format   outputFormat
 }
 
 func (b operationBase) parseFile(path string) (tv *typed.TypedValue, err error) {
@@ -52,9 +59,29 @@ type validation struct {
 	fileToValidate string
 }
 
-func (v validation) Execute(_ io.Writer) error {
+// validationResult is the machine-readable output of a validation.
+type validationResult struct {
+	File  string `json:"file"`
+	Valid bool   `json:"valid"`
+	Error string `json:"error,omitempty"`
+}
+
+func (v validation) Execute(w io.Writer) error {
 	_, err := v.parseFile(v.fileToValidate)
-	return err
+	switch v.format {
+	case formatText:
+		return err
+	case formatJSON, formatYAML:
+		result := validationResult{File: v.fileToValidate, Valid: err == nil}
+		if err != nil {
+			result.Error = err.Error()
+		}
+		if werr := writeStructured(w, v.format, result); werr != nil {
+			return werr
+		}
+		return err
+	}
+	return errUnsupportedFormat("validate", v.format)
 }
 
 type fieldset struct {
@@ -78,7 +105,29 @@ func (f fieldset) Execute(w io.Writer) error {
 		return err
 	}
 
-	return c.Added.ToJSONStream(w)
+	switch f.format {
+	case formatText, formatJSON:
+		return c.Added.ToJSONStream(w)
+	case formatYAML:
+		b, err := c.Added.ToJSON()
+		if err != nil {
+			return err
+		}
+		y, err := yaml.JSONToYAML(b)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(y)
+		return err
+	case formatTable:
+		tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(tw, "PATH")
+		for _, p := range pathStrings(c.Added) {
+			fmt.Fprintln(tw, p)
+		}
+		return tw.Flush()
+	}
+	return errUnsupportedFormat("fieldset", f.format)
 }
 
 type listTypes struct {
@@ -86,10 +135,19 @@ type listTypes struct {
 }
 
 func (l listTypes) Execute(w io.Writer) error {
-	for _, td := range l.parser.Schema.Types {
-		fmt.Fprintf(w, "%v\n", td.Name)
+	switch l.format {
+	case formatText, formatTable:
+		if l.format == formatTable {
+			fmt.Fprintln(w, "NAME")
+		}
+		for _, td := range l.parser.Schema.Types {
+			fmt.Fprintf(w, "%v\n", td.Name)
+		}
+		return nil
+	case formatJSON, formatYAML:
+		return writeStructured(w, l.format, l.parser.TypeNames())
 	}
-	return nil
+	return errUnsupportedFormat("list-types", l.format)
 }
 
 type merge struct {
@@ -114,11 +172,24 @@ func (m merge) Execute(w io.Writer) error {
 		return err
 	}
 
-	yaml, err := value.ToYAML(out.AsValue())
+	var b []byte
+	switch m.format {
+	case formatText, formatYAML:
+		b, err = value.ToYAML(out.AsValue())
+	case formatJSON:
+		b, err = value.ToJSON(out.AsValue())
+		b = append(b, '\n')
+	case formatColorDiff:
+		var diff string
+		diff, err = lhs.Diff(out)
+		b = []byte(colorizeDiff(diff))
+	default:
+		return errUnsupportedFormat("merge", m.format)
+	}
 	if err != nil {
 		return err
 	}
-	_, err = w.Write(yaml)
+	_, err = w.Write(b)
 
 	return err
 }
@@ -140,6 +211,10 @@ func (c compare) Execute(w io.Writer) error {
 		return err
 	}
 
+	if c.format != formatText {
+		return writeComparison(w, "compare", c.format, lhs, rhs)
+	}
+
 	got, err := lhs.Compare(rhs)
 	if err != nil {
 		return err
@@ -150,10 +225,212 @@ func (c compare) Execute(w io.Writer) error {
 		return err
 	}
 
-	// TODO: I think it'd be neat if we actually emitted a machine-readable
-	// format.
-
 	_, err = fmt.Fprintf(w, got.String())
 
 	return err
 }
+
+// comparisonResult is the machine-readable output of a comparison.
+type comparisonResult struct {
+	Added    []string `json:"added"`
+	Modified []string `json:"modified"`
+	Removed  []string `json:"removed"`
+}
+
+// writeComparison writes the differences between lhs and rhs in any format
+// but text, on behalf of operation.
+func writeComparison(w io.Writer, operation string, format outputFormat, lhs, rhs *typed.TypedValue) error {
+	if format == formatColorDiff {
+		diff, err := lhs.Diff(rhs)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprint(w, colorizeDiff(diff))
+		return err
+	}
+	got, err := lhs.Compare(rhs)
+	if err != nil {
+		return err
+	}
+	result := comparisonResult{
+		Added:    pathStrings(got.Added),
+		Modified: pathStrings(got.Modified),
+		Removed:  pathStrings(got.Removed),
+	}
+	switch format {
+	case formatJSON, formatYAML:
+		return writeStructured(w, format, result)
+	case formatTable:
+		tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(tw, "CHANGE\tPATH")
+		for _, change := range []struct {
+			name  string
+			paths []string
+		}{{"added", result.Added}, {"modified", result.Modified}, {"removed", result.Removed}} {
+			for _, p := range change.paths {
+				fmt.Fprintf(tw, "%v\t%v\n", change.name, p)
+			}
+		}
+		return tw.Flush()
+	}
+	return errUnsupportedFormat(operation, format)
+}
+
+type diff struct {
+	operationBase
+
+	lhs        string
+	rhs        string
+	sideBySide bool
+}
+
+func (d diff) Execute(w io.Writer) error {
+	lhs, err := d.parseFile(d.lhs)
+	if err != nil {
+		return err
+	}
+	rhs, err := d.parseFile(d.rhs)
+	if err != nil {
+		return err
+	}
+
+	if d.format != formatText {
+		return writeComparison(w, "diff", d.format, lhs, rhs)
+	}
+
+	var opts []typed.DiffOption
+	if d.sideBySide {
+		opts = append(opts, typed.WithSideBySide(60))
+	}
+	got, err := lhs.Diff(rhs, opts...)
+	if err != nil {
+		return err
+	}
+	if got == "" {
+		_, err = fmt.Fprint(w, "No difference")
+		return err
+	}
+	_, err = fmt.Fprint(w, got)
+	return err
+}
+
+type explain struct {
+	operationBase
+
+	managedFields string
+	path          fieldpath.Path
+}
+
+func (e explain) Execute(w io.Writer) error {
+	managers, err := readManagedFields(e.managedFields)
+	if err != nil {
+		return err
+	}
+	explanation := smdmerge.Explain(managers, e.path)
+	switch e.format {
+	case formatText:
+		_, err = fmt.Fprint(w, explanation.String())
+		return err
+	case formatJSON, formatYAML:
+		result := explainResult{
+			Path:   explanation.Path.String(),
+			Owned:  explanation.Owned.String(),
+			Owners: []explainOwner{},
+		}
+		for _, owner := range explanation.Owners {
+			result.Owners = append(result.Owners, explainOwner{
+				Manager:     owner.Manager,
+				Subresource: owner.Subresource,
+				APIVersion:  string(owner.APIVersion),
+				Applied:     owner.Applied,
+			})
+		}
+		return writeStructured(w, e.format, result)
+	case formatTable:
+		tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(tw, "MANAGER\tSUBRESOURCE\tAPIVERSION\tAPPLIED\tOWNED")
+		for _, owner := range explanation.Owners {
+			fmt.Fprintf(tw, "%v\t%v\t%v\t%v\t%v\n", owner.Manager, owner.Subresource, owner.APIVersion, owner.Applied, explanation.Owned)
+		}
+		return tw.Flush()
+	}
+	return errUnsupportedFormat("explain", e.format)
+}
+
+// explainResult is the machine-readable output of explain.
+type explainResult struct {
+	Path   string         `json:"path"`
+	Owned  string         `json:"owned"`
+	Owners []explainOwner `json:"owners"`
+}
+
+type explainOwner struct {
+	Manager     string `json:"manager"`
+	Subresource string `json:"subresource,omitempty"`
+	APIVersion  string `json:"apiVersion"`
+	Applied     bool   `json:"applied"`
+}
+
+// parsePath parses a path given as a JSON list of serialized path elements.
+func parsePath(s string) (fieldpath.Path, error) {
+	var elements []string
+	if err := json.Unmarshal([]byte(s), &elements); err != nil {
+		return nil, fmt.Errorf("unable to parse path %q: %v", s, err)
+	}
+	path := make(fieldpath.Path, 0, len(elements))
+	for _, element := range elements {
+		pe, err := fieldpath.DeserializePathElement(element)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse path element %q: %v", element, err)
+		}
+		path = append(path, pe)
+	}
+	return path, nil
+}
+
+// managedFieldsEntry is an entry of the managedFields of a Kubernetes object.
+type managedFieldsEntry struct {
+	Manager     string          `json:"manager"`
+	Operation   string          `json:"operation"`
+	APIVersion  string          `json:"apiVersion"`
+	Subresource string          `json:"subresource,omitempty"`
+	FieldsV1    json.RawMessage `json:"fieldsV1"`
+}
+
+// readManagedFields reads the managedFields entries from either a list of
+// entries or a whole object. Managers are named after the manager of the
+// entry, followed by its subresource if any.
+func readManagedFields(path string) (fieldpath.ManagedFields, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read file %q: %v", path, err)
+	}
+	var entries []managedFieldsEntry
+	if err := yaml.Unmarshal(b, &entries); err != nil {
+		var object struct {
+			Metadata struct {
+				ManagedFields []managedFieldsEntry `json:"managedFields"`
+			} `json:"metadata"`
+		}
+		if err := yaml.Unmarshal(b, &object); err != nil {
+			return nil, fmt.Errorf("unable to parse managed fields %q: %v", path, err)
+		}
+		entries = object.Metadata.ManagedFields
+	}
+
+	managers := fieldpath.ManagedFields{}
+	for _, entry := range entries {
+		name := fieldpath.SubresourceManager(entry.Manager, entry.Subresource)
+		if _, ok := managers[name]; ok {
+			return nil, fmt.Errorf("duplicate managed fields entry for %q", name)
+		}
+		set := &fieldpath.Set{}
+		if len(entry.FieldsV1) != 0 {
+			if err := set.FromJSON(bytes.NewReader(entry.FieldsV1)); err != nil {
+				return nil, fmt.Errorf("unable to parse fields of %q: %v", name, err)
+			}
+		}
+		managers[name] = fieldpath.NewSubresourceVersionedSet(set, fieldpath.APIVersion(entry.APIVersion), entry.Operation == "Apply", entry.Subresource)
+	}
+	return managers, nil
+}
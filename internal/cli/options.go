@@ -28,8 +28,9 @@ import (
 )
 
 var (
-	ErrTooManyOperations = errors.New("exactly one of --merge, --compare, --validate or --fieldset must be provided")
-	ErrNeedTwoArgs       = errors.New("--merge and --compare require both --lhs and --rhs")
+	ErrTooManyOperations    = errors.New("exactly one of --merge, --compare, --diff, --validate, --fieldset, --explain, --analyze-managed-fields or --co-owners must be provided")
+	ErrNeedTwoArgs          = errors.New("--merge, --compare and --diff require both --lhs and --rhs")
+	ErrNeedManagedFieldsArg = errors.New("--explain requires --managed-fields")
 )
 
 type Options struct {
@@ -37,17 +38,29 @@ type Options struct {
 	typeName   string
 
 	output string
+	format string
 
 	// options determining the operation to perform
 	listTypes    bool
 	validatePath string
 	merge        bool
 	compare      bool
+	diff         bool
 	fieldset     string
+	explain      string
 
-	// arguments for merge or compare
+	analyzeManagedFields string
+	coOwners             string
+
+	// arguments for merge, compare or diff
 	lhsPath string
 	rhsPath string
+
+	// arguments for diff
+	sideBySide bool
+
+	// arguments for explain
+	managedFieldsPath string
 }
 
 func (o *Options) AddFlags(fs *flag.FlagSet) {
@@ -55,6 +68,7 @@ func (o *Options) AddFlags(fs *flag.FlagSet) {
 	fs.StringVar(&o.typeName, "type-name", "", "Name of type in the schema to use. If empty, the first type in the schema will be used.")
 
 	fs.StringVar(&o.output, "output", "-", "Output location (if the command has output). '-' means stdout.")
+	fs.StringVar(&o.format, "format", "", "Output format: json, yaml, table, or color-diff for --merge, --compare and --diff. Defaults to a human-readable text.")
 
 	// The three supported operations. We could make these into subcommands
 	// and that would probably make more sense, but this is easy and this
@@ -64,15 +78,43 @@ func (o *Options) AddFlags(fs *flag.FlagSet) {
 	fs.StringVar(&o.validatePath, "validate", "", "Path to a file to perform a validation operation on.")
 	fs.BoolVar(&o.merge, "merge", false, "Perform a merge operation between --lhs and --rhs")
 	fs.BoolVar(&o.compare, "compare", false, "Perform a compare operation between --lhs and --rhs")
+	fs.BoolVar(&o.diff, "diff", false, "Show the differences between --lhs and --rhs, matching list items by key")
 	fs.StringVar(&o.fieldset, "fieldset", "", "Path to a file for which we should build a fieldset.")
+	fs.StringVar(&o.explain, "explain", "", `Field to explain the ownership of, as a JSON list of path elements in the managedFields format, e.g. '["f:spec","f:replicas"]'.`)
+
+	fs.StringVar(&o.analyzeManagedFields, "analyze-managed-fields", "", "Path to a file containing managedFields, as for --managed-fields, to report the size and overlap of the fields of each manager. Doesn't need a schema.")
+
+	fs.StringVar(&o.coOwners, "co-owners", "", "Path to a file containing managedFields, as for --managed-fields, to report the fields owned by several managers, by pairs of managers. Doesn't need a schema.")
 
 	fs.StringVar(&o.lhsPath, "lhs", "", "Path to a file containing the left hand side of the operation")
 	fs.StringVar(&o.rhsPath, "rhs", "", "Path to a file containing the right hand side of the operation")
+
+	fs.BoolVar(&o.sideBySide, "side-by-side", false, "Show the --diff in two columns rather than as a unified diff")
+
+	fs.StringVar(&o.managedFieldsPath, "managed-fields", "", "Path to a file containing either a list of managedFields entries, or an object with metadata.managedFields")
 }
 
 // resolve turns options in to an operation that can be executed.
 func (o *Options) Resolve() (Operation, error) {
 	var base operationBase
+	// Count how many operations were requested
+	c := map[bool]int{true: 1}
+	count := c[o.merge] + c[o.compare] + c[o.diff] + c[o.validatePath != ""] + c[o.listTypes] + c[o.fieldset != ""] + c[o.explain != ""] + c[o.analyzeManagedFields != ""] + c[o.coOwners != ""]
+	if count > 1 {
+		return nil, ErrTooManyOperations
+	}
+	format, err := parseOutputFormat(o.format)
+	if err != nil {
+		return nil, err
+	}
+	base.format = format
+	if o.analyzeManagedFields != "" {
+		return analyzeManagedFields{o.analyzeManagedFields, format}, nil
+	}
+	if o.coOwners != "" {
+		return coOwners{o.coOwners, format}, nil
+	}
+
 	if o.schemaPath == "" {
 		return nil, errors.New("a schema is required")
 	}
@@ -95,13 +137,6 @@ func (o *Options) Resolve() (Operation, error) {
 		base.typeName = o.typeName
 	}
 
-	// Count how many operations were requested
-	c := map[bool]int{true: 1}
-	count := c[o.merge] + c[o.compare] + c[o.validatePath != ""] + c[o.listTypes] + c[o.fieldset != ""]
-	if count > 1 {
-		return nil, ErrTooManyOperations
-	}
-
 	switch {
 	case o.listTypes:
 		return listTypes{base}, nil
@@ -117,8 +152,22 @@ func (o *Options) Resolve() (Operation, error) {
 			return nil, ErrNeedTwoArgs
 		}
 		return compare{base, o.lhsPath, o.rhsPath}, nil
+	case o.diff:
+		if o.lhsPath == "" || o.rhsPath == "" {
+			return nil, ErrNeedTwoArgs
+		}
+		return diff{base, o.lhsPath, o.rhsPath, o.sideBySide}, nil
 	case o.fieldset != "":
 		return fieldset{base, o.fieldset}, nil
+	case o.explain != "":
+		if o.managedFieldsPath == "" {
+			return nil, ErrNeedManagedFieldsArg
+		}
+		path, err := parsePath(o.explain)
+		if err != nil {
+			return nil, err
+		}
+		return explain{base, o.managedFieldsPath, path}, nil
 	}
 	return nil, errors.New("no operation requested")
 }
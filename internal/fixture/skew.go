@@ -0,0 +1,310 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fixture
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
+	"sigs.k8s.io/structured-merge-diff/v4/merge"
+	"sigs.k8s.io/structured-merge-diff/v4/schema"
+	"sigs.k8s.io/structured-merge-diff/v4/typed"
+	"sigs.k8s.io/structured-merge-diff/v4/value"
+	yaml "sigs.k8s.io/yaml/goyaml.v2"
+)
+
+// VersionSkew describes how the fields of an object move between versions.
+// Every version is converted to and from the hub version, whose fields
+// don't move. For example:
+//
+//	hub: v1
+//	versions:
+//	- version: v2
+//	  fields:
+//	  - from: spec.size
+//	    to: spec.replicas
+//
+// moves the "spec.replicas" field of v1 to "spec.size" in v2.
+type VersionSkew struct {
+	Hub      fieldpath.APIVersion `yaml:"hub"`
+	Versions []VersionFields      `yaml:"versions"`
+}
+
+// VersionFields lists the fields of a version which aren't where they are
+// in the hub version.
+type VersionFields struct {
+	Version fieldpath.APIVersion `yaml:"version"`
+	Fields  []FieldMove          `yaml:"fields"`
+}
+
+// FieldMove moves the field at From in a version to To in the hub version.
+// Both are field names separated by dots, e.g. "spec.replicas". Fields in
+// list items can't be moved.
+type FieldMove struct {
+	From string `yaml:"from"`
+	To   string `yaml:"to"`
+}
+
+// VersionSkewConverter is a merge.Converter which simulates the conversion
+// between versions described by a VersionSkew. The types of the parser are
+// named after the versions.
+type VersionSkewConverter struct {
+	parser Parser
+	moves  map[fieldpath.APIVersion][]FieldMove
+}
+
+var _ merge.Converter = &VersionSkewConverter{}
+var _ merge.SetConverter = &VersionSkewConverter{}
+
+var errMissingVersion = errors.New("cannot convert to unknown version")
+
+// NewVersionSkewConverter returns a converter for the VersionSkew in
+// mapping, in YAML.
+func NewVersionSkewConverter(parser Parser, mapping []byte) (*VersionSkewConverter, error) {
+	var skew VersionSkew
+	if err := yaml.UnmarshalStrict(mapping, &skew); err != nil {
+		return nil, fmt.Errorf("invalid version skew: %v", err)
+	}
+	if skew.Hub == "" {
+		return nil, errors.New("invalid version skew: missing hub version")
+	}
+	c := &VersionSkewConverter{
+		parser: parser,
+		moves:  map[fieldpath.APIVersion][]FieldMove{skew.Hub: nil},
+	}
+	for _, v := range skew.Versions {
+		if _, ok := c.moves[v.Version]; ok {
+			return nil, fmt.Errorf("invalid version skew: version %q is listed twice", v.Version)
+		}
+		for _, f := range v.Fields {
+			if f.From == "" || f.To == "" {
+				return nil, fmt.Errorf("invalid version skew: version %q moves a field from %q to %q", v.Version, f.From, f.To)
+			}
+		}
+		c.moves[v.Version] = v.Fields
+	}
+	return c, nil
+}
+
+// Convert implements merge.Converter.
+func (c *VersionSkewConverter) Convert(v *typed.TypedValue, version fieldpath.APIVersion) (*typed.TypedValue, error) {
+	if v.TypeRef().NamedType == nil {
+		return nil, errors.New("cannot convert object without a named type")
+	}
+	inVersion := fieldpath.APIVersion(*v.TypeRef().NamedType)
+	inMoves, ok := c.moves[inVersion]
+	if !ok {
+		return nil, fmt.Errorf("cannot convert from unknown version %q", inVersion)
+	}
+	outMoves, ok := c.moves[version]
+	if !ok {
+		return nil, errMissingVersion
+	}
+	obj := copyUnstructured(v.AsValue())
+	obj, err := moveFields(obj, inMoves, false)
+	if err != nil {
+		return nil, err
+	}
+	obj, err = moveFields(obj, outMoves, true)
+	if err != nil {
+		return nil, err
+	}
+	return c.parser.Type(string(version)).FromUnstructured(obj)
+}
+
+// IsMissingVersionError implements merge.Converter.
+func (c *VersionSkewConverter) IsMissingVersionError(err error) bool {
+	return err == errMissingVersion
+}
+
+// ConvertSet implements merge.SetConverter.
+func (c *VersionSkewConverter) ConvertSet(set *fieldpath.Set, from, to fieldpath.APIVersion) (*fieldpath.Set, error) {
+	inMoves, ok := c.moves[from]
+	if !ok {
+		// The fields of obsolete versions are dropped, like their managers.
+		return nil, errMissingVersion
+	}
+	outMoves, ok := c.moves[to]
+	if !ok {
+		return nil, errMissingVersion
+	}
+	// Paths which don't exist in the new version, like the parents of the
+	// fields moved away, are dropped.
+	pt := c.parser.Type(string(to))
+	out := fieldpath.NewSet()
+	set.Iterate(func(p fieldpath.Path) {
+		if p = movePath(movePath(p, inMoves, false), outMoves, true); inSchema(pt.Schema, pt.TypeRef, p) {
+			out.Insert(p)
+		}
+	})
+	return out, nil
+}
+
+// inSchema returns true if the type tr has a value at p.
+func inSchema(s *schema.Schema, tr schema.TypeRef, p fieldpath.Path) bool {
+	for _, pe := range p {
+		a, ok := s.Resolve(tr)
+		if !ok {
+			return false
+		}
+		if pe.FieldName == nil {
+			if a.List == nil {
+				return false
+			}
+			tr = a.List.ElementType
+			continue
+		}
+		if a.Map == nil {
+			return false
+		}
+		if f, ok := a.Map.FindField(*pe.FieldName); ok {
+			tr = f.Type
+		} else if (a.Map.ElementType != schema.TypeRef{}) {
+			tr = a.Map.ElementType
+		} else {
+			return false
+		}
+	}
+	return true
+}
+
+// movePath returns the path of the field at p once moved from From to To,
+// or the other way around if reverse is set.
+func movePath(p fieldpath.Path, moves []FieldMove, reverse bool) fieldpath.Path {
+	for _, move := range moves {
+		from, to := move.From, move.To
+		if reverse {
+			from, to = to, from
+		}
+		prefix := strings.Split(from, ".")
+		if len(p) < len(prefix) {
+			continue
+		}
+		matches := true
+		for i, name := range prefix {
+			if p[i].FieldName == nil || *p[i].FieldName != name {
+				matches = false
+				break
+			}
+		}
+		if !matches {
+			continue
+		}
+		var moved fieldpath.Path
+		for _, name := range strings.Split(to, ".") {
+			name := name
+			moved = append(moved, fieldpath.PathElement{FieldName: &name})
+		}
+		return append(moved, p[len(prefix):]...)
+	}
+	return p
+}
+
+// copyUnstructured returns a copy of v which can be modified.
+func copyUnstructured(v value.Value) interface{} {
+	switch {
+	case v.IsMap():
+		out := map[string]interface{}{}
+		v.AsMap().Iterate(func(key string, value value.Value) bool {
+			out[key] = copyUnstructured(value)
+			return true
+		})
+		return out
+	case v.IsList():
+		out := []interface{}{}
+		ri := v.AsList().Range()
+		for ri.Next() {
+			_, item := ri.Item()
+			out = append(out, copyUnstructured(item))
+		}
+		return out
+	default:
+		return v.Unstructured()
+	}
+}
+
+// moveFields moves the fields of obj from From to To, or the other way
+// around if reverse is set. All fields are removed before any is set, so
+// that fields can be swapped.
+func moveFields(obj interface{}, moves []FieldMove, reverse bool) (interface{}, error) {
+	m, ok := obj.(map[string]interface{})
+	if !ok {
+		return obj, nil
+	}
+	values := make([]interface{}, len(moves))
+	found := make([]bool, len(moves))
+	for i, move := range moves {
+		from := move.From
+		if reverse {
+			from = move.To
+		}
+		values[i], found[i] = removeField(m, strings.Split(from, "."))
+	}
+	for i, move := range moves {
+		if !found[i] {
+			continue
+		}
+		to := move.To
+		if reverse {
+			to = move.From
+		}
+		if err := setField(m, strings.Split(to, "."), values[i]); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// removeField removes the field at path from m, as well as the maps it
+// leaves empty.
+func removeField(m map[string]interface{}, path []string) (interface{}, bool) {
+	v, ok := m[path[0]]
+	if !ok {
+		return nil, false
+	}
+	if len(path) == 1 {
+		delete(m, path[0])
+		return v, true
+	}
+	child, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+	v, ok = removeField(child, path[1:])
+	if ok && len(child) == 0 {
+		delete(m, path[0])
+	}
+	return v, ok
+}
+
+// setField sets the field at path of m, creating the missing maps.
+func setField(m map[string]interface{}, path []string, v interface{}) error {
+	for i, name := range path[:len(path)-1] {
+		child, ok := m[name]
+		if !ok || child == nil {
+			child = map[string]interface{}{}
+			m[name] = child
+		}
+		if m, ok = child.(map[string]interface{}); !ok {
+			return fmt.Errorf("cannot move field to %q: %q is not a map", strings.Join(path, "."), strings.Join(path[:i+1], "."))
+		}
+	}
+	m[path[len(path)-1]] = v
+	return nil
+}
@@ -24,6 +24,7 @@ import (
 
 	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
 	"sigs.k8s.io/structured-merge-diff/v4/merge"
+	"sigs.k8s.io/structured-merge-diff/v4/schema"
 	"sigs.k8s.io/structured-merge-diff/v4/typed"
 )
 
@@ -544,6 +545,35 @@ type TestCase struct {
 	// IgnoredFields containing the set to ignore for every version.
 	// IgnoredFields may not be set if IgnoreFilter is set.
 	IgnoredFields map[fieldpath.APIVersion]*fieldpath.Set
+
+	// MergeTracer, if set, is notified of the merge decisions of every
+	// Apply operation.
+	MergeTracer typed.MergeTracer
+
+	// Ownership is the Ownership of the sets recorded by the Updater.
+	Ownership fieldpath.Ownership
+
+	// Ignored is the set of fields ignored in every version.
+	Ignored *fieldpath.Set
+
+	// FloatTolerance is how much numeric fields can change without being
+	// modified.
+	FloatTolerance float64
+
+	// StringNormalizers normalize the string fields before they are
+	// compared.
+	StringNormalizers []typed.StringNormalizer
+
+	// NullPolicy is what explicit nulls of applied configurations mean.
+	NullPolicy schema.NullPolicy
+
+	// EmptyCollections defines which of empty, null and absent maps and
+	// lists are equivalent.
+	EmptyCollections typed.EmptyCollectionPolicy
+
+	// ShareValues makes applied objects share the parts of the live
+	// object which the configuration doesn't set.
+	ShareValues bool
 }
 
 // Test runs the test-case using the given parser and a dummy converter.
@@ -579,6 +609,14 @@ func (tc TestCase) BenchWithConverter(parser Parser, converter merge.Converter)
 		IgnoreFilter:      tc.IgnoreFilter,
 		IgnoredFields:     tc.IgnoredFields,
 		ReturnInputOnNoop: tc.ReturnInputOnNoop,
+		MergeTracer:       tc.MergeTracer,
+		Ownership:         tc.Ownership,
+		Ignored:           tc.Ignored,
+		FloatTolerance:    tc.FloatTolerance,
+		StringNormalizers: tc.StringNormalizers,
+		NullPolicy:        tc.NullPolicy,
+		EmptyCollections:  tc.EmptyCollections,
+		ShareValues:       tc.ShareValues,
 	}
 	state := State{
 		Updater: updaterBuilder.BuildUpdater(),
@@ -602,6 +640,14 @@ func (tc TestCase) TestWithConverter(parser Parser, converter merge.Converter) e
 		IgnoreFilter:      tc.IgnoreFilter,
 		IgnoredFields:     tc.IgnoredFields,
 		ReturnInputOnNoop: tc.ReturnInputOnNoop,
+		MergeTracer:       tc.MergeTracer,
+		Ownership:         tc.Ownership,
+		Ignored:           tc.Ignored,
+		FloatTolerance:    tc.FloatTolerance,
+		StringNormalizers: tc.StringNormalizers,
+		NullPolicy:        tc.NullPolicy,
+		EmptyCollections:  tc.EmptyCollections,
+		ShareValues:       tc.ShareValues,
 	}
 	state := State{
 		Updater: updaterBuilder.BuildUpdater(),
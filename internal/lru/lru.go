@@ -0,0 +1,76 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package lru implements a bounded cache evicting the least recently used
+// entries.
+package lru
+
+import "container/list"
+
+// Cache is a cache of at most a given number of entries, evicting the least
+// recently used ones. It isn't safe for concurrent use.
+type Cache struct {
+	maxEntries int
+	entries    *list.List
+	elements   map[interface{}]*list.Element
+}
+
+type entry struct {
+	key, value interface{}
+}
+
+// New returns a cache of at most maxEntries entries, or of an unbounded
+// number of entries if it isn't positive.
+func New(maxEntries int) *Cache {
+	return &Cache{
+		maxEntries: maxEntries,
+		entries:    list.New(),
+		elements:   map[interface{}]*list.Element{},
+	}
+}
+
+// Get returns the value of key, if any, and marks it as recently used.
+func (c *Cache) Get(key interface{}) (interface{}, bool) {
+	e, ok := c.elements[key]
+	if !ok {
+		return nil, false
+	}
+	c.entries.MoveToFront(e)
+	return e.Value.(*entry).value, true
+}
+
+// Add sets the value of key, and returns the number of entries evicted to
+// make room for it.
+func (c *Cache) Add(key, value interface{}) (evicted int) {
+	if e, ok := c.elements[key]; ok {
+		c.entries.MoveToFront(e)
+		e.Value.(*entry).value = value
+		return 0
+	}
+	c.elements[key] = c.entries.PushFront(&entry{key: key, value: value})
+	for c.maxEntries > 0 && c.entries.Len() > c.maxEntries {
+		oldest := c.entries.Back()
+		c.entries.Remove(oldest)
+		delete(c.elements, oldest.Value.(*entry).key)
+		evicted++
+	}
+	return evicted
+}
+
+// Len returns the number of entries.
+func (c *Cache) Len() int {
+	return c.entries.Len()
+}
@@ -0,0 +1,45 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lru
+
+import "testing"
+
+func TestCache(t *testing.T) {
+	c := New(2)
+	if evicted := c.Add("a", 1) + c.Add("b", 2); evicted != 0 {
+		t.Fatalf("expected no eviction, got %v", evicted)
+	}
+	if v, ok := c.Get("a"); !ok || v != 1 {
+		t.Fatalf("expected a to be 1, got %v, %v", v, ok)
+	}
+	// b is the least recently used.
+	if evicted := c.Add("c", 3); evicted != 1 {
+		t.Fatalf("expected one eviction, got %v", evicted)
+	}
+	if _, ok := c.Get("b"); ok {
+		t.Error("expected b to be evicted")
+	}
+	if evicted := c.Add("a", 4); evicted != 0 {
+		t.Fatalf("expected no eviction when updating, got %v", evicted)
+	}
+	if v, ok := c.Get("a"); !ok || v != 4 {
+		t.Errorf("expected a to be 4, got %v, %v", v, ok)
+	}
+	if c.Len() != 2 {
+		t.Errorf("expected 2 entries, got %v", c.Len())
+	}
+}
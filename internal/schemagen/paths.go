@@ -0,0 +1,222 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package schemagen
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/token"
+	"strings"
+	"unicode"
+
+	"sigs.k8s.io/structured-merge-diff/v4/schema"
+)
+
+// pathsHelpers are the functions the generated methods build the paths
+// with. They copy the paths they extend, so that paths can be shared.
+const pathsHelpers = `
+func withField(p fieldpath.Path, name string) fieldpath.Path {
+	return append(p.Copy(), fieldpath.PathElement{FieldName: &name})
+}
+
+func withKey(p fieldpath.Path, name string, nameValues ...interface{}) fieldpath.Path {
+	return append(withField(p, name), fieldpath.PathElement{Key: fieldpath.KeyByFields(nameValues...)})
+}
+
+func withValue(p fieldpath.Path, name string, v interface{}) fieldpath.Path {
+	val := value.NewValueInterface(v)
+	return append(withField(p, name), fieldpath.PathElement{Value: &val})
+}
+`
+
+// WritePaths returns the source of a Go file of package pkg declaring
+// the types of the paths of the struct types of s, e.g. for a pod type:
+//
+//	PodPath{}.Spec().Containers("app").Image()
+//
+// returns the fieldpath.Path .spec.containers[name="app"].image. Each
+// struct type gets a <Type>Path type, whose zero value is the root, with
+// a method per field:
+//   - fields of struct types return the paths of these types;
+//   - associative lists, sets and granular maps take the keys of their
+//     items and return the path of the item, the path of the field itself
+//     is returned by the <Field>Field method;
+//   - other fields, and the atomic ones, return a fieldpath.Path.
+//
+// The file declares unexported helpers, there must be one per package.
+func WritePaths(pkg string, s *schema.Schema) ([]byte, error) {
+	w := pathsWriter{s: s}
+	fmt.Fprintf(&w.b, "// Code generated by schemagen. DO NOT EDIT.\n\npackage %v\n\n", pkg)
+	fmt.Fprintf(&w.b, "import (\n\t%q\n\t%q\n)\n", "sigs.k8s.io/structured-merge-diff/v4/fieldpath", "sigs.k8s.io/structured-merge-diff/v4/value")
+	for _, t := range s.Types {
+		if w.isStruct(schema.Named(t.Name)) {
+			w.writeType(t)
+		}
+	}
+	if len(w.errs) > 0 {
+		return nil, fmt.Errorf("%v", strings.Join(w.errs, "; "))
+	}
+	w.b.WriteString(pathsHelpers)
+	return format.Source(w.b.Bytes())
+}
+
+type pathsWriter struct {
+	s    *schema.Schema
+	b    bytes.Buffer
+	errs []string
+}
+
+// isStruct returns true if t is a named type with fields, which isn't
+// atomic, and so gets a path type.
+func (w *pathsWriter) isStruct(t schema.TypeRef) bool {
+	if t.NamedType == nil {
+		return false
+	}
+	a, ok := w.s.Resolve(t)
+	return ok && a.Map != nil && len(a.Map.Fields) > 0 && a.Map.ElementRelationship != schema.Atomic
+}
+
+// pathType returns the Go type of the path of a value of type t.
+func (w *pathsWriter) pathType(t schema.TypeRef) string {
+	if w.isStruct(t) {
+		return goName(*t.NamedType) + "Path"
+	}
+	return "fieldpath.Path"
+}
+
+// wrap returns the Go expression of the path expr, of type pathType.
+func wrap(pathType, expr string) string {
+	if pathType == "fieldpath.Path" {
+		return expr
+	}
+	return fmt.Sprintf("%v{%v}", pathType, expr)
+}
+
+func (w *pathsWriter) writeType(t schema.TypeDef) {
+	name := goName(t.Name) + "Path"
+	fmt.Fprintf(&w.b, "\n// %v is the path of a value of type %v, its zero value is the root.\n", name, t.Name)
+	fmt.Fprintf(&w.b, "type %v struct{ path fieldpath.Path }\n", name)
+	fmt.Fprintf(&w.b, "\n// Path returns the path.\nfunc (p %v) Path() fieldpath.Path { return p.path }\n", name)
+	methods := map[string]bool{"Path": true}
+	method := func(m string) string {
+		if methods[m] {
+			w.errs = append(w.errs, fmt.Sprintf("type %v: more than one method named %v", t.Name, m))
+		}
+		methods[m] = true
+		return m
+	}
+	for _, f := range t.Map.Fields {
+		m := goName(f.Name)
+		a, ok := w.s.Resolve(f.Type)
+		if !ok {
+			w.errs = append(w.errs, fmt.Sprintf("type %v: field %v: unknown type", t.Name, f.Name))
+			continue
+		}
+		var elem schema.TypeRef
+		var params []string
+		var expr string
+		switch {
+		case a.List != nil && a.List.ElementRelationship == schema.Associative && len(a.List.Keys) > 0:
+			elem = a.List.ElementType
+			var nameValues []string
+			for _, key := range a.List.Keys {
+				param := paramName(key)
+				params = append(params, fmt.Sprintf("%v %v", param, w.keyType(elem, key)))
+				nameValues = append(nameValues, fmt.Sprintf("%q, %v", key, param))
+			}
+			expr = fmt.Sprintf("withKey(p.path, %q, %v)", f.Name, strings.Join(nameValues, ", "))
+		case a.List != nil && a.List.ElementRelationship == schema.Associative:
+			elem = a.List.ElementType
+			params = []string{"v " + w.goType(elem)}
+			expr = fmt.Sprintf("withValue(p.path, %q, v)", f.Name)
+		case a.Map != nil && len(a.Map.Fields) == 0 && a.Map.ElementRelationship != schema.Atomic && a.Map.ElementType != (schema.TypeRef{}):
+			elem = a.Map.ElementType
+			params = []string{"key string"}
+			expr = fmt.Sprintf("withField(withField(p.path, %q), key)", f.Name)
+		default:
+			pt := w.pathType(f.Type)
+			fmt.Fprintf(&w.b, "\n// %v returns the path of the %v field.\n", method(m), f.Name)
+			fmt.Fprintf(&w.b, "func (p %v) %v() %v { return %v }\n", name, m, pt, wrap(pt, fmt.Sprintf("withField(p.path, %q)", f.Name)))
+			continue
+		}
+		pt := w.pathType(elem)
+		fmt.Fprintf(&w.b, "\n// %v returns the path of the item of the %v field with the given key.\n", method(m), f.Name)
+		fmt.Fprintf(&w.b, "func (p %v) %v(%v) %v { return %v }\n", name, m, strings.Join(params, ", "), pt, wrap(pt, expr))
+		fmt.Fprintf(&w.b, "\n// %v returns the path of the %v field.\n", method(m+"Field"), f.Name)
+		fmt.Fprintf(&w.b, "func (p %v) %vField() fieldpath.Path { return withField(p.path, %q) }\n", name, m, f.Name)
+	}
+}
+
+// keyType returns the Go type of the key field of the items of type elem.
+func (w *pathsWriter) keyType(elem schema.TypeRef, key string) string {
+	a, ok := w.s.Resolve(elem)
+	if !ok || a.Map == nil {
+		return "interface{}"
+	}
+	f, ok := a.Map.FindField(key)
+	if !ok {
+		return "interface{}"
+	}
+	return w.goType(f.Type)
+}
+
+// goType returns the Go type of the scalars of type t.
+func (w *pathsWriter) goType(t schema.TypeRef) string {
+	a, ok := w.s.Resolve(t)
+	if !ok || a.Scalar == nil || a.List != nil || a.Map != nil {
+		return "interface{}"
+	}
+	switch *a.Scalar {
+	case schema.String:
+		return "string"
+	case schema.Boolean:
+		return "bool"
+	case schema.Numeric:
+		return "int64"
+	}
+	return "interface{}"
+}
+
+// goName returns the exported Go name of the type or field name, e.g.
+// IoK8sApiCoreV1Pod for io.k8s.api.core.v1.Pod.
+func goName(name string) string {
+	parts := strings.FieldsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	var b strings.Builder
+	for _, part := range parts {
+		r := []rune(part)
+		r[0] = unicode.ToUpper(r[0])
+		b.WriteString(string(r))
+	}
+	if b.Len() == 0 || unicode.IsDigit([]rune(b.String())[0]) {
+		return "X" + b.String()
+	}
+	return b.String()
+}
+
+// paramName returns the name of the parameter of the key field name.
+func paramName(name string) string {
+	r := []rune(goName(name))
+	r[0] = unicode.ToLower(r[0])
+	param := string(r)
+	if token.Lookup(param).IsKeyword() || param == "p" {
+		return param + "Key"
+	}
+	return param
+}
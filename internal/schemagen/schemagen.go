@@ -0,0 +1,456 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package schemagen generates merge schemas from the Go types of a package,
+// see Generate.
+package schemagen
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"sigs.k8s.io/structured-merge-diff/v4/schema"
+	yaml "sigs.k8s.io/yaml/goyaml.v2"
+)
+
+// UntypedAtomicName is the name of the type given to the values of unknown
+// types, e.g. interface{} fields or types of other packages: they can be of
+// any kind, and are owned and replaced as a whole.
+const UntypedAtomicName = "__untyped_atomic_"
+
+// knownTypes are the types of other packages which are serialized as
+// scalars, or as arbitrary values.
+var knownTypes = map[string]schema.Scalar{
+	"time.Time":                schema.String,
+	"time.Duration":            schema.Numeric,
+	"encoding/json.RawMessage": schema.Untyped,
+	"k8s.io/apimachinery/pkg/apis/meta/v1.Time":       schema.String,
+	"k8s.io/apimachinery/pkg/apis/meta/v1.MicroTime":  schema.String,
+	"k8s.io/apimachinery/pkg/apis/meta/v1.Duration":   schema.String,
+	"k8s.io/apimachinery/pkg/api/resource.Quantity":   schema.NumericOrString,
+	"k8s.io/apimachinery/pkg/util/intstr.IntOrString": schema.NumericOrString,
+	"k8s.io/apimachinery/pkg/runtime.RawExtension":    schema.Untyped,
+}
+
+// typeMetaName is the type of the apiVersion and kind fields of the
+// Kubernetes objects, which is usually embedded.
+const typeMetaName = "k8s.io/apimachinery/pkg/apis/meta/v1.TypeMeta"
+
+// Generate returns the schema of the Go types named roots, which are
+// structs declared in the package in dir, and of the types of the package
+// they use. The types are named after their Go names.
+//
+// Fields are named after their json tags, like encoding/json does, and
+// embedded structs without names are inlined. Their types follow the Go
+// types, changed by the markers of their comments, as in Kubernetes:
+//   - slices are atomic lists, unless marked +listType=set (for scalars)
+//     or +listType=map with +listMapKey markers naming their keys (for
+//     structs). The +patchStrategy=merge and +patchMergeKey markers, or
+//     struct tags, are understood too;
+//   - maps must have string keys, and are granular unless marked
+//     +mapType=atomic;
+//   - structs are granular unless they or the fields of their type are
+//     marked +structType=atomic;
+//   - []byte is a string, interface{} and the types of other packages are
+//     untyped and atomic (see UntypedAtomicName), except a few known
+//     Kubernetes and standard types.
+//
+// The markers of named types apply where they are used, those of the
+// fields take precedence.
+func Generate(dir string, roots []string) (*schema.Schema, error) {
+	g := generator{
+		types:   map[string]*typeSpec{},
+		defined: map[string]bool{},
+		b:       schema.NewBuilder(),
+	}
+	if err := g.load(dir); err != nil {
+		return nil, err
+	}
+	for _, root := range roots {
+		spec, ok := g.types[root]
+		if !ok {
+			return nil, fmt.Errorf("type %v isn't declared in %v", root, dir)
+		}
+		if _, ok := spec.Type.(*ast.StructType); !ok {
+			return nil, fmt.Errorf("type %v isn't a struct", root)
+		}
+		g.use(root)
+	}
+	for len(g.queue) > 0 {
+		name := g.queue[0]
+		g.queue = g.queue[1:]
+		g.define(name)
+	}
+	if len(g.errs) > 0 {
+		return nil, fmt.Errorf("%v", strings.Join(g.errs, "; "))
+	}
+	if g.untyped {
+		untyped := schema.Untyped
+		g.b.Type(UntypedAtomicName, schema.TypeRef{Inlined: schema.Atom{
+			Scalar: &untyped,
+			List:   &schema.List{ElementType: schema.Named(UntypedAtomicName), ElementRelationship: schema.Atomic},
+			Map:    &schema.Map{ElementType: schema.Named(UntypedAtomicName), ElementRelationship: schema.Atomic},
+		}})
+	}
+	return g.b.Build()
+}
+
+// WriteGo returns the source of a Go file of package pkg declaring a
+// constant named name, holding s as YAML, for typed.NewParser.
+func WriteGo(pkg, name string, s *schema.Schema) ([]byte, error) {
+	out, err := yaml.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Code generated by schemagen. DO NOT EDIT.\n\npackage %v\n\n", pkg)
+	fmt.Fprintf(&b, "// %v is the merge schema of the types of the package, see typed.NewParser.\n", name)
+	fmt.Fprintf(&b, "const %v = `%s`\n", name, out)
+	return format.Source(b.Bytes())
+}
+
+// typeSpec is a type declared in the package, with the file which declares
+// it, to resolve the packages of the types it uses.
+type typeSpec struct {
+	*ast.TypeSpec
+	doc  *ast.CommentGroup
+	file *ast.File
+}
+
+type generator struct {
+	types map[string]*typeSpec
+	// defined are the struct types which are, or will be, defined, and
+	// queue the ones which remain to be.
+	defined map[string]bool
+	queue   []string
+	// untyped is set once UntypedAtomicName is used.
+	untyped bool
+
+	b    *schema.Builder
+	errs []string
+}
+
+func (g *generator) errorf(format string, args ...interface{}) {
+	g.errs = append(g.errs, fmt.Sprintf(format, args...))
+}
+
+// load parses the type declarations of the package in dir.
+func (g *generator) load(dir string) error {
+	files, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return err
+	}
+	fset := token.NewFileSet()
+	for _, path := range files {
+		if strings.HasSuffix(path, "_test.go") {
+			continue
+		}
+		f, err := parser.ParseFile(fset, path, nil, parser.ParseComments)
+		if err != nil {
+			return err
+		}
+		for _, decl := range f.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				ts := spec.(*ast.TypeSpec)
+				doc := ts.Doc
+				if doc == nil && len(gen.Specs) == 1 {
+					doc = gen.Doc
+				}
+				g.types[ts.Name.Name] = &typeSpec{TypeSpec: ts, doc: doc, file: f}
+			}
+		}
+	}
+	return nil
+}
+
+// use returns a reference to the struct type name, which is defined once.
+func (g *generator) use(name string) schema.TypeRef {
+	if !g.defined[name] {
+		g.defined[name] = true
+		g.queue = append(g.queue, name)
+	}
+	return schema.Named(name)
+}
+
+// define defines the struct type name.
+func (g *generator) define(name string) {
+	spec := g.types[name]
+	sb := g.b.Struct(name)
+	if parseMarkers(spec.doc).get("structType") == "atomic" {
+		sb.Atomic()
+	}
+	g.addFields(sb, name, spec, spec.Type.(*ast.StructType))
+}
+
+// addFields adds the fields of st, declared by spec, to sb.
+func (g *generator) addFields(sb *schema.StructBuilder, at string, spec *typeSpec, st *ast.StructType) {
+	for _, field := range st.Fields.List {
+		tag := structTag(field).Get("json")
+		name := strings.Split(tag, ",")[0]
+		if name == "-" && tag == "-" {
+			continue
+		}
+		if len(field.Names) == 0 {
+			if name == "" {
+				g.inline(sb, at, spec, field.Type)
+				continue
+			}
+			g.addField(sb, at, spec, name, field)
+			continue
+		}
+		for _, ident := range field.Names {
+			if !ident.IsExported() {
+				continue
+			}
+			fieldName := name
+			if fieldName == "" {
+				fieldName = ident.Name
+			}
+			g.addField(sb, at, spec, fieldName, field)
+		}
+	}
+}
+
+func (g *generator) addField(sb *schema.StructBuilder, at string, spec *typeSpec, name string, field *ast.Field) {
+	m := parseMarkers(field.Doc).with(parseMarkers(field.Comment))
+	// The patch tags of the Kubernetes types are markers too.
+	tag := structTag(field)
+	for _, name := range []string{"patchStrategy", "patchMergeKey"} {
+		if v := tag.Get(name); v != "" && m.get(name) == "" {
+			m[name] = []string{v}
+		}
+	}
+	sb.Field(name, g.typeRef(fmt.Sprintf("%v.%v", at, name), spec.file, field.Type, m))
+}
+
+func structTag(field *ast.Field) reflect.StructTag {
+	if field.Tag == nil {
+		return ""
+	}
+	t, err := strconv.Unquote(field.Tag.Value)
+	if err != nil {
+		return ""
+	}
+	return reflect.StructTag(t)
+}
+
+// inline adds the fields of the embedded struct t to sb.
+func (g *generator) inline(sb *schema.StructBuilder, at string, spec *typeSpec, t ast.Expr) {
+	if star, ok := t.(*ast.StarExpr); ok {
+		t = star.X
+	}
+	switch t := t.(type) {
+	case *ast.Ident:
+		embedded, ok := g.types[t.Name]
+		if !ok {
+			g.errorf("%v: unknown embedded type %v", at, t.Name)
+			return
+		}
+		st, ok := embedded.Type.(*ast.StructType)
+		if !ok {
+			g.errorf("%v: embedded type %v isn't a struct", at, t.Name)
+			return
+		}
+		g.addFields(sb, at, embedded, st)
+	case *ast.SelectorExpr:
+		if qualifiedName(spec.file, t) == typeMetaName {
+			sb.Field("apiVersion", schema.ScalarType(schema.String))
+			sb.Field("kind", schema.ScalarType(schema.String))
+			return
+		}
+		g.errorf("%v: can't inline the fields of %v", at, qualifiedName(spec.file, t))
+	default:
+		g.errorf("%v: embedded field isn't a struct", at)
+	}
+}
+
+// typeRef returns the type of a field of type t, declared in file, with
+// markers m.
+func (g *generator) typeRef(at string, file *ast.File, t ast.Expr, m markers) schema.TypeRef {
+	switch t := t.(type) {
+	case *ast.StarExpr:
+		return g.typeRef(at, file, t.X, m)
+	case *ast.Ident:
+		switch t.Name {
+		case "string":
+			return schema.ScalarType(schema.String)
+		case "bool":
+			return schema.ScalarType(schema.Boolean)
+		case "int", "int8", "int16", "int32", "int64", "uint", "uint8", "uint16", "uint32", "uint64", "uintptr", "float32", "float64", "byte", "rune":
+			return schema.ScalarType(schema.Numeric)
+		case "any":
+			return g.untypedAtomic()
+		}
+		spec, ok := g.types[t.Name]
+		if !ok {
+			g.errorf("%v: unknown type %v", at, t.Name)
+			return schema.ScalarType(schema.Untyped)
+		}
+		if _, ok := spec.Type.(*ast.StructType); ok {
+			ref := g.use(t.Name)
+			if m.get("structType") == "atomic" {
+				atomic := schema.Atomic
+				ref.ElementRelationship = &atomic
+			}
+			return ref
+		}
+		// Other named types are inlined, with their markers.
+		return g.typeRef(at, spec.file, spec.Type, parseMarkers(spec.doc).with(m))
+	case *ast.ArrayType:
+		if elt, ok := t.Elt.(*ast.Ident); ok && elt.Name == "byte" && t.Len == nil {
+			return schema.ScalarType(schema.String)
+		}
+		return g.listType(at, file, t, m)
+	case *ast.MapType:
+		if key := g.typeRef(at, file, t.Key, nil); key.Inlined.Scalar == nil || *key.Inlined.Scalar != schema.String {
+			g.errorf("%v: map keys must be strings", at)
+		}
+		elem := g.typeRef(at+"[]", file, t.Value, nil)
+		if m.get("mapType") == "atomic" {
+			return schema.AtomicMapOf(elem)
+		}
+		return schema.MapOf(elem)
+	case *ast.InterfaceType:
+		return g.untypedAtomic()
+	case *ast.SelectorExpr:
+		name := qualifiedName(file, t)
+		scalar, ok := knownTypes[name]
+		if !ok || scalar == schema.Untyped {
+			return g.untypedAtomic()
+		}
+		return schema.ScalarType(scalar)
+	}
+	g.errorf("%v: unsupported type %T", at, t)
+	return schema.ScalarType(schema.Untyped)
+}
+
+func (g *generator) listType(at string, file *ast.File, t *ast.ArrayType, m markers) schema.TypeRef {
+	elem := g.typeRef(at+"[]", file, t.Elt, nil)
+	listType := m.get("listType")
+	keys := m.all("listMapKey")
+	if listType == "" && m.get("patchStrategy") != "" {
+		for _, strategy := range strings.Split(m.get("patchStrategy"), ",") {
+			if strategy != "merge" {
+				continue
+			}
+			listType = "set"
+			if key := m.get("patchMergeKey"); key != "" {
+				listType = "map"
+				keys = []string{key}
+			}
+		}
+	}
+	switch listType {
+	case "", "atomic":
+		return schema.AtomicList(elem)
+	case "set":
+		return schema.SetOf(elem)
+	case "map":
+		if len(keys) == 0 {
+			g.errorf("%v: +listType=map requires +listMapKey", at)
+			return schema.AtomicList(elem)
+		}
+		return schema.AssociativeList(elem, keys[0], keys[1:]...)
+	}
+	g.errorf("%v: unknown +listType=%v", at, listType)
+	return schema.AtomicList(elem)
+}
+
+func (g *generator) untypedAtomic() schema.TypeRef {
+	g.untyped = true
+	return schema.Named(UntypedAtomicName)
+}
+
+// qualifiedName returns the import path and name of t, used in file.
+func qualifiedName(file *ast.File, t *ast.SelectorExpr) string {
+	pkg, ok := t.X.(*ast.Ident)
+	if !ok {
+		return t.Sel.Name
+	}
+	for _, imp := range file.Imports {
+		path, err := strconv.Unquote(imp.Path.Value)
+		if err != nil {
+			continue
+		}
+		name := filepath.Base(path)
+		if imp.Name != nil {
+			name = imp.Name.Name
+		}
+		if name == pkg.Name {
+			return path + "." + t.Sel.Name
+		}
+	}
+	return pkg.Name + "." + t.Sel.Name
+}
+
+// markers are the +name=value markers of a comment, in order.
+type markers map[string][]string
+
+func parseMarkers(cg *ast.CommentGroup) markers {
+	m := markers{}
+	if cg == nil {
+		return m
+	}
+	for _, c := range cg.List {
+		line := strings.TrimSpace(strings.TrimPrefix(c.Text, "//"))
+		if !strings.HasPrefix(line, "+") {
+			continue
+		}
+		kv := strings.SplitN(line[1:], "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		m[kv[0]] = append(m[kv[0]], kv[1])
+	}
+	return m
+}
+
+// with returns the markers of m, overridden by those of o.
+func (m markers) with(o markers) markers {
+	out := markers{}
+	for k, v := range m {
+		out[k] = v
+	}
+	for k, v := range o {
+		out[k] = v
+	}
+	return out
+}
+
+// get returns the last value of the marker name, or "".
+func (m markers) get(name string) string {
+	if v := m[name]; len(v) > 0 {
+		return v[len(v)-1]
+	}
+	return ""
+}
+
+// all returns the values of the marker name.
+func (m markers) all(name string) []string {
+	return m[name]
+}
@@ -0,0 +1,291 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package schemagen
+
+import (
+	"strings"
+	"testing"
+
+	"sigs.k8s.io/structured-merge-diff/v4/schema"
+	yaml "sigs.k8s.io/yaml/goyaml.v2"
+)
+
+func TestGenerate(t *testing.T) {
+	s, err := Generate("testdata", []string{"Widget"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := yaml.Marshal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `types:
+- name: Widget
+  map:
+    fields:
+    - name: apiVersion
+      type:
+        scalar: string
+    - name: kind
+      type:
+        scalar: string
+    - name: metadata
+      type:
+        map:
+          elementType:
+            namedType: __untyped_atomic_
+    - name: spec
+      type:
+        namedType: WidgetSpec
+    - name: status
+      type:
+        namedType: WidgetStatus
+- name: WidgetSpec
+  map:
+    fields:
+    - name: replicas
+      type:
+        scalar: numeric
+    - name: ports
+      type:
+        list:
+          elementType:
+            namedType: Port
+          elementRelationship: associative
+          keys:
+          - name
+          - protocol
+    - name: finalizers
+      type:
+        list:
+          elementType:
+            scalar: string
+          elementRelationship: associative
+    - name: args
+      type:
+        list:
+          elementType:
+            scalar: string
+          elementRelationship: atomic
+    - name: selector
+      type:
+        map:
+          elementType:
+            scalar: string
+          elementRelationship: atomic
+    - name: labels
+      type:
+        map:
+          elementType:
+            scalar: string
+    - name: env
+      type:
+        list:
+          elementType:
+            namedType: EnvVar
+          elementRelationship: associative
+          keys:
+          - name
+    - name: color
+      type:
+        namedType: Color
+    - name: border
+      type:
+        namedType: Border
+        elementRelationship: atomic
+    - name: phase
+      type:
+        scalar: string
+    - name: names
+      type:
+        list:
+          elementType:
+            scalar: string
+          elementRelationship: associative
+    - name: data
+      type:
+        scalar: string
+    - name: config
+      type:
+        namedType: __untyped_atomic_
+    - name: port
+      type:
+        scalar: numericOrString
+    - name: since
+      type:
+        scalar: string
+    - name: Description
+      type:
+        scalar: string
+- name: WidgetStatus
+  map:
+    fields:
+    - name: ready
+      type:
+        scalar: boolean
+- name: Port
+  map:
+    fields:
+    - name: name
+      type:
+        scalar: string
+    - name: protocol
+      type:
+        scalar: string
+- name: EnvVar
+  map:
+    fields:
+    - name: name
+      type:
+        scalar: string
+    - name: value
+      type:
+        scalar: string
+- name: Color
+  map:
+    fields:
+    - name: R
+      type:
+        scalar: numeric
+    - name: G
+      type:
+        scalar: numeric
+    - name: B
+      type:
+        scalar: numeric
+    elementRelationship: atomic
+- name: Border
+  map:
+    fields:
+    - name: width
+      type:
+        scalar: numeric
+- name: __untyped_atomic_
+  scalar: untyped
+  list:
+    elementType:
+      namedType: __untyped_atomic_
+    elementRelationship: atomic
+  map:
+    elementType:
+      namedType: __untyped_atomic_
+    elementRelationship: atomic
+`
+	if string(out) != expected {
+		t.Errorf("expected:\n%v\ngot:\n%v", expected, string(out))
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		roots []string
+		err   string
+	}{{
+		name:  "unknown type",
+		roots: []string{"Gadget"},
+		err:   "type Gadget isn't declared in testdata",
+	}, {
+		name:  "not a struct",
+		roots: []string{"Phase"},
+		err:   "type Phase isn't a struct",
+	}}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Generate("testdata", tt.roots)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("expected error %q, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestWriteGo(t *testing.T) {
+	s, err := Generate("testdata", []string{"WidgetStatus"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := WriteGo("widgets", "WidgetSchema", s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "// Code generated by schemagen. DO NOT EDIT.\n\npackage widgets\n\n" +
+		"// WidgetSchema is the merge schema of the types of the package, see typed.NewParser.\n" +
+		"const WidgetSchema = `types:\n- name: WidgetStatus\n  map:\n    fields:\n    - name: ready\n      type:\n        scalar: boolean\n`\n"
+	if string(out) != expected {
+		t.Errorf("expected:\n%v\ngot:\n%v", expected, string(out))
+	}
+}
+
+func TestWritePaths(t *testing.T) {
+	b := schema.NewBuilder()
+	b.Struct("pod").
+		Field("spec", schema.Named("spec"))
+	b.Struct("spec").
+		Field("containers", schema.AssociativeList(schema.Named("container"), "name")).
+		Field("labels", schema.MapOf(schema.ScalarType(schema.String)))
+	b.Struct("container").
+		Field("name", schema.ScalarType(schema.String))
+	s, err := b.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := WritePaths("pods", s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		"type PodPath struct{ path fieldpath.Path }\n",
+		"func (p PodPath) Spec() SpecPath { return SpecPath{withField(p.path, \"spec\")} }\n",
+		"func (p SpecPath) Containers(name string) ContainerPath {\n\treturn ContainerPath{withKey(p.path, \"containers\", \"name\", name)}\n}\n",
+		"func (p SpecPath) ContainersField() fieldpath.Path { return withField(p.path, \"containers\") }\n",
+		"func (p SpecPath) Labels(key string) fieldpath.Path {\n\treturn withField(withField(p.path, \"labels\"), key)\n}\n",
+		"func (p ContainerPath) Name() fieldpath.Path { return withField(p.path, \"name\") }\n",
+	} {
+		if !strings.Contains(string(out), expected) {
+			t.Errorf("expected %q in:\n%s", expected, out)
+		}
+	}
+}
+
+func TestWritePathsConflict(t *testing.T) {
+	b := schema.NewBuilder()
+	b.Struct("pod").
+		Field("ports", schema.SetOf(schema.ScalarType(schema.Numeric))).
+		Field("portsField", schema.ScalarType(schema.String))
+	s, err := b.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := WritePaths("pods", s); err == nil || !strings.Contains(err.Error(), "more than one method named PortsField") {
+		t.Errorf("expected a conflict, got %v", err)
+	}
+}
+
+func TestGoName(t *testing.T) {
+	for name, expected := range map[string]string{
+		"io.k8s.api.core.v1.Pod": "IoK8sApiCoreV1Pod",
+		"apiVersion":             "ApiVersion",
+		"__untyped_atomic_":      "UntypedAtomic",
+		"2d":                     "X2d",
+	} {
+		if got := goName(name); got != expected {
+			t.Errorf("goName(%q): expected %v, got %v", name, expected, got)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package testdata
+
+import (
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// Widget is a resource.
+type Widget struct {
+	metav1.TypeMeta `json:",inline"`
+	Metadata        map[string]interface{} `json:"metadata,omitempty"`
+
+	Spec   WidgetSpec    `json:"spec"`
+	Status *WidgetStatus `json:"status,omitempty"`
+}
+
+type WidgetSpec struct {
+	Replicas *int32 `json:"replicas,omitempty"`
+	// +listType=map
+	// +listMapKey=name
+	// +listMapKey=protocol
+	Ports []Port `json:"ports,omitempty"`
+	// +listType=set
+	Finalizers []string `json:"finalizers,omitempty"`
+	Args       []string `json:"args,omitempty"`
+	// +mapType=atomic
+	Selector map[string]string `json:"selector,omitempty"`
+	Labels   map[string]string `json:"labels,omitempty"`
+	Env      []EnvVar          `json:"env,omitempty" patchStrategy:"merge" patchMergeKey:"name"`
+	Color    Color             `json:"color"`
+	// +structType=atomic
+	Border Border             `json:"border"`
+	Phase  Phase              `json:"phase,omitempty"`
+	Names  Names              `json:"names,omitempty"`
+	Data   []byte             `json:"data,omitempty"`
+	Config interface{}        `json:"config,omitempty"`
+	Port   intstr.IntOrString `json:"port"`
+	Since  time.Time          `json:"since"`
+
+	Common `json:",inline"`
+
+	Ignored string `json:"-"`
+	private string
+}
+
+type Common struct {
+	Description string
+}
+
+type Port struct {
+	Name     string `json:"name"`
+	Protocol string `json:"protocol"`
+}
+
+type EnvVar struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// +structType=atomic
+type Color struct {
+	R, G, B uint8
+}
+
+type Border struct {
+	Width int `json:"width"`
+}
+
+type Phase string
+
+// +listType=set
+type Names []string
+
+type WidgetStatus struct {
+	Ready bool `json:"ready"`
+}
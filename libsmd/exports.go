@@ -0,0 +1,89 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+/*
+#include <stdlib.h>
+*/
+import "C"
+
+import (
+	"unsafe"
+)
+
+// result returns out as a C string, or NULL after setting *err if failed.
+func result(out string, failed error, err **C.char) *C.char {
+	if failed != nil {
+		if err != nil {
+			*err = C.CString(failed.Error())
+		}
+		return nil
+	}
+	return C.CString(out)
+}
+
+// recoverPanic is deferred by every exported function, so that the panics
+// of the library fail the call, setting *err, rather than the host process.
+func recoverPanic(err **C.char) {
+	if r := recover(); r != nil && err != nil {
+		*err = C.CString(panicError(r).Error())
+	}
+}
+
+//export smd_schema_new
+func smd_schema_new(schema, typeName *C.char, err **C.char) C.longlong {
+	defer recoverPanic(err)
+	handle, failed := newSchema(C.GoString(schema), C.GoString(typeName))
+	if failed != nil {
+		result("", failed, err)
+		return 0
+	}
+	return C.longlong(handle)
+}
+
+//export smd_schema_free
+func smd_schema_free(schema C.longlong) {
+	defer recoverPanic(nil)
+	freeSchema(int64(schema))
+}
+
+//export smd_merge
+func smd_merge(schema C.longlong, lhs, rhs *C.char, err **C.char) *C.char {
+	defer recoverPanic(err)
+	out, failed := mergeJSON(int64(schema), C.GoString(lhs), C.GoString(rhs))
+	return result(out, failed, err)
+}
+
+//export smd_compare
+func smd_compare(schema C.longlong, lhs, rhs *C.char, err **C.char) *C.char {
+	defer recoverPanic(err)
+	out, failed := compareJSON(int64(schema), C.GoString(lhs), C.GoString(rhs))
+	return result(out, failed, err)
+}
+
+//export smd_apply
+func smd_apply(schema C.longlong, live, config, manager, managedFields *C.char, force C.int, err **C.char) *C.char {
+	defer recoverPanic(err)
+	out, failed := applyJSON(int64(schema), C.GoString(live), C.GoString(config), C.GoString(manager), C.GoString(managedFields), force != 0)
+	return result(out, failed, err)
+}
+
+//export smd_free
+func smd_free(p unsafe.Pointer) {
+	defer recoverPanic(nil)
+	C.free(p)
+}
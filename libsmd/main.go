@@ -0,0 +1,46 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Command libsmd builds this library as a C shared library, with a minimal
+// C API over JSON buffers, for other languages to embed its semantics:
+//
+//	go build -buildmode=c-shared -o libsmd.so ./libsmd
+//
+// which also writes the declarations of the functions to libsmd.h. The
+// objects are JSON, the managed fields are encoded by
+// fieldpath.EncodeManagedFields and the field sets in the FieldsV1 encoding.
+// Every function returns a string allocated by the library, to be released
+// with smd_free, and on failure returns NULL and sets *err to the error
+// message, to be released with smd_free as well. The operations are the
+// ones of server.Server, with its default limits of the objects, and the
+// panics of the library fail the call like errors:
+//
+//	long long smd_schema_new(char* schema, char* typeName, char** err);
+//	void smd_schema_free(long long schema);
+//	char* smd_merge(long long schema, char* lhs, char* rhs, char** err);
+//	char* smd_compare(long long schema, char* lhs, char* rhs, char** err);
+//	char* smd_apply(long long schema, char* live, char* config, char* manager,
+//	                char* managedFields, int force, char** err);
+//	void smd_free(void* p);
+//
+// Schemas are parsed once by smd_schema_new, which returns a handle to
+// pass to the other functions, or 0 on failure. The handle 0 deduces the
+// types from the objects (see simple.Deduced). smd_merge returns
+// {"object": ...}, smd_compare {"added": ..., "modified": ...,
+// "removed": ...}, and smd_apply {"object": ..., "managedFields": ...}.
+package main
+
+func main() {}
@@ -0,0 +1,105 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync/atomic"
+
+	"sigs.k8s.io/structured-merge-diff/v4/server"
+)
+
+// srv serves the operations, with the schemas returned by newSchema
+// registered under their handles. Its limits apply to the objects.
+var srv = server.NewServer()
+
+// nextHandle is the last handle returned by newSchema.
+var nextHandle int64
+
+// schemaName returns the name of the schema of handle in srv, empty for the
+// handle 0 which deduces the types.
+func schemaName(handle int64) string {
+	if handle == 0 {
+		return ""
+	}
+	return strconv.FormatInt(handle, 10)
+}
+
+func newSchema(schemaYAML, typeName string) (int64, error) {
+	handle := atomic.AddInt64(&nextHandle, 1)
+	if err := srv.RegisterSchema(schemaName(handle), []byte(schemaYAML), typeName); err != nil {
+		return 0, err
+	}
+	return handle, nil
+}
+
+func freeSchema(handle int64) {
+	if handle != 0 {
+		srv.UnregisterSchema(schemaName(handle))
+	}
+}
+
+func mergeJSON(handle int64, lhs, rhs string) (string, error) {
+	resp, err := srv.Merge(&server.ObjectsRequest{
+		Schema: schemaName(handle),
+		LHS:    json.RawMessage(lhs),
+		RHS:    json.RawMessage(rhs),
+	})
+	if err != nil {
+		return "", err
+	}
+	return marshal(resp)
+}
+
+func compareJSON(handle int64, lhs, rhs string) (string, error) {
+	resp, err := srv.Compare(&server.ObjectsRequest{
+		Schema: schemaName(handle),
+		LHS:    json.RawMessage(lhs),
+		RHS:    json.RawMessage(rhs),
+	})
+	if err != nil {
+		return "", err
+	}
+	return marshal(resp)
+}
+
+func applyJSON(handle int64, live, config, manager, managedFields string, force bool) (string, error) {
+	resp, err := srv.Apply(&server.ApplyRequest{
+		Schema:        schemaName(handle),
+		Live:          json.RawMessage(live),
+		Config:        json.RawMessage(config),
+		Manager:       manager,
+		ManagedFields: json.RawMessage(managedFields),
+		Force:         force,
+	})
+	if err != nil {
+		return "", err
+	}
+	return marshal(resp)
+}
+
+// panicError returns the error of a recovered panic.
+func panicError(r interface{}) error {
+	return fmt.Errorf("internal error: %v", r)
+}
+
+func marshal(v interface{}) (string, error) {
+	b, err := json.Marshal(v)
+	return string(b), err
+}
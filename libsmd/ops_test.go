@@ -0,0 +1,97 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestOperations(t *testing.T) {
+	handle, err := newSchema(`types:
+- name: object
+  map:
+    fields:
+    - name: args
+      type:
+        list:
+          elementType:
+            scalar: string
+          elementRelationship: atomic
+    - name: replicas
+      type:
+        scalar: numeric
+`, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer freeSchema(handle)
+
+	out, err := mergeJSON(handle, `{"args": ["a"], "replicas": 1}`, `{"args": ["b"]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := `{"object":{"args":["b"],"replicas":1}}`; out != expected {
+		t.Errorf("expected merge to return %s, got %s", expected, out)
+	}
+
+	out, err = compareJSON(handle, `{"args": ["a"], "replicas": 1}`, `{"args": ["b"]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := `{"added":{},"modified":{"f:args":{}},"removed":{"f:replicas":{}}}`; out != expected {
+		t.Errorf("expected compare to return %s, got %s", expected, out)
+	}
+
+	out, err = applyJSON(handle, "", `{"replicas": 1}`, "a", "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"object":{"replicas":1},"managedFields":[{"manager":"a","operation":"Apply","apiVersion":"v1","fieldsType":"FieldsV1","fieldsV1":{"f:replicas":{}}}]}`
+	if out != expected {
+		t.Errorf("expected apply to return %s, got %s", expected, out)
+	}
+	managed := `[{"manager":"a","operation":"Apply","apiVersion":"v1","fieldsType":"FieldsV1","fieldsV1":{"f:replicas":{}}}]`
+	if _, err := applyJSON(handle, `{"replicas": 1}`, `{"replicas": 2}`, "b", managed, false); err == nil || !strings.Contains(err.Error(), `conflict with "a"`) {
+		t.Errorf("expected a conflict, got %v", err)
+	}
+}
+
+func TestSchemaHandles(t *testing.T) {
+	if _, err := newSchema("types: 1", ""); err == nil {
+		t.Error("expected an invalid schema to fail")
+	}
+	handle, err := newSchema("types:\n- name: object\n  scalar: string\n", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	freeSchema(handle)
+	if _, err := mergeJSON(handle, `"a"`, `"b"`); err == nil {
+		t.Error("expected a freed schema to be unknown")
+	}
+	// The handle 0 deduces the types.
+	if out, err := mergeJSON(0, `{"a": 1}`, `{"b": 2}`); err != nil || out != `{"object":{"a":1,"b":2}}` {
+		t.Errorf("expected a deduced merge, got %v, %v", out, err)
+	}
+}
+
+func TestUntypedLimits(t *testing.T) {
+	deep := strings.Repeat(`{"a":`, 100) + "1" + strings.Repeat("}", 100)
+	if _, err := mergeJSON(0, deep, `{}`); err == nil {
+		t.Error("expected an object exceeding the limits to fail")
+	}
+}
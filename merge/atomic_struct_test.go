@@ -0,0 +1,179 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package merge_test
+
+import (
+	"testing"
+
+	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
+	. "sigs.k8s.io/structured-merge-diff/v4/internal/fixture"
+	"sigs.k8s.io/structured-merge-diff/v4/merge"
+	"sigs.k8s.io/structured-merge-diff/v4/typed"
+)
+
+var atomicStructParser = func() Parser {
+	parser, err := typed.NewParser(`types:
+- name: container
+  map:
+    fields:
+    - name: name
+      type:
+        scalar: string
+    - name: resources
+      type:
+        namedType: resources
+- name: resources
+  map:
+    fields:
+    - name: limits
+      type:
+        namedType: quantities
+    - name: requests
+      type:
+        namedType: quantities
+    elementRelationship: atomic
+- name: quantities
+  map:
+    fields:
+    - name: cpu
+      type:
+        scalar: string
+    - name: memory
+      type:
+        scalar: string
+`)
+	if err != nil {
+		panic(err)
+	}
+	return SameVersionParser{T: parser.Type("container")}
+}()
+
+func TestAtomicStructs(t *testing.T) {
+	tests := map[string]TestCase{
+		"nested fields conflict through the struct": {
+			Ops: []Operation{
+				Apply{
+					Manager:    "apply-one",
+					APIVersion: "v1",
+					Object: `
+						resources:
+						  limits:
+						    cpu: "1"
+					`,
+				},
+				Apply{
+					Manager:    "apply-two",
+					APIVersion: "v1",
+					Object: `
+						resources:
+						  requests:
+						    memory: 1Gi
+					`,
+					Conflicts: merge.Conflicts{
+						merge.Conflict{Manager: "apply-one", Path: _P("resources")},
+					},
+				},
+			},
+			Object: `
+				resources:
+				  limits:
+				    cpu: "1"
+			`,
+			APIVersion: "v1",
+			Managed: fieldpath.ManagedFields{
+				"apply-one": fieldpath.NewVersionedSet(_NS(_P("resources")), "v1", true),
+			},
+		},
+		"applying replaces the struct": {
+			Ops: []Operation{
+				Apply{
+					Manager:    "apply-one",
+					APIVersion: "v1",
+					Object: `
+						name: app
+						resources:
+						  limits:
+						    cpu: "1"
+					`,
+				},
+				Apply{
+					Manager:    "apply-one",
+					APIVersion: "v1",
+					Object: `
+						name: app
+						resources:
+						  requests:
+						    memory: 1Gi
+					`,
+				},
+			},
+			Object: `
+				name: app
+				resources:
+				  requests:
+				    memory: 1Gi
+			`,
+			APIVersion: "v1",
+			Managed: fieldpath.ManagedFields{
+				"apply-one": fieldpath.NewVersionedSet(_NS(_P("name"), _P("resources")), "v1", true),
+			},
+		},
+		"updating a nested field takes the struct": {
+			Ops: []Operation{
+				Apply{
+					Manager:    "apply-one",
+					APIVersion: "v1",
+					Object: `
+						name: app
+						resources:
+						  limits:
+						    cpu: "1"
+					`,
+				},
+				Update{
+					Manager:    "controller",
+					APIVersion: "v1",
+					Object: `
+						name: app
+						resources:
+						  limits:
+						    cpu: "2"
+					`,
+				},
+			},
+			Object: `
+				name: app
+				resources:
+				  limits:
+				    cpu: "2"
+			`,
+			APIVersion: "v1",
+			Managed: fieldpath.ManagedFields{
+				"apply-one":  fieldpath.NewVersionedSet(_NS(_P("name")), "v1", true),
+				"controller": fieldpath.NewVersionedSet(_NS(_P("resources")), "v1", false),
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if err := test.Test(atomicStructParser); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package merge
+
+import (
+	"fmt"
+
+	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
+	"sigs.k8s.io/structured-merge-diff/v4/typed"
+)
+
+// ApplyOperation is one of the applies run by ApplyAll.
+type ApplyOperation struct {
+	// Manager is the applier.
+	Manager string
+	// Config is the applied configuration.
+	Config *typed.TypedValue
+	// Force makes the applier take the conflicting fields from their
+	// owners rather than failing.
+	Force bool
+}
+
+// ApplyAllError is returned by ApplyAll when one of the applies fails.
+type ApplyAllError struct {
+	// Index is the index of the failed apply.
+	Index int
+	// Manager is the manager of the failed apply.
+	Manager string
+	// Err is the error returned by Apply, e.g. Conflicts.
+	Err error
+}
+
+func (e *ApplyAllError) Error() string {
+	return fmt.Sprintf("apply %d by %q failed: %v", e.Index, e.Manager, e.Err)
+}
+
+// Unwrap returns the error returned by Apply.
+func (e *ApplyAllError) Unwrap() error {
+	return e.Err
+}
+
+// ApplyAll runs the applies in order against liveObject, as if Apply was
+// called for each of them with the object and managers returned by the
+// previous one, and returns the final object and managers. The configs must
+// be in the same version as liveObject.
+//
+// ApplyAll is a convenience wrapper: it doesn't batch the applies into a
+// single pass, and each of them still costs a full merge and conflict
+// check, since the conflicts of an apply depend on the object and managers
+// left by the previous ones. It only saves the callers replaying sequences
+// of applies, e.g. simulation tools and conformance tests, from threading
+// the results and copying managers themselves.
+//
+// If an apply fails, ApplyAll stops and returns an *ApplyAllError, and
+// managers is left untouched. The returned object is never nil, even if the
+// applies didn't change anything.
+func (s *Updater) ApplyAll(liveObject *typed.TypedValue, version fieldpath.APIVersion, managers fieldpath.ManagedFields, applies []ApplyOperation) (*typed.TypedValue, fieldpath.ManagedFields, error) {
+	object := liveObject
+	managers = managers.Copy()
+	for i, apply := range applies {
+		newObject, newManagers, err := s.Apply(object, apply.Config, version, managers, apply.Manager, apply.Force)
+		if err != nil {
+			return nil, fieldpath.ManagedFields{}, &ApplyAllError{Index: i, Manager: apply.Manager, Err: err}
+		}
+		if newObject != nil {
+			object = newObject
+		}
+		managers = newManagers
+	}
+	return object, managers, nil
+}
@@ -0,0 +1,97 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package merge_test
+
+import (
+	"errors"
+	"testing"
+
+	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
+	. "sigs.k8s.io/structured-merge-diff/v4/internal/fixture"
+	"sigs.k8s.io/structured-merge-diff/v4/merge"
+	"sigs.k8s.io/structured-merge-diff/v4/typed"
+	"sigs.k8s.io/structured-merge-diff/v4/value"
+)
+
+func TestApplyAll(t *testing.T) {
+	updater := &merge.Updater{Converter: &specificVersionConverter{
+		AcceptedVersions: []fieldpath.APIVersion{"v1"},
+	}}
+	pt := leafFieldsParser.Type("v1")
+	applies := []struct {
+		manager string
+		config  typed.YAMLObject
+		force   bool
+	}{
+		{"apply-one", `{"numeric": 1, "string": "a"}`, false},
+		{"apply-two", `{"numeric": 1, "bool": true}`, false},
+		{"apply-one", `{"numeric": 1, "string": "a"}`, false},
+		{"apply-two", `{"numeric": 2}`, true},
+	}
+
+	// The applies are run one at a time, as a reference.
+	state := State{Updater: updater, Parser: leafFieldsParser}
+	var ops []merge.ApplyOperation
+	for _, a := range applies {
+		if err := state.Apply(a.config, "v1", a.manager, a.force); err != nil {
+			t.Fatal(err)
+		}
+		config, err := pt.FromYAML(a.config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ops = append(ops, merge.ApplyOperation{Manager: a.manager, Config: config, Force: a.force})
+	}
+
+	live, err := pt.FromUnstructured(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	managers := fieldpath.ManagedFields{}
+	object, gotManagers, err := updater.ApplyAll(live, "v1", managers, ops)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if comparison, err := object.Compare(state.Live); err != nil || !comparison.IsSame() {
+		t.Errorf("expected object %v, got %v", value.ToString(state.Live.AsValue()), value.ToString(object.AsValue()))
+	}
+	if !gotManagers.Equals(state.Managers) {
+		t.Errorf("expected managers:\n%v\ngot:\n%v", state.Managers, gotManagers)
+	}
+	if len(managers) != 0 {
+		t.Errorf("expected managers to be left untouched, got %v", managers)
+	}
+
+	// apply-one now conflicts with apply-two, which forced .numeric.
+	conflicting := append(ops, merge.ApplyOperation{Manager: "apply-one", Config: ops[0].Config})
+	_, _, err = updater.ApplyAll(live, "v1", managers, conflicting)
+	var applyErr *merge.ApplyAllError
+	if !errors.As(err, &applyErr) || applyErr.Index != len(ops) || applyErr.Manager != "apply-one" {
+		t.Fatalf("expected the last apply to fail, got %v", err)
+	}
+	var conflicts merge.Conflicts
+	if !errors.As(err, &conflicts) {
+		t.Fatalf("expected conflicts, got %v", err)
+	}
+	expected := merge.Conflicts{{Manager: "apply-two", Path: _P("numeric")}}
+	if !conflicts.Equals(expected) {
+		t.Errorf("expected conflicts %v, got %v", expected, conflicts)
+	}
+	if len(managers) != 0 {
+		t.Errorf("expected managers to be left untouched, got %v", managers)
+	}
+}
@@ -22,6 +22,8 @@ import (
 	"strings"
 
 	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
+	"sigs.k8s.io/structured-merge-diff/v4/typed"
+	"sigs.k8s.io/structured-merge-diff/v4/value"
 )
 
 // Conflict is a conflict on a specific field with the current manager of
@@ -30,6 +32,13 @@ import (
 type Conflict struct {
 	Manager string
 	Path    fieldpath.Path
+
+	// Applied is the value requested for the field, if known. It is nil
+	// when the field is removed.
+	Applied value.Value
+	// Live is the current value of the field, if known. It is nil when the
+	// field doesn't exist.
+	Live value.Value
 }
 
 // Conflict is an error.
@@ -40,7 +49,8 @@ func (c Conflict) Error() string {
 	return fmt.Sprintf("conflict with %q: %v", c.Manager, c.Path)
 }
 
-// Equals returns true if c == c2
+// Equals returns true if c == c2. The values of the conflicts are
+// ignored.
 func (c Conflict) Equals(c2 Conflict) bool {
 	if c.Manager != c2.Manager {
 		return false
@@ -105,17 +115,50 @@ func (c Conflicts) ToSet() *fieldpath.Set {
 }
 
 // ConflictsFromManagers creates a list of conflicts given Managers sets.
+// The conflicts are ordered by path, and by manager for the same path.
 func ConflictsFromManagers(sets fieldpath.ManagedFields) Conflicts {
-	conflicts := []Conflict{}
+	managers := make([]string, 0, len(sets))
+	for manager := range sets {
+		managers = append(managers, manager)
+	}
+	sort.Strings(managers)
 
-	for manager, set := range sets {
-		set.Set().Iterate(func(p fieldpath.Path) {
-			conflicts = append(conflicts, Conflict{
-				Manager: manager,
-				Path:    p.Copy(),
-			})
-		})
+	owned := make([]*fieldpath.Set, len(managers))
+	for i, manager := range managers {
+		owned[i] = sets[manager].Set()
 	}
 
+	conflicts := []Conflict{}
+	fieldpath.NewSet().UnionAll(owned...).Iterate(func(p fieldpath.Path) {
+		for i, manager := range managers {
+			if owned[i].Has(p) {
+				conflicts = append(conflicts, Conflict{
+					Manager: manager,
+					Path:    p.Copy(),
+				})
+			}
+		}
+	})
+
+	return conflicts
+}
+
+// conflictsWithValues creates a list of conflicts given Managers sets,
+// with the values of the fields in the old (live) and new (applied)
+// objects of the version of each manager.
+func conflictsWithValues(sets fieldpath.ManagedFields, objects map[fieldpath.APIVersion][2]*typed.TypedValue) Conflicts {
+	conflicts := ConflictsFromManagers(sets)
+	for i := range conflicts {
+		objs, ok := objects[sets[conflicts[i].Manager].APIVersion()]
+		if !ok {
+			continue
+		}
+		if v, ok := objs[0].Lookup(conflicts[i].Path); ok {
+			conflicts[i].Live = v
+		}
+		if v, ok := objs[1].Lookup(conflicts[i].Path); ok {
+			conflicts[i].Applied = v
+		}
+	}
 	return conflicts
 }
@@ -21,6 +21,7 @@ import (
 
 	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
 	"sigs.k8s.io/structured-merge-diff/v4/merge"
+	"sigs.k8s.io/structured-merge-diff/v4/typed"
 	"sigs.k8s.io/structured-merge-diff/v4/value"
 )
 
@@ -111,3 +112,60 @@ func TestConflictsFromManagers(t *testing.T) {
 		t.Errorf("Got %v, wanted %v", got.Error(), wanted)
 	}
 }
+
+func TestConflictsFromManagersOrder(t *testing.T) {
+	got := merge.ConflictsFromManagers(fieldpath.ManagedFields{
+		"Bob": fieldpath.NewVersionedSet(
+			_NS(_P("key"), _P("value")),
+			"v1",
+			false,
+		),
+		"Alice": fieldpath.NewVersionedSet(
+			_NS(_P("value")),
+			"v1",
+			false,
+		),
+	})
+	wanted := merge.Conflicts{
+		{Manager: "Bob", Path: _P("key")},
+		{Manager: "Alice", Path: _P("value")},
+		{Manager: "Bob", Path: _P("value")},
+	}
+	if !got.Equals(wanted) {
+		t.Errorf("Got %v, wanted %v", got, wanted)
+	}
+}
+
+func TestConflictValues(t *testing.T) {
+	updater := &merge.Updater{Converter: &specificVersionConverter{
+		AcceptedVersions: []fieldpath.APIVersion{"v1"},
+	}}
+	pt := leafFieldsParser.Type("v1")
+	parse := func(y typed.YAMLObject) *typed.TypedValue {
+		tv, err := pt.FromYAML(y)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tv
+	}
+
+	live, managers, err := updater.Apply(parse(`{}`), parse(`{"numeric": 1, "string": "a"}`), "v1", fieldpath.ManagedFields{}, "apply-one", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = updater.Apply(live, parse(`{"numeric": 2, "bool": true}`), "v1", managers, "apply-two", false)
+	conflicts, ok := err.(merge.Conflicts)
+	if !ok || len(conflicts) != 1 {
+		t.Fatalf("expected one conflict, got %v", err)
+	}
+	c := conflicts[0]
+	if !c.Equals(merge.Conflict{Manager: "apply-one", Path: _P("numeric")}) {
+		t.Errorf("unexpected conflict %v", c)
+	}
+	if c.Applied == nil || !value.Equals(c.Applied, _V(2)) {
+		t.Errorf("expected applied value 2, got %v", c.Applied)
+	}
+	if c.Live == nil || !value.Equals(c.Live, _V(1)) {
+		t.Errorf("expected live value 1, got %v", c.Live)
+	}
+}
@@ -0,0 +1,148 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package merge_test
+
+import (
+	"testing"
+
+	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
+	"sigs.k8s.io/structured-merge-diff/v4/merge"
+	"sigs.k8s.io/structured-merge-diff/v4/typed"
+	"sigs.k8s.io/structured-merge-diff/v4/value"
+)
+
+var defaultsParser = func() *typed.Parser {
+	parser, err := typed.NewParser(`types:
+- name: v1
+  map:
+    fields:
+    - name: name
+      type:
+        scalar: string
+    - name: replicas
+      type:
+        scalar: numeric
+      default: 1
+    - name: template
+      type:
+        map:
+          fields:
+          - name: restartPolicy
+            type:
+              scalar: string
+            default: Always
+          - name: labels
+            type:
+              map:
+                elementType:
+                  scalar: string
+            default:
+              app: default
+    - name: ports
+      type:
+        list:
+          elementType:
+            map:
+              fields:
+              - name: port
+                type:
+                  scalar: numeric
+              - name: protocol
+                type:
+                  scalar: string
+                default: TCP
+          elementRelationship: associative
+          keys: [port, protocol]
+`)
+	if err != nil {
+		panic(err)
+	}
+	return parser
+}()
+
+func TestApplyCreate(t *testing.T) {
+	updater := &merge.Updater{Converter: &specificVersionConverter{
+		AcceptedVersions: []fieldpath.APIVersion{"v1"},
+	}}
+	pt := defaultsParser.Type("v1")
+	tests := []struct {
+		name     string
+		config   typed.YAMLObject
+		object   typed.YAMLObject
+		managers fieldpath.ManagedFields
+	}{
+		{
+			name:   "defaults",
+			config: `{"name": "a"}`,
+			object: `{"name": "a", "replicas": 1}`,
+			managers: fieldpath.ManagedFields{
+				"applier": fieldpath.NewVersionedSet(_NS(_P("name")), "v1", true),
+			},
+		}, {
+			name:   "nested defaults",
+			config: `{"replicas": 3, "template": {}, "ports": [{"port": 80}]}`,
+			object: `{"replicas": 3, "template": {"restartPolicy": "Always", "labels": {"app": "default"}}, "ports": [{"port": 80, "protocol": "TCP"}]}`,
+			managers: fieldpath.ManagedFields{
+				"applier": fieldpath.NewVersionedSet(_NS(
+					_P("replicas"),
+					_P("template"),
+					_P("ports", _KBF("port", 80, "protocol", "TCP")),
+					_P("ports", _KBF("port", 80, "protocol", "TCP"), "port"),
+				), "v1", true),
+			},
+		}, {
+			name:     "empty",
+			config:   `{}`,
+			object:   `{"replicas": 1}`,
+			managers: fieldpath.ManagedFields{},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := pt.FromYAML(tt.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			object, managers, err := updater.ApplyCreate(config, "v1", "applier")
+			if err != nil {
+				t.Fatal(err)
+			}
+			expected, err := pt.FromYAML(tt.object)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if object == nil || !value.Equals(object.AsValue(), expected.AsValue()) {
+				t.Errorf("expected object %v, got %v", value.ToString(expected.AsValue()), object)
+			}
+			if !managers.Equals(tt.managers) {
+				t.Errorf("expected managers:\n%v\ngot:\n%v", tt.managers, managers)
+			}
+			if !value.Equals(config.AsValue(), mustParse(t, pt, tt.config).AsValue()) {
+				t.Errorf("expected the config not to be modified, got %v", value.ToString(config.AsValue()))
+			}
+		})
+	}
+}
+
+func mustParse(t *testing.T, pt typed.ParseableType, object typed.YAMLObject) *typed.TypedValue {
+	tv, err := pt.FromYAML(object)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tv
+}
@@ -0,0 +1,164 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package merge_test
+
+import (
+	"testing"
+
+	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
+	"sigs.k8s.io/structured-merge-diff/v4/merge"
+	"sigs.k8s.io/structured-merge-diff/v4/typed"
+	"sigs.k8s.io/structured-merge-diff/v4/value"
+)
+
+func TestApplyWithDirectives(t *testing.T) {
+	updater := &merge.Updater{Converter: &specificVersionConverter{
+		AcceptedVersions: []fieldpath.APIVersion{"v1"},
+	}}
+	pt := defaultsParser.Type("v1")
+	live := `{"name": "a", "template": {"labels": {"app": "web", "team": "x"}}, "ports": [{"port": 80, "protocol": "TCP"}, {"port": 443, "protocol": "TCP"}]}`
+	managers := func() fieldpath.ManagedFields {
+		return fieldpath.ManagedFields{
+			"applier": fieldpath.NewVersionedSet(_NS(
+				_P("name"),
+				_P("ports", _KBF("port", 80, "protocol", "TCP")),
+				_P("ports", _KBF("port", 80, "protocol", "TCP"), "port"),
+				_P("ports", _KBF("port", 80, "protocol", "TCP"), "protocol"),
+			), "v1", true),
+			"other": fieldpath.NewVersionedSet(_NS(
+				_P("template", "labels", "team"),
+				_P("ports", _KBF("port", 443, "protocol", "TCP")),
+				_P("ports", _KBF("port", 443, "protocol", "TCP"), "port"),
+				_P("ports", _KBF("port", 443, "protocol", "TCP"), "protocol"),
+			), "v1", false),
+		}
+	}
+	tests := []struct {
+		name     string
+		config   string
+		force    bool
+		object   typed.YAMLObject
+		managers fieldpath.ManagedFields
+		err      bool
+	}{
+		{
+			name:     "no directive",
+			config:   `{"name": "b", "ports": [{"port": 80, "protocol": "TCP"}]}`,
+			object:   `{"name": "b", "template": {"labels": {"app": "web", "team": "x"}}, "ports": [{"port": 80, "protocol": "TCP"}, {"port": 443, "protocol": "TCP"}]}`,
+			managers: managers(),
+		}, {
+			name:   "delete owned item",
+			config: `{"name": "a", "ports": [{"port": 80, "protocol": "TCP", "$patch": "delete"}]}`,
+			object: `{"name": "a", "template": {"labels": {"app": "web", "team": "x"}}, "ports": [{"port": 443, "protocol": "TCP"}]}`,
+			managers: fieldpath.ManagedFields{
+				"applier": fieldpath.NewVersionedSet(_NS(_P("name")), "v1", true),
+				"other":   managers()["other"],
+			},
+		}, {
+			name:   "delete item of another manager",
+			config: `{"name": "a", "ports": [{"port": 80, "protocol": "TCP"}, {"port": 443, "protocol": "TCP", "$patch": "delete"}]}`,
+			err:    true,
+		}, {
+			name:   "force delete item of another manager",
+			config: `{"name": "a", "ports": [{"port": 80, "protocol": "TCP"}, {"port": 443, "protocol": "TCP", "$patch": "delete"}]}`,
+			force:  true,
+			object: `{"name": "a", "template": {"labels": {"app": "web", "team": "x"}}, "ports": [{"port": 80, "protocol": "TCP"}]}`,
+			managers: fieldpath.ManagedFields{
+				"applier": managers()["applier"],
+				"other":   fieldpath.NewVersionedSet(_NS(_P("template", "labels", "team")), "v1", false),
+			},
+		}, {
+			name:   "replace map",
+			config: `{"name": "a", "ports": [{"port": 80, "protocol": "TCP"}], "template": {"labels": {"$patch": "replace", "app": "api"}}}`,
+			force:  true,
+			object: `{"name": "a", "template": {"labels": {"app": "api"}}, "ports": [{"port": 80, "protocol": "TCP"}, {"port": 443, "protocol": "TCP"}]}`,
+			managers: fieldpath.ManagedFields{
+				"applier": fieldpath.NewVersionedSet(managers()["applier"].Set().Union(_NS(_P("template", "labels", "app"))), "v1", true),
+				"other": fieldpath.NewVersionedSet(_NS(
+					_P("ports", _KBF("port", 443, "protocol", "TCP")),
+					_P("ports", _KBF("port", 443, "protocol", "TCP"), "port"),
+					_P("ports", _KBF("port", 443, "protocol", "TCP"), "protocol"),
+				), "v1", false),
+			},
+		}, {
+			name:   "delete field of another manager",
+			config: `{"name": "a", "ports": [{"port": 80, "protocol": "TCP"}], "template": {"labels": {"$deleteFields": ["team"]}}}`,
+			err:    true,
+		}, {
+			name:   "force delete field of another manager",
+			config: `{"name": "a", "ports": [{"port": 80, "protocol": "TCP"}], "template": {"labels": {"$deleteFields": ["team"]}}}`,
+			force:  true,
+			object: `{"name": "a", "template": {"labels": {"app": "web"}}, "ports": [{"port": 80, "protocol": "TCP"}, {"port": 443, "protocol": "TCP"}]}`,
+			managers: fieldpath.ManagedFields{
+				"applier": managers()["applier"],
+				"other": fieldpath.NewVersionedSet(_NS(
+					_P("ports", _KBF("port", 443, "protocol", "TCP")),
+					_P("ports", _KBF("port", 443, "protocol", "TCP"), "port"),
+					_P("ports", _KBF("port", 443, "protocol", "TCP"), "protocol"),
+				), "v1", false),
+			},
+		}, {
+			name:   "replace list",
+			config: `{"name": "a", "$replaceFields": ["ports"], "ports": [{"port": 8080, "protocol": "TCP"}]}`,
+			force:  true,
+			object: `{"name": "a", "template": {"labels": {"app": "web", "team": "x"}}, "ports": [{"port": 8080, "protocol": "TCP"}]}`,
+			managers: fieldpath.ManagedFields{
+				"applier": fieldpath.NewVersionedSet(_NS(
+					_P("name"),
+					_P("ports", _KBF("port", 8080, "protocol", "TCP")),
+					_P("ports", _KBF("port", 8080, "protocol", "TCP"), "port"),
+					_P("ports", _KBF("port", 8080, "protocol", "TCP"), "protocol"),
+				), "v1", true),
+				"other": fieldpath.NewVersionedSet(_NS(_P("template", "labels", "team")), "v1", false),
+			},
+		}, {
+			name:   "replace map field",
+			config: `{"name": "a", "$replaceFields": ["template"], "template": {}}`,
+			err:    true,
+		}, {
+			name:   "unknown directive",
+			config: `{"name": "a", "template": {"$patch": "merge"}}`,
+			err:    true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := value.FromJSON([]byte(tt.config))
+			if err != nil {
+				t.Fatal(err)
+			}
+			object, managers, err := updater.ApplyWithDirectives(mustParse(t, pt, typed.YAMLObject(live)), config, "v1", managers(), "applier", tt.force)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("expected an error, got object %v", object)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			expected := mustParse(t, pt, tt.object)
+			if object == nil || !value.Equals(object.AsValue(), expected.AsValue()) {
+				t.Errorf("expected object %v, got %v", value.ToString(expected.AsValue()), object)
+			}
+			if !managers.Equals(tt.managers) {
+				t.Errorf("expected managers:\n%v\ngot:\n%v", tt.managers, managers)
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package merge_test
+
+import (
+	"testing"
+
+	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
+	. "sigs.k8s.io/structured-merge-diff/v4/internal/fixture"
+	"sigs.k8s.io/structured-merge-diff/v4/typed"
+)
+
+func TestEmptyCollections(t *testing.T) {
+	ops := []Operation{
+		Apply{
+			Manager:    "one",
+			APIVersion: "v1",
+			Object:     `{"a": 1}`,
+		},
+		Apply{
+			Manager:    "two",
+			APIVersion: "v1",
+			Object:     `{"b": {}}`,
+		},
+		Update{
+			Manager:    "controller",
+			APIVersion: "v1",
+			Object:     `{"a": 1}`,
+		},
+	}
+	tests := map[string]TestCase{
+		"empty_is_distinct": {
+			Ops:        ops,
+			Object:     `{"a": 1}`,
+			APIVersion: "v1",
+			Managed: fieldpath.ManagedFields{
+				"one": fieldpath.NewVersionedSet(_NS(_P("a")), "v1", true),
+			},
+		},
+		"empty_equals_absent": {
+			Ops:        ops,
+			Object:     `{"a": 1}`,
+			APIVersion: "v1",
+			Managed: fieldpath.ManagedFields{
+				"one": fieldpath.NewVersionedSet(_NS(_P("a")), "v1", true),
+				"two": fieldpath.NewVersionedSet(_NS(_P("b")), "v1", true),
+			},
+			EmptyCollections: typed.EmptyEqualsAbsent,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if err := test.Test(DeducedParser); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
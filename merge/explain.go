@@ -0,0 +1,168 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package merge
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
+)
+
+// Owner is a manager that owns a field.
+type Owner struct {
+	// Manager is the name of the manager, without the subresource which
+	// suffixes the keys of the ManagedFields of subresource managers (see
+	// Key).
+	Manager string
+	// Subresource is the subresource through which the manager owns the
+	// field, or empty for the main resource.
+	Subresource string
+	// APIVersion is the version in which the manager owns the field.
+	APIVersion fieldpath.APIVersion
+	// Applied is true if the manager owns the field through Apply, false
+	// if through Update.
+	Applied bool
+}
+
+// Key returns the key of the manager in the ManagedFields, see
+// fieldpath.SubresourceManager.
+func (o Owner) Key() string {
+	return fieldpath.SubresourceManager(o.Manager, o.Subresource)
+}
+
+// Explanation describes who owns a field, and what changing it would do to
+// the ownership.
+type Explanation struct {
+	// Path is the field being explained.
+	Path fieldpath.Path
+	// Owned is the field that the owners own: Path itself, or, if nobody
+	// owns Path, the closest of its parents which is owned as a whole, e.g.
+	// an atomic struct, map or list that contains Path.
+	Owned fieldpath.Path
+	// Owners are the managers of the field, sorted by key.
+	Owners []Owner
+}
+
+// Explain reports which managers own path, and in which version. Paths are
+// not converted: owners with a set in another version are only reported if
+// the field has the same path in both versions.
+//
+// Fields inside atomic structs, maps and lists are owned through them. A
+// parent of path is considered atomic if a manager owns it but none of its
+// children.
+func Explain(managers fieldpath.ManagedFields, path fieldpath.Path) Explanation {
+	e := Explanation{Path: path, Owned: path}
+	for manager, set := range managers {
+		if set.Set().Has(path) {
+			e.Owners = append(e.Owners, newOwner(manager, set))
+		}
+	}
+	for i := len(path) - 1; i > 0 && len(e.Owners) == 0; i-- {
+		parent := path[:i]
+		for manager, set := range managers {
+			if set.Set().Has(parent) && ownsNoChild(set.Set(), parent) {
+				e.Owned = parent
+				e.Owners = append(e.Owners, newOwner(manager, set))
+			}
+		}
+	}
+	sort.Slice(e.Owners, func(i, j int) bool {
+		return e.Owners[i].Key() < e.Owners[j].Key()
+	})
+	return e
+}
+
+func newOwner(manager string, set fieldpath.VersionedSet) Owner {
+	subresource := fieldpath.Subresource(set)
+	if subresource != "" {
+		manager = strings.TrimSuffix(manager, " ("+subresource+")")
+	}
+	return Owner{
+		Manager:     manager,
+		Subresource: subresource,
+		APIVersion:  set.APIVersion(),
+		Applied:     set.Applied(),
+	}
+}
+
+// ownsNoChild returns true if set has no field under path.
+func ownsNoChild(set *fieldpath.Set, path fieldpath.Path) bool {
+	for _, pe := range path {
+		set = set.WithPrefix(pe)
+	}
+	return set.Empty()
+}
+
+// IsOwnedBy returns true if manager, the key of a manager in the
+// ManagedFields, owns the field.
+func (e Explanation) IsOwnedBy(manager string) bool {
+	for _, owner := range e.Owners {
+		if owner.Key() == manager {
+			return true
+		}
+	}
+	return false
+}
+
+// ApplyConflicts returns the conflicts that manager would get by applying a
+// different value to the field. They are the same as those returned by
+// Updater.Apply when not forcing, which would also get all the other owners
+// to lose the field when forcing. Applying the same value never conflicts,
+// and makes manager share the ownership of the field instead.
+func (e Explanation) ApplyConflicts(manager string) Conflicts {
+	conflicts := Conflicts{}
+	for _, owner := range e.Owners {
+		if owner.Key() == manager {
+			continue
+		}
+		conflicts = append(conflicts, Conflict{Manager: owner.Key(), Path: e.Owned})
+	}
+	return conflicts
+}
+
+// String describes the ownership of the field and what changing it would do
+// to it.
+func (e Explanation) String() string {
+	b := strings.Builder{}
+	if len(e.Owners) == 0 {
+		fmt.Fprintf(&b, "%v is not owned by any manager.\n", e.Path)
+		fmt.Fprintf(&b, "Changing it with Apply or Update makes that manager its only owner.\n")
+		return b.String()
+	}
+	if len(e.Owned) != len(e.Path) {
+		fmt.Fprintf(&b, "%v is part of %v, which is owned as a whole.\n", e.Path, e.Owned)
+	}
+	fmt.Fprintf(&b, "%v is owned by:\n", e.Owned)
+	for _, owner := range e.Owners {
+		operation := "Update"
+		if owner.Applied {
+			operation = "Apply"
+		}
+		if owner.Subresource != "" {
+			fmt.Fprintf(&b, "- %q (%v, %v, subresource %v)\n", owner.Manager, operation, owner.APIVersion, owner.Subresource)
+			continue
+		}
+		fmt.Fprintf(&b, "- %q (%v, %v)\n", owner.Manager, operation, owner.APIVersion)
+	}
+	fmt.Fprintf(&b, "Applying a different value conflicts with all of its owners but the applier, unless forced, which makes the applier its only owner.\n")
+	fmt.Fprintf(&b, "Applying the same value shares its ownership with its current owners.\n")
+	fmt.Fprintf(&b, "Changing it with Update never conflicts, and makes the updater its only owner.\n")
+	fmt.Fprintf(&b, "Removing it with Update removes it from all of its owners.\n")
+	return b.String()
+}
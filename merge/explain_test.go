@@ -0,0 +1,183 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package merge_test
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
+	. "sigs.k8s.io/structured-merge-diff/v4/internal/fixture"
+	"sigs.k8s.io/structured-merge-diff/v4/merge"
+	"sigs.k8s.io/structured-merge-diff/v4/typed"
+)
+
+func TestExplain(t *testing.T) {
+	managers := fieldpath.ManagedFields{
+		"apply-one": fieldpath.NewVersionedSet(_NS(
+			_P("numeric"),
+			_P("string"),
+		), "v1", true),
+		"apply-two": fieldpath.NewVersionedSet(_NS(
+			_P("numeric"),
+		), "v2", true),
+		"controller": fieldpath.NewVersionedSet(_NS(
+			_P("bool"),
+			_P("numeric"),
+		), "v1", false),
+	}
+
+	e := merge.Explain(managers, _P("numeric"))
+	expected := []merge.Owner{
+		{Manager: "apply-one", APIVersion: "v1", Applied: true},
+		{Manager: "apply-two", APIVersion: "v2", Applied: true},
+		{Manager: "controller", APIVersion: "v1", Applied: false},
+	}
+	if !reflect.DeepEqual(e.Owners, expected) {
+		t.Errorf("expected owners %v, got %v", expected, e.Owners)
+	}
+	if !e.IsOwnedBy("apply-two") || e.IsOwnedBy("other") {
+		t.Errorf("unexpected IsOwnedBy results for %v", e.Owners)
+	}
+	conflicts := e.ApplyConflicts("apply-one")
+	expectedConflicts := merge.Conflicts{
+		{Manager: "apply-two", Path: _P("numeric")},
+		{Manager: "controller", Path: _P("numeric")},
+	}
+	if !conflicts.Equals(expectedConflicts) {
+		t.Errorf("expected conflicts %v, got %v", expectedConflicts, conflicts)
+	}
+	if s := e.String(); !strings.Contains(s, `- "controller" (Update, v1)`) {
+		t.Errorf("expected controller to be listed as owner:\n%v", s)
+	}
+
+	e = merge.Explain(managers, _P("other"))
+	if len(e.Owners) != 0 {
+		t.Errorf("expected no owner, got %v", e.Owners)
+	}
+	if len(e.ApplyConflicts("apply-one")) != 0 {
+		t.Errorf("expected no conflict, got %v", e.ApplyConflicts("apply-one"))
+	}
+	if s := e.String(); !strings.Contains(s, "not owned by any manager") {
+		t.Errorf("expected field to be reported as not owned:\n%v", s)
+	}
+}
+
+// TestExplainMatchesApply checks that the conflicts predicted by Explain are
+// the ones that Apply actually returns.
+func TestExplainMatchesApply(t *testing.T) {
+	state := State{
+		Updater: &merge.Updater{Converter: &specificVersionConverter{
+			AcceptedVersions: []fieldpath.APIVersion{"v1"},
+		}},
+		Parser: leafFieldsParser,
+	}
+	if err := state.Apply(typed.YAMLObject(`{"numeric": 1, "string": "a"}`), "v1", "apply-one", false); err != nil {
+		t.Fatal(err)
+	}
+	if err := state.Update(typed.YAMLObject(`{"numeric": 1, "string": "a", "bool": true}`), "v1", "controller"); err != nil {
+		t.Fatal(err)
+	}
+	if err := state.Apply(typed.YAMLObject(`{"numeric": 1, "bool": true}`), "v1", "apply-two", false); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []fieldpath.Path{_P("numeric"), _P("string"), _P("bool")} {
+		predicted := merge.Explain(state.Managers, path).ApplyConflicts("apply-three")
+		obj := typed.YAMLObject(`{"numeric": 2}`)
+		switch path.String() {
+		case ".string":
+			obj = `{"string": "b"}`
+		case ".bool":
+			obj = `{"bool": false}`
+		}
+		dry := State{Updater: state.Updater, Parser: state.Parser, Live: state.Live, Managers: state.Managers.Copy()}
+		err := dry.Apply(obj, "v1", "apply-three", false)
+		var got merge.Conflicts
+		if err != nil {
+			var ok bool
+			if got, ok = err.(merge.Conflicts); !ok {
+				t.Fatalf("%v: unexpected error: %v", path, err)
+			}
+		}
+		// Apply reports conflicts in no particular order.
+		sort.Slice(got, func(i, j int) bool { return got[i].Manager < got[j].Manager })
+		if !got.Equals(predicted) {
+			t.Errorf("%v: predicted conflicts %v, got %v", path, predicted, got)
+		}
+	}
+}
+
+func TestExplainAtomic(t *testing.T) {
+	state := State{
+		Updater: &merge.Updater{Converter: &specificVersionConverter{
+			AcceptedVersions: []fieldpath.APIVersion{"v1"},
+		}},
+		Parser: atomicMapParser,
+	}
+	if err := state.Apply(typed.YAMLObject(`{"atomicMap": {"field1": "a"}}`), "v1", "apply-one", false); err != nil {
+		t.Fatal(err)
+	}
+
+	e := merge.Explain(state.Managers, _P("atomicMap", "field2"))
+	if !e.Owned.Equals(_P("atomicMap")) || !e.IsOwnedBy("apply-one") {
+		t.Fatalf("expected .atomicMap to be owned by apply-one, got %v owned by %v", e.Owned, e.Owners)
+	}
+	if s := e.String(); !strings.Contains(s, ".atomicMap.field2 is part of .atomicMap") {
+		t.Errorf("expected the atomic parent to be explained:\n%v", s)
+	}
+
+	predicted := e.ApplyConflicts("apply-two")
+	err := state.Apply(typed.YAMLObject(`{"atomicMap": {"field2": "b"}}`), "v1", "apply-two", false)
+	got, ok := err.(merge.Conflicts)
+	if !ok {
+		t.Fatalf("expected conflicts, got %v", err)
+	}
+	if !got.Equals(predicted) {
+		t.Errorf("predicted conflicts %v, got %v", predicted, got)
+	}
+}
+
+func TestExplainSubresource(t *testing.T) {
+	managers := fieldpath.ManagedFields{
+		"helm (v3)": fieldpath.NewVersionedSet(_NS(_P("numeric")), "v1", true),
+		"autoscaler (scale)": fieldpath.NewSubresourceVersionedSet(_NS(
+			_P("numeric"),
+		), "v1", false, "scale"),
+	}
+
+	e := merge.Explain(managers, _P("numeric"))
+	expected := []merge.Owner{
+		{Manager: "autoscaler", Subresource: "scale", APIVersion: "v1", Applied: false},
+		{Manager: "helm (v3)", APIVersion: "v1", Applied: true},
+	}
+	if !reflect.DeepEqual(e.Owners, expected) {
+		t.Errorf("expected owners %v, got %v", expected, e.Owners)
+	}
+	if !e.IsOwnedBy("autoscaler (scale)") || e.IsOwnedBy("autoscaler") {
+		t.Errorf("unexpected IsOwnedBy results for %v", e.Owners)
+	}
+	expectedConflicts := merge.Conflicts{{Manager: "autoscaler (scale)", Path: _P("numeric")}}
+	if conflicts := e.ApplyConflicts("helm (v3)"); !conflicts.Equals(expectedConflicts) {
+		t.Errorf("expected conflicts %v, got %v", expectedConflicts, conflicts)
+	}
+	if s := e.String(); !strings.Contains(s, `- "autoscaler" (Update, v1, subresource scale)`) {
+		t.Errorf("expected autoscaler to be listed with its subresource:\n%v", s)
+	}
+}
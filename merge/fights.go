@@ -0,0 +1,184 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package merge
+
+import (
+	"fmt"
+	"sort"
+
+	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
+	"sigs.k8s.io/structured-merge-diff/v4/typed"
+)
+
+// FightOptions configures DetectFights.
+type FightOptions struct {
+	// Window is the number of consecutive operations of the trace in which
+	// flips are counted. If it isn't positive, the whole trace is.
+	Window int
+	// MinFlips is the number of flips within Window from which a field is
+	// reported. It defaults to 3.
+	MinFlips int
+}
+
+// Fight is a field that two managers keep taking from each other.
+type Fight struct {
+	// Path is the field.
+	Path fieldpath.Path
+	// Managers are the two managers, sorted.
+	Managers [2]string
+	// Operations are the indexes in the trace of the operations which
+	// flipped the field from one manager to the other, in the window with
+	// the most flips.
+	Operations []int
+}
+
+// FightReport is the result of DetectFights.
+type FightReport struct {
+	// Fights are sorted by managers, then by path.
+	Fights []Fight
+}
+
+// flip is an operation which took a field from another manager.
+type flip struct {
+	path     fieldpath.Path
+	managers [2]string
+	op       int
+}
+
+// DetectFights looks for controllers fighting over fields in trace, e.g. a
+// controller reverting what another one sets, in a hot loop. A flip is an
+// operation of a manager changing the value of a field, or taking its
+// ownership, after another manager last did. A field is reported when the
+// same two managers flip it at least MinFlips times within Window
+// operations. Failed operations and no-op applies are ignored. Objects are
+// parsed with the type returned by types for their version, as Replay does.
+func (t *Trace) DetectFights(types func(fieldpath.APIVersion) typed.ParseableType, opts FightOptions) (*FightReport, error) {
+	if opts.MinFlips <= 0 {
+		opts.MinFlips = 3
+	}
+	last := map[string]string{}
+	flips := map[string][]flip{}
+	for i, op := range t.Operations {
+		if op.Error != "" || string(op.Result) == "null" {
+			continue
+		}
+		taken, err := op.takenFields(types)
+		if err != nil {
+			return nil, fmt.Errorf("operation %d: %v", i, err)
+		}
+		taken.Iterate(func(p fieldpath.Path) {
+			key := p.String()
+			previous := last[key]
+			last[key] = op.Manager
+			if previous == "" || previous == op.Manager {
+				return
+			}
+			managers := [2]string{previous, op.Manager}
+			if managers[1] < managers[0] {
+				managers[0], managers[1] = managers[1], managers[0]
+			}
+			id := managers[0] + "\x00" + managers[1] + "\x00" + key
+			flips[id] = append(flips[id], flip{path: p.Copy(), managers: managers, op: i})
+		})
+	}
+
+	report := &FightReport{}
+	for _, fs := range flips {
+		ops := busiestWindow(fs, opts.Window)
+		if len(ops) < opts.MinFlips {
+			continue
+		}
+		report.Fights = append(report.Fights, Fight{Path: fs[0].path, Managers: fs[0].managers, Operations: ops})
+	}
+	sort.Slice(report.Fights, func(i, j int) bool {
+		a, b := report.Fights[i], report.Fights[j]
+		if a.Managers != b.Managers {
+			if a.Managers[0] != b.Managers[0] {
+				return a.Managers[0] < b.Managers[0]
+			}
+			return a.Managers[1] < b.Managers[1]
+		}
+		return a.Path.Compare(b.Path) < 0
+	})
+	return report, nil
+}
+
+// busiestWindow returns the operations of the window of the given size
+// with the most flips, the first one if several have as many.
+func busiestWindow(fs []flip, window int) []int {
+	best, bestStart := 0, 0
+	start := 0
+	for end := range fs {
+		for window > 0 && fs[end].op-fs[start].op >= window {
+			start++
+		}
+		if n := end - start + 1; n > best {
+			best, bestStart = n, start
+		}
+	}
+	ops := make([]int, 0, best)
+	for _, f := range fs[bestStart : bestStart+best] {
+		ops = append(ops, f.op)
+	}
+	return ops
+}
+
+// takenFields returns the fields whose value op changed, and the fields
+// whose ownership the manager of op took from other managers.
+func (op RecordedOperation) takenFields(types func(fieldpath.APIVersion) typed.ParseableType) (*fieldpath.Set, error) {
+	pt := types(op.Version)
+	live, err := pt.FromYAML(typed.YAMLObject(op.Live), typed.AllowDuplicates)
+	if err != nil {
+		return nil, fmt.Errorf("invalid live object: %v", err)
+	}
+	result, err := pt.FromYAML(typed.YAMLObject(op.Result), typed.AllowDuplicates)
+	if err != nil {
+		return nil, fmt.Errorf("invalid result: %v", err)
+	}
+	comparison, err := live.Compare(result)
+	if err != nil {
+		return nil, err
+	}
+	taken := comparison.Modified.Union(comparison.Added).Union(comparison.Removed)
+
+	before, err := fieldpath.DecodeManagedFields(op.Managers)
+	if err != nil {
+		return nil, err
+	}
+	after, err := fieldpath.DecodeManagedFields(op.ResultManagers)
+	if err != nil {
+		return nil, err
+	}
+	gained := fieldpath.NewSet()
+	if set, ok := after[op.Manager]; ok {
+		gained = set.Set()
+		if previous, ok := before[op.Manager]; ok {
+			gained = gained.Difference(previous.Set())
+		}
+	}
+	for name, set := range before {
+		if name == op.Manager {
+			continue
+		}
+		lost := set.Set()
+		if remaining, ok := after[name]; ok {
+			lost = lost.Difference(remaining.Set())
+		}
+		taken = taken.Union(gained.Intersection(lost))
+	}
+	return taken, nil
+}
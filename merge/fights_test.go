@@ -0,0 +1,119 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package merge_test
+
+import (
+	"reflect"
+	"testing"
+
+	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
+	"sigs.k8s.io/structured-merge-diff/v4/merge"
+	"sigs.k8s.io/structured-merge-diff/v4/typed"
+)
+
+func TestDetectFights(t *testing.T) {
+	updater := &merge.Updater{Converter: &specificVersionConverter{
+		AcceptedVersions: []fieldpath.APIVersion{"v1"},
+	}}
+	recorder := merge.NewRecorder(updater)
+	pt := leafFieldsParser.Type("v1")
+
+	live, err := pt.FromUnstructured(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	managers := fieldpath.ManagedFields{}
+	ops := []struct {
+		manager string
+		object  typed.YAMLObject
+		apply   bool
+	}{
+		{"controller-a", `{"numeric": 1, "string": "a"}`, false},
+		{"controller-b", `{"numeric": 2, "string": "a"}`, false},
+		{"controller-a", `{"numeric": 1, "string": "a"}`, false},
+		{"controller-b", `{"numeric": 2, "string": "a"}`, false},
+		{"applier", `{"bool": true}`, true},
+		{"controller-a", `{"numeric": 1, "string": "a", "bool": true}`, false},
+		{"applier", `{"bool": true}`, true},
+	}
+	for _, op := range ops {
+		obj, err := pt.FromYAML(op.object)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var newLive *typed.TypedValue
+		if op.apply {
+			newLive, managers, err = recorder.Apply(live, obj, "v1", managers, op.manager, false)
+		} else {
+			newLive, managers, err = recorder.Update(live, obj, "v1", managers, op.manager)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if newLive != nil {
+			live = newLive
+		}
+	}
+	types := func(fieldpath.APIVersion) typed.ParseableType { return pt }
+
+	tests := []struct {
+		name     string
+		opts     merge.FightOptions
+		expected []merge.Fight
+	}{{
+		name: "whole trace",
+		expected: []merge.Fight{{
+			Path:       _P("numeric"),
+			Managers:   [2]string{"controller-a", "controller-b"},
+			Operations: []int{1, 2, 3, 5},
+		}},
+	}, {
+		name: "window",
+		opts: merge.FightOptions{Window: 3},
+		expected: []merge.Fight{{
+			Path:       _P("numeric"),
+			Managers:   [2]string{"controller-a", "controller-b"},
+			Operations: []int{1, 2, 3},
+		}},
+	}, {
+		name: "small window",
+		opts: merge.FightOptions{Window: 2},
+	}, {
+		name: "more flips",
+		opts: merge.FightOptions{MinFlips: 5},
+	}, {
+		name: "fewer flips",
+		opts: merge.FightOptions{MinFlips: 1},
+		expected: []merge.Fight{{
+			Path:       _P("numeric"),
+			Managers:   [2]string{"controller-a", "controller-b"},
+			Operations: []int{1, 2, 3, 5},
+		}},
+	}}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := recorder.Trace().DetectFights(types, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(report.Fights, tt.expected) {
+				t.Errorf("expected fights:\n%v\ngot:\n%v", tt.expected, report.Fights)
+			}
+		})
+	}
+}
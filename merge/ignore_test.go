@@ -273,6 +273,86 @@ func TestIgnoredFields(t *testing.T) {
 	}
 }
 
+func TestIgnored(t *testing.T) {
+	tests := map[string]TestCase{
+		"update_does_not_own_ignored": {
+			APIVersion: "v1",
+			Ops: []Operation{
+				Update{
+					Manager:    "default",
+					APIVersion: "v1",
+					Object:     `{"numeric": 1, "obj": {"string": "foo", "numeric": 2}}`,
+				},
+			},
+			Object: `{"numeric": 1, "obj": {"string": "foo", "numeric": 2}}`,
+			Managed: fieldpath.ManagedFields{
+				"default": fieldpath.NewVersionedSet(
+					_NS(
+						_P("numeric"),
+					),
+					"v1",
+					false,
+				),
+			},
+			Ignored: _NS(
+				_P("obj"),
+			),
+		},
+		"apply_does_not_conflict_on_ignored": {
+			APIVersion: "v1",
+			Ops: []Operation{
+				Apply{
+					Manager:    "apply-one",
+					APIVersion: "v1",
+					Object: `
+						numeric: 1
+						string: "a"
+					`,
+				},
+				Apply{
+					Manager:    "apply-two",
+					APIVersion: "v1",
+					Object: `
+						numeric: 1
+						string: "b"
+					`,
+				},
+			},
+			Object: `
+				numeric: 1
+				string: "b"
+			`,
+			Managed: fieldpath.ManagedFields{
+				"apply-one": fieldpath.NewVersionedSet(
+					_NS(
+						_P("numeric"),
+					),
+					"v1",
+					true,
+				),
+				"apply-two": fieldpath.NewVersionedSet(
+					_NS(
+						_P("numeric"),
+					),
+					"v1",
+					true,
+				),
+			},
+			Ignored: _NS(
+				_P("string"),
+			),
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if err := test.Test(DeducedParser); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
 func TestFilteredFieldsUsesVersions(t *testing.T) {
 	tests := map[string]TestCase{
 		"does_use_ignored_fields_versions": {
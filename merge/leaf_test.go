@@ -17,6 +17,7 @@ limitations under the License.
 package merge_test
 
 import (
+	"strings"
 	"testing"
 
 	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
@@ -47,6 +48,75 @@ var leafFieldsParser = func() Parser {
 
 func TestUpdateLeaf(t *testing.T) {
 	tests := map[string]TestCase{
+		"apply_normalized_string": {
+			Ops: []Operation{
+				Apply{
+					Manager:    "apply-one",
+					Object:     `string: "A"`,
+					APIVersion: "v1",
+				},
+				Apply{
+					Manager:    "apply-two",
+					Object:     `string: "a"`,
+					APIVersion: "v1",
+				},
+			},
+			Object:     `string: "a"`,
+			APIVersion: "v1",
+			Managed: fieldpath.ManagedFields{
+				"apply-one": fieldpath.NewVersionedSet(
+					_NS(
+						_P("string"),
+					),
+					"v1",
+					true,
+				),
+				"apply-two": fieldpath.NewVersionedSet(
+					_NS(
+						_P("string"),
+					),
+					"v1",
+					true,
+				),
+			},
+			StringNormalizers: []typed.StringNormalizer{{
+				Fields:    fieldpath.MakePrefixMatcherOrDie("string"),
+				Normalize: strings.ToLower,
+			}},
+		},
+		"apply_within_float_tolerance": {
+			Ops: []Operation{
+				Apply{
+					Manager:    "apply-one",
+					Object:     `numeric: 1.5`,
+					APIVersion: "v1",
+				},
+				Apply{
+					Manager:    "apply-two",
+					Object:     `numeric: 1.5000001`,
+					APIVersion: "v1",
+				},
+			},
+			Object:     `numeric: 1.5000001`,
+			APIVersion: "v1",
+			Managed: fieldpath.ManagedFields{
+				"apply-one": fieldpath.NewVersionedSet(
+					_NS(
+						_P("numeric"),
+					),
+					"v1",
+					true,
+				),
+				"apply-two": fieldpath.NewVersionedSet(
+					_NS(
+						_P("numeric"),
+					),
+					"v1",
+					true,
+				),
+			},
+			FloatTolerance: 1e-3,
+		},
 		"apply_twice": {
 			Ops: []Operation{
 				Apply{
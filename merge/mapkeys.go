@@ -0,0 +1,153 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package merge
+
+import (
+	"fmt"
+	"strings"
+
+	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
+	"sigs.k8s.io/structured-merge-diff/v4/typed"
+	"sigs.k8s.io/structured-merge-diff/v4/value"
+)
+
+// MapKeyPolicy is how the keys of some maps of the objects, like their
+// labels and annotations, are owned: the managers only own the keys they
+// set, and some keys, usually set by servers, are never owned.
+type MapKeyPolicy struct {
+	// Maps are the paths of the maps, from the root of the objects. They
+	// can only be made of field names.
+	Maps []fieldpath.Path
+
+	// IgnoredKeyPrefixes are the prefixes of the keys of the maps which
+	// never conflict and are never owned, like the Ignored fields of the
+	// Updater.
+	IgnoredKeyPrefixes []string
+}
+
+// MetadataMapKeyPolicy is the MapKeyPolicy of the labels and annotations of
+// Kubernetes objects, which ignores the annotations maintained by kubectl
+// and the controllers.
+var MetadataMapKeyPolicy = MapKeyPolicy{
+	Maps: []fieldpath.Path{
+		fieldpath.MakePathOrDie("metadata", "labels"),
+		fieldpath.MakePathOrDie("metadata", "annotations"),
+	},
+	IgnoredKeyPrefixes: []string{
+		"kubectl.kubernetes.io/last-applied-configuration",
+		"deployment.kubernetes.io/",
+		"pv.kubernetes.io/",
+		"endpoints.kubernetes.io/last-change-trigger-time",
+	},
+}
+
+// ParserOption returns the option which makes the maps of the policy
+// granular in the schemas given to typed.NewParser, even if they are
+// atomic.
+func (p MapKeyPolicy) ParserOption() typed.ParserOption {
+	return typed.WithGranularMaps(p.Maps...)
+}
+
+// Filter returns a filter which removes the ignored keys of the maps, and
+// their children, from sets.
+func (p MapKeyPolicy) Filter() fieldpath.Filter {
+	return mapKeyFilter{p}
+}
+
+type mapKeyFilter struct {
+	policy MapKeyPolicy
+}
+
+func (f mapKeyFilter) Filter(set *fieldpath.Set) *fieldpath.Set {
+	if len(f.policy.IgnoredKeyPrefixes) == 0 {
+		return set
+	}
+	ignored := fieldpath.NewSet()
+	set.Iterate(func(path fieldpath.Path) {
+		if _, key, ok := f.policy.key(path, false); ok && f.policy.ignored(key) {
+			ignored.Insert(path.Copy())
+		}
+	})
+	if ignored.Empty() {
+		return set
+	}
+	return set.Difference(ignored)
+}
+
+// key returns the map and the key of path, if path is a key of one of the
+// maps, or with children, below one.
+func (p MapKeyPolicy) key(path fieldpath.Path, exact bool) (fieldpath.Path, string, bool) {
+	for _, m := range p.Maps {
+		if len(path) <= len(m) || (exact && len(path) != len(m)+1) {
+			continue
+		}
+		if !path[:len(m)].Equals(m) || path[len(m)].FieldName == nil {
+			continue
+		}
+		return m, *path[len(m)].FieldName, true
+	}
+	return nil, "", false
+}
+
+func (p MapKeyPolicy) ignored(key string) bool {
+	for _, prefix := range p.IgnoredKeyPrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// MapKeyConflict is a conflict on a key of one of the maps of a
+// MapKeyPolicy.
+type MapKeyConflict struct {
+	Conflict
+	// Map is the path of the map.
+	Map fieldpath.Path
+	Key string
+}
+
+// Error formats the conflict with the values of the key, if they are known,
+// e.g. `conflict with "kubectl" on key "app" of .metadata.labels: applied
+// "web", live "api"`.
+func (c MapKeyConflict) Error() string {
+	msg := fmt.Sprintf("conflict with %q on key %q of %v", c.Manager, c.Key, c.Map)
+	switch {
+	case c.Applied == nil && c.Live == nil:
+		return msg
+	case c.Applied == nil:
+		return fmt.Sprintf("%v: removed, live %v", msg, value.ToString(c.Live))
+	case c.Live == nil:
+		return fmt.Sprintf("%v: applied %v", msg, value.ToString(c.Applied))
+	}
+	return fmt.Sprintf("%v: applied %v, live %v", msg, value.ToString(c.Applied), value.ToString(c.Live))
+}
+
+// KeyConflicts splits conflicts between the conflicts on the keys of the
+// maps of the policy, and the others.
+func (p MapKeyPolicy) KeyConflicts(conflicts Conflicts) ([]MapKeyConflict, Conflicts) {
+	var keys []MapKeyConflict
+	var others Conflicts
+	for _, c := range conflicts {
+		if m, key, ok := p.key(c.Path, true); ok {
+			keys = append(keys, MapKeyConflict{Conflict: c, Map: m, Key: key})
+		} else {
+			others = append(others, c)
+		}
+	}
+	return keys, others
+}
@@ -0,0 +1,126 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package merge_test
+
+import (
+	"errors"
+	"testing"
+
+	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
+	"sigs.k8s.io/structured-merge-diff/v4/merge"
+	"sigs.k8s.io/structured-merge-diff/v4/typed"
+)
+
+func TestMapKeyPolicy(t *testing.T) {
+	policy := merge.MetadataMapKeyPolicy
+	parser, err := typed.NewParser(`types:
+- name: v1
+  map:
+    fields:
+    - name: metadata
+      type:
+        namedType: objectMeta
+    - name: replicas
+      type:
+        scalar: numeric
+- name: objectMeta
+  map:
+    fields:
+    - name: labels
+      type:
+        map:
+          elementType:
+            scalar: string
+          elementRelationship: atomic
+    - name: annotations
+      type:
+        map:
+          elementType:
+            scalar: string
+          elementRelationship: atomic
+`, policy.ParserOption())
+	if err != nil {
+		t.Fatal(err)
+	}
+	pt := parser.Type("v1")
+	updater := (&merge.UpdaterBuilder{
+		Converter: &specificVersionConverter{AcceptedVersions: []fieldpath.APIVersion{"v1"}},
+		MapKeys:   &policy,
+	}).BuildUpdater()
+	apply := func(live *typed.TypedValue, managers fieldpath.ManagedFields, manager string, config typed.YAMLObject) (*typed.TypedValue, fieldpath.ManagedFields, error) {
+		t.Helper()
+		tv, err := pt.FromYAML(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if live == nil {
+			if live, err = pt.FromYAML("{}"); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return updater.Apply(live, tv, "v1", managers, manager, false)
+	}
+
+	live, managers, err := apply(nil, fieldpath.ManagedFields{}, "a", `
+metadata:
+  labels: {app: web}
+  annotations: {note: a, deployment.kubernetes.io/revision: "1"}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	live, managers, err = apply(live, managers, "b", `
+metadata:
+  labels: {team: x}
+  annotations: {deployment.kubernetes.io/revision: "2"}
+`)
+	if err != nil {
+		t.Fatalf("expected the keys of other managers and the ignored keys not to conflict: %v", err)
+	}
+	want := fieldpath.ManagedFields{
+		"a": fieldpath.NewVersionedSet(_NS(
+			_P("metadata", "labels", "app"),
+			_P("metadata", "annotations", "note"),
+		), "v1", true),
+		"b": fieldpath.NewVersionedSet(_NS(
+			_P("metadata", "labels", "team"),
+		), "v1", true),
+	}
+	if !managers.Equals(want) {
+		t.Errorf("expected managers:\n%v\ngot:\n%v", want, managers)
+	}
+
+	_, _, err = apply(live, managers, "b", `
+metadata:
+  labels: {team: x, app: api}
+`)
+	var conflicts merge.Conflicts
+	if !errors.As(err, &conflicts) {
+		t.Fatalf("expected conflicts, got %v", err)
+	}
+	keys, others := policy.KeyConflicts(conflicts)
+	if len(others) != 0 {
+		t.Errorf("expected only key conflicts, got %v", others)
+	}
+	if len(keys) != 1 {
+		t.Fatalf("expected one key conflict, got %v", keys)
+	}
+	wantMsg := `conflict with "a" on key "app" of .metadata.labels: applied "api", live "web"`
+	if got := keys[0].Error(); got != wantMsg {
+		t.Errorf("expected %q, got %q", wantMsg, got)
+	}
+}
@@ -1641,6 +1641,12 @@ var atomicMapParser = func() Parser {
       - name: atomicMap
         type:
           namedType: atomicMap
+      - name: labels
+        type:
+          map:
+            elementType:
+              scalar: string
+            elementRelationship: atomic
 - name: atomicMap
   map:
     fields:
@@ -1705,6 +1711,71 @@ func TestMultipleApplierAtomicMaps(t *testing.T) {
 				),
 			},
 		},
+		"map of scalars": {
+			Ops: []Operation{
+				Apply{
+					Manager:    "apply-one",
+					APIVersion: "v1",
+					Object: `
+						labels:
+						  a: p
+						  b: q
+					`,
+				},
+				Apply{
+					Manager:    "apply-two",
+					APIVersion: "v1",
+					Object: `
+						labels:
+						  a: p
+					`,
+					Conflicts: merge.Conflicts{
+						merge.Conflict{Manager: "apply-one", Path: _P("labels")},
+					},
+				},
+				Apply{
+					Manager:    "apply-two",
+					APIVersion: "v1",
+					Object: `
+						labels:
+						  b: q
+						  a: p
+					`,
+				},
+				Apply{
+					Manager:    "apply-one",
+					APIVersion: "v1",
+					Object: `
+						atomicMap:
+						  field1: a
+					`,
+				},
+			},
+			Object: `
+				atomicMap:
+				  field1: a
+				labels:
+				  a: p
+				  b: q
+			`,
+			APIVersion: "v1",
+			Managed: fieldpath.ManagedFields{
+				"apply-one": fieldpath.NewVersionedSet(
+					_NS(
+						_P("atomicMap"),
+					),
+					"v1",
+					true,
+				),
+				"apply-two": fieldpath.NewVersionedSet(
+					_NS(
+						_P("labels"),
+					),
+					"v1",
+					true,
+				),
+			},
+		},
 	}
 	for name, test := range tests {
 		t.Run(name, func(t *testing.T) {
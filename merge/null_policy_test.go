@@ -0,0 +1,87 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package merge_test
+
+import (
+	"testing"
+
+	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
+	. "sigs.k8s.io/structured-merge-diff/v4/internal/fixture"
+	"sigs.k8s.io/structured-merge-diff/v4/schema"
+)
+
+func TestApplyNullPolicy(t *testing.T) {
+	tests := map[string]TestCase{
+		"null_deletes": {
+			Ops: []Operation{
+				Apply{
+					Manager:    "default",
+					APIVersion: "v1",
+					Object:     `{"numeric": 1, "string": "a"}`,
+				},
+				Apply{
+					Manager:    "default",
+					APIVersion: "v1",
+					Object:     `{"numeric": 1, "string": null}`,
+				},
+			},
+			Object: `{"numeric": 1}`,
+			Managed: fieldpath.ManagedFields{
+				"default": fieldpath.NewVersionedSet(_NS(_P("numeric")), "v1", true),
+			},
+			APIVersion: "v1",
+			NullPolicy: schema.NullDeletes,
+		},
+		"null_is_value": {
+			Ops: []Operation{
+				Apply{
+					Manager:    "default",
+					APIVersion: "v1",
+					Object:     `{"numeric": 1, "string": null}`,
+				},
+			},
+			Object: `{"numeric": 1, "string": null}`,
+			Managed: fieldpath.ManagedFields{
+				"default": fieldpath.NewVersionedSet(_NS(_P("numeric"), _P("string")), "v1", true),
+			},
+			APIVersion: "v1",
+			NullPolicy: schema.NullIsValue,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if err := test.Test(leafFieldsParser); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+
+	invalid := TestCase{
+		Ops: []Operation{
+			Apply{
+				Manager:    "default",
+				APIVersion: "v1",
+				Object:     `{"string": null}`,
+			},
+		},
+		NullPolicy: schema.NullIsInvalid,
+	}
+	if err := invalid.Test(leafFieldsParser); err == nil {
+		t.Error("expected an explicit null to be rejected")
+	}
+}
@@ -0,0 +1,134 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package merge_test
+
+import (
+	"testing"
+
+	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
+	. "sigs.k8s.io/structured-merge-diff/v4/internal/fixture"
+)
+
+func TestOwnership(t *testing.T) {
+	ops := []Operation{
+		Update{
+			Manager:    "updater",
+			APIVersion: "v1",
+			Object: `
+				spec:
+				  replicas: 1
+			`,
+		},
+		Apply{
+			Manager:    "applier",
+			APIVersion: "v1",
+			Object: `
+				status:
+				  replicas: 1
+				  ready: 1
+			`,
+		},
+		Apply{
+			Manager:    "other",
+			APIVersion: "v1",
+			Object: `
+				status:
+				  replicas: 1
+			`,
+		},
+		Apply{
+			Manager:    "applier",
+			APIVersion: "v1",
+			Object: `
+				status:
+				  ready: 1
+			`,
+		},
+	}
+	tests := map[string]TestCase{
+		"mixed": {
+			Ownership: fieldpath.MixedOwnership,
+			Managed: fieldpath.ManagedFields{
+				// The update added .spec as a whole.
+				"updater": fieldpath.NewVersionedSet(_NS(
+					_P("spec"),
+					_P("spec", "replicas"),
+				), "v1", false),
+				"applier": fieldpath.NewVersionedSet(_NS(
+					_P("status", "ready"),
+				), "v1", true),
+				"other": fieldpath.NewVersionedSet(_NS(
+					_P("status", "replicas"),
+				), "v1", true),
+			},
+		},
+		"leaf": {
+			Ownership: fieldpath.LeafOwnership,
+			Managed: fieldpath.ManagedFields{
+				"updater": fieldpath.NewVersionedSet(_NS(
+					_P("spec", "replicas"),
+				), "v1", false),
+				"applier": fieldpath.NewVersionedSet(_NS(
+					_P("status", "ready"),
+				), "v1", true),
+				"other": fieldpath.NewVersionedSet(_NS(
+					_P("status", "replicas"),
+				), "v1", true),
+			},
+		},
+		"node": {
+			Ownership: fieldpath.NodeOwnership,
+			Managed: fieldpath.ManagedFields{
+				"updater": fieldpath.NewVersionedSet(_NS(
+					_P("spec"),
+					_P("spec", "replicas"),
+				), "v1", false),
+				// Both appliers own .status, which doesn't conflict.
+				"applier": fieldpath.NewVersionedSet(_NS(
+					_P("status"),
+					_P("status", "ready"),
+				), "v1", true),
+				"other": fieldpath.NewVersionedSet(_NS(
+					_P("status"),
+					_P("status", "replicas"),
+				), "v1", true),
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			test.Ops = ops
+			test.APIVersion = "v1"
+			test.Object = `
+				spec:
+				  replicas: 1
+				status:
+				  replicas: 1
+				  ready: 1
+			`
+			if err := test.Test(SameVersionParser{T: subresourceParser.Type("v1")}); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+
+	managers := tests["mixed"].Managed.WithOwnership(fieldpath.NodeOwnership)
+	if !managers.Equals(tests["node"].Managed) {
+		t.Errorf("expected migrated managers:\n%v\ngot:\n%v", tests["node"].Managed, managers)
+	}
+}
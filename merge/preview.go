@@ -0,0 +1,66 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package merge
+
+import (
+	"fmt"
+
+	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
+	"sigs.k8s.io/structured-merge-diff/v4/typed"
+)
+
+// ApplyPreview is the outcome of an apply computed by PreviewApply, without
+// changing anything.
+type ApplyPreview struct {
+	// Conflicts are the conflicts an apply without force would fail with,
+	// if any.
+	Conflicts Conflicts
+	// Result and Managers are the object and managers an apply would
+	// result in, forcing the conflicts if there are any. Result is the live
+	// object if the apply changes nothing.
+	Result   *typed.TypedValue
+	Managers fieldpath.ManagedFields
+	// Diff is the difference between the live object and Result, rendered
+	// by typed.TypedValue.Diff. It is empty if the apply changes nothing.
+	Diff string
+}
+
+// PreviewApply computes the result of applying configObject as manager, and
+// its conflicts, as in a server-side dry run. Unlike Apply, it never fails
+// because of conflicts, and doesn't modify managers. opts configure the
+// rendering of the diff.
+func (s *Updater) PreviewApply(liveObject, configObject *typed.TypedValue, version fieldpath.APIVersion, managers fieldpath.ManagedFields, manager string, opts ...typed.DiffOption) (*ApplyPreview, error) {
+	preview := &ApplyPreview{}
+	result, newManagers, err := s.Apply(liveObject, configObject, version, managers.Copy(), manager, false)
+	if conflicts, ok := err.(Conflicts); ok {
+		preview.Conflicts = conflicts
+		result, newManagers, err = s.Apply(liveObject, configObject, version, managers.Copy(), manager, true)
+	}
+	if err != nil {
+		return nil, err
+	}
+	preview.Managers = newManagers
+	if result == nil {
+		preview.Result = liveObject
+		return preview, nil
+	}
+	preview.Result = result
+	if preview.Diff, err = liveObject.Diff(result, opts...); err != nil {
+		return nil, fmt.Errorf("failed to render diff: %v", err)
+	}
+	return preview, nil
+}
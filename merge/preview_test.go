@@ -0,0 +1,84 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package merge_test
+
+import (
+	"testing"
+
+	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
+	"sigs.k8s.io/structured-merge-diff/v4/merge"
+)
+
+func TestPreviewApply(t *testing.T) {
+	updater := &merge.Updater{Converter: &specificVersionConverter{
+		AcceptedVersions: []fieldpath.APIVersion{"v1"},
+	}}
+	pt := leafFieldsParser.Type("v1")
+	empty, err := pt.FromUnstructured(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	updated, err := pt.FromYAML(`{"numeric": 1, "bool": true}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	live, managers, err := updater.Update(empty, updated, "v1", fieldpath.ManagedFields{}, "controller")
+	if err != nil {
+		t.Fatal(err)
+	}
+	config, err := pt.FromYAML(`{"numeric": 2, "string": "a"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	preview, err := updater.PreviewApply(live, config, "v1", managers, "applier")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedConflicts := merge.Conflicts{{Manager: "controller", Path: _P("numeric")}}
+	if !preview.Conflicts.Equals(expectedConflicts) {
+		t.Errorf("expected conflicts %v, got %v", expectedConflicts, preview.Conflicts)
+	}
+	expectedDiff := `@@ -1,2 +1,3 @@
+-numeric: 1
++numeric: 2
++string: a
+ bool: true
+`
+	if preview.Diff != expectedDiff {
+		t.Errorf("expected diff:\n%v\ngot:\n%v", expectedDiff, preview.Diff)
+	}
+	if !preview.Managers["applier"].Set().Equals(_NS(_P("numeric"), _P("string"))) {
+		t.Errorf("unexpected managers: %v", preview.Managers)
+	}
+	if _, ok := managers["applier"]; ok {
+		t.Errorf("expected the managers not to be modified, got %v", managers)
+	}
+
+	// Once applied, previewing the same apply again changes nothing.
+	live, managers, err = updater.Apply(live, config, "v1", managers, "applier", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	preview, err = updater.PreviewApply(live, config, "v1", managers, "applier")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(preview.Conflicts) != 0 || preview.Diff != "" || preview.Result != live {
+		t.Errorf("expected an empty preview, got %+v", preview)
+	}
+}
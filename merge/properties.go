@@ -0,0 +1,410 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package merge
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+
+	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
+	"sigs.k8s.io/structured-merge-diff/v4/schema"
+	"sigs.k8s.io/structured-merge-diff/v4/typed"
+	"sigs.k8s.io/structured-merge-diff/v4/value"
+)
+
+// PropertyOption configures CheckMergeProperties.
+type PropertyOption func(*propertyOptions)
+
+type propertyOptions struct {
+	seed       int64
+	iterations int
+	maxDepth   int
+}
+
+// WithPropertySeed seeds the generation of objects, which is otherwise
+// seeded with 1, so that runs are reproducible.
+func WithPropertySeed(seed int64) PropertyOption {
+	return func(opts *propertyOptions) {
+		opts.seed = seed
+	}
+}
+
+// WithPropertyIterations sets the number of generated triples of objects,
+// 100 by default.
+func WithPropertyIterations(iterations int) PropertyOption {
+	return func(opts *propertyOptions) {
+		opts.iterations = iterations
+	}
+}
+
+// WithPropertyMaxDepth sets the maximum nesting of the generated objects,
+// 6 by default.
+func WithPropertyMaxDepth(depth int) PropertyOption {
+	return func(opts *propertyOptions) {
+		opts.maxDepth = depth
+	}
+}
+
+// SchemaWarning is a schema construct known to break the properties of
+// merge.
+type SchemaWarning struct {
+	// Path locates the construct in the type, e.g. ".spec.ports[]" for
+	// the items of a list, or ".labels.*" for the values of a map.
+	Path string
+	// Reason explains how the construct breaks merge.
+	Reason string
+}
+
+func (w SchemaWarning) String() string {
+	return fmt.Sprintf("%v: %v", w.Path, w.Reason)
+}
+
+// PropertyViolation is an example of objects for which merge doesn't have
+// the expected property.
+type PropertyViolation struct {
+	// Property is the violated property: "identity", "idempotency" or
+	// "associativity".
+	Property string
+	// Objects are the merged objects.
+	Objects []*typed.TypedValue
+	// Fields are the fields of the two results which should be equal but
+	// differ.
+	Fields *fieldpath.Set
+}
+
+func (v PropertyViolation) String() string {
+	return fmt.Sprintf("%v is violated for fields:\n%v", v.Property, v.Fields)
+}
+
+// PropertyReport is the result of CheckMergeProperties.
+type PropertyReport struct {
+	Warnings   []SchemaWarning
+	Violations []PropertyViolation
+}
+
+// OK returns true if no warning or violation was found.
+func (r *PropertyReport) OK() bool {
+	return len(r.Warnings) == 0 && len(r.Violations) == 0
+}
+
+// CheckMergeProperties helps schema authors check that merging objects of
+// the type named typeName in s behaves as users expect. It looks for schema
+// constructs known to cause surprises, such as sets of numbers, then
+// generates random objects a, b and c of the type and checks that:
+//   - merging with an empty object changes nothing (identity);
+//   - merging a with itself gives a (idempotency);
+//   - merge(merge(a, b), c) equals merge(a, merge(b, c)) (associativity).
+//
+// Merge isn't commutative, since the right hand side wins. Only the first
+// violation of each property is reported.
+func CheckMergeProperties(s *schema.Schema, typeName string, opts ...PropertyOption) (*PropertyReport, error) {
+	options := propertyOptions{seed: 1, iterations: 100, maxDepth: 6}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	tr := schema.TypeRef{NamedType: &typeName}
+	if _, ok := s.Resolve(tr); !ok {
+		return nil, fmt.Errorf("no type named %q in schema", typeName)
+	}
+	report := &PropertyReport{Warnings: lintMergeSchema(s, tr)}
+
+	pt := typed.ParseableType{Schema: s, TypeRef: tr}
+	var empty *typed.TypedValue
+	if atom, _ := s.Resolve(tr); atom.Map != nil {
+		var err error
+		if empty, err = pt.FromUnstructured(map[string]interface{}{}); err != nil {
+			return nil, err
+		}
+	}
+	g := objectGenerator{schema: s, rand: rand.New(rand.NewSource(options.seed)), maxDepth: options.maxDepth}
+	violated := map[string]bool{}
+	check := func(property string, objects []*typed.TypedValue, lhs, rhs *typed.TypedValue) error {
+		if violated[property] {
+			return nil
+		}
+		c, err := lhs.Compare(rhs)
+		if err != nil {
+			return err
+		}
+		if c.IsSame() {
+			return nil
+		}
+		violated[property] = true
+		report.Violations = append(report.Violations, PropertyViolation{
+			Property: property,
+			Objects:  objects,
+			Fields:   c.Added.Union(c.Modified).Union(c.Removed),
+		})
+		return nil
+	}
+	failures := 0
+	for i := 0; i < options.iterations; i++ {
+		var objects []*typed.TypedValue
+		for len(objects) < 3 {
+			tv, err := pt.FromUnstructured(g.generate(tr, 0))
+			if err != nil {
+				// The generator doesn't know every constraint, e.g.
+				// unions, skip the objects it gets wrong.
+				failures++
+				if failures > 10*options.iterations {
+					return nil, fmt.Errorf("unable to generate valid objects: %v", err)
+				}
+				continue
+			}
+			objects = append(objects, tv)
+		}
+		a, b, c := objects[0], objects[1], objects[2]
+		if empty != nil {
+			for _, pair := range [][2]*typed.TypedValue{{empty, a}, {a, empty}} {
+				merged, err := pair[0].Merge(pair[1])
+				if err != nil {
+					return nil, err
+				}
+				if err := check("identity", pair[:], a, merged); err != nil {
+					return nil, err
+				}
+			}
+		}
+		aa, err := a.Merge(a)
+		if err != nil {
+			return nil, err
+		}
+		if err := check("idempotency", []*typed.TypedValue{a, a}, a, aa); err != nil {
+			return nil, err
+		}
+		ab, err := a.Merge(b)
+		if err != nil {
+			return nil, err
+		}
+		abc1, err := ab.Merge(c)
+		if err != nil {
+			return nil, err
+		}
+		bc, err := b.Merge(c)
+		if err != nil {
+			return nil, err
+		}
+		abc2, err := a.Merge(bc)
+		if err != nil {
+			return nil, err
+		}
+		if err := check("associativity", objects, abc1, abc2); err != nil {
+			return nil, err
+		}
+	}
+	return report, nil
+}
+
+// lintMergeSchema returns the constructs of the type tr which are known to
+// break the properties of merge.
+func lintMergeSchema(s *schema.Schema, tr schema.TypeRef) []SchemaWarning {
+	var warnings []SchemaWarning
+	// visiting holds the named types being visited, to stop at recursions.
+	visiting := map[string]bool{}
+	var visit func(tr schema.TypeRef, path string)
+	visit = func(tr schema.TypeRef, path string) {
+		if tr.NamedType != nil {
+			if visiting[*tr.NamedType] {
+				return
+			}
+			visiting[*tr.NamedType] = true
+			defer delete(visiting, *tr.NamedType)
+		}
+		atom, ok := s.Resolve(tr)
+		if !ok {
+			return
+		}
+		if atom.Scalar != nil && (atom.Map != nil || atom.List != nil) {
+			warnings = append(warnings, SchemaWarning{
+				Path:   path,
+				Reason: "value can be either a scalar or a collection: replacing a collection by a scalar drops its content, so merge isn't associative",
+			})
+		}
+		if atom.List != nil && atom.List.ElementRelationship == schema.Associative {
+			elem, _ := s.Resolve(atom.List.ElementType)
+			if len(atom.List.Keys) == 0 && isNumber(elem) {
+				warnings = append(warnings, SchemaWarning{
+					Path:   path + "[]",
+					Reason: "set of numbers: items are matched by exact value, so floats which only differ by rounding are distinct items, and NaN never matches itself",
+				})
+			}
+			if elem.Map != nil {
+				for _, key := range atom.List.Keys {
+					if sf, ok := elem.Map.FindField(key); ok {
+						if keyAtom, _ := s.Resolve(sf.Type); isNumber(keyAtom) {
+							warnings = append(warnings, SchemaWarning{
+								Path:   path + "[]." + key,
+								Reason: "associative list keyed by a number: floats which only differ by rounding are distinct keys, and NaN never matches itself",
+							})
+						}
+					}
+				}
+			}
+		}
+		if atom.List != nil && atom.List.ElementRelationship != schema.Atomic {
+			visit(atom.List.ElementType, path+"[]")
+		}
+		if atom.Map != nil && atom.Map.ElementRelationship != schema.Atomic {
+			for _, f := range atom.Map.Fields {
+				visit(f.Type, path+"."+f.Name)
+			}
+			if atom.Map.ElementType.NamedType != nil || atom.Map.ElementType.Inlined != (schema.Atom{}) {
+				visit(atom.Map.ElementType, path+".*")
+			}
+		}
+	}
+	visit(tr, "")
+	for i := range warnings {
+		if warnings[i].Path == "" {
+			warnings[i].Path = "."
+		}
+	}
+	sort.Slice(warnings, func(i, j int) bool { return warnings[i].Path < warnings[j].Path })
+	return warnings
+}
+
+func isNumber(a schema.Atom) bool {
+	return a.Scalar != nil && (*a.Scalar == schema.Numeric || *a.Scalar == schema.Untyped) && a.Map == nil && a.List == nil
+}
+
+// objectGenerator generates random objects of a schema. Values are taken
+// from small sets, so that the keys and values of different objects often
+// collide, which is what merge is about.
+type objectGenerator struct {
+	schema   *schema.Schema
+	rand     *rand.Rand
+	maxDepth int
+}
+
+var (
+	generatedStrings = []interface{}{"a", "b", "c"}
+	generatedNumbers = []interface{}{int64(0), int64(1), int64(2), 0.5, 1.5}
+	generatedBools   = []interface{}{true, false}
+	generatedKeys    = []string{"x", "y", "z"}
+)
+
+// generate returns the unstructured form of a random value of type tr.
+func (g *objectGenerator) generate(tr schema.TypeRef, depth int) interface{} {
+	atom, ok := g.schema.Resolve(tr)
+	if !ok {
+		return nil
+	}
+	var choices []func() interface{}
+	if atom.Scalar != nil {
+		choices = append(choices, func() interface{} { return g.scalar(*atom.Scalar) })
+	}
+	if depth < g.maxDepth {
+		if atom.Map != nil {
+			choices = append(choices, func() interface{} { return g.generateMap(atom.Map, depth) })
+		}
+		if atom.List != nil {
+			choices = append(choices, func() interface{} { return g.generateList(atom.List, depth) })
+		}
+	}
+	if len(choices) == 0 {
+		if atom.Map != nil {
+			return map[string]interface{}{}
+		}
+		return []interface{}{}
+	}
+	if depth == 0 && atom.Map != nil {
+		// Objects are maps.
+		return g.generateMap(atom.Map, depth)
+	}
+	return choices[g.rand.Intn(len(choices))]()
+}
+
+func (g *objectGenerator) pick(values []interface{}) interface{} {
+	return values[g.rand.Intn(len(values))]
+}
+
+func (g *objectGenerator) scalar(s schema.Scalar) interface{} {
+	switch s {
+	case schema.String:
+		return g.pick(generatedStrings)
+	case schema.Numeric:
+		return g.pick(generatedNumbers)
+	case schema.Boolean:
+		return g.pick(generatedBools)
+	case schema.NumericOrString:
+		return g.pick(append(append([]interface{}{}, generatedStrings...), generatedNumbers...))
+	}
+	all := append(append(append([]interface{}{}, generatedStrings...), generatedNumbers...), generatedBools...)
+	return g.pick(all)
+}
+
+func (g *objectGenerator) generateMap(m *schema.Map, depth int) map[string]interface{} {
+	out := map[string]interface{}{}
+	for _, f := range m.Fields {
+		if g.rand.Intn(2) == 0 {
+			out[f.Name] = g.generate(f.Type, depth+1)
+		}
+	}
+	if len(m.Fields) == 0 && (m.ElementType.NamedType != nil || m.ElementType.Inlined != (schema.Atom{})) {
+		for _, key := range generatedKeys {
+			if g.rand.Intn(2) == 0 {
+				out[key] = g.generate(m.ElementType, depth+1)
+			}
+		}
+	}
+	return out
+}
+
+func (g *objectGenerator) generateList(l *schema.List, depth int) []interface{} {
+	out := []interface{}{}
+	var seen []value.Value
+	n := g.rand.Intn(4)
+	// Give up on items which collide with previous ones after a few tries.
+	for tries := 0; len(out) < n && tries < 4*n; tries++ {
+		item := g.generate(l.ElementType, depth+1)
+		if l.ElementRelationship != schema.Associative {
+			out = append(out, item)
+			continue
+		}
+		id := item
+		if len(l.Keys) > 0 {
+			m, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			elem, _ := g.schema.Resolve(l.ElementType)
+			key := map[string]interface{}{}
+			for _, k := range l.Keys {
+				if _, ok := m[k]; !ok && elem.Map != nil {
+					if sf, ok := elem.Map.FindField(k); ok {
+						m[k] = g.generate(sf.Type, g.maxDepth)
+					}
+				}
+				key[k] = m[k]
+			}
+			id = key
+		}
+		v := value.NewValueInterface(id)
+		duplicate := false
+		for _, s := range seen {
+			if value.Equals(s, v) {
+				duplicate = true
+			}
+		}
+		if !duplicate {
+			seen = append(seen, v)
+			out = append(out, item)
+		}
+	}
+	return out
+}
@@ -0,0 +1,135 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package merge_test
+
+import (
+	"reflect"
+	"testing"
+
+	"sigs.k8s.io/structured-merge-diff/v4/merge"
+	"sigs.k8s.io/structured-merge-diff/v4/schema"
+	"sigs.k8s.io/structured-merge-diff/v4/typed"
+)
+
+var propertiesParser = func() *typed.Parser {
+	parser, err := typed.NewParser(`types:
+- name: type
+  map:
+    fields:
+    - name: name
+      type:
+        scalar: string
+    - name: labels
+      type:
+        map:
+          elementType:
+            scalar: string
+    - name: finalizers
+      type:
+        list:
+          elementType:
+            scalar: string
+          elementRelationship: associative
+    - name: items
+      type:
+        list:
+          elementType:
+            namedType: item
+          elementRelationship: associative
+          keys:
+          - name
+    - name: args
+      type:
+        list:
+          elementType:
+            scalar: string
+          elementRelationship: atomic
+    - name: child
+      type:
+        namedType: type
+- name: item
+  map:
+    fields:
+    - name: name
+      type:
+        scalar: string
+    - name: value
+      type:
+        scalar: numeric
+`)
+	if err != nil {
+		panic(err)
+	}
+	return parser
+}()
+
+func TestCheckMergeProperties(t *testing.T) {
+	tests := []struct {
+		name       string
+		schema     *schema.Schema
+		typeName   string
+		warnings   []string
+		violations []string
+	}{{
+		name:     "well-behaved",
+		schema:   &propertiesParser.Schema,
+		typeName: "type",
+	}, {
+		name:     "number keys",
+		schema:   &verifyParser.Schema,
+		typeName: "type",
+		warnings: []string{".floats[]", ".items[].key"},
+	}, {
+		name:       "deduced",
+		schema:     typed.DeducedParseableType.Schema,
+		typeName:   *typed.DeducedParseableType.TypeRef.NamedType,
+		warnings:   []string{"."},
+		violations: []string{"associativity"},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			report, err := merge.CheckMergeProperties(test.schema, test.typeName, merge.WithPropertyIterations(200))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var warnings, violations []string
+			for _, w := range report.Warnings {
+				warnings = append(warnings, w.Path)
+			}
+			for _, v := range report.Violations {
+				violations = append(violations, v.Property)
+				if v.Fields.Empty() {
+					t.Errorf("expected the fields violating %v", v.Property)
+				}
+			}
+			if !reflect.DeepEqual(warnings, test.warnings) {
+				t.Errorf("expected warnings %v, got %v", test.warnings, report.Warnings)
+			}
+			if !reflect.DeepEqual(violations, test.violations) {
+				t.Errorf("expected violations %v, got %v", test.violations, report.Violations)
+			}
+			if report.OK() != (len(test.warnings) == 0 && len(test.violations) == 0) {
+				t.Errorf("unexpected OK() %v", report.OK())
+			}
+		})
+	}
+
+	if _, err := merge.CheckMergeProperties(&propertiesParser.Schema, "missing"); err == nil {
+		t.Error("expected a missing type to fail")
+	}
+}
@@ -17,8 +17,10 @@ limitations under the License.
 package merge_test
 
 import (
+	"fmt"
 	"io/ioutil"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	. "sigs.k8s.io/structured-merge-diff/v4/internal/fixture"
@@ -215,3 +217,84 @@ func BenchmarkOperations(b *testing.B) {
 		})
 	}
 }
+
+// containerHeavyPod returns a Pod with the given number of containers, each
+// with environment variables and ports, whose values depend on version.
+func containerHeavyPod(containers int, version string) typed.YAMLObject {
+	var b strings.Builder
+	b.WriteString("apiVersion: v1\nkind: Pod\nmetadata:\n  name: heavy\n  namespace: default\nspec:\n  containers:\n")
+	for i := 0; i < containers; i++ {
+		fmt.Fprintf(&b, "  - name: container-%d\n    image: image-%d:%s\n    env:\n", i, i, version)
+		for j := 0; j < 20; j++ {
+			fmt.Fprintf(&b, "    - name: VAR_%d\n      value: %s-%d\n", j, version, j)
+		}
+		b.WriteString("    ports:\n")
+		for j := 0; j < 5; j++ {
+			fmt.Fprintf(&b, "    - containerPort: %d\n      protocol: TCP\n", 8000+j)
+		}
+	}
+	return typed.YAMLObject(b.String())
+}
+
+func BenchmarkApplyContainerHeavyPod(b *testing.B) {
+	label := typed.YAMLObject("apiVersion: v1\nkind: Pod\nmetadata:\n  name: heavy\n  labels:\n    app: heavy\n")
+	for _, containers := range []int{10, 100} {
+		b.Run(fmt.Sprintf("containers=%d", containers), func(b *testing.B) {
+			tests := []struct {
+				name string
+				ops  []Operation
+			}{
+				{
+					name: "ApplyChanges",
+					ops: []Operation{
+						Apply{
+							Manager:    "applier",
+							APIVersion: "v1",
+							Object:     containerHeavyPod(containers, "v1"),
+						},
+						Apply{
+							Manager:    "applier",
+							APIVersion: "v1",
+							Object:     containerHeavyPod(containers, "v2"),
+						},
+					},
+				},
+				{
+					name: "ApplyLabel",
+					ops: []Operation{
+						Apply{
+							Manager:    "applier",
+							APIVersion: "v1",
+							Object:     containerHeavyPod(containers, "v1"),
+						},
+						Apply{
+							Manager:    "labeler",
+							APIVersion: "v1",
+							Object:     label,
+						},
+						Apply{
+							Manager:    "labeler",
+							APIVersion: "v1",
+							Object:     label,
+						},
+					},
+				},
+			}
+			for _, test := range tests {
+				b.Run(test.name, func(b *testing.B) {
+					tc := TestCase{Ops: test.ops, ShareValues: true}
+					p := SameVersionParser{T: k8s.Type("io.k8s.api.core.v1.Pod")}
+					tc.PreprocessOperations(p)
+
+					b.ReportAllocs()
+					b.ResetTimer()
+					for n := 0; n < b.N; n++ {
+						if err := tc.Bench(p); err != nil {
+							b.Fatal(err)
+						}
+					}
+				})
+			}
+		})
+	}
+}
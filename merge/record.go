@@ -0,0 +1,192 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package merge
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
+	"sigs.k8s.io/structured-merge-diff/v4/typed"
+)
+
+// Trace is a sequence of calls to Update and Apply, recorded by a Recorder.
+// It serializes to JSON, so that it can be attached to bug reports and
+// replayed with Replay.
+type Trace struct {
+	Operations []RecordedOperation `json:"operations"`
+}
+
+// RecordedOperation is a call to Update or Apply, with its inputs and
+// outputs. Objects are in JSON.
+type RecordedOperation struct {
+	// Apply is true for calls to Apply, and false for calls to Update.
+	Apply   bool                 `json:"apply,omitempty"`
+	Manager string               `json:"manager"`
+	Version fieldpath.APIVersion `json:"version"`
+	Force   bool                 `json:"force,omitempty"`
+
+	Live   json.RawMessage `json:"live"`
+	Object json.RawMessage `json:"object"`
+	// Managers are encoded by fieldpath.EncodeManagedFields.
+	Managers json.RawMessage `json:"managers"`
+
+	// Result and ResultManagers are the object and managers returned by
+	// the call, or empty if it failed. Result is null if Apply didn't
+	// change the object.
+	Result         json.RawMessage `json:"result,omitempty"`
+	ResultManagers json.RawMessage `json:"resultManagers,omitempty"`
+	// Error is the error returned by the call, if any.
+	Error string `json:"error,omitempty"`
+}
+
+// Recorder records the calls to Update and Apply of an Updater into a
+// Trace. It can be used concurrently.
+type Recorder struct {
+	updater *Updater
+
+	lock  sync.Mutex
+	trace Trace
+}
+
+// NewRecorder returns a Recorder which passes the calls to updater.
+func NewRecorder(updater *Updater) *Recorder {
+	return &Recorder{updater: updater}
+}
+
+// Update calls Update on the Updater and records the call.
+func (r *Recorder) Update(liveObject, newObject *typed.TypedValue, version fieldpath.APIVersion, managers fieldpath.ManagedFields, manager string) (*typed.TypedValue, fieldpath.ManagedFields, error) {
+	op, err := recordInputs(liveObject, newObject, version, managers, manager)
+	if err != nil {
+		return nil, fieldpath.ManagedFields{}, err
+	}
+	object, managers, updateErr := r.updater.Update(liveObject, newObject, version, managers, manager)
+	return object, managers, r.record(op, object, managers, updateErr)
+}
+
+// Apply calls Apply on the Updater and records the call.
+func (r *Recorder) Apply(liveObject, configObject *typed.TypedValue, version fieldpath.APIVersion, managers fieldpath.ManagedFields, manager string, force bool) (*typed.TypedValue, fieldpath.ManagedFields, error) {
+	op, err := recordInputs(liveObject, configObject, version, managers, manager)
+	if err != nil {
+		return nil, fieldpath.ManagedFields{}, err
+	}
+	op.Apply = true
+	op.Force = force
+	object, managers, applyErr := r.updater.Apply(liveObject, configObject, version, managers, manager, force)
+	return object, managers, r.record(op, object, managers, applyErr)
+}
+
+// Trace returns a copy of the calls recorded so far.
+func (r *Recorder) Trace() *Trace {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return &Trace{Operations: append([]RecordedOperation(nil), r.trace.Operations...)}
+}
+
+func recordInputs(liveObject, object *typed.TypedValue, version fieldpath.APIVersion, managers fieldpath.ManagedFields, manager string) (RecordedOperation, error) {
+	op := RecordedOperation{Manager: manager, Version: version}
+	var err error
+	if op.Live, err = liveObject.ToJSON(); err != nil {
+		return op, fmt.Errorf("failed to record live object: %v", err)
+	}
+	if op.Object, err = object.ToJSON(); err != nil {
+		return op, fmt.Errorf("failed to record object: %v", err)
+	}
+	if op.Managers, err = fieldpath.EncodeManagedFields(managers); err != nil {
+		return op, fmt.Errorf("failed to record managers: %v", err)
+	}
+	return op, nil
+}
+
+// record adds op to the trace with the results of the call, and returns
+// the error of the call.
+func (r *Recorder) record(op RecordedOperation, object *typed.TypedValue, managers fieldpath.ManagedFields, err error) error {
+	if err != nil {
+		op.Error = err.Error()
+	} else {
+		op.Result = json.RawMessage("null")
+		if object != nil {
+			if op.Result, err = object.ToJSON(); err != nil {
+				return fmt.Errorf("failed to record result: %v", err)
+			}
+		}
+		if op.ResultManagers, err = fieldpath.EncodeManagedFields(managers); err != nil {
+			return fmt.Errorf("failed to record result managers: %v", err)
+		}
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.trace.Operations = append(r.trace.Operations, op)
+	return err
+}
+
+// Replay calls Update or Apply on updater with the recorded inputs, which
+// are parsed with the type returned by types for their version. It returns
+// the results of the call, which can then be compared to the recorded ones.
+func (op RecordedOperation) Replay(updater *Updater, types func(fieldpath.APIVersion) typed.ParseableType) (*typed.TypedValue, fieldpath.ManagedFields, error) {
+	pt := types(op.Version)
+	live, err := pt.FromYAML(typed.YAMLObject(op.Live), typed.AllowDuplicates)
+	if err != nil {
+		return nil, fieldpath.ManagedFields{}, fmt.Errorf("invalid live object: %v", err)
+	}
+	object, err := pt.FromYAML(typed.YAMLObject(op.Object), typed.AllowDuplicates)
+	if err != nil {
+		return nil, fieldpath.ManagedFields{}, fmt.Errorf("invalid object: %v", err)
+	}
+	managers, err := fieldpath.DecodeManagedFields(op.Managers)
+	if err != nil {
+		return nil, fieldpath.ManagedFields{}, err
+	}
+	if op.Apply {
+		return updater.Apply(live, object, op.Version, managers, op.Manager, op.Force)
+	}
+	return updater.Update(live, object, op.Version, managers, op.Manager)
+}
+
+// Replay replays every operation of the trace (see RecordedOperation.Replay)
+// and returns an error for the first one whose results differ from the
+// recorded ones.
+func (t *Trace) Replay(updater *Updater, types func(fieldpath.APIVersion) typed.ParseableType) error {
+	for i, op := range t.Operations {
+		object, managers, err := op.Replay(updater, types)
+		if op.Error != "" || err != nil {
+			if err == nil || err.Error() != op.Error {
+				return fmt.Errorf("operation %d: recorded error %q, got %v", i, op.Error, err)
+			}
+			continue
+		}
+		expected, err := fieldpath.DecodeManagedFields(op.ResultManagers)
+		if err != nil {
+			return fmt.Errorf("operation %d: %v", i, err)
+		}
+		if !managers.Equals(expected) {
+			return fmt.Errorf("operation %d: recorded managers:\n%v\ngot:\n%v", i, expected, managers)
+		}
+		result := json.RawMessage("null")
+		if object != nil {
+			if result, err = object.ToJSON(); err != nil {
+				return fmt.Errorf("operation %d: %v", i, err)
+			}
+		}
+		if !bytes.Equal(result, op.Result) {
+			return fmt.Errorf("operation %d: recorded result %s, got %s", i, op.Result, result)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package merge_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
+	"sigs.k8s.io/structured-merge-diff/v4/merge"
+	"sigs.k8s.io/structured-merge-diff/v4/typed"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	updater := &merge.Updater{Converter: &specificVersionConverter{
+		AcceptedVersions: []fieldpath.APIVersion{"v1"},
+	}}
+	recorder := merge.NewRecorder(updater)
+	pt := leafFieldsParser.Type("v1")
+
+	live, err := pt.FromUnstructured(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	managers := fieldpath.ManagedFields{}
+	ops := []struct {
+		manager string
+		object  typed.YAMLObject
+		apply   bool
+	}{
+		{"apply-one", `{"numeric": 1, "string": "a"}`, true},
+		{"controller", `{"numeric": 2, "string": "a"}`, false},
+		{"apply-one", `{"numeric": 1, "string": "a"}`, true},
+	}
+	for i, op := range ops {
+		obj, err := pt.FromYAML(op.object)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var newLive *typed.TypedValue
+		if op.apply {
+			newLive, managers, err = recorder.Apply(live, obj, "v1", managers, op.manager, false)
+		} else {
+			newLive, managers, err = recorder.Update(live, obj, "v1", managers, op.manager)
+		}
+		if i == len(ops)-1 {
+			if _, ok := err.(merge.Conflicts); !ok {
+				t.Fatalf("expected the last apply to conflict, got %v", err)
+			}
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if newLive != nil {
+			live = newLive
+		}
+	}
+
+	data, err := json.Marshal(recorder.Trace())
+	if err != nil {
+		t.Fatal(err)
+	}
+	trace := &merge.Trace{}
+	if err := json.Unmarshal(data, trace); err != nil {
+		t.Fatal(err)
+	}
+	if len(trace.Operations) != len(ops) || trace.Operations[2].Error == "" {
+		t.Fatalf("unexpected trace: %s", data)
+	}
+	types := func(fieldpath.APIVersion) typed.ParseableType { return pt }
+	if err := trace.Replay(updater, types); err != nil {
+		t.Fatal(err)
+	}
+
+	// The replay reports results which differ from the recorded ones.
+	trace.Operations[1].Result = json.RawMessage(`{"numeric":3,"string":"a"}`)
+	if err := trace.Replay(updater, types); err == nil || !strings.Contains(err.Error(), "operation 1") {
+		t.Errorf("expected operation 1 to differ, got %v", err)
+	}
+}
@@ -0,0 +1,242 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package merge
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
+	"sigs.k8s.io/structured-merge-diff/v4/typed"
+	"sigs.k8s.io/structured-merge-diff/v4/value"
+)
+
+// BeforeFirstApplyManager is the default manager to which
+// RepairManagedFields gives the fields owned by no manager, like the one of
+// Kubernetes for the fields of objects which were never applied.
+const BeforeFirstApplyManager = "before-first-apply"
+
+// RepairOptions configures RepairManagedFields.
+type RepairOptions struct {
+	// Version is the version of the live object. Only the managers of this
+	// version are repaired.
+	Version fieldpath.APIVersion
+	// OrphansManager is the manager to which the fields owned by no manager
+	// are given. It defaults to BeforeFirstApplyManager.
+	OrphansManager string
+}
+
+// RekeyedPath is a path whose list keys were fixed by RepairManagedFields.
+type RekeyedPath struct {
+	Manager  string
+	From, To fieldpath.Path
+}
+
+// RepairReport lists the changes made by RepairManagedFields.
+type RepairReport struct {
+	// Dropped are the paths of each manager which the object doesn't have.
+	Dropped map[string]*fieldpath.Set
+	// Rekeyed are the paths whose list keys no longer matched the ones of
+	// the object, sorted by manager.
+	Rekeyed []RekeyedPath
+	// Orphans are the fields owned by no manager, given to the
+	// OrphansManager.
+	Orphans *fieldpath.Set
+	// Skipped are the managers of other versions, which weren't repaired,
+	// sorted. Orphans aren't looked for if there are any, since their
+	// fields can't be compared with the object.
+	Skipped []string
+}
+
+// Empty returns true if nothing was repaired.
+func (r *RepairReport) Empty() bool {
+	return len(r.Dropped) == 0 && len(r.Rekeyed) == 0 && (r.Orphans == nil || r.Orphans.Empty())
+}
+
+func (r *RepairReport) String() string {
+	b := strings.Builder{}
+	managers := make([]string, 0, len(r.Dropped))
+	for manager := range r.Dropped {
+		managers = append(managers, manager)
+	}
+	sort.Strings(managers)
+	for _, manager := range managers {
+		r.Dropped[manager].Iterate(func(p fieldpath.Path) {
+			fmt.Fprintf(&b, "%v: dropped %v\n", manager, p)
+		})
+	}
+	for _, rekeyed := range r.Rekeyed {
+		fmt.Fprintf(&b, "%v: rekeyed %v to %v\n", rekeyed.Manager, rekeyed.From, rekeyed.To)
+	}
+	if r.Orphans != nil {
+		r.Orphans.Iterate(func(p fieldpath.Path) {
+			fmt.Fprintf(&b, "orphan %v\n", p)
+		})
+	}
+	for _, manager := range r.Skipped {
+		fmt.Fprintf(&b, "%v: skipped\n", manager)
+	}
+	return b.String()
+}
+
+// RepairManagedFields returns managers repaired to match the live object,
+// e.g. after a migration or an upgrade which changed the schema, and a
+// report of the changes. The paths which the object doesn't have are
+// dropped, but the ones whose list keys changed, e.g. because a key field
+// was added, are fixed to the key of the only item of the object whose
+// common key fields match. The fields of the object owned by no manager
+// are then given to the OrphansManager. managers isn't modified.
+func RepairManagedFields(live *typed.TypedValue, managers fieldpath.ManagedFields, opts RepairOptions) (fieldpath.ManagedFields, *RepairReport, error) {
+	if opts.OrphansManager == "" {
+		opts.OrphansManager = BeforeFirstApplyManager
+	}
+	liveSet, err := live.ToFieldSet()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get the fields of the object: %v", err)
+	}
+	index := newLivePaths(liveSet)
+
+	names := make([]string, 0, len(managers))
+	for manager := range managers {
+		names = append(names, manager)
+	}
+	sort.Strings(names)
+
+	report := &RepairReport{Dropped: map[string]*fieldpath.Set{}}
+	out := make(fieldpath.ManagedFields, len(managers))
+	owned := make([]*fieldpath.Set, 0, len(names))
+	for _, manager := range names {
+		set := managers[manager]
+		if set.APIVersion() != opts.Version {
+			report.Skipped = append(report.Skipped, manager)
+			out[manager] = set
+			continue
+		}
+		repaired := fieldpath.NewSet()
+		dropped := fieldpath.NewSet()
+		set.Set().Iterate(func(p fieldpath.Path) {
+			fixed, ok := index.resolve(p)
+			if !ok {
+				dropped.Insert(p)
+				return
+			}
+			if !fixed.Equals(p) {
+				report.Rekeyed = append(report.Rekeyed, RekeyedPath{Manager: manager, From: p.Copy(), To: fixed})
+			}
+			repaired.Insert(fixed)
+		})
+		if !dropped.Empty() {
+			report.Dropped[manager] = dropped
+		}
+		owned = append(owned, repaired)
+		if !repaired.Empty() {
+			out[manager] = fieldpath.WithSet(set, repaired, set.APIVersion())
+		}
+	}
+
+	if len(report.Skipped) == 0 {
+		report.Orphans = liveSet.Leaves().DifferenceAll(owned...)
+		if !report.Orphans.Empty() {
+			set := report.Orphans
+			if previous, ok := out[opts.OrphansManager]; ok {
+				set = set.Union(previous.Set())
+			}
+			out[opts.OrphansManager] = fieldpath.NewVersionedSet(set, opts.Version, false)
+		}
+	}
+	return out, report, nil
+}
+
+// livePaths indexes the paths of an object.
+type livePaths struct {
+	// paths has the paths of the object and all their prefixes.
+	paths map[string]bool
+	// keyed has the keyed list items under each path.
+	keyed map[string][]fieldpath.PathElement
+}
+
+func newLivePaths(set *fieldpath.Set) livePaths {
+	l := livePaths{paths: map[string]bool{}, keyed: map[string][]fieldpath.PathElement{}}
+	set.Iterate(func(p fieldpath.Path) {
+		for i := range p {
+			prefix := p[:i+1].String()
+			if l.paths[prefix] {
+				continue
+			}
+			l.paths[prefix] = true
+			if p[i].Key != nil {
+				parent := p[:i].String()
+				l.keyed[parent] = append(l.keyed[parent], p[i])
+			}
+		}
+	})
+	return l
+}
+
+// resolve returns p, with its list keys fixed if needed, and false if the
+// object doesn't have it.
+func (l livePaths) resolve(p fieldpath.Path) (fieldpath.Path, bool) {
+	out := make(fieldpath.Path, 0, len(p))
+	for _, pe := range p {
+		if l.paths[append(out, pe).String()] {
+			out = append(out, pe)
+			continue
+		}
+		if pe.Key == nil {
+			return nil, false
+		}
+		match, ok := l.rekey(out, *pe.Key)
+		if !ok {
+			return nil, false
+		}
+		out = append(out, match)
+	}
+	return out, true
+}
+
+// rekey returns the only keyed item under parent whose key fields in common
+// with key have the same values.
+func (l livePaths) rekey(parent fieldpath.Path, key value.FieldList) (fieldpath.PathElement, bool) {
+	var match fieldpath.PathElement
+	found := 0
+	for _, item := range l.keyed[parent.String()] {
+		if keysAgree(key, *item.Key) {
+			match = item
+			found++
+		}
+	}
+	return match, found == 1
+}
+
+// keysAgree returns true if lhs and rhs have fields in common, all with the
+// same values.
+func keysAgree(lhs, rhs value.FieldList) bool {
+	common := false
+	for _, l := range lhs {
+		for _, r := range rhs {
+			if l.Name != r.Name {
+				continue
+			}
+			if !value.Equals(l.Value, r.Value) {
+				return false
+			}
+			common = true
+		}
+	}
+	return common
+}
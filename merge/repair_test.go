@@ -0,0 +1,161 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package merge_test
+
+import (
+	"testing"
+
+	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
+	"sigs.k8s.io/structured-merge-diff/v4/merge"
+	"sigs.k8s.io/structured-merge-diff/v4/typed"
+)
+
+var repairParser = func() *typed.Parser {
+	parser, err := typed.NewParser(`types:
+- name: v1
+  map:
+    fields:
+    - name: name
+      type:
+        scalar: string
+    - name: spec
+      type:
+        map:
+          fields:
+          - name: replicas
+            type:
+              scalar: numeric
+    - name: ports
+      type:
+        list:
+          elementType:
+            map:
+              fields:
+              - name: port
+                type:
+                  scalar: numeric
+              - name: protocol
+                type:
+                  scalar: string
+              - name: name
+                type:
+                  scalar: string
+          elementRelationship: associative
+          keys: [port, protocol]
+`)
+	if err != nil {
+		panic(err)
+	}
+	return parser
+}()
+
+func TestRepairManagedFields(t *testing.T) {
+	live, err := repairParser.Type("v1").FromYAML(`{
+		"name": "a",
+		"spec": {"replicas": 1},
+		"ports": [{"port": 80, "protocol": "TCP"}, {"port": 80, "protocol": "UDP"}, {"port": 443, "protocol": "TCP", "name": "https"}]
+	}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	https := _KBF("port", 443, "protocol", "TCP")
+	tests := []struct {
+		name     string
+		managers fieldpath.ManagedFields
+		expected fieldpath.ManagedFields
+		report   string
+	}{
+		{
+			name: "repaired",
+			managers: fieldpath.ManagedFields{
+				"applier": fieldpath.NewVersionedSet(_NS(
+					_P("name"),
+					_P("spec", "size"),
+					// Keyed before protocol became a key.
+					_P("ports", _KBF("port", 443)),
+					_P("ports", _KBF("port", 443), "name"),
+				), "v1", true),
+				"controller": fieldpath.NewVersionedSet(_NS(
+					_P("status", "ready"),
+					// Matches both items.
+					_P("ports", _KBF("port", 80), "port"),
+				), "v1", false),
+			},
+			expected: fieldpath.ManagedFields{
+				"applier": fieldpath.NewVersionedSet(_NS(
+					_P("name"),
+					_P("ports", https),
+					_P("ports", https, "name"),
+				), "v1", true),
+				merge.BeforeFirstApplyManager: fieldpath.NewVersionedSet(_NS(
+					_P("spec", "replicas"),
+					_P("ports", https, "port"),
+					_P("ports", https, "protocol"),
+					_P("ports", _KBF("port", 80, "protocol", "TCP"), "port"),
+					_P("ports", _KBF("port", 80, "protocol", "TCP"), "protocol"),
+					_P("ports", _KBF("port", 80, "protocol", "UDP"), "port"),
+					_P("ports", _KBF("port", 80, "protocol", "UDP"), "protocol"),
+				), "v1", false),
+			},
+			report: `applier: dropped .spec.size
+controller: dropped .ports[port=80].port
+controller: dropped .status.ready
+applier: rekeyed .ports[port=443] to .ports[port=443,protocol="TCP"]
+applier: rekeyed .ports[port=443].name to .ports[port=443,protocol="TCP"].name
+orphan .ports[port=80,protocol="TCP"].port
+orphan .ports[port=80,protocol="TCP"].protocol
+orphan .ports[port=80,protocol="UDP"].port
+orphan .ports[port=80,protocol="UDP"].protocol
+orphan .ports[port=443,protocol="TCP"].port
+orphan .ports[port=443,protocol="TCP"].protocol
+orphan .spec.replicas
+`,
+		}, {
+			name: "other versions",
+			managers: fieldpath.ManagedFields{
+				"applier": fieldpath.NewVersionedSet(_NS(_P("name"), _P("spec", "size")), "v1", true),
+				"old":     fieldpath.NewVersionedSet(_NS(_P("spec", "size")), "v0", false),
+			},
+			expected: fieldpath.ManagedFields{
+				"applier": fieldpath.NewVersionedSet(_NS(_P("name")), "v1", true),
+				"old":     fieldpath.NewVersionedSet(_NS(_P("spec", "size")), "v0", false),
+			},
+			report: `applier: dropped .spec.size
+old: skipped
+`,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			original := tt.managers.Copy()
+			repaired, report, err := merge.RepairManagedFields(live, tt.managers, merge.RepairOptions{Version: "v1"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !repaired.Equals(tt.expected) {
+				t.Errorf("expected managers:\n%v\ngot:\n%v", tt.expected, repaired)
+			}
+			if report.String() != tt.report {
+				t.Errorf("expected report:\n%v\ngot:\n%v", tt.report, report)
+			}
+			if !tt.managers.Equals(original) {
+				t.Errorf("expected the managers not to be modified")
+			}
+		})
+	}
+}
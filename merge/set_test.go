@@ -22,6 +22,7 @@ import (
 	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
 	. "sigs.k8s.io/structured-merge-diff/v4/internal/fixture"
 	"sigs.k8s.io/structured-merge-diff/v4/typed"
+	"sigs.k8s.io/structured-merge-diff/v4/value"
 )
 
 var setFieldsParser = func() Parser {
@@ -592,3 +593,152 @@ func TestUpdateSet(t *testing.T) {
 		})
 	}
 }
+
+var setOfMapsParser = func() Parser {
+	parser, err := typed.NewParser(`types:
+- name: sets
+  map:
+    fields:
+    - name: list
+      type:
+        list:
+          elementType:
+            namedType: item
+          elementRelationship: associative
+- name: item
+  map:
+    fields:
+    - name: name
+      type:
+        scalar: string
+    - name: value
+      type:
+        scalar: string
+    elementRelationship: atomic`)
+	if err != nil {
+		panic(err)
+	}
+	return SameVersionParser{T: parser.Type("sets")}
+}()
+
+func item(fields ...string) value.Value {
+	m := map[string]interface{}{}
+	for i := 0; i < len(fields); i += 2 {
+		m[fields[i]] = fields[i+1]
+	}
+	return _V(m)
+}
+
+func TestUpdateSetOfMaps(t *testing.T) {
+	tests := map[string]TestCase{
+		"items_are_compared_by_value": {
+			Ops: []Operation{
+				Apply{
+					Manager:    "apply-one",
+					APIVersion: "v1",
+					Object: `
+						list:
+						- name: a
+						  value: one
+						- name: b
+					`,
+				},
+				Apply{
+					Manager:    "apply-two",
+					APIVersion: "v1",
+					Object: `
+						list:
+						- name: a
+						  value: two
+					`,
+				},
+			},
+			Object: `
+				list:
+				- name: a
+				  value: one
+				- name: b
+				- name: a
+				  value: two
+			`,
+			APIVersion: "v1",
+			Managed: fieldpath.ManagedFields{
+				"apply-one": fieldpath.NewVersionedSet(
+					_NS(
+						_P("list", item("name", "a", "value", "one")),
+						_P("list", item("name", "b")),
+					),
+					"v1",
+					true,
+				),
+				"apply-two": fieldpath.NewVersionedSet(
+					_NS(
+						_P("list", item("name", "a", "value", "two")),
+					),
+					"v1",
+					true,
+				),
+			},
+		},
+		"items_no_longer_applied_are_removed": {
+			Ops: []Operation{
+				Apply{
+					Manager:    "apply-one",
+					APIVersion: "v1",
+					Object: `
+						list:
+						- name: a
+						  value: one
+						- name: b
+					`,
+				},
+				Apply{
+					Manager:    "apply-two",
+					APIVersion: "v1",
+					Object: `
+						list:
+						- name: b
+					`,
+				},
+				Apply{
+					Manager:    "apply-one",
+					APIVersion: "v1",
+					Object: `
+						list:
+						- name: c
+					`,
+				},
+			},
+			Object: `
+				list:
+				- name: b
+				- name: c
+			`,
+			APIVersion: "v1",
+			Managed: fieldpath.ManagedFields{
+				"apply-one": fieldpath.NewVersionedSet(
+					_NS(
+						_P("list", item("name", "c")),
+					),
+					"v1",
+					true,
+				),
+				"apply-two": fieldpath.NewVersionedSet(
+					_NS(
+						_P("list", item("name", "b")),
+					),
+					"v1",
+					true,
+				),
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if err := test.Test(setOfMapsParser); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package merge
+
+import (
+	"fmt"
+
+	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
+	"sigs.k8s.io/structured-merge-diff/v4/schema"
+	"sigs.k8s.io/structured-merge-diff/v4/typed"
+)
+
+// ApplySubresource is like Apply, but through a subresource of the object
+// (e.g. "status"), or through the main resource if subresource is empty.
+//
+// The fields of a subresource are the ones marked with its name in the
+// schema (see schema.StructField.Subresource). Only them can be applied
+// through the subresource, and only the other fields can be applied through
+// the main resource: the other fields of configObject are dropped. The
+// ownership is tracked under fieldpath.SubresourceManager(manager,
+// subresource), so that the appliers of the main resource and of its
+// subresources never conflict, by a VersionedSet recording the subresource
+// (see fieldpath.Subresource). A manager of the main resource whose name is
+// the same, e.g. "helm (v3)" for manager "helm" and subresource "v3", is an
+// error, and so is the other way around.
+func (s *Updater) ApplySubresource(liveObject, configObject *typed.TypedValue, version fieldpath.APIVersion, managers fieldpath.ManagedFields, manager, subresource string, force bool) (*typed.TypedValue, fieldpath.ManagedFields, error) {
+	paths := subresourcePaths(configObject.Schema(), configObject.TypeRef())
+	if subresource == "" {
+		all := make([]*fieldpath.Set, 0, len(paths))
+		for _, set := range paths {
+			all = append(all, set)
+		}
+		configObject = configObject.RemoveItems(fieldpath.NewSet().UnionAll(all...))
+	} else {
+		set, ok := paths[subresource]
+		if !ok {
+			return nil, fieldpath.ManagedFields{}, fmt.Errorf("unknown subresource %q", subresource)
+		}
+		// Extracting the fields of the subresource as a whole yields null
+		// values, so their leaves are extracted instead.
+		fields, err := configObject.ToFieldSet()
+		if err != nil {
+			return nil, fieldpath.ManagedFields{}, fmt.Errorf("failed to get field set: %v", err)
+		}
+		extracted := fieldpath.NewSet()
+		fields.Leaves().Iterate(func(p fieldpath.Path) {
+			for i := 1; i <= len(p); i++ {
+				if set.Has(p[:i]) {
+					extracted.Insert(p)
+					return
+				}
+			}
+		})
+		configObject = configObject.ExtractItems(extracted, typed.WithAppendKeyFields())
+	}
+	return s.apply(liveObject, configObject, version, fieldpath.NewManagedFieldsSnapshot(managers), fieldpath.SubresourceManager(manager, subresource), subresource, force, nil)
+}
+
+// subresourcePaths returns the paths of the fields of each subresource of
+// the type tr.
+func subresourcePaths(s *schema.Schema, tr schema.TypeRef) map[string]*fieldpath.Set {
+	paths := map[string]*fieldpath.Set{}
+	var walk func(prefix fieldpath.Path, tr schema.TypeRef, visiting map[string]bool)
+	walk = func(prefix fieldpath.Path, tr schema.TypeRef, visiting map[string]bool) {
+		if tr.NamedType != nil {
+			if visiting[*tr.NamedType] {
+				return
+			}
+			visiting[*tr.NamedType] = true
+			defer delete(visiting, *tr.NamedType)
+		}
+		a, ok := s.Resolve(tr)
+		if !ok || a.Map == nil {
+			return
+		}
+		for _, f := range a.Map.Fields {
+			name := f.Name
+			path := append(prefix.Copy(), fieldpath.PathElement{FieldName: &name})
+			if f.Subresource == "" {
+				walk(path, f.Type, visiting)
+				continue
+			}
+			if paths[f.Subresource] == nil {
+				paths[f.Subresource] = fieldpath.NewSet()
+			}
+			paths[f.Subresource].Insert(path)
+		}
+	}
+	walk(nil, tr, map[string]bool{})
+	return paths
+}
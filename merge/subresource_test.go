@@ -0,0 +1,161 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package merge_test
+
+import (
+	"testing"
+
+	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
+	"sigs.k8s.io/structured-merge-diff/v4/merge"
+	"sigs.k8s.io/structured-merge-diff/v4/typed"
+	"sigs.k8s.io/structured-merge-diff/v4/value"
+)
+
+var subresourceParser = func() *typed.Parser {
+	parser, err := typed.NewParser(`types:
+- name: v1
+  map:
+    fields:
+    - name: spec
+      type:
+        map:
+          fields:
+          - name: replicas
+            type:
+              scalar: numeric
+    - name: status
+      subresource: status
+      type:
+        map:
+          fields:
+          - name: replicas
+            type:
+              scalar: numeric
+          - name: ready
+            type:
+              scalar: numeric
+`)
+	if err != nil {
+		panic(err)
+	}
+	return parser
+}()
+
+func TestApplySubresource(t *testing.T) {
+	updater := &merge.Updater{Converter: &specificVersionConverter{
+		AcceptedVersions: []fieldpath.APIVersion{"v1"},
+	}}
+	pt := subresourceParser.Type("v1")
+	live, err := pt.FromUnstructured(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	managers := fieldpath.ManagedFields{}
+	for _, op := range []struct {
+		manager     string
+		subresource string
+		config      typed.YAMLObject
+	}{
+		{"applier", "", `{"spec": {"replicas": 1}, "status": {"replicas": 3}}`},
+		{"controller", "status", `{"spec": {"replicas": 2}, "status": {"replicas": 1, "ready": 1}}`},
+		// The applier of the main resource never conflicts with the
+		// controller.
+		{"applier", "", `{"spec": {"replicas": 1}, "status": {"replicas": 5}}`},
+	} {
+		config, err := pt.FromYAML(op.config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		newLive, newManagers, err := updater.ApplySubresource(live, config, "v1", managers, op.manager, op.subresource, false)
+		if err != nil {
+			t.Fatalf("%v: %v", op.manager, err)
+		}
+		if newLive != nil {
+			live = newLive
+		}
+		managers = newManagers
+	}
+
+	expected, err := pt.FromYAML(`{"spec": {"replicas": 1}, "status": {"replicas": 1, "ready": 1}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !value.Equals(live.AsValue(), expected.AsValue()) {
+		t.Errorf("expected object %v, got %v", value.ToString(expected.AsValue()), value.ToString(live.AsValue()))
+	}
+	expectedManagers := fieldpath.ManagedFields{
+		"applier": fieldpath.NewVersionedSet(_NS(
+			_P("spec", "replicas"),
+		), "v1", true),
+		"controller (status)": fieldpath.NewSubresourceVersionedSet(_NS(
+			_P("status", "replicas"),
+			_P("status", "ready"),
+		), "v1", true, "status"),
+	}
+	if !managers.Equals(expectedManagers) {
+		t.Errorf("expected managers:\n%v\ngot:\n%v", expectedManagers, managers)
+	}
+
+	if _, _, err := updater.ApplySubresource(live, expected, "v1", managers, "controller", "scale", false); err == nil {
+		t.Error("expected an error for an unknown subresource")
+	}
+}
+
+func TestApplySubresourceParenthesizedNames(t *testing.T) {
+	updater := &merge.Updater{Converter: &specificVersionConverter{
+		AcceptedVersions: []fieldpath.APIVersion{"v1"},
+	}}
+	pt := subresourceParser.Type("v1")
+	live, err := pt.FromYAML(`{"spec": {"replicas": 1}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config, err := pt.FromYAML(`{"spec": {"replicas": 2}, "status": {"replicas": 2}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A manager of the main resource named like a manager of a subresource
+	// is a manager of the main resource.
+	_, managers, err := updater.Apply(live, config, "v1", fieldpath.ManagedFields{}, "helm (status)", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := fieldpath.ManagedFields{
+		"helm (status)": fieldpath.NewVersionedSet(_NS(
+			_P("spec", "replicas"),
+			_P("status", "replicas"),
+		), "v1", true),
+	}
+	if !managers.Equals(expected) {
+		t.Errorf("expected managers:\n%v\ngot:\n%v", expected, managers)
+	}
+	if _, _, err := updater.ApplySubresource(live, config, "v1", managers.Copy(), "helm", "status", false); err == nil {
+		t.Error("expected applying through a subresource under the name of another manager to fail")
+	}
+
+	_, managers, err = updater.ApplySubresource(live, config, "v1", fieldpath.ManagedFields{}, "helm", "status", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := updater.Apply(live, config, "v1", managers.Copy(), "helm (status)", false); err == nil {
+		t.Error("expected applying under the name of a manager of a subresource to fail")
+	}
+	if _, _, err := updater.Update(live, config, "v1", managers.Copy(), "helm (status)"); err == nil {
+		t.Error("expected updating under the name of a manager of a subresource to fail")
+	}
+}
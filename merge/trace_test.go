@@ -0,0 +1,69 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package merge_test
+
+import (
+	"testing"
+
+	. "sigs.k8s.io/structured-merge-diff/v4/internal/fixture"
+	"sigs.k8s.io/structured-merge-diff/v4/typed"
+)
+
+func TestApplyMergeTracer(t *testing.T) {
+	var decisions []typed.MergeDecision
+	test := TestCase{
+		Ops: []Operation{
+			Apply{
+				Manager:    "default",
+				APIVersion: "v1",
+				Object: `
+					numeric: 1
+					string: "string"
+				`,
+			},
+		},
+		Object: `
+			numeric: 1
+			string: "string"
+		`,
+		APIVersion: "v1",
+		MergeTracer: typed.MergeTracerFunc(func(d typed.MergeDecision) {
+			decisions = append(decisions, d)
+		}),
+	}
+	if err := test.Test(leafFieldsParser); err != nil {
+		t.Fatal(err)
+	}
+
+	got := map[string]typed.MergeSide{}
+	for _, d := range decisions {
+		got[d.Path.String()] = d.Side
+	}
+	expected := map[string]typed.MergeSide{
+		"":         typed.MergeSideRHS,
+		".numeric": typed.MergeSideRHS,
+		".string":  typed.MergeSideRHS,
+	}
+	if len(got) != len(expected) {
+		t.Fatalf("expected decisions %v, got %v", expected, got)
+	}
+	for path, side := range expected {
+		if got[path] != side {
+			t.Errorf("%q: expected %v, got %v", path, side, got[path])
+		}
+	}
+}
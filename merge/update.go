@@ -16,6 +16,7 @@ package merge
 import (
 	"fmt"
 	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
+	"sigs.k8s.io/structured-merge-diff/v4/schema"
 	"sigs.k8s.io/structured-merge-diff/v4/typed"
 	"sigs.k8s.io/structured-merge-diff/v4/value"
 )
@@ -27,6 +28,17 @@ type Converter interface {
 	IsMissingVersionError(error) bool
 }
 
+// SetConverter can be implemented by a Converter which can also convert
+// field sets between versions, e.g. when fields are renamed or moved
+// between parents. The Updater then uses it to convert the fields owned by
+// a manager which updates the object in a different version than the last
+// time, which otherwise keep the paths of the previous version. Like for
+// objects, the fields of versions which no longer exist are dropped if
+// ConvertSet fails with an error that IsMissingVersionError recognizes.
+type SetConverter interface {
+	ConvertSet(set *fieldpath.Set, from, to fieldpath.APIVersion) (*fieldpath.Set, error)
+}
+
 // UpdateBuilder allows you to create a new Updater by exposing all of
 // the options and setting them once.
 type UpdaterBuilder struct {
@@ -36,6 +48,13 @@ type UpdaterBuilder struct {
 	// IgnoredFields provides a set of fields to ignore for each
 	IgnoredFields map[fieldpath.APIVersion]*fieldpath.Set
 
+	// Ignored is a set of fields ignored in every version, in addition to
+	// IgnoredFields or IgnoreFilter, e.g. .metadata.resourceVersion. The
+	// fields, and all of their children, never conflict and are never
+	// owned. Use typed.WithIgnoredFields to leave them out of comparisons
+	// the same way.
+	Ignored *fieldpath.Set
+
 	// Stop comparing the new object with old object after applying.
 	// This was initially used to avoid spurious etcd update, but
 	// since that's vastly inefficient, we've come-up with a better
@@ -43,14 +62,98 @@ type UpdaterBuilder struct {
 	// Comparing has become more expensive too now that we're not using
 	// `Compare` but `value.Equals` so this gives an option to avoid it.
 	ReturnInputOnNoop bool
+
+	// MergeTracer, if set, is notified of every decision taken while
+	// merging the applied configuration into the live object. Since it
+	// applies to every operation of the Updater, build a dedicated Updater
+	// to trace a single operation.
+	MergeTracer typed.MergeTracer
+
+	// Ownership is how the sets recorded for the managers which update or
+	// apply the object represent the ownership of its nodes. The sets of
+	// the other managers are left as they are; see
+	// fieldpath.ManagedFields.WithOwnership to migrate them.
+	Ownership fieldpath.Ownership
+
+	// FloatTolerance, if positive, is how much numeric fields can change
+	// without being modified, e.g. conflicting with their managers or
+	// changing owner on update. See typed.WithFloatTolerance.
+	FloatTolerance float64
+
+	// StringNormalizers normalize the string fields before they are
+	// compared, so that equivalent strings aren't modified. See
+	// typed.WithStringNormalizers.
+	StringNormalizers []typed.StringNormalizer
+
+	// NullPolicy, if set, is what explicit nulls of applied configurations
+	// mean, unless the schema of a field sets another one. See
+	// typed.WithNullPolicy.
+	NullPolicy schema.NullPolicy
+
+	// EmptyCollections defines which of empty, null and absent maps and
+	// lists are equivalent, so that replacing one by another neither
+	// modifies the field nor changes the object. See
+	// typed.WithEmptyCollections.
+	EmptyCollections typed.EmptyCollectionPolicy
+
+	// UntypedComparator, if set, compares the values of untyped fields,
+	// and UntypedMaxNodes, if positive, limits their size. See
+	// typed.WithUntypedComparator and typed.WithUntypedMaxNodes.
+	UntypedComparator typed.UntypedComparator
+	UntypedMaxNodes   int
+
+	// UntypedLimits bounds the untyped content of the objects given to
+	// Update and Apply, which fail with a *typed.UntypedLimitError when it
+	// exceeds them. See typed.UntypedLimits.
+	UntypedLimits typed.UntypedLimits
+
+	// MergeBudget bounds the merge of the applied configuration with the
+	// live object, which fails with a *typed.MergeBudgetError when it
+	// exceeds it. See typed.WithMergeBudget.
+	MergeBudget typed.MergeBudget
+
+	// MapKeys, if set, leaves the ignored keys of its maps out of the sets
+	// of the managers and of the conflicts. Its maps are only owned key by
+	// key if the schema of the objects makes them granular, see
+	// MapKeyPolicy.ParserOption.
+	MapKeys *MapKeyPolicy
+
+	// ListChunking bounds the memory used to compare very long associative
+	// lists. See typed.WithListChunking.
+	ListChunking typed.ListChunking
+
+	// ShareValues makes the objects returned by Apply share the parts of
+	// the live object which the configuration doesn't set, rather than
+	// copy them, which makes applying small configurations to big objects
+	// much cheaper. The live objects must then be valid, and neither they
+	// nor the returned objects may be modified. See typed.WithSharedValues.
+	ShareValues bool
 }
 
 func (u *UpdaterBuilder) BuildUpdater() *Updater {
+	var mapKeys fieldpath.Filter
+	if u.MapKeys != nil {
+		mapKeys = u.MapKeys.Filter()
+	}
 	return &Updater{
 		Converter:         u.Converter,
 		IgnoreFilter:      u.IgnoreFilter,
 		IgnoredFields:     u.IgnoredFields,
 		returnInputOnNoop: u.ReturnInputOnNoop,
+		mergeTracer:       u.MergeTracer,
+		ownership:         u.Ownership,
+		ignored:           u.Ignored,
+		floatTolerance:    u.FloatTolerance,
+		stringNormalizers: u.StringNormalizers,
+		nullPolicy:        u.NullPolicy,
+		emptyCollections:  u.EmptyCollections,
+		untypedComparator: u.UntypedComparator,
+		untypedMaxNodes:   u.UntypedMaxNodes,
+		untypedLimits:     u.UntypedLimits,
+		mergeBudget:       u.MergeBudget,
+		mapKeys:           mapKeys,
+		listChunking:      u.ListChunking,
+		shareValues:       u.ShareValues,
 	}
 }
 
@@ -67,28 +170,86 @@ type Updater struct {
 	IgnoreFilter map[fieldpath.APIVersion]fieldpath.Filter
 
 	returnInputOnNoop bool
+
+	mergeTracer typed.MergeTracer
+
+	ownership fieldpath.Ownership
+
+	ignored *fieldpath.Set
+
+	floatTolerance float64
+
+	stringNormalizers []typed.StringNormalizer
+
+	nullPolicy schema.NullPolicy
+
+	emptyCollections typed.EmptyCollectionPolicy
+
+	untypedComparator typed.UntypedComparator
+	untypedMaxNodes   int
+
+	untypedLimits typed.UntypedLimits
+	mergeBudget   typed.MergeBudget
+
+	mapKeys fieldpath.Filter
+
+	listChunking typed.ListChunking
+
+	shareValues bool
 }
 
-func (s *Updater) update(oldObject, newObject *typed.TypedValue, version fieldpath.APIVersion, managers fieldpath.ManagedFields, workflow string, force bool) (fieldpath.ManagedFields, *typed.Comparison, error) {
+func (s *Updater) compareOptions() []typed.CompareOption {
+	return []typed.CompareOption{
+		typed.WithIgnoredFields(s.ignored),
+		typed.WithFloatTolerance(s.floatTolerance),
+		typed.WithStringNormalizers(s.stringNormalizers...),
+		typed.WithEmptyCollections(s.emptyCollections),
+		typed.WithUntypedComparator(s.untypedComparator),
+		typed.WithUntypedMaxNodes(s.untypedMaxNodes),
+		typed.WithListChunking(s.listChunking),
+	}
+}
+
+// equals returns true if the two objects are equal, once their empty
+// collections are normalized.
+func (s *Updater) equals(lhs, rhs *typed.TypedValue) bool {
+	lhs = lhs.NormalizeEmptyCollections(s.emptyCollections)
+	rhs = rhs.NormalizeEmptyCollections(s.emptyCollections)
+	return value.EqualsUsing(value.NewFreelistAllocator(), lhs.AsValue(), rhs.AsValue())
+}
+
+// update computes the managers once newObject replaces oldObject. With
+// force, or if forced includes all the conflicting fields, the conflicting
+// fields are taken from their managers; otherwise they are an error.
+// compare is the comparison of the objects, if it is known already.
+func (s *Updater) update(oldObject, newObject *typed.TypedValue, compare *typed.Comparison, version fieldpath.APIVersion, managers fieldpath.ManagedFields, workflow string, force bool, forced fieldpath.Filter) (fieldpath.ManagedFields, *typed.Comparison, error) {
 	conflicts := fieldpath.ManagedFields{}
 	removed := fieldpath.ManagedFields{}
-	compare, err := oldObject.Compare(newObject)
-	if err != nil {
-		return nil, nil, fmt.Errorf("failed to compare objects: %v", err)
+	if compare == nil {
+		var err error
+		compare, err = oldObject.Compare(newObject, s.compareOptions()...)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to compare objects: %v", err)
+		}
 	}
 
 	var versions map[fieldpath.APIVersion]*typed.Comparison
+	// objects are the old and new objects in each version, used to report
+	// the values of the conflicting fields.
+	objects := map[fieldpath.APIVersion][2]*typed.TypedValue{
+		version: {oldObject, newObject},
+	}
 
 	if s.IgnoredFields != nil && s.IgnoreFilter != nil {
 		return nil, nil, fmt.Errorf("IgnoreFilter and IgnoreFilter may not both be set")
 	}
 	if s.IgnoredFields != nil {
 		versions = map[fieldpath.APIVersion]*typed.Comparison{
-			version: compare.ExcludeFields(s.IgnoredFields[version]),
+			version: compare.ExcludeFields(s.IgnoredFields[version]).FilterFields(s.mapKeys),
 		}
 	} else {
 		versions = map[fieldpath.APIVersion]*typed.Comparison{
-			version: compare.FilterFields(s.IgnoreFilter[version]),
+			version: compare.FilterFields(s.IgnoreFilter[version]).FilterFields(s.mapKeys),
 		}
 	}
 
@@ -115,15 +276,16 @@ func (s *Updater) update(oldObject, newObject *typed.TypedValue, version fieldpa
 				}
 				return nil, nil, fmt.Errorf("failed to convert new object: %v", err)
 			}
-			compare, err = versionedOldObject.Compare(versionedNewObject)
+			compare, err = versionedOldObject.Compare(versionedNewObject, s.compareOptions()...)
 			if err != nil {
 				return nil, nil, fmt.Errorf("failed to compare objects: %v", err)
 			}
+			objects[managerSet.APIVersion()] = [2]*typed.TypedValue{versionedOldObject, versionedNewObject}
 
 			if s.IgnoredFields != nil {
-				versions[managerSet.APIVersion()] = compare.ExcludeFields(s.IgnoredFields[managerSet.APIVersion()])
+				versions[managerSet.APIVersion()] = compare.ExcludeFields(s.IgnoredFields[managerSet.APIVersion()]).FilterFields(s.mapKeys)
 			} else {
-				versions[managerSet.APIVersion()] = compare.FilterFields(s.IgnoreFilter[managerSet.APIVersion()])
+				versions[managerSet.APIVersion()] = compare.FilterFields(s.IgnoreFilter[managerSet.APIVersion()]).FilterFields(s.mapKeys)
 			}
 		}
 
@@ -138,15 +300,33 @@ func (s *Updater) update(oldObject, newObject *typed.TypedValue, version fieldpa
 	}
 
 	if !force && len(conflicts) != 0 {
-		return nil, nil, ConflictsFromManagers(conflicts)
-	}
-
-	for manager, conflictSet := range conflicts {
-		managers[manager] = fieldpath.NewVersionedSet(managers[manager].Set().Difference(conflictSet.Set()), managers[manager].APIVersion(), managers[manager].Applied())
+		unforced := conflicts
+		if forced != nil {
+			unforced = fieldpath.ManagedFields{}
+			for manager, conflictSet := range conflicts {
+				set := conflictSet.Set().Difference(forced.Filter(conflictSet.Set()))
+				if !set.Empty() {
+					unforced[manager] = fieldpath.NewVersionedSet(set, conflictSet.APIVersion(), false)
+				}
+			}
+		}
+		if len(unforced) != 0 {
+			return nil, nil, conflictsWithValues(unforced, objects)
+		}
 	}
 
-	for manager, removedSet := range removed {
-		managers[manager] = fieldpath.NewVersionedSet(managers[manager].Set().Difference(removedSet.Set()), managers[manager].APIVersion(), managers[manager].Applied())
+	for manager := range managers {
+		var toRemove []*fieldpath.Set
+		if conflictSet, ok := conflicts[manager]; ok {
+			toRemove = append(toRemove, conflictSet.Set())
+		}
+		if removedSet, ok := removed[manager]; ok {
+			toRemove = append(toRemove, removedSet.Set())
+		}
+		if len(toRemove) == 0 {
+			continue
+		}
+		managers[manager] = fieldpath.WithSet(managers[manager], managers[manager].Set().DifferenceAll(toRemove...), managers[manager].APIVersion())
 	}
 
 	for manager := range managers {
@@ -163,20 +343,55 @@ func (s *Updater) update(oldObject, newObject *typed.TypedValue, version fieldpa
 // that you intend to persist (after applying the patch if this is for a
 // PATCH call), and liveObject must be the original object (empty if
 // this is a CREATE call).
+//
+// managers isn't modified, the returned managers are a new map. Update is
+// UpdateSnapshot for the callers which keep ManagedFields.
 func (s *Updater) Update(liveObject, newObject *typed.TypedValue, version fieldpath.APIVersion, managers fieldpath.ManagedFields, manager string) (*typed.TypedValue, fieldpath.ManagedFields, error) {
-	var err error
-	managers, err = s.reconcileManagedFieldsWithSchemaChanges(liveObject, managers)
+	return s.updateObject(liveObject, newObject, version, fieldpath.NewManagedFieldsSnapshot(managers), manager)
+}
+
+// UpdateSnapshot is like Update, but takes and returns read-only managers.
+func (s *Updater) UpdateSnapshot(liveObject, newObject *typed.TypedValue, version fieldpath.APIVersion, managers fieldpath.ManagedFieldsSnapshot, manager string) (*typed.TypedValue, fieldpath.ManagedFieldsSnapshot, error) {
+	object, newManagers, err := s.updateObject(liveObject, newObject, version, managers, manager)
 	if err != nil {
+		return nil, fieldpath.ManagedFieldsSnapshot{}, err
+	}
+	return object, fieldpath.NewManagedFieldsSnapshot(newManagers), nil
+}
+
+// updateObject returns the managers of the updated object. Like apply, it
+// only reads snapshot, and writes the managers to a new map.
+func (s *Updater) updateObject(liveObject, newObject *typed.TypedValue, version fieldpath.APIVersion, snapshot fieldpath.ManagedFieldsSnapshot, manager string) (*typed.TypedValue, fieldpath.ManagedFields, error) {
+	if err := newObject.CheckUntypedLimits(s.untypedLimits); err != nil {
 		return nil, fieldpath.ManagedFields{}, err
 	}
-	managers, compare, err := s.update(liveObject, newObject, version, managers, manager, true)
+	if err := checkSubresource(snapshot, manager, ""); err != nil {
+		return nil, fieldpath.ManagedFields{}, err
+	}
+	managers, err := s.reconcileManagedFieldsWithSchemaChanges(liveObject, snapshot)
+	if err != nil {
+		return nil, fieldpath.ManagedFields{}, err
+	}
+	managers, compare, err := s.update(liveObject, newObject, nil, version, managers, manager, true, nil)
 	if err != nil {
 		return nil, fieldpath.ManagedFields{}, err
 	}
 	if _, ok := managers[manager]; !ok {
 		managers[manager] = fieldpath.NewVersionedSet(fieldpath.NewSet(), version, false)
 	}
-	set := managers[manager].Set().Difference(compare.Removed).Union(compare.Modified).Union(compare.Added)
+	if sc, ok := s.Converter.(SetConverter); ok && managers[manager].APIVersion() != version {
+		converted, err := sc.ConvertSet(managers[manager].Set(), managers[manager].APIVersion(), version)
+		if err != nil {
+			if !s.Converter.IsMissingVersionError(err) {
+				return nil, fieldpath.ManagedFields{}, fmt.Errorf("failed to convert fields of %q to version %v: %v", manager, version, err)
+			}
+			// Like the managers of obsolete versions, the fields can't be
+			// converted and are dropped.
+			converted = fieldpath.NewSet()
+		}
+		managers[manager] = fieldpath.NewVersionedSet(converted, version, false)
+	}
+	set := managers[manager].Set().Difference(compare.Removed).UnionAll(compare.Modified, compare.Added)
 
 	if s.IgnoredFields != nil && s.IgnoreFilter != nil {
 		return nil, nil, fmt.Errorf("IgnoreFilter and IgnoreFilter may not both be set")
@@ -190,6 +405,16 @@ func (s *Updater) Update(liveObject, newObject *typed.TypedValue, version fieldp
 	if ignoreFilter != nil {
 		set = ignoreFilter.Filter(set)
 	}
+	if s.ignored != nil {
+		set = set.RecursiveDifference(s.ignored)
+	}
+	if volatile := newObject.VolatileFields(); !volatile.Empty() {
+		set = set.RecursiveDifference(volatile)
+	}
+	if s.mapKeys != nil {
+		set = s.mapKeys.Filter(set)
+	}
+	set = set.WithOwnership(s.ownership)
 
 	managers[manager] = fieldpath.NewVersionedSet(
 		set,
@@ -205,18 +430,180 @@ func (s *Updater) Update(liveObject, newObject *typed.TypedValue, version fieldp
 // Apply should be called when Apply is run, given the current object as
 // well as the configuration that is applied. This will merge the object
 // and return it.
+//
+// The live object is walked once to merge the configuration and to find
+// the conflicts, and once more only if some of its fields are pruned.
+//
+// managers isn't modified, the returned managers are a new map. Apply is
+// ApplySnapshot for the callers which keep ManagedFields.
 func (s *Updater) Apply(liveObject, configObject *typed.TypedValue, version fieldpath.APIVersion, managers fieldpath.ManagedFields, manager string, force bool) (*typed.TypedValue, fieldpath.ManagedFields, error) {
-	var err error
-	managers, err = s.reconcileManagedFieldsWithSchemaChanges(liveObject, managers)
+	return s.apply(liveObject, configObject, version, fieldpath.NewManagedFieldsSnapshot(managers), manager, "", force, nil)
+}
+
+// ApplySnapshot is like Apply, but takes and returns read-only managers.
+func (s *Updater) ApplySnapshot(liveObject, configObject *typed.TypedValue, version fieldpath.APIVersion, managers fieldpath.ManagedFieldsSnapshot, manager string, force bool) (*typed.TypedValue, fieldpath.ManagedFieldsSnapshot, error) {
+	object, newManagers, err := s.apply(liveObject, configObject, version, managers, manager, "", force, nil)
+	if err != nil {
+		return nil, fieldpath.ManagedFieldsSnapshot{}, err
+	}
+	return object, fieldpath.NewManagedFieldsSnapshot(newManagers), nil
+}
+
+// ApplyForcing is like Apply, but only forces the conflicting fields
+// selected by forced, e.g. fieldpath.NewIncludeSetFilter to force the
+// fields under some paths. It fails with the other conflicts, if any, in
+// which case no field is forced.
+//
+// The conflicting fields are given to forced in the version of the set of
+// their manager, which can differ from version.
+func (s *Updater) ApplyForcing(liveObject, configObject *typed.TypedValue, version fieldpath.APIVersion, managers fieldpath.ManagedFields, manager string, forced fieldpath.Filter) (*typed.TypedValue, fieldpath.ManagedFields, error) {
+	return s.apply(liveObject, configObject, version, fieldpath.NewManagedFieldsSnapshot(managers), manager, "", false, forced)
+}
+
+// ApplyWithDirectives is like Apply, but the configuration can have
+// directives, as described by typed.TypedValue.Overlay, which remove fields
+// from the object whichever manager owns them: "$patch: delete" in a map
+// deletes it, and "$patch: replace" in a map replaces the one of the object
+// rather than merging with it, while "$deleteFields" and "$replaceFields"
+// in a map list its fields to delete and its associative lists to replace.
+// This lets manager remove fields which it never owned, which omitting them
+// can't express. The fields removed this way are conflicts with their other
+// managers, unless force is set, in which case they lose them.
+//
+// The nulls of the configuration are kept as they are, see WithNullPolicy.
+func (s *Updater) ApplyWithDirectives(liveObject *typed.TypedValue, config value.Value, version fieldpath.APIVersion, managers fieldpath.ManagedFields, manager string, force bool) (*typed.TypedValue, fieldpath.ManagedFields, error) {
+	configObject, directives, err := liveObject.ExtractDirectives(config)
+	if err != nil {
+		return nil, fieldpath.ManagedFields{}, fmt.Errorf("invalid config: %v", err)
+	}
+	if directives.Empty() {
+		return s.Apply(liveObject, configObject, version, managers, manager, force)
+	}
+	newLive := liveObject.Empty()
+	if !directives.ReplaceRoot {
+		newLive = liveObject.RemoveItems(directives.Delete.Union(directives.Replace))
+	}
+	removed, err := removedFields(liveObject, newLive, configObject)
+	if err != nil {
+		return nil, fieldpath.ManagedFields{}, err
+	}
+	if !force {
+		if err := s.removalConflicts(managers, removed, version, manager); err != nil {
+			return nil, fieldpath.ManagedFields{}, err
+		}
+	}
+	managers, err = s.RemoveFields(managers, removed, version)
+	if err != nil {
+		return nil, fieldpath.ManagedFields{}, err
+	}
+	newObject, managers, err := s.apply(newLive, configObject, version, fieldpath.NewManagedFieldsSnapshot(managers), manager, "", force, nil)
+	if err != nil {
+		return nil, fieldpath.ManagedFields{}, err
+	}
+	if newObject == nil && !s.equals(liveObject, newLive) {
+		newObject = newLive
+	}
+	return newObject, managers, nil
+}
+
+// removedFields returns the fields of live which are neither in newLive nor
+// in config, i.e. removed by the directives of config.
+func removedFields(live, newLive, config *typed.TypedValue) (*fieldpath.Set, error) {
+	liveSet, err := live.ToFieldSet()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get field set: %v", err)
+	}
+	newLiveSet, err := newLive.ToFieldSet()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get field set: %v", err)
+	}
+	configSet, err := config.ToFieldSet()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get field set: %v", err)
+	}
+	return liveSet.Difference(newLiveSet).Difference(configSet), nil
+}
+
+// removalConflicts returns the conflicts of the managers but manager which
+// own fields of removed, in version.
+func (s *Updater) removalConflicts(managers fieldpath.ManagedFields, removed *fieldpath.Set, version fieldpath.APIVersion, manager string) error {
+	sc, _ := s.Converter.(SetConverter)
+	sets := VersionedSets{Converter: sc}
+	conflicting := fieldpath.ManagedFields{}
+	for m, set := range managers {
+		if m == manager {
+			continue
+		}
+		owned, err := sets.Intersection(set, fieldpath.NewVersionedSet(removed, version, false))
+		if err != nil {
+			return fmt.Errorf("failed to check the fields owned by %q: %v", m, err)
+		}
+		if !owned.Set().Empty() {
+			conflicting[m] = owned
+		}
+	}
+	if len(conflicting) > 0 {
+		return ConflictsFromManagers(conflicting)
+	}
+	return nil
+}
+
+// checkSubresource returns an error if manager owns fields through another
+// subresource than subresource, or the main resource if it is empty: the
+// name is then the one of another manager, see fieldpath.SubresourceManager.
+func checkSubresource(managers fieldpath.ManagedFieldsSnapshot, manager, subresource string) error {
+	set, ok := managers.Get(manager)
+	if !ok || fieldpath.Subresource(set) == subresource {
+		return nil
+	}
+	if owner := fieldpath.Subresource(set); owner != "" {
+		return fmt.Errorf("manager %q already owns fields through subresource %q", manager, owner)
+	}
+	return fmt.Errorf("manager %q already owns fields through the main resource", manager)
+}
+
+// apply returns the merged object and its managers. It only reads
+// snapshot: the managers are written to a new map, so that the sets of the
+// managers are never copied.
+func (s *Updater) apply(liveObject, configObject *typed.TypedValue, version fieldpath.APIVersion, snapshot fieldpath.ManagedFieldsSnapshot, manager, subresource string, force bool, forced fieldpath.Filter) (*typed.TypedValue, fieldpath.ManagedFields, error) {
+	if err := configObject.CheckUntypedLimits(s.untypedLimits); err != nil {
+		return nil, fieldpath.ManagedFields{}, err
+	}
+	if err := checkSubresource(snapshot, manager, subresource); err != nil {
+		return nil, fieldpath.ManagedFields{}, err
+	}
+	managers, err := s.reconcileManagedFieldsWithSchemaChanges(liveObject, snapshot)
 	if err != nil {
 		return nil, fieldpath.ManagedFields{}, err
 	}
-	newObject, err := liveObject.Merge(configObject)
+	// The keys of the items of the lists are computed once: the ones of
+	// the live and applied objects by the merge and ToFieldSet, and the
+	// ones of the merged object as it is built, for Compare if the merged
+	// object is compared again.
+	index := typed.NewListIndex()
+	liveObject = liveObject.WithListIndex(index)
+	configObject = configObject.WithListIndex(index)
+	var mergeOpts []typed.MergeOption
+	if s.mergeTracer != nil {
+		mergeOpts = append(mergeOpts, typed.WithMergeTracer(s.mergeTracer))
+	}
+	if s.nullPolicy != "" {
+		mergeOpts = append(mergeOpts, typed.WithNullPolicy(s.nullPolicy))
+	}
+	mergeOpts = append(mergeOpts, typed.WithMergeBudget(s.mergeBudget))
+	if s.shareValues {
+		mergeOpts = append(mergeOpts, typed.WithSharedValues())
+	}
+	// The live object is compared with the merged one as they are merged,
+	// rather than walked again to detect the conflicts, unless some fields
+	// are pruned.
+	merged, err := liveObject.MergeAndCompare(configObject, mergeOpts, s.compareOptions())
 	if err != nil {
-		return nil, fieldpath.ManagedFields{}, fmt.Errorf("failed to merge config: %v", err)
+		return nil, fieldpath.ManagedFields{}, fmt.Errorf("failed to merge config: %w", err)
 	}
+	newObject := merged.Merged
 	lastSet := managers[manager]
-	set, err := configObject.ToFieldSet()
+	set, err := configObject.ToFieldSet(typed.WithFieldSetNullPolicy(s.nullPolicy))
 	if err != nil {
 		return nil, fieldpath.ManagedFields{}, fmt.Errorf("failed to get field set: %v", err)
 	}
@@ -233,19 +620,94 @@ func (s *Updater) Apply(liveObject, configObject *typed.TypedValue, version fiel
 	if ignoreFilter != nil {
 		set = ignoreFilter.Filter(set)
 	}
-	managers[manager] = fieldpath.NewVersionedSet(set, version, true)
-	newObject, err = s.prune(newObject, managers, manager, lastSet)
+	if s.ignored != nil {
+		set = set.RecursiveDifference(s.ignored)
+	}
+	if volatile := newObject.VolatileFields(); !volatile.Empty() {
+		set = set.RecursiveDifference(volatile)
+	}
+	if s.mapKeys != nil {
+		set = s.mapKeys.Filter(set)
+	}
+	set = set.WithOwnership(s.ownership)
+	managers[manager] = fieldpath.NewSubresourceVersionedSet(set, version, true, subresource)
+	pruned, err := s.prune(newObject, managers, manager, lastSet)
 	if err != nil {
 		return nil, fieldpath.ManagedFields{}, fmt.Errorf("failed to prune fields: %v", err)
 	}
-	managers, _, err = s.update(liveObject, newObject, version, managers, manager, force)
+	compare, unchanged := merged.Comparison, merged.Unchanged
+	if pruned != newObject {
+		newObject = pruned
+		compare = nil
+		unchanged = !s.returnInputOnNoop && s.equals(liveObject, newObject)
+	}
+	managers, _, err = s.update(liveObject, newObject, compare, version, managers, manager, force, forced)
 	if err != nil {
 		return nil, fieldpath.ManagedFields{}, err
 	}
-	if !s.returnInputOnNoop && value.EqualsUsing(value.NewFreelistAllocator(), liveObject.AsValue(), newObject.AsValue()) {
-		newObject = nil
+	if !s.returnInputOnNoop && unchanged {
+		return nil, managers, nil
 	}
-	return newObject, managers, nil
+	// The index isn't needed anymore, don't keep it with the object.
+	return newObject.WithListIndex(nil), managers, nil
+}
+
+// ApplyCreate should be called when Apply creates the object, i.e. there is
+// no live object, given the configuration that is applied by manager. It
+// returns the created object and its managers: only manager, unless it
+// owns no field.
+//
+// Unlike Apply with an empty live object, the fields omitted by the
+// configuration which have a default in the schema are set, and owned by
+// no manager, and the created object is always returned, even if it is
+// empty.
+//
+// Schemas have no immutable fields, so ApplyCreate enforces no immutability
+// rule: such rules restrict the updates of existing objects, and are left
+// to the callers, which may compare the live object with the one returned
+// by Apply.
+func (s *Updater) ApplyCreate(configObject *typed.TypedValue, version fieldpath.APIVersion, manager string) (*typed.TypedValue, fieldpath.ManagedFields, error) {
+	newObject, managers, err := s.apply(configObject.Empty(), configObject, version, fieldpath.ManagedFieldsSnapshot{}, manager, "", false, nil)
+	if err != nil {
+		return nil, fieldpath.ManagedFields{}, err
+	}
+	if newObject == nil {
+		newObject = configObject
+	}
+	return newObject.WithDefaults(), managers, nil
+}
+
+// RemoveFields returns the managers without the fields of removed, in
+// version, nor their children. It is meant for fields removed from the
+// object by something else than the Updater, e.g. pruned by another system,
+// which would otherwise stay owned by all the managers but the next one to
+// update the object. removed is converted to the versions of the managers
+// if the Converter is a SetConverter, otherwise managers of other versions
+// are an error. Since conversions work path by path, removed should list the
+// removed children too, not only their parents.
+func (s *Updater) RemoveFields(managers fieldpath.ManagedFields, removed *fieldpath.Set, version fieldpath.APIVersion) (fieldpath.ManagedFields, error) {
+	sc, _ := s.Converter.(SetConverter)
+	sets := VersionedSets{Converter: sc}
+	converted := map[fieldpath.APIVersion]fieldpath.VersionedSet{}
+	out := fieldpath.ManagedFields{}
+	for manager, set := range managers {
+		r, ok := converted[set.APIVersion()]
+		if !ok {
+			var err error
+			r, err = sets.Convert(fieldpath.NewVersionedSet(removed, version, false), set.APIVersion())
+			if err == nil {
+				err = fieldpath.CheckVersions("difference", set, r)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to remove the fields owned by %q: %v", manager, err)
+			}
+			converted[set.APIVersion()] = r
+		}
+		if left := set.Set().RecursiveDifference(r.Set()); !left.Empty() {
+			out[manager] = fieldpath.WithSet(set, left, set.APIVersion())
+		}
+	}
+	return out, nil
 }
 
 // prune will remove a field, list or map item, iff:
@@ -256,6 +718,11 @@ func (s *Updater) prune(merged *typed.TypedValue, managers fieldpath.ManagedFiel
 	if lastSet == nil || lastSet.Set().Empty() {
 		return merged, nil
 	}
+	// Nothing is pruned if the manager still applies all the fields it
+	// applied last time, the fields it owns are kept.
+	if applied := managers[applyingManager]; applied.APIVersion() == lastSet.APIVersion() && lastSet.Set().Difference(applied.Set()).Empty() {
+		return merged, nil
+	}
 	version := lastSet.APIVersion()
 	convertedMerged, err := s.Converter.Convert(merged, version)
 	if err != nil {
@@ -282,12 +749,13 @@ func (s *Updater) prune(merged *typed.TypedValue, managers fieldpath.ManagedFiel
 // but other appliers or updaters (or the current applier's new config) claim to own.
 func (s *Updater) addBackOwnedItems(merged, pruned *typed.TypedValue, prunedVersion fieldpath.APIVersion, managedFields fieldpath.ManagedFields, applyingManager string) (*typed.TypedValue, error) {
 	var err error
-	managedAtVersion := map[fieldpath.APIVersion]*fieldpath.Set{}
+	setsAtVersion := map[fieldpath.APIVersion][]*fieldpath.Set{}
 	for _, managerSet := range managedFields {
-		if _, ok := managedAtVersion[managerSet.APIVersion()]; !ok {
-			managedAtVersion[managerSet.APIVersion()] = fieldpath.NewSet()
-		}
-		managedAtVersion[managerSet.APIVersion()] = managedAtVersion[managerSet.APIVersion()].Union(managerSet.Set())
+		setsAtVersion[managerSet.APIVersion()] = append(setsAtVersion[managerSet.APIVersion()], managerSet.Set())
+	}
+	managedAtVersion := map[fieldpath.APIVersion]*fieldpath.Set{}
+	for version, sets := range setsAtVersion {
+		managedAtVersion[version] = fieldpath.NewSet().UnionAll(sets...)
 	}
 	// Add back owned items at pruned version first to avoid conversion failure
 	// caused by pruned fields which are required for conversion.
@@ -370,25 +838,35 @@ func (s *Updater) addBackDanglingItems(merged, pruned *typed.TypedValue, lastSet
 // Supports:
 // - changing types from atomic to granular
 // - changing types from granular to atomic
-func (s *Updater) reconcileManagedFieldsWithSchemaChanges(liveObject *typed.TypedValue, managers fieldpath.ManagedFields) (fieldpath.ManagedFields, error) {
-	result := fieldpath.ManagedFields{}
-	for manager, versionedSet := range managers {
-		tv, err := s.Converter.Convert(liveObject, versionedSet.APIVersion())
+//
+// The managers are returned in a new map, where the updater writes.
+func (s *Updater) reconcileManagedFieldsWithSchemaChanges(liveObject *typed.TypedValue, managers fieldpath.ManagedFieldsSnapshot) (fieldpath.ManagedFields, error) {
+	result := make(fieldpath.ManagedFields, managers.Len())
+	var err error
+	managers.Iterate(func(manager string, versionedSet fieldpath.VersionedSet) bool {
+		var tv *typed.TypedValue
+		tv, err = s.Converter.Convert(liveObject, versionedSet.APIVersion())
 		if s.Converter.IsMissingVersionError(err) { // okay to skip, obsolete versions will be deleted automatically anyway
-			continue
+			err = nil
+			return true
 		}
 		if err != nil {
-			return nil, err
+			return false
 		}
-		reconciled, err := typed.ReconcileFieldSetWithSchema(versionedSet.Set(), tv)
+		var reconciled *fieldpath.Set
+		reconciled, err = typed.ReconcileFieldSetWithSchema(versionedSet.Set(), tv)
 		if err != nil {
-			return nil, err
+			return false
 		}
 		if reconciled != nil {
-			result[manager] = fieldpath.NewVersionedSet(reconciled, versionedSet.APIVersion(), versionedSet.Applied())
+			result[manager] = fieldpath.WithSet(versionedSet, reconciled, versionedSet.APIVersion())
 		} else {
 			result[manager] = versionedSet
 		}
+		return true
+	})
+	if err != nil {
+		return nil, err
 	}
 	return result, nil
 }
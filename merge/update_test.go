@@ -0,0 +1,230 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package merge_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
+	"sigs.k8s.io/structured-merge-diff/v4/internal/fixture"
+	"sigs.k8s.io/structured-merge-diff/v4/merge"
+	"sigs.k8s.io/structured-merge-diff/v4/typed"
+)
+
+func TestSnapshots(t *testing.T) {
+	updater := &merge.Updater{Converter: &specificVersionConverter{
+		AcceptedVersions: []fieldpath.APIVersion{"v1"},
+	}}
+	pt := leafFieldsParser.Type("v1")
+	live, err := pt.FromYAML(`{"numeric": 1, "string": "a", "bool": true}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bystander := fieldpath.NewVersionedSet(_NS(_P("bool")), "v1", false)
+	initial := fieldpath.NewManagedFieldsSnapshot(fieldpath.ManagedFields{
+		"controller": fieldpath.NewVersionedSet(_NS(_P("numeric"), _P("string")), "v1", false),
+		"bystander":  bystander,
+	})
+
+	updated, err := pt.FromYAML(`{"numeric": 2, "string": "a", "bool": true}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	live, managers, err := updater.UpdateSnapshot(live, updated, "v1", initial, "other")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The sets of the managers which don't change are shared, not copied.
+	if set, _ := managers.Get("bystander"); set.Set() != bystander.Set() {
+		t.Errorf("expected the set of bystander to be shared")
+	}
+	config, err := pt.FromYAML(`{"string": "b"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := updater.ApplySnapshot(live, config, "v1", managers, "applier", false); err == nil {
+		t.Fatal("expected a conflict with controller")
+	}
+	_, managers, err = updater.ApplySnapshot(live, config, "v1", managers, "applier", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := fieldpath.NewManagedFieldsSnapshot(fieldpath.ManagedFields{
+		"other":     fieldpath.NewVersionedSet(_NS(_P("numeric")), "v1", false),
+		"applier":   fieldpath.NewVersionedSet(_NS(_P("string")), "v1", true),
+		"bystander": bystander,
+	})
+	if !managers.Equals(expected) {
+		t.Errorf("expected managers:\n%v\ngot:\n%v", expected, managers)
+	}
+	if set, ok := initial.Get("controller"); !ok || !set.Set().Equals(_NS(_P("numeric"), _P("string"))) {
+		t.Errorf("the initial managers were modified: %v", initial)
+	}
+}
+
+func TestApplyForcing(t *testing.T) {
+	updater := &merge.Updater{Converter: &specificVersionConverter{
+		AcceptedVersions: []fieldpath.APIVersion{"v1"},
+	}}
+	pt := leafFieldsParser.Type("v1")
+	live, err := pt.FromYAML(`{"numeric": 1, "string": "a"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	managers := fieldpath.ManagedFields{
+		"apply-one": fieldpath.NewVersionedSet(_NS(_P("numeric"), _P("string")), "v1", true),
+	}
+	forced := fieldpath.NewIncludeSetFilter(_NS(_P("numeric")))
+
+	config, err := pt.FromYAML(`{"numeric": 2, "string": "b"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = updater.ApplyForcing(live, config, "v1", managers.Copy(), "apply-two", forced)
+	conflicts, ok := err.(merge.Conflicts)
+	if !ok {
+		t.Fatalf("expected conflicts, got %v", err)
+	}
+	if expected := (merge.Conflicts{{Manager: "apply-one", Path: _P("string")}}); !conflicts.Equals(expected) {
+		t.Errorf("expected conflicts %v, got %v", expected, conflicts)
+	}
+
+	config, err = pt.FromYAML(`{"numeric": 2, "string": "a"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	object, managers, err := updater.ApplyForcing(live, config, "v1", managers, "apply-two", forced)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := fieldpath.ManagedFields{
+		"apply-one": fieldpath.NewVersionedSet(_NS(_P("string")), "v1", true),
+		"apply-two": fieldpath.NewVersionedSet(_NS(_P("numeric"), _P("string")), "v1", true),
+	}
+	if !managers.Equals(expected) {
+		t.Errorf("expected managers %v, got %v", expected, managers)
+	}
+	if got, _ := _P("numeric").Lookup(object.AsValue()); got == nil || got.AsInt() != 2 {
+		t.Errorf("expected numeric to be forced to 2, got %v", got)
+	}
+}
+
+func TestRemoveFields(t *testing.T) {
+	converter, err := fixture.NewVersionSkewConverter(versionSkewParser, []byte(versionSkew))
+	if err != nil {
+		t.Fatal(err)
+	}
+	managers := fieldpath.ManagedFields{
+		"applier":  fieldpath.NewVersionedSet(_NS(_P("name"), _P("spec", "replicas")), "v1", true),
+		"updater":  fieldpath.NewVersionedSet(_NS(_P("spec", "size")), "v2", false),
+		"counter":  fieldpath.NewVersionedSet(_NS(_P("count")), "v3", false),
+		"namer-v3": fieldpath.NewVersionedSet(_NS(_P("name")), "v3", false),
+	}
+	updater := &merge.Updater{Converter: converter}
+	// The removed children are listed, for them to be converted too.
+	removed := _NS(_P("spec"), _P("spec", "replicas"))
+	out, err := updater.RemoveFields(managers, removed, "v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := fieldpath.ManagedFields{
+		"applier":  fieldpath.NewVersionedSet(_NS(_P("name")), "v1", true),
+		"namer-v3": fieldpath.NewVersionedSet(_NS(_P("name")), "v3", false),
+	}
+	if !out.Equals(expected) {
+		t.Errorf("expected:\n%v\ngot:\n%v", expected, out)
+	}
+
+	updater = &merge.Updater{Converter: &specificVersionConverter{
+		AcceptedVersions: []fieldpath.APIVersion{"v1", "v2", "v3"},
+	}}
+	if _, err := updater.RemoveFields(managers, removed, "v1"); err == nil {
+		t.Error("expected an error for managers of other versions without a SetConverter")
+	}
+}
+
+func TestUpdateFromMissingVersion(t *testing.T) {
+	converter, err := fixture.NewVersionSkewConverter(versionSkewParser, []byte(versionSkew))
+	if err != nil {
+		t.Fatal(err)
+	}
+	updater := &merge.Updater{Converter: converter}
+	pt := versionSkewParser.Type("v1")
+	live, err := pt.FromYAML(`{"name": "a", "spec": {"replicas": 1}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	updated, err := pt.FromYAML(`{"name": "a", "spec": {"replicas": 2}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	managers := fieldpath.ManagedFields{
+		"updater": fieldpath.NewVersionedSet(_NS(_P("name")), "v0", false),
+	}
+	_, managers, err = updater.Update(live, updated, "v1", managers, "updater")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The fields owned in the obsolete version are dropped.
+	expected := fieldpath.ManagedFields{
+		"updater": fieldpath.NewVersionedSet(_NS(_P("spec", "replicas")), "v1", false),
+	}
+	if !managers.Equals(expected) {
+		t.Errorf("expected:\n%v\ngot:\n%v", expected, managers)
+	}
+}
+
+func TestApplyWithDecodedLongKeys(t *testing.T) {
+	updater := &merge.Updater{Converter: &specificVersionConverter{
+		AcceptedVersions: []fieldpath.APIVersion{"v1"},
+	}}
+	pt := associativeListParser.Type("v1")
+	name := strings.Repeat("a", 2*fieldpath.MaxPathElementValueLength)
+	live, err := pt.FromYAML(typed.YAMLObject(fmt.Sprintf(`{"list": [{"name": %q, "value": 1}]}`, name)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The managed fields stored before the long values were hashed hold
+	// the raw key.
+	managers, err := fieldpath.DecodeManagedFields([]byte(fmt.Sprintf(`[{
+		"manager": "owner", "operation": "Apply", "apiVersion": "v1", "fieldsType": "FieldsV1",
+		"fieldsV1": {"f:list": {"k:{\"name\":\"%s\"}": {".": {}, "f:name": {}, "f:value": {}}}}
+	}]`, name)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := pt.FromYAML(typed.YAMLObject(fmt.Sprintf(`{"list": [{"name": %q, "value": 2}]}`, name)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = updater.Apply(live, config, "v1", managers, "other", false)
+	conflicts, ok := err.(merge.Conflicts)
+	if !ok || len(conflicts) != 1 || conflicts[0].Manager != "owner" {
+		t.Fatalf("expected a conflict with owner, got %v", err)
+	}
+	_, managers, err = updater.Apply(live, config, "v1", managers, "other", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if set := managers["owner"].Set(); set.Size() != 2 {
+		t.Errorf("expected owner to keep the item and its name, got %v", set)
+	}
+}
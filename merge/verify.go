@@ -0,0 +1,134 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package merge
+
+import (
+	"fmt"
+	"strings"
+
+	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
+	"sigs.k8s.io/structured-merge-diff/v4/schema"
+	"sigs.k8s.io/structured-merge-diff/v4/typed"
+)
+
+// IdempotencyError reports the fields of an object which merging or
+// applying the object onto itself changes, see VerifyIdempotent.
+type IdempotencyError struct {
+	// Merged lists the fields whose value changes when the object is
+	// merged with itself.
+	Merged *fieldpath.Set
+	// Reapplied lists the fields whose value or ownership changes when the
+	// object is applied a second time.
+	Reapplied *fieldpath.Set
+}
+
+func (e *IdempotencyError) Error() string {
+	var b strings.Builder
+	b.WriteString("merge is not idempotent")
+	if !e.Merged.Empty() {
+		fmt.Fprintf(&b, "; merging the object with itself changes:\n%v", e.Merged)
+	}
+	if !e.Reapplied.Empty() {
+		fmt.Fprintf(&b, "; applying the object twice changes:\n%v", e.Reapplied)
+	}
+	return b.String()
+}
+
+// verifyVersion and verifyManager are the version and manager used to apply
+// objects in VerifyIdempotent.
+const (
+	verifyVersion fieldpath.APIVersion = "v1"
+	verifyManager                      = "verify"
+)
+
+// VerifyIdempotent checks that merging object, of the type named typeName in
+// s, with itself gives back object, and that applying object a second time
+// changes neither the object nor its managed fields. Keys, list types and
+// other schema choices which break these properties surprise users, so
+// schema authors can run this on sample objects.
+//
+// It returns an *IdempotencyError listing the offending fields, or another
+// error if the object isn't valid.
+func VerifyIdempotent(s *schema.Schema, typeName string, object typed.YAMLObject) error {
+	pt := typed.ParseableType{Schema: s, TypeRef: schema.TypeRef{NamedType: &typeName}}
+	tv, err := pt.FromYAML(object)
+	if err != nil {
+		return err
+	}
+	result := &IdempotencyError{Merged: &fieldpath.Set{}, Reapplied: &fieldpath.Set{}}
+
+	merged, err := tv.Merge(tv)
+	if err != nil {
+		return fmt.Errorf("failed to merge the object with itself: %v", err)
+	}
+	if result.Merged, err = changedFields(tv, merged); err != nil {
+		return err
+	}
+
+	live := tv
+	if atom, ok := s.Resolve(pt.TypeRef); ok && atom.Map != nil {
+		if live, err = pt.FromUnstructured(map[string]interface{}{}); err != nil {
+			return err
+		}
+	}
+	updater := (&UpdaterBuilder{Converter: identityConverter{}, ReturnInputOnNoop: true}).BuildUpdater()
+	first, firstManagers, err := updater.Apply(live, tv, verifyVersion, fieldpath.ManagedFields{}, verifyManager, false)
+	if err != nil {
+		return fmt.Errorf("failed to apply the object: %v", err)
+	}
+	second, secondManagers, err := updater.Apply(first, tv, verifyVersion, firstManagers, verifyManager, false)
+	if err != nil {
+		return fmt.Errorf("failed to apply the object a second time: %v", err)
+	}
+	if result.Reapplied, err = changedFields(first, second); err != nil {
+		return err
+	}
+	firstSet, secondSet := ownedFields(firstManagers), ownedFields(secondManagers)
+	result.Reapplied = result.Reapplied.Union(firstSet.Difference(secondSet)).Union(secondSet.Difference(firstSet))
+
+	if result.Merged.Empty() && result.Reapplied.Empty() {
+		return nil
+	}
+	return result
+}
+
+// changedFields returns the fields which differ between lhs and rhs.
+func changedFields(lhs, rhs *typed.TypedValue) (*fieldpath.Set, error) {
+	c, err := lhs.Compare(rhs)
+	if err != nil {
+		return nil, err
+	}
+	return c.Added.Union(c.Modified).Union(c.Removed), nil
+}
+
+func ownedFields(managers fieldpath.ManagedFields) *fieldpath.Set {
+	if set, ok := managers[verifyManager]; ok {
+		return set.Set()
+	}
+	return &fieldpath.Set{}
+}
+
+// identityConverter is a Converter for objects which only have one version.
+type identityConverter struct{}
+
+func (identityConverter) Convert(object *typed.TypedValue, _ fieldpath.APIVersion) (*typed.TypedValue, error) {
+	return object, nil
+}
+
+func (identityConverter) IsMissingVersionError(error) bool {
+	return false
+}
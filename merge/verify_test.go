@@ -0,0 +1,118 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package merge_test
+
+import (
+	"errors"
+	"testing"
+
+	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
+	"sigs.k8s.io/structured-merge-diff/v4/merge"
+	"sigs.k8s.io/structured-merge-diff/v4/typed"
+)
+
+var verifyParser = func() *typed.Parser {
+	parser, err := typed.NewParser(`types:
+- name: type
+  map:
+    fields:
+    - name: name
+      type:
+        scalar: string
+    - name: floats
+      type:
+        list:
+          elementType:
+            scalar: numeric
+          elementRelationship: associative
+    - name: items
+      type:
+        list:
+          elementType:
+            namedType: item
+          elementRelationship: associative
+          keys:
+          - key
+- name: item
+  map:
+    fields:
+    - name: key
+      type:
+        scalar: numeric
+    - name: value
+      type:
+        scalar: string
+`)
+	if err != nil {
+		panic(err)
+	}
+	return parser
+}()
+
+func TestVerifyIdempotent(t *testing.T) {
+	tests := []struct {
+		name   string
+		object typed.YAMLObject
+		// changed is the field which merging and applying change, if any.
+		changed string
+		err     bool
+	}{{
+		name:   "idempotent",
+		object: `{"name": "a", "floats": [1.5, 2], "items": [{"key": 1, "value": "a"}, {"key": 2.5}]}`,
+	}, {
+		name:    "NaN in a set",
+		object:  `{"floats": [1, .nan]}`,
+		changed: "floats",
+	}, {
+		name:    "NaN key",
+		object:  `{"items": [{"key": .nan, "value": "a"}]}`,
+		changed: "items",
+	}, {
+		name:   "invalid object",
+		object: `{"items": [{"key": 1}, {"key": 1.0}]}`,
+		err:    true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := merge.VerifyIdempotent(&verifyParser.Schema, "type", test.object)
+			var idempotencyErr *merge.IdempotencyError
+			switch {
+			case test.changed != "":
+				if !errors.As(err, &idempotencyErr) {
+					t.Fatalf("expected an IdempotencyError, got %v", err)
+				}
+				for _, set := range []*fieldpath.Set{idempotencyErr.Merged, idempotencyErr.Reapplied} {
+					if set.Empty() {
+						t.Errorf("expected changes under %v, got none", test.changed)
+					}
+					set.Iterate(func(p fieldpath.Path) {
+						if p[0].FieldName == nil || *p[0].FieldName != test.changed {
+							t.Errorf("expected changes under %v, got %v", test.changed, p)
+						}
+					})
+				}
+			case test.err:
+				if err == nil || errors.As(err, &idempotencyErr) {
+					t.Errorf("expected a validation error, got %v", err)
+				}
+			case err != nil:
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,202 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package merge_test
+
+import (
+	"testing"
+
+	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
+	. "sigs.k8s.io/structured-merge-diff/v4/internal/fixture"
+	"sigs.k8s.io/structured-merge-diff/v4/merge"
+	"sigs.k8s.io/structured-merge-diff/v4/typed"
+)
+
+var versionSkewParser = func() Parser {
+	parser, err := typed.NewParser(`types:
+- name: v1
+  map:
+    fields:
+    - name: name
+      type:
+        scalar: string
+    - name: spec
+      type:
+        map:
+          fields:
+          - name: replicas
+            type:
+              scalar: numeric
+- name: v2
+  map:
+    fields:
+    - name: name
+      type:
+        scalar: string
+    - name: spec
+      type:
+        map:
+          fields:
+          - name: size
+            type:
+              scalar: numeric
+- name: v3
+  map:
+    fields:
+    - name: name
+      type:
+        scalar: string
+    - name: count
+      type:
+        scalar: numeric
+`)
+	if err != nil {
+		panic(err)
+	}
+	return parser
+}()
+
+const versionSkew = `
+hub: v1
+versions:
+- version: v2
+  fields:
+  - from: spec.size
+    to: spec.replicas
+- version: v3
+  fields:
+  - from: count
+    to: spec.replicas
+`
+
+func TestVersionSkew(t *testing.T) {
+	converter, err := NewVersionSkewConverter(versionSkewParser, []byte(versionSkew))
+	if err != nil {
+		t.Fatal(err)
+	}
+	test := TestCase{
+		Ops: []Operation{
+			Apply{
+				Manager:    "applier",
+				APIVersion: "v1",
+				Object: `
+					name: a
+					spec:
+					  replicas: 1
+				`,
+			},
+			Update{
+				Manager:    "updater",
+				APIVersion: "v2",
+				Object: `
+					name: a
+					spec:
+					  size: 2
+				`,
+			},
+			Apply{
+				Manager:    "applier",
+				APIVersion: "v1",
+				Object: `
+					name: a
+					spec:
+					  replicas: 1
+				`,
+				// Conflicts are reported in the version of their manager.
+				Conflicts: merge.Conflicts{
+					merge.Conflict{Manager: "updater", Path: _P("spec", "size")},
+				},
+			},
+		},
+		Object: `
+			name: a
+			count: 2
+		`,
+		APIVersion: "v3",
+		Managed: fieldpath.ManagedFields{
+			"applier": fieldpath.NewVersionedSet(
+				_NS(
+					_P("name"),
+				),
+				"v1",
+				true,
+			),
+			"updater": fieldpath.NewVersionedSet(
+				_NS(
+					_P("spec", "size"),
+				),
+				"v2",
+				false,
+			),
+		},
+	}
+	if err := test.TestWithConverter(versionSkewParser, converter); err != nil {
+		t.Fatal(err)
+	}
+
+	// The fields owned by a manager are converted when it updates the
+	// object in another version.
+	test = TestCase{
+		Ops: []Operation{
+			Update{
+				Manager:    "updater",
+				APIVersion: "v1",
+				Object: `
+					name: a
+					spec:
+					  replicas: 1
+				`,
+			},
+			Update{
+				Manager:    "updater",
+				APIVersion: "v3",
+				Object: `
+					name: b
+					count: 1
+				`,
+			},
+		},
+		Object: `
+			name: b
+			spec:
+			  size: 1
+		`,
+		APIVersion: "v2",
+		Managed: fieldpath.ManagedFields{
+			"updater": fieldpath.NewVersionedSet(
+				_NS(
+					_P("name"),
+					_P("count"),
+				),
+				"v3",
+				false,
+			),
+		},
+	}
+	if err := test.TestWithConverter(versionSkewParser, converter); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, mapping := range []string{
+		`versions: []`,
+		"hub: v1\nversions:\n- version: v2\n  fields:\n  - from: spec.size",
+		"hub: v1\nversions:\n- version: v1",
+	} {
+		if _, err := NewVersionSkewConverter(versionSkewParser, []byte(mapping)); err == nil {
+			t.Errorf("expected an error for mapping %q", mapping)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package merge
+
+import (
+	"fmt"
+
+	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
+)
+
+// VersionedSets does set algebra on VersionedSets which may be of
+// different versions. The rhs of an operation is converted to the version
+// of the lhs with the Converter, and a nil Converter refuses to mix
+// versions like the fieldpath functions do.
+type VersionedSets struct {
+	Converter SetConverter
+}
+
+// Convert returns set in the given version.
+func (v VersionedSets) Convert(set fieldpath.VersionedSet, version fieldpath.APIVersion) (fieldpath.VersionedSet, error) {
+	if set.APIVersion() == version || v.Converter == nil {
+		return set, nil
+	}
+	converted, err := v.Converter.ConvertSet(set.Set(), set.APIVersion(), version)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert fields from version %v to %v: %v", set.APIVersion(), version, err)
+	}
+	return fieldpath.WithSet(set, converted, version), nil
+}
+
+// Union returns the union of lhs and rhs, in the version of lhs.
+func (v VersionedSets) Union(lhs, rhs fieldpath.VersionedSet) (fieldpath.VersionedSet, error) {
+	return v.apply(fieldpath.UnionVersioned, lhs, rhs)
+}
+
+// Difference returns the fields of lhs which aren't in rhs, in the version
+// of lhs.
+func (v VersionedSets) Difference(lhs, rhs fieldpath.VersionedSet) (fieldpath.VersionedSet, error) {
+	return v.apply(fieldpath.DifferenceVersioned, lhs, rhs)
+}
+
+// Intersection returns the fields in both lhs and rhs, in the version of
+// lhs.
+func (v VersionedSets) Intersection(lhs, rhs fieldpath.VersionedSet) (fieldpath.VersionedSet, error) {
+	return v.apply(fieldpath.IntersectionVersioned, lhs, rhs)
+}
+
+func (v VersionedSets) apply(op func(lhs, rhs fieldpath.VersionedSet) (fieldpath.VersionedSet, error), lhs, rhs fieldpath.VersionedSet) (fieldpath.VersionedSet, error) {
+	rhs, err := v.Convert(rhs, lhs.APIVersion())
+	if err != nil {
+		return nil, err
+	}
+	return op(lhs, rhs)
+}
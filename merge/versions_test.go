@@ -0,0 +1,108 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package merge_test
+
+import (
+	"testing"
+
+	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
+	. "sigs.k8s.io/structured-merge-diff/v4/internal/fixture"
+	"sigs.k8s.io/structured-merge-diff/v4/merge"
+)
+
+func TestVersionedSets(t *testing.T) {
+	converter, err := NewVersionSkewConverter(versionSkewParser, []byte(versionSkew))
+	if err != nil {
+		t.Fatal(err)
+	}
+	v1 := fieldpath.NewVersionedSet(_NS(_P("name"), _P("spec", "replicas")), "v1", true)
+	v2 := fieldpath.NewVersionedSet(_NS(_P("spec", "size")), "v2", false)
+	v3 := fieldpath.NewVersionedSet(_NS(_P("name"), _P("count")), "v3", false)
+
+	tests := []struct {
+		name      string
+		converter merge.SetConverter
+		op        func(merge.VersionedSets, fieldpath.VersionedSet, fieldpath.VersionedSet) (fieldpath.VersionedSet, error)
+		lhs, rhs  fieldpath.VersionedSet
+		out       *fieldpath.Set
+		err       string
+	}{
+		{
+			name:      "union",
+			converter: converter,
+			op:        merge.VersionedSets.Union,
+			lhs:       v2,
+			rhs:       v3,
+			out:       _NS(_P("name"), _P("spec", "size")),
+		}, {
+			name:      "difference",
+			converter: converter,
+			op:        merge.VersionedSets.Difference,
+			lhs:       v1,
+			rhs:       v2,
+			out:       _NS(_P("name")),
+		}, {
+			name:      "intersection",
+			converter: converter,
+			op:        merge.VersionedSets.Intersection,
+			lhs:       v3,
+			rhs:       v1,
+			out:       _NS(_P("name"), _P("count")),
+		}, {
+			name: "same version without converter",
+			op:   merge.VersionedSets.Union,
+			lhs:  v1,
+			rhs:  fieldpath.NewVersionedSet(_NS(_P("spec")), "v1", false),
+			out:  _NS(_P("name"), _P("spec"), _P("spec", "replicas")),
+		}, {
+			name: "refused without converter",
+			op:   merge.VersionedSets.Difference,
+			lhs:  v1,
+			rhs:  v2,
+			err:  `difference of sets of different versions "v1" and "v2"`,
+		}, {
+			name:      "conversion error",
+			converter: converter,
+			op:        merge.VersionedSets.Union,
+			lhs:       fieldpath.NewVersionedSet(_NS(), "v4", false),
+			rhs:       v1,
+			err:       "failed to convert fields from version v1 to v4: cannot convert to unknown version",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.op(merge.VersionedSets{Converter: tt.converter}, tt.lhs, tt.rhs)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("expected error %q, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !got.Set().Equals(tt.out) {
+				t.Errorf("expected %v, got %v", tt.out, got.Set())
+			}
+			if got.APIVersion() != tt.lhs.APIVersion() || got.Applied() != tt.lhs.Applied() {
+				t.Errorf("expected version and applied of lhs, got %v %v", got.APIVersion(), got.Applied())
+			}
+		})
+	}
+}
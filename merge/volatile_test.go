@@ -0,0 +1,104 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package merge_test
+
+import (
+	"testing"
+
+	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
+	. "sigs.k8s.io/structured-merge-diff/v4/internal/fixture"
+	"sigs.k8s.io/structured-merge-diff/v4/typed"
+)
+
+var volatileParser = func() Parser {
+	parser, err := typed.NewParser(`types:
+- name: v1
+  map:
+    fields:
+    - name: name
+      type:
+        scalar: string
+    - name: generation
+      type:
+        scalar: numeric
+      volatile: true
+`)
+	if err != nil {
+		panic(err)
+	}
+	return SameVersionParser{T: parser.Type("v1")}
+}()
+
+func TestVolatileFields(t *testing.T) {
+	tests := map[string]TestCase{
+		"apply_does_not_own_volatile": {
+			Ops: []Operation{
+				Apply{
+					Manager:    "applier",
+					APIVersion: "v1",
+					Object: `
+						name: a
+						generation: 1
+					`,
+				},
+			},
+			Object: `
+				name: a
+				generation: 1
+			`,
+			APIVersion: "v1",
+			Managed: fieldpath.ManagedFields{
+				"applier": fieldpath.NewVersionedSet(_NS(_P("name")), "v1", true),
+			},
+		},
+		"volatile_never_conflicts": {
+			Ops: []Operation{
+				Update{
+					Manager:    "server",
+					APIVersion: "v1",
+					Object: `
+						name: a
+						generation: 1
+					`,
+				},
+				Apply{
+					Manager:    "applier",
+					APIVersion: "v1",
+					Object: `
+						generation: 2
+					`,
+				},
+			},
+			Object: `
+				name: a
+				generation: 2
+			`,
+			APIVersion: "v1",
+			Managed: fieldpath.ManagedFields{
+				"server": fieldpath.NewVersionedSet(_NS(_P("name")), "v1", false),
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if err := test.Test(volatileParser); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
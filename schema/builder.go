@@ -0,0 +1,256 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package schema
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Builder builds a Schema in Go code, for generators and tests which would
+// otherwise write YAML only checked once parsed. The helpers below build
+// the type references with the right shapes, e.g. associative lists always
+// have keys, and Build checks what the types can't, like references to
+// undefined types:
+//
+//	b := schema.NewBuilder()
+//	b.Struct("pod").
+//		Field("name", schema.ScalarType(schema.String)).
+//		Field("containers", schema.AssociativeList(schema.Named("container"), "name"))
+//	b.Struct("container").
+//		Field("name", schema.ScalarType(schema.String)).
+//		Field("args", schema.AtomicList(schema.ScalarType(schema.String)))
+//	s, err := b.Build()
+//
+// The types are added in order. A Builder must not be used once built.
+type Builder struct {
+	types []TypeDef
+	// errs are the errors found while adding the types.
+	errs []string
+}
+
+// NewBuilder returns an empty builder.
+func NewBuilder() *Builder {
+	return &Builder{}
+}
+
+// Type adds a type named name, of the inlined type t.
+func (b *Builder) Type(name string, t TypeRef) *Builder {
+	if t.NamedType != nil {
+		b.errs = append(b.errs, fmt.Sprintf("type %q: must be inlined, not a reference to %q", name, *t.NamedType))
+		return b
+	}
+	b.types = append(b.types, TypeDef{Name: name, Atom: t.Inlined})
+	return b
+}
+
+// Struct adds a struct type named name, whose fields are added to the
+// returned StructBuilder.
+func (b *Builder) Struct(name string) *StructBuilder {
+	m := &Map{}
+	b.types = append(b.types, TypeDef{Name: name, Atom: Atom{Map: m}})
+	return &StructBuilder{m: m}
+}
+
+// Build returns the schema, or an error if it is invalid.
+func (b *Builder) Build() (*Schema, error) {
+	c := builderChecker{types: map[string]*Atom{}, errs: b.errs}
+	for i := range b.types {
+		t := &b.types[i]
+		if t.Name == "" {
+			c.errorf("type %d has no name", i)
+			continue
+		}
+		if _, ok := c.types[t.Name]; ok {
+			c.errorf("type %q is defined more than once", t.Name)
+			continue
+		}
+		c.types[t.Name] = &t.Atom
+	}
+	for i := range b.types {
+		t := &b.types[i]
+		c.checkAtom(fmt.Sprintf("type %q", t.Name), &t.Atom)
+	}
+	if len(c.errs) > 0 {
+		return nil, fmt.Errorf("invalid schema: %v", strings.Join(c.errs, "; "))
+	}
+	return &Schema{Types: b.types}, nil
+}
+
+// StructBuilder adds the fields of a struct type, see Builder.Struct.
+type StructBuilder struct {
+	m *Map
+}
+
+// Field adds a field of type t.
+func (b *StructBuilder) Field(name string, t TypeRef) *StructBuilder {
+	b.m.Fields = append(b.m.Fields, StructField{Name: name, Type: t})
+	return b
+}
+
+// FieldWithDefault adds a field of type t, with a default value.
+func (b *StructBuilder) FieldWithDefault(name string, t TypeRef, def interface{}) *StructBuilder {
+	b.m.Fields = append(b.m.Fields, StructField{Name: name, Type: t, Default: def})
+	return b
+}
+
+// Extra allows fields which aren't declared, of type t.
+func (b *StructBuilder) Extra(t TypeRef) *StructBuilder {
+	b.m.ElementType = t
+	return b
+}
+
+// Atomic makes the struct atomic: it is owned and replaced as a whole.
+func (b *StructBuilder) Atomic() *StructBuilder {
+	b.m.ElementRelationship = Atomic
+	return b
+}
+
+// Named returns a reference to the type named name.
+func Named(name string) TypeRef {
+	return TypeRef{NamedType: &name}
+}
+
+// ScalarType returns an inlined scalar type.
+func ScalarType(s Scalar) TypeRef {
+	return TypeRef{Inlined: Atom{Scalar: &s}}
+}
+
+// MapOf returns an inlined map type whose items are of type elem, and can
+// be owned separately.
+func MapOf(elem TypeRef) TypeRef {
+	return TypeRef{Inlined: Atom{Map: &Map{ElementType: elem}}}
+}
+
+// AtomicMapOf returns an inlined map type whose items are of type elem, and
+// is owned and replaced as a whole.
+func AtomicMapOf(elem TypeRef) TypeRef {
+	return TypeRef{Inlined: Atom{Map: &Map{ElementType: elem, ElementRelationship: Atomic}}}
+}
+
+// AtomicList returns an inlined list type whose items are of type elem, and
+// is owned and replaced as a whole.
+func AtomicList(elem TypeRef) TypeRef {
+	return TypeRef{Inlined: Atom{List: &List{ElementType: elem, ElementRelationship: Atomic}}}
+}
+
+// SetOf returns an inlined list type whose items are scalars of type elem,
+// which are unique and can be owned separately.
+func SetOf(elem TypeRef) TypeRef {
+	return TypeRef{Inlined: Atom{List: &List{ElementType: elem, ElementRelationship: Associative}}}
+}
+
+// AssociativeList returns an inlined list type whose items are maps of type
+// elem, identified by the fields key and keys, and can be owned separately.
+func AssociativeList(elem TypeRef, key string, keys ...string) TypeRef {
+	return TypeRef{Inlined: Atom{List: &List{
+		ElementType:         elem,
+		ElementRelationship: Associative,
+		Keys:                append([]string{key}, keys...),
+	}}}
+}
+
+// builderChecker checks the types built by a Builder.
+type builderChecker struct {
+	types map[string]*Atom
+	errs  []string
+}
+
+func (c *builderChecker) errorf(format string, args ...interface{}) {
+	c.errs = append(c.errs, fmt.Sprintf(format, args...))
+}
+
+// resolve returns the atom of tr, or nil if it references an undefined
+// type, which is reported.
+func (c *builderChecker) resolve(at string, tr TypeRef) *Atom {
+	if tr.NamedType == nil {
+		return &tr.Inlined
+	}
+	a, ok := c.types[*tr.NamedType]
+	if !ok {
+		c.errorf("%v: reference to undefined type %q", at, *tr.NamedType)
+		return nil
+	}
+	return a
+}
+
+// checkTypeRef checks tr, whose inlined types are checked recursively.
+func (c *builderChecker) checkTypeRef(at string, tr TypeRef) *Atom {
+	if tr.NamedType != nil {
+		return c.resolve(at, tr)
+	}
+	c.checkAtom(at, &tr.Inlined)
+	return &tr.Inlined
+}
+
+func (c *builderChecker) checkAtom(at string, a *Atom) {
+	if a.Scalar == nil && a.List == nil && a.Map == nil {
+		c.errorf("%v: type is empty", at)
+	}
+	if a.Map != nil {
+		c.checkMap(at, a.Map)
+	}
+	if a.List != nil {
+		c.checkList(at, a.List)
+	}
+}
+
+func (c *builderChecker) checkMap(at string, m *Map) {
+	fields := map[string]bool{}
+	for _, f := range m.Fields {
+		if fields[f.Name] {
+			c.errorf("%v: field %q is declared more than once", at, f.Name)
+			continue
+		}
+		fields[f.Name] = true
+		c.checkTypeRef(fmt.Sprintf("%v: field %q", at, f.Name), f.Type)
+	}
+	if m.ElementType != (TypeRef{}) {
+		c.checkTypeRef(at+": element type", m.ElementType)
+	}
+}
+
+func (c *builderChecker) checkList(at string, l *List) {
+	elem := c.checkTypeRef(at+": element type", l.ElementType)
+	switch l.ElementRelationship {
+	case Atomic:
+		if len(l.Keys) > 0 {
+			c.errorf("%v: only associative lists have keys", at)
+		}
+	case Associative:
+		if elem == nil || elem.Map == nil {
+			if len(l.Keys) > 0 {
+				c.errorf("%v: only associative lists of maps have keys", at)
+			}
+			return
+		}
+		if len(l.Keys) == 0 && elem.Scalar == nil {
+			c.errorf("%v: associative list of maps has no keys", at)
+		}
+		if len(elem.Map.Fields) == 0 || elem.Map.ElementType != (TypeRef{}) {
+			// Keys can be undeclared fields.
+			return
+		}
+		for _, key := range l.Keys {
+			if findField(elem.Map, key) == nil {
+				c.errorf("%v: key %q isn't a field of the items", at, key)
+			}
+		}
+	default:
+		c.errorf("%v: list has no element relationship", at)
+	}
+}
@@ -0,0 +1,191 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package schema
+
+import (
+	"strings"
+	"testing"
+
+	yaml "sigs.k8s.io/yaml/goyaml.v2"
+)
+
+func TestBuilder(t *testing.T) {
+	b := NewBuilder()
+	b.Struct("pod").
+		Field("name", ScalarType(String)).
+		Field("labels", MapOf(ScalarType(String))).
+		Field("containers", AssociativeList(Named("container"), "name")).
+		FieldWithDefault("replicas", ScalarType(Numeric), 1)
+	b.Struct("container").
+		Field("name", ScalarType(String)).
+		Field("args", AtomicList(ScalarType(String))).
+		Field("finalizers", SetOf(ScalarType(String))).
+		Field("resources", AtomicMapOf(ScalarType(NumericOrString)))
+	b.Struct("extensible").Extra(ScalarType(Untyped)).Atomic()
+	b.Type("names", AtomicList(ScalarType(String)))
+	s, err := b.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `types:
+- name: pod
+  map:
+    fields:
+    - name: name
+      type:
+        scalar: string
+    - name: labels
+      type:
+        map:
+          elementType:
+            scalar: string
+    - name: containers
+      type:
+        list:
+          elementType:
+            namedType: container
+          elementRelationship: associative
+          keys:
+          - name
+    - name: replicas
+      type:
+        scalar: numeric
+      default: 1
+- name: container
+  map:
+    fields:
+    - name: name
+      type:
+        scalar: string
+    - name: args
+      type:
+        list:
+          elementType:
+            scalar: string
+          elementRelationship: atomic
+    - name: finalizers
+      type:
+        list:
+          elementType:
+            scalar: string
+          elementRelationship: associative
+    - name: resources
+      type:
+        map:
+          elementType:
+            scalar: numericOrString
+          elementRelationship: atomic
+- name: extensible
+  map:
+    elementType:
+      scalar: untyped
+    elementRelationship: atomic
+- name: names
+  list:
+    elementType:
+      scalar: string
+    elementRelationship: atomic
+`
+	out, err := yaml.Marshal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != expected {
+		t.Errorf("expected:\n%v\ngot:\n%v", expected, string(out))
+	}
+	var parsed Schema
+	if err := yaml.Unmarshal([]byte(expected), &parsed); err != nil {
+		t.Fatal(err)
+	}
+	if !s.Equals(&parsed) {
+		t.Errorf("expected the built schema to equal the parsed one")
+	}
+}
+
+func TestBuilderErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		build func(b *Builder)
+		err   string
+	}{{
+		name: "undefined type",
+		build: func(b *Builder) {
+			b.Struct("a").Field("b", Named("b"))
+		},
+		err: `type "a": field "b": reference to undefined type "b"`,
+	}, {
+		name: "duplicate type",
+		build: func(b *Builder) {
+			b.Struct("a")
+			b.Type("a", ScalarType(String))
+		},
+		err: `type "a" is defined more than once`,
+	}, {
+		name: "alias",
+		build: func(b *Builder) {
+			b.Struct("a")
+			b.Type("b", Named("a"))
+		},
+		err: `type "b": must be inlined, not a reference to "a"`,
+	}, {
+		name: "duplicate field",
+		build: func(b *Builder) {
+			b.Struct("a").Field("x", ScalarType(String)).Field("x", ScalarType(Numeric))
+		},
+		err: `type "a": field "x" is declared more than once`,
+	}, {
+		name: "unknown key",
+		build: func(b *Builder) {
+			b.Struct("a").Field("items", AssociativeList(Named("item"), "id"))
+			b.Struct("item").Field("name", ScalarType(String))
+		},
+		err: `type "a": field "items": key "id" isn't a field of the items`,
+	}, {
+		name: "keys of scalars",
+		build: func(b *Builder) {
+			b.Type("a", AssociativeList(ScalarType(String), "id"))
+		},
+		err: `type "a": only associative lists of maps have keys`,
+	}, {
+		name: "empty type",
+		build: func(b *Builder) {
+			b.Struct("a").Field("x", TypeRef{})
+		},
+		err: `type "a": field "x": type is empty`,
+	}, {
+		name: "several errors",
+		build: func(b *Builder) {
+			b.Struct("a").Field("x", Named("x")).Field("y", Named("y"))
+		},
+		err: `type "a": field "x": reference to undefined type "x"; type "a": field "y": reference to undefined type "y"`,
+	}}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewBuilder()
+			tt.build(b)
+			_, err := b.Build()
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("expected error %q, got %q", tt.err, err)
+			}
+		})
+	}
+}
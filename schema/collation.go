@@ -0,0 +1,92 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package schema
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"golang.org/x/text/cases"
+)
+
+// Collation names a way of comparing strings, by folding them to a
+// canonical form which is then compared byte-wise. The empty Collation
+// compares strings as they are.
+type Collation string
+
+// CaseInsensitive compares strings regardless of their case, by Unicode
+// case folding: e.g. "ß" and "SS", or the Kelvin sign and "k", are equal.
+const CaseInsensitive = Collation("caseInsensitive")
+
+// caseFold folds the case of s. Casers are stateful, so each call uses
+// its own.
+func caseFold(s string) string {
+	return cases.Fold().String(s)
+}
+
+var (
+	// collationsLock serializes the registrations, which replace the
+	// registry, so that reading it takes no lock.
+	collationsLock sync.Mutex
+	// collations holds a map[Collation]func(string) string, never modified
+	// once stored.
+	collations atomic.Value
+)
+
+func init() {
+	collations.Store(map[Collation]func(string) string{
+		CaseInsensitive: caseFold,
+	})
+}
+
+// RegisterCollation registers fold as the folding function of c, e.g. a
+// unicode normalization, replacing any previous one. fold must be
+// idempotent, and is typically registered at init time.
+func RegisterCollation(c Collation, fold func(string) string) {
+	collationsLock.Lock()
+	defer collationsLock.Unlock()
+	old := collations.Load().(map[Collation]func(string) string)
+	registry := make(map[Collation]func(string) string, len(old)+1)
+	for k, v := range old {
+		registry[k] = v
+	}
+	registry[c] = fold
+	collations.Store(registry)
+}
+
+// Folder returns the folding function of c, so that folding many strings
+// resolves it once. It returns false if c isn't registered.
+func (c Collation) Folder() (func(string) string, bool) {
+	if c == "" {
+		return func(s string) string { return s }, true
+	}
+	fold, ok := collations.Load().(map[Collation]func(string) string)[c]
+	return fold, ok
+}
+
+// Fold returns the canonical form of s for c. It returns false if c isn't
+// registered.
+func (c Collation) Fold(s string) (string, bool) {
+	if c == "" {
+		return s, true
+	}
+	fold, ok := c.Folder()
+	if !ok {
+		return "", false
+	}
+	return fold(s), true
+}
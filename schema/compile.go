@@ -0,0 +1,80 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package schema
+
+import "sync/atomic"
+
+// Compile builds all the indexes of the schema ahead of time: the named
+// types, the fields of every map, and the type references which override the
+// element relationship of the type they refer to. Lookups in a compiled
+// schema never build anything nor take a lock, and copies of it made with
+// CopyInto share its indexes.
+//
+// The schema must not be modified afterwards. Compiling a schema more than
+// once has no effect.
+func (s *Schema) Compile() {
+	s.compileOnce.Do(func() {
+		s.FindNamedType("")
+		resolved := map[TypeRef]Atom{}
+		for i := range s.Types {
+			s.compileAtom(&s.Types[i].Atom, resolved)
+		}
+		s.compiled = resolved
+		atomic.StoreUint32(&s.isCompiled, 1)
+	})
+}
+
+// IsCompiled returns true if Compile was called on the schema or on the
+// schema it was copied from.
+func (s *Schema) IsCompiled() bool {
+	return atomic.LoadUint32(&s.isCompiled) == 1
+}
+
+func (s *Schema) compileAtom(a *Atom, resolved map[TypeRef]Atom) {
+	if a.Map != nil {
+		a.Map.FindField("")
+		for i := range a.Map.Fields {
+			s.compileTypeRef(a.Map.Fields[i].Type, resolved)
+		}
+		s.compileTypeRef(a.Map.ElementType, resolved)
+	}
+	if a.List != nil {
+		s.compileTypeRef(a.List.ElementType, resolved)
+		for i := range a.List.ElementVariants {
+			s.compileTypeRef(a.List.ElementVariants[i].Type, resolved)
+		}
+	}
+}
+
+// compileTypeRef walks the inlined types, and resolves the named types whose
+// element relationship is overridden. Named types are otherwise walked on
+// their own.
+func (s *Schema) compileTypeRef(tr TypeRef, resolved map[TypeRef]Atom) {
+	if tr.NamedType == nil {
+		s.compileAtom(&tr.Inlined, resolved)
+		return
+	}
+	if tr.ElementRelationship == nil {
+		return
+	}
+	if a, ok := s.Resolve(tr); ok {
+		if a.Map != nil {
+			a.Map.FindField("")
+		}
+		resolved[tr] = a
+	}
+}
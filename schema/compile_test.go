@@ -0,0 +1,72 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package schema
+
+import (
+	"sync/atomic"
+	"testing"
+)
+
+func TestCompile(t *testing.T) {
+	list, atomicER, numeric := "list", Atomic, Numeric
+	override := TypeRef{NamedType: &list, ElementRelationship: &atomicER}
+	s := Schema{Types: []TypeDef{{
+		Name: "type",
+		Atom: Atom{Map: &Map{Fields: []StructField{
+			{Name: "atomicList", Type: override},
+			{Name: "inline", Type: TypeRef{Inlined: Atom{Map: &Map{
+				Fields: []StructField{{Name: "a", Type: TypeRef{NamedType: &list}}},
+			}}}},
+		}}},
+	}, {
+		Name: "list",
+		Atom: Atom{List: &List{ElementType: TypeRef{Inlined: Atom{Scalar: &numeric}}, ElementRelationship: Associative}},
+	}}}
+
+	if s.IsCompiled() {
+		t.Fatal("expected the schema not to be compiled")
+	}
+	s.Compile()
+	s.Compile()
+	if !s.IsCompiled() {
+		t.Fatal("expected the schema to be compiled")
+	}
+
+	m := s.Types[0].Atom.Map
+	if atomic.LoadUint32(&m.indexed) != 1 {
+		t.Errorf("expected the fields of named types to be indexed")
+	}
+	if atomic.LoadUint32(&m.Fields[1].Type.Inlined.Map.indexed) != 1 {
+		t.Errorf("expected the fields of inlined types to be indexed")
+	}
+	if _, ok := s.compiled[override]; !ok {
+		t.Errorf("expected overridden type references to be resolved")
+	}
+
+	var dst Schema
+	s.CopyInto(&dst)
+	if !dst.IsCompiled() {
+		t.Fatal("expected the copy to be compiled")
+	}
+	a, ok := dst.Resolve(override)
+	if !ok || a.List == nil || a.List.ElementRelationship != Atomic {
+		t.Errorf("expected the overridden list to be atomic, got %v", a)
+	}
+	if dst.resolvedTypes != nil {
+		t.Errorf("expected the copy to resolve type references from the compiled index")
+	}
+}
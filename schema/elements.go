@@ -18,22 +18,35 @@ package schema
 
 import (
 	"sync"
+	"sync/atomic"
 )
 
 // Schema is a list of named types.
 //
 // Schema types are indexed in a map before the first search so this type
-// should be considered immutable.
+// should be considered immutable. Once constructed, a Schema is safe for
+// concurrent use: its indexes are built exactly once, and resolved type
+// references are cached under a lock.
 type Schema struct {
 	Types []TypeDef `yaml:"types,omitempty"`
 
 	once sync.Once
 	m    map[string]TypeDef
+	// indexed is set once m is fully built.
+	indexed uint32
+	// nullPolicies is true if a field sets a NullPolicy, it's set with m.
+	nullPolicies bool
 
 	lock sync.Mutex
 	// Cached results of resolving type references to atoms. Only stores
 	// type references which require fields of Atom to be overriden.
 	resolvedTypes map[TypeRef]Atom
+
+	compileOnce sync.Once
+	// compiled holds the type references resolved by Compile, it's
+	// read-only once isCompiled is set.
+	compiled   map[TypeRef]Atom
+	isCompiled uint32
 }
 
 // A TypeSpecifier references a particular type in a schema.
@@ -66,12 +79,27 @@ type TypeRef struct {
 // Atom represents the smallest possible pieces of the type system.
 // Each set field in the Atom represents a possible type for the object.
 // If none of the fields are set, any object will fail validation against the atom.
+//
+// An atom with several fields set is a dual type, e.g. a scalar or a map:
+// values of any of these shapes are valid, and are handled as the type of
+// their shape. A value which changes shape is modified as a whole.
 type Atom struct {
 	*Scalar `yaml:"scalar,omitempty"`
 	*List   `yaml:"list,omitempty"`
 	*Map    `yaml:"map,omitempty"`
+
+	// Enum restricts a scalar to a set of allowed values, see Enum. It is
+	// ignored for lists and maps.
+	Enum *Enum `yaml:"enum,omitempty"`
 }
 
+// Enum is the set of values allowed for a scalar. Values are compared like
+// value.Equals does, so an int matches an equal float. A null value is always
+// allowed, since it means the field isn't set.
+//
+// It's a pointer in Atom so that atoms remain comparable.
+type Enum []interface{}
+
 // Scalar (AKA "primitive") represents a type which has a single value which is
 // either numeric, string, or boolean, or untyped for any of them.
 //
@@ -83,6 +111,9 @@ const (
 	String  = Scalar("string")
 	Boolean = Scalar("boolean")
 	Untyped = Scalar("untyped")
+	// NumericOrString accepts numbers and strings, like the
+	// x-kubernetes-int-or-string fields of Kubernetes.
+	NumericOrString = Scalar("numericOrString")
 )
 
 // ElementRelationship is an enum of the different possible relationships
@@ -100,6 +131,20 @@ const (
 	Separable = ElementRelationship("separable")
 )
 
+// NullPolicy defines what an explicit null means for a field.
+type NullPolicy string
+
+const (
+	// NullIsValue keeps explicit nulls like any other value (default
+	// behavior).
+	NullIsValue = NullPolicy("value")
+	// NullDeletes makes an explicit null equivalent to an absent field,
+	// which deletes the field when it's merged.
+	NullDeletes = NullPolicy("delete")
+	// NullIsInvalid rejects explicit nulls.
+	NullIsInvalid = NullPolicy("invalid")
+)
+
 // Map is a key-value pair. Its default semantics are the same as an
 // associative list, but:
 //   - It is serialized differently:
@@ -129,6 +174,16 @@ type Map struct {
 	// overlap between unions.
 	Unions []Union `yaml:"unions,omitempty"`
 
+	// Dependencies lists the fields which can only be set along with
+	// other fields. They are enforced by validation.
+	Dependencies []FieldDependency `yaml:"dependencies,omitempty"`
+
+	// ExclusiveGroups lists groups of fields of which at most one, or
+	// exactly one if the group is required, can be set. Unlike unions,
+	// they don't change how objects are merged; they are only enforced
+	// by validation.
+	ExclusiveGroups []ExclusiveGroup `yaml:"exclusiveGroups,omitempty"`
+
 	// ElementType is the type of the structs's unknown fields.
 	ElementType TypeRef `yaml:"elementType,omitempty"`
 
@@ -138,13 +193,16 @@ type Map struct {
 	//   is effectively a scalar / leaf field; it doesn't make sense for
 	//   separate actors to set the elements. Example: an RGB color struct;
 	//   it would never make sense to "own" only one component of the
-	//   color.
+	//   color. Atomic maps are owned as a whole, and replaced as a whole
+	//   when merged.
 	// The default behavior for maps is `separable`; it's permitted to
 	// leave this unset to get the default behavior.
 	ElementRelationship ElementRelationship `yaml:"elementRelationship,omitempty"`
 
 	once sync.Once
 	m    map[string]StructField
+	// indexed is set once m is fully built.
+	indexed uint32
 }
 
 // FindField is a convenience function that returns the referenced StructField,
@@ -155,6 +213,7 @@ func (m *Map) FindField(name string) (StructField, bool) {
 		for _, field := range m.Fields {
 			m.m[field.Name] = field
 		}
+		atomic.StoreUint32(&m.indexed, 1)
 	})
 	sf, ok := m.m[name]
 	return sf, ok
@@ -163,7 +222,8 @@ func (m *Map) FindField(name string) (StructField, bool) {
 // CopyInto this instance of Map into the other
 // If other is nil this method does nothing.
 // If other is already initialized, overwrites it with this instance
-// Warning: Not thread safe
+// It is safe to call concurrently with other readers of m, but dst must not
+// be in use.
 func (m *Map) CopyInto(dst *Map) {
 	if dst == nil {
 		return
@@ -173,14 +233,19 @@ func (m *Map) CopyInto(dst *Map) {
 	dst.Fields = m.Fields
 	dst.ElementType = m.ElementType
 	dst.Unions = m.Unions
+	dst.Dependencies = m.Dependencies
+	dst.ExclusiveGroups = m.ExclusiveGroups
 	dst.ElementRelationship = m.ElementRelationship
 
-	if m.m != nil {
+	// The index may be being built by another goroutine, it can only be
+	// shared once it's complete.
+	if atomic.LoadUint32(&m.indexed) == 1 {
 		// If cache is non-nil then the once token had been consumed.
 		// Must reset token and use it again to ensure same semantics.
 		dst.once = sync.Once{}
 		dst.once.Do(func() {
 			dst.m = m.m
+			atomic.StoreUint32(&dst.indexed, 1)
 		})
 	}
 }
@@ -229,6 +294,24 @@ type Union struct {
 	Fields []UnionField `yaml:"fields,omitempty"`
 }
 
+// FieldDependency states that if Field is set, i.e. present and not null,
+// all the fields of Requires must be set too.
+type FieldDependency struct {
+	// Field is the name of the dependent field.
+	Field string `yaml:"field"`
+	// Requires are the names of the fields that Field requires.
+	Requires []string `yaml:"requires,omitempty"`
+}
+
+// ExclusiveGroup is a group of mutually exclusive fields: at most one of
+// them can be set, i.e. present and not null.
+type ExclusiveGroup struct {
+	// Fields are the names of the fields of the group.
+	Fields []string `yaml:"fields,omitempty"`
+	// Required, if true, means that one of the fields must be set.
+	Required bool `yaml:"required,omitempty"`
+}
+
 // StructField pairs a field name with a field type.
 type StructField struct {
 	// Name is the field name.
@@ -237,6 +320,17 @@ type StructField struct {
 	Type TypeRef `yaml:"type,omitempty"`
 	// Default value for the field, nil if not present.
 	Default interface{} `yaml:"default,omitempty"`
+	// Subresource, if set, is the name of the subresource (e.g. "status")
+	// through which the field is written, rather than the main resource.
+	// See merge.Updater.ApplySubresource.
+	Subresource string `yaml:"subresource,omitempty"`
+	// NullPolicy, if set, overrides the policy for explicit nulls of the
+	// field. See typed.WithNullPolicy.
+	NullPolicy NullPolicy `yaml:"nullPolicy,omitempty"`
+	// Volatile fields are maintained by servers, like timestamps and
+	// generations, and are never compared nor owned. See
+	// typed.TypedValue.VolatileFields.
+	Volatile bool `yaml:"volatile,omitempty"`
 }
 
 // List represents a type which contains a zero or more elements, all of the
@@ -268,19 +362,115 @@ type List struct {
 	//
 	// Each key must refer to a single field name (no nesting, not JSONPath).
 	Keys []string `yaml:"keys,omitempty"`
+
+	// KeyCollations changes how the string values of some of the Keys are
+	// compared, e.g. case-insensitively for APIs which treat names so. The
+	// values are folded by their collation wherever items are identified,
+	// so merging, comparing and field sets all agree, and field sets hold
+	// the folded values. Other keys are compared byte-wise.
+	KeyCollations []KeyCollation `yaml:"keyCollations,omitempty"`
+
+	// Discriminator, if set, makes the list heterogeneous: the type of
+	// each map element is selected by the string value of its
+	// Discriminator field among the ElementVariants, and elements with
+	// another or no value have the ElementType. In associative lists with
+	// keys, the discriminator is part of the key of the elements, so
+	// elements of different types are never merged together.
+	Discriminator string `yaml:"discriminator,omitempty"`
+
+	// ElementVariants are the types of the elements of a heterogeneous
+	// list, see Discriminator.
+	ElementVariants []ElementVariant `yaml:"elementVariants,omitempty"`
+}
+
+// ElementVariant is the type of the elements of a heterogeneous list
+// whose discriminator has a given value.
+type ElementVariant struct {
+	// DiscriminatorValue is the value of the discriminator of the
+	// elements of this type.
+	DiscriminatorValue string `yaml:"discriminatorValue"`
+	// Type is the type of the elements.
+	Type TypeRef `yaml:"type"`
+}
+
+// ElementTypeFor returns the type of the elements whose discriminator is
+// discriminatorValue, and false if no variant has that value, in which
+// case the ElementType is returned.
+func (l *List) ElementTypeFor(discriminatorValue string) (TypeRef, bool) {
+	for _, v := range l.ElementVariants {
+		if v.DiscriminatorValue == discriminatorValue {
+			return v.Type, true
+		}
+	}
+	return l.ElementType, false
+}
+
+// KeyCollation sets the Collation of a key of an associative list.
+type KeyCollation struct {
+	// Key is the name of the key field.
+	Key string `yaml:"key"`
+	// Collation is the name of the collation, see Collation.
+	Collation Collation `yaml:"collation"`
+}
+
+// KeyCollation returns the collation of key, or "" if it is compared
+// byte-wise.
+func (l *List) KeyCollation(key string) Collation {
+	for _, kc := range l.KeyCollations {
+		if kc.Key == key {
+			return kc.Collation
+		}
+	}
+	return ""
 }
 
 // FindNamedType is a convenience function that returns the referenced TypeDef,
 // if it exists, or (nil, false) if it doesn't.
 func (s *Schema) FindNamedType(name string) (TypeDef, bool) {
+	s.index()
+	t, ok := s.m[name]
+	return t, ok
+}
+
+// HasNullPolicies returns true if a field of the schema sets a NullPolicy.
+func (s *Schema) HasNullPolicies() bool {
+	s.index()
+	return s.nullPolicies
+}
+
+func (s *Schema) index() {
 	s.once.Do(func() {
 		s.m = make(map[string]TypeDef, len(s.Types))
 		for _, t := range s.Types {
 			s.m[t.Name] = t
+			s.nullPolicies = s.nullPolicies || atomHasNullPolicies(t.Atom)
 		}
+		atomic.StoreUint32(&s.indexed, 1)
 	})
-	t, ok := s.m[name]
-	return t, ok
+}
+
+// atomHasNullPolicies returns true if a field of a or of its inlined types
+// sets a NullPolicy.
+func atomHasNullPolicies(a Atom) bool {
+	if a.List != nil {
+		if atomHasNullPolicies(a.List.ElementType.Inlined) {
+			return true
+		}
+		for _, v := range a.List.ElementVariants {
+			if atomHasNullPolicies(v.Type.Inlined) {
+				return true
+			}
+		}
+	}
+	if a.Map == nil {
+		return false
+	}
+	for _, f := range a.Map.Fields {
+		if f.NullPolicy != "" || atomHasNullPolicies(f.Type.Inlined) {
+			return true
+		}
+	}
+	return atomHasNullPolicies(a.Map.ElementType.Inlined)
 }
 
 func (s *Schema) resolveNoOverrides(tr TypeRef) (Atom, bool) {
@@ -311,6 +501,12 @@ func (s *Schema) Resolve(tr TypeRef) (Atom, bool) {
 		return s.resolveNoOverrides(tr)
 	}
 
+	if atomic.LoadUint32(&s.isCompiled) == 1 {
+		if result, ok := s.compiled[tr]; ok {
+			return result, true
+		}
+	}
+
 	s.lock.Lock()
 	defer s.lock.Unlock()
 
@@ -355,7 +551,8 @@ func (s *Schema) Resolve(tr TypeRef) (Atom, bool) {
 // Clones this instance of Schema into the other
 // If other is nil this method does nothing.
 // If other is already initialized, overwrites it with this instance
-// Warning: Not thread safe
+// It is safe to call concurrently with other readers of s, but dst must not
+// be in use.
 func (s *Schema) CopyInto(dst *Schema) {
 	if dst == nil {
 		return
@@ -364,12 +561,23 @@ func (s *Schema) CopyInto(dst *Schema) {
 	// Schema type is considered immutable so sharing references
 	dst.Types = s.Types
 
-	if s.m != nil {
+	// The index may be being built by another goroutine, it can only be
+	// shared once it's complete.
+	if atomic.LoadUint32(&s.indexed) == 1 {
 		// If cache is non-nil then the once token had been consumed.
 		// Must reset token and use it again to ensure same semantics.
 		dst.once = sync.Once{}
 		dst.once.Do(func() {
 			dst.m = s.m
+			dst.nullPolicies = s.nullPolicies
+			atomic.StoreUint32(&dst.indexed, 1)
+		})
+	}
+	if s.IsCompiled() {
+		dst.compileOnce = sync.Once{}
+		dst.compileOnce.Do(func() {
+			dst.compiled = s.compiled
+			atomic.StoreUint32(&dst.isCompiled, 1)
 		})
 	}
 }
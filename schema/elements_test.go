@@ -18,6 +18,7 @@ package schema
 
 import (
 	"reflect"
+	"sync"
 	"testing"
 )
 
@@ -177,3 +178,37 @@ func TestCopyInto(t *testing.T) {
 		})
 	}
 }
+
+// TestConcurrentUse resolves and copies a shared schema from several
+// goroutines. It's most useful when run with -race.
+func TestConcurrentUse(t *testing.T) {
+	atomic := ElementRelationship("atomic")
+	s := Schema{
+		Types: []TypeDef{
+			{Name: "a", Atom: Atom{Map: &Map{Fields: []StructField{
+				{Name: "b", Type: TypeRef{NamedType: strptr("a")}},
+			}}}},
+		},
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			a, ok := s.Resolve(TypeRef{NamedType: strptr("a"), ElementRelationship: &atomic})
+			if !ok {
+				t.Errorf("failed to resolve type a")
+				return
+			}
+			if _, ok := a.Map.FindField("b"); !ok {
+				t.Errorf("failed to find field b")
+			}
+			var theCopy Schema
+			s.CopyInto(&theCopy)
+			if _, ok := theCopy.FindNamedType("a"); !ok {
+				t.Errorf("failed to find type a in copy")
+			}
+		}()
+	}
+	wg.Wait()
+}
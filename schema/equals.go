@@ -83,17 +83,30 @@ func (a *Atom) Equals(b *Atom) bool {
 	if (a.Map == nil) != (b.Map == nil) {
 		return false
 	}
-	switch {
-	case a.Scalar != nil:
-		return *a.Scalar == *b.Scalar
-	case a.List != nil:
-		return a.List.Equals(b.List)
-	case a.Map != nil:
-		return a.Map.Equals(b.Map)
+	if !a.Enum.Equals(b.Enum) {
+		return false
+	}
+	// Dual types set several of them, which must all be equal.
+	if a.Scalar != nil && *a.Scalar != *b.Scalar {
+		return false
+	}
+	if a.List != nil && !a.List.Equals(b.List) {
+		return false
+	}
+	if a.Map != nil && !a.Map.Equals(b.Map) {
+		return false
 	}
 	return true
 }
 
+// Equals returns true iff the two Enums are equal.
+func (a *Enum) Equals(b *Enum) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return reflect.DeepEqual(*a, *b)
+}
+
 // Equals returns true iff the two Maps are equal.
 func (a *Map) Equals(b *Map) bool {
 	if a == nil || b == nil {
@@ -121,6 +134,50 @@ func (a *Map) Equals(b *Map) bool {
 			return false
 		}
 	}
+	if len(a.Dependencies) != len(b.Dependencies) {
+		return false
+	}
+	for i := range a.Dependencies {
+		if !a.Dependencies[i].Equals(&b.Dependencies[i]) {
+			return false
+		}
+	}
+	if len(a.ExclusiveGroups) != len(b.ExclusiveGroups) {
+		return false
+	}
+	for i := range a.ExclusiveGroups {
+		if !a.ExclusiveGroups[i].Equals(&b.ExclusiveGroups[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// Equals returns true iff the two FieldDependencies are equal.
+func (a *FieldDependency) Equals(b *FieldDependency) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return a.Field == b.Field && stringsEqual(a.Requires, b.Requires)
+}
+
+// Equals returns true iff the two ExclusiveGroups are equal.
+func (a *ExclusiveGroup) Equals(b *ExclusiveGroup) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return a.Required == b.Required && stringsEqual(a.Fields, b.Fields)
+}
+
+func stringsEqual(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
 	return true
 }
 
@@ -176,6 +233,15 @@ func (a *StructField) Equals(b *StructField) bool {
 	if !reflect.DeepEqual(a.Default, b.Default) {
 		return false
 	}
+	if a.Subresource != b.Subresource {
+		return false
+	}
+	if a.NullPolicy != b.NullPolicy {
+		return false
+	}
+	if a.Volatile != b.Volatile {
+		return false
+	}
 	return a.Type.Equals(&b.Type)
 }
 
@@ -198,5 +264,27 @@ func (a *List) Equals(b *List) bool {
 			return false
 		}
 	}
+	if len(a.KeyCollations) != len(b.KeyCollations) {
+		return false
+	}
+	for i := range a.KeyCollations {
+		if a.KeyCollations[i] != b.KeyCollations[i] {
+			return false
+		}
+	}
+	if a.Discriminator != b.Discriminator {
+		return false
+	}
+	if len(a.ElementVariants) != len(b.ElementVariants) {
+		return false
+	}
+	for i := range a.ElementVariants {
+		if a.ElementVariants[i].DiscriminatorValue != b.ElementVariants[i].DiscriminatorValue {
+			return false
+		}
+		if !a.ElementVariants[i].Type.Equals(&b.ElementVariants[i].Type) {
+			return false
+		}
+	}
 	return true
 }
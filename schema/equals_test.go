@@ -33,7 +33,7 @@ func fuzzInterface(i *interface{}, c fuzz.Continue) {
 
 func (*Schema) Generate(rand *rand.Rand, size int) reflect.Value {
 	s := Schema{}
-	f := fuzz.New().RandSource(rand).MaxDepth(4)
+	f := fuzz.New().RandSource(rand).MaxDepth(4).Funcs(fuzzInterface)
 	f.Fuzz(&s)
 	return reflect.ValueOf(&s)
 }
@@ -47,14 +47,14 @@ func (*Map) Generate(rand *rand.Rand, size int) reflect.Value {
 
 func (TypeDef) Generate(rand *rand.Rand, size int) reflect.Value {
 	td := TypeDef{}
-	f := fuzz.New().RandSource(rand).MaxDepth(4)
+	f := fuzz.New().RandSource(rand).MaxDepth(4).Funcs(fuzzInterface)
 	f.Fuzz(&td)
 	return reflect.ValueOf(td)
 }
 
 func (Atom) Generate(rand *rand.Rand, size int) reflect.Value {
 	a := Atom{}
-	f := fuzz.New().RandSource(rand).MaxDepth(4)
+	f := fuzz.New().RandSource(rand).MaxDepth(4).Funcs(fuzzInterface)
 	f.Fuzz(&a)
 	return reflect.ValueOf(a)
 }
@@ -107,6 +107,7 @@ func TestEquals(t *testing.T) {
 			y.Scalar = x.Scalar
 			y.List = x.List
 			y.Map = x.Map
+			y.Enum = x.Enum
 			return x.Equals(&y) == reflect.DeepEqual(x, y)
 		},
 		func(x *Map) bool {
@@ -118,8 +119,28 @@ func TestEquals(t *testing.T) {
 			y.ElementRelationship = x.ElementRelationship
 			y.Fields = x.Fields
 			y.Unions = x.Unions
+			y.Dependencies = x.Dependencies
+			y.ExclusiveGroups = x.ExclusiveGroups
 			return x.Equals(&y) == reflect.DeepEqual(x, &y)
 		},
+		func(x FieldDependency) bool {
+			if !x.Equals(&x) {
+				return false
+			}
+			var y FieldDependency
+			y.Field = x.Field
+			y.Requires = x.Requires
+			return x.Equals(&y) == reflect.DeepEqual(x, y)
+		},
+		func(x ExclusiveGroup) bool {
+			if !x.Equals(&x) {
+				return false
+			}
+			var y ExclusiveGroup
+			y.Fields = x.Fields
+			y.Required = x.Required
+			return x.Equals(&y) == reflect.DeepEqual(x, y)
+		},
 		func(x Union) bool {
 			if !x.Equals(&x) {
 				return false
@@ -147,6 +168,9 @@ func TestEquals(t *testing.T) {
 			y.Name = x.Name
 			y.Type = x.Type
 			y.Default = x.Default
+			y.Subresource = x.Subresource
+			y.NullPolicy = x.NullPolicy
+			y.Volatile = x.Volatile
 			return x.Equals(&y) == reflect.DeepEqual(x, y)
 		},
 		func(x List) bool {
@@ -157,6 +181,9 @@ func TestEquals(t *testing.T) {
 			y.ElementType = x.ElementType
 			y.ElementRelationship = x.ElementRelationship
 			y.Keys = x.Keys
+			y.KeyCollations = x.KeyCollations
+			y.Discriminator = x.Discriminator
+			y.ElementVariants = x.ElementVariants
 			return x.Equals(&y) == reflect.DeepEqual(x, y)
 		},
 	}
@@ -166,3 +193,19 @@ func TestEquals(t *testing.T) {
 		}
 	}
 }
+
+func TestAtomEqualsDualTypes(t *testing.T) {
+	str := String
+	dual := func(elementType Scalar) *Atom {
+		return &Atom{
+			Scalar: &str,
+			Map:    &Map{ElementType: TypeRef{Inlined: Atom{Scalar: &elementType}}},
+		}
+	}
+	if !dual(String).Equals(dual(String)) {
+		t.Error("expected equal dual types to be equal")
+	}
+	if dual(String).Equals(dual(Numeric)) {
+		t.Error("expected dual types with different maps under the same scalar to differ")
+	}
+}
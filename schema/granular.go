@@ -0,0 +1,90 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package schema
+
+import "strings"
+
+// MakeMapsGranular makes separable the maps found by following fields, a
+// list of field names, from any named type of the schema, so that the
+// managers of these maps own their keys one by one even if the maps are
+// atomic. It returns the location of the maps (see InvalidSets), e.g.
+// "io.k8s.api.apps.v1.Deployment.metadata.labels". It modifies the schema,
+// and so must be called before the schema is used.
+//
+// The relationship is overridden by the field that holds the map, so if that
+// field belongs to a named type, like the labels of ObjectMeta, the map is
+// made separable wherever the named type is used.
+func (s *Schema) MakeMapsGranular(fields []string) []string {
+	if len(fields) == 0 {
+		return nil
+	}
+	named := make(map[string]*Atom, len(s.Types))
+	for i := range s.Types {
+		named[s.Types[i].Name] = &s.Types[i].Atom
+	}
+	var out []string
+	for i := range s.Types {
+		a := &s.Types[i].Atom
+		for _, name := range fields[:len(fields)-1] {
+			if a = fieldAtom(named, a, name); a == nil {
+				break
+			}
+		}
+		if a == nil || a.Map == nil {
+			continue
+		}
+		f := findField(a.Map, fields[len(fields)-1])
+		if f == nil {
+			continue
+		}
+		if m, ok := s.resolveNoOverrides(f.Type); !ok || m.Map == nil {
+			continue
+		}
+		// A new pointer, since Resolve caches the types by TypeRef.
+		separable := Separable
+		f.Type.ElementRelationship = &separable
+		out = append(out, s.Types[i].Name+"."+strings.Join(fields, "."))
+	}
+	return out
+}
+
+// fieldAtom returns the type of the field of the map a, or nil if a isn't a
+// map with such a field. The atoms of named types are looked up in named.
+func fieldAtom(named map[string]*Atom, a *Atom, name string) *Atom {
+	if a.Map == nil {
+		return nil
+	}
+	f := findField(a.Map, name)
+	if f == nil {
+		return nil
+	}
+	if f.Type.NamedType == nil {
+		return &f.Type.Inlined
+	}
+	return named[*f.Type.NamedType]
+}
+
+// findField returns the field of m, which can be modified, without building
+// the index of its fields.
+func findField(m *Map, name string) *StructField {
+	for i := range m.Fields {
+		if m.Fields[i].Name == name {
+			return &m.Fields[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package schema
+
+import (
+	"reflect"
+	"testing"
+
+	yaml "sigs.k8s.io/yaml/goyaml.v2"
+)
+
+func TestMakeMapsGranular(t *testing.T) {
+	var s Schema
+	if err := yaml.Unmarshal([]byte(`types:
+- name: deployment
+  map:
+    fields:
+    - name: metadata
+      type:
+        namedType: objectMeta
+- name: pod
+  map:
+    fields:
+    - name: metadata
+      type:
+        map:
+          fields:
+          - name: labels
+            type:
+              map:
+                elementType:
+                  scalar: string
+                elementRelationship: atomic
+- name: objectMeta
+  map:
+    fields:
+    - name: name
+      type:
+        scalar: string
+    - name: labels
+      type:
+        namedType: stringMap
+- name: stringMap
+  map:
+    elementType:
+      scalar: string
+    elementRelationship: atomic
+`), &s); err != nil {
+		t.Fatal(err)
+	}
+
+	got := s.MakeMapsGranular([]string{"metadata", "labels"})
+	want := []string{"deployment.metadata.labels", "pod.metadata.labels"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+	if got := s.MakeMapsGranular([]string{"metadata", "name"}); len(got) != 0 {
+		t.Errorf("expected scalars to be left alone, got %v", got)
+	}
+
+	for _, name := range []string{"deployment", "pod"} {
+		root, _ := s.FindNamedType(name)
+		metadata, _ := s.Resolve(root.Atom.Map.Fields[0].Type)
+		labels, ok := metadata.Map.FindField("labels")
+		if !ok {
+			t.Fatalf("%v: missing labels", name)
+		}
+		a, ok := s.Resolve(labels.Type)
+		if !ok || a.Map == nil || a.Map.ElementRelationship != Separable {
+			t.Errorf("%v: expected separable labels, got %+v", name, a.Map)
+		}
+	}
+	if named, _ := s.FindNamedType("stringMap"); named.Atom.Map.ElementRelationship != Atomic {
+		t.Errorf("expected the named type to be left alone")
+	}
+}
@@ -0,0 +1,307 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package schema
+
+import (
+	"errors"
+	"sort"
+
+	"sigs.k8s.io/structured-merge-diff/v4/value"
+)
+
+// InferredTypeName is the name of the type of the samples in a schema
+// returned by Infer.
+const InferredTypeName = "inferred"
+
+// untypedAtomicName is the name of the type used by Infer for values that
+// are sometimes maps, lists or scalars. It is compatible with the deduced
+// types of the typed package.
+const untypedAtomicName = "__untyped_atomic_"
+
+// Infer proposes a schema for the samples, which should all be objects of
+// the same type. It is meant to bootstrap the schema of APIs which don't
+// define one, and should be reviewed before being used:
+//   - maps are given a field for each key found in the samples;
+//   - scalars are typed after the values found in the samples;
+//   - lists of maps are associative if some fields (one, or else two) are
+//     set in every item and unique within each list, and atomic otherwise.
+//     The "name" field is preferred, then the first one in alphabetical
+//     order;
+//   - lists of scalars are atomic, since the order of their items usually
+//     matters;
+//   - values which are of different kinds (e.g. a map in one sample, and a
+//     string in another), or only ever null, are untyped and atomic.
+//
+// The type of the samples is named InferredTypeName.
+func Infer(samples []value.Value) (*Schema, error) {
+	if len(samples) == 0 {
+		return nil, errors.New("no samples to infer a schema from")
+	}
+	root := &shape{}
+	for _, sample := range samples {
+		root.observe(sample)
+	}
+	b := inferenceBuilder{}
+	s := &Schema{Types: []TypeDef{{Name: InferredTypeName}}}
+	if ref := b.typeRef(root); ref.NamedType != nil {
+		s.Types[0].Atom = untypedAtomic()
+	} else {
+		s.Types[0].Atom = ref.Inlined
+	}
+	if b.usesUntypedAtomic {
+		s.Types = append(s.Types, TypeDef{Name: untypedAtomicName, Atom: untypedAtomic()})
+	}
+	return s, nil
+}
+
+// shape accumulates what the samples look like at a given path.
+type shape struct {
+	maps, lists bool
+	scalars     map[Scalar]bool
+
+	// fields are the shapes of the fields of maps.
+	fields map[string]*shape
+	// elements is the shape of the items of lists.
+	elements *shape
+
+	// keys tracks the candidate keys of lists of maps. keysObserved is set
+	// once a non-empty list was seen.
+	keysObserved bool
+	singleKeys   map[string]bool
+	pairKeys     map[[2]string]bool
+}
+
+func (s *shape) observe(v value.Value) {
+	switch {
+	case v == nil || v.IsNull():
+	case v.IsMap():
+		s.maps = true
+		if s.fields == nil {
+			s.fields = map[string]*shape{}
+		}
+		v.AsMap().Iterate(func(key string, fv value.Value) bool {
+			f, ok := s.fields[key]
+			if !ok {
+				f = &shape{}
+				s.fields[key] = f
+			}
+			f.observe(fv)
+			return true
+		})
+	case v.IsList():
+		s.lists = true
+		if s.elements == nil {
+			s.elements = &shape{}
+		}
+		l := v.AsList()
+		for i := 0; i < l.Length(); i++ {
+			s.elements.observe(l.At(i))
+		}
+		s.observeKeys(l)
+	default:
+		if s.scalars == nil {
+			s.scalars = map[Scalar]bool{}
+		}
+		switch {
+		case v.IsString():
+			s.scalars[String] = true
+		case v.IsBool():
+			s.scalars[Boolean] = true
+		case v.IsInt(), v.IsFloat():
+			s.scalars[Numeric] = true
+		default:
+			s.scalars[Untyped] = true
+		}
+	}
+}
+
+// observeKeys narrows the candidate keys of the list down to the ones that
+// identify the items of l.
+func (s *shape) observeKeys(l value.List) {
+	if l.Length() == 0 {
+		return
+	}
+	// Candidates are the scalar fields set in every item.
+	var candidates []string
+	for i := 0; i < l.Length(); i++ {
+		item := l.At(i)
+		if !item.IsMap() {
+			candidates = nil
+			break
+		}
+		var fields []string
+		item.AsMap().Iterate(func(key string, fv value.Value) bool {
+			if !fv.IsNull() && !fv.IsMap() && !fv.IsList() && (i == 0 || contains(candidates, key)) {
+				fields = append(fields, key)
+			}
+			return true
+		})
+		candidates = fields
+	}
+	sort.Strings(candidates)
+
+	singles := map[string]bool{}
+	for _, f := range candidates {
+		if uniqueKeys(l, f) {
+			singles[f] = true
+		}
+	}
+	pairs := map[[2]string]bool{}
+	for i, f := range candidates {
+		for _, g := range candidates[i+1:] {
+			if uniqueKeys(l, f, g) {
+				pairs[[2]string{f, g}] = true
+			}
+		}
+	}
+
+	if !s.keysObserved {
+		s.keysObserved = true
+		s.singleKeys = singles
+		s.pairKeys = pairs
+		return
+	}
+	for f := range s.singleKeys {
+		if !singles[f] {
+			delete(s.singleKeys, f)
+		}
+	}
+	for p := range s.pairKeys {
+		if !pairs[p] {
+			delete(s.pairKeys, p)
+		}
+	}
+}
+
+// uniqueKeys returns true if the values of the fields are unique among the
+// items of l, which are maps that all have the fields.
+func uniqueKeys(l value.List, fields ...string) bool {
+	seen := map[string]bool{}
+	for i := 0; i < l.Length(); i++ {
+		m := l.At(i).AsMap()
+		key := ""
+		for _, f := range fields {
+			fv, _ := m.Get(f)
+			key += value.ToString(fv) + "\x00"
+		}
+		if seen[key] {
+			return false
+		}
+		seen[key] = true
+	}
+	return true
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// keys returns the keys chosen for the list, if any.
+func (s *shape) keys() []string {
+	if len(s.singleKeys) > 0 {
+		if s.singleKeys["name"] {
+			return []string{"name"}
+		}
+		var singles []string
+		for f := range s.singleKeys {
+			singles = append(singles, f)
+		}
+		sort.Strings(singles)
+		return singles[:1]
+	}
+	var pairs [][2]string
+	for p := range s.pairKeys {
+		pairs = append(pairs, p)
+	}
+	if len(pairs) == 0 {
+		return nil
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i][0] != pairs[j][0] {
+			return pairs[i][0] < pairs[j][0]
+		}
+		return pairs[i][1] < pairs[j][1]
+	})
+	return pairs[0][:]
+}
+
+type inferenceBuilder struct {
+	usesUntypedAtomic bool
+}
+
+func (b *inferenceBuilder) untyped() TypeRef {
+	b.usesUntypedAtomic = true
+	name := untypedAtomicName
+	return TypeRef{NamedType: &name}
+}
+
+func (b *inferenceBuilder) typeRef(s *shape) TypeRef {
+	kinds := 0
+	for _, ok := range []bool{s.maps, s.lists, len(s.scalars) > 0} {
+		if ok {
+			kinds++
+		}
+	}
+	if kinds != 1 {
+		return b.untyped()
+	}
+	switch {
+	case s.maps:
+		m := &Map{}
+		names := make([]string, 0, len(s.fields))
+		for name := range s.fields {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			m.Fields = append(m.Fields, StructField{Name: name, Type: b.typeRef(s.fields[name])})
+		}
+		return TypeRef{Inlined: Atom{Map: m}}
+	case s.lists:
+		l := &List{ElementType: b.typeRef(s.elements), ElementRelationship: Atomic}
+		if s.elements.maps && l.ElementType.NamedType == nil {
+			if keys := s.keys(); len(keys) > 0 {
+				l.ElementRelationship = Associative
+				l.Keys = keys
+			}
+		}
+		return TypeRef{Inlined: Atom{List: l}}
+	default:
+		scalar := Untyped
+		if len(s.scalars) == 1 {
+			for k := range s.scalars {
+				scalar = k
+			}
+		}
+		return TypeRef{Inlined: Atom{Scalar: &scalar}}
+	}
+}
+
+func untypedAtomic() Atom {
+	name := untypedAtomicName
+	untyped := Untyped
+	return Atom{
+		Scalar: &untyped,
+		List:   &List{ElementType: TypeRef{NamedType: &name}, ElementRelationship: Atomic},
+		Map:    &Map{ElementType: TypeRef{NamedType: &name}, ElementRelationship: Atomic},
+	}
+}
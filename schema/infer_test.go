@@ -0,0 +1,207 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package schema
+
+import (
+	"testing"
+
+	"sigs.k8s.io/structured-merge-diff/v4/value"
+	yaml "sigs.k8s.io/yaml/goyaml.v2"
+)
+
+func TestInfer(t *testing.T) {
+	tests := []struct {
+		name     string
+		samples  []string
+		expected string
+	}{{
+		name: "fields",
+		samples: []string{
+			`{"name": "a", "replicas": 1, "paused": true, "spec": {"image": "x"}}`,
+			`{"name": "b", "replicas": 1.5, "spec": {"args": ["p", "q"]}, "extra": null}`,
+		},
+		expected: `types:
+- name: inferred
+  map:
+    fields:
+    - name: extra
+      type:
+        namedType: __untyped_atomic_
+    - name: name
+      type:
+        scalar: string
+    - name: paused
+      type:
+        scalar: boolean
+    - name: replicas
+      type:
+        scalar: numeric
+    - name: spec
+      type:
+        map:
+          fields:
+          - name: args
+            type:
+              list:
+                elementType:
+                  scalar: string
+                elementRelationship: atomic
+          - name: image
+            type:
+              scalar: string
+- name: __untyped_atomic_
+  scalar: untyped
+  list:
+    elementType:
+      namedType: __untyped_atomic_
+    elementRelationship: atomic
+  map:
+    elementType:
+      namedType: __untyped_atomic_
+    elementRelationship: atomic
+`,
+	}, {
+		name: "list keys",
+		samples: []string{
+			`{"byName": [{"name": "a", "id": 1}, {"name": "b", "id": 2}],
+			  "byID": [{"id": 1, "value": "a"}, {"id": 2, "value": "a"}],
+			  "byPair": [{"port": 80, "protocol": "TCP"}, {"port": 80, "protocol": "UDP"}],
+			  "noKey": [{"value": "a"}]}`,
+			`{"byName": [{"name": "a", "id": 1}, {"name": "b", "id": 1}],
+			  "byID": [{"id": 1}],
+			  "byPair": [{"port": 80, "protocol": "TCP"}, {"port": 443, "protocol": "TCP"}],
+			  "noKey": [{"value": "a"}, {"value": "a"}]}`,
+		},
+		expected: `types:
+- name: inferred
+  map:
+    fields:
+    - name: byID
+      type:
+        list:
+          elementType:
+            map:
+              fields:
+              - name: id
+                type:
+                  scalar: numeric
+              - name: value
+                type:
+                  scalar: string
+          elementRelationship: associative
+          keys:
+          - id
+    - name: byName
+      type:
+        list:
+          elementType:
+            map:
+              fields:
+              - name: id
+                type:
+                  scalar: numeric
+              - name: name
+                type:
+                  scalar: string
+          elementRelationship: associative
+          keys:
+          - name
+    - name: byPair
+      type:
+        list:
+          elementType:
+            map:
+              fields:
+              - name: port
+                type:
+                  scalar: numeric
+              - name: protocol
+                type:
+                  scalar: string
+          elementRelationship: associative
+          keys:
+          - port
+          - protocol
+    - name: noKey
+      type:
+        list:
+          elementType:
+            map:
+              fields:
+              - name: value
+                type:
+                  scalar: string
+          elementRelationship: atomic
+`,
+	}, {
+		name: "mixed kinds",
+		samples: []string{
+			`{"a": "x", "b": 1}`,
+			`{"a": {"x": 1}, "b": "y"}`,
+		},
+		expected: `types:
+- name: inferred
+  map:
+    fields:
+    - name: a
+      type:
+        namedType: __untyped_atomic_
+    - name: b
+      type:
+        scalar: untyped
+- name: __untyped_atomic_
+  scalar: untyped
+  list:
+    elementType:
+      namedType: __untyped_atomic_
+    elementRelationship: atomic
+  map:
+    elementType:
+      namedType: __untyped_atomic_
+    elementRelationship: atomic
+`,
+	}}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			var samples []value.Value
+			for _, sample := range tt.samples {
+				v, err := value.FromJSON([]byte(sample))
+				if err != nil {
+					t.Fatal(err)
+				}
+				samples = append(samples, v)
+			}
+			s, err := Infer(samples)
+			if err != nil {
+				t.Fatal(err)
+			}
+			out, err := yaml.Marshal(s)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(out) != tt.expected {
+				t.Errorf("expected:\n%v\ngot:\n%v", tt.expected, string(out))
+			}
+		})
+	}
+
+	if _, err := Infer(nil); err == nil {
+		t.Error("expected an error without samples")
+	}
+}
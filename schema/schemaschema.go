@@ -48,6 +48,12 @@ var SchemaSchemaYAML = `types:
     - name: untyped
       type:
         namedType: untyped
+    - name: enum
+      type:
+        list:
+          elementType:
+            scalar: untyped
+          elementRelationship: atomic
 - name: typeRef
   map:
     fields:
@@ -66,6 +72,12 @@ var SchemaSchemaYAML = `types:
     - name: untyped
       type:
         namedType: untyped
+    - name: enum
+      type:
+        list:
+          elementType:
+            scalar: untyped
+          elementRelationship: atomic
     - name: elementRelationship
       type:
         scalar: string
@@ -87,12 +99,48 @@ var SchemaSchemaYAML = `types:
           elementType:
             namedType: union
           elementRelationship: atomic
+    - name: dependencies
+      type:
+        list:
+          elementType:
+            namedType: fieldDependency
+          elementRelationship: atomic
+    - name: exclusiveGroups
+      type:
+        list:
+          elementType:
+            namedType: exclusiveGroup
+          elementRelationship: atomic
     - name: elementType
       type:
         namedType: typeRef
     - name: elementRelationship
       type:
         scalar: string
+- name: fieldDependency
+  map:
+    fields:
+    - name: field
+      type:
+        scalar: string
+    - name: requires
+      type:
+        list:
+          elementType:
+            scalar: string
+          elementRelationship: atomic
+- name: exclusiveGroup
+  map:
+    fields:
+    - name: fields
+      type:
+        list:
+          elementType:
+            scalar: string
+          elementRelationship: atomic
+    - name: required
+      type:
+        scalar: boolean
 - name: unionField
   map:
     fields:
@@ -131,6 +179,15 @@ var SchemaSchemaYAML = `types:
     - name: default
       type:
         namedType: __untyped_atomic_
+    - name: subresource
+      type:
+        scalar: string
+    - name: nullPolicy
+      type:
+        scalar: string
+    - name: volatile
+      type:
+        scalar: boolean
 - name: list
   map:
     fields:
@@ -146,6 +203,43 @@ var SchemaSchemaYAML = `types:
           elementType:
             scalar: string
           elementRelationship: atomic
+    - name: keyCollations
+      type:
+        list:
+          elementType:
+            namedType: keyCollation
+          elementRelationship: associative
+          keys:
+          - key
+    - name: discriminator
+      type:
+        scalar: string
+    - name: elementVariants
+      type:
+        list:
+          elementType:
+            namedType: elementVariant
+          elementRelationship: associative
+          keys:
+          - discriminatorValue
+- name: elementVariant
+  map:
+    fields:
+    - name: discriminatorValue
+      type:
+        scalar: string
+    - name: type
+      type:
+        namedType: typeRef
+- name: keyCollation
+  map:
+    fields:
+    - name: key
+      type:
+        scalar: string
+    - name: collation
+      type:
+        scalar: string
 - name: untyped
   map:
     fields:
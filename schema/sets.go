@@ -0,0 +1,107 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package schema
+
+// InvalidSets returns the location of every associative list without keys
+// (i.e. a set) whose elements can't be scalars or atomic maps. Such lists
+// can't hold any value but empty ones, since sets can only contain scalars
+// and atomic maps, which are compared by deep equality.
+//
+// Locations start with the name of the type, followed by the names of the
+// fields (".name") and list elements ("[]") that lead to the list, e.g.
+// "io.k8s.api.core.v1.PodSpec.containers[].ports".
+func (s *Schema) InvalidSets() []string {
+	var out []string
+	s.walkSets(func(location string, _ func()) {
+		out = append(out, location)
+	})
+	return out
+}
+
+// MakeInvalidSetsAtomic makes the lists returned by InvalidSets atomic,
+// which is how they are usually meant to be merged, and returns their
+// location. It modifies the schema, and so must be called before the
+// schema is used.
+func (s *Schema) MakeInvalidSetsAtomic() []string {
+	var out []string
+	s.walkSets(func(location string, makeAtomic func()) {
+		makeAtomic()
+		out = append(out, location)
+	})
+	return out
+}
+
+// walkSets calls fn for every invalid set of the schema, with a function
+// that makes it atomic.
+func (s *Schema) walkSets(fn func(location string, makeAtomic func())) {
+	for i := range s.Types {
+		s.walkAtomSets(s.Types[i].Name, &s.Types[i].Atom, fn)
+	}
+}
+
+func (s *Schema) walkAtomSets(location string, a *Atom, fn func(location string, makeAtomic func())) {
+	if a.Map != nil {
+		for i := range a.Map.Fields {
+			f := &a.Map.Fields[i]
+			s.walkTypeRefSets(location+"."+f.Name, &f.Type, fn)
+		}
+		s.walkTypeRefSets(location+"[]", &a.Map.ElementType, fn)
+	}
+	if a.List != nil {
+		l := a.List
+		if l.ElementRelationship == Associative && s.isInvalidSet(l) {
+			fn(location, func() { l.ElementRelationship = Atomic })
+		}
+		s.walkTypeRefSets(location+"[]", &l.ElementType, fn)
+		for i := range l.ElementVariants {
+			v := &l.ElementVariants[i]
+			s.walkTypeRefSets(location+"["+v.DiscriminatorValue+"]", &v.Type, fn)
+		}
+	}
+}
+
+// walkTypeRefSets walks the inlined types, and the named lists made
+// associative by tr. Named types are otherwise walked on their own.
+func (s *Schema) walkTypeRefSets(location string, tr *TypeRef, fn func(location string, makeAtomic func())) {
+	if tr.NamedType == nil {
+		s.walkAtomSets(location, &tr.Inlined, fn)
+		return
+	}
+	if tr.ElementRelationship == nil || *tr.ElementRelationship != Associative {
+		return
+	}
+	if a, ok := s.resolveNoOverrides(*tr); ok && a.List != nil && s.isInvalidSet(a.List) {
+		fn(location, func() {
+			// A new pointer, since Resolve caches the types by TypeRef.
+			atomic := Atomic
+			tr.ElementRelationship = &atomic
+		})
+	}
+}
+
+// isInvalidSet returns true if l, which is associative, has no keys and
+// elements which can't be scalars or atomic maps.
+func (s *Schema) isInvalidSet(l *List) bool {
+	if len(l.Keys) > 0 {
+		return false
+	}
+	element, ok := s.Resolve(l.ElementType)
+	if !ok || element.Scalar != nil {
+		return false
+	}
+	return element.List != nil || (element.Map != nil && element.Map.ElementRelationship != Atomic)
+}
@@ -0,0 +1,124 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package schema
+
+import (
+	"reflect"
+	"testing"
+
+	yaml "sigs.k8s.io/yaml/goyaml.v2"
+)
+
+func TestInvalidSets(t *testing.T) {
+	var s Schema
+	if err := yaml.Unmarshal([]byte(`types:
+- name: root
+  map:
+    fields:
+    - name: scalars
+      type:
+        list:
+          elementType:
+            scalar: string
+          elementRelationship: associative
+    - name: keyed
+      type:
+        list:
+          elementType:
+            namedType: item
+          elementRelationship: associative
+          keys: [name]
+    - name: maps
+      type:
+        list:
+          elementType:
+            namedType: item
+          elementRelationship: associative
+    - name: atomicMaps
+      type:
+        list:
+          elementType:
+            namedType: atomicItem
+          elementRelationship: associative
+    - name: nested
+      type:
+        list:
+          elementType:
+            list:
+              elementType:
+                map:
+                  elementType:
+                    scalar: string
+              elementRelationship: associative
+          elementRelationship: atomic
+    - name: untyped
+      type:
+        list:
+          elementType:
+            namedType: __untyped_atomic_
+          elementRelationship: associative
+    - name: override
+      type:
+        namedType: items
+        elementRelationship: associative
+- name: item
+  map:
+    fields:
+    - name: name
+      type:
+        scalar: string
+- name: atomicItem
+  map:
+    fields:
+    - name: name
+      type:
+        scalar: string
+    elementRelationship: atomic
+- name: items
+  list:
+    elementType:
+      namedType: item
+    elementRelationship: atomic
+- name: __untyped_atomic_
+  scalar: untyped
+  list:
+    elementType:
+      namedType: __untyped_atomic_
+    elementRelationship: atomic
+`), &s); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"root.maps", "root.nested[]", "root.override"}
+	if got := s.InvalidSets(); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+	if got := s.MakeInvalidSetsAtomic(); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+	if got := s.InvalidSets(); len(got) != 0 {
+		t.Fatalf("expected no invalid sets, got %v", got)
+	}
+	root, _ := s.FindNamedType("root")
+	for _, name := range []string{"maps", "override"} {
+		f, _ := root.Map.FindField(name)
+		a, ok := s.Resolve(f.Type)
+		if !ok || a.List.ElementRelationship != Atomic {
+			t.Errorf("expected %v to be atomic, got %v", name, a.List.ElementRelationship)
+		}
+	}
+}
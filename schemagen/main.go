@@ -0,0 +1,74 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package main implements a command line tool generating the merge schema
+// of the Go types of a package, and the types of their field paths, meant
+// to be run by go generate:
+//
+//	//go:generate go run sigs.k8s.io/structured-merge-diff/v4/schemagen -types Widget -out zz_generated.schema.go -paths-out zz_generated.paths.go
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+
+	"sigs.k8s.io/structured-merge-diff/v4/internal/schemagen"
+)
+
+func main() {
+	dir := flag.String("dir", ".", "The directory of the package declaring the types.")
+	types := flag.String("types", "", "The comma separated names of the root types.")
+	pkg := flag.String("package", os.Getenv("GOPACKAGE"), "The package of the generated file, by default the one go generate runs in.")
+	name := flag.String("name", "SchemaYAML", "The name of the generated constant.")
+	out := flag.String("out", "zz_generated.schema.go", "The generated schema file, if any.")
+	pathsOut := flag.String("paths-out", "", "The generated file of the types of the field paths, if any.")
+	flag.Parse()
+
+	if *types == "" {
+		log.Fatalf("-types is required")
+	}
+	if *pkg == "" {
+		log.Fatalf("-package is required outside of go generate")
+	}
+
+	s, err := schemagen.Generate(*dir, strings.Split(*types, ","))
+	if err != nil {
+		log.Fatalf("Couldn't generate the schema: %v", err)
+	}
+	if *out != "" {
+		src, err := schemagen.WriteGo(*pkg, *name, s)
+		if err != nil {
+			log.Fatalf("Couldn't write the schema: %v", err)
+		}
+		write(*out, src)
+	}
+	if *pathsOut != "" {
+		src, err := schemagen.WritePaths(*pkg, s)
+		if err != nil {
+			log.Fatalf("Couldn't write the paths: %v", err)
+		}
+		write(*pathsOut, src)
+	}
+}
+
+func write(path string, src []byte) {
+	if err := ioutil.WriteFile(path, src, 0644); err != nil {
+		log.Fatalf("Couldn't write %v: %v", path, err)
+	}
+}
@@ -0,0 +1,26 @@
+module sigs.k8s.io/structured-merge-diff/v4/server/grpcserver
+
+go 1.19
+
+require (
+	google.golang.org/grpc v1.56.3
+	sigs.k8s.io/structured-merge-diff/v4 v4.0.0-00010101000000-000000000000
+)
+
+require (
+	github.com/golang/protobuf v1.5.3 // indirect
+	github.com/json-iterator/go v1.1.12 // indirect
+	github.com/modern-go/concurrent v0.0.0-20180306012644-bacd9c7ef1dd // indirect
+	github.com/modern-go/reflect2 v1.0.2 // indirect
+	golang.org/x/net v0.9.0 // indirect
+	golang.org/x/sys v0.7.0 // indirect
+	golang.org/x/text v0.9.0 // indirect
+	google.golang.org/genproto v0.0.0-20230410155749-daa745c078e1 // indirect
+	google.golang.org/protobuf v1.30.0 // indirect
+	sigs.k8s.io/yaml v1.4.0 // indirect
+)
+
+// No published version of structured-merge-diff has the server package yet,
+// so the requirement above is a placeholder which the replace resolves to
+// this checkout: this module only builds inside the repository.
+replace sigs.k8s.io/structured-merge-diff/v4 => ../..
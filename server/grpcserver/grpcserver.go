@@ -0,0 +1,306 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package grpcserver serves the operations of a server.Server as a gRPC
+// service, for the programs which prefer gRPC to JSON over HTTP. It is its
+// own module, so that the structured-merge-diff module doesn't depend on
+// gRPC.
+//
+// The service, ServiceName, has the methods:
+//
+//	RegisterSchema(RegisterSchemaRequest) returns (Empty)
+//	UnregisterSchema(SchemaName) returns (Empty)
+//	ListSchemas(Empty) returns (server.SchemasResponse)
+//	Merge(server.ObjectsRequest) returns (server.ObjectResponse)
+//	Compare(server.ObjectsRequest) returns (server.CompareResponse)
+//	Apply(server.ApplyRequest) returns (server.ObjectResponse)
+//	Update(server.UpdateRequest) returns (server.ObjectResponse)
+//
+// Its messages are the JSON messages of the HTTP service, with the
+// content-subtype "json" (i.e. the content-type "application/grpc+json"),
+// rather than protocol buffers: importing this package registers the
+// codec, and other languages pass a JSON serializer to their gRPC stubs.
+//
+// Errors have the gRPC code of their HTTP status, e.g. NotFound for unknown
+// schemas or Aborted for conflicts, and their server.ErrorResponse, with
+// the conflicts of applies, in the ErrorTrailer trailer.
+package grpcserver
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"sigs.k8s.io/structured-merge-diff/v4/server"
+)
+
+// ServiceName is the full name of the gRPC service.
+const ServiceName = "smd.v1.StructuredMergeDiff"
+
+// ErrorTrailer is the trailer holding the server.ErrorResponse, in JSON, of
+// the failed calls.
+const ErrorTrailer = "smd-error-bin"
+
+// RegisterSchemaRequest registers a schema under a name.
+type RegisterSchemaRequest struct {
+	Name string `json:"name"`
+	server.SchemaRequest
+}
+
+// SchemaName names a registered schema.
+type SchemaName struct {
+	Name string `json:"name"`
+}
+
+// Empty is the message of the calls which have no request or response.
+type Empty struct{}
+
+func init() {
+	encoding.RegisterCodec(codec{})
+}
+
+// codec encodes messages in JSON.
+type codec struct{}
+
+func (codec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (codec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+func (codec) Name() string {
+	return "json"
+}
+
+// NewServer returns a gRPC server serving s, whose messages are limited to
+// the body limit of s.
+func NewServer(s *server.Server, opts ...grpc.ServerOption) *grpc.Server {
+	if n := s.MaxBodyBytes(); n > 0 {
+		opts = append([]grpc.ServerOption{grpc.MaxRecvMsgSize(int(n))}, opts...)
+	}
+	g := grpc.NewServer(opts...)
+	Register(g, s)
+	return g
+}
+
+// Register registers the service serving s on r. The size of the messages
+// isn't limited by the body limit of s, but by the options of r.
+func Register(r grpc.ServiceRegistrar, s *server.Server) {
+	r.RegisterService(&serviceDesc, s)
+}
+
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: ServiceName,
+	HandlerType: (*interface{})(nil),
+	Methods: []grpc.MethodDesc{
+		unary("RegisterSchema", func() interface{} { return &RegisterSchemaRequest{} }, func(s *server.Server, req interface{}) (interface{}, error) {
+			r := req.(*RegisterSchemaRequest)
+			if r.Name == "" {
+				return nil, status.Error(codes.InvalidArgument, "missing name")
+			}
+			if err := s.RegisterSchema(r.Name, []byte(r.Schema), r.TypeName); err != nil {
+				return nil, status.Errorf(codes.InvalidArgument, "invalid schema: %v", err)
+			}
+			return &Empty{}, nil
+		}),
+		unary("UnregisterSchema", func() interface{} { return &SchemaName{} }, func(s *server.Server, req interface{}) (interface{}, error) {
+			if err := s.UnregisterSchema(req.(*SchemaName).Name); err != nil {
+				return nil, err
+			}
+			return &Empty{}, nil
+		}),
+		unary("ListSchemas", func() interface{} { return &Empty{} }, func(s *server.Server, req interface{}) (interface{}, error) {
+			return &server.SchemasResponse{Schemas: s.Schemas()}, nil
+		}),
+		unary("Merge", func() interface{} { return &server.ObjectsRequest{} }, func(s *server.Server, req interface{}) (interface{}, error) {
+			return s.Merge(req.(*server.ObjectsRequest))
+		}),
+		unary("Compare", func() interface{} { return &server.ObjectsRequest{} }, func(s *server.Server, req interface{}) (interface{}, error) {
+			return s.Compare(req.(*server.ObjectsRequest))
+		}),
+		unary("Apply", func() interface{} { return &server.ApplyRequest{} }, func(s *server.Server, req interface{}) (interface{}, error) {
+			return s.Apply(req.(*server.ApplyRequest))
+		}),
+		unary("Update", func() interface{} { return &server.UpdateRequest{} }, func(s *server.Server, req interface{}) (interface{}, error) {
+			return s.Update(req.(*server.UpdateRequest))
+		}),
+	},
+	Streams: []grpc.StreamDesc{},
+}
+
+// unary returns the method name, which decodes its requests in the
+// messages returned by newReq and calls call with them.
+func unary(name string, newReq func() interface{}, call func(s *server.Server, req interface{}) (interface{}, error)) grpc.MethodDesc {
+	fullMethod := "/" + ServiceName + "/" + name
+	return grpc.MethodDesc{
+		MethodName: name,
+		Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+			req := newReq()
+			if err := dec(req); err != nil {
+				return nil, err
+			}
+			handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+				resp, err := call(srv.(*server.Server), req)
+				if err != nil {
+					return nil, toStatus(ctx, err)
+				}
+				return resp, nil
+			}
+			if interceptor == nil {
+				return handler(ctx, req)
+			}
+			return interceptor(ctx, req, &grpc.UnaryServerInfo{Server: srv, FullMethod: fullMethod}, handler)
+		},
+	}
+}
+
+// toStatus returns err, an error of the operations of a server.Server, as
+// a gRPC status, and sets its ErrorTrailer.
+func toStatus(ctx context.Context, err error) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	if data, jsonErr := json.Marshal(server.NewErrorResponse(err)); jsonErr == nil {
+		grpc.SetTrailer(ctx, metadata.Pairs(ErrorTrailer, string(data)))
+	}
+	return status.Error(Code(server.ErrorStatus(err)), err.Error())
+}
+
+// Code returns the gRPC code of an HTTP status of the service.
+func Code(httpStatus int) codes.Code {
+	switch httpStatus {
+	case http.StatusOK:
+		return codes.OK
+	case http.StatusBadRequest:
+		return codes.InvalidArgument
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusMethodNotAllowed:
+		return codes.Unimplemented
+	case http.StatusConflict:
+		return codes.Aborted
+	case http.StatusRequestEntityTooLarge:
+		return codes.ResourceExhausted
+	}
+	return codes.Internal
+}
+
+// Client calls the service over a connection.
+type Client struct {
+	cc grpc.ClientConnInterface
+}
+
+// NewClient returns a Client calling the service over cc.
+func NewClient(cc grpc.ClientConnInterface) *Client {
+	return &Client{cc: cc}
+}
+
+// Error is the error of a failed call: a gRPC status and, if the service
+// sent it, the server.ErrorResponse with the conflicts of applies.
+type Error struct {
+	Status   *status.Status
+	Response *server.ErrorResponse
+}
+
+func (e *Error) Error() string {
+	return e.Status.Err().Error()
+}
+
+// GRPCStatus returns the status of e, for status.FromError and
+// status.Code.
+func (e *Error) GRPCStatus() *status.Status {
+	return e.Status
+}
+
+func (c *Client) invoke(ctx context.Context, method string, req, resp interface{}, opts []grpc.CallOption) error {
+	var trailer metadata.MD
+	opts = append([]grpc.CallOption{grpc.CallContentSubtype(codec{}.Name()), grpc.Trailer(&trailer)}, opts...)
+	err := c.cc.Invoke(ctx, "/"+ServiceName+"/"+method, req, resp, opts...)
+	if err == nil {
+		return nil
+	}
+	out := &Error{Status: status.Convert(err)}
+	if values := trailer.Get(ErrorTrailer); len(values) > 0 {
+		var errResp server.ErrorResponse
+		if json.Unmarshal([]byte(values[0]), &errResp) == nil {
+			out.Response = &errResp
+		}
+	}
+	return out
+}
+
+// RegisterSchema registers a schema.
+func (c *Client) RegisterSchema(ctx context.Context, req *RegisterSchemaRequest, opts ...grpc.CallOption) error {
+	return c.invoke(ctx, "RegisterSchema", req, &Empty{}, opts)
+}
+
+// UnregisterSchema unregisters a schema.
+func (c *Client) UnregisterSchema(ctx context.Context, name string, opts ...grpc.CallOption) error {
+	return c.invoke(ctx, "UnregisterSchema", &SchemaName{Name: name}, &Empty{}, opts)
+}
+
+// ListSchemas lists the registered schemas.
+func (c *Client) ListSchemas(ctx context.Context, opts ...grpc.CallOption) (*server.SchemasResponse, error) {
+	resp := &server.SchemasResponse{}
+	if err := c.invoke(ctx, "ListSchemas", &Empty{}, resp, opts); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// Merge merges req.RHS into req.LHS.
+func (c *Client) Merge(ctx context.Context, req *server.ObjectsRequest, opts ...grpc.CallOption) (*server.ObjectResponse, error) {
+	resp := &server.ObjectResponse{}
+	if err := c.invoke(ctx, "Merge", req, resp, opts); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// Compare compares req.LHS and req.RHS.
+func (c *Client) Compare(ctx context.Context, req *server.ObjectsRequest, opts ...grpc.CallOption) (*server.CompareResponse, error) {
+	resp := &server.CompareResponse{}
+	if err := c.invoke(ctx, "Compare", req, resp, opts); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// Apply applies a configuration.
+func (c *Client) Apply(ctx context.Context, req *server.ApplyRequest, opts ...grpc.CallOption) (*server.ObjectResponse, error) {
+	resp := &server.ObjectResponse{}
+	if err := c.invoke(ctx, "Apply", req, resp, opts); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// Update records an update.
+func (c *Client) Update(ctx context.Context, req *server.UpdateRequest, opts ...grpc.CallOption) (*server.ObjectResponse, error) {
+	resp := &server.ObjectResponse{}
+	if err := c.invoke(ctx, "Update", req, resp, opts); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
@@ -0,0 +1,163 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package grpcserver_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"sigs.k8s.io/structured-merge-diff/v4/server"
+	"sigs.k8s.io/structured-merge-diff/v4/server/grpcserver"
+)
+
+const schemaYAML = `types:
+- name: object
+  map:
+    fields:
+    - name: replicas
+      type:
+        scalar: numeric
+    - name: args
+      type:
+        list:
+          elementType:
+            scalar: string
+          elementRelationship: atomic
+`
+
+func start(t *testing.T, srv *server.Server) *grpcserver.Client {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	g := grpcserver.NewServer(srv)
+	go g.Serve(lis)
+	t.Cleanup(g.Stop)
+	cc, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { cc.Close() })
+	return grpcserver.NewClient(cc)
+}
+
+func equalJSON(t *testing.T, expected string, got json.RawMessage) {
+	t.Helper()
+	var e, g interface{}
+	if err := json.Unmarshal([]byte(expected), &e); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(got, &g); err != nil {
+		t.Fatalf("invalid JSON %q: %v", got, err)
+	}
+	if !reflect.DeepEqual(e, g) {
+		t.Errorf("expected %s, got %s", expected, got)
+	}
+}
+
+func TestSchemas(t *testing.T) {
+	ctx := context.Background()
+	c := start(t, server.NewServer())
+	if err := c.RegisterSchema(ctx, &grpcserver.RegisterSchemaRequest{Name: "object", SchemaRequest: server.SchemaRequest{Schema: schemaYAML}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.RegisterSchema(ctx, &grpcserver.RegisterSchemaRequest{Name: "invalid", SchemaRequest: server.SchemaRequest{Schema: "types: ["}}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected InvalidArgument for an invalid schema, got %v", err)
+	}
+	resp, err := c.ListSchemas(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(resp.Schemas, []string{"object"}) {
+		t.Errorf("unexpected schemas: %v", resp.Schemas)
+	}
+	if err := c.UnregisterSchema(ctx, "object"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.UnregisterSchema(ctx, "object"); status.Code(err) != codes.NotFound {
+		t.Errorf("expected NotFound for an unknown schema, got %v", err)
+	}
+}
+
+func TestOperations(t *testing.T) {
+	ctx := context.Background()
+	srv := server.NewServer()
+	if err := srv.RegisterSchema("object", []byte(schemaYAML), ""); err != nil {
+		t.Fatal(err)
+	}
+	c := start(t, srv)
+
+	merged, err := c.Merge(ctx, &server.ObjectsRequest{Schema: "object", LHS: json.RawMessage(`{"replicas": 1, "args": ["a"]}`), RHS: json.RawMessage(`{"replicas": 2}`)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	equalJSON(t, `{"replicas": 2, "args": ["a"]}`, merged.Object)
+
+	compared, err := c.Compare(ctx, &server.ObjectsRequest{Schema: "object", LHS: json.RawMessage(`{"replicas": 1}`), RHS: json.RawMessage(`{"replicas": 2}`)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	equalJSON(t, `{"f:replicas": {}}`, compared.Modified)
+
+	applied, err := c.Apply(ctx, &server.ApplyRequest{Schema: "object", Config: json.RawMessage(`{"replicas": 1}`), Manager: "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	equalJSON(t, `{"replicas": 1}`, applied.Object)
+
+	updated, err := c.Update(ctx, &server.UpdateRequest{Schema: "object", Live: applied.Object, Object: json.RawMessage(`{"replicas": 3}`), Manager: "b", ManagedFields: applied.ManagedFields})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = c.Apply(ctx, &server.ApplyRequest{Schema: "object", Live: updated.Object, Config: json.RawMessage(`{"replicas": 1}`), Manager: "a", ManagedFields: updated.ManagedFields})
+	if status.Code(err) != codes.Aborted {
+		t.Fatalf("expected Aborted for a conflict, got %v", err)
+	}
+	var callErr *grpcserver.Error
+	if !errors.As(err, &callErr) || callErr.Response == nil {
+		t.Fatalf("expected the error response, got %#v", err)
+	}
+	if expected := []server.Conflict{{Manager: "b", Path: ".replicas"}}; !reflect.DeepEqual(callErr.Response.Conflicts, expected) {
+		t.Errorf("expected conflicts %v, got %v", expected, callErr.Response.Conflicts)
+	}
+
+	if _, err := c.Merge(ctx, &server.ObjectsRequest{Schema: "unknown", LHS: json.RawMessage(`{}`), RHS: json.RawMessage(`{}`)}); status.Code(err) != codes.NotFound {
+		t.Errorf("expected NotFound for an unknown schema, got %v", err)
+	}
+}
+
+func TestMaxBodyBytes(t *testing.T) {
+	ctx := context.Background()
+	c := start(t, server.NewServer(server.WithMaxBodyBytes(64)))
+	big := `{"args": ["` + strings.Repeat("a", 128) + `"]}`
+	_, err := c.Merge(ctx, &server.ObjectsRequest{LHS: json.RawMessage(big), RHS: json.RawMessage(`{}`)})
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("expected ResourceExhausted for a large request, got %v", err)
+	}
+}
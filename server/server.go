@@ -0,0 +1,526 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package server exposes the operations of the simple package as a JSON
+// over HTTP service, so that programs which aren't written in Go can reuse
+// the exact semantics of this library instead of approximating them. The
+// operations are methods of Server too, which the server/grpcserver module
+// serves as a gRPC service.
+//
+// Objects are sent and returned as JSON, managed fields in the encoding of
+// fieldpath.EncodeManagedFields, and field sets in the FieldsV1 encoding.
+// The endpoints are:
+//
+//	PUT    /v1/schemas/{name}  register a schema (SchemaRequest)
+//	DELETE /v1/schemas/{name}  unregister a schema
+//	GET    /v1/schemas         list the registered schemas
+//	POST   /v1/merge           merge rhs into lhs (ObjectsRequest)
+//	POST   /v1/compare         compare lhs and rhs (ObjectsRequest)
+//	POST   /v1/apply           apply a configuration (ApplyRequest)
+//	POST   /v1/update          update an object (UpdateRequest)
+//
+// Operations use the registered schema named in the request, or deduce the
+// types from the objects if none is named (see simple.Deduced). Errors are
+// returned as an ErrorResponse, with the conflicts of applies.
+//
+// Request bodies larger than the limit of WithMaxBodyBytes are rejected
+// with 413, and objects whose untyped content exceeds the limits of
+// WithUntypedLimits with 400, so that a single request can't make the
+// server allocate without bound.
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
+	"sigs.k8s.io/structured-merge-diff/v4/merge"
+	"sigs.k8s.io/structured-merge-diff/v4/simple"
+	"sigs.k8s.io/structured-merge-diff/v4/typed"
+	"sigs.k8s.io/yaml"
+)
+
+// SchemaRequest registers a schema.
+type SchemaRequest struct {
+	// Schema is the schema, in YAML or JSON.
+	Schema string `json:"schema"`
+	// TypeName is the type of the objects, or the first type of the schema
+	// if empty.
+	TypeName string `json:"typeName,omitempty"`
+}
+
+// ObjectsRequest is a request to merge or compare two objects.
+type ObjectsRequest struct {
+	// Schema is the name of a registered schema, or empty to deduce the
+	// types from the objects.
+	Schema string          `json:"schema,omitempty"`
+	LHS    json.RawMessage `json:"lhs"`
+	RHS    json.RawMessage `json:"rhs"`
+}
+
+// ApplyRequest is a request to apply a configuration to an object, which
+// may be empty if the object doesn't exist yet.
+type ApplyRequest struct {
+	Schema        string          `json:"schema,omitempty"`
+	Live          json.RawMessage `json:"live,omitempty"`
+	Config        json.RawMessage `json:"config"`
+	Manager       string          `json:"manager"`
+	ManagedFields json.RawMessage `json:"managedFields,omitempty"`
+	Force         bool            `json:"force,omitempty"`
+}
+
+// UpdateRequest is a request to record an update of an object.
+type UpdateRequest struct {
+	Schema        string          `json:"schema,omitempty"`
+	Live          json.RawMessage `json:"live,omitempty"`
+	Object        json.RawMessage `json:"object"`
+	Manager       string          `json:"manager"`
+	ManagedFields json.RawMessage `json:"managedFields,omitempty"`
+}
+
+// ObjectResponse is the response of merges, applies and updates.
+type ObjectResponse struct {
+	Object        json.RawMessage `json:"object,omitempty"`
+	ManagedFields json.RawMessage `json:"managedFields,omitempty"`
+}
+
+// CompareResponse is the response of comparisons.
+type CompareResponse struct {
+	Added    json.RawMessage `json:"added"`
+	Modified json.RawMessage `json:"modified"`
+	Removed  json.RawMessage `json:"removed"`
+}
+
+// SchemasResponse lists the registered schemas.
+type SchemasResponse struct {
+	Schemas []string `json:"schemas"`
+}
+
+// ErrorResponse is returned when a request fails.
+type ErrorResponse struct {
+	Error string `json:"error"`
+	// Conflicts are the conflicts which failed an apply, if any.
+	Conflicts []Conflict `json:"conflicts,omitempty"`
+}
+
+// Conflict is a field of an applied configuration owned by another
+// manager.
+type Conflict struct {
+	Manager string `json:"manager"`
+	Path    string `json:"path"`
+}
+
+// DefaultMaxBodyBytes is the default limit of the size of request bodies.
+const DefaultMaxBodyBytes = 4 << 20
+
+// DefaultUntypedLimits are the default limits of the untyped content of the
+// objects of requests.
+var DefaultUntypedLimits = typed.UntypedLimits{MaxDepth: 64, MaxNodes: 100000}
+
+// Server serves the operations. It can be used concurrently.
+type Server struct {
+	lock    sync.RWMutex
+	schemas map[string]*simple.Schema
+
+	maxBodyBytes  int64
+	untypedLimits typed.UntypedLimits
+	deduced       *simple.Schema
+
+	mux *http.ServeMux
+}
+
+var _ http.Handler = &Server{}
+
+// Option configures a Server.
+type Option func(*Server)
+
+// WithMaxBodyBytes sets the limit of the size of request bodies, which is
+// DefaultMaxBodyBytes by default. Limits which aren't positive disable it.
+func WithMaxBodyBytes(n int64) Option {
+	return func(s *Server) {
+		s.maxBodyBytes = n
+	}
+}
+
+// WithUntypedLimits sets the limits of the untyped content of the objects
+// of requests, which are DefaultUntypedLimits by default. The objects of
+// deduced schemas are untyped as a whole.
+func WithUntypedLimits(limits typed.UntypedLimits) Option {
+	return func(s *Server) {
+		s.untypedLimits = limits
+	}
+}
+
+// NewServer returns a Server without any registered schema.
+func NewServer(opts ...Option) *Server {
+	s := &Server{
+		schemas:       map[string]*simple.Schema{},
+		maxBodyBytes:  DefaultMaxBodyBytes,
+		untypedLimits: DefaultUntypedLimits,
+		mux:           http.NewServeMux(),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.deduced = simple.Deduced().WithUntypedLimits(s.untypedLimits)
+	s.mux.HandleFunc("/v1/schemas", s.listSchemas)
+	s.mux.HandleFunc("/v1/schemas/", s.handleSchema)
+	s.mux.HandleFunc("/v1/merge", post(s.handleMerge))
+	s.mux.HandleFunc("/v1/compare", post(s.handleCompare))
+	s.mux.HandleFunc("/v1/apply", post(s.handleApply))
+	s.mux.HandleFunc("/v1/update", post(s.handleUpdate))
+	return s
+}
+
+// MaxBodyBytes returns the limit of the size of request bodies, see
+// WithMaxBodyBytes.
+func (s *Server) MaxBodyBytes() int64 {
+	return s.maxBodyBytes
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.maxBodyBytes > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, s.maxBodyBytes)
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+// RegisterSchema registers a schema under name, replacing any previous
+// schema of that name.
+func (s *Server) RegisterSchema(name string, schemaYAML []byte, typeName string) error {
+	schema, err := simple.NewSchema(schemaYAML, typeName)
+	if err != nil {
+		return err
+	}
+	schema = schema.WithUntypedLimits(s.untypedLimits)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.schemas[name] = schema
+	return nil
+}
+
+// UnregisterSchema unregisters the schema registered under name.
+func (s *Server) UnregisterSchema(name string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if _, ok := s.schemas[name]; !ok {
+		return &requestError{status: http.StatusNotFound, err: fmt.Errorf("unknown schema %q", name)}
+	}
+	delete(s.schemas, name)
+	return nil
+}
+
+// Schemas returns the names of the registered schemas, sorted.
+func (s *Server) Schemas() []string {
+	s.lock.RLock()
+	names := make([]string, 0, len(s.schemas))
+	for name := range s.schemas {
+		names = append(names, name)
+	}
+	s.lock.RUnlock()
+	sort.Strings(names)
+	return names
+}
+
+// requestError is an error caused by the request, rather than by the
+// operation.
+type requestError struct {
+	status int
+	err    error
+}
+
+func (e *requestError) Error() string {
+	return e.err.Error()
+}
+
+func badRequest(format string, args ...interface{}) error {
+	return &requestError{status: http.StatusBadRequest, err: fmt.Errorf(format, args...)}
+}
+
+func (s *Server) schema(name string) (*simple.Schema, error) {
+	if name == "" {
+		return s.deduced, nil
+	}
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	schema, ok := s.schemas[name]
+	if !ok {
+		return nil, &requestError{status: http.StatusNotFound, err: fmt.Errorf("unknown schema %q", name)}
+	}
+	return schema, nil
+}
+
+func (s *Server) listSchemas(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, &requestError{status: http.StatusMethodNotAllowed, err: fmt.Errorf("method %v not allowed", r.Method)})
+		return
+	}
+	writeJSON(w, http.StatusOK, SchemasResponse{Schemas: s.Schemas()})
+}
+
+func (s *Server) handleSchema(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/v1/schemas/")
+	if name == "" || strings.Contains(name, "/") {
+		writeError(w, &requestError{status: http.StatusNotFound, err: fmt.Errorf("invalid schema name %q", name)})
+		return
+	}
+	switch r.Method {
+	case http.MethodPut:
+		var req SchemaRequest
+		if err := s.decode(r, &req); err != nil {
+			writeError(w, err)
+			return
+		}
+		if err := s.RegisterSchema(name, []byte(req.Schema), req.TypeName); err != nil {
+			writeError(w, badRequest("invalid schema: %v", err))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodDelete:
+		if err := s.UnregisterSchema(name); err != nil {
+			writeError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, &requestError{status: http.StatusMethodNotAllowed, err: fmt.Errorf("method %v not allowed", r.Method)})
+	}
+}
+
+// post returns a handler which only accepts POST requests, and writes the
+// response returned by fn.
+func post(fn func(r *http.Request) (interface{}, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeError(w, &requestError{status: http.StatusMethodNotAllowed, err: fmt.Errorf("method %v not allowed", r.Method)})
+			return
+		}
+		resp, err := fn(r)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+// decode decodes the body of r into req. Bodies larger than the limit
+// fail with 413.
+func (s *Server) decode(r *http.Request, req interface{}) error {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		if s.maxBodyBytes > 0 && int64(len(body)) >= s.maxBodyBytes {
+			return &requestError{status: http.StatusRequestEntityTooLarge, err: fmt.Errorf("request body exceeds %d bytes", s.maxBodyBytes)}
+		}
+		return badRequest("invalid request: %v", err)
+	}
+	if err := json.Unmarshal(body, req); err != nil {
+		return badRequest("invalid request: %v", err)
+	}
+	return nil
+}
+
+func (s *Server) handleMerge(r *http.Request) (interface{}, error) {
+	var req ObjectsRequest
+	if err := s.decode(r, &req); err != nil {
+		return nil, err
+	}
+	return s.Merge(&req)
+}
+
+func (s *Server) handleCompare(r *http.Request) (interface{}, error) {
+	var req ObjectsRequest
+	if err := s.decode(r, &req); err != nil {
+		return nil, err
+	}
+	return s.Compare(&req)
+}
+
+func (s *Server) handleApply(r *http.Request) (interface{}, error) {
+	var req ApplyRequest
+	if err := s.decode(r, &req); err != nil {
+		return nil, err
+	}
+	return s.Apply(&req)
+}
+
+func (s *Server) handleUpdate(r *http.Request) (interface{}, error) {
+	var req UpdateRequest
+	if err := s.decode(r, &req); err != nil {
+		return nil, err
+	}
+	return s.Update(&req)
+}
+
+// Merge merges req.RHS into req.LHS.
+func (s *Server) Merge(req *ObjectsRequest) (*ObjectResponse, error) {
+	schema, err := s.schema(req.Schema)
+	if err != nil {
+		return nil, err
+	}
+	out, err := simple.MergeYAML(schema, req.LHS, req.RHS)
+	if err != nil {
+		return nil, badRequest("%v", err)
+	}
+	object, err := yaml.YAMLToJSON(out)
+	if err != nil {
+		return nil, err
+	}
+	return &ObjectResponse{Object: object}, nil
+}
+
+// Compare compares req.LHS and req.RHS.
+func (s *Server) Compare(req *ObjectsRequest) (*CompareResponse, error) {
+	schema, err := s.schema(req.Schema)
+	if err != nil {
+		return nil, err
+	}
+	c, err := simple.DiffYAML(schema, req.LHS, req.RHS)
+	if err != nil {
+		return nil, badRequest("%v", err)
+	}
+	var resp CompareResponse
+	if resp.Added, err = c.Added.ToJSON(); err != nil {
+		return nil, err
+	}
+	if resp.Modified, err = c.Modified.ToJSON(); err != nil {
+		return nil, err
+	}
+	if resp.Removed, err = c.Removed.ToJSON(); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+func decodeManagedFields(data json.RawMessage) (fieldpath.ManagedFields, error) {
+	if len(data) == 0 {
+		return fieldpath.ManagedFields{}, nil
+	}
+	managed, err := fieldpath.DecodeManagedFields(data)
+	if err != nil {
+		return nil, badRequest("%v", err)
+	}
+	return managed, nil
+}
+
+// Apply applies req.Config to req.Live. It fails with merge.Conflicts if
+// the configuration conflicts with the fields of other managers.
+func (s *Server) Apply(req *ApplyRequest) (*ObjectResponse, error) {
+	if req.Manager == "" {
+		return nil, badRequest("missing manager")
+	}
+	schema, err := s.schema(req.Schema)
+	if err != nil {
+		return nil, err
+	}
+	managed, err := decodeManagedFields(req.ManagedFields)
+	if err != nil {
+		return nil, err
+	}
+	out, managed, err := simple.ApplyYAML(schema, req.Live, req.Config, req.Manager, managed, req.Force)
+	if err != nil {
+		var conflicts merge.Conflicts
+		if errors.As(err, &conflicts) {
+			return nil, err
+		}
+		return nil, badRequest("%v", err)
+	}
+	return objectResponse(out, managed)
+}
+
+// Update records the update of req.Live to req.Object by req.Manager.
+func (s *Server) Update(req *UpdateRequest) (*ObjectResponse, error) {
+	if req.Manager == "" {
+		return nil, badRequest("missing manager")
+	}
+	schema, err := s.schema(req.Schema)
+	if err != nil {
+		return nil, err
+	}
+	managed, err := decodeManagedFields(req.ManagedFields)
+	if err != nil {
+		return nil, err
+	}
+	managed, err = simple.UpdateYAML(schema, req.Live, req.Object, req.Manager, managed)
+	if err != nil {
+		return nil, badRequest("%v", err)
+	}
+	resp, err := objectResponse(nil, managed)
+	if err != nil {
+		return nil, err
+	}
+	resp.Object = req.Object
+	return resp, nil
+}
+
+func objectResponse(objectYAML []byte, managed fieldpath.ManagedFields) (*ObjectResponse, error) {
+	resp := &ObjectResponse{}
+	var err error
+	if objectYAML != nil {
+		if resp.Object, err = yaml.YAMLToJSON(objectYAML); err != nil {
+			return nil, err
+		}
+	}
+	if resp.ManagedFields, err = fieldpath.EncodeManagedFields(managed); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// ErrorStatus returns the HTTP status of err, an error of the operations of
+// a Server: 4xx if the request is at fault, e.g. 404 for unknown schemas or
+// 409 for conflicts, and 500 otherwise.
+func ErrorStatus(err error) int {
+	var reqErr *requestError
+	var conflicts merge.Conflicts
+	switch {
+	case errors.As(err, &reqErr):
+		return reqErr.status
+	case errors.As(err, &conflicts):
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+// NewErrorResponse returns the ErrorResponse of err, an error of the
+// operations of a Server.
+func NewErrorResponse(err error) ErrorResponse {
+	resp := ErrorResponse{Error: err.Error()}
+	var conflicts merge.Conflicts
+	if errors.As(err, &conflicts) {
+		for _, c := range conflicts {
+			resp.Conflicts = append(resp.Conflicts, Conflict{Manager: c.Manager, Path: c.Path.String()})
+		}
+	}
+	return resp
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	writeJSON(w, ErrorStatus(err), NewErrorResponse(err))
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,203 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"sigs.k8s.io/structured-merge-diff/v4/server"
+	"sigs.k8s.io/structured-merge-diff/v4/typed"
+)
+
+const schemaYAML = `types:
+- name: object
+  map:
+    fields:
+    - name: replicas
+      type:
+        scalar: numeric
+    - name: args
+      type:
+        list:
+          elementType:
+            scalar: string
+          elementRelationship: atomic
+`
+
+func do(t *testing.T, srv http.Handler, method, path string, req interface{}, resp interface{}) int {
+	t.Helper()
+	var body bytes.Buffer
+	if req != nil {
+		if err := json.NewEncoder(&body).Encode(req); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(method, path, &body))
+	if resp != nil && rec.Body.Len() > 0 {
+		if err := json.Unmarshal(rec.Body.Bytes(), resp); err != nil {
+			t.Fatalf("invalid response %q: %v", rec.Body.String(), err)
+		}
+	}
+	return rec.Code
+}
+
+func equalJSON(t *testing.T, expected string, got json.RawMessage) {
+	t.Helper()
+	var e, g interface{}
+	if err := json.Unmarshal([]byte(expected), &e); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(got, &g); err != nil {
+		t.Fatalf("invalid JSON %q: %v", got, err)
+	}
+	if !reflect.DeepEqual(e, g) {
+		t.Errorf("expected %s, got %s", expected, got)
+	}
+}
+
+func TestSchemas(t *testing.T) {
+	srv := server.NewServer()
+	if code := do(t, srv, http.MethodPut, "/v1/schemas/obj", server.SchemaRequest{Schema: schemaYAML}, nil); code != http.StatusNoContent {
+		t.Fatalf("expected %v, got %v", http.StatusNoContent, code)
+	}
+	var errResp server.ErrorResponse
+	if code := do(t, srv, http.MethodPut, "/v1/schemas/bad", server.SchemaRequest{Schema: "types: 1"}, &errResp); code != http.StatusBadRequest || errResp.Error == "" {
+		t.Errorf("expected an invalid schema to be rejected, got %v %v", code, errResp)
+	}
+	var list server.SchemasResponse
+	if code := do(t, srv, http.MethodGet, "/v1/schemas", nil, &list); code != http.StatusOK || !reflect.DeepEqual(list.Schemas, []string{"obj"}) {
+		t.Errorf("expected the schema to be listed, got %v %v", code, list)
+	}
+	if code := do(t, srv, http.MethodDelete, "/v1/schemas/obj", nil, nil); code != http.StatusNoContent {
+		t.Errorf("expected %v, got %v", http.StatusNoContent, code)
+	}
+	if code := do(t, srv, http.MethodDelete, "/v1/schemas/obj", nil, nil); code != http.StatusNotFound {
+		t.Errorf("expected %v, got %v", http.StatusNotFound, code)
+	}
+	if code := do(t, srv, http.MethodPost, "/v1/merge", server.ObjectsRequest{Schema: "obj", LHS: json.RawMessage(`{}`), RHS: json.RawMessage(`{}`)}, nil); code != http.StatusNotFound {
+		t.Errorf("expected unknown schemas to be not found, got %v", code)
+	}
+}
+
+func TestMergeAndCompare(t *testing.T) {
+	srv := server.NewServer()
+	if err := srv.RegisterSchema("obj", []byte(schemaYAML), ""); err != nil {
+		t.Fatal(err)
+	}
+	req := server.ObjectsRequest{
+		Schema: "obj",
+		LHS:    json.RawMessage(`{"replicas": 1, "args": ["a", "b"]}`),
+		RHS:    json.RawMessage(`{"args": ["c"]}`),
+	}
+	var obj server.ObjectResponse
+	if code := do(t, srv, http.MethodPost, "/v1/merge", req, &obj); code != http.StatusOK {
+		t.Fatalf("merge failed with %v", code)
+	}
+	equalJSON(t, `{"replicas": 1, "args": ["c"]}`, obj.Object)
+
+	var cmp server.CompareResponse
+	if code := do(t, srv, http.MethodPost, "/v1/compare", req, &cmp); code != http.StatusOK {
+		t.Fatalf("compare failed with %v", code)
+	}
+	equalJSON(t, `{}`, cmp.Added)
+	equalJSON(t, `{"f:args":{}}`, cmp.Modified)
+	equalJSON(t, `{"f:replicas":{}}`, cmp.Removed)
+
+	if code := do(t, srv, http.MethodGet, "/v1/merge", nil, nil); code != http.StatusMethodNotAllowed {
+		t.Errorf("expected %v, got %v", http.StatusMethodNotAllowed, code)
+	}
+}
+
+func TestApplyAndUpdate(t *testing.T) {
+	srv := server.NewServer()
+	var applied server.ObjectResponse
+	if code := do(t, srv, http.MethodPost, "/v1/apply", server.ApplyRequest{
+		Config:  json.RawMessage(`{"replicas": 1}`),
+		Manager: "a",
+	}, &applied); code != http.StatusOK {
+		t.Fatalf("apply failed with %v", code)
+	}
+	equalJSON(t, `{"replicas": 1}`, applied.Object)
+
+	var errResp server.ErrorResponse
+	code := do(t, srv, http.MethodPost, "/v1/apply", server.ApplyRequest{
+		Live:          applied.Object,
+		Config:        json.RawMessage(`{"replicas": 2}`),
+		Manager:       "b",
+		ManagedFields: applied.ManagedFields,
+	}, &errResp)
+	if code != http.StatusConflict || !reflect.DeepEqual(errResp.Conflicts, []server.Conflict{{Manager: "a", Path: ".replicas"}}) {
+		t.Fatalf("expected a conflict, got %v %v", code, errResp)
+	}
+
+	var updated server.ObjectResponse
+	if code := do(t, srv, http.MethodPost, "/v1/update", server.UpdateRequest{
+		Live:          applied.Object,
+		Object:        json.RawMessage(`{"replicas": 3}`),
+		Manager:       "u",
+		ManagedFields: applied.ManagedFields,
+	}, &updated); code != http.StatusOK {
+		t.Fatalf("update failed with %v", code)
+	}
+	equalJSON(t, `[{"manager": "u", "operation": "Update", "apiVersion": "v1", "fieldsType": "FieldsV1", "fieldsV1": {"f:replicas": {}}}]`, updated.ManagedFields)
+
+	if code := do(t, srv, http.MethodPost, "/v1/apply", server.ApplyRequest{Config: json.RawMessage(`{}`)}, nil); code != http.StatusBadRequest {
+		t.Errorf("expected a missing manager to be rejected, got %v", code)
+	}
+}
+
+func TestLimits(t *testing.T) {
+	srv := server.NewServer(
+		server.WithMaxBodyBytes(256),
+		server.WithUntypedLimits(typed.UntypedLimits{MaxDepth: 2}),
+	)
+	var resp server.ErrorResponse
+	large := server.ObjectsRequest{
+		LHS: json.RawMessage(`{"a": "` + strings.Repeat("x", 256) + `"}`),
+		RHS: json.RawMessage(`{}`),
+	}
+	if code := do(t, srv, http.MethodPost, "/v1/merge", large, &resp); code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected %v, got %v: %v", http.StatusRequestEntityTooLarge, code, resp.Error)
+	}
+	if code := do(t, srv, http.MethodPut, "/v1/schemas/obj", server.SchemaRequest{Schema: strings.Repeat(" ", 256)}, &resp); code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected %v, got %v: %v", http.StatusRequestEntityTooLarge, code, resp.Error)
+	}
+
+	deep := server.ObjectsRequest{
+		LHS: json.RawMessage(`{"a": {"b": {"c": 1}}}`),
+		RHS: json.RawMessage(`{}`),
+	}
+	if code := do(t, srv, http.MethodPost, "/v1/merge", deep, &resp); code != http.StatusBadRequest {
+		t.Errorf("expected %v, got %v", http.StatusBadRequest, code)
+	} else if !strings.Contains(resp.Error, "MaxDepth") {
+		t.Errorf("expected a MaxDepth error, got %q", resp.Error)
+	}
+	shallow := server.ObjectsRequest{
+		LHS: json.RawMessage(`{"a": {"b": 1}}`),
+		RHS: json.RawMessage(`{}`),
+	}
+	if code := do(t, srv, http.MethodPost, "/v1/merge", shallow, nil); code != http.StatusOK {
+		t.Errorf("expected %v, got %v", http.StatusOK, code)
+	}
+}
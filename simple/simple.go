@@ -0,0 +1,196 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package simple
+
+import (
+	"errors"
+	"fmt"
+
+	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
+	"sigs.k8s.io/structured-merge-diff/v4/merge"
+	"sigs.k8s.io/structured-merge-diff/v4/typed"
+	"sigs.k8s.io/structured-merge-diff/v4/value"
+)
+
+// Version is the only version objects are managed at. Managed fields
+// returned by ApplyYAML are recorded at this version.
+const Version = fieldpath.APIVersion("v1")
+
+// Schema is the type documents are parsed as.
+type Schema struct {
+	pt typed.ParseableType
+}
+
+// NewSchema parses and validates a schema and returns the type with the
+// given name. If typeName is empty, the first type of the schema is used.
+func NewSchema(schemaYAML []byte, typeName string) (*Schema, error) {
+	parser, err := typed.NewParser(typed.YAMLObject(schemaYAML))
+	if err != nil {
+		return nil, err
+	}
+	if typeName == "" {
+		if len(parser.Schema.Types) == 0 {
+			return nil, errors.New("no types were given in the schema")
+		}
+		typeName = parser.Schema.Types[0].Name
+	}
+	pt := parser.Type(typeName)
+	if !pt.IsValid() {
+		return nil, fmt.Errorf("type %q not found in schema", typeName)
+	}
+	return &Schema{pt: pt}, nil
+}
+
+// Deduced returns a schema that deduces the type from the content of the
+// documents: maps are granular and lists are atomic.
+func Deduced() *Schema {
+	return &Schema{pt: typed.DeducedParseableType}
+}
+
+// WithUntypedLimits returns a copy of s which fails to parse the documents
+// whose untyped content exceeds limits, e.g. the documents of deduced
+// schemas, which are untyped all the way down.
+func (s *Schema) WithUntypedLimits(limits typed.UntypedLimits) *Schema {
+	return &Schema{pt: s.pt.WithUntypedLimits(limits)}
+}
+
+func (s *Schema) parse(name string, doc []byte) (*typed.TypedValue, error) {
+	if len(doc) == 0 {
+		doc = []byte("null")
+	}
+	tv, err := s.pt.FromYAML(typed.YAMLObject(doc))
+	if err != nil {
+		return nil, fmt.Errorf("invalid %v: %v", name, err)
+	}
+	return tv, nil
+}
+
+// MergeYAML merges rhs into lhs and returns the result as YAML. Fields set
+// in both documents take their value from rhs; no field is ever removed.
+func MergeYAML(s *Schema, lhs, rhs []byte) ([]byte, error) {
+	l, err := s.parse("lhs", lhs)
+	if err != nil {
+		return nil, err
+	}
+	r, err := s.parse("rhs", rhs)
+	if err != nil {
+		return nil, err
+	}
+	out, err := l.Merge(r)
+	if err != nil {
+		return nil, err
+	}
+	return value.ToYAML(out.AsValue())
+}
+
+// DiffYAML compares lhs and rhs and returns the fields that were added,
+// modified or removed by rhs.
+func DiffYAML(s *Schema, lhs, rhs []byte) (*typed.Comparison, error) {
+	l, err := s.parse("lhs", lhs)
+	if err != nil {
+		return nil, err
+	}
+	r, err := s.parse("rhs", rhs)
+	if err != nil {
+		return nil, err
+	}
+	return l.Compare(r)
+}
+
+// ApplyYAML applies config on behalf of manager to the live document, which
+// may be empty if the object doesn't exist yet. managed records which
+// manager owns which fields; it may be nil the first time and should be
+// kept and passed back for subsequent calls. Unless force is set, an error
+// of type merge.Conflicts is returned if config changes fields owned by
+// other managers.
+func ApplyYAML(s *Schema, live, config []byte, manager string, managed fieldpath.ManagedFields, force bool) ([]byte, fieldpath.ManagedFields, error) {
+	l, err := s.parse("live object", live)
+	if err != nil {
+		return nil, nil, err
+	}
+	c, err := s.parse("config", config)
+	if err != nil {
+		return nil, nil, err
+	}
+	if managed == nil {
+		managed = fieldpath.ManagedFields{}
+	}
+	updater := newUpdater()
+	out, managed, err := updater.Apply(l, c, Version, managed.Copy(), manager, force)
+	if err != nil {
+		return nil, nil, err
+	}
+	y, err := value.ToYAML(out.AsValue())
+	if err != nil {
+		return nil, nil, err
+	}
+	return y, managed, nil
+}
+
+// UpdateYAML records that manager changed the live document into the
+// updated one, and returns the new ownership of the fields. Updates never
+// conflict: fields changed by manager are taken away from their previous
+// owners.
+func UpdateYAML(s *Schema, live, updated []byte, manager string, managed fieldpath.ManagedFields) (fieldpath.ManagedFields, error) {
+	l, err := s.parse("live object", live)
+	if err != nil {
+		return nil, err
+	}
+	u, err := s.parse("updated object", updated)
+	if err != nil {
+		return nil, err
+	}
+	if managed == nil {
+		managed = fieldpath.ManagedFields{}
+	}
+	updater := newUpdater()
+	_, managed, err = updater.Update(l, u, Version, managed.Copy(), manager)
+	return managed, err
+}
+
+func newUpdater() *merge.Updater {
+	builder := merge.UpdaterBuilder{
+		Converter:         identityConverter{},
+		ReturnInputOnNoop: true,
+	}
+	return builder.BuildUpdater()
+}
+
+// identityConverter doesn't convert: all documents are at Version.
+type identityConverter struct{}
+
+var _ merge.Converter = identityConverter{}
+
+func (identityConverter) Convert(v *typed.TypedValue, version fieldpath.APIVersion) (*typed.TypedValue, error) {
+	if version != Version {
+		return nil, missingVersionError(version)
+	}
+	return v, nil
+}
+
+// IsMissingVersionError lets the updater drop fields managed at any other
+// version than Version.
+func (identityConverter) IsMissingVersionError(err error) bool {
+	_, ok := err.(missingVersionError)
+	return ok
+}
+
+type missingVersionError fieldpath.APIVersion
+
+func (e missingVersionError) Error() string {
+	return fmt.Sprintf("unknown version %q", string(e))
+}
@@ -0,0 +1,157 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package simple
+
+import (
+	"testing"
+
+	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
+	"sigs.k8s.io/structured-merge-diff/v4/merge"
+)
+
+var testSchema = []byte(`types:
+- name: config
+  map:
+    fields:
+    - name: name
+      type:
+        scalar: string
+    - name: replicas
+      type:
+        scalar: numeric
+    - name: ports
+      type:
+        list:
+          elementType:
+            namedType: port
+          elementRelationship: associative
+          keys:
+          - name
+- name: port
+  map:
+    fields:
+    - name: name
+      type:
+        scalar: string
+    - name: port
+      type:
+        scalar: numeric
+`)
+
+func mustSchema(t *testing.T) *Schema {
+	t.Helper()
+	s, err := NewSchema(testSchema, "")
+	if err != nil {
+		t.Fatalf("failed to create schema: %v", err)
+	}
+	return s
+}
+
+func TestNewSchemaErrors(t *testing.T) {
+	if _, err := NewSchema(testSchema, "missing"); err == nil {
+		t.Errorf("expected error for missing type")
+	}
+	if _, err := NewSchema([]byte(`types: 3`), ""); err == nil {
+		t.Errorf("expected error for invalid schema")
+	}
+	if _, err := NewSchema([]byte(`types: []`), ""); err == nil {
+		t.Errorf("expected error for empty schema")
+	}
+}
+
+func TestMergeYAML(t *testing.T) {
+	out, err := MergeYAML(mustSchema(t),
+		[]byte("name: a\nports:\n- name: http\n  port: 80\n"),
+		[]byte("replicas: 3\nports:\n- name: https\n  port: 443\n"),
+	)
+	if err != nil {
+		t.Fatalf("failed to merge: %v", err)
+	}
+	expected := `name: a
+ports:
+- name: http
+  port: 80
+- name: https
+  port: 443
+replicas: 3
+`
+	if string(out) != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, out)
+	}
+
+	if _, err := MergeYAML(mustSchema(t), []byte("name: 1"), []byte("{}")); err == nil {
+		t.Errorf("expected validation error")
+	}
+}
+
+func TestDiffYAML(t *testing.T) {
+	c, err := DiffYAML(Deduced(), []byte("a: 1\nb: 2\n"), []byte("a: 2\nc: 3\n"))
+	if err != nil {
+		t.Fatalf("failed to diff: %v", err)
+	}
+	if !c.Modified.Equals(fieldpath.NewSet(fieldpath.MakePathOrDie("a"))) {
+		t.Errorf("unexpected modified fields: %v", c.Modified)
+	}
+	if !c.Added.Equals(fieldpath.NewSet(fieldpath.MakePathOrDie("c"))) {
+		t.Errorf("unexpected added fields: %v", c.Added)
+	}
+	if !c.Removed.Equals(fieldpath.NewSet(fieldpath.MakePathOrDie("b"))) {
+		t.Errorf("unexpected removed fields: %v", c.Removed)
+	}
+}
+
+func TestApplyYAML(t *testing.T) {
+	s := mustSchema(t)
+	live, managed, err := ApplyYAML(s, nil, []byte("name: a\nreplicas: 1\n"), "alice", nil, false)
+	if err != nil {
+		t.Fatalf("failed to apply: %v", err)
+	}
+
+	managed, err = UpdateYAML(s, live, []byte("name: a\nreplicas: 2\n"), "controller", managed)
+	if err != nil {
+		t.Fatalf("failed to update: %v", err)
+	}
+	live = []byte("name: a\nreplicas: 2\n")
+
+	_, _, err = ApplyYAML(s, live, []byte("name: a\nreplicas: 3\n"), "alice", managed, false)
+	if _, ok := err.(merge.Conflicts); !ok {
+		t.Fatalf("expected conflicts, got %v", err)
+	}
+
+	// Not applying replicas anymore leaves them to the controller.
+	live, managed, err = ApplyYAML(s, live, []byte("name: a\n"), "alice", managed, false)
+	if err != nil {
+		t.Fatalf("failed to apply: %v", err)
+	}
+	if expected := "name: a\nreplicas: 2\n"; string(live) != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, live)
+	}
+	if !managed["controller"].Set().Equals(fieldpath.NewSet(fieldpath.MakePathOrDie("replicas"))) {
+		t.Errorf("unexpected managed fields:\n%v", managed)
+	}
+
+	live, managed, err = ApplyYAML(s, live, []byte("name: a\nreplicas: 3\n"), "alice", managed, true)
+	if err != nil {
+		t.Fatalf("failed to force apply: %v", err)
+	}
+	if expected := "name: a\nreplicas: 3\n"; string(live) != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, live)
+	}
+	if _, ok := managed["controller"]; ok {
+		t.Errorf("expected controller to lose ownership:\n%v", managed)
+	}
+}
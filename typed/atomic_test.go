@@ -0,0 +1,154 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package typed_test
+
+import (
+	"testing"
+
+	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
+	"sigs.k8s.io/structured-merge-diff/v4/typed"
+	"sigs.k8s.io/structured-merge-diff/v4/value"
+)
+
+// atomicStructParser has a struct, resources, which is atomic: it is owned
+// and replaced as a whole.
+var atomicStructParser = func() *typed.Parser {
+	parser, err := typed.NewParser(`types:
+- name: container
+  map:
+    fields:
+    - name: name
+      type:
+        scalar: string
+    - name: resources
+      type:
+        namedType: resources
+- name: resources
+  map:
+    fields:
+    - name: limits
+      type:
+        namedType: quantities
+    - name: requests
+      type:
+        namedType: quantities
+    elementRelationship: atomic
+- name: quantities
+  map:
+    fields:
+    - name: cpu
+      type:
+        scalar: string
+    - name: memory
+      type:
+        scalar: string
+`)
+	if err != nil {
+		panic(err)
+	}
+	return parser
+}()
+
+func parseAtomicStruct(t *testing.T, object typed.YAMLObject) *typed.TypedValue {
+	t.Helper()
+	tv, err := atomicStructParser.Type("container").FromYAML(object)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tv
+}
+
+func TestAtomicStructToFieldSet(t *testing.T) {
+	tv := parseAtomicStruct(t, `{"name": "app", "resources": {"limits": {"cpu": "1"}, "requests": {"memory": "1Gi"}}}`)
+	set, err := tv.ToFieldSet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := _NS(_P("name"), _P("resources"))
+	if !set.Equals(expected) {
+		t.Errorf("expected the fields of the atomic struct not to be listed, expected:\n%v\ngot:\n%v", expected, set)
+	}
+}
+
+func TestAtomicStructMerge(t *testing.T) {
+	lhs := parseAtomicStruct(t, `{"name": "app", "resources": {"limits": {"cpu": "1"}, "requests": {"memory": "1Gi"}}}`)
+	rhs := parseAtomicStruct(t, `{"resources": {"limits": {"memory": "2Gi"}}}`)
+	out, err := lhs.Merge(rhs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The struct is replaced as a whole, its fields aren't merged.
+	expected := parseAtomicStruct(t, `{"name": "app", "resources": {"limits": {"memory": "2Gi"}}}`)
+	if !value.Equals(out.AsValue(), expected.AsValue()) {
+		t.Errorf("expected %v, got %v", value.ToString(expected.AsValue()), value.ToString(out.AsValue()))
+	}
+}
+
+func TestAtomicStructCompare(t *testing.T) {
+	tests := []struct {
+		name            string
+		lhs, rhs        typed.YAMLObject
+		added, modified *fieldpath.Set
+		removed         *fieldpath.Set
+	}{{
+		name:     "nested change",
+		lhs:      `{"resources": {"limits": {"cpu": "1"}}}`,
+		rhs:      `{"resources": {"limits": {"cpu": "2"}}}`,
+		added:    _NS(),
+		modified: _NS(_P("resources")),
+		removed:  _NS(),
+	}, {
+		name:     "added field",
+		lhs:      `{"resources": {"limits": {"cpu": "1"}}}`,
+		rhs:      `{"resources": {"limits": {"cpu": "1"}, "requests": {"cpu": "1"}}}`,
+		added:    _NS(),
+		modified: _NS(_P("resources")),
+		removed:  _NS(),
+	}, {
+		name:     "added struct",
+		lhs:      `{"name": "app"}`,
+		rhs:      `{"name": "app", "resources": {"limits": {"cpu": "1"}}}`,
+		added:    _NS(_P("resources")),
+		modified: _NS(),
+		removed:  _NS(),
+	}, {
+		name:     "removed struct",
+		lhs:      `{"name": "app", "resources": {"limits": {"cpu": "1"}}}`,
+		rhs:      `{"name": "app"}`,
+		added:    _NS(),
+		modified: _NS(),
+		removed:  _NS(_P("resources")),
+	}, {
+		name:     "unchanged",
+		lhs:      `{"resources": {"limits": {"cpu": "1"}}}`,
+		rhs:      `{"resources": {"limits": {"cpu": "1"}}}`,
+		added:    _NS(),
+		modified: _NS(),
+		removed:  _NS(),
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := parseAtomicStruct(t, tt.lhs).Compare(parseAtomicStruct(t, tt.rhs))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !c.Added.Equals(tt.added) || !c.Modified.Equals(tt.modified) || !c.Removed.Equals(tt.removed) {
+				t.Errorf("expected added %v, modified %v and removed %v, got:\n%v", tt.added, tt.modified, tt.removed, c)
+			}
+		})
+	}
+}
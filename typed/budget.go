@@ -0,0 +1,100 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package typed
+
+import (
+	"fmt"
+
+	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
+	"sigs.k8s.io/structured-merge-diff/v4/value"
+)
+
+// MergeBudget bounds the work and memory of a single Merge, so that
+// pathological objects make it fail rather than exhaust the process. Limits
+// which aren't positive aren't enforced.
+type MergeBudget struct {
+	// MaxNodes is the maximum number of fields, items and values visited.
+	MaxNodes int
+	// MaxBytes is the maximum size of the merged object, as estimated by
+	// value.EstimateSize.
+	MaxBytes int
+}
+
+// MergeBudgetError is returned by Merge when it exceeds its MergeBudget.
+type MergeBudgetError struct {
+	// Path is the field or item being merged when the budget was exceeded.
+	Path fieldpath.Path
+	// Limit is the name of the exceeded limit, "MaxNodes" or "MaxBytes".
+	Limit string
+	// Max is the value of the exceeded limit.
+	Max int
+}
+
+func (e *MergeBudgetError) Error() string {
+	if len(e.Path) == 0 {
+		return fmt.Sprintf("merge exceeds %v of %v", e.Limit, e.Max)
+	}
+	return fmt.Sprintf("%v: merge exceeds %v of %v", e.Path, e.Limit, e.Max)
+}
+
+// WithMergeBudget configures Merge to abort with a *MergeBudgetError as soon
+// as it exceeds budget.
+func WithMergeBudget(budget MergeBudget) MergeOption {
+	return func(opts *mergeOptions) {
+		opts.budget = budget
+	}
+}
+
+// mergeBudgetState tracks the spending of a MergeBudget during one merge.
+type mergeBudgetState struct {
+	budget MergeBudget
+	nodes  int
+	bytes  int
+	err    *MergeBudgetError
+}
+
+func newMergeBudgetState(budget MergeBudget) *mergeBudgetState {
+	if budget.MaxNodes <= 0 && budget.MaxBytes <= 0 {
+		return nil
+	}
+	return &mergeBudgetState{budget: budget}
+}
+
+// visit accounts for a node at path, it returns false once the budget is
+// exceeded.
+func (s *mergeBudgetState) visit(path fieldpath.Path) bool {
+	if s.err != nil {
+		return false
+	}
+	s.nodes++
+	if s.budget.MaxNodes > 0 && s.nodes > s.budget.MaxNodes {
+		s.err = &MergeBudgetError{Path: path.Copy(), Limit: "MaxNodes", Max: s.budget.MaxNodes}
+		return false
+	}
+	return true
+}
+
+// keep accounts for v, kept as a leaf of the merged object at path.
+func (s *mergeBudgetState) keep(path fieldpath.Path, v value.Value) {
+	if s.err != nil || s.budget.MaxBytes <= 0 || v == nil {
+		return
+	}
+	s.bytes += value.EstimateSize(v)
+	if s.bytes > s.budget.MaxBytes {
+		s.err = &MergeBudgetError{Path: path.Copy(), Limit: "MaxBytes", Max: s.budget.MaxBytes}
+	}
+}
@@ -0,0 +1,96 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package typed_test
+
+import (
+	"errors"
+	"testing"
+
+	"sigs.k8s.io/structured-merge-diff/v4/typed"
+)
+
+func TestMergeBudget(t *testing.T) {
+	tests := []struct {
+		name   string
+		lhs    typed.YAMLObject
+		rhs    typed.YAMLObject
+		budget typed.MergeBudget
+		// err is the path and limit of the expected error, if any.
+		err string
+	}{{
+		name: "no budget",
+		lhs:  `{"name": "a", "limits": {"a": 1, "b": 2}}`,
+		rhs:  `{"limits": {"c": 3}}`,
+	}, {
+		name:   "within nodes",
+		lhs:    `{"name": "a", "limits": {"a": 1, "b": 2}}`,
+		rhs:    `{"limits": {"c": 3}}`,
+		budget: typed.MergeBudget{MaxNodes: 6},
+	}, {
+		name:   "too many nodes",
+		lhs:    `{"limits": {"a": 1}}`,
+		rhs:    `{"limits": {"a": 2}}`,
+		budget: typed.MergeBudget{MaxNodes: 2},
+		err:    `.limits.a MaxNodes`,
+	}, {
+		name:   "too many items",
+		lhs:    `{"ports": [{"name": "a"}, {"name": "b"}]}`,
+		rhs:    `{"ports": [{"name": "c", "number": 1}]}`,
+		budget: typed.MergeBudget{MaxNodes: 5},
+		err:    `.ports[name="b"].name MaxNodes`,
+	}, {
+		name:   "within bytes",
+		lhs:    `{"name": "aaaa"}`,
+		rhs:    `{"name": "bbbbbbbb"}`,
+		budget: typed.MergeBudget{MaxBytes: 24},
+	}, {
+		name:   "too many bytes",
+		lhs:    `{"name": "aaaa"}`,
+		rhs:    `{"name": "bbbbbbbbb"}`,
+		budget: typed.MergeBudget{MaxBytes: 24},
+		err:    `.name MaxBytes`,
+	}}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			pt := coerceParser.Type("resources")
+			lhs, err := pt.FromYAML(tt.lhs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rhs, err := pt.FromYAML(tt.rhs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = lhs.Merge(rhs, typed.WithMergeBudget(tt.budget))
+			if tt.err == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			var budgetErr *typed.MergeBudgetError
+			if !errors.As(err, &budgetErr) {
+				t.Fatalf("expected a MergeBudgetError, got %v", err)
+			}
+			if got := budgetErr.Path.String() + " " + budgetErr.Limit; got != tt.err {
+				t.Errorf("expected error %q, got %q", tt.err, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,178 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package typed_test
+
+import (
+	"fmt"
+	"math"
+	"runtime/debug"
+	"testing"
+
+	"sigs.k8s.io/structured-merge-diff/v4/schema"
+	"sigs.k8s.io/structured-merge-diff/v4/typed"
+	"sigs.k8s.io/structured-merge-diff/v4/value"
+	yaml "sigs.k8s.io/yaml/goyaml.v2"
+)
+
+// TestChaos checks that the operations of TypedValue don't panic, whatever
+// their values and schemas, including values of kinds which don't match
+// their schema, holding Go types which aren't unstructured, and broken
+// schemas.
+func TestChaos(t *testing.T) {
+	schemas := map[string]string{
+		"valid": `types:
+- name: root
+  map:
+    fields:
+    - name: s
+      type: {scalar: string}
+    - name: l
+      type:
+        list:
+          elementType: {namedType: item}
+          elementRelationship: associative
+          keys: [k]
+    - name: set
+      type:
+        list:
+          elementType: {scalar: string}
+          elementRelationship: associative
+    - name: m
+      type:
+        map:
+          elementType: {scalar: numeric}
+- name: item
+  map:
+    fields:
+    - name: k
+      type: {scalar: string}
+    - name: v
+      type: {namedType: root}
+`,
+		"missing types": `types:
+- name: root
+  map:
+    fields:
+    - name: s
+      type: {namedType: missing}
+    - name: l
+      type:
+        list:
+          elementType: {namedType: missing}
+          elementRelationship: associative
+          keys: [k]
+`,
+		"empty atoms": `types:
+- name: root
+  map:
+    fields:
+    - name: s
+      type: {}
+    - name: l
+      type:
+        list:
+          elementRelationship: associative
+          keys: [k]
+    - name: m
+      type:
+        map: {}
+`,
+		"missing keys": `types:
+- name: root
+  map:
+    fields:
+    - name: l
+      type:
+        list:
+          elementType: {scalar: string}
+          elementRelationship: associative
+          keys: [k]
+    - name: set
+      type:
+        list:
+          elementType:
+            map:
+              fields:
+              - name: a
+                type: {scalar: string}
+          elementRelationship: associative
+`,
+		"recursive": `types:
+- name: root
+  namedType: root
+`,
+	}
+	values := map[string]interface{}{
+		"empty":          map[string]interface{}{},
+		"wrong kinds":    map[string]interface{}{"s": []interface{}{1}, "l": "x", "set": map[string]interface{}{}, "m": []interface{}{}},
+		"bad items":      map[string]interface{}{"l": []interface{}{1, nil, []interface{}{}, map[string]interface{}{"k": []interface{}{}}}, "set": []interface{}{map[string]interface{}{}, nil, "a", "a"}},
+		"nulls":          map[string]interface{}{"s": nil, "l": nil, "set": []interface{}{nil}, "m": map[string]interface{}{"a": nil}},
+		"go types":       map[string]interface{}{"s": struct{}{}, "l": []string{"a"}, "m": map[string]string{"a": "b"}, "set": []int{1}},
+		"other go types": map[string]interface{}{"s": make(chan int), "l": func() {}, "m": map[int]interface{}{1: 2}, "set": &struct{}{}},
+		"numbers":        map[string]interface{}{"s": math.NaN(), "m": map[string]interface{}{"a": math.Inf(1), "b": uint64(math.MaxUint64), "c": int8(1)}},
+		"interface keys": map[interface{}]interface{}{1: "a", "l": []interface{}{map[interface{}]interface{}{nil: 1, "k": "a"}}},
+		"nested":         map[string]interface{}{"l": []interface{}{map[string]interface{}{"k": "a", "v": map[string]interface{}{"l": []interface{}{map[string]interface{}{"k": 1}}}}}},
+		"scalar root":    "x",
+		"list root":      []interface{}{1},
+		"nil root":       nil,
+	}
+	objects := map[string]value.Value{}
+	for name, v := range values {
+		objects[name] = value.NewValueInterface(v)
+	}
+	set := _NS(_P("s"), _P("l", _KBF("k", "a")), _P("set", _V("a")), _P("m", "a"), _P("l", 0))
+	for schemaName, schemaYAML := range schemas {
+		var s schema.Schema
+		if err := yaml.Unmarshal([]byte(schemaYAML), &s); err != nil {
+			t.Fatalf("%v: %v", schemaName, err)
+		}
+		tr := schema.TypeRef{NamedType: &s.Types[0].Name}
+		for lhsName, lhs := range objects {
+			for rhsName, rhs := range objects {
+				l := typed.AsTypedUnvalidated(lhs, &s, tr)
+				r := typed.AsTypedUnvalidated(rhs, &s, tr)
+				operations := map[string]func(){
+					"Validate":    func() { l.Validate() },
+					"ToFieldSet":  func() { l.ToFieldSet() },
+					"Merge":       func() { l.Merge(r) },
+					"Compare":     func() { l.Compare(r) },
+					"RemoveItems": func() { l.RemoveItems(set) },
+					"ExtractItems": func() {
+						l.ExtractItems(set, typed.WithAppendKeyFields())
+					},
+					"WithDefaults":              func() { l.WithDefaults() },
+					"NormalizeEmptyCollections": func() { l.NormalizeEmptyCollections(typed.EmptyEqualsAbsent) },
+					"Overlay":                   func() { l.Overlay(r.AsValue()) },
+					"Stats":                     func() { l.Stats() },
+					"ToYAML":                    func() { l.ToYAML() },
+					"Diff":                      func() { l.Diff(r) },
+				}
+				for opName, op := range operations {
+					name := fmt.Sprintf("%v/%v/%v/%v", schemaName, opName, lhsName, rhsName)
+					func() {
+						defer func() {
+							if p := recover(); p != nil {
+								t.Errorf("%v: panic: %v\n%s", name, p, debug.Stack())
+							}
+						}()
+						op()
+					}()
+				}
+			}
+		}
+	}
+}
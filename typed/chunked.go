@@ -0,0 +1,148 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package typed
+
+import (
+	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
+	"sigs.k8s.io/structured-merge-diff/v4/schema"
+	"sigs.k8s.io/structured-merge-diff/v4/value"
+)
+
+// ListChunking bounds the memory used by Compare for very long associative
+// lists, e.g. aggregated endpoints. Their items are normally all indexed by
+// key on both sides. Chunked lists are instead walked in lockstep while
+// their items have the same keys, which is the common case of lists whose
+// order didn't change; then the items of lhs are indexed ChunkSize at a
+// time, and matched with the items of rhs while iterating over them again.
+// Beyond the index of a chunk, only a flag per item of rhs is kept, at the
+// cost of computing the keys of the reordered items of rhs once per chunk.
+//
+// The items of chunked lists must have unique keys: duplicated keys, only
+// allowed with AllowDuplicates, are compared item by item rather than as a
+// whole.
+type ListChunking struct {
+	// MinLength is the length from which lists are chunked, on either
+	// side. Lists aren't chunked if it isn't positive.
+	MinLength int
+	// ChunkSize is the number of items of lhs indexed at once. Lists
+	// aren't chunked if it isn't positive.
+	ChunkSize int
+}
+
+// WithListChunking configures Compare to compare long associative lists by
+// chunks, see ListChunking.
+func WithListChunking(chunking ListChunking) CompareOption {
+	return func(opts *compareOptions) {
+		opts.listChunking = chunking
+	}
+}
+
+func (c ListChunking) enabled(lLen, rLen int) bool {
+	if c.MinLength <= 0 || c.ChunkSize <= 0 {
+		return false
+	}
+	return lLen >= c.MinLength || rLen >= c.MinLength
+}
+
+// chunkItem is an item of lhs in the index of a chunk.
+type chunkItem struct {
+	value   value.Value
+	matched bool
+}
+
+func (w *compareWalker) visitListItemsChunked(t *schema.List, lhs, rhs value.List, lLen, rLen int) (errs ValidationErrors) {
+	folders := keyFolders(t)
+	pathElement := func(l value.List, i int) (value.Value, fieldpath.PathElement, error) {
+		child := l.At(i)
+		pe, err := listItemToPathElement(w.allocator, w.schema, t, folders, child)
+		return child, pe, err
+	}
+
+	// Walk the items in lockstep while they have the same keys. Invalid
+	// items are left to be reported below.
+	start := 0
+	for ; start < lLen && start < rLen; start++ {
+		lChild, lPE, err := pathElement(lhs, start)
+		if err != nil {
+			break
+		}
+		rChild, rPE, err := pathElement(rhs, start)
+		if err != nil || !lPE.Equals(rPE) {
+			break
+		}
+		errs = append(errs, w.compareListItem(t, lPE, lChild, rChild)...)
+	}
+
+	// matched flags the remaining items of rhs matched by an item of lhs.
+	matched := make([]bool, rLen-start)
+	for chunkStart := start; chunkStart < lLen; chunkStart += w.listChunking.ChunkSize {
+		chunkEnd := chunkStart + w.listChunking.ChunkSize
+		if chunkEnd > lLen {
+			chunkEnd = lLen
+		}
+		index := fieldpath.MakePathElementMap(chunkEnd - chunkStart)
+		order := make([]fieldpath.PathElement, 0, chunkEnd-chunkStart)
+		for i := chunkStart; i < chunkEnd; i++ {
+			child, pe, err := pathElement(lhs, i)
+			if err != nil {
+				errs = append(errs, errorf("element %v: %v", i, err.Error())...)
+				continue
+			}
+			// Ignore repeated occurrences of pe.
+			if _, found := index.Get(pe); found {
+				continue
+			}
+			index.Insert(pe, &chunkItem{value: child})
+			order = append(order, pe)
+		}
+		for i := start; i < rLen; i++ {
+			if matched[i-start] {
+				continue
+			}
+			rChild, pe, err := pathElement(rhs, i)
+			if err != nil {
+				continue
+			}
+			item, found := index.Get(pe)
+			if !found || item.(*chunkItem).matched {
+				continue
+			}
+			item.(*chunkItem).matched = true
+			matched[i-start] = true
+			errs = append(errs, w.compareListItem(t, pe, item.(*chunkItem).value, rChild)...)
+		}
+		for _, pe := range order {
+			item, _ := index.Get(pe)
+			if !item.(*chunkItem).matched {
+				errs = append(errs, w.compareListItem(t, pe, item.(*chunkItem).value, nil)...)
+			}
+		}
+	}
+
+	for i := start; i < rLen; i++ {
+		if matched[i-start] {
+			continue
+		}
+		rChild, pe, err := pathElement(rhs, i)
+		if err != nil {
+			errs = append(errs, errorf("element %v: %v", i, err.Error())...)
+			continue
+		}
+		errs = append(errs, w.compareListItem(t, pe, nil, rChild)...)
+	}
+	return errs
+}
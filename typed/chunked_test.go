@@ -0,0 +1,147 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package typed_test
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"sigs.k8s.io/structured-merge-diff/v4/typed"
+)
+
+var chunkedParser = func() *typed.Parser {
+	parser, err := typed.NewParser(`types:
+- name: endpoints
+  map:
+    fields:
+    - name: endpoints
+      type:
+        list:
+          elementType:
+            namedType: endpoint
+          elementRelationship: associative
+          keys: [ip]
+- name: endpoint
+  map:
+    fields:
+    - name: ip
+      type:
+        scalar: string
+    - name: ready
+      type:
+        scalar: boolean
+`)
+	if err != nil {
+		panic(err)
+	}
+	return parser
+}()
+
+func endpoints(ips []int, ready func(ip int) bool) map[string]interface{} {
+	items := make([]interface{}, 0, len(ips))
+	for _, ip := range ips {
+		items = append(items, map[string]interface{}{
+			"ip":    fmt.Sprintf("10.0.%d.%d", ip/256, ip%256),
+			"ready": ready(ip),
+		})
+	}
+	return map[string]interface{}{"endpoints": items}
+}
+
+func TestListChunking(t *testing.T) {
+	const n = 2000
+	all := make([]int, n)
+	for i := range all {
+		all[i] = i
+	}
+	lhsIPs := all[:n-100]
+	r := rand.New(rand.NewSource(1))
+	tests := []struct {
+		name string
+		rhs  []int
+	}{{
+		name: "same order",
+		rhs:  lhsIPs,
+	}, {
+		name: "appended and removed",
+		rhs:  all[50:],
+	}, {
+		name: "shuffled",
+		rhs: func() []int {
+			ips := append([]int(nil), all[100:]...)
+			r.Shuffle(len(ips), func(i, j int) { ips[i], ips[j] = ips[j], ips[i] })
+			return ips
+		}(),
+	}, {
+		name: "empty",
+		rhs:  []int{},
+	}}
+
+	pt := chunkedParser.Type("endpoints")
+	lhs, err := pt.FromUnstructured(endpoints(lhsIPs, func(int) bool { return true }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			rhs, err := pt.FromUnstructured(endpoints(tt.rhs, func(ip int) bool { return ip%7 != 0 }))
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, err := lhs.Compare(rhs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := lhs.Compare(rhs, typed.WithListChunking(typed.ListChunking{MinLength: 100, ChunkSize: 64}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !got.Added.Equals(want.Added) || !got.Removed.Equals(want.Removed) || !got.Modified.Equals(want.Modified) {
+				t.Errorf("expected:\n%v\ngot:\n%v", want, got)
+			}
+		})
+	}
+}
+
+func BenchmarkListChunking(b *testing.B) {
+	const n = 20000
+	ips := make([]int, n)
+	for i := range ips {
+		ips[i] = i
+	}
+	pt := chunkedParser.Type("endpoints")
+	lhs, err := pt.FromUnstructured(endpoints(ips, func(int) bool { return true }))
+	if err != nil {
+		b.Fatal(err)
+	}
+	rhs, err := pt.FromUnstructured(endpoints(ips, func(ip int) bool { return ip%7 != 0 }))
+	if err != nil {
+		b.Fatal(err)
+	}
+	for _, chunking := range []typed.ListChunking{{}, {MinLength: 1000, ChunkSize: 4096}} {
+		b.Run(fmt.Sprintf("chunk=%d", chunking.ChunkSize), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := lhs.Compare(rhs, typed.WithListChunking(chunking)); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
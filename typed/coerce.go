@@ -0,0 +1,205 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package typed
+
+import (
+	"math"
+	"strconv"
+
+	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
+	"sigs.k8s.io/structured-merge-diff/v4/schema"
+	"sigs.k8s.io/structured-merge-diff/v4/value"
+)
+
+// Coercions configures a ParseableType to convert scalars whose type differs
+// from the one declared by the schema in a benign way, typically because of
+// how YAML or JSON typed them, rather than failing validation. Only numeric
+// fields are converted; values which can't be converted are left as is and
+// validated as usual.
+type Coercions struct {
+	// IntsToFloats converts integers to floats where numeric is declared, so
+	// that all the numbers of such fields have the same Go type.
+	IntsToFloats bool
+	// NumericStrings converts strings which parse as numbers, e.g. "10" or
+	// "0.5", to integers or floats where numeric is declared. This is meant
+	// for quantity-like fields written as strings. NaN and infinities
+	// aren't converted.
+	NumericStrings bool
+	// Record, if not nil, is called with every conversion, in no particular
+	// order. Since a ParseableType can be used concurrently, Record must
+	// be safe for concurrent use if it is.
+	Record func(Coercion)
+}
+
+// Coercion is a scalar converted as configured by Coercions.
+type Coercion struct {
+	// Path is the field or item that was converted.
+	Path fieldpath.Path
+	// From and To are the unstructured values before and after conversion.
+	From, To interface{}
+}
+
+// WithCoercions returns a copy of p whose FromYAML, FromUnstructured and
+// FromStructured convert the scalars of the object as configured by c
+// before validating it.
+func (p ParseableType) WithCoercions(c Coercions) ParseableType {
+	p.coercions = &c
+	return p
+}
+
+func (c *Coercions) enabled() bool {
+	return c != nil && (c.IntsToFloats || c.NumericStrings)
+}
+
+// coerce returns v with the coercions of c applied. v itself is never
+// modified, the maps and lists containing a converted scalar are copied.
+func (c *Coercions) coerce(s *schema.Schema, tr schema.TypeRef, v value.Value) value.Value {
+	w := coercingWalker{schema: s, coercions: c, allocator: value.NewFreelistAllocator()}
+	if out, changed := w.walk(tr, v, nil); changed {
+		return value.NewValueInterface(out)
+	}
+	return v
+}
+
+type coercingWalker struct {
+	schema    *schema.Schema
+	coercions *Coercions
+	allocator value.Allocator
+}
+
+// walk returns the unstructured value of v with its coercions applied, and
+// whether any was applied. The returned value is only meaningful if it was.
+func (w *coercingWalker) walk(tr schema.TypeRef, v value.Value, path fieldpath.Path) (interface{}, bool) {
+	if v == nil || v.IsNull() {
+		return nil, false
+	}
+	a, ok := w.schema.Resolve(tr)
+	if !ok {
+		return nil, false
+	}
+	a = deduceAtom(a, v)
+	switch {
+	case a.Scalar != nil:
+		return w.scalar(*a.Scalar, v, path)
+	case a.List != nil && v.IsList():
+		return w.list(a.List, v.AsListUsing(w.allocator), path)
+	case a.Map != nil && v.IsMap():
+		return w.mapValue(a.Map, v.AsMapUsing(w.allocator), path)
+	}
+	return nil, false
+}
+
+func (w *coercingWalker) scalar(t schema.Scalar, v value.Value, path fieldpath.Path) (interface{}, bool) {
+	if t != schema.Numeric {
+		return nil, false
+	}
+	var out interface{}
+	switch {
+	case v.IsInt() && w.coercions.IntsToFloats:
+		out = float64(v.AsInt())
+	case v.IsString() && w.coercions.NumericStrings:
+		n, ok := parseNumber(v.AsString(), w.coercions.IntsToFloats)
+		if !ok {
+			return nil, false
+		}
+		out = n
+	default:
+		return nil, false
+	}
+	if w.coercions.Record != nil {
+		w.coercions.Record(Coercion{Path: path.Copy(), From: v.Unstructured(), To: out})
+	}
+	return out, true
+}
+
+// parseNumber parses s as an int64, or as a finite float64 if it isn't an
+// integer or if floats is set.
+func parseNumber(s string, floats bool) (interface{}, bool) {
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+		if floats {
+			return float64(i), true
+		}
+		return i, true
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+		return nil, false
+	}
+	return f, true
+}
+
+func (w *coercingWalker) list(t *schema.List, l value.List, path fieldpath.Path) (interface{}, bool) {
+	defer w.allocator.Free(l)
+	var out []interface{}
+	folders := keyFolders(t)
+	for i := 0; i < l.Length(); i++ {
+		item := l.AtUsing(w.allocator, i)
+		defer w.allocator.Free(item)
+		pe, err := listItemToPathElement(w.allocator, w.schema, t, folders, item)
+		if err != nil {
+			index := i
+			pe = fieldpath.PathElement{Index: &index}
+		}
+		converted, changed := w.walk(t.ElementType, item, append(path[:len(path):len(path)], pe))
+		if changed && out == nil {
+			out = make([]interface{}, l.Length())
+			for j := 0; j < i; j++ {
+				out[j] = l.At(j).Unstructured()
+			}
+		}
+		if out != nil {
+			if !changed {
+				converted = item.Unstructured()
+			}
+			out[i] = converted
+		}
+	}
+	return out, out != nil
+}
+
+func (w *coercingWalker) mapValue(t *schema.Map, m value.Map, path fieldpath.Path) (interface{}, bool) {
+	defer w.allocator.Free(m)
+	var changes map[string]interface{}
+	m.IterateUsing(w.allocator, func(key string, val value.Value) bool {
+		tr := t.ElementType
+		if sf, ok := t.FindField(key); ok {
+			tr = sf.Type
+		}
+		k := key
+		converted, changed := w.walk(tr, val, append(path[:len(path):len(path)], fieldpath.PathElement{FieldName: &k}))
+		if changed {
+			if changes == nil {
+				changes = map[string]interface{}{}
+			}
+			changes[key] = converted
+		}
+		return true
+	})
+	if changes == nil {
+		return nil, false
+	}
+	out := make(map[string]interface{}, m.Length())
+	m.IterateUsing(w.allocator, func(key string, val value.Value) bool {
+		if converted, ok := changes[key]; ok {
+			out[key] = converted
+		} else {
+			out[key] = val.Unstructured()
+		}
+		return true
+	})
+	return out, true
+}
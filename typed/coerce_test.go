@@ -0,0 +1,178 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package typed_test
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"sigs.k8s.io/structured-merge-diff/v4/typed"
+	"sigs.k8s.io/structured-merge-diff/v4/value"
+)
+
+var coerceParser = func() *typed.Parser {
+	parser, err := typed.NewParser(`types:
+- name: resources
+  map:
+    fields:
+    - name: name
+      type:
+        scalar: string
+    - name: cpu
+      type:
+        scalar: numeric
+    - name: limits
+      type:
+        map:
+          elementType:
+            scalar: numeric
+    - name: ports
+      type:
+        list:
+          elementType:
+            namedType: port
+          elementRelationship: associative
+          keys:
+          - name
+- name: port
+  map:
+    fields:
+    - name: name
+      type:
+        scalar: string
+    - name: number
+      type:
+        scalar: numeric
+`)
+	if err != nil {
+		panic(err)
+	}
+	return parser
+}()
+
+func TestCoercions(t *testing.T) {
+	tests := []struct {
+		name      string
+		coercions typed.Coercions
+		object    typed.YAMLObject
+		// expected is the parsed object, empty if parsing must fail.
+		expected typed.YAMLObject
+		// recorded are the coercions reported, as "path: from -> to".
+		recorded []string
+	}{{
+		name:     "disabled",
+		object:   `{"name": "10", "cpu": 1}`,
+		expected: `{"name": "10", "cpu": 1}`,
+	}, {
+		name:      "numeric strings rejected without coercion",
+		coercions: typed.Coercions{IntsToFloats: true},
+		object:    `{"cpu": "1"}`,
+	}, {
+		name:      "ints to floats",
+		coercions: typed.Coercions{IntsToFloats: true},
+		object:    `{"name": "n", "cpu": 2, "limits": {"a": 1.5, "b": 3}}`,
+		expected:  `{"name": "n", "cpu": 2.0, "limits": {"a": 1.5, "b": 3.0}}`,
+		recorded:  []string{".cpu: int(2) -> float64(2)", ".limits.b: int(3) -> float64(3)"},
+	}, {
+		name:      "numeric strings",
+		coercions: typed.Coercions{NumericStrings: true},
+		object:    `{"name": "10", "cpu": "2", "limits": {"a": "0.5"}, "ports": [{"name": "http", "number": "80"}]}`,
+		expected:  `{"name": "10", "cpu": 2, "limits": {"a": 0.5}, "ports": [{"name": "http", "number": 80}]}`,
+		recorded: []string{
+			`.cpu: string("2") -> int64(2)`,
+			`.limits.a: string("0.5") -> float64(0.5)`,
+			`.ports[name="http"].number: string("80") -> int64(80)`,
+		},
+	}, {
+		name:      "numeric strings to floats",
+		coercions: typed.Coercions{IntsToFloats: true, NumericStrings: true},
+		object:    `{"cpu": "2"}`,
+		expected:  `{"cpu": 2.0}`,
+		recorded:  []string{`.cpu: string("2") -> float64(2)`},
+	}, {
+		name:      "non numeric strings",
+		coercions: typed.Coercions{NumericStrings: true},
+		object:    `{"cpu": "1Gi"}`,
+	}, {
+		name:      "NaN",
+		coercions: typed.Coercions{NumericStrings: true},
+		object:    `{"cpu": "NaN"}`,
+	}}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			var recorded []string
+			tt.coercions.Record = func(c typed.Coercion) {
+				recorded = append(recorded, fmt.Sprintf("%v: %T(%#v) -> %T(%v)", c.Path, c.From, c.From, c.To, c.To))
+			}
+			pt := coerceParser.Type("resources")
+			if tt.coercions.IntsToFloats || tt.coercions.NumericStrings {
+				pt = pt.WithCoercions(tt.coercions)
+			}
+			tv, err := pt.FromYAML(tt.object)
+			if tt.expected == "" {
+				if err == nil {
+					t.Fatalf("expected an error, got %v", value.ToString(tv.AsValue()))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			expected, err := coerceParser.Type("resources").FromYAML(tt.expected)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := describe(tv.AsValue()), describe(expected.AsValue()); got != want {
+				t.Errorf("expected %v, got %v", want, got)
+			}
+			sort.Strings(recorded)
+			if !reflect.DeepEqual(recorded, tt.recorded) {
+				t.Errorf("expected coercions:\n%v\ngot:\n%v", strings.Join(tt.recorded, "\n"), strings.Join(recorded, "\n"))
+			}
+		})
+	}
+}
+
+// describe is like value.ToString, but tells ints and floats apart.
+func describe(v value.Value) string {
+	switch {
+	case v.IsInt():
+		return fmt.Sprintf("int(%v)", v.AsInt())
+	case v.IsFloat():
+		return fmt.Sprintf("float(%v)", v.AsFloat())
+	case v.IsList():
+		var items []string
+		for i := 0; i < v.AsList().Length(); i++ {
+			items = append(items, describe(v.AsList().At(i)))
+		}
+		return "[" + strings.Join(items, ",") + "]"
+	case v.IsMap():
+		var items []string
+		v.AsMap().Iterate(func(key string, val value.Value) bool {
+			items = append(items, key+"="+describe(val))
+			return true
+		})
+		sort.Strings(items)
+		return "{" + strings.Join(items, ",") + "}"
+	}
+	return value.ToString(v)
+}
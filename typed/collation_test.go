@@ -0,0 +1,187 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package typed_test
+
+import (
+	"strings"
+	"testing"
+
+	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
+	"sigs.k8s.io/structured-merge-diff/v4/schema"
+	"sigs.k8s.io/structured-merge-diff/v4/typed"
+	"sigs.k8s.io/structured-merge-diff/v4/value"
+)
+
+func init() {
+	schema.RegisterCollation("trimmed", strings.TrimSpace)
+}
+
+func collationParser(collation string) *typed.Parser {
+	parser, err := typed.NewParser(typed.YAMLObject(`types:
+- name: object
+  map:
+    fields:
+    - name: hosts
+      type:
+        list:
+          elementType:
+            namedType: host
+          elementRelationship: associative
+          keys:
+          - name
+          - port
+          keyCollations:
+          - key: name
+            collation: ` + collation + `
+- name: host
+  map:
+    fields:
+    - name: name
+      type:
+        scalar: string
+    - name: port
+      type:
+        scalar: numeric
+    - name: alias
+      type:
+        scalar: string
+`))
+	if err != nil {
+		panic(err)
+	}
+	return parser
+}
+
+func TestKeyCollations(t *testing.T) {
+	pt := collationParser(string(schema.CaseInsensitive)).Type("object")
+
+	tv, err := pt.FromYAML(`{"hosts": [{"name": "Example.COM", "port": 80, "alias": "a"}]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	set, err := tv.ToFieldSet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	item := fieldpath.PathElement{Key: &value.FieldList{
+		{Name: "name", Value: value.NewValueInterface("example.com")},
+		{Name: "port", Value: value.NewValueInterface(80)},
+	}}
+	if !set.Has(fieldpath.MakePathOrDie("hosts", item, "alias")) {
+		t.Errorf("expected the field set to have the folded key, got:\n%v", set)
+	}
+
+	for _, names := range [][2]string{{"a", "A"}, {"straße", "STRASSE"}, {"\u212a", "k"}} {
+		object := typed.YAMLObject(`{"hosts": [{"name": "` + names[0] + `", "port": 80}, {"name": "` + names[1] + `", "port": 80}]}`)
+		if _, err := pt.FromYAML(object); err == nil {
+			t.Errorf("expected %q and %q, differing only by case, to be duplicates", names[0], names[1])
+		}
+	}
+
+	rhs, err := pt.FromYAML(`{"hosts": [{"name": "EXAMPLE.com", "port": 80}, {"name": "other", "port": 80}]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	merged, err := tv.Merge(rhs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, err := pt.FromYAML(`{"hosts": [{"name": "EXAMPLE.com", "port": 80, "alias": "a"}, {"name": "other", "port": 80}]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !value.Equals(merged.AsValue(), expected.AsValue()) {
+		t.Errorf("expected merged object %v, got %v", value.ToString(expected.AsValue()), value.ToString(merged.AsValue()))
+	}
+
+	comparison, err := tv.Compare(rhs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !comparison.Modified.Has(fieldpath.MakePathOrDie("hosts", item, "name")) {
+		t.Errorf("expected the name to be modified, got %v", comparison)
+	}
+}
+
+func TestCustomKeyCollation(t *testing.T) {
+	pt := collationParser("trimmed").Type("object")
+	if _, err := pt.FromYAML(`{"hosts": [{"name": "a", "port": 80}, {"name": " a ", "port": 80}]}`); err == nil {
+		t.Error("expected items differing only by spaces to be duplicates")
+	}
+	if _, err := pt.FromYAML(`{"hosts": [{"name": "a", "port": 80}, {"name": "A", "port": 80}]}`); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	pt = collationParser("unknown").Type("object")
+	_, err := pt.FromYAML(`{"hosts": [{"name": "a", "port": 80}]}`)
+	if err == nil || !strings.Contains(err.Error(), `unknown collation "unknown" for key field "name"`) {
+		t.Errorf("expected unknown collation error, got %v", err)
+	}
+}
+
+func TestKeyCollationsLookup(t *testing.T) {
+	pt := collationParser(string(schema.CaseInsensitive)).Type("object")
+	tv, err := pt.FromYAML(`{"hosts": [{"name": "Example.COM", "port": 80, "alias": "a"}]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	item := fieldpath.PathElement{Key: &value.FieldList{
+		{Name: "name", Value: value.NewValueInterface("example.com")},
+		{Name: "port", Value: value.NewValueInterface(80)},
+	}}
+	path := fieldpath.MakePathOrDie("hosts", item, "alias")
+	if v, ok := tv.Lookup(path); !ok || v.AsString() != "a" {
+		t.Errorf("expected to find alias a with the folded key, got %v", v)
+	}
+	if !item.MatchesItemCollated(value.NewValueInterface(map[string]interface{}{"name": "EXAMPLE.com", "port": 80}), []schema.KeyCollation{{Key: "name", Collation: schema.CaseInsensitive}}) {
+		t.Error("expected the item to match its folded key")
+	}
+}
+
+func TestLongKeyCollations(t *testing.T) {
+	pt := collationParser(string(schema.CaseInsensitive)).Type("object")
+	name := strings.Repeat("a", 2*fieldpath.MaxPathElementValueLength)
+	lower, err := pt.FromYAML(typed.YAMLObject(`{"hosts": [{"name": "` + name + `", "port": 80}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	upper, err := pt.FromYAML(typed.YAMLObject(`{"hosts": [{"name": "` + strings.ToUpper(name) + `", "port": 80}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lowerSet, err := lower.ToFieldSet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	upperSet, err := upper.ToFieldSet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The keys are folded before they are hashed.
+	if !lowerSet.Equals(upperSet) {
+		t.Errorf("expected equivalent long keys to have the same hash, got:\n%v\nand:\n%v", lowerSet, upperSet)
+	}
+	var item fieldpath.PathElement
+	lowerSet.Iterate(func(p fieldpath.Path) {
+		if len(p) > 1 {
+			item = p[1]
+		}
+	})
+	if _, ok := upper.Lookup(fieldpath.MakePathOrDie("hosts", item)); !ok {
+		t.Errorf("expected to find the item of the hashed key %v", item)
+	}
+}
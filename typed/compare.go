@@ -18,6 +18,7 @@ package typed
 
 import (
 	"fmt"
+	"math"
 	"strings"
 
 	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
@@ -72,6 +73,39 @@ func (c *Comparison) ExcludeFields(fields *fieldpath.Set) *Comparison {
 	return c
 }
 
+// ApplyTo replays the comparison onto target: the fields removed by rhs are
+// removed from target, and the fields added or modified by rhs are set to
+// their value in rhs. Lists are changed according to the schema, e.g. the
+// items of associative lists are matched by key, while atomic lists are
+// replaced as a whole. Fields excluded or filtered out of the comparison are
+// left alone.
+//
+// This allows computing a diff between two objects, and applying it to a
+// third one. rhs is the right-hand-side object of the comparison, which the
+// added and modified values are taken from; the comparison doesn't keep it,
+// so that the comparisons which are kept, e.g. by a CompareCache, don't keep
+// the objects alive. target must have the same type as rhs.
+func (c *Comparison) ApplyTo(rhs, target *TypedValue) (*TypedValue, error) {
+	if rhs == nil {
+		return nil, errorf("the right-hand-side object of the comparison is required")
+	}
+	if target.schema != rhs.schema {
+		return nil, errorf("expected objects with types from the same schema")
+	}
+	if !target.typeRef.Equals(&rhs.typeRef) {
+		return nil, errorf("expected objects of the same type, but got %v and %v", target.typeRef, rhs.typeRef)
+	}
+	out := target.RemoveItems(c.Removed)
+	// Only the leaves are extracted, their parents come with them.
+	// ExtractItems doesn't keep the value of a field if the set contains
+	// both the field and its children.
+	changed := c.Added.Union(c.Modified).Leaves()
+	if changed.Empty() {
+		return out, nil
+	}
+	return out.Merge(rhs.ExtractItems(changed, WithAppendKeyFields()))
+}
+
 func (c *Comparison) FilterFields(filter fieldpath.Filter) *Comparison {
 	if filter == nil {
 		return c
@@ -82,6 +116,28 @@ func (c *Comparison) FilterFields(filter fieldpath.Filter) *Comparison {
 	return c
 }
 
+// CompareValues compares two values of unknown type, with the same path
+// vocabulary as TypedValue.Compare. It uses the semantics of
+// DeducedParseableType: maps are compared field by field, while lists and
+// scalars are compared as a whole. A nil value is treated as null.
+func CompareValues(lhs, rhs value.Value) (*Comparison, error) {
+	if lhs == nil {
+		lhs = value.NewValueInterface(nil)
+	}
+	if rhs == nil {
+		rhs = value.NewValueInterface(nil)
+	}
+	l, err := AsTyped(lhs, DeducedParseableType.Schema, DeducedParseableType.TypeRef)
+	if err != nil {
+		return nil, fmt.Errorf("lhs: %v", err)
+	}
+	r, err := AsTyped(rhs, DeducedParseableType.Schema, DeducedParseableType.TypeRef)
+	if err != nil {
+		return nil, fmt.Errorf("rhs: %v", err)
+	}
+	return l.Compare(r)
+}
+
 type compareWalker struct {
 	lhs     value.Value
 	rhs     value.Value
@@ -94,8 +150,28 @@ type compareWalker struct {
 	// Resulting comparison.
 	comparison *Comparison
 
+	// Numeric scalars which differ by at most floatTolerance are equal.
+	floatTolerance float64
+	// Strings are compared once normalized by the first matching
+	// normalizer.
+	stringNormalizers []StringNormalizer
+	// Untyped leaves are compared with untypedComparator, if set, and
+	// can't have more than untypedMaxNodes nodes, if positive.
+	untypedComparator UntypedComparator
+	untypedMaxNodes   int
+	// Long associative lists are compared by chunks, if enabled.
+	listChunking ListChunking
+	// If set, holds the keys of the items of the lists.
+	index *ListIndex
+	// If set, the deferred validations of lhs and rhs, which are validated
+	// as they are walked, see AsTypedLazy.
+	lhsLazy *lazyValidation
+	rhsLazy *lazyValidation
+
 	// internal housekeeping--don't set when constructing.
-	inLeaf bool // Set to true if we're in a "big leaf"--atomic map/list
+	inLeaf    bool // Set to true if we're in a "big leaf"--atomic map/list
+	untyped   bool // Set to true if the current type is untyped
+	descended bool // Set to true if we've descended into the items
 
 	// Allocate only as many walkers as needed for the depth by storing them here.
 	spareWalkers *[]*compareWalker
@@ -113,9 +189,16 @@ func (w *compareWalker) compare(prefixFn func() string) (errs ValidationErrors)
 	if !ok {
 		return errorf("schema error: no type found matching: %v", *w.typeRef.NamedType)
 	}
+	errs = append(w.lhsLazy.before(w.schema, w.typeRef, w.lhs), w.rhsLazy.before(w.schema, w.typeRef, w.rhs)...)
+	if len(errs) > 0 {
+		return errs.WithLazyPrefix(prefixFn)
+	}
 
 	alhs := deduceAtom(a, w.lhs)
 	arhs := deduceAtom(a, w.rhs)
+	if !w.inLeaf {
+		w.untyped = a.Scalar != nil && *a.Scalar == schema.Untyped
+	}
 
 	// deduceAtom does not fix the type for nil values
 	// nil is a wildcard and will accept whatever form the other operand takes
@@ -124,10 +207,16 @@ func (w *compareWalker) compare(prefixFn func() string) (errs ValidationErrors)
 	} else if w.lhs == nil || alhs.Equals(&arhs) {
 		errs = append(errs, handleAtom(arhs, w.typeRef, w)...)
 	} else {
+		// lhs and rhs aren't walked together, validate all of lhs.
+		lhsLazy := w.lhsLazy
+		w.lhsLazy = nil
 		w2 := *w
 		errs = append(errs, handleAtom(alhs, w.typeRef, &w2)...)
 		errs = append(errs, handleAtom(arhs, w.typeRef, w)...)
+		errs = append(errs, lhsLazy.after(w.schema, w.typeRef, w.lhs, false)...)
 	}
+	errs = append(errs, w.lhsLazy.after(w.schema, w.typeRef, w.lhs, w.descended)...)
+	errs = append(errs, w.rhsLazy.after(w.schema, w.typeRef, w.rhs, w.descended)...)
 
 	if !w.inLeaf {
 		if w.lhs == nil {
@@ -141,11 +230,11 @@ func (w *compareWalker) compare(prefixFn func() string) (errs ValidationErrors)
 
 // doLeaf should be called on leaves before descending into children, if there
 // will be a descent. It modifies w.inLeaf.
-func (w *compareWalker) doLeaf() {
+func (w *compareWalker) doLeaf() ValidationErrors {
 	if w.inLeaf {
 		// We're in a "big leaf", an atomic map or list. Ignore
 		// subsequent leaves.
-		return
+		return nil
 	}
 	w.inLeaf = true
 
@@ -154,11 +243,63 @@ func (w *compareWalker) doLeaf() {
 		w.comparison.Added.Insert(w.path)
 	} else if w.rhs == nil {
 		w.comparison.Removed.Insert(w.path)
-	} else if !value.EqualsUsing(w.allocator, w.rhs, w.lhs) {
-		// TODO: Equality is not sufficient for this.
-		// Need to implement equality check on the value type.
-		w.comparison.Modified.Insert(w.path)
+	} else {
+		modified, err := w.leafModified()
+		if err != nil {
+			return err
+		}
+		if modified {
+			w.comparison.Modified.Insert(w.path)
+		}
+	}
+	return nil
+}
+
+// leafModified returns true if lhs and rhs, the values of a leaf, differ.
+func (w *compareWalker) leafModified() (bool, ValidationErrors) {
+	if w.untyped && (w.untypedComparator != nil || w.untypedMaxNodes > 0) {
+		equal, err := w.untypedEquals()
+		return !equal, err
 	}
+	// TODO: Equality is not sufficient for this.
+	// Need to implement equality check on the value type.
+	return !value.EqualsUsing(w.allocator, w.rhs, w.lhs) && !w.withinTolerance() && !w.normalizedEquals(), nil
+}
+
+// withinTolerance returns true if lhs and rhs are numbers which differ by
+// at most floatTolerance.
+func (w *compareWalker) withinTolerance() bool {
+	if w.floatTolerance <= 0 {
+		return false
+	}
+	l, ok := asFloat(w.lhs)
+	if !ok {
+		return false
+	}
+	r, ok := asFloat(w.rhs)
+	if !ok {
+		return false
+	}
+	return math.Abs(l-r) <= w.floatTolerance
+}
+
+// normalizedEquals returns true if lhs and rhs are strings which are equal
+// once normalized.
+func (w *compareWalker) normalizedEquals() bool {
+	if len(w.stringNormalizers) == 0 || !w.lhs.IsString() || !w.rhs.IsString() {
+		return false
+	}
+	return normalizedEquals(w.stringNormalizers, w.path, w.lhs.AsString(), w.rhs.AsString())
+}
+
+func asFloat(v value.Value) (float64, bool) {
+	switch {
+	case v.IsFloat():
+		return v.AsFloat(), true
+	case v.IsInt():
+		return float64(v.AsInt()), true
+	}
+	return 0, false
 }
 
 func (w *compareWalker) doScalar(t *schema.Scalar) ValidationErrors {
@@ -170,9 +311,7 @@ func (w *compareWalker) doScalar(t *schema.Scalar) ValidationErrors {
 	}
 
 	// All scalars are leaf fields.
-	w.doLeaf()
-
-	return nil
+	return w.doLeaf()
 }
 
 func (w *compareWalker) prepareDescent(pe fieldpath.PathElement, tr schema.TypeRef, cmp *Comparison) *compareWalker {
@@ -192,6 +331,7 @@ func (w *compareWalker) prepareDescent(pe fieldpath.PathElement, tr schema.TypeR
 	w2.lhs = nil
 	w2.rhs = nil
 	w2.comparison = cmp
+	w2.descended = false
 	return w2
 }
 
@@ -222,6 +362,10 @@ func (w *compareWalker) visitListItems(t *schema.List, lhs, rhs value.List) (err
 	if lhs != nil {
 		lLen = lhs.Length()
 	}
+	if w.listChunking.enabled(lLen, rLen) {
+		return w.visitListItemsChunked(t, lhs, rhs, lLen, rLen)
+	}
+	w.descended = true
 
 	maxLength := rLen
 	if lLen > maxLength {
@@ -233,9 +377,10 @@ func (w *compareWalker) visitListItems(t *schema.List, lhs, rhs value.List) (err
 
 	// Gather all the elements from lhs, indexed by PE, in a list for duplicates.
 	lValues := fieldpath.MakePathElementMap(lLen)
+	lKeys := w.index.listItemKeys(w.allocator, w.schema, w.typeRef, t, w.lhs, lhs)
 	for i := 0; i < lLen; i++ {
 		child := lhs.At(i)
-		pe, err := listItemToPathElement(w.allocator, w.schema, t, child)
+		pe, err := lKeys.at(i, child)
 		if err != nil {
 			errs = append(errs, errorf("element %v: %v", i, err.Error())...)
 			// If we can't construct the path element, we can't
@@ -255,9 +400,10 @@ func (w *compareWalker) visitListItems(t *schema.List, lhs, rhs value.List) (err
 
 	// Gather all the elements from rhs, indexed by PE, in a list for duplicates.
 	rValues := fieldpath.MakePathElementMap(rLen)
+	rKeys := w.index.listItemKeys(w.allocator, w.schema, w.typeRef, t, w.rhs, rhs)
 	for i := 0; i < rLen; i++ {
 		rValue := rhs.At(i)
-		pe, err := listItemToPathElement(w.allocator, w.schema, t, rValue)
+		pe, err := rKeys.at(i, rValue)
 		if err != nil {
 			errs = append(errs, errorf("element %v: %v", i, err.Error())...)
 			// If we can't construct the path element, we can't
@@ -347,9 +493,10 @@ func (w *compareWalker) indexListPathElements(t *schema.List, list value.List) (
 	}
 	observed := fieldpath.MakePathElementValueMap(length)
 	pes := make([]fieldpath.PathElement, 0, length)
+	folders := keyFolders(t)
 	for i := 0; i < length; i++ {
 		child := list.At(i)
-		pe, err := listItemToPathElement(w.allocator, w.schema, t, child)
+		pe, err := listItemToPathElement(w.allocator, w.schema, t, folders, child)
 		if err != nil {
 			errs = append(errs, errorf("element %v: %v", i, err.Error())...)
 			// If we can't construct the path element, we can't
@@ -368,7 +515,11 @@ func (w *compareWalker) indexListPathElements(t *schema.List, list value.List) (
 }
 
 func (w *compareWalker) compareListItem(t *schema.List, pe fieldpath.PathElement, lChild, rChild value.Value) ValidationErrors {
-	w2 := w.prepareDescent(pe, t.ElementType, w.comparison)
+	child := rChild
+	if child == nil {
+		child = lChild
+	}
+	w2 := w.prepareDescent(pe, listElementType(w.allocator, t, child), w.comparison)
 	w2.lhs = lChild
 	w2.rhs = rChild
 	errs := w2.compare(pe.String)
@@ -388,6 +539,11 @@ func (w *compareWalker) derefList(prefix string, v value.Value) (value.List, Val
 }
 
 func (w *compareWalker) doList(t *schema.List) (errs ValidationErrors) {
+	// Values that share their data are equal, there is no need to walk
+	// them.
+	if value.Same(w.lhs, w.rhs) {
+		return nil
+	}
 	lhs, _ := w.derefList("lhs: ", w.lhs)
 	if lhs != nil {
 		defer w.allocator.Free(lhs)
@@ -403,8 +559,7 @@ func (w *compareWalker) doList(t *schema.List) (errs ValidationErrors) {
 	emptyPromoteToLeaf := (lhs == nil || lhs.Length() == 0) && (rhs == nil || rhs.Length() == 0)
 
 	if t.ElementRelationship == schema.Atomic || emptyPromoteToLeaf {
-		w.doLeaf()
-		return nil
+		return w.doLeaf()
 	}
 
 	if lhs == nil && rhs == nil {
@@ -433,6 +588,7 @@ func (w *compareWalker) visitMapItem(t *schema.Map, out map[string]interface{},
 func (w *compareWalker) visitMapItems(t *schema.Map, lhs, rhs value.Map) (errs ValidationErrors) {
 	out := map[string]interface{}{}
 
+	w.descended = true
 	value.MapZipUsing(w.allocator, lhs, rhs, value.Unordered, func(key string, lhsValue, rhsValue value.Value) bool {
 		errs = append(errs, w.visitMapItem(t, out, key, lhsValue, rhsValue)...)
 		return true
@@ -442,6 +598,11 @@ func (w *compareWalker) visitMapItems(t *schema.Map, lhs, rhs value.Map) (errs V
 }
 
 func (w *compareWalker) doMap(t *schema.Map) (errs ValidationErrors) {
+	// Values that share their data are equal, there is no need to walk
+	// them.
+	if value.Same(w.lhs, w.rhs) {
+		return nil
+	}
 	lhs, _ := w.derefMap("lhs: ", w.lhs)
 	if lhs != nil {
 		defer w.allocator.Free(lhs)
@@ -456,8 +617,7 @@ func (w *compareWalker) doMap(t *schema.Map) (errs ValidationErrors) {
 	emptyPromoteToLeaf := (lhs == nil || lhs.Empty()) && (rhs == nil || rhs.Empty())
 
 	if t.ElementRelationship == schema.Atomic || emptyPromoteToLeaf {
-		w.doLeaf()
-		return nil
+		return w.doLeaf()
 	}
 
 	if lhs == nil && rhs == nil {
@@ -0,0 +1,110 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package typed
+
+import (
+	"sync"
+
+	"sigs.k8s.io/structured-merge-diff/v4/internal/lru"
+	"sigs.k8s.io/structured-merge-diff/v4/value"
+)
+
+// CompareCache memoizes the comparisons of objects by the hashes of their
+// content (see value.HashOf), for callers which compare the same pairs of
+// objects again and again, e.g. controllers diffing their objects on every
+// resync. It is safe for concurrent use.
+//
+// The comparisons returned for equal pairs of objects share their sets of
+// fields, which must not be modified.
+type CompareCache struct {
+	opts []CompareOption
+
+	lock    sync.Mutex
+	entries *lru.Cache
+	stats   CompareCacheStats
+}
+
+// CompareCacheStats are the statistics of a CompareCache.
+type CompareCacheStats struct {
+	// Hits is the number of comparisons found in the cache.
+	Hits int64
+	// Misses is the number of comparisons computed since they weren't in
+	// the cache.
+	Misses int64
+	// Evictions is the number of entries evicted to make room for others.
+	Evictions int64
+	// Entries is the current number of entries.
+	Entries int
+}
+
+// NewCompareCache returns a cache of at most maxEntries comparisons, or of
+// an unbounded number of them if it isn't positive, made with opts.
+func NewCompareCache(maxEntries int, opts ...CompareOption) *CompareCache {
+	return &CompareCache{opts: opts, entries: lru.New(maxEntries)}
+}
+
+// compareKey identifies a comparison of objects of a given type.
+type compareKey struct {
+	typeKey
+	lhs, rhs value.Hash
+}
+
+// Compare returns lhs.Compare(rhs), with the options of the cache, from the
+// cache if the same contents were already compared.
+func (c *CompareCache) Compare(lhs, rhs *TypedValue) (*Comparison, error) {
+	if lhs.schema != rhs.schema || !lhs.typeRef.Equals(&rhs.typeRef) {
+		// Let Compare report it.
+		return lhs.Compare(rhs, c.opts...)
+	}
+	key := compareKey{
+		typeKey: keyOfType(lhs.schema, lhs.typeRef),
+		lhs:     value.HashOf(lhs.value),
+		rhs:     value.HashOf(rhs.value),
+	}
+	c.lock.Lock()
+	cached, ok := c.entries.Get(key)
+	if ok {
+		c.stats.Hits++
+	} else {
+		c.stats.Misses++
+	}
+	c.lock.Unlock()
+	if ok {
+		comparison := *cached.(*Comparison)
+		return &comparison, nil
+	}
+	comparison, err := lhs.Compare(rhs, c.opts...)
+	if err != nil {
+		return nil, err
+	}
+	// ExcludeFields and FilterFields change the comparison they are
+	// called on, so the caller doesn't get the cached one.
+	stored := *comparison
+	c.lock.Lock()
+	c.stats.Evictions += int64(c.entries.Add(key, &stored))
+	c.lock.Unlock()
+	return comparison, nil
+}
+
+// Stats returns the statistics of the cache.
+func (c *CompareCache) Stats() CompareCacheStats {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	stats := c.stats
+	stats.Entries = c.entries.Len()
+	return stats
+}
@@ -0,0 +1,93 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package typed_test
+
+import (
+	"testing"
+
+	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
+	"sigs.k8s.io/structured-merge-diff/v4/typed"
+)
+
+func TestCompareCache(t *testing.T) {
+	pt := overlayParser.Type("service")
+	parse := func(object typed.YAMLObject) *typed.TypedValue {
+		tv, err := pt.FromYAML(object)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tv
+	}
+	cache := typed.NewCompareCache(2, typed.WithIgnoredFields(_NS(_P("labels"))))
+	tests := []struct {
+		lhs, rhs typed.YAMLObject
+		expected typed.CompareCacheStats
+	}{
+		{`{"name": "a"}`, `{"name": "b", "labels": {"a": "b"}}`, typed.CompareCacheStats{Misses: 1, Entries: 1}},
+		// Equal objects, parsed again.
+		{`{"name": "a"}`, `{"name": "b", "labels": {"a": "b"}}`, typed.CompareCacheStats{Hits: 1, Misses: 1, Entries: 1}},
+		{`{"name": "a"}`, `{"name": "c"}`, typed.CompareCacheStats{Hits: 1, Misses: 2, Entries: 2}},
+		{`{"name": "c"}`, `{"name": "a"}`, typed.CompareCacheStats{Hits: 1, Misses: 3, Evictions: 1, Entries: 2}},
+		{`{"name": "a"}`, `{"name": "b", "labels": {"a": "b"}}`, typed.CompareCacheStats{Hits: 1, Misses: 4, Evictions: 2, Entries: 2}},
+	}
+	for i, tt := range tests {
+		lhs, rhs := parse(tt.lhs), parse(tt.rhs)
+		got, err := cache.Compare(lhs, rhs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected, err := lhs.Compare(rhs, typed.WithIgnoredFields(_NS(_P("labels"))))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.String() != expected.String() {
+			t.Errorf("%v: expected comparison:\n%v\ngot:\n%v", i, expected, got)
+		}
+		if stats := cache.Stats(); stats != tt.expected {
+			t.Errorf("%v: expected stats %+v, got %+v", i, tt.expected, stats)
+		}
+	}
+}
+
+func TestCompareCacheResultsAreCopies(t *testing.T) {
+	pt := overlayParser.Type("service")
+	lhs, err := pt.FromYAML(`{"name": "a", "labels": {"a": "b"}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rhs, err := pt.FromYAML(`{"name": "b", "labels": {"a": "c"}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache := typed.NewCompareCache(0)
+	for i := 0; i < 2; i++ {
+		got, err := cache.Compare(lhs, rhs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := _NS(_P("name"), _P("labels", "a"))
+		if !got.Modified.Equals(expected) {
+			t.Errorf("%v: expected %v to be modified, got:\n%v", i, expected, got)
+		}
+		// Filtering a result doesn't change the cached comparison.
+		got.ExcludeFields(_NS(_P("name")))
+		got.FilterFields(fieldpath.NewExcludeSetFilter(_NS(_P("labels"))))
+	}
+	if stats := cache.Stats(); stats.Hits != 1 || stats.Misses != 1 {
+		t.Errorf("expected a miss then a hit, got %+v", stats)
+	}
+}
@@ -1,10 +1,12 @@
 package typed_test
 
 import (
+	"fmt"
 	"testing"
 
 	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
 	"sigs.k8s.io/structured-merge-diff/v4/typed"
+	"sigs.k8s.io/structured-merge-diff/v4/value"
 )
 
 func TestComparisonExcludeFields(t *testing.T) {
@@ -74,3 +76,382 @@ func TestComparisonExcludeFields(t *testing.T) {
 		})
 	}
 }
+
+func TestCompareValues(t *testing.T) {
+	cases := []struct {
+		name   string
+		lhs    string
+		rhs    string
+		Expect *typed.Comparison
+	}{
+		{
+			name: "same",
+			lhs:  `{"a": 1, "b": [1, 2], "c": {"d": "e"}}`,
+			rhs:  `{"c": {"d": "e"}, "b": [1, 2], "a": 1}`,
+			Expect: &typed.Comparison{
+				Added:    fieldpath.NewSet(),
+				Modified: fieldpath.NewSet(),
+				Removed:  fieldpath.NewSet(),
+			},
+		},
+		{
+			name: "maps are compared by field, lists as a whole",
+			lhs:  `{"a": 1, "b": [1, 2], "c": {"d": "e", "f": "g"}}`,
+			rhs:  `{"b": [2, 1], "c": {"d": "x", "h": "i"}, "j": null}`,
+			Expect: &typed.Comparison{
+				Added:    fieldpath.NewSet(fieldpath.MakePathOrDie("c", "h"), fieldpath.MakePathOrDie("j")),
+				Modified: fieldpath.NewSet(fieldpath.MakePathOrDie("b"), fieldpath.MakePathOrDie("c", "d")),
+				Removed:  fieldpath.NewSet(fieldpath.MakePathOrDie("a"), fieldpath.MakePathOrDie("c", "f")),
+			},
+		},
+		{
+			name: "from nothing",
+			rhs:  `{"a": {"b": 1}}`,
+			Expect: &typed.Comparison{
+				Added:    fieldpath.NewSet(fieldpath.MakePathOrDie("a"), fieldpath.MakePathOrDie("a", "b")),
+				Modified: fieldpath.NewSet(),
+				Removed:  fieldpath.NewSet(),
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var lhs, rhs value.Value
+			var err error
+			if c.lhs != "" {
+				if lhs, err = value.FromJSON([]byte(c.lhs)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if rhs, err = value.FromJSON([]byte(c.rhs)); err != nil {
+				t.Fatal(err)
+			}
+			got, err := typed.CompareValues(lhs, rhs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !got.Added.Equals(c.Expect.Added) ||
+				!got.Modified.Equals(c.Expect.Modified) ||
+				!got.Removed.Equals(c.Expect.Removed) {
+				t.Fatalf("expected:\n%v\ngot:\n%v\n", c.Expect, got)
+			}
+		})
+	}
+}
+
+func TestComparisonApplyTo(t *testing.T) {
+	cases := []struct {
+		name     string
+		lhs      typed.YAMLObject
+		rhs      typed.YAMLObject
+		target   typed.YAMLObject
+		expected typed.YAMLObject
+	}{
+		{
+			name:     "no changes",
+			lhs:      `{"name": "a"}`,
+			rhs:      `{"name": "a"}`,
+			target:   `{"name": "b", "extra": "x"}`,
+			expected: `{"name": "b", "extra": "x"}`,
+		},
+		{
+			name:     "fields",
+			lhs:      `{"name": "a", "enabled": true}`,
+			rhs:      `{"name": "a2", "extra": "x"}`,
+			target:   `{"name": "b", "enabled": false, "other": "y"}`,
+			expected: `{"name": "a2", "extra": "x", "other": "y"}`,
+		},
+		{
+			name: "associative list",
+			lhs: `{"containers": [
+				{"name": "a", "image": "a:1"},
+				{"name": "b", "image": "b:1"}
+			]}`,
+			rhs: `{"containers": [
+				{"name": "a", "image": "a:2"},
+				{"name": "c", "image": "c:1"}
+			]}`,
+			target: `{"containers": [
+				{"name": "b", "image": "b:0"},
+				{"name": "a", "image": "a:0"},
+				{"name": "d", "image": "d:0"}
+			]}`,
+			expected: `{"containers": [
+				{"name": "a", "image": "a:2"},
+				{"name": "d", "image": "d:0"},
+				{"name": "c", "image": "c:1"}
+			]}`,
+		},
+		{
+			name:     "atomic list",
+			lhs:      `{"args": ["p", "q"]}`,
+			rhs:      `{"args": ["p", "r"]}`,
+			target:   `{"args": ["s"]}`,
+			expected: `{"args": ["p", "r"]}`,
+		},
+	}
+
+	pt := documentParser.Type("root")
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			lhs, err := pt.FromYAML(c.lhs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rhs, err := pt.FromYAML(c.rhs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			target, err := pt.FromYAML(c.target)
+			if err != nil {
+				t.Fatal(err)
+			}
+			expected, err := pt.FromYAML(c.expected)
+			if err != nil {
+				t.Fatal(err)
+			}
+			comparison, err := lhs.Compare(rhs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := comparison.ApplyTo(rhs, target)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !value.EqualsUsing(value.NewFreelistAllocator(), got.AsValue(), expected.AsValue()) {
+				t.Errorf("expected:\n%v\ngot:\n%v", value.ToString(expected.AsValue()), value.ToString(got.AsValue()))
+			}
+		})
+	}
+
+	comparison := &typed.Comparison{
+		Added:    fieldpath.NewSet(fieldpath.MakePathOrDie("name")),
+		Modified: fieldpath.NewSet(),
+		Removed:  fieldpath.NewSet(),
+	}
+	target, err := pt.FromYAML(`{"name": "a"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := comparison.ApplyTo(nil, target); err == nil {
+		t.Error("expected an error without the right-hand-side object")
+	}
+}
+
+// mostlyIdenticalObjects returns an object, and a copy of it with one field
+// changed which shares the rest of its data if shallow is set.
+func mostlyIdenticalObjects(shallow bool) (*typed.TypedValue, *typed.TypedValue, error) {
+	makeItems := func() map[string]interface{} {
+		items := map[string]interface{}{}
+		for i := 0; i < 1000; i++ {
+			items[fmt.Sprintf("item%d", i)] = map[string]interface{}{
+				"name":   fmt.Sprintf("item%d", i),
+				"values": []interface{}{int64(i), int64(i + 1)},
+				"nested": map[string]interface{}{"a": "b", "c": "d"},
+			}
+		}
+		return items
+	}
+	items := makeItems()
+	lhs := map[string]interface{}{"items": items, "status": "old"}
+	rhs := map[string]interface{}{"items": items, "status": "new"}
+	if !shallow {
+		rhs["items"] = makeItems()
+	}
+	l, err := typed.DeducedParseableType.FromUnstructured(lhs)
+	if err != nil {
+		return nil, nil, err
+	}
+	r, err := typed.DeducedParseableType.FromUnstructured(rhs)
+	if err != nil {
+		return nil, nil, err
+	}
+	return l, r, nil
+}
+
+func TestCompareSharedData(t *testing.T) {
+	for _, shallow := range []bool{true, false} {
+		lhs, rhs, err := mostlyIdenticalObjects(shallow)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c, err := lhs.Compare(rhs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !c.Modified.Equals(fieldpath.NewSet(fieldpath.MakePathOrDie("status"))) || !c.Added.Empty() || !c.Removed.Empty() {
+			t.Errorf("shallow=%v: expected only .status to be modified, got:\n%v", shallow, c)
+		}
+	}
+}
+
+func TestCompareFingerprints(t *testing.T) {
+	pt := typed.DeducedParseableType
+	// The objects differ, but their fingerprints claim they are equal: the
+	// comparison trusts them and doesn't walk the objects.
+	lhs, err := typed.AsTyped(value.WithFingerprint(value.NewValueInterface(map[string]interface{}{"a": 1}), "x"), pt.Schema, pt.TypeRef)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rhs, err := typed.AsTyped(value.WithFingerprint(value.NewValueInterface(map[string]interface{}{"a": 2}), "x"), pt.Schema, pt.TypeRef)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := lhs.Compare(rhs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !c.IsSame() {
+		t.Errorf("expected objects with the same fingerprint to be the same, got:\n%v", c)
+	}
+}
+
+func TestCompareWithIgnoredFields(t *testing.T) {
+	lhs, err := typed.DeducedParseableType.FromYAML(`{"a": 1, "b": {"c": 1}, "e": 1}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rhs, err := typed.DeducedParseableType.FromYAML(`{"a": 2, "b": {"c": 2}, "d": 1}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := lhs.Compare(rhs, typed.WithIgnoredFields(fieldpath.NewSet(
+		fieldpath.MakePathOrDie("b"),
+		fieldpath.MakePathOrDie("e"),
+	)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !c.Modified.Equals(fieldpath.NewSet(fieldpath.MakePathOrDie("a"))) ||
+		!c.Added.Equals(fieldpath.NewSet(fieldpath.MakePathOrDie("d"))) ||
+		!c.Removed.Empty() {
+		t.Errorf("expected .a to be modified and .d to be added, got:\n%v", c)
+	}
+}
+
+func TestCompareWithFloatTolerance(t *testing.T) {
+	lhs, err := typed.DeducedParseableType.FromYAML(`{"a": 1, "b": 0.1, "c": 1, "d": "1"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rhs, err := typed.DeducedParseableType.FromYAML(`{"a": 1.0, "b": 0.1000001, "c": 1.1, "d": "1.0"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := lhs.Compare(rhs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := fieldpath.NewSet(
+		fieldpath.MakePathOrDie("b"),
+		fieldpath.MakePathOrDie("c"),
+		fieldpath.MakePathOrDie("d"),
+	)
+	if !c.Modified.Equals(expected) {
+		t.Errorf("expected modified fields:\n%v\ngot:\n%v", expected, c.Modified)
+	}
+	c, err = lhs.Compare(rhs, typed.WithFloatTolerance(1e-3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = fieldpath.NewSet(
+		fieldpath.MakePathOrDie("c"),
+		fieldpath.MakePathOrDie("d"),
+	)
+	if !c.Modified.Equals(expected) {
+		t.Errorf("expected modified fields with tolerance:\n%v\ngot:\n%v", expected, c.Modified)
+	}
+}
+
+func BenchmarkCompareMostlyIdentical(b *testing.B) {
+	for _, bc := range []struct {
+		name    string
+		shallow bool
+	}{{"shared", true}, {"copied", false}} {
+		b.Run(bc.name, func(b *testing.B) {
+			lhs, rhs, err := mostlyIdenticalObjects(bc.shallow)
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := lhs.Compare(rhs); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestCompareDualTypes(t *testing.T) {
+	parser, err := typed.NewParser(`types:
+- name: service
+  map:
+    fields:
+    - name: targetPort
+      type:
+        scalar: numericOrString
+    - name: selector
+      type:
+        scalar: string
+        map:
+          elementType:
+            scalar: string
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pt := parser.Type("service")
+	tests := []struct {
+		lhs, rhs                 typed.YAMLObject
+		modified, added, removed *fieldpath.Set
+	}{
+		{
+			lhs:      `{"targetPort": 8080, "selector": {"app": "web"}}`,
+			rhs:      `{"targetPort": 8080, "selector": {"app": "api"}}`,
+			modified: _NS(_P("selector", "app")),
+		}, {
+			lhs:      `{"targetPort": 8080}`,
+			rhs:      `{"targetPort": "8080"}`,
+			modified: _NS(_P("targetPort")),
+		}, {
+			lhs:      `{"selector": "app=web"}`,
+			rhs:      `{"selector": {"app": "web"}}`,
+			modified: _NS(_P("selector")),
+			added:    _NS(_P("selector", "app")),
+		}, {
+			lhs:      `{"selector": {"app": "web"}}`,
+			rhs:      `{"selector": "app=web"}`,
+			modified: _NS(_P("selector")),
+			removed:  _NS(_P("selector", "app")),
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(string(tt.lhs)+" "+string(tt.rhs), func(t *testing.T) {
+			lhs, err := pt.FromYAML(tt.lhs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rhs, err := pt.FromYAML(tt.rhs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			c, err := lhs.Compare(rhs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, s := range []**fieldpath.Set{&tt.modified, &tt.added, &tt.removed} {
+				if *s == nil {
+					*s = _NS()
+				}
+			}
+			if !c.Modified.Equals(tt.modified) || !c.Added.Equals(tt.added) || !c.Removed.Equals(tt.removed) {
+				t.Errorf("expected modified %v, added %v and removed %v, got:\n%v", tt.modified, tt.added, tt.removed, c)
+			}
+		})
+	}
+}
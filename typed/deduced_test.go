@@ -206,6 +206,7 @@ func TestMergeDeduced(t *testing.T) {
 						value.ToString(out.AsValue()), value.ToString(got.AsValue()),
 					)
 				}
+				checkMergeAndCompare(t, lhs, rhs, got)
 			}
 		})
 	}
@@ -0,0 +1,134 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package typed
+
+import (
+	"sigs.k8s.io/structured-merge-diff/v4/schema"
+	"sigs.k8s.io/structured-merge-diff/v4/value"
+)
+
+// WithDefaults returns a copy of tv where the absent fields of its maps are
+// set to their Default in the schema, if any. Fields set to null are kept.
+// tv itself is never modified, the maps and lists containing a default are
+// copied.
+func (tv *TypedValue) WithDefaults() *TypedValue {
+	w := defaultingWalker{schema: tv.schema, allocator: value.NewFreelistAllocator()}
+	if out, changed := w.walk(tv.typeRef, tv.value); changed {
+		return tv.withValue(value.NewValueInterface(out))
+	}
+	return tv
+}
+
+type defaultingWalker struct {
+	schema    *schema.Schema
+	allocator value.Allocator
+}
+
+// walk returns the unstructured value of v with the defaults set, and
+// whether any was set. The returned value is only meaningful if it was.
+func (w *defaultingWalker) walk(tr schema.TypeRef, v value.Value) (interface{}, bool) {
+	if v == nil || v.IsNull() {
+		return nil, false
+	}
+	a, ok := w.schema.Resolve(tr)
+	if !ok {
+		return nil, false
+	}
+	a = deduceAtom(a, v)
+	switch {
+	case a.List != nil && v.IsList():
+		return w.list(a.List, v.AsListUsing(w.allocator))
+	case a.Map != nil && v.IsMap():
+		return w.mapValue(a.Map, v.AsMapUsing(w.allocator))
+	}
+	return nil, false
+}
+
+func (w *defaultingWalker) list(t *schema.List, l value.List) (interface{}, bool) {
+	defer w.allocator.Free(l)
+	var out []interface{}
+	for i := 0; i < l.Length(); i++ {
+		item := l.AtUsing(w.allocator, i)
+		defaulted, changed := w.walk(listElementType(w.allocator, t, item), item)
+		if changed && out == nil {
+			out = make([]interface{}, l.Length())
+			for j := 0; j < i; j++ {
+				out[j] = l.At(j).Unstructured()
+			}
+		}
+		if out != nil {
+			if !changed {
+				defaulted = item.Unstructured()
+			}
+			out[i] = defaulted
+		}
+		w.allocator.Free(item)
+	}
+	return out, out != nil
+}
+
+func (w *defaultingWalker) mapValue(t *schema.Map, m value.Map) (interface{}, bool) {
+	defer w.allocator.Free(m)
+	changes := map[string]interface{}{}
+	for _, sf := range t.Fields {
+		if sf.Default != nil && !m.Has(sf.Name) {
+			changes[sf.Name] = copyDefault(sf.Default)
+		}
+	}
+	m.IterateUsing(w.allocator, func(key string, val value.Value) bool {
+		tr := t.ElementType
+		if sf, ok := t.FindField(key); ok {
+			tr = sf.Type
+		}
+		if defaulted, changed := w.walk(tr, val); changed {
+			changes[key] = defaulted
+		}
+		return true
+	})
+	if len(changes) == 0 {
+		return nil, false
+	}
+	out := make(map[string]interface{}, m.Length()+len(changes))
+	m.IterateUsing(w.allocator, func(key string, val value.Value) bool {
+		out[key] = val.Unstructured()
+		return true
+	})
+	for key, defaulted := range changes {
+		out[key] = defaulted
+	}
+	return out, true
+}
+
+// copyDefault returns a deep copy of the default d, so that the objects it's
+// set in don't share it with the schema.
+func copyDefault(d interface{}) interface{} {
+	switch d := d.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(d))
+		for k, v := range d {
+			out[k] = copyDefault(v)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(d))
+		for i, v := range d {
+			out[i] = copyDefault(v)
+		}
+		return out
+	}
+	return d
+}
@@ -0,0 +1,423 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package typed
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"sigs.k8s.io/structured-merge-diff/v4/schema"
+	"sigs.k8s.io/structured-merge-diff/v4/value"
+	yaml "sigs.k8s.io/yaml/goyaml.v2"
+)
+
+// DiffOption configures Diff.
+type DiffOption func(*diffOptions)
+
+type diffOptions struct {
+	context    int
+	sideBySide bool
+	width      int
+}
+
+// WithDiffContext configures Diff to show lines unchanged lines around each
+// change, rather than 3. All the unchanged lines are shown if lines is
+// negative.
+func WithDiffContext(lines int) DiffOption {
+	return func(opts *diffOptions) {
+		opts.context = lines
+	}
+}
+
+// WithSideBySide configures Diff to render the objects in two columns of
+// the given width, rather than as a unified diff. Longer lines of the left
+// column are truncated.
+func WithSideBySide(width int) DiffOption {
+	return func(opts *diffOptions) {
+		opts.sideBySide = true
+		opts.width = width
+	}
+}
+
+// Diff renders the differences between tv and rhs, as YAML, in a format
+// similar to diff's. Unlike a textual diff, it aligns the items of
+// associative lists by key, or by value for sets, rather than by position,
+// so that inserting or reordering items doesn't show every following item as
+// changed. It returns an empty string if the objects are equal.
+//
+// tv and rhs must both be of the same type (their Schema and TypeRef must
+// match), or an error will be returned.
+func (tv *TypedValue) Diff(rhs *TypedValue, opts ...DiffOption) (string, error) {
+	options := diffOptions{context: 3}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if tv.schema != rhs.schema {
+		return "", errorf("expected objects with types from the same schema")
+	}
+	if !tv.typeRef.Equals(&rhs.typeRef) {
+		return "", errorf("expected objects of the same type, but got %v and %v", tv.typeRef, rhs.typeRef)
+	}
+	d := differ{schema: tv.schema}
+	if err := d.diff(tv.typeRef, "", "", nil, nil, tv.value, rhs.value); err != nil {
+		return "", err
+	}
+	if !d.changed {
+		return "", nil
+	}
+	var b strings.Builder
+	if options.sideBySide {
+		writeSideBySide(&b, d.lines, options.context, options.width)
+	} else {
+		writeUnified(&b, d.lines, options.context)
+	}
+	return b.String(), nil
+}
+
+// diffLine is a line of a diff. op is ' ' for lines of both objects, '-'
+// for lines of the left one only, and '+' for lines of the right one only.
+type diffLine struct {
+	op   byte
+	text string
+}
+
+type differ struct {
+	schema  *schema.Schema
+	lines   []diffLine
+	changed bool
+}
+
+// diff adds the lines of the field or list item whose values are lhs and
+// rhs, either of which may be nil. label is the name of the field, or nil
+// for list items. The first line starts with lead and the others with
+// indent, e.g. "  - " and "    " for an item of a list of maps. keys are the
+// fields which should come first in maps, i.e. the keys of list items.
+func (d *differ) diff(tr schema.TypeRef, lead, indent string, label *string, keys []string, lhs, rhs value.Value) error {
+	switch {
+	case lhs == nil && rhs == nil:
+		return nil
+	case lhs == nil:
+		return d.render('+', tr, lead, indent, label, rhs)
+	case rhs == nil:
+		return d.render('-', tr, lead, indent, label, lhs)
+	case value.Equals(lhs, rhs):
+		return d.render(' ', tr, lead, indent, label, lhs)
+	}
+	atom, _ := d.schema.Resolve(tr)
+	switch {
+	case lhs.IsMap() && rhs.IsMap() && !lhs.AsMap().Empty() && !rhs.AsMap().Empty():
+		return d.diffMap(atom.Map, lead, indent, label, keys, lhs.AsMap(), rhs.AsMap())
+	case lhs.IsList() && rhs.IsList() && lhs.AsList().Length() > 0 && rhs.AsList().Length() > 0 &&
+		atom.List != nil && atom.List.ElementRelationship == schema.Associative:
+		return d.diffList(atom.List, lead, indent, label, lhs.AsList(), rhs.AsList())
+	}
+	if err := d.render('-', tr, lead, indent, label, lhs); err != nil {
+		return err
+	}
+	return d.render('+', tr, lead, indent, label, rhs)
+}
+
+func (d *differ) diffMap(t *schema.Map, lead, indent string, label *string, keys []string, lhs, rhs value.Map) error {
+	if label != nil {
+		if err := d.header(lead, *label); err != nil {
+			return err
+		}
+		lead, indent = indent+"  ", indent+"  "
+	}
+	for _, f := range unionEmitOrder(t, keys, lhs, rhs) {
+		name := f.Name
+		lv, _ := lhs.Get(name)
+		rv, _ := rhs.Get(name)
+		if err := d.diff(f.Type, lead, indent, &name, nil, lv, rv); err != nil {
+			return err
+		}
+		lead = indent
+	}
+	return nil
+}
+
+func (d *differ) diffList(t *schema.List, lead, indent string, label *string, lhs, rhs value.List) error {
+	itemLead := lead + "- "
+	if label != nil {
+		if err := d.header(lead, *label); err != nil {
+			return err
+		}
+		itemLead = indent + "- "
+	}
+	itemIndent := indent + "  "
+	item := func(lv, rv value.Value) error {
+		err := d.diff(t.ElementType, itemLead, itemIndent, nil, t.Keys, lv, rv)
+		itemLead = indent + "- "
+		return err
+	}
+
+	a := value.NewFreelistAllocator()
+	folders := keyFolders(t)
+	itemKey := func(l value.List, i int) string {
+		pe, err := listItemToPathElement(a, d.schema, t, folders, l.At(i))
+		if err != nil {
+			// Invalid items can't be matched.
+			return fmt.Sprintf("#%d", i)
+		}
+		return pe.String()
+	}
+	// unmatched lists the unmatched items of rhs by key, in order.
+	unmatched := map[string][]int{}
+	for j := 0; j < rhs.Length(); j++ {
+		k := itemKey(rhs, j)
+		unmatched[k] = append(unmatched[k], j)
+	}
+	match := make([]int, lhs.Length())
+	matched := make([]bool, rhs.Length())
+	for i := range match {
+		match[i] = -1
+		k := itemKey(lhs, i)
+		if js := unmatched[k]; len(js) > 0 {
+			match[i], unmatched[k] = js[0], js[1:]
+			matched[match[i]] = true
+		}
+	}
+
+	// Items are shown in the order of lhs, and the items which are only in
+	// rhs are inserted before the next item of lhs which follows them.
+	shown := make([]bool, rhs.Length())
+	i, j := 0, 0
+	for i < lhs.Length() || j < rhs.Length() {
+		var err error
+		switch {
+		case j < rhs.Length() && shown[j]:
+			j++
+		case i < lhs.Length() && match[i] < 0:
+			err = item(lhs.At(i), nil)
+			i++
+		case j < rhs.Length() && !matched[j]:
+			err = item(nil, rhs.At(j))
+			shown[j] = true
+		case i < lhs.Length():
+			err = item(lhs.At(i), rhs.At(match[i]))
+			shown[match[i]] = true
+			i++
+		default:
+			j++
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// header adds the line of a field whose value is a collection.
+func (d *differ) header(lead, label string) error {
+	b, err := yaml.Marshal(label)
+	if err != nil {
+		return err
+	}
+	d.lines = append(d.lines, diffLine{op: ' ', text: lead + strings.TrimSuffix(string(b), "\n") + ":"})
+	return nil
+}
+
+// render adds the lines of a whole value, see diff.
+func (d *differ) render(op byte, tr schema.TypeRef, lead, indent string, label *string, v value.Value) error {
+	u, errs := orderedUnstructured(d.schema, tr, v)
+	if len(errs) > 0 {
+		return errs
+	}
+	if label != nil {
+		u = yaml.MapSlice{{Key: *label, Value: u}}
+	}
+	b, err := yaml.Marshal(u)
+	if err != nil {
+		return err
+	}
+	if op != ' ' {
+		d.changed = true
+	}
+	for i, line := range strings.Split(strings.TrimSuffix(string(b), "\n"), "\n") {
+		prefix := indent
+		if i == 0 {
+			prefix = lead
+		}
+		d.lines = append(d.lines, diffLine{op: op, text: prefix + line})
+	}
+	return nil
+}
+
+// unionEmitOrder lists the fields of lhs and rhs like emitOrder does, except
+// that the given keys come first.
+func unionEmitOrder(t *schema.Map, keys []string, lhs, rhs value.Map) []emittedField {
+	var out []emittedField
+	seen := map[string]bool{}
+	add := func(name string, tr schema.TypeRef) {
+		if seen[name] {
+			return
+		}
+		if _, ok := lhs.Get(name); !ok {
+			if _, ok := rhs.Get(name); !ok {
+				return
+			}
+		}
+		seen[name] = true
+		out = append(out, emittedField{Name: name, Type: tr})
+	}
+	var elementType schema.TypeRef
+	if t != nil {
+		elementType = t.ElementType
+	}
+	fieldType := func(name string) schema.TypeRef {
+		if t != nil {
+			if sf, ok := t.FindField(name); ok {
+				return sf.Type
+			}
+		}
+		return elementType
+	}
+	for _, key := range keys {
+		add(key, fieldType(key))
+	}
+	if t != nil {
+		for _, field := range t.Fields {
+			add(field.Name, field.Type)
+		}
+	}
+	var others []string
+	for _, m := range []value.Map{lhs, rhs} {
+		m.Iterate(func(key string, _ value.Value) bool {
+			if !seen[key] {
+				others = append(others, key)
+			}
+			return true
+		})
+	}
+	sort.Strings(others)
+	for _, key := range others {
+		add(key, elementType)
+	}
+	return out
+}
+
+// hunks returns the ranges of items, given whether each of them changed,
+// which are within context items of a change.
+func hunks(changed []bool, context int) (ranges [][2]int) {
+	if context < 0 {
+		return [][2]int{{0, len(changed)}}
+	}
+	for i, c := range changed {
+		if !c {
+			continue
+		}
+		start, end := i-context, i+context+1
+		if start < 0 {
+			start = 0
+		}
+		if end > len(changed) {
+			end = len(changed)
+		}
+		if n := len(ranges); n > 0 && ranges[n-1][1] >= start {
+			ranges[n-1][1] = end
+		} else {
+			ranges = append(ranges, [2]int{start, end})
+		}
+	}
+	return ranges
+}
+
+func writeUnified(b *strings.Builder, lines []diffLine, context int) {
+	changed := make([]bool, len(lines))
+	// lhsLine and rhsLine are the line numbers in each object of the lines
+	// of the diff, starting at 1.
+	lhsLine := make([]int, len(lines)+1)
+	rhsLine := make([]int, len(lines)+1)
+	lhsLine[0], rhsLine[0] = 1, 1
+	for i, line := range lines {
+		changed[i] = line.op != ' '
+		lhsLine[i+1], rhsLine[i+1] = lhsLine[i], rhsLine[i]
+		if line.op != '+' {
+			lhsLine[i+1]++
+		}
+		if line.op != '-' {
+			rhsLine[i+1]++
+		}
+	}
+	for _, r := range hunks(changed, context) {
+		fmt.Fprintf(b, "@@ -%d,%d +%d,%d @@\n", lhsLine[r[0]], lhsLine[r[1]]-lhsLine[r[0]], rhsLine[r[0]], rhsLine[r[1]]-rhsLine[r[0]])
+		for _, line := range lines[r[0]:r[1]] {
+			b.WriteByte(line.op)
+			b.WriteString(line.text)
+			b.WriteByte('\n')
+		}
+	}
+}
+
+// sideBySideRow is a row of a side-by-side diff. mark is ' ' for unchanged
+// lines, '|' for changed ones, and '<' or '>' for lines of the left or
+// right object only.
+type sideBySideRow struct {
+	left, right string
+	mark        byte
+}
+
+func writeSideBySide(b *strings.Builder, lines []diffLine, context, width int) {
+	if width <= 0 {
+		width = 60
+	}
+	var rows []sideBySideRow
+	for i := 0; i < len(lines); {
+		if lines[i].op == ' ' {
+			rows = append(rows, sideBySideRow{left: lines[i].text, right: lines[i].text, mark: ' '})
+			i++
+			continue
+		}
+		// Pair the removed lines with the added lines which follow them.
+		var removed, added []string
+		for ; i < len(lines) && lines[i].op == '-'; i++ {
+			removed = append(removed, lines[i].text)
+		}
+		for ; i < len(lines) && lines[i].op == '+'; i++ {
+			added = append(added, lines[i].text)
+		}
+		for k := 0; k < len(removed) || k < len(added); k++ {
+			switch {
+			case k >= len(added):
+				rows = append(rows, sideBySideRow{left: removed[k], mark: '<'})
+			case k >= len(removed):
+				rows = append(rows, sideBySideRow{right: added[k], mark: '>'})
+			default:
+				rows = append(rows, sideBySideRow{left: removed[k], right: added[k], mark: '|'})
+			}
+		}
+	}
+	changed := make([]bool, len(rows))
+	for i, row := range rows {
+		changed[i] = row.mark != ' '
+	}
+	for h, r := range hunks(changed, context) {
+		if h > 0 || r[0] > 0 {
+			b.WriteString("...\n")
+		}
+		for _, row := range rows[r[0]:r[1]] {
+			left := row.left
+			if len(left) > width {
+				left = left[:width]
+			}
+			line := fmt.Sprintf("%-*s %c %s", width, left, row.mark, row.right)
+			b.WriteString(strings.TrimRight(line, " "))
+			b.WriteByte('\n')
+		}
+	}
+}
@@ -0,0 +1,192 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package typed_test
+
+import (
+	"strings"
+	"testing"
+
+	"sigs.k8s.io/structured-merge-diff/v4/typed"
+)
+
+var diffParser = func() *typed.Parser {
+	parser, err := typed.NewParser(`types:
+- name: pod
+  map:
+    fields:
+    - name: name
+      type:
+        scalar: string
+    - name: containers
+      type:
+        list:
+          elementType:
+            namedType: container
+          elementRelationship: associative
+          keys:
+          - name
+    - name: finalizers
+      type:
+        list:
+          elementType:
+            scalar: string
+          elementRelationship: associative
+    - name: labels
+      type:
+        map:
+          elementType:
+            scalar: string
+- name: container
+  map:
+    fields:
+    - name: name
+      type:
+        scalar: string
+    - name: image
+      type:
+        scalar: string
+    - name: args
+      type:
+        list:
+          elementType:
+            scalar: string
+          elementRelationship: atomic
+`)
+	if err != nil {
+		panic(err)
+	}
+	return parser
+}()
+
+func TestDiff(t *testing.T) {
+	const (
+		lhs = `{"name": "p", "labels": {"a": "1", "b": "2"}, "finalizers": ["x", "y"], "containers": [{"name": "a", "image": "i1", "args": ["1"]}, {"name": "b", "image": "i2"}, {"name": "c", "image": "i3"}]}`
+		rhs = `{"name": "p", "labels": {"a": "1", "c": "3"}, "finalizers": ["y", "z"], "containers": [{"name": "new", "image": "n"}, {"name": "a", "image": "i1", "args": ["1", "2"]}, {"name": "c", "image": "i3b"}]}`
+	)
+	tests := []struct {
+		name     string
+		lhs, rhs typed.YAMLObject
+		opts     []typed.DiffOption
+		diff     string
+	}{{
+		name: "equal",
+		lhs:  lhs,
+		rhs:  lhs,
+	}, {
+		name: "unified",
+		lhs:  lhs,
+		rhs:  rhs,
+		diff: `@@ -1,16 +1,17 @@
+ name: p
+ containers:
++- name: new
++  image: "n"
+ - name: a
+   image: i1
+-  args:
+-  - "1"
++  args:
++  - "1"
++  - "2"
+-- name: b
+-  image: i2
+ - name: c
+-  image: i3
++  image: i3b
+ finalizers:
+-- x
+ - "y"
++- z
+ labels:
+   a: "1"
+-  b: "2"
++  c: "3"
+`,
+	}, {
+		name: "unified without context",
+		lhs:  `{"name": "p", "labels": {"a": "1", "b": "2", "c": "3", "d": "4"}}`,
+		rhs:  `{"name": "q", "labels": {"a": "1", "b": "2", "c": "3", "d": "5"}}`,
+		opts: []typed.DiffOption{typed.WithDiffContext(0)},
+		diff: `@@ -1,1 +1,1 @@
+-name: p
++name: q
+@@ -6,1 +6,1 @@
+-  d: "4"
++  d: "5"
+`,
+	}, {
+		name: "side by side",
+		lhs:  lhs,
+		rhs:  rhs,
+		opts: []typed.DiffOption{typed.WithSideBySide(16), typed.WithDiffContext(1)},
+		diff: `...
+containers:        containers:
+                 > - name: new
+                 >   image: "n"
+- name: a          - name: a
+  image: i1          image: i1
+  args:          |   args:
+  - "1"          |   - "1"
+                 >   - "2"
+- name: b        <
+  image: i2      <
+- name: c          - name: c
+  image: i3      |   image: i3b
+finalizers:        finalizers:
+- x              <
+- "y"              - "y"
+                 > - z
+labels:            labels:
+  a: "1"             a: "1"
+  b: "2"         |   c: "3"
+`,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			lhs, err := diffParser.Type("pod").FromYAML(test.lhs)
+			if err != nil {
+				t.Fatalf("failed to parse lhs: %v", err)
+			}
+			rhs, err := diffParser.Type("pod").FromYAML(test.rhs)
+			if err != nil {
+				t.Fatalf("failed to parse rhs: %v", err)
+			}
+			got, err := lhs.Diff(rhs, test.opts...)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != test.diff {
+				t.Errorf("expected diff:\n%v\ngot:\n%v", test.diff, got)
+			}
+		})
+	}
+}
+
+func TestDiffTypeMismatch(t *testing.T) {
+	lhs, err := diffParser.Type("pod").FromYAML(`{"name": "p"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rhs, err := diffParser.Type("container").FromYAML(`{"name": "p"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lhs.Diff(rhs); err == nil || !strings.Contains(err.Error(), "same type") {
+		t.Errorf("expected a type mismatch error, got %v", err)
+	}
+}
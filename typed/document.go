@@ -0,0 +1,319 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package typed
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+
+	"sigs.k8s.io/structured-merge-diff/v4/schema"
+	"sigs.k8s.io/structured-merge-diff/v4/value"
+	yaml "sigs.k8s.io/yaml/goyaml.v2"
+	yamlv3 "sigs.k8s.io/yaml/goyaml.v3"
+)
+
+// YAMLDocument is a YAML document which remembers its comments, blank lines
+// and formatting, so that a value derived from it (e.g. by merging it) can
+// be written back without losing them.
+//
+//	doc, err := ParseYAMLDocument(object)
+//	tv, err := parseableType.FromYAMLDocument(doc)
+//	merged, err := tv.Merge(other)
+//	out, err := doc.Render(merged)
+type YAMLDocument struct {
+	object YAMLObject
+	lines  []string
+	root   *yamlv3.Node
+}
+
+// ParseYAMLDocument parses object, keeping its comments.
+func ParseYAMLDocument(object YAMLObject) (*YAMLDocument, error) {
+	root := &yamlv3.Node{}
+	if err := yamlv3.Unmarshal([]byte(object), root); err != nil {
+		return nil, err
+	}
+	return &YAMLDocument{
+		object: object,
+		lines:  strings.Split(string(object), "\n"),
+		root:   root,
+	}, nil
+}
+
+// Object returns the source of the document.
+func (d *YAMLDocument) Object() YAMLObject {
+	return d.object
+}
+
+// FromYAMLDocument parses the value of the document. It is the same as
+// calling FromYAML with the source of the document.
+func (p ParseableType) FromYAMLDocument(d *YAMLDocument, opts ...ValidationOptions) (*TypedValue, error) {
+	return p.FromYAML(d.object, opts...)
+}
+
+// Render emits tv as YAML, reusing the document as much as possible:
+//   - the fields and list items that are in both the document and tv keep
+//     their order, comments, and the blank lines before them;
+//   - the scalars that didn't change keep their original formatting;
+//   - the fields and list items that are only in tv are added after the
+//     others, in the order used by ToYAML, and the ones that are only in the
+//     document are dropped along with their comments.
+//
+// List items are matched by key for associative lists, by value for sets,
+// and by index otherwise. Aliases of the document are expanded.
+func (d *YAMLDocument) Render(tv *TypedValue) ([]byte, error) {
+	var orig *yamlv3.Node
+	out := &yamlv3.Node{Kind: yamlv3.DocumentNode}
+	if d.root.Kind == yamlv3.DocumentNode {
+		out.HeadComment = d.root.HeadComment
+		out.LineComment = d.root.LineComment
+		out.FootComment = d.root.FootComment
+		if len(d.root.Content) > 0 {
+			orig = d.root.Content[0]
+		}
+	}
+	r := documentRenderer{doc: d, schema: tv.schema}
+	root, err := r.render(tv.typeRef, tv.value, orig)
+	if err != nil {
+		return nil, err
+	}
+	out.Content = []*yamlv3.Node{root}
+
+	buf := bytes.Buffer{}
+	encoder := yamlv3.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(out); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	// The blank lines of list items are indented. Lines of spaces can't be
+	// part of a scalar, which would be quoted instead.
+	lines := bytes.Split(buf.Bytes(), []byte("\n"))
+	for i := range lines {
+		if len(bytes.TrimLeft(lines[i], " ")) == 0 {
+			lines[i] = nil
+		}
+	}
+	return bytes.Join(lines, []byte("\n")), nil
+}
+
+type documentRenderer struct {
+	doc    *YAMLDocument
+	schema *schema.Schema
+}
+
+// render returns the node for v, based on orig if it isn't nil.
+func (r *documentRenderer) render(tr schema.TypeRef, v value.Value, orig *yamlv3.Node) (*yamlv3.Node, error) {
+	if orig != nil && orig.Kind == yamlv3.AliasNode {
+		orig = orig.Alias
+	}
+	var atom schema.Atom
+	if r.schema != nil {
+		atom, _ = r.schema.Resolve(tr)
+	}
+	switch {
+	case v != nil && v.IsMap():
+		if orig == nil || orig.Kind != yamlv3.MappingNode {
+			orig = commentsOf(orig, yamlv3.MappingNode)
+		}
+		return r.renderMap(atom.Map, v.AsMap(), orig)
+	case v != nil && v.IsList():
+		if orig == nil || orig.Kind != yamlv3.SequenceNode {
+			orig = commentsOf(orig, yamlv3.SequenceNode)
+		}
+		return r.renderList(atom.List, v.AsList(), orig)
+	default:
+		if orig != nil && orig.Kind == yamlv3.ScalarNode {
+			if ov, err := yamlScalarValue(orig); err == nil && value.Equals(ov, valueOrNull(v)) {
+				n := *orig
+				n.Anchor = ""
+				return &n, nil
+			}
+		}
+		n := &yamlv3.Node{}
+		var u interface{}
+		if v != nil {
+			u = v.Unstructured()
+		}
+		if err := n.Encode(u); err != nil {
+			return nil, err
+		}
+		if orig != nil {
+			n.HeadComment = orig.HeadComment
+			n.LineComment = orig.LineComment
+			n.FootComment = orig.FootComment
+		}
+		return n, nil
+	}
+}
+
+func (r *documentRenderer) renderMap(t *schema.Map, m value.Map, orig *yamlv3.Node) (*yamlv3.Node, error) {
+	out := copyYAMLCollection(orig)
+	fields := emitOrder(t, m)
+	byName := make(map[string]emittedField, len(fields))
+	for _, f := range fields {
+		byName[f.Name] = f
+	}
+	done := map[string]bool{}
+	for i := 0; i+1 < len(orig.Content); i += 2 {
+		k, v := orig.Content[i], orig.Content[i+1]
+		f, ok := byName[k.Value]
+		if k.Kind != yamlv3.ScalarNode || !ok || done[k.Value] {
+			continue
+		}
+		done[k.Value] = true
+		child, err := r.render(f.Type, f.Value, v)
+		if err != nil {
+			return nil, err
+		}
+		key := *k
+		key.Anchor = ""
+		// The blank line before the first key of a list item is before
+		// the item.
+		if k.Line != orig.Line && r.blankLineBefore(k) {
+			key.HeadComment = "\n" + key.HeadComment
+		}
+		out.Content = append(out.Content, &key, child)
+	}
+	for _, f := range fields {
+		if done[f.Name] {
+			continue
+		}
+		child, err := r.render(f.Type, f.Value, nil)
+		if err != nil {
+			return nil, err
+		}
+		key := &yamlv3.Node{}
+		if err := key.Encode(f.Name); err != nil {
+			return nil, err
+		}
+		out.Content = append(out.Content, key, child)
+	}
+	return out, nil
+}
+
+// renderList keeps the order of l, which is significant, and reuses the
+// items of orig that match its items.
+func (r *documentRenderer) renderList(t *schema.List, l value.List, orig *yamlv3.Node) (*yamlv3.Node, error) {
+	out := copyYAMLCollection(orig)
+	var elementType schema.TypeRef
+	if t != nil {
+		elementType = t.ElementType
+	}
+	// Items are matched by the string form of their path element.
+	folders := keyFolders(t)
+	itemKey := func(i int, item value.Value) string {
+		if t == nil || t.ElementRelationship != schema.Associative {
+			return strconv.Itoa(i)
+		}
+		pe, err := listItemToPathElement(value.HeapAllocator, r.schema, t, folders, item)
+		if err != nil {
+			return ""
+		}
+		return pe.String()
+	}
+	origItems := map[string]*yamlv3.Node{}
+	for i, n := range orig.Content {
+		var item interface{}
+		if err := n.Decode(&item); err != nil {
+			continue
+		}
+		key := itemKey(i, value.NewValueInterface(item))
+		if _, ok := origItems[key]; !ok && key != "" {
+			origItems[key] = n
+		}
+	}
+	for i := 0; i < l.Length(); i++ {
+		item := l.At(i)
+		key := itemKey(i, item)
+		o := origItems[key]
+		delete(origItems, key)
+		n, err := r.render(elementType, item, o)
+		if err != nil {
+			return nil, err
+		}
+		if o != nil && r.blankLineBefore(o) {
+			n.HeadComment = "\n" + n.HeadComment
+		}
+		out.Content = append(out.Content, n)
+	}
+	return out, nil
+}
+
+// blankLineBefore returns true if there is a blank line before n and its
+// head comment in the document.
+func (r *documentRenderer) blankLineBefore(n *yamlv3.Node) bool {
+	line := n.Line - 1
+	if n.HeadComment != "" {
+		line -= strings.Count(n.HeadComment, "\n") + 1
+	}
+	return line >= 1 && line <= len(r.doc.lines) && strings.TrimSpace(r.doc.lines[line-1]) == ""
+}
+
+// copyYAMLCollection returns a copy of the map or list n, without its
+// content.
+func copyYAMLCollection(n *yamlv3.Node) *yamlv3.Node {
+	return &yamlv3.Node{
+		Kind:        n.Kind,
+		Style:       n.Style & yamlv3.FlowStyle,
+		HeadComment: n.HeadComment,
+		LineComment: n.LineComment,
+		FootComment: n.FootComment,
+	}
+}
+
+// commentsOf returns an empty node of the given kind with the comments of n,
+// if it isn't nil.
+func commentsOf(n *yamlv3.Node, kind yamlv3.Kind) *yamlv3.Node {
+	out := &yamlv3.Node{Kind: kind}
+	if n != nil {
+		out.HeadComment = n.HeadComment
+		out.LineComment = n.LineComment
+		out.FootComment = n.FootComment
+	}
+	return out
+}
+
+// yamlScalarValue decodes n the way FromYAML does, i.e. with YAML 1.1 rules:
+// plain scalars like "yes" are booleans.
+func yamlScalarValue(n *yamlv3.Node) (value.Value, error) {
+	quoted := yamlv3.DoubleQuotedStyle | yamlv3.SingleQuotedStyle | yamlv3.LiteralStyle | yamlv3.FoldedStyle
+	if n.Style&quoted != 0 || (n.Style&yamlv3.TaggedStyle != 0 && n.ShortTag() == "!!str") {
+		return value.NewValueInterface(n.Value), nil
+	}
+	if n.Style&yamlv3.TaggedStyle != 0 {
+		var v interface{}
+		if err := n.Decode(&v); err != nil {
+			return nil, err
+		}
+		return value.NewValueInterface(v), nil
+	}
+	var v interface{}
+	if err := yaml.Unmarshal([]byte(n.Value), &v); err != nil {
+		return nil, err
+	}
+	return value.NewValueInterface(v), nil
+}
+
+func valueOrNull(v value.Value) value.Value {
+	if v == nil {
+		return value.NewValueInterface(nil)
+	}
+	return v
+}
@@ -0,0 +1,172 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package typed_test
+
+import (
+	"testing"
+
+	"sigs.k8s.io/structured-merge-diff/v4/typed"
+)
+
+var documentParser = func() *typed.Parser {
+	parser, err := typed.NewParser(`types:
+- name: root
+  map:
+    fields:
+    - name: name
+      type:
+        scalar: string
+    - name: enabled
+      type:
+        scalar: boolean
+    - name: containers
+      type:
+        list:
+          elementType:
+            namedType: container
+          elementRelationship: associative
+          keys: [name]
+    - name: args
+      type:
+        list:
+          elementType:
+            scalar: string
+          elementRelationship: atomic
+    elementType:
+      scalar: string
+- name: container
+  map:
+    fields:
+    - name: name
+      type:
+        scalar: string
+    - name: image
+      type:
+        scalar: string
+`)
+	if err != nil {
+		panic(err)
+	}
+	return parser
+}()
+
+func TestYAMLDocumentRender(t *testing.T) {
+	tests := []struct {
+		name     string
+		original typed.YAMLObject
+		updated  typed.YAMLObject
+		expected string
+	}{{
+		name: "unchanged",
+		original: `# The name.
+name: 'example' # quoted
+
+# Toggle.
+enabled: yes
+containers:
+# First.
+- name: a
+  image: "a:1"
+
+- name: b # second
+  image: b:1
+`,
+		expected: `# The name.
+name: 'example' # quoted
+
+# Toggle.
+enabled: yes
+containers:
+  # First.
+  - name: a
+    image: "a:1"
+
+  - name: b # second
+    image: b:1
+`,
+	}, {
+		name: "changes",
+		original: `name: example # the name
+
+# Containers.
+containers:
+- name: a # first
+  image: a:1
+- name: b
+  image: b:1 # pinned
+args: [p, q]
+extra: value # dropped
+`,
+		updated: `
+name: example
+enabled: true
+containers:
+- name: a
+  image: a:1
+- name: b
+  image: b:2
+- name: c
+  image: c:1
+args: [p, r]
+`,
+		expected: `name: example # the name
+
+# Containers.
+containers:
+  - name: a # first
+    image: a:1
+  - name: b
+    image: b:2 # pinned
+  - name: c
+    image: c:1
+args: [p, r]
+enabled: true
+`,
+	}, {
+		name:     "empty",
+		original: ``,
+		updated:  `{"name": "example"}`,
+		expected: "name: example\n",
+	}}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			pt := documentParser.Type("root")
+			doc, err := typed.ParseYAMLDocument(tt.original)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tv, err := pt.FromYAMLDocument(doc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.updated != "" {
+				if tv, err = pt.FromYAML(tt.updated); err != nil {
+					t.Fatal(err)
+				}
+			}
+			out, err := doc.Render(tv)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(out) != tt.expected {
+				t.Errorf("expected:\n%v\ngot:\n%v", tt.expected, string(out))
+			}
+		})
+	}
+}
@@ -0,0 +1,167 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package typed
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	jsoniter "github.com/json-iterator/go"
+	"sigs.k8s.io/structured-merge-diff/v4/schema"
+	"sigs.k8s.io/structured-merge-diff/v4/value"
+	yaml "sigs.k8s.io/yaml/goyaml.v2"
+)
+
+// ToYAML emits the value as YAML, in a stable order: the fields of a map
+// come in the order in which the schema declares them, followed by the
+// other keys sorted lexically.
+func (tv *TypedValue) ToYAML() ([]byte, error) {
+	u, errs := orderedUnstructured(tv.schema, tv.typeRef, tv.value)
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return yaml.Marshal(u)
+}
+
+// ToJSON emits the value as JSON, in the same order as ToYAML.
+func (tv *TypedValue) ToJSON() ([]byte, error) {
+	buf := bytes.Buffer{}
+	u, errs := orderedUnstructured(tv.schema, tv.typeRef, tv.value)
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	stream := jsoniter.NewStream(jsoniter.ConfigCompatibleWithStandardLibrary, &buf, 4096)
+	writeOrderedJSON(stream, u)
+	if err := stream.Flush(); err != nil {
+		return nil, err
+	}
+	if stream.Error != nil {
+		return nil, stream.Error
+	}
+	return buf.Bytes(), nil
+}
+
+// orderedUnstructured converts v to its unstructured form, except that maps
+// are converted to yaml.MapSlice in the order in which they are emitted. It
+// fails on values which are neither null, maps, lists nor scalars, e.g.
+// unstructured objects holding channels, which can't be serialized.
+func orderedUnstructured(s *schema.Schema, tr schema.TypeRef, v value.Value) (interface{}, ValidationErrors) {
+	var atom schema.Atom
+	if s != nil {
+		atom, _ = s.Resolve(tr)
+	}
+	switch {
+	case v == nil || v.IsNull():
+		return nil, nil
+	case v.IsMap():
+		return orderedMap(s, atom.Map, v.AsMap())
+	case v.IsList():
+		l := v.AsList()
+		var elementType schema.TypeRef
+		if atom.List != nil {
+			elementType = atom.List.ElementType
+		}
+		out := make([]interface{}, 0, l.Length())
+		for i := 0; i < l.Length(); i++ {
+			item, errs := orderedUnstructured(s, elementType, l.At(i))
+			if len(errs) > 0 {
+				return nil, errs.WithPrefix(fmt.Sprintf("[%d]", i))
+			}
+			out = append(out, item)
+		}
+		return out, nil
+	case v.IsString() || v.IsInt() || v.IsFloat() || v.IsBool():
+		return v.Unstructured(), nil
+	default:
+		return nil, errorf("unsupported value of type %T", v.Unstructured())
+	}
+}
+
+func orderedMap(s *schema.Schema, t *schema.Map, m value.Map) (yaml.MapSlice, ValidationErrors) {
+	out := make(yaml.MapSlice, 0, m.Length())
+	for _, f := range emitOrder(t, m) {
+		u, errs := orderedUnstructured(s, f.Type, f.Value)
+		if len(errs) > 0 {
+			return nil, errs.WithPrefix("." + f.Name)
+		}
+		out = append(out, yaml.MapItem{Key: f.Name, Value: u})
+	}
+	return out, nil
+}
+
+type emittedField struct {
+	Name  string
+	Type  schema.TypeRef
+	Value value.Value
+}
+
+// emitOrder lists the fields of m in the order in which they are emitted,
+// along with their types.
+func emitOrder(t *schema.Map, m value.Map) []emittedField {
+	out := make([]emittedField, 0, m.Length())
+	declared := map[string]bool{}
+	var elementType schema.TypeRef
+	if t != nil {
+		elementType = t.ElementType
+		for _, field := range t.Fields {
+			declared[field.Name] = true
+			if fv, ok := m.Get(field.Name); ok {
+				out = append(out, emittedField{Name: field.Name, Type: field.Type, Value: fv})
+			}
+		}
+	}
+	var others []string
+	m.Iterate(func(key string, _ value.Value) bool {
+		if !declared[key] {
+			others = append(others, key)
+		}
+		return true
+	})
+	sort.Strings(others)
+	for _, key := range others {
+		fv, _ := m.Get(key)
+		out = append(out, emittedField{Name: key, Type: elementType, Value: fv})
+	}
+	return out
+}
+
+func writeOrderedJSON(stream *jsoniter.Stream, v interface{}) {
+	switch v := v.(type) {
+	case yaml.MapSlice:
+		stream.WriteObjectStart()
+		for i, item := range v {
+			if i > 0 {
+				stream.WriteMore()
+			}
+			stream.WriteObjectField(item.Key.(string))
+			writeOrderedJSON(stream, item.Value)
+		}
+		stream.WriteObjectEnd()
+	case []interface{}:
+		stream.WriteArrayStart()
+		for i, item := range v {
+			if i > 0 {
+				stream.WriteMore()
+			}
+			writeOrderedJSON(stream, item)
+		}
+		stream.WriteArrayEnd()
+	default:
+		stream.WriteVal(v)
+	}
+}
@@ -0,0 +1,166 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package typed_test
+
+import (
+	"strings"
+	"testing"
+
+	"sigs.k8s.io/structured-merge-diff/v4/typed"
+	"sigs.k8s.io/structured-merge-diff/v4/value"
+)
+
+var emitParser = func() *typed.Parser {
+	parser, err := typed.NewParser(`types:
+- name: root
+  map:
+    fields:
+    - name: name
+      type:
+        scalar: string
+    - name: spec
+      type:
+        namedType: spec
+    - name: items
+      type:
+        list:
+          elementType:
+            namedType: spec
+          elementRelationship: atomic
+    elementType:
+      scalar: string
+- name: spec
+  map:
+    fields:
+    - name: replicas
+      type:
+        scalar: numeric
+    - name: image
+      type:
+        scalar: string
+    - name: labels
+      type:
+        map:
+          elementType:
+            scalar: string
+`)
+	if err != nil {
+		panic(err)
+	}
+	return parser
+}()
+
+func TestToYAMLAndJSON(t *testing.T) {
+	tests := []struct {
+		name         string
+		typeName     string
+		object       typed.YAMLObject
+		expectedYAML string
+		expectedJSON string
+	}{{
+		name:     "schema order",
+		typeName: "root",
+		object: `
+zzz: last
+items:
+- image: b
+  replicas: 2
+spec:
+  labels: {b: "2", a: "1"}
+  image: "<html>"
+  replicas: 1
+aaa: first
+name: example
+`,
+		expectedYAML: `name: example
+spec:
+  replicas: 1
+  image: <html>
+  labels:
+    a: "1"
+    b: "2"
+items:
+- replicas: 2
+  image: b
+aaa: first
+zzz: last
+`,
+		expectedJSON: `{"name":"example","spec":{"replicas":1,"image":"\u003chtml\u003e","labels":{"a":"1","b":"2"}},"items":[{"replicas":2,"image":"b"}],"aaa":"first","zzz":"last"}`,
+	}, {
+		name:         "null",
+		typeName:     "root",
+		object:       `null`,
+		expectedYAML: "null\n",
+		expectedJSON: `null`,
+	}}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			tv, err := emitParser.Type(tt.typeName).FromYAML(tt.object)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < 5; i++ {
+				y, err := tv.ToYAML()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(y) != tt.expectedYAML {
+					t.Fatalf("expected YAML:\n%v\ngot:\n%v", tt.expectedYAML, string(y))
+				}
+				j, err := tv.ToJSON()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(j) != tt.expectedJSON {
+					t.Fatalf("expected JSON:\n%v\ngot:\n%v", tt.expectedJSON, string(j))
+				}
+			}
+		})
+	}
+}
+
+func TestToJSONDeduced(t *testing.T) {
+	tv, err := typed.DeducedParseableType.FromYAML(`{"b": [{"d": 1, "c": 2}], "a": true}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	j, err := tv.ToJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e, a := `{"a":true,"b":[{"c":2,"d":1}]}`, string(j); e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+}
+
+func TestToYAMLUnsupportedValues(t *testing.T) {
+	tv := typed.AsTypedUnvalidated(value.NewValueInterface(map[string]interface{}{
+		"a": []interface{}{1, make(chan int)},
+	}), typed.DeducedParseableType.Schema, typed.DeducedParseableType.TypeRef)
+	const expected = ".a[1]: unsupported value of type chan int"
+	if _, err := tv.ToYAML(); err == nil || err.Error() != expected {
+		t.Errorf("expected ToYAML to fail with %q, got %v", expected, err)
+	}
+	if _, err := tv.ToJSON(); err == nil || err.Error() != expected {
+		t.Errorf("expected ToJSON to fail with %q, got %v", expected, err)
+	}
+	if _, err := tv.Diff(tv.Empty()); err == nil || !strings.Contains(err.Error(), "unsupported value") {
+		t.Errorf("expected Diff to fail, got %v", err)
+	}
+}
@@ -0,0 +1,146 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package typed
+
+import (
+	"sigs.k8s.io/structured-merge-diff/v4/schema"
+	"sigs.k8s.io/structured-merge-diff/v4/value"
+)
+
+// EmptyCollectionPolicy defines which of empty, null and absent maps and
+// lists are equivalent.
+type EmptyCollectionPolicy int
+
+const (
+	// EmptyIsDistinct keeps {}, [], null and absent collections distinct
+	// (default behavior).
+	EmptyIsDistinct EmptyCollectionPolicy = iota
+	// EmptyEqualsNull makes {} and [] equivalent to null, but not to
+	// absent.
+	EmptyEqualsNull
+	// EmptyEqualsAbsent makes {}, [] and null collections equivalent to
+	// absent fields.
+	EmptyEqualsAbsent
+)
+
+// WithEmptyCollections configures Compare to normalize both objects
+// according to policy, see NormalizeEmptyCollections, so that the maps and
+// lists which are equivalent aren't modified.
+func WithEmptyCollections(policy EmptyCollectionPolicy) CompareOption {
+	return func(opts *compareOptions) {
+		opts.emptyCollections = policy
+	}
+}
+
+// NormalizeEmptyCollections returns tv with its equivalent maps and lists
+// normalized according to policy: with EmptyEqualsNull, the empty map and
+// list fields are replaced by null; with EmptyEqualsAbsent, the empty and
+// null map and list fields are removed, recursively, so that a map left
+// empty is removed as well. The items of lists are left as they are, since
+// removing them would shift the following ones. tv is returned as is if
+// nothing changes.
+func (tv *TypedValue) NormalizeEmptyCollections(policy EmptyCollectionPolicy) *TypedValue {
+	if policy == EmptyIsDistinct {
+		return tv
+	}
+	w := emptyCollectionWalker{schema: tv.schema, policy: policy}
+	if out, changed, _ := w.walk(tv.typeRef, tv.value); changed {
+		return tv.withValue(value.NewValueInterface(out))
+	}
+	return tv
+}
+
+type emptyCollectionWalker struct {
+	schema *schema.Schema
+	policy EmptyCollectionPolicy
+}
+
+// walk returns the normalized unstructured value of v, if it changed, and
+// whether it's an empty or null collection once normalized.
+func (w *emptyCollectionWalker) walk(tr schema.TypeRef, v value.Value) (out interface{}, changed, empty bool) {
+	a, ok := w.schema.Resolve(tr)
+	if !ok || v == nil {
+		return nil, false, false
+	}
+	switch {
+	case v.IsNull():
+		return nil, false, a.Map != nil || a.List != nil
+	case a.Map != nil && v.IsMap():
+		return w.walkMap(a.Map, v.AsMap())
+	case a.List != nil && v.IsList():
+		return w.walkList(a.List, v.AsList())
+	}
+	return nil, false, false
+}
+
+func (w *emptyCollectionWalker) walkMap(t *schema.Map, m value.Map) (interface{}, bool, bool) {
+	changed := map[string]interface{}{}
+	deleted := map[string]bool{}
+	m.Iterate(func(key string, val value.Value) bool {
+		tr := t.ElementType
+		if sf, ok := t.FindField(key); ok {
+			tr = sf.Type
+		}
+		out, c, empty := w.walk(tr, val)
+		switch {
+		case empty && w.policy == EmptyEqualsAbsent:
+			deleted[key] = true
+		case empty && !val.IsNull():
+			changed[key] = nil
+		case c:
+			changed[key] = out
+		}
+		return true
+	})
+	if len(changed) == 0 && len(deleted) == 0 {
+		return nil, false, m.Length() == 0
+	}
+	out := make(map[string]interface{}, m.Length()-len(deleted))
+	m.Iterate(func(key string, val value.Value) bool {
+		if deleted[key] {
+			return true
+		}
+		if c, ok := changed[key]; ok {
+			out[key] = c
+		} else {
+			out[key] = val.Unstructured()
+		}
+		return true
+	})
+	return out, true, len(out) == 0
+}
+
+func (w *emptyCollectionWalker) walkList(t *schema.List, l value.List) (interface{}, bool, bool) {
+	changed := map[int]interface{}{}
+	for i := 0; i < l.Length(); i++ {
+		if out, c, _ := w.walk(t.ElementType, l.At(i)); c {
+			changed[i] = out
+		}
+	}
+	if len(changed) == 0 {
+		return nil, false, l.Length() == 0
+	}
+	out := make([]interface{}, l.Length())
+	for i := range out {
+		if c, ok := changed[i]; ok {
+			out[i] = c
+		} else {
+			out[i] = l.At(i).Unstructured()
+		}
+	}
+	return out, true, false
+}
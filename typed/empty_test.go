@@ -0,0 +1,143 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package typed_test
+
+import (
+	"testing"
+
+	"sigs.k8s.io/structured-merge-diff/v4/typed"
+)
+
+var emptyCollectionsParser = func() *typed.Parser {
+	parser, err := typed.NewParser(`types:
+- name: type
+  map:
+    fields:
+    - name: name
+      type:
+        scalar: string
+    - name: labels
+      type:
+        map:
+          elementType:
+            scalar: string
+    - name: args
+      type:
+        list:
+          elementType:
+            scalar: string
+          elementRelationship: atomic
+    - name: nested
+      type:
+        namedType: type
+    - name: items
+      type:
+        list:
+          elementType:
+            namedType: type
+          elementRelationship: atomic
+`)
+	if err != nil {
+		panic(err)
+	}
+	return parser
+}()
+
+func TestNormalizeEmptyCollections(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   typed.EmptyCollectionPolicy
+		obj      typed.YAMLObject
+		expected typed.YAMLObject
+	}{{
+		name:     "distinct",
+		policy:   typed.EmptyIsDistinct,
+		obj:      `{"labels": {}, "args": null}`,
+		expected: `{"labels": {}, "args": null}`,
+	}, {
+		name:     "null",
+		policy:   typed.EmptyEqualsNull,
+		obj:      `{"labels": {}, "args": [], "name": null, "nested": {"labels": {}}}`,
+		expected: `{"labels": null, "args": null, "name": null, "nested": {"labels": null}}`,
+	}, {
+		name:     "absent",
+		policy:   typed.EmptyEqualsAbsent,
+		obj:      `{"labels": {}, "args": null, "name": null, "nested": {"labels": {}}}`,
+		expected: `{"name": null}`,
+	}, {
+		name:     "list items are kept",
+		policy:   typed.EmptyEqualsAbsent,
+		obj:      `{"items": [{}, {"args": []}]}`,
+		expected: `{"items": [{}, {}]}`,
+	}, {
+		name:     "unchanged",
+		policy:   typed.EmptyEqualsAbsent,
+		obj:      `{"labels": {"a": "b"}, "args": ["c"]}`,
+		expected: `{"labels": {"a": "b"}, "args": ["c"]}`,
+	}}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			pt := emptyCollectionsParser.Type("type")
+			tv, err := pt.FromYAML(tt.obj)
+			if err != nil {
+				t.Fatal(err)
+			}
+			expected, err := pt.FromYAML(tt.expected)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := tv.NormalizeEmptyCollections(tt.policy)
+			if cmp, err := got.Compare(expected); err != nil || !cmp.IsSame() {
+				t.Errorf("expected %v, got %v", expected.AsValue(), got.AsValue())
+			}
+		})
+	}
+}
+
+func TestCompareWithEmptyCollections(t *testing.T) {
+	pt := emptyCollectionsParser.Type("type")
+	lhs, err := pt.FromYAML(`{"name": "a", "labels": {}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rhs, err := pt.FromYAML(`{"name": "a", "args": null}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmp, err := lhs.Compare(rhs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cmp.IsSame() {
+		t.Errorf("expected empty collections to be distinct by default")
+	}
+	cmp, err = lhs.Compare(rhs, typed.WithEmptyCollections(typed.EmptyEqualsNull))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cmp.Removed.Equals(_NS(_P("labels"))) || !cmp.Added.Equals(_NS(_P("args"))) {
+		t.Errorf("expected null and absent collections to be distinct, got %v", cmp)
+	}
+	cmp, err = lhs.Compare(rhs, typed.WithEmptyCollections(typed.EmptyEqualsAbsent))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cmp.IsSame() {
+		t.Errorf("expected no difference, got %v", cmp)
+	}
+}
@@ -0,0 +1,84 @@
+//go:build go1.18
+// +build go1.18
+
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package typed_test
+
+import (
+	"testing"
+
+	"sigs.k8s.io/structured-merge-diff/v4/typed"
+	"sigs.k8s.io/structured-merge-diff/v4/value"
+)
+
+// The seeds of the fuzz targets are in testdata/fuzz, along with the inputs
+// of the crashes found by fuzzing, which go test runs as regression tests.
+
+// FuzzFromYAML checks that parsing untrusted YAML objects doesn't panic, and
+// that the objects it accepts survive a round-trip through YAML.
+func FuzzFromYAML(f *testing.F) {
+	f.Add([]byte(`{"a": 1, "b": [true, null, "c"], "d": {"e": 1.5}}`))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		tv, err := typed.DeducedParseableType.FromYAML(typed.YAMLObject(data))
+		if err != nil {
+			return
+		}
+		out, err := tv.ToYAML()
+		if err != nil {
+			t.Fatalf("failed to serialize %v: %v", value.ToString(tv.AsValue()), err)
+		}
+		again, err := typed.DeducedParseableType.FromYAML(typed.YAMLObject(out))
+		if err != nil {
+			t.Fatalf("failed to parse %q: %v", out, err)
+		}
+		if value.Equals(tv.AsValue(), again.AsValue()) {
+			return
+		}
+		// NaNs aren't Equal, compare the serializations.
+		if outAgain, err := again.ToYAML(); err != nil || string(outAgain) != string(out) {
+			t.Fatalf("expected %q after a round-trip, got %q, %v", out, outAgain, err)
+		}
+	})
+}
+
+// FuzzNewParser checks that parsing untrusted schemas doesn't panic, nor
+// does parsing objects of their types.
+func FuzzNewParser(f *testing.F) {
+	f.Add([]byte(`types:
+- name: a
+  map:
+    fields:
+    - name: b
+      type:
+        scalar: string`), []byte(`{"b": "c"}`))
+	f.Fuzz(func(t *testing.T, schema, object []byte) {
+		parser, err := typed.NewParser(typed.YAMLObject(schema))
+		if err != nil {
+			return
+		}
+		for _, name := range parser.TypeNames() {
+			tv, err := parser.Type(name).FromYAML(typed.YAMLObject(object))
+			if err != nil {
+				continue
+			}
+			if _, err := tv.ToFieldSet(); err != nil {
+				t.Fatalf("failed to get the fields of a valid object: %v", err)
+			}
+		}
+	})
+}
@@ -184,8 +184,8 @@ func mapValue(a value.Allocator, val value.Value) (value.Map, error) {
 	return val.AsMapUsing(a), nil
 }
 
-func getAssociativeKeyDefault(s *schema.Schema, list *schema.List, fieldName string) (interface{}, error) {
-	atom, ok := s.Resolve(list.ElementType)
+func getAssociativeKeyDefault(s *schema.Schema, elementType schema.TypeRef, fieldName string) (interface{}, error) {
+	atom, ok := s.Resolve(elementType)
 	if !ok {
 		return nil, errors.New("invalid elementType for list")
 	}
@@ -197,7 +197,7 @@ func getAssociativeKeyDefault(s *schema.Schema, list *schema.List, fieldName str
 	return field.Default, nil
 }
 
-func keyedAssociativeListItemToPathElement(a value.Allocator, s *schema.Schema, list *schema.List, child value.Value) (fieldpath.PathElement, error) {
+func keyedAssociativeListItemToPathElement(a value.Allocator, s *schema.Schema, list *schema.List, folders []keyFolder, child value.Value) (fieldpath.PathElement, error) {
 	pe := fieldpath.PathElement{}
 	if child.IsNull() {
 		// null entries are illegal.
@@ -209,10 +209,16 @@ func keyedAssociativeListItemToPathElement(a value.Allocator, s *schema.Schema,
 	keyMap := value.FieldList{}
 	m := child.AsMapUsing(a)
 	defer a.Free(m)
+	elementType := listElementType(a, list, child)
+	if list.Discriminator != "" && !hasKey(list, list.Discriminator) {
+		if val, ok := m.Get(list.Discriminator); ok {
+			keyMap = append(keyMap, value.Field{Name: list.Discriminator, Value: val})
+		}
+	}
 	for _, fieldName := range list.Keys {
 		if val, ok := m.Get(fieldName); ok {
 			keyMap = append(keyMap, value.Field{Name: fieldName, Value: val})
-		} else if def, err := getAssociativeKeyDefault(s, list, fieldName); err != nil {
+		} else if def, err := getAssociativeKeyDefault(s, elementType, fieldName); err != nil {
 			return pe, fmt.Errorf("couldn't find default value for %v: %v", fieldName, err)
 		} else if def != nil {
 			keyMap = append(keyMap, value.Field{Name: fieldName, Value: value.NewValueInterface(def)})
@@ -220,17 +226,63 @@ func keyedAssociativeListItemToPathElement(a value.Allocator, s *schema.Schema,
 			return pe, fmt.Errorf("associative list with keys has an element that omits key field %q (and doesn't have default value)", fieldName)
 		}
 	}
+	for _, f := range folders {
+		for i := range keyMap {
+			if keyMap[i].Name != f.key || !keyMap[i].Value.IsString() {
+				continue
+			}
+			if f.fold == nil {
+				return pe, fmt.Errorf("unknown collation %q for key field %q", f.collation, f.key)
+			}
+			keyMap[i].Value = value.NewValueInterface(f.fold(keyMap[i].Value.AsString()))
+		}
+	}
+	// Long values are hashed once folded, so that equivalent keys have
+	// the same hash.
+	for i := range keyMap {
+		keyMap[i].Value = fieldpath.SafePathElementValue(keyMap[i].Value)
+	}
 	keyMap.Sort()
 	pe.Key = &keyMap
 	return pe, nil
 }
 
-func setItemToPathElement(child value.Value) (fieldpath.PathElement, error) {
+func hasKey(list *schema.List, fieldName string) bool {
+	for _, key := range list.Keys {
+		if key == fieldName {
+			return true
+		}
+	}
+	return false
+}
+
+// listElementType returns the type of child, an element of list, which
+// depends on its discriminator if the list is heterogeneous.
+func listElementType(a value.Allocator, list *schema.List, child value.Value) schema.TypeRef {
+	if list.Discriminator == "" || child == nil || !child.IsMap() {
+		return list.ElementType
+	}
+	m := child.AsMapUsing(a)
+	defer a.Free(m)
+	if d, ok := m.Get(list.Discriminator); ok && d.IsString() {
+		tr, _ := list.ElementTypeFor(d.AsString())
+		return tr
+	}
+	return list.ElementType
+}
+
+func setItemToPathElement(s *schema.Schema, list *schema.List, child value.Value) (fieldpath.PathElement, error) {
 	pe := fieldpath.PathElement{}
 	switch {
 	case child.IsMap():
-		// TODO: atomic maps should be acceptable.
-		return pe, errors.New("associative list without keys has an element that's a map type")
+		// Maps are compared by deep equality, and are thus only
+		// acceptable if they're atomic.
+		if a, ok := s.Resolve(list.ElementType); !ok || a.Map == nil || a.Map.ElementRelationship != schema.Atomic {
+			return pe, errors.New("associative list without keys has an element that's a non-atomic map type")
+		}
+		v := fieldpath.SafePathElementValue(child)
+		pe.Value = &v
+		return pe, nil
 	case child.IsList():
 		// Should we support a set of lists? For the moment
 		// let's say we don't.
@@ -240,20 +292,46 @@ func setItemToPathElement(child value.Value) (fieldpath.PathElement, error) {
 		return pe, errors.New("associative list without keys has an element that's an explicit null")
 	default:
 		// We are a set type.
-		pe.Value = &child
+		v := fieldpath.SafePathElementValue(child)
+		pe.Value = &v
 		return pe, nil
 	}
 }
 
-func listItemToPathElement(a value.Allocator, s *schema.Schema, list *schema.List, child value.Value) (fieldpath.PathElement, error) {
+// keyFolder is the folding function of the collation of a key field.
+type keyFolder struct {
+	key       string
+	collation schema.Collation
+	// fold is nil if the collation isn't registered.
+	fold func(string) string
+}
+
+// keyFolders returns the folding functions of the key collations of list,
+// so that they are resolved once per list rather than once per item.
+func keyFolders(list *schema.List) []keyFolder {
+	if list == nil || len(list.KeyCollations) == 0 {
+		return nil
+	}
+	folders := make([]keyFolder, len(list.KeyCollations))
+	for i, kc := range list.KeyCollations {
+		folders[i] = keyFolder{key: kc.Key, collation: kc.Collation}
+		folders[i].fold, _ = kc.Collation.Folder()
+	}
+	return folders
+}
+
+// listItemToPathElement returns the path element of child, an item of
+// list whose key folders are folders.
+func listItemToPathElement(a value.Allocator, s *schema.Schema, list *schema.List, folders []keyFolder, child value.Value) (fieldpath.PathElement, error) {
 	if list.ElementRelationship != schema.Associative {
 		return fieldpath.PathElement{}, errors.New("invalid indexing of non-associative list")
 	}
 
 	if len(list.Keys) > 0 {
-		return keyedAssociativeListItemToPathElement(a, s, list, child)
+		return keyedAssociativeListItemToPathElement(a, s, list, folders, child)
 	}
 
-	// If there's no keys, then we must be a set of primitives.
-	return setItemToPathElement(child)
+	// If there's no keys, then we must be a set of primitives or of
+	// atomic maps.
+	return setItemToPathElement(s, list, child)
 }
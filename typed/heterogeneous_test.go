@@ -0,0 +1,179 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package typed_test
+
+import (
+	"strings"
+	"testing"
+
+	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
+	"sigs.k8s.io/structured-merge-diff/v4/typed"
+	"sigs.k8s.io/structured-merge-diff/v4/value"
+)
+
+var heterogeneousParser = func() *typed.Parser {
+	parser, err := typed.NewParser(typed.YAMLObject(`types:
+- name: object
+  map:
+    fields:
+    - name: sources
+      type:
+        list:
+          elementRelationship: associative
+          keys:
+          - name
+          discriminator: kind
+          elementVariants:
+          - discriminatorValue: git
+            type:
+              namedType: gitSource
+          - discriminatorValue: bucket
+            type:
+              namedType: bucketSource
+- name: gitSource
+  map:
+    fields:
+    - name: kind
+      type:
+        scalar: string
+    - name: name
+      type:
+        scalar: string
+    - name: url
+      type:
+        scalar: string
+- name: bucketSource
+  map:
+    fields:
+    - name: kind
+      type:
+        scalar: string
+    - name: name
+      type:
+        scalar: string
+    - name: bucket
+      type:
+        scalar: string
+    - name: region
+      type:
+        scalar: string
+`))
+	if err != nil {
+		panic(err)
+	}
+	return parser
+}()
+
+func sourceKey(kind, name string) fieldpath.PathElement {
+	return fieldpath.PathElement{Key: &value.FieldList{
+		{Name: "kind", Value: value.NewValueInterface(kind)},
+		{Name: "name", Value: value.NewValueInterface(name)},
+	}}
+}
+
+func TestHeterogeneousListValidation(t *testing.T) {
+	pt := heterogeneousParser.Type("object")
+	tests := []struct {
+		object string
+		err    string
+	}{
+		{object: `{"sources": [{"kind": "git", "name": "a", "url": "u"}, {"kind": "bucket", "name": "a", "bucket": "b"}]}`},
+		{
+			object: `{"sources": [{"kind": "git", "name": "a", "bucket": "b"}]}`,
+			err:    `.sources[kind="git",name="a"].bucket: field not declared in schema`,
+		}, {
+			object: `{"sources": [{"kind": "svn", "name": "a"}]}`,
+			err:    `.sources[kind="svn",name="a"]: unknown discriminator value "svn"`,
+		}, {
+			object: `{"sources": [{"name": "a"}]}`,
+			err:    `.sources[name="a"]: missing discriminator field "kind"`,
+		}, {
+			object: `{"sources": [{"kind": "git", "name": "a"}, {"kind": "git", "name": "a"}]}`,
+			err:    `.sources: duplicate entries for key [kind="git",name="a"]`,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.object, func(t *testing.T) {
+			_, err := pt.FromYAML(typed.YAMLObject(tt.object))
+			if tt.err == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("expected error %q, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestHeterogeneousListMerge(t *testing.T) {
+	pt := heterogeneousParser.Type("object")
+	lhs, err := pt.FromYAML(`{"sources": [{"kind": "git", "name": "a", "url": "u"}, {"kind": "bucket", "name": "b", "bucket": "b1"}]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rhs, err := pt.FromYAML(`{"sources": [{"kind": "bucket", "name": "a", "bucket": "b2"}, {"kind": "bucket", "name": "b", "region": "r"}]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	merged, err := lhs.Merge(rhs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, err := pt.FromYAML(`{"sources": [` +
+		`{"kind": "git", "name": "a", "url": "u"}, ` +
+		`{"kind": "bucket", "name": "a", "bucket": "b2"}, ` +
+		`{"kind": "bucket", "name": "b", "bucket": "b1", "region": "r"}]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !value.Equals(merged.AsValue(), expected.AsValue()) {
+		t.Errorf("expected merged object %v, got %v", value.ToString(expected.AsValue()), value.ToString(merged.AsValue()))
+	}
+
+	set, err := merged.ToFieldSet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []fieldpath.Path{
+		fieldpath.MakePathOrDie("sources", sourceKey("git", "a"), "url"),
+		fieldpath.MakePathOrDie("sources", sourceKey("bucket", "a"), "bucket"),
+		fieldpath.MakePathOrDie("sources", sourceKey("bucket", "b"), "region"),
+	} {
+		if !set.Has(p) {
+			t.Errorf("expected %v in the field set:\n%v", p, set)
+		}
+	}
+
+	comparison, err := lhs.Compare(rhs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !comparison.Removed.Has(fieldpath.MakePathOrDie("sources", sourceKey("git", "a"))) {
+		t.Errorf("expected the git source to be removed, got %v", comparison)
+	}
+	if !comparison.Added.Has(fieldpath.MakePathOrDie("sources", sourceKey("bucket", "a"))) {
+		t.Errorf("expected the bucket source to be added, got %v", comparison)
+	}
+	if !comparison.Added.Has(fieldpath.MakePathOrDie("sources", sourceKey("bucket", "b"), "region")) {
+		t.Errorf("expected the region to be added, got %v", comparison)
+	}
+}
@@ -0,0 +1,120 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package typed
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"sigs.k8s.io/structured-merge-diff/v4/schema"
+	"sigs.k8s.io/structured-merge-diff/v4/value"
+)
+
+// AsTypedLazy is like AsTyped, but defers the validation of v until an
+// operation needs a valid object: Merge, Compare and ToFieldSet validate
+// each part of v as they walk it, and fail with the validation errors of
+// the parts they walked, if any. Once an operation has walked all of v
+// without errors, v is validated and isn't validated again; ToFieldSet
+// with WithFieldSetPatterns only walks, and validates, the parts of v that
+// the patterns may match. The operations which don't need a valid object,
+// e.g. AsValue, ToYAML, ExtractItems or Stats, don't validate it, so that
+// objects which are never merged, compared nor turned into field sets
+// don't pay for it.
+//
+// The TypedValues returned by RemoveItems and ExtractItems are deferred
+// too, and their validation only covers the fields they kept: to only
+// validate the part of a big object which is used, extract it first.
+// Merge returns validated TypedValues.
+func AsTypedLazy(v value.Value, s *schema.Schema, typeRef schema.TypeRef, opts ...ValidationOptions) *TypedValue {
+	return &TypedValue{
+		value:   v,
+		typeRef: typeRef,
+		schema:  s,
+		lazy:    &lazyValidation{opts: opts},
+	}
+}
+
+// lazyValidation is the deferred validation of a TypedValue, see
+// AsTypedLazy.
+type lazyValidation struct {
+	opts []ValidationOptions
+	once sync.Once
+	done int32
+	err  error
+}
+
+// IsLazy returns whether the validation of tv is deferred, i.e. whether it
+// is built by AsTypedLazy and not validated yet.
+func (tv *TypedValue) IsLazy() bool {
+	return tv != nil && tv.lazy != nil && atomic.LoadInt32(&tv.lazy.done) == 0
+}
+
+// deferred returns the deferred validation of tv, nil unless tv is lazy.
+func (tv *TypedValue) deferred() *lazyValidation {
+	if !tv.IsLazy() {
+		return nil
+	}
+	return tv.lazy
+}
+
+// before validates v, a node of type tr of the lazy value that a walker is
+// about to visit, but not its children, which the walker visits next.
+func (l *lazyValidation) before(s *schema.Schema, tr schema.TypeRef, v value.Value) ValidationErrors {
+	if l == nil || v == nil {
+		return nil
+	}
+	w := validatingWalker(v, s, tr)
+	defer w.finished()
+	w.setOptions(l.opts)
+	w.shallow = true
+	return w.validate(nil)
+}
+
+// after validates the children of v, a node of type tr of the lazy value
+// that a walker has visited, unless it descended into them.
+func (l *lazyValidation) after(s *schema.Schema, tr schema.TypeRef, v value.Value, descended bool) ValidationErrors {
+	if l == nil || v == nil || descended || (!v.IsMap() && !v.IsList()) {
+		return nil
+	}
+	w := validatingWalker(v, s, tr)
+	defer w.finished()
+	w.setOptions(l.opts)
+	return w.validate(nil)
+}
+
+// valid records that the walk of an operation validated all of the lazy
+// value.
+func (l *lazyValidation) valid() {
+	if l == nil {
+		return
+	}
+	l.once.Do(func() {
+		atomic.StoreInt32(&l.done, 1)
+	})
+}
+
+// validated validates tv, once, if its validation is deferred.
+func (tv *TypedValue) validated() error {
+	if tv == nil || tv.lazy == nil {
+		return nil
+	}
+	tv.lazy.once.Do(func() {
+		tv.lazy.err = tv.Validate(tv.lazy.opts...)
+		atomic.StoreInt32(&tv.lazy.done, 1)
+	})
+	return tv.lazy.err
+}
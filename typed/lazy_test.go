@@ -0,0 +1,171 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package typed_test
+
+import (
+	"strings"
+	"testing"
+
+	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
+	"sigs.k8s.io/structured-merge-diff/v4/typed"
+	"sigs.k8s.io/structured-merge-diff/v4/value"
+)
+
+func TestAsTypedLazy(t *testing.T) {
+	pt := overlayParser.Type("service")
+	tests := []struct {
+		name   string
+		object string
+		valid  bool
+	}{
+		{
+			name:   "valid",
+			object: `{"name": "a", "ports": [{"name": "http", "port": 80}]}`,
+			valid:  true,
+		}, {
+			name:   "invalid scalar",
+			object: `{"name": 1}`,
+		}, {
+			name:   "unknown field",
+			object: `{"name": "a", "unknown": true}`,
+		}, {
+			name:   "duplicate keys",
+			object: `{"ports": [{"name": "http"}, {"name": "http"}]}`,
+		}, {
+			name:   "invalid item",
+			object: `{"name": "a", "ports": [{"name": "http", "port": "80"}]}`,
+		}, {
+			name:   "invalid atomic list",
+			object: `{"name": "a", "args": [1]}`,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := value.FromJSON([]byte(tt.object))
+			if err != nil {
+				t.Fatal(err)
+			}
+			operations := map[string]func(tv *typed.TypedValue) error{
+				"Merge": func(tv *typed.TypedValue) error {
+					_, err := tv.Merge(tv)
+					return err
+				},
+				"Compare": func(tv *typed.TypedValue) error {
+					_, err := tv.Compare(tv)
+					return err
+				},
+				"ToFieldSet": func(tv *typed.TypedValue) error {
+					_, err := tv.ToFieldSet()
+					return err
+				},
+			}
+			for name, op := range operations {
+				tv := typed.AsTypedLazy(v, pt.Schema, pt.TypeRef)
+				if !tv.IsLazy() {
+					t.Fatalf("%v: expected a lazy object", name)
+				}
+				if _, err := tv.ToYAML(); err != nil {
+					t.Fatalf("%v: expected ToYAML not to validate, got %v", name, err)
+				}
+				if !tv.ExtractItems(_NS(_P("name"))).IsLazy() {
+					t.Errorf("%v: expected ExtractItems to return a lazy object", name)
+				}
+				if !tv.IsLazy() {
+					t.Fatalf("%v: expected the object not to be validated yet", name)
+				}
+				err := op(tv)
+				if tt.valid && err != nil {
+					t.Errorf("%v: unexpected error: %v", name, err)
+				}
+				if !tt.valid && err == nil {
+					t.Errorf("%v: expected a validation error", name)
+				}
+				// Invalid objects stay lazy, their validation may
+				// have stopped at the first invalid part.
+				if tt.valid && tv.IsLazy() {
+					t.Errorf("%v: expected the object to be validated", name)
+				}
+				if again := op(tv); (err == nil) != (again == nil) {
+					t.Errorf("%v: expected the same result twice, got %v and %v", name, err, again)
+				}
+			}
+		})
+	}
+}
+
+func TestAsTypedLazyExtracted(t *testing.T) {
+	pt := overlayParser.Type("service")
+	v, err := value.FromJSON([]byte(`{"name": "a", "ports": [{"name": "http", "port": 80}], "unknown": true}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tv := typed.AsTypedLazy(v, pt.Schema, pt.TypeRef)
+	// The invalid field is left out of the extracted object, and never
+	// validated.
+	extracted := tv.ExtractItems(_NS(_P("name"), _P("ports")))
+	if _, err := extracted.ToFieldSet(); err != nil {
+		t.Errorf("expected the extracted fields to be valid, got %v", err)
+	}
+	if _, err := extracted.Merge(extracted); err != nil {
+		t.Errorf("expected the extracted fields to be valid, got %v", err)
+	}
+	if !tv.IsLazy() {
+		t.Error("expected the object not to be validated")
+	}
+	if _, err := tv.ToFieldSet(); err == nil {
+		t.Error("expected the whole object to be invalid")
+	}
+}
+
+func TestAsTypedLazyWalked(t *testing.T) {
+	pt := overlayParser.Type("service")
+	parse := func(object string, opts ...typed.ValidationOptions) *typed.TypedValue {
+		v, err := value.FromJSON([]byte(object))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return typed.AsTypedLazy(v, pt.Schema, pt.TypeRef, opts...)
+	}
+
+	// The patterns leave the invalid field out of the walk, which doesn't
+	// validate it.
+	tv := parse(`{"name": "a", "labels": {"a": 1}}`)
+	set, err := tv.ToFieldSet(typed.WithFieldSetPatterns(fieldpath.ParsePatternOrDie(".name")))
+	if err != nil {
+		t.Fatalf("expected the walked fields to be valid, got %v", err)
+	}
+	if !set.Equals(_NS(_P("name"))) {
+		t.Errorf("unexpected field set: %v", set)
+	}
+	if !tv.IsLazy() {
+		t.Error("expected the object not to be validated")
+	}
+	if _, err := tv.ToFieldSet(); err == nil || !strings.Contains(err.Error(), ".labels.a") {
+		t.Errorf("expected an error at .labels.a, got %v", err)
+	}
+
+	// The walkers don't visit the duplicated items, which are validated
+	// as a whole.
+	tv = parse(`{"ports": [{"name": "http"}, {"name": "http", "port": "80"}]}`, typed.AllowDuplicates)
+	if _, err := tv.Merge(parse(`{"name": "a"}`)); err == nil || !strings.Contains(err.Error(), `.ports[name="http"].port`) {
+		t.Errorf("expected an error at the duplicated item, got %v", err)
+	}
+	if !tv.IsLazy() {
+		t.Error("expected the invalid object not to be validated")
+	}
+}
@@ -0,0 +1,290 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package typed
+
+import (
+	"bytes"
+	"fmt"
+
+	jsoniter "github.com/json-iterator/go"
+
+	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
+	"sigs.k8s.io/structured-merge-diff/v4/schema"
+	"sigs.k8s.io/structured-merge-diff/v4/value"
+)
+
+// UntypedLimits bounds the untyped content of an object, i.e. the values
+// of the fields whose type is an untyped scalar, like the
+// x-kubernetes-preserve-unknown-fields subtrees of custom resources. Since
+// the schema doesn't bound their shape, they are where adversarial nesting
+// can make every walker of the object recurse and allocate without limit.
+// Limits which aren't positive aren't enforced.
+type UntypedLimits struct {
+	// MaxDepth is the maximum nesting of the maps and lists of each untyped
+	// value, e.g. 1 allows a map of scalars but not a map of maps.
+	MaxDepth int
+	// MaxNodes is the maximum number of scalars, list items and map entries
+	// of all the untyped values of the object together.
+	MaxNodes int
+}
+
+// UntypedLimitError is returned when the untyped content of an object
+// exceeds UntypedLimits.
+type UntypedLimitError struct {
+	// Path is the untyped field whose value exceeds the limit.
+	Path fieldpath.Path
+	// Limit is the name of the exceeded limit, "MaxDepth" or "MaxNodes".
+	Limit string
+	// Max is the value of the exceeded limit.
+	Max int
+}
+
+func (e *UntypedLimitError) Error() string {
+	if len(e.Path) == 0 {
+		return fmt.Sprintf("untyped value exceeds %v of %v", e.Limit, e.Max)
+	}
+	return fmt.Sprintf("%v: untyped value exceeds %v of %v", e.Path, e.Limit, e.Max)
+}
+
+// WithUntypedLimits returns a copy of p whose FromYAML, FromUnstructured and
+// FromStructured fail with an *UntypedLimitError, before validating the
+// object, if its untyped content exceeds limits. FromYAML measures the JSON
+// documents as their tokens are read, and fails before decoding them, the
+// other YAML documents are measured once decoded.
+func (p ParseableType) WithUntypedLimits(limits UntypedLimits) ParseableType {
+	p.untypedLimits = limits
+	return p
+}
+
+// asTyped checks the untyped limits of p, if any, applies its coercions and
+// returns v as a validated TypedValue.
+func (p ParseableType) asTyped(v value.Value, opts []ValidationOptions) (*TypedValue, error) {
+	if err := checkUntypedLimits(p.Schema, p.TypeRef, v, p.untypedLimits); err != nil {
+		return nil, err
+	}
+	if p.coercions.enabled() {
+		v = p.coercions.coerce(p.Schema, p.TypeRef, v)
+	}
+	return AsTyped(v, p.Schema, p.TypeRef, opts...)
+}
+
+// WithFieldSetUntypedLimits configures ToFieldSet to fail with an
+// *UntypedLimitError if the untyped content of the value exceeds limits.
+func WithFieldSetUntypedLimits(limits UntypedLimits) ToFieldSetOption {
+	return func(opts *toFieldSetOptions) {
+		opts.untypedLimits = limits
+	}
+}
+
+// CheckUntypedLimits returns an *UntypedLimitError if the untyped content
+// of tv exceeds limits.
+func (tv *TypedValue) CheckUntypedLimits(limits UntypedLimits) error {
+	return checkUntypedLimits(tv.schema, tv.typeRef, tv.value, limits)
+}
+
+func checkUntypedLimits(s *schema.Schema, tr schema.TypeRef, v value.Value, limits UntypedLimits) error {
+	if limits.MaxDepth <= 0 && limits.MaxNodes <= 0 {
+		return nil
+	}
+	w := untypedLimitsWalker{schema: s, limits: limits}
+	w.walk(tr, v)
+	if w.err != nil {
+		return w.err
+	}
+	return nil
+}
+
+type untypedLimitsWalker struct {
+	schema *schema.Schema
+	limits UntypedLimits
+	path   fieldpath.Path
+	nodes  int
+	err    *UntypedLimitError
+}
+
+// walk follows the schema down to the untyped values of v, and measures
+// them. Values which don't match the schema are skipped, validation reports
+// them. It returns false once a limit is exceeded.
+func (w *untypedLimitsWalker) walk(tr schema.TypeRef, v value.Value) bool {
+	a, ok := w.schema.Resolve(tr)
+	if !ok || v == nil || v.IsNull() {
+		return true
+	}
+	if a.Scalar != nil && *a.Scalar == schema.Untyped {
+		return w.measure(v, 0)
+	}
+	switch {
+	case a.Map != nil && v.IsMap():
+		ok := true
+		v.AsMap().Iterate(func(key string, child value.Value) bool {
+			ctr := a.Map.ElementType
+			if sf, found := a.Map.FindField(key); found {
+				ctr = sf.Type
+			}
+			w.path = append(w.path, fieldpath.PathElement{FieldName: &key})
+			ok = w.walk(ctr, child)
+			w.path = w.path[:len(w.path)-1]
+			return ok
+		})
+		return ok
+	case a.List != nil && v.IsList():
+		l := v.AsList()
+		for i := 0; i < l.Length(); i++ {
+			i := i
+			w.path = append(w.path, fieldpath.PathElement{Index: &i})
+			ok := w.walk(a.List.ElementType, l.At(i))
+			w.path = w.path[:len(w.path)-1]
+			if !ok {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// measure counts the nodes of the untyped value v, at the given depth
+// below the untyped field, and returns false if it exceeds a limit.
+func (w *untypedLimitsWalker) measure(v value.Value, depth int) bool {
+	w.nodes++
+	if w.limits.MaxNodes > 0 && w.nodes > w.limits.MaxNodes {
+		w.fail("MaxNodes", w.limits.MaxNodes)
+		return false
+	}
+	if !v.IsMap() && !v.IsList() {
+		return true
+	}
+	if w.limits.MaxDepth > 0 && depth >= w.limits.MaxDepth {
+		if (v.IsMap() && v.AsMap().Length() > 0) || (v.IsList() && v.AsList().Length() > 0) {
+			w.fail("MaxDepth", w.limits.MaxDepth)
+			return false
+		}
+		return true
+	}
+	ok := true
+	if v.IsMap() {
+		v.AsMap().Iterate(func(_ string, child value.Value) bool {
+			ok = w.measure(child, depth+1)
+			return ok
+		})
+		return ok
+	}
+	l := v.AsList()
+	for i := 0; i < l.Length() && ok; i++ {
+		ok = w.measure(l.At(i), depth+1)
+	}
+	return ok
+}
+
+func (w *untypedLimitsWalker) fail(limit string, max int) {
+	w.err = &UntypedLimitError{Path: w.path.Copy(), Limit: limit, Max: max}
+}
+
+// checkJSONUntypedLimits measures the untyped content of data, a document of
+// type tr, as its tokens are read, and returns an *UntypedLimitError as soon
+// as it exceeds limits, without decoding the document. Documents which
+// aren't JSON are left to the YAML decoder, and so are syntax errors.
+func checkJSONUntypedLimits(s *schema.Schema, tr schema.TypeRef, data []byte, limits UntypedLimits) error {
+	if limits.MaxDepth <= 0 && limits.MaxNodes <= 0 {
+		return nil
+	}
+	if trimmed := bytes.TrimLeft(data, " \t\r\n"); len(trimmed) == 0 || (trimmed[0] != '{' && trimmed[0] != '[') {
+		return nil
+	}
+	iter := jsoniter.ConfigCompatibleWithStandardLibrary.BorrowIterator(data)
+	defer jsoniter.ConfigCompatibleWithStandardLibrary.ReturnIterator(iter)
+	w := untypedLimitsWalker{schema: s, limits: limits}
+	w.scan(iter, tr)
+	if w.err != nil {
+		return w.err
+	}
+	return nil
+}
+
+// scan is like walk, for the value read by iter. The values which don't
+// match the schema are skipped.
+func (w *untypedLimitsWalker) scan(iter *jsoniter.Iterator, tr schema.TypeRef) bool {
+	a, ok := w.schema.Resolve(tr)
+	next := iter.WhatIsNext()
+	switch {
+	case !ok || next == jsoniter.NilValue:
+		iter.Skip()
+	case a.Scalar != nil && *a.Scalar == schema.Untyped:
+		return w.measureTokens(iter, 0)
+	case a.Map != nil && next == jsoniter.ObjectValue:
+		ok := true
+		iter.ReadMapCB(func(iter *jsoniter.Iterator, key string) bool {
+			ctr := a.Map.ElementType
+			if sf, found := a.Map.FindField(key); found {
+				ctr = sf.Type
+			}
+			w.path = append(w.path, fieldpath.PathElement{FieldName: &key})
+			ok = w.scan(iter, ctr)
+			w.path = w.path[:len(w.path)-1]
+			return ok
+		})
+		return ok && iter.Error == nil
+	case a.List != nil && next == jsoniter.ArrayValue:
+		ok, i := true, 0
+		iter.ReadArrayCB(func(iter *jsoniter.Iterator) bool {
+			index := i
+			w.path = append(w.path, fieldpath.PathElement{Index: &index})
+			ok = w.scan(iter, a.List.ElementType)
+			w.path = w.path[:len(w.path)-1]
+			i++
+			return ok
+		})
+		return ok && iter.Error == nil
+	default:
+		iter.Skip()
+	}
+	return iter.Error == nil
+}
+
+// measureTokens is like measure, for the untyped value read by iter.
+func (w *untypedLimitsWalker) measureTokens(iter *jsoniter.Iterator, depth int) bool {
+	w.nodes++
+	if w.limits.MaxNodes > 0 && w.nodes > w.limits.MaxNodes {
+		w.fail("MaxNodes", w.limits.MaxNodes)
+		return false
+	}
+	// The children of the values at the maximum depth exceed it.
+	child := func() bool {
+		if w.limits.MaxDepth > 0 && depth >= w.limits.MaxDepth {
+			w.fail("MaxDepth", w.limits.MaxDepth)
+			return false
+		}
+		return w.measureTokens(iter, depth+1)
+	}
+	switch iter.WhatIsNext() {
+	case jsoniter.ObjectValue:
+		ok := true
+		iter.ReadMapCB(func(iter *jsoniter.Iterator, _ string) bool {
+			ok = child()
+			return ok
+		})
+		return ok && iter.Error == nil
+	case jsoniter.ArrayValue:
+		ok := true
+		iter.ReadArrayCB(func(iter *jsoniter.Iterator) bool {
+			ok = child()
+			return ok
+		})
+		return ok && iter.Error == nil
+	}
+	iter.Skip()
+	return iter.Error == nil
+}
@@ -0,0 +1,134 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package typed_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"sigs.k8s.io/structured-merge-diff/v4/typed"
+)
+
+func nested(depth int) string {
+	return strings.Repeat(`{"a": `, depth) + "1" + strings.Repeat("}", depth)
+}
+
+func TestUntypedLimits(t *testing.T) {
+	tests := []struct {
+		name   string
+		object typed.YAMLObject
+		limits typed.UntypedLimits
+		// err is the path and limit of the expected error, if any.
+		err string
+	}{{
+		name:   "no limits",
+		object: typed.YAMLObject(`{"blob": ` + nested(100) + `}`),
+	}, {
+		name:   "within depth",
+		object: typed.YAMLObject(`{"blob": ` + nested(3) + `}`),
+		limits: typed.UntypedLimits{MaxDepth: 3},
+	}, {
+		name:   "too deep",
+		object: typed.YAMLObject(`{"blob": ` + nested(4) + `}`),
+		limits: typed.UntypedLimits{MaxDepth: 3},
+		err:    ".blob MaxDepth",
+	}, {
+		name:   "empty collections don't nest",
+		object: `{"blob": {"a": {"b": [], "c": {}}}}`,
+		limits: typed.UntypedLimits{MaxDepth: 2},
+	}, {
+		name:   "within nodes",
+		object: `{"name": "n", "blob": [1, 2, 3]}`,
+		limits: typed.UntypedLimits{MaxNodes: 4},
+	}, {
+		name:   "too many nodes",
+		object: `{"name": "n", "blob": [1, 2, 3, 4]}`,
+		limits: typed.UntypedLimits{MaxNodes: 4},
+		err:    ".blob MaxNodes",
+	}, {
+		name:   "typed fields aren't counted",
+		object: `{"name": "a very long name", "blob": 1}`,
+		limits: typed.UntypedLimits{MaxDepth: 1, MaxNodes: 1},
+	}, {
+		name:   "yaml too deep",
+		object: "blob:\n  a:\n    b:\n      c: 1\n",
+		limits: typed.UntypedLimits{MaxDepth: 2},
+		err:    ".blob MaxDepth",
+	}, {
+		name:   "yaml too many nodes",
+		object: "name: x\nblob:\n- 1\n- 2\n",
+		limits: typed.UntypedLimits{MaxNodes: 2},
+		err:    ".blob MaxNodes",
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pt := untypedParser.Type("type").WithUntypedLimits(test.limits)
+			_, parseErr := pt.FromYAML(test.object)
+
+			tv, err := untypedParser.Type("type").FromYAML(test.object)
+			if err != nil {
+				t.Fatalf("failed to parse object: %v", err)
+			}
+			_, setErr := tv.ToFieldSet(typed.WithFieldSetUntypedLimits(test.limits))
+
+			for _, err := range []error{parseErr, setErr} {
+				if test.err == "" {
+					if err != nil {
+						t.Errorf("unexpected error: %v", err)
+					}
+					continue
+				}
+				var limitErr *typed.UntypedLimitError
+				if !errors.As(err, &limitErr) {
+					t.Fatalf("expected an UntypedLimitError, got %v", err)
+				}
+				if got := limitErr.Path.String() + " " + limitErr.Limit; got != test.err {
+					t.Errorf("expected error %q, got %q", test.err, got)
+				}
+			}
+		})
+	}
+}
+
+func TestUntypedLimitsDeduced(t *testing.T) {
+	pt := typed.DeducedParseableType.WithUntypedLimits(typed.UntypedLimits{MaxDepth: 10})
+	if _, err := pt.FromYAML(typed.YAMLObject(nested(10))); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	_, err := pt.FromYAML(typed.YAMLObject(`{"spec": ` + nested(10) + `}`))
+	if err == nil || err.Error() != "untyped value exceeds MaxDepth of 10" {
+		t.Errorf("expected MaxDepth error on the deduced root, got %v", err)
+	}
+}
+
+func TestUntypedLimitsWhileDecoding(t *testing.T) {
+	pt := untypedParser.Type("type").WithUntypedLimits(typed.UntypedLimits{MaxDepth: 64, MaxNodes: 1000})
+	// The documents are cut short: the limits are exceeded before the
+	// decoder would find the syntax error.
+	for _, object := range []string{
+		`{"name": "n", "blob": ` + strings.Repeat(`[`, 100000),
+		`{"blob": [` + strings.Repeat(`1, `, 100000),
+	} {
+		_, err := pt.FromYAML(typed.YAMLObject(object))
+		var limitErr *typed.UntypedLimitError
+		if !errors.As(err, &limitErr) {
+			t.Errorf("expected an UntypedLimitError, got %v", err)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package typed
+
+import (
+	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
+	"sigs.k8s.io/structured-merge-diff/v4/schema"
+	"sigs.k8s.io/structured-merge-diff/v4/value"
+)
+
+// ListIndex holds the keys of the items of the associative lists walked by
+// Merge, Compare and ToFieldSet, so that the operations of the typed values
+// which share it compute them once per list rather than once per operation
+// (see TypedValue.WithListIndex). The values returned by Merge share the
+// index of the live object, with the keys of the lists they merged.
+//
+// Lists are identified by their address (see value.IdentityOf), so the
+// lists of the typed values which share an index must not be modified. The
+// index keeps the lists it has keys for: it should only live as long as a
+// single operation, like Updater.Apply. It isn't safe for concurrent use.
+type ListIndex struct {
+	lists map[listIndexKey]listIndexEntry
+	stats ListIndexStats
+}
+
+// ListIndexStats counts the lists of a ListIndex.
+type ListIndexStats struct {
+	// Hits and Misses count the lists whose keys were found in the index,
+	// and the ones whose keys were computed.
+	Hits, Misses int64
+}
+
+type listIndexKey struct {
+	typeKey
+	identity value.Identity
+}
+
+type listIndexEntry struct {
+	// list is the referent of the list, kept so that its address isn't
+	// reused.
+	list interface{}
+	keys []listItemKey
+}
+
+type listItemKey struct {
+	pe  fieldpath.PathElement
+	err error
+}
+
+// NewListIndex returns an empty index.
+func NewListIndex() *ListIndex {
+	return &ListIndex{lists: map[listIndexKey]listIndexEntry{}}
+}
+
+// Stats returns the number of hits and misses of the index.
+func (x *ListIndex) Stats() ListIndexStats {
+	return x.stats
+}
+
+// WithListIndex returns tv sharing index, or no index if it is nil.
+func (tv *TypedValue) WithListIndex(index *ListIndex) *TypedValue {
+	out := tv.withValue(tv.value)
+	out.lazy = tv.lazy
+	out.index = index
+	return out
+}
+
+// listItemKeys returns the keys of the items of list, whose value is v and
+// type tr, from the index if any.
+func (x *ListIndex) listItemKeys(a value.Allocator, s *schema.Schema, tr schema.TypeRef, t *schema.List, v value.Value, list value.List) listItemKeys {
+	keys := listItemKeys{allocator: a, schema: s, list: t, folders: keyFolders(t)}
+	if x == nil || list == nil || list.Length() == 0 {
+		return keys
+	}
+	id, ok := value.IdentityOf(v)
+	if !ok {
+		return keys
+	}
+	key := listIndexKey{typeKey: keyOfType(s, tr), identity: id}
+	if e, ok := x.lists[key]; ok {
+		x.stats.Hits++
+		keys.keys = e.keys
+		return keys
+	}
+	x.stats.Misses++
+	keys.keys = make([]listItemKey, list.Length())
+	for i := range keys.keys {
+		keys.keys[i].pe, keys.keys[i].err = listItemToPathElement(a, s, t, keys.folders, list.At(i))
+	}
+	x.lists[key] = listIndexEntry{list: value.Referent(v), keys: keys.keys}
+	return keys
+}
+
+// add records the keys of the items of a list built by an operation.
+func (x *ListIndex) add(s *schema.Schema, tr schema.TypeRef, list []interface{}, pes []fieldpath.PathElement) {
+	if x == nil || len(list) == 0 || len(list) != len(pes) {
+		return
+	}
+	v := value.NewValueInterface(list)
+	id, ok := value.IdentityOf(v)
+	if !ok {
+		return
+	}
+	keys := make([]listItemKey, len(pes))
+	for i := range pes {
+		keys[i].pe = pes[i]
+	}
+	x.lists[listIndexKey{typeKey: keyOfType(s, tr), identity: id}] = listIndexEntry{list: list, keys: keys}
+}
+
+// listItemKeys are the keys of the items of a list, computed on demand
+// without an index.
+type listItemKeys struct {
+	keys      []listItemKey
+	allocator value.Allocator
+	schema    *schema.Schema
+	list      *schema.List
+	folders   []keyFolder
+}
+
+// at returns the key of child, the item i of the list.
+func (k listItemKeys) at(i int, child value.Value) (fieldpath.PathElement, error) {
+	if k.keys != nil {
+		return k.keys[i].pe, k.keys[i].err
+	}
+	return listItemToPathElement(k.allocator, k.schema, k.list, k.folders, child)
+}
@@ -0,0 +1,106 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package typed_test
+
+import (
+	"testing"
+
+	"sigs.k8s.io/structured-merge-diff/v4/typed"
+	"sigs.k8s.io/structured-merge-diff/v4/value"
+)
+
+func TestListIndex(t *testing.T) {
+	tests := []struct {
+		name     string
+		lhs, rhs []int
+	}{{
+		name: "same items",
+		lhs:  []int{1, 2, 3},
+		rhs:  []int{1, 2, 3},
+	}, {
+		name: "added and removed",
+		lhs:  []int{1, 2, 3, 4},
+		rhs:  []int{3, 4, 5, 6},
+	}, {
+		name: "reordered",
+		lhs:  []int{1, 2, 3, 4},
+		rhs:  []int{4, 2, 3},
+	}, {
+		name: "empty",
+		lhs:  []int{1, 2},
+		rhs:  []int{},
+	}}
+
+	pt := chunkedParser.Type("endpoints")
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			lhs, err := pt.FromUnstructured(endpoints(tt.lhs, func(int) bool { return true }))
+			if err != nil {
+				t.Fatal(err)
+			}
+			rhs, err := pt.FromUnstructured(endpoints(tt.rhs, func(ip int) bool { return ip%2 == 0 }))
+			if err != nil {
+				t.Fatal(err)
+			}
+			index := typed.NewListIndex()
+			ilhs, irhs := lhs.WithListIndex(index), rhs.WithListIndex(index)
+
+			want, err := lhs.Merge(rhs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := ilhs.Merge(irhs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !value.Equals(got.AsValue(), want.AsValue()) {
+				t.Errorf("expected merged:\n%v\ngot:\n%v", value.ToString(want.AsValue()), value.ToString(got.AsValue()))
+			}
+
+			wantCmp, err := lhs.Compare(want)
+			if err != nil {
+				t.Fatal(err)
+			}
+			gotCmp, err := ilhs.Compare(got)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !gotCmp.Added.Equals(wantCmp.Added) || !gotCmp.Removed.Equals(wantCmp.Removed) || !gotCmp.Modified.Equals(wantCmp.Modified) {
+				t.Errorf("expected comparison:\n%v\ngot:\n%v", wantCmp, gotCmp)
+			}
+
+			wantSet, err := want.ToFieldSet()
+			if err != nil {
+				t.Fatal(err)
+			}
+			gotSet, err := got.ToFieldSet()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !gotSet.Equals(wantSet) {
+				t.Errorf("expected field set:\n%v\ngot:\n%v", wantSet, gotSet)
+			}
+
+			// The keys of lhs were computed by Merge, and the ones of the
+			// merged list as it was built.
+			if stats := index.Stats(); stats.Hits == 0 {
+				t.Errorf("expected the index to be reused, got %+v", stats)
+			}
+		})
+	}
+}
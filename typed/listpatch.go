@@ -0,0 +1,213 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package typed
+
+import (
+	"fmt"
+	"sort"
+
+	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
+	"sigs.k8s.io/structured-merge-diff/v4/schema"
+	"sigs.k8s.io/structured-merge-diff/v4/value"
+)
+
+// AtomicListPatch edits the items of an atomic list by position.
+//
+// Atomic lists are otherwise only ever replaced as a whole. Positions are
+// only meaningful for the exact list they were computed from, so a patch
+// must be built from the current object, and its result applied like any
+// other change of the list: whoever applies it takes the whole list over.
+type AtomicListPatch struct {
+	// Path is the path of the atomic list.
+	Path fieldpath.Path
+	// Set maps positions to their new items. The position right after
+	// the last item appends to the list, positions are applied in
+	// increasing order.
+	Set map[int]interface{}
+	// Remove lists the positions of the items to remove, once Set is
+	// applied. A position can't be both set and removed.
+	Remove []int
+}
+
+// PatchAtomicList returns a copy of tv with the atomic list at patch.Path
+// edited by position. An error is returned if the path isn't the one of an
+// atomic list, if a position is out of range, or if it is both set and
+// removed. A missing list is patched like an empty one, provided its parent
+// exists. Only the maps and lists along the path are copied, the rest of
+// the object is shared with tv.
+func (tv *TypedValue) PatchAtomicList(patch AtomicListPatch) (*TypedValue, error) {
+	if err := tv.checkAtomicList(patch.Path); err != nil {
+		return nil, err
+	}
+	for _, i := range patch.Remove {
+		if _, ok := patch.Set[i]; ok {
+			return nil, errorf("%v: position %v is both set and removed", patch.Path, i)
+		}
+	}
+	patched, err := tv.patchListAt(tv.value, tv.typeRef, patch.Path, func(list []interface{}) ([]interface{}, error) {
+		return patch.apply(list)
+	})
+	if err != nil {
+		return nil, errorf("%v: %v", patch.Path, err)
+	}
+	return AsTyped(value.NewValueInterface(patched), tv.schema, tv.typeRef)
+}
+
+// checkAtomicList returns an error unless path is the one of an atomic list
+// according to the schema.
+func (tv *TypedValue) checkAtomicList(path fieldpath.Path) error {
+	tr := tv.typeRef
+	for i, pe := range path {
+		a, ok := tv.schema.Resolve(tr)
+		if !ok {
+			return errorf("%v: no type found matching: %v", path[:i], tr)
+		}
+		switch {
+		case pe.FieldName != nil:
+			if a.Map == nil {
+				return errorf("%v: expected a map", path[:i])
+			}
+			if tr, ok = typeRefAtPath(a.Map, pe); !ok {
+				return errorf("%v: no field %q", path[:i], *pe.FieldName)
+			}
+		default:
+			if a.List == nil {
+				return errorf("%v: expected a list", path[:i])
+			}
+			tr = a.List.ElementType
+		}
+	}
+	a, ok := tv.schema.Resolve(tr)
+	if !ok {
+		return errorf("%v: no type found matching: %v", path, tr)
+	}
+	if a.List == nil || a.List.ElementRelationship != schema.Atomic {
+		return errorf("%v: expected an atomic list", path)
+	}
+	return nil
+}
+
+func (patch AtomicListPatch) apply(list []interface{}) ([]interface{}, error) {
+	positions := make([]int, 0, len(patch.Set))
+	for i := range patch.Set {
+		positions = append(positions, i)
+	}
+	sort.Ints(positions)
+	// The items are copied so that the patch can be reused.
+	out := append([]interface{}{}, list...)
+	for _, i := range positions {
+		switch {
+		case i >= 0 && i < len(out):
+			out[i] = patch.Set[i]
+		case i == len(out):
+			out = append(out, patch.Set[i])
+		default:
+			return nil, fmt.Errorf("position %v is out of range for a list of %v items", i, len(out))
+		}
+	}
+	remove := append([]int{}, patch.Remove...)
+	sort.Sort(sort.Reverse(sort.IntSlice(remove)))
+	for j, i := range remove {
+		if j > 0 && i == remove[j-1] {
+			continue
+		}
+		if i < 0 || i >= len(out) {
+			return nil, fmt.Errorf("position %v is out of range for a list of %v items", i, len(out))
+		}
+		out = append(out[:i], out[i+1:]...)
+	}
+	return out, nil
+}
+
+// patchListAt calls fn with the items of the list at path in node, of
+// type tr, and returns the unstructured node with the list replaced by the
+// result. The maps and lists along path are copied, their other children
+// are shared.
+func (tv *TypedValue) patchListAt(node value.Value, tr schema.TypeRef, path fieldpath.Path, fn func([]interface{}) ([]interface{}, error)) (interface{}, error) {
+	if len(path) == 0 {
+		if node == nil || node.IsNull() {
+			return fn(nil)
+		}
+		if !node.IsList() {
+			return nil, fmt.Errorf("expected a list, got %v", value.KindOf(node))
+		}
+		return fn(listItems(node.AsList()))
+	}
+	// The types along the path are checked by checkAtomicList.
+	a, _ := tv.schema.Resolve(tr)
+	pe := path[0]
+	if pe.FieldName != nil {
+		if node == nil || !node.IsMap() {
+			return nil, fmt.Errorf("expected a map at %v, got %v", pe, value.KindOf(node))
+		}
+		m := node.AsMap()
+		child, ok := m.Get(*pe.FieldName)
+		if !ok && len(path) > 1 {
+			return nil, fmt.Errorf("%v not found", pe)
+		}
+		tr, _ := typeRefAtPath(a.Map, pe)
+		patched, err := tv.patchListAt(child, tr, path[1:], fn)
+		if err != nil {
+			return nil, err
+		}
+		out := make(map[string]interface{}, m.Length()+1)
+		m.Iterate(func(key string, val value.Value) bool {
+			out[key] = val.Unstructured()
+			return true
+		})
+		out[*pe.FieldName] = patched
+		return out, nil
+	}
+	if node == nil || !node.IsList() {
+		return nil, fmt.Errorf("expected a list at %v, got %v", pe, value.KindOf(node))
+	}
+	list := node.AsList()
+	i := -1
+	if pe.Index != nil {
+		if *pe.Index >= 0 && *pe.Index < list.Length() {
+			i = *pe.Index
+		}
+	} else {
+		for j := 0; j < list.Length(); j++ {
+			if pe.MatchesItemCollated(list.At(j), a.List.KeyCollations) {
+				i = j
+				break
+			}
+		}
+	}
+	if i < 0 {
+		return nil, fmt.Errorf("%v not found", pe)
+	}
+	item := list.At(i)
+	tr = listElementType(value.HeapAllocator, a.List, item)
+	patched, err := tv.patchListAt(item, tr, path[1:], fn)
+	if err != nil {
+		return nil, err
+	}
+	out := listItems(list)
+	out[i] = patched
+	return out, nil
+}
+
+// listItems returns a new slice of the unstructured items of l.
+func listItems(l value.List) []interface{} {
+	out := make([]interface{}, l.Length())
+	for i := range out {
+		out[i] = l.At(i).Unstructured()
+	}
+	return out
+}
@@ -0,0 +1,173 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package typed_test
+
+import (
+	"strings"
+	"testing"
+
+	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
+	"sigs.k8s.io/structured-merge-diff/v4/typed"
+	"sigs.k8s.io/structured-merge-diff/v4/value"
+)
+
+var listPatchParser = func() *typed.Parser {
+	parser, err := typed.NewParser(`types:
+- name: pod
+  map:
+    fields:
+    - name: containers
+      type:
+        list:
+          elementType:
+            namedType: container
+          elementRelationship: associative
+          keys: [name]
+- name: container
+  map:
+    fields:
+    - name: name
+      type:
+        scalar: string
+    - name: args
+      type:
+        list:
+          elementType:
+            scalar: string
+          elementRelationship: atomic
+    - name: ports
+      type:
+        list:
+          elementType:
+            scalar: numeric
+          elementRelationship: associative
+`)
+	if err != nil {
+		panic(err)
+	}
+	return parser
+}()
+
+func TestPatchAtomicList(t *testing.T) {
+	const obj = `{"containers": [{"name": "a", "args": ["one", "two", "three"]}, {"name": "b"}]}`
+	args := fieldpath.MakePathOrDie("containers", fieldpath.KeyByFields("name", "a"), "args")
+	tests := []struct {
+		name     string
+		patch    typed.AtomicListPatch
+		expected string
+		err      string
+	}{{
+		name:     "set",
+		patch:    typed.AtomicListPatch{Path: args, Set: map[int]interface{}{1: "deux"}},
+		expected: `{"containers": [{"name": "a", "args": ["one", "deux", "three"]}, {"name": "b"}]}`,
+	}, {
+		name:     "append",
+		patch:    typed.AtomicListPatch{Path: args, Set: map[int]interface{}{4: "five", 3: "four"}},
+		expected: `{"containers": [{"name": "a", "args": ["one", "two", "three", "four", "five"]}, {"name": "b"}]}`,
+	}, {
+		name:     "remove",
+		patch:    typed.AtomicListPatch{Path: args, Remove: []int{0, 2, 0}},
+		expected: `{"containers": [{"name": "a", "args": ["two"]}, {"name": "b"}]}`,
+	}, {
+		name:     "missing list",
+		patch:    typed.AtomicListPatch{Path: fieldpath.MakePathOrDie("containers", 1, "args"), Set: map[int]interface{}{0: "one"}},
+		expected: `{"containers": [{"name": "a", "args": ["one", "two", "three"]}, {"name": "b", "args": ["one"]}]}`,
+	}, {
+		name:  "out of range",
+		patch: typed.AtomicListPatch{Path: args, Set: map[int]interface{}{5: "six"}},
+		err:   "out of range",
+	}, {
+		name:  "remove out of range",
+		patch: typed.AtomicListPatch{Path: args, Remove: []int{3}},
+		err:   "out of range",
+	}, {
+		name:  "set and removed",
+		patch: typed.AtomicListPatch{Path: args, Set: map[int]interface{}{1: "deux"}, Remove: []int{1}},
+		err:   "both set and removed",
+	}, {
+		name:  "invalid item",
+		patch: typed.AtomicListPatch{Path: args, Set: map[int]interface{}{0: 1}},
+		err:   "expected string",
+	}, {
+		name:  "missing parent",
+		patch: typed.AtomicListPatch{Path: fieldpath.MakePathOrDie("containers", fieldpath.KeyByFields("name", "c"), "args")},
+		err:   "not found",
+	}, {
+		name:  "associative list",
+		patch: typed.AtomicListPatch{Path: fieldpath.MakePathOrDie("containers")},
+		err:   "expected an atomic list",
+	}, {
+		name:  "associative set",
+		patch: typed.AtomicListPatch{Path: fieldpath.MakePathOrDie("containers", 0, "ports")},
+		err:   "expected an atomic list",
+	}, {
+		name:  "unknown field",
+		patch: typed.AtomicListPatch{Path: fieldpath.MakePathOrDie("volumes")},
+		err:   "no field",
+	}}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			tv, err := listPatchParser.Type("pod").FromYAML(obj)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := tv.PatchAtomicList(tt.patch)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("expected an error containing %q, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			expected, err := listPatchParser.Type("pod").FromYAML(typed.YAMLObject(tt.expected))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cmp, err := got.Compare(expected); err != nil || !cmp.IsSame() {
+				t.Errorf("expected %v, got %v (%v)", tt.expected, got.AsValue(), cmp)
+			}
+			// The original object is left untouched.
+			if cmp, err := tv.Compare(got); err != nil || cmp.IsSame() {
+				t.Errorf("expected the original object to be unchanged, got %v", tv.AsValue())
+			}
+		})
+	}
+}
+
+func TestPatchAtomicListShares(t *testing.T) {
+	tv, err := listPatchParser.Type("pod").FromYAML(`{"containers": [{"name": "a", "args": ["one"]}, {"name": "b", "args": ["two"]}]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := tv.PatchAtomicList(typed.AtomicListPatch{
+		Path: fieldpath.MakePathOrDie("containers", fieldpath.KeyByFields("name", "a"), "args"),
+		Set:  map[int]interface{}{0: "uno"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Only the maps and lists along the path are copied.
+	b := fieldpath.MakePathOrDie("containers", 1)
+	before, _ := tv.Lookup(b)
+	after, _ := got.Lookup(b)
+	if !value.Same(before, after) {
+		t.Errorf("expected the container b to be shared")
+	}
+}
@@ -41,8 +41,31 @@ type mergingWalker struct {
 	// output of the merge operation (nil if none)
 	out *interface{}
 
+	// If set, notified of every merge decision.
+	tracer MergeTracer
+
+	// If set, the budget of the merge, shared by all the walkers.
+	budget *mergeBudgetState
+
+	// If set, holds the keys of the items of the lists.
+	index *ListIndex
+
+	// If set, the parts of lhs which rhs doesn't set are shared with the
+	// output, see WithSharedValues.
+	share bool
+
+	// If set, the deferred validations of lhs and rhs, which are validated
+	// as they are walked, see AsTypedLazy.
+	lhsLazy *lazyValidation
+	rhsLazy *lazyValidation
+
+	// If set, the comparison of lhs with the output, made as they are
+	// merged, see MergeAndCompare.
+	comparison *mergeComparison
+
 	// internal housekeeping--don't set when constructing.
-	inLeaf bool // Set to true if we're in a "big leaf"--atomic map/list
+	inLeaf    bool // Set to true if we're in a "big leaf"--atomic map/list
+	descended bool // Set to true if we've descended into the items
 
 	// Allocate only as many walkers as needed for the depth by storing them here.
 	spareWalkers *[]*mergingWalker
@@ -73,6 +96,21 @@ func (w *mergingWalker) merge(prefixFn func() string) (errs ValidationErrors) {
 		// check this condidition here instead of everywhere below.
 		return errorf("at least one of lhs and rhs must be provided")
 	}
+	if w.budget != nil && !w.budget.visit(w.path) {
+		return errorf("merge budget exceeded")
+	}
+	errs = append(w.lhsLazy.before(w.schema, w.typeRef, w.lhs), w.rhsLazy.before(w.schema, w.typeRef, w.rhs)...)
+	if len(errs) > 0 {
+		return errs.WithLazyPrefix(prefixFn)
+	}
+	if w.share && w.rhs == nil && !w.inLeaf && w.tracer == nil && w.budget == nil && w.postItemHook == nil {
+		// rhs doesn't change this part of lhs, which is kept as it is
+		// rather than copied: the merged object shares it with lhs,
+		// which makes it cheap to compare them. lhs must be valid.
+		v := w.lhs.Unstructured()
+		w.out = &v
+		return w.lhsLazy.after(w.schema, w.typeRef, w.lhs, false).WithLazyPrefix(prefixFn)
+	}
 	a, ok := w.schema.Resolve(w.typeRef)
 	if !ok {
 		return errorf("schema error: no type found matching: %v", *w.typeRef.NamedType)
@@ -88,10 +126,23 @@ func (w *mergingWalker) merge(prefixFn func() string) (errs ValidationErrors) {
 	} else if w.lhs == nil || alhs.Equals(&arhs) {
 		errs = append(errs, handleAtom(arhs, w.typeRef, w)...)
 	} else {
+		// lhs and rhs aren't walked together, validate all of lhs.
+		lhsLazy := w.lhsLazy
+		w.lhsLazy = nil
 		w2 := *w
+		// Only the rhs decision is kept.
+		w2.tracer = nil
 		errs = append(errs, handleAtom(alhs, w.typeRef, &w2)...)
 		errs = append(errs, handleAtom(arhs, w.typeRef, w)...)
+		errs = append(errs, lhsLazy.after(w.schema, w.typeRef, w.lhs, false)...)
+		// The output doesn't follow the structure of lhs.
+		w.comparison.giveUp()
 	}
+	if !w.inLeaf && w.lhs == nil {
+		w.comparison.added(w.path)
+	}
+	errs = append(errs, w.lhsLazy.after(w.schema, w.typeRef, w.lhs, w.descended)...)
+	errs = append(errs, w.rhsLazy.after(w.schema, w.typeRef, w.rhs, w.descended)...)
 
 	if !w.inLeaf && w.postItemHook != nil {
 		w.postItemHook(w)
@@ -101,7 +152,7 @@ func (w *mergingWalker) merge(prefixFn func() string) (errs ValidationErrors) {
 
 // doLeaf should be called on leaves before descending into children, if there
 // will be a descent. It modifies w.inLeaf.
-func (w *mergingWalker) doLeaf() {
+func (w *mergingWalker) doLeaf(relationship schema.ElementRelationship) {
 	if w.inLeaf {
 		// We're in a "big leaf", an atomic map or list. Ignore
 		// subsequent leaves.
@@ -111,6 +162,37 @@ func (w *mergingWalker) doLeaf() {
 
 	// We don't recurse into leaf fields for merging.
 	w.rule(w)
+	if w.rhs != nil {
+		w.comparison.leaf(w.schema, w.typeRef, w.path, w.lhs, w.rhs)
+	}
+	if w.budget != nil {
+		if w.rhs != nil {
+			w.budget.keep(w.path, w.rhs)
+		} else {
+			w.budget.keep(w.path, w.lhs)
+		}
+	}
+
+	// ruleKeepRHS is the only rule, which keeps rhs if it's set.
+	if w.rhs != nil {
+		w.trace(MergeSideRHS, relationship)
+	} else {
+		w.trace(MergeSideLHS, relationship)
+	}
+}
+
+// doItems reports the decision to merge the items of a list or map, before
+// descending into them. hasLHS and hasRHS tell whether each side has a
+// non-null list or map.
+func (w *mergingWalker) doItems(relationship schema.ElementRelationship, hasLHS, hasRHS bool) {
+	switch {
+	case !hasLHS:
+		w.trace(MergeSideRHS, relationship)
+	case !hasRHS:
+		w.trace(MergeSideLHS, relationship)
+	default:
+		w.trace(MergeSideBoth, relationship)
+	}
 }
 
 func (w *mergingWalker) doScalar(t *schema.Scalar) ValidationErrors {
@@ -122,7 +204,7 @@ func (w *mergingWalker) doScalar(t *schema.Scalar) ValidationErrors {
 	}
 
 	// All scalars are leaf fields.
-	w.doLeaf()
+	w.doLeaf("")
 
 	return nil
 }
@@ -144,6 +226,7 @@ func (w *mergingWalker) prepareDescent(pe fieldpath.PathElement, tr schema.TypeR
 	w2.lhs = nil
 	w2.rhs = nil
 	w2.out = nil
+	w2.descended = false
 	return w2
 }
 
@@ -179,15 +262,34 @@ func (w *mergingWalker) visitListItems(t *schema.List, lhs, rhs value.List) (err
 		outLen = rLen
 	}
 	out := make([]interface{}, 0, outLen)
+	// outPEs are the keys of the items of out, recorded in the index.
+	var outPEs []fieldpath.PathElement
+	if w.index != nil {
+		outPEs = make([]fieldpath.PathElement, 0, outLen)
+	}
 
-	rhsPEs, observedRHS, rhsErrs := w.indexListPathElements(t, rhs, false)
+	w.descended = true
+	rhsPEs, observedRHS, rhsErrs := w.indexListPathElements(t, w.rhs, rhs, false)
 	errs = append(errs, rhsErrs...)
-	lhsPEs, observedLHS, lhsErrs := w.indexListPathElements(t, lhs, true)
+	lhsPEs, observedLHS, lhsErrs := w.indexListPathElements(t, w.lhs, lhs, true)
 	errs = append(errs, lhsErrs...)
 
 	if len(errs) != 0 {
 		return errs
 	}
+	// kept is the number of items of out which are the ones of lhs, in the
+	// same order, or -1 if they are not.
+	kept := 0
+	keep := func(pe fieldpath.PathElement) {
+		if w.comparison == nil || kept < 0 {
+			return
+		}
+		if kept < len(lhsPEs) && lhsPEs[kept].Equals(pe) {
+			kept++
+		} else {
+			kept = -1
+		}
+	}
 
 	sharedOrder := make([]*fieldpath.PathElement, 0, rLen)
 	for i := range rhsPEs {
@@ -213,10 +315,12 @@ func (w *mergingWalker) visitListItems(t *schema.List, lhs, rhs value.List) (err
 				mergedRHS.Insert(pe, struct{}{})
 				lChild, _ := observedLHS.Get(pe) // may be nil if the PE is duplicaated.
 				rChild, _ := observedRHS.Get(pe)
-				mergeOut, errs := w.mergeListItem(t, pe, lChild, rChild)
-				errs = append(errs, errs...)
+				mergeOut, itemErrs := w.mergeListItem(t, pe, lChild, rChild)
+				errs = append(errs, itemErrs...)
 				if mergeOut != nil {
 					out = append(out, *mergeOut)
+					outPEs = appendPE(outPEs, w.index, pe)
+					keep(pe)
 				}
 				lI++
 				rI++
@@ -239,10 +343,12 @@ func (w *mergingWalker) visitListItems(t *schema.List, lhs, rhs value.List) (err
 			if _, ok := observedRHS.Get(pe); !ok {
 				// take LHS item using At to make sure we get the right item (observed may not contain the right item).
 				lChild := lhs.AtUsing(w.allocator, lI)
-				mergeOut, errs := w.mergeListItem(t, pe, lChild, nil)
-				errs = append(errs, errs...)
+				mergeOut, itemErrs := w.mergeListItem(t, pe, lChild, nil)
+				errs = append(errs, itemErrs...)
 				if mergeOut != nil {
 					out = append(out, *mergeOut)
+					outPEs = appendPE(outPEs, w.index, pe)
+					keep(pe)
 				}
 				lI++
 				continue
@@ -257,10 +363,12 @@ func (w *mergingWalker) visitListItems(t *schema.List, lhs, rhs value.List) (err
 			mergedRHS.Insert(pe, struct{}{})
 			lChild, _ := observedLHS.Get(pe) // may be nil if absent or duplicaated.
 			rChild, _ := observedRHS.Get(pe)
-			mergeOut, errs := w.mergeListItem(t, pe, lChild, rChild)
-			errs = append(errs, errs...)
+			mergeOut, itemErrs := w.mergeListItem(t, pe, lChild, rChild)
+			errs = append(errs, itemErrs...)
 			if mergeOut != nil {
 				out = append(out, *mergeOut)
+				outPEs = appendPE(outPEs, w.index, pe)
+				keep(pe)
 			}
 			rI++
 			// Advance nextShared, if we are merging nextShared.
@@ -274,7 +382,13 @@ func (w *mergingWalker) visitListItems(t *schema.List, lhs, rhs value.List) (err
 		}
 	}
 
+	if kept != len(lhsPEs) {
+		// The items of lhs are reordered or dropped.
+		w.comparison.change()
+	}
+
 	if len(out) > 0 {
+		w.index.add(w.schema, w.typeRef, out, outPEs)
 		i := interface{}(out)
 		w.out = &i
 	}
@@ -282,7 +396,15 @@ func (w *mergingWalker) visitListItems(t *schema.List, lhs, rhs value.List) (err
 	return errs
 }
 
-func (w *mergingWalker) indexListPathElements(t *schema.List, list value.List, allowDuplicates bool) ([]fieldpath.PathElement, fieldpath.PathElementValueMap, ValidationErrors) {
+// appendPE appends pe to pes if the keys of the items are indexed.
+func appendPE(pes []fieldpath.PathElement, index *ListIndex, pe fieldpath.PathElement) []fieldpath.PathElement {
+	if index == nil {
+		return pes
+	}
+	return append(pes, pe)
+}
+
+func (w *mergingWalker) indexListPathElements(t *schema.List, v value.Value, list value.List, allowDuplicates bool) ([]fieldpath.PathElement, fieldpath.PathElementValueMap, ValidationErrors) {
 	var errs ValidationErrors
 	length := 0
 	if list != nil {
@@ -290,9 +412,10 @@ func (w *mergingWalker) indexListPathElements(t *schema.List, list value.List, a
 	}
 	observed := fieldpath.MakePathElementValueMap(length)
 	pes := make([]fieldpath.PathElement, 0, length)
+	keys := w.index.listItemKeys(w.allocator, w.schema, w.typeRef, t, v, list)
 	for i := 0; i < length; i++ {
 		child := list.At(i)
-		pe, err := listItemToPathElement(w.allocator, w.schema, t, child)
+		pe, err := keys.at(i, child)
 		if err != nil {
 			errs = append(errs, errorf("element %v: %v", i, err.Error())...)
 			// If we can't construct the path element, we can't
@@ -308,6 +431,8 @@ func (w *mergingWalker) indexListPathElements(t *schema.List, list value.List, a
 		} else {
 			// Duplicated items are not merged with the new value, make them nil.
 			observed.Insert(pe, value.NewValueInterface(nil))
+			// They are dropped or merged as a whole.
+			w.comparison.giveUp()
 		}
 		pes = append(pes, pe)
 	}
@@ -315,7 +440,11 @@ func (w *mergingWalker) indexListPathElements(t *schema.List, list value.List, a
 }
 
 func (w *mergingWalker) mergeListItem(t *schema.List, pe fieldpath.PathElement, lChild, rChild value.Value) (out *interface{}, errs ValidationErrors) {
-	w2 := w.prepareDescent(pe, t.ElementType)
+	child := rChild
+	if child == nil {
+		child = lChild
+	}
+	w2 := w.prepareDescent(pe, listElementType(w.allocator, t, child))
 	w2.lhs = lChild
 	w2.rhs = rChild
 	errs = append(errs, w2.merge(pe.String)...)
@@ -353,7 +482,7 @@ func (w *mergingWalker) doList(t *schema.List) (errs ValidationErrors) {
 	emptyPromoteToLeaf := (lhs == nil || lhs.Length() == 0) && (rhs == nil || rhs.Length() == 0)
 
 	if t.ElementRelationship == schema.Atomic || emptyPromoteToLeaf {
-		w.doLeaf()
+		w.doLeaf(t.ElementRelationship)
 		return nil
 	}
 
@@ -361,6 +490,7 @@ func (w *mergingWalker) doList(t *schema.List) (errs ValidationErrors) {
 		return nil
 	}
 
+	w.doItems(t.ElementRelationship, lhs != nil, rhs != nil)
 	errs = w.visitListItems(t, lhs, rhs)
 
 	return errs
@@ -386,6 +516,7 @@ func (w *mergingWalker) visitMapItem(t *schema.Map, out map[string]interface{},
 func (w *mergingWalker) visitMapItems(t *schema.Map, lhs, rhs value.Map) (errs ValidationErrors) {
 	out := map[string]interface{}{}
 
+	w.descended = true
 	value.MapZipUsing(w.allocator, lhs, rhs, value.Unordered, func(key string, lhsValue, rhsValue value.Value) bool {
 		errs = append(errs, w.visitMapItem(t, out, key, lhsValue, rhsValue)...)
 		return true
@@ -412,8 +543,12 @@ func (w *mergingWalker) doMap(t *schema.Map) (errs ValidationErrors) {
 	// distinction.
 	emptyPromoteToLeaf := (lhs == nil || lhs.Empty()) && (rhs == nil || rhs.Empty())
 
-	if t.ElementRelationship == schema.Atomic || emptyPromoteToLeaf {
-		w.doLeaf()
+	relationship := t.ElementRelationship
+	if relationship == "" {
+		relationship = schema.Separable
+	}
+	if relationship == schema.Atomic || emptyPromoteToLeaf {
+		w.doLeaf(relationship)
 		return nil
 	}
 
@@ -421,6 +556,7 @@ func (w *mergingWalker) doMap(t *schema.Map) (errs ValidationErrors) {
 		return nil
 	}
 
+	w.doItems(relationship, lhs != nil, rhs != nil)
 	errs = append(errs, w.visitMapItems(t, lhs, rhs)...)
 
 	return errs
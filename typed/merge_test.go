@@ -20,6 +20,7 @@ import (
 	"fmt"
 	"testing"
 
+	"sigs.k8s.io/structured-merge-diff/v4/schema"
 	"sigs.k8s.io/structured-merge-diff/v4/typed"
 	"sigs.k8s.io/structured-merge-diff/v4/value"
 )
@@ -516,11 +517,35 @@ func (tt mergeTestCase) test(t *testing.T) {
 						value.ToString(out.AsValue()), value.ToString(got.AsValue()),
 					)
 				}
+				checkMergeAndCompare(t, lhs, rhs, got)
 			}
 		})
 	}
 }
 
+// checkMergeAndCompare checks that MergeAndCompare returns merged, the
+// merge of lhs and rhs, and the comparison of lhs with it.
+func checkMergeAndCompare(t *testing.T, lhs, rhs, merged *typed.TypedValue, compareOpts ...typed.CompareOption) {
+	t.Helper()
+	result, err := lhs.MergeAndCompare(rhs, nil, compareOpts)
+	if err != nil {
+		t.Fatalf("MergeAndCompare failed: %v", err)
+	}
+	if !value.Equals(result.Merged.AsValue(), merged.AsValue()) {
+		t.Errorf("MergeAndCompare: expected\n%v\nbut got\n%v\n", value.ToString(merged.AsValue()), value.ToString(result.Merged.AsValue()))
+	}
+	expected, err := lhs.Compare(merged, compareOpts...)
+	if err != nil {
+		t.Fatalf("Compare failed: %v", err)
+	}
+	if !result.Comparison.Added.Equals(expected.Added) || !result.Comparison.Modified.Equals(expected.Modified) || !result.Comparison.Removed.Equals(expected.Removed) {
+		t.Errorf("MergeAndCompare: expected the comparison\n%v\nbut got\n%v", expected, result.Comparison)
+	}
+	if unchanged := value.Equals(lhs.AsValue(), merged.AsValue()); result.Unchanged != unchanged {
+		t.Errorf("MergeAndCompare: expected Unchanged to be %v", unchanged)
+	}
+}
+
 func TestMerge(t *testing.T) {
 	for _, tt := range mergeCases {
 		tt := tt
@@ -530,3 +555,200 @@ func TestMerge(t *testing.T) {
 		})
 	}
 }
+
+func TestMergeTracer(t *testing.T) {
+	parser, err := typed.NewParser(`types:
+- name: root
+  map:
+    fields:
+    - name: name
+      type:
+        scalar: string
+    - name: config
+      type:
+        map:
+          elementType:
+            scalar: string
+          elementRelationship: atomic
+    - name: labels
+      type:
+        map:
+          elementType:
+            scalar: string
+    - name: items
+      type:
+        list:
+          elementType:
+            namedType: item
+          elementRelationship: associative
+          keys:
+          - name
+- name: item
+  map:
+    fields:
+    - name: name
+      type:
+        scalar: string
+    - name: value
+      type:
+        scalar: numeric
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pt := parser.Type("root")
+	lhs, err := pt.FromYAML(`{"name":"a","config":{"x":"1","y":"2"},"labels":{"a":"b"},"items":[{"name":"i","value":1},{"name":"j","value":2}]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rhs, err := pt.FromYAML(`{"config":{"x":"3"},"labels":{"c":"d"},"items":[{"name":"i","value":3}]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := map[string]typed.MergeDecision{}
+	tracer := typed.MergeTracerFunc(func(d typed.MergeDecision) {
+		key := d.Path.String()
+		if _, ok := got[key]; ok {
+			t.Errorf("duplicate decision for %v", key)
+		}
+		got[key] = d
+	})
+	if _, err := lhs.Merge(rhs, typed.WithMergeTracer(tracer)); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]struct {
+		side         typed.MergeSide
+		relationship schema.ElementRelationship
+	}{
+		"":                         {typed.MergeSideBoth, schema.Separable},
+		".name":                    {typed.MergeSideLHS, ""},
+		".config":                  {typed.MergeSideRHS, schema.Atomic},
+		".labels":                  {typed.MergeSideBoth, schema.Separable},
+		".labels.a":                {typed.MergeSideLHS, ""},
+		".labels.c":                {typed.MergeSideRHS, ""},
+		".items":                   {typed.MergeSideBoth, schema.Associative},
+		".items[name=\"i\"]":       {typed.MergeSideBoth, schema.Separable},
+		".items[name=\"i\"].name":  {typed.MergeSideRHS, ""},
+		".items[name=\"i\"].value": {typed.MergeSideRHS, ""},
+		".items[name=\"j\"]":       {typed.MergeSideLHS, schema.Separable},
+		".items[name=\"j\"].name":  {typed.MergeSideLHS, ""},
+		".items[name=\"j\"].value": {typed.MergeSideLHS, ""},
+	}
+	for path, e := range expected {
+		d, ok := got[path]
+		if !ok {
+			t.Errorf("missing decision for %q", path)
+			continue
+		}
+		if d.Side != e.side || d.Relationship != e.relationship {
+			t.Errorf("%q: expected %v/%q, got %v/%q", path, e.side, e.relationship, d.Side, d.Relationship)
+		}
+	}
+	for path := range got {
+		if _, ok := expected[path]; !ok {
+			t.Errorf("unexpected decision for %q", path)
+		}
+	}
+}
+
+func TestMergeSharesUntouchedValues(t *testing.T) {
+	parser, err := typed.NewParser(`types:
+- name: root
+  map:
+    fields:
+    - name: name
+      type:
+        scalar: string
+    - name: labels
+      type:
+        map:
+          elementType:
+            scalar: string
+    - name: items
+      type:
+        list:
+          elementType:
+            namedType: item
+          elementRelationship: associative
+          keys:
+          - name
+- name: item
+  map:
+    fields:
+    - name: name
+      type:
+        scalar: string
+    - name: value
+      type:
+        map:
+          elementType:
+            scalar: numeric
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pt := parser.Type("root")
+	lhs, err := pt.FromYAML(`{"name":"a","labels":{"a":"b"},"items":[{"name":"i","value":{"x":1}},{"name":"j","value":{"y":2}}]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rhs, err := pt.FromYAML(`{"name":"b","items":[{"name":"i","value":{"x":3}}]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := lhs.Merge(rhs, typed.WithSharedValues())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	field := func(v value.Value, name string) value.Value {
+		f, ok := v.AsMap().Get(name)
+		if !ok {
+			t.Fatalf("missing field %q in %v", name, value.ToString(v))
+		}
+		return f
+	}
+	item := func(v value.Value, i int) value.Value {
+		return field(v, "items").AsList().At(i)
+	}
+	// The parts of lhs which rhs doesn't set are kept as they are, while
+	// the ones it sets are copied.
+	if !value.Same(field(lhs.AsValue(), "labels"), field(out.AsValue(), "labels")) {
+		t.Errorf("expected .labels to be shared with lhs")
+	}
+	if !value.Same(field(item(lhs.AsValue(), 1), "value"), field(item(out.AsValue(), 1), "value")) {
+		t.Errorf("expected .items[name=\"j\"].value to be shared with lhs")
+	}
+	if value.Same(field(item(lhs.AsValue(), 0), "value"), field(item(out.AsValue(), 0), "value")) {
+		t.Errorf("expected .items[name=\"i\"].value not to be shared with lhs")
+	}
+
+	expected, err := pt.FromYAML(`{"name":"b","labels":{"a":"b"},"items":[{"name":"i","value":{"x":3}},{"name":"j","value":{"y":2}}]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !value.Equals(out.AsValue(), expected.AsValue()) {
+		t.Errorf("expected\n%v\nbut got\n%v", value.ToString(expected.AsValue()), value.ToString(out.AsValue()))
+	}
+
+	// By default, the result is a copy which can be modified.
+	out, err = lhs.Merge(rhs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !value.Equals(out.AsValue(), expected.AsValue()) {
+		t.Errorf("expected\n%v\nbut got\n%v", value.ToString(expected.AsValue()), value.ToString(out.AsValue()))
+	}
+	u := out.AsValue().Unstructured().(map[string]interface{})
+	u["labels"].(map[string]interface{})["a"] = "modified"
+	u["items"].([]interface{})[1].(map[string]interface{})["value"].(map[string]interface{})["y"] = 5
+	original, err := pt.FromYAML(`{"name":"a","labels":{"a":"b"},"items":[{"name":"i","value":{"x":1}},{"name":"j","value":{"y":2}}]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !value.Equals(lhs.AsValue(), original.AsValue()) {
+		t.Errorf("expected lhs to be unchanged by modifying the result, got\n%v", value.ToString(lhs.AsValue()))
+	}
+}
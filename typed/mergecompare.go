@@ -0,0 +1,182 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package typed
+
+import (
+	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
+	"sigs.k8s.io/structured-merge-diff/v4/schema"
+	"sigs.k8s.io/structured-merge-diff/v4/value"
+)
+
+// MergeResult is the result of MergeAndCompare.
+type MergeResult struct {
+	// Merged is the result of the merge.
+	Merged *TypedValue
+	// Comparison is the comparison of the object with Merged.
+	Comparison *Comparison
+	// Unchanged is true if Merged is equal to the object, once their
+	// empty collections are normalized like the comparison normalizes
+	// them. Unlike an empty Comparison, it means that no value changes,
+	// even by less than the float tolerance, and that no list is
+	// reordered.
+	Unchanged bool
+}
+
+// MergeAndCompare merges tv and pso like Merge with mergeOpts, and compares
+// tv with the merged object like Compare with compareOpts, in the same walk
+// of the objects rather than by walking them again: merging pso can't
+// remove fields from tv, so the fields pso sets are the only ones which
+// can be added or modified. The few merges which don't keep the structure
+// of tv, e.g. of lists with duplicated items, or which apply a null policy
+// or compare with an EmptyCollectionPolicy, are compared once merged.
+func (tv *TypedValue) MergeAndCompare(pso *TypedValue, mergeOpts []MergeOption, compareOpts []CompareOption) (*MergeResult, error) {
+	options := &mergeOptions{}
+	for _, opt := range mergeOpts {
+		opt(options)
+	}
+	var cmpOptions compareOptions
+	for _, opt := range compareOpts {
+		opt(&cmpOptions)
+	}
+	if options.nullPolicy != "" || tv.schema.HasNullPolicies() || cmpOptions.emptyCollections != EmptyIsDistinct {
+		return tv.mergeThenCompare(pso, options, compareOpts, cmpOptions)
+	}
+	comparison := &mergeComparison{
+		comparison: &Comparison{
+			Removed:  fieldpath.NewSet(),
+			Modified: fieldpath.NewSet(),
+			Added:    fieldpath.NewSet(),
+		},
+		comparer: compareWalker{
+			floatTolerance:    cmpOptions.floatTolerance,
+			stringNormalizers: cmpOptions.stringNormalizers,
+			untypedComparator: cmpOptions.untypedComparator,
+			untypedMaxNodes:   cmpOptions.untypedMaxNodes,
+			allocator:         value.NewFreelistAllocator(),
+		},
+	}
+	merged, err := merge(tv, pso, ruleKeepRHS, nil, options.tracer, newMergeBudgetState(options.budget), options.share, comparison)
+	if err != nil {
+		return nil, err
+	}
+	if comparison.unknown {
+		return tv.compareMerged(merged, compareOpts, cmpOptions)
+	}
+	if len(comparison.errs) > 0 {
+		return nil, comparison.errs
+	}
+	c := comparison.comparison
+	return &MergeResult{
+		Merged:     merged,
+		Comparison: c.ExcludeFields(cmpOptions.ignored).ExcludeFields(tv.VolatileFields()),
+		Unchanged:  !comparison.changed,
+	}, nil
+}
+
+// mergeThenCompare merges tv and pso, then compares tv with the merged
+// object.
+func (tv *TypedValue) mergeThenCompare(pso *TypedValue, options *mergeOptions, compareOpts []CompareOption, cmpOptions compareOptions) (*MergeResult, error) {
+	merged, err := tv.mergeWith(pso, options)
+	if err != nil {
+		return nil, err
+	}
+	return tv.compareMerged(merged, compareOpts, cmpOptions)
+}
+
+// compareMerged compares tv with merged, the result of a merge into tv.
+func (tv *TypedValue) compareMerged(merged *TypedValue, compareOpts []CompareOption, cmpOptions compareOptions) (*MergeResult, error) {
+	c, err := tv.Compare(merged, compareOpts...)
+	if err != nil {
+		return nil, err
+	}
+	lhs := tv.NormalizeEmptyCollections(cmpOptions.emptyCollections)
+	rhs := merged.NormalizeEmptyCollections(cmpOptions.emptyCollections)
+	return &MergeResult{
+		Merged:     merged,
+		Comparison: c,
+		Unchanged:  value.EqualsUsing(value.NewFreelistAllocator(), lhs.value, rhs.value),
+	}, nil
+}
+
+// mergeComparison is the comparison of the lhs of a merge with its output,
+// which the merging walkers record as they merge. Its methods do nothing
+// if it is nil, i.e. if the merge isn't compared.
+type mergeComparison struct {
+	comparison *Comparison
+	// comparer compares the leaves.
+	comparer compareWalker
+	// errs are the errors of the comparison of the leaves.
+	errs ValidationErrors
+	// changed is true if the output isn't equal to lhs.
+	changed bool
+	// unknown is true if the merge took a path which the comparison
+	// can't follow: lhs must be compared with the output once merged.
+	unknown bool
+}
+
+// added records that the output adds path to lhs.
+func (c *mergeComparison) added(path fieldpath.Path) {
+	if c == nil {
+		return
+	}
+	c.comparison.Added.Insert(path)
+	c.changed = true
+}
+
+// leaf records the merge of the leaf at path, whose output is rhs.
+func (c *mergeComparison) leaf(s *schema.Schema, tr schema.TypeRef, path fieldpath.Path, lhs, rhs value.Value) {
+	if c == nil {
+		return
+	}
+	if lhs == nil {
+		c.added(path)
+		return
+	}
+	w := &c.comparer
+	if !value.EqualsUsing(w.allocator, lhs, rhs) {
+		c.changed = true
+	}
+	a, _ := s.Resolve(tr)
+	w.lhs, w.rhs, w.path = lhs, rhs, path
+	w.untyped = a.Scalar != nil && *a.Scalar == schema.Untyped
+	modified, errs := w.leafModified()
+	w.lhs, w.rhs, w.path = nil, nil, nil
+	if len(errs) > 0 {
+		c.errs = append(c.errs, errs.WithPrefix(path.String())...)
+		return
+	}
+	if modified {
+		c.comparison.Modified.Insert(path)
+	}
+}
+
+// change records that the output isn't equal to lhs, e.g. since it
+// reorders a list.
+func (c *mergeComparison) change() {
+	if c == nil {
+		return
+	}
+	c.changed = true
+}
+
+// giveUp records that the output doesn't follow the structure of lhs.
+func (c *mergeComparison) giveUp() {
+	if c == nil {
+		return
+	}
+	c.unknown = true
+}
@@ -0,0 +1,342 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package typed_test
+
+import (
+	"testing"
+
+	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
+	"sigs.k8s.io/structured-merge-diff/v4/typed"
+	"sigs.k8s.io/structured-merge-diff/v4/value"
+)
+
+var mergeAndCompareParser = func() *typed.Parser {
+	parser, err := typed.NewParser(`types:
+- name: type
+  map:
+    fields:
+    - name: name
+      type:
+        scalar: string
+    - name: ratio
+      type:
+        scalar: numeric
+    - name: labels
+      type:
+        map:
+          elementType:
+            scalar: string
+    - name: ports
+      type:
+        list:
+          elementType:
+            namedType: port
+          elementRelationship: associative
+          keys:
+          - name
+- name: port
+  map:
+    fields:
+    - name: name
+      type:
+        scalar: string
+    - name: port
+      type:
+        scalar: numeric
+`)
+	if err != nil {
+		panic(err)
+	}
+	return parser
+}()
+
+func TestMergeAndCompare(t *testing.T) {
+	tests := []struct {
+		name        string
+		lhs         string
+		rhs         string
+		opts        []typed.CompareOption
+		merged      string
+		added       *fieldpath.Set
+		modified    *fieldpath.Set
+		unchanged   bool
+		emptyParser bool
+	}{{
+		name:      "unchanged",
+		lhs:       `{"name": "a", "labels": {"app": "a"}}`,
+		rhs:       `{"labels": {"app": "a"}}`,
+		merged:    `{"name": "a", "labels": {"app": "a"}}`,
+		added:     _NS(),
+		modified:  _NS(),
+		unchanged: true,
+	}, {
+		name:     "added and modified",
+		lhs:      `{"name": "a", "labels": {"app": "a"}}`,
+		rhs:      `{"name": "b", "labels": {"tier": "web"}}`,
+		merged:   `{"name": "b", "labels": {"app": "a", "tier": "web"}}`,
+		added:    _NS(_P("labels", "tier")),
+		modified: _NS(_P("name")),
+	}, {
+		name:     "within the float tolerance",
+		lhs:      `{"ratio": 0.1}`,
+		rhs:      `{"ratio": 0.1000001}`,
+		opts:     []typed.CompareOption{typed.WithFloatTolerance(1e-3)},
+		merged:   `{"ratio": 0.1000001}`,
+		added:    _NS(),
+		modified: _NS(),
+	}, {
+		name:     "ignored fields",
+		lhs:      `{"name": "a", "labels": {"app": "a"}}`,
+		rhs:      `{"name": "b", "labels": {"app": "b"}}`,
+		opts:     []typed.CompareOption{typed.WithIgnoredFields(_NS(_P("labels")))},
+		merged:   `{"name": "b", "labels": {"app": "b"}}`,
+		added:    _NS(),
+		modified: _NS(_P("name")),
+	}, {
+		name:     "reordered items",
+		lhs:      `{"ports": [{"name": "a", "port": 1}, {"name": "b", "port": 2}]}`,
+		rhs:      `{"ports": [{"name": "b"}, {"name": "a"}]}`,
+		merged:   `{"ports": [{"name": "b", "port": 2}, {"name": "a", "port": 1}]}`,
+		added:    _NS(),
+		modified: _NS(),
+	}, {
+		name:   "added item",
+		lhs:    `{"ports": [{"name": "a", "port": 1}]}`,
+		rhs:    `{"ports": [{"name": "b", "port": 2}]}`,
+		merged: `{"ports": [{"name": "a", "port": 1}, {"name": "b", "port": 2}]}`,
+		added: _NS(
+			_P("ports", _KBF("name", "b")),
+			_P("ports", _KBF("name", "b"), "name"),
+			_P("ports", _KBF("name", "b"), "port"),
+		),
+		modified: _NS(),
+	}, {
+		name:        "with an empty collection policy",
+		lhs:         `{"name": "a", "labels": {}}`,
+		rhs:         `{"name": "a", "args": []}`,
+		opts:        []typed.CompareOption{typed.WithEmptyCollections(typed.EmptyEqualsAbsent)},
+		merged:      `{"name": "a", "labels": {}, "args": []}`,
+		added:       _NS(),
+		modified:    _NS(),
+		unchanged:   true,
+		emptyParser: true,
+	}}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			pt := mergeAndCompareParser.Type("type")
+			if tt.emptyParser {
+				pt = emptyCollectionsParser.Type("type")
+			}
+			lhs, err := pt.FromYAML(typed.YAMLObject(tt.lhs))
+			if err != nil {
+				t.Fatal(err)
+			}
+			rhs, err := pt.FromYAML(typed.YAMLObject(tt.rhs))
+			if err != nil {
+				t.Fatal(err)
+			}
+			merged, err := pt.FromYAML(typed.YAMLObject(tt.merged))
+			if err != nil {
+				t.Fatal(err)
+			}
+			result, err := lhs.MergeAndCompare(rhs, nil, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !value.Equals(result.Merged.AsValue(), merged.AsValue()) {
+				t.Errorf("expected\n%v\nbut got\n%v", value.ToString(merged.AsValue()), value.ToString(result.Merged.AsValue()))
+			}
+			if !result.Comparison.Added.Equals(tt.added) || !result.Comparison.Modified.Equals(tt.modified) || !result.Comparison.Removed.Empty() {
+				t.Errorf("expected %v to be added and %v to be modified, got:\n%v", tt.added, tt.modified, result.Comparison)
+			}
+			if result.Unchanged != tt.unchanged {
+				t.Errorf("expected Unchanged to be %v", tt.unchanged)
+			}
+		})
+	}
+}
+
+var mergeAndCompareListsParser = func() *typed.Parser {
+	parser, err := typed.NewParser(`types:
+- name: type
+  map:
+    fields:
+    - name: name
+      type:
+        scalar: string
+    - name: args
+      type:
+        list:
+          elementType:
+            scalar: string
+          elementRelationship: atomic
+    - name: finalizers
+      type:
+        list:
+          elementType:
+            scalar: string
+          elementRelationship: associative
+    - name: ports
+      type:
+        list:
+          elementType:
+            namedType: port
+          elementRelationship: associative
+          keys:
+          - name
+    - name: selector
+      type:
+        map:
+          elementType:
+            scalar: string
+          elementRelationship: atomic
+    - name: spec
+      type:
+        namedType: __untyped_deduced_
+- name: port
+  map:
+    fields:
+    - name: name
+      type:
+        scalar: string
+    - name: port
+      type:
+        scalar: numeric
+- name: __untyped_atomic_
+  scalar: untyped
+  list:
+    elementType:
+      namedType: __untyped_atomic_
+    elementRelationship: atomic
+  map:
+    elementType:
+      namedType: __untyped_atomic_
+    elementRelationship: atomic
+- name: __untyped_deduced_
+  scalar: untyped
+  list:
+    elementType:
+      namedType: __untyped_atomic_
+    elementRelationship: atomic
+  map:
+    elementType:
+      namedType: __untyped_deduced_
+    elementRelationship: separable
+`)
+	if err != nil {
+		panic(err)
+	}
+	return parser
+}()
+
+// TestMergeAndCompareLists checks that MergeAndCompare gives the same
+// result as Merge then Compare, including for the merges which it can't
+// compare as it walks.
+func TestMergeAndCompareLists(t *testing.T) {
+	tests := []struct {
+		name string
+		lhs  string
+		rhs  string
+		// removes is true if the comparison removes fields.
+		removes bool
+	}{{
+		name: "atomic list replaced",
+		lhs:  `{"args": ["a", "b"]}`,
+		rhs:  `{"args": ["c"]}`,
+	}, {
+		name: "atomic list unchanged",
+		lhs:  `{"name": "a", "args": ["a", "b"]}`,
+		rhs:  `{"args": ["a", "b"]}`,
+	}, {
+		name: "atomic list added",
+		lhs:  `{"name": "a"}`,
+		rhs:  `{"args": ["a"]}`,
+	}, {
+		name: "associative list item modified",
+		lhs:  `{"ports": [{"name": "a", "port": 1}, {"name": "b", "port": 2}]}`,
+		rhs:  `{"ports": [{"name": "b", "port": 3}]}`,
+	}, {
+		name: "associative list reordered",
+		lhs:  `{"ports": [{"name": "a", "port": 1}, {"name": "b", "port": 2}]}`,
+		rhs:  `{"ports": [{"name": "b"}, {"name": "c", "port": 3}, {"name": "a"}]}`,
+	}, {
+		name: "set list extended",
+		lhs:  `{"finalizers": ["a", "b"]}`,
+		rhs:  `{"finalizers": ["c", "a"]}`,
+	}, {
+		name: "set list unchanged",
+		lhs:  `{"finalizers": ["a", "b"]}`,
+		rhs:  `{"finalizers": ["a", "b"]}`,
+	}, {
+		name: "atomic map replaced",
+		lhs:  `{"selector": {"app": "a", "tier": "web"}}`,
+		rhs:  `{"selector": {"app": "b"}}`,
+	}, {
+		name:    "map replaced by a scalar",
+		lhs:     `{"spec": {"replicas": 1, "paused": true}}`,
+		rhs:     `{"spec": "none"}`,
+		removes: true,
+	}, {
+		name: "list replaced by a map",
+		lhs:  `{"spec": [1, 2]}`,
+		rhs:  `{"spec": {"replicas": 1}}`,
+	}, {
+		name: "scalar replaced by a map",
+		lhs:  `{"spec": "none"}`,
+		rhs:  `{"spec": {"replicas": 1}}`,
+	}, {
+		name: "duplicated associative list items",
+		lhs:  `{"ports": [{"name": "a", "port": 1}, {"name": "a", "port": 2}, {"name": "b", "port": 3}]}`,
+		rhs:  `{"ports": [{"name": "b", "port": 4}]}`,
+	}, {
+		name: "duplicated set list items",
+		lhs:  `{"finalizers": ["a", "a", "b"]}`,
+		rhs:  `{"finalizers": ["c"]}`,
+	}, {
+		name:    "duplicated items replaced",
+		lhs:     `{"ports": [{"name": "a", "port": 1}, {"name": "a", "port": 2}]}`,
+		rhs:     `{"ports": [{"name": "a", "port": 3}]}`,
+		removes: true,
+	}}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			pt := mergeAndCompareListsParser.Type("type")
+			lhs, err := pt.FromYAML(typed.YAMLObject(tt.lhs), typed.AllowDuplicates)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rhs, err := pt.FromYAML(typed.YAMLObject(tt.rhs))
+			if err != nil {
+				t.Fatal(err)
+			}
+			merged, err := lhs.Merge(rhs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkMergeAndCompare(t, lhs, rhs, merged)
+			comparison, err := lhs.Compare(merged)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if removes := !comparison.Removed.Empty(); removes != tt.removes {
+				t.Errorf("expected the comparison to remove fields: %v, got:\n%v", tt.removes, comparison)
+			}
+		})
+	}
+}
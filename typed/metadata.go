@@ -0,0 +1,185 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package typed
+
+import (
+	"fmt"
+
+	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
+	"sigs.k8s.io/structured-merge-diff/v4/value"
+)
+
+// Metadata is the part of the metadata of a Kubernetes object that most
+// callers deal with, see TypedValue.Metadata. The empty fields are unset.
+type Metadata struct {
+	Name        string
+	Namespace   string
+	Labels      map[string]string
+	Annotations map[string]string
+	// ManagedFields are the managers of the object. Only their field sets,
+	// versions and operations are kept: the times of the entries are lost
+	// when they are written back.
+	ManagedFields fieldpath.ManagedFields
+}
+
+// Metadata returns the metadata of tv, a Kubernetes object: the fields of
+// its metadata field, whatever its schema says about them. It fails if
+// they aren't shaped like in Kubernetes, e.g. if the labels aren't a map of
+// strings.
+func (tv *TypedValue) Metadata() (Metadata, error) {
+	var meta Metadata
+	m, ok := metadataMap(tv.value)
+	if !ok {
+		return meta, nil
+	}
+	var errs ValidationErrors
+	if v, ok := m.Get("name"); ok && !v.IsNull() {
+		if !v.IsString() {
+			errs = append(errs, errorf(".metadata.name: expected string, got %v", value.KindOf(v))...)
+		} else {
+			meta.Name = v.AsString()
+		}
+	}
+	if v, ok := m.Get("namespace"); ok && !v.IsNull() {
+		if !v.IsString() {
+			errs = append(errs, errorf(".metadata.namespace: expected string, got %v", value.KindOf(v))...)
+		} else {
+			meta.Namespace = v.AsString()
+		}
+	}
+	var err ValidationErrors
+	meta.Labels, err = stringMap(m, "labels")
+	errs = append(errs, err...)
+	meta.Annotations, err = stringMap(m, "annotations")
+	errs = append(errs, err...)
+	if v, ok := m.Get("managedFields"); ok && !v.IsNull() {
+		data, jerr := value.ToJSON(v)
+		if jerr == nil {
+			meta.ManagedFields, jerr = fieldpath.DecodeManagedFields(data)
+		}
+		if jerr != nil {
+			errs = append(errs, errorf(".metadata.managedFields: %v", jerr)...)
+		}
+	}
+	if len(errs) > 0 {
+		return Metadata{}, errs
+	}
+	return meta, nil
+}
+
+// WithMetadata returns a copy of tv whose metadata fields are set to meta,
+// unsetting the empty ones. The other fields of the metadata are kept.
+// The copy is validated, and tv isn't modified.
+func (tv *TypedValue) WithMetadata(meta Metadata) (*TypedValue, error) {
+	root := map[string]interface{}{}
+	if tv.value != nil && !tv.value.IsNull() {
+		if !tv.value.IsMap() {
+			return nil, errorf("expected map, got %v", value.KindOf(tv.value))
+		}
+		root = shallowCopy(tv.value.AsMap())
+	}
+	metadata := map[string]interface{}{}
+	if v, ok := root["metadata"]; ok && v != nil {
+		mv := value.NewValueInterface(v)
+		if !mv.IsMap() {
+			return nil, errorf(".metadata: expected map, got %v", value.KindOf(mv))
+		}
+		metadata = shallowCopy(mv.AsMap())
+	}
+	setOrDelete := func(key string, v interface{}, empty bool) {
+		if empty {
+			delete(metadata, key)
+		} else {
+			metadata[key] = v
+		}
+	}
+	setOrDelete("name", meta.Name, meta.Name == "")
+	setOrDelete("namespace", meta.Namespace, meta.Namespace == "")
+	setOrDelete("labels", unstructuredStringMap(meta.Labels), len(meta.Labels) == 0)
+	setOrDelete("annotations", unstructuredStringMap(meta.Annotations), len(meta.Annotations) == 0)
+	var managedFields interface{}
+	if len(meta.ManagedFields) > 0 {
+		data, err := fieldpath.EncodeManagedFields(meta.ManagedFields)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode managed fields: %v", err)
+		}
+		v, err := value.FromJSON(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode managed fields: %v", err)
+		}
+		managedFields = v.Unstructured()
+	}
+	setOrDelete("managedFields", managedFields, managedFields == nil)
+	if len(metadata) == 0 {
+		delete(root, "metadata")
+	} else {
+		root["metadata"] = metadata
+	}
+	return AsTyped(value.NewValueInterface(root), tv.schema, tv.typeRef)
+}
+
+// metadataMap returns the metadata map of v, if any.
+func metadataMap(v value.Value) (value.Map, bool) {
+	if v == nil || !v.IsMap() {
+		return nil, false
+	}
+	m, ok := v.AsMap().Get("metadata")
+	if !ok || !m.IsMap() {
+		return nil, false
+	}
+	return m.AsMap(), true
+}
+
+// stringMap returns the field of m which is a map of strings, like labels.
+func stringMap(m value.Map, field string) (map[string]string, ValidationErrors) {
+	v, ok := m.Get(field)
+	if !ok || v.IsNull() {
+		return nil, nil
+	}
+	if !v.IsMap() {
+		return nil, errorf(".metadata.%v: expected map, got %v", field, value.KindOf(v))
+	}
+	out := map[string]string{}
+	var errs ValidationErrors
+	v.AsMap().Iterate(func(key string, v value.Value) bool {
+		if !v.IsString() {
+			errs = append(errs, errorf(".metadata.%v.%v: expected string, got %v", field, key, value.KindOf(v))...)
+			return true
+		}
+		out[key] = v.AsString()
+		return true
+	})
+	return out, errs
+}
+
+func unstructuredStringMap(m map[string]string) map[string]interface{} {
+	out := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+	return out
+}
+
+// shallowCopy returns the fields of m, whose values are shared.
+func shallowCopy(m value.Map) map[string]interface{} {
+	out := make(map[string]interface{}, m.Length())
+	m.Iterate(func(key string, v value.Value) bool {
+		out[key] = v.Unstructured()
+		return true
+	})
+	return out
+}
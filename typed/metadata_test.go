@@ -0,0 +1,167 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package typed_test
+
+import (
+	"reflect"
+	"testing"
+
+	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
+	"sigs.k8s.io/structured-merge-diff/v4/typed"
+	"sigs.k8s.io/structured-merge-diff/v4/value"
+)
+
+func TestMetadata(t *testing.T) {
+	tests := []struct {
+		name    string
+		object  typed.YAMLObject
+		want    typed.Metadata
+		wantErr bool
+	}{{
+		name:   "no metadata",
+		object: `{"spec": {}}`,
+	}, {
+		name: "all fields",
+		object: `
+metadata:
+  name: a
+  namespace: ns
+  labels: {app: web}
+  annotations: {note: hello}
+  managedFields:
+  - manager: kubectl
+    operation: Apply
+    apiVersion: v1
+    fieldsType: FieldsV1
+    fieldsV1: {"f:spec": {"f:replicas": {}}}
+`,
+		want: typed.Metadata{
+			Name:        "a",
+			Namespace:   "ns",
+			Labels:      map[string]string{"app": "web"},
+			Annotations: map[string]string{"note": "hello"},
+			ManagedFields: fieldpath.ManagedFields{
+				"kubectl": fieldpath.NewVersionedSet(
+					fieldpath.NewSet(fieldpath.MakePathOrDie("spec", "replicas")),
+					"v1",
+					true,
+				),
+			},
+		},
+	}, {
+		name:    "name isn't a string",
+		object:  `{"metadata": {"name": 1}}`,
+		wantErr: true,
+	}, {
+		name:    "labels aren't strings",
+		object:  `{"metadata": {"labels": {"a": {}}}}`,
+		wantErr: true,
+	}}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			tv, err := typed.DeducedParseableType.FromYAML(tt.object)
+			if err != nil {
+				t.Fatalf("failed to parse object: %v", err)
+			}
+			got, err := tv.Metadata()
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got.Name != tt.want.Name || got.Namespace != tt.want.Namespace ||
+				!reflect.DeepEqual(got.Labels, tt.want.Labels) ||
+				!reflect.DeepEqual(got.Annotations, tt.want.Annotations) ||
+				!got.ManagedFields.Equals(tt.want.ManagedFields) {
+				t.Errorf("expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestWithMetadata(t *testing.T) {
+	const object = `
+metadata:
+  name: a
+  uid: "1234"
+  labels: {app: web}
+spec: {replicas: 1}
+`
+	tv, err := typed.DeducedParseableType.FromYAML(object)
+	if err != nil {
+		t.Fatalf("failed to parse object: %v", err)
+	}
+	before, err := typed.DeducedParseableType.FromYAML(object)
+	if err != nil {
+		t.Fatalf("failed to parse object: %v", err)
+	}
+
+	got, err := tv.WithMetadata(typed.Metadata{
+		Name:        "b",
+		Namespace:   "ns",
+		Annotations: map[string]string{"note": "hello"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want, err := typed.DeducedParseableType.FromYAML(`
+metadata:
+  name: b
+  namespace: ns
+  uid: "1234"
+  annotations: {note: hello}
+spec: {replicas: 1}
+`)
+	if err != nil {
+		t.Fatalf("failed to parse object: %v", err)
+	}
+	if !value.Equals(got.AsValue(), want.AsValue()) {
+		t.Errorf("expected %v, got %v", value.ToString(want.AsValue()), value.ToString(got.AsValue()))
+	}
+	if !value.Equals(tv.AsValue(), before.AsValue()) {
+		t.Errorf("original object was modified: %v", value.ToString(tv.AsValue()))
+	}
+
+	meta, err := got.Metadata()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	meta.ManagedFields = fieldpath.ManagedFields{
+		"kubectl": fieldpath.NewVersionedSet(
+			fieldpath.NewSet(fieldpath.MakePathOrDie("spec", "replicas")),
+			"v1",
+			true,
+		),
+	}
+	got, err = got.WithMetadata(meta)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	roundTrip, err := got.Metadata()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !roundTrip.ManagedFields.Equals(meta.ManagedFields) {
+		t.Errorf("expected managed fields %v, got %v", meta.ManagedFields, roundTrip.ManagedFields)
+	}
+}
@@ -0,0 +1,111 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package typed
+
+import (
+	"fmt"
+
+	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
+)
+
+// OwnershipMigration is how the ownership of a list or a map is migrated
+// when it switches between atomic and granular in the schema, see
+// MigrateOwnership.
+type OwnershipMigration int
+
+const (
+	// CollapseToParent makes the managers of any child of the list or
+	// map own the list or map itself, which is how atomic lists and maps
+	// are owned.
+	CollapseToParent OwnershipMigration = iota
+	// ExpandToLeaves makes the managers of the list or map itself, and of
+	// none of its children, own its current leaves in the live object
+	// instead, which is how granular lists and maps are owned.
+	ExpandToLeaves
+)
+
+// MigrateOwnership migrates the ownership of the list or map at path in
+// the sets of the managers of version, after its relationship changed in
+// the schema: granular lists and maps that become atomic should be
+// migrated with CollapseToParent, and atomic ones that become granular
+// with ExpandToLeaves, which needs live, the object with the new schema.
+// Without a migration, the managers keep sets that don't match how the
+// list or map is owned anymore. The managers are modified and returned.
+func MigrateOwnership(managers fieldpath.ManagedFields, version fieldpath.APIVersion, path fieldpath.Path, migration OwnershipMigration, live *TypedValue) (fieldpath.ManagedFields, error) {
+	var leaves *fieldpath.Set
+	if migration == ExpandToLeaves {
+		var err error
+		if leaves, err = live.ToFieldSet(); err != nil {
+			return nil, fmt.Errorf("failed to get the fields of the object: %v", err)
+		}
+	}
+	for name, vs := range managers {
+		if vs.APIVersion() != version {
+			continue
+		}
+		var set *fieldpath.Set
+		switch migration {
+		case CollapseToParent:
+			set = CollapseOwnership(vs.Set(), path)
+		case ExpandToLeaves:
+			set = expandOwnership(vs.Set(), path, leaves)
+		default:
+			return nil, fmt.Errorf("unknown ownership migration %v", migration)
+		}
+		managers[name] = fieldpath.WithSet(vs, set, version)
+	}
+	return managers, nil
+}
+
+// CollapseOwnership returns set, in which path and its children are
+// replaced by path if set has any of them. See CollapseToParent.
+func CollapseOwnership(set *fieldpath.Set, path fieldpath.Path) *fieldpath.Set {
+	if !set.Has(path) {
+		if children, ok := fieldSetAtPath(set, path); !ok || children.Empty() {
+			return set
+		}
+	}
+	out := set.RecursiveDifference(fieldpath.NewSet(path))
+	out.Insert(path)
+	return out
+}
+
+// ExpandOwnership returns set, in which path is replaced by its leaves in
+// tv if set has path but none of its children. See ExpandToLeaves.
+func (tv *TypedValue) ExpandOwnership(set *fieldpath.Set, path fieldpath.Path) (*fieldpath.Set, error) {
+	leaves, err := tv.ToFieldSet()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the fields of the object: %v", err)
+	}
+	return expandOwnership(set, path, leaves), nil
+}
+
+func expandOwnership(set *fieldpath.Set, path fieldpath.Path, fields *fieldpath.Set) *fieldpath.Set {
+	if !set.Has(path) {
+		return set
+	}
+	if children, ok := fieldSetAtPath(set, path); ok && !children.Empty() {
+		return set
+	}
+	out := set.Difference(fieldpath.NewSet(path))
+	if children, ok := fieldSetAtPath(fields, path); ok {
+		children.Iterate(func(p fieldpath.Path) {
+			out.Insert(append(path.Copy(), p...))
+		})
+	}
+	return out
+}
@@ -0,0 +1,106 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package typed_test
+
+import (
+	"testing"
+
+	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
+	"sigs.k8s.io/structured-merge-diff/v4/typed"
+)
+
+func TestMigrateOwnership(t *testing.T) {
+	parser, err := typed.NewParser(`types:
+- name: object
+  map:
+    fields:
+    - name: name
+      type:
+        scalar: string
+    - name: labels
+      type:
+        map:
+          elementType:
+            scalar: string
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	live, err := parser.Type("object").FromYAML(`{"name": "a", "labels": {"app": "web", "team": "x"}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	labels := _P("labels")
+
+	tests := []struct {
+		name      string
+		migration typed.OwnershipMigration
+		set       *fieldpath.Set
+		want      *fieldpath.Set
+	}{{
+		name:      "collapse children",
+		migration: typed.CollapseToParent,
+		set:       _NS(_P("name"), _P("labels", "app")),
+		want:      _NS(_P("name"), labels),
+	}, {
+		name:      "collapse parent and children",
+		migration: typed.CollapseToParent,
+		set:       _NS(labels, _P("labels", "app")),
+		want:      _NS(labels),
+	}, {
+		name:      "collapse unowned",
+		migration: typed.CollapseToParent,
+		set:       _NS(_P("name")),
+		want:      _NS(_P("name")),
+	}, {
+		name:      "expand parent",
+		migration: typed.ExpandToLeaves,
+		set:       _NS(_P("name"), labels),
+		want:      _NS(_P("name"), _P("labels", "app"), _P("labels", "team")),
+	}, {
+		name:      "expand parent with children",
+		migration: typed.ExpandToLeaves,
+		set:       _NS(labels, _P("labels", "app")),
+		want:      _NS(labels, _P("labels", "app")),
+	}, {
+		name:      "expand unowned",
+		migration: typed.ExpandToLeaves,
+		set:       _NS(_P("labels", "app")),
+		want:      _NS(_P("labels", "app")),
+	}}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			managers := fieldpath.ManagedFields{
+				"a": fieldpath.NewVersionedSet(tt.set, "v1", true),
+				"b": fieldpath.NewVersionedSet(tt.set, "v2", false),
+			}
+			got, err := typed.MigrateOwnership(managers, "v1", labels, tt.migration, live)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := fieldpath.ManagedFields{
+				"a": fieldpath.NewVersionedSet(tt.want, "v1", true),
+				"b": fieldpath.NewVersionedSet(tt.set, "v2", false),
+			}
+			if !got.Equals(want) {
+				t.Errorf("expected managers:\n%v\ngot:\n%v", want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package typed
+
+import (
+	"math/big"
+	"regexp"
+	"strings"
+
+	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
+)
+
+// StringNormalizer normalizes the string fields matched by Fields before
+// they are compared, so that strings with the same meaning, e.g. "1000m"
+// and "1" for quantities, aren't modified. Normalize can be one of
+// strings.TrimSpace, strings.ToLower or NormalizeQuantity, or any function
+// returning the same string for equivalent strings. A nil Fields matches
+// all the string fields, and normalizers without Normalize are ignored.
+type StringNormalizer struct {
+	Fields    *fieldpath.SetMatcher
+	Normalize func(string) string
+}
+
+// WithStringNormalizers configures Compare to compare the string scalar
+// fields matched by a normalizer after normalizing them. The first
+// normalizer matching a field is used.
+func WithStringNormalizers(normalizers ...StringNormalizer) CompareOption {
+	return func(opts *compareOptions) {
+		opts.stringNormalizers = append(opts.stringNormalizers, normalizers...)
+	}
+}
+
+// normalizedEquals returns true if lhs and rhs are equal once normalized by
+// the first normalizer matching path, if any.
+func normalizedEquals(normalizers []StringNormalizer, path fieldpath.Path, lhs, rhs string) bool {
+	for _, n := range normalizers {
+		if n.Normalize == nil {
+			continue
+		}
+		if n.Fields == nil || n.Fields.Matches(path) {
+			return n.Normalize(lhs) == n.Normalize(rhs)
+		}
+	}
+	return false
+}
+
+var quantityPattern = regexp.MustCompile(`^([+-]?(?:[0-9]+\.?[0-9]*|\.[0-9]+))([eE][+-]?[0-9]+|[a-zA-Z]*)$`)
+
+var exponentPattern = regexp.MustCompile(`^[eE][+-]?[0-9]+$`)
+
+var quantitySuffixes = map[string]*big.Rat{
+	"":   big.NewRat(1, 1),
+	"n":  big.NewRat(1, 1000000000),
+	"u":  big.NewRat(1, 1000000),
+	"m":  big.NewRat(1, 1000),
+	"k":  big.NewRat(1000, 1),
+	"M":  big.NewRat(1000000, 1),
+	"G":  new(big.Rat).SetInt64(1e9),
+	"T":  new(big.Rat).SetInt64(1e12),
+	"P":  new(big.Rat).SetInt64(1e15),
+	"E":  new(big.Rat).SetInt64(1e18),
+	"Ki": new(big.Rat).SetInt64(1 << 10),
+	"Mi": new(big.Rat).SetInt64(1 << 20),
+	"Gi": new(big.Rat).SetInt64(1 << 30),
+	"Ti": new(big.Rat).SetInt64(1 << 40),
+	"Pi": new(big.Rat).SetInt64(1 << 50),
+	"Ei": new(big.Rat).SetInt64(1 << 60),
+}
+
+// NormalizeQuantity returns the canonical form of a Kubernetes quantity,
+// e.g. "1" for "1000m" or "1024" for "1Ki", or s if it isn't a quantity.
+func NormalizeQuantity(s string) string {
+	m := quantityPattern.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return s
+	}
+	number, ok := new(big.Rat).SetString(m[1])
+	if !ok {
+		return s
+	}
+	suffix := m[2]
+	if exponentPattern.MatchString(suffix) {
+		// An exponent, e.g. "1e3", which SetString parses.
+		if number, ok = new(big.Rat).SetString(m[1] + suffix); !ok {
+			return s
+		}
+		return number.RatString()
+	}
+	multiplier, ok := quantitySuffixes[suffix]
+	if !ok {
+		return s
+	}
+	return number.Mul(number, multiplier).RatString()
+}
@@ -0,0 +1,111 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package typed_test
+
+import (
+	"strings"
+	"testing"
+
+	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
+	"sigs.k8s.io/structured-merge-diff/v4/typed"
+)
+
+func TestNormalizeQuantity(t *testing.T) {
+	table := []struct {
+		in     string
+		expect string
+	}{
+		{"1", "1"},
+		{"1000m", "1"},
+		{"1.", "1"},
+		{"0.5", "1/2"},
+		{"500m", "1/2"},
+		{"1k", "1000"},
+		{"1e3", "1000"},
+		{"1E3", "1000"},
+		{"1E", "1000000000000000000"},
+		{"1Ki", "1024"},
+		{"1Ei", "1152921504606846976"},
+		{" 2Gi ", "2147483648"},
+		{"-1.5", "-3/2"},
+		{"1x", "1x"},
+		{"abc", "abc"},
+		{"", ""},
+	}
+	for _, tt := range table {
+		if got := typed.NormalizeQuantity(tt.in); got != tt.expect {
+			t.Errorf("NormalizeQuantity(%q): expected %q, got %q", tt.in, tt.expect, got)
+		}
+	}
+}
+
+func TestCompareWithStringNormalizers(t *testing.T) {
+	lhs, err := typed.DeducedParseableType.FromYAML(`{"cpu": "1", "name": "Foo", "other": "Foo", "mem": "1Gi"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rhs, err := typed.DeducedParseableType.FromYAML(`{"cpu": "1000m", "name": " foo", "other": "foo", "mem": "1G"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := lhs.Compare(rhs, typed.WithStringNormalizers(
+		typed.StringNormalizer{
+			Fields:    fieldpath.MakePrefixMatcherOrDie("cpu").Merge(fieldpath.MakePrefixMatcherOrDie("mem")),
+			Normalize: typed.NormalizeQuantity,
+		},
+		typed.StringNormalizer{
+			Fields: fieldpath.MakePrefixMatcherOrDie("name"),
+			Normalize: func(s string) string {
+				return strings.ToLower(strings.TrimSpace(s))
+			},
+		},
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := fieldpath.NewSet(
+		fieldpath.MakePathOrDie("mem"),
+		fieldpath.MakePathOrDie("other"),
+	)
+	if !c.Modified.Equals(expected) {
+		t.Errorf("expected modified fields:\n%v\ngot:\n%v", expected, c.Modified)
+	}
+}
+
+func TestCompareWithNilNormalizerFields(t *testing.T) {
+	lhs, err := typed.DeducedParseableType.FromYAML(`{"name": "Foo", "other": "Bar"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rhs, err := typed.DeducedParseableType.FromYAML(`{"name": "foo", "other": "baz"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Normalizers without fields apply to all the strings, and the ones
+	// without Normalize are ignored.
+	c, err := lhs.Compare(rhs, typed.WithStringNormalizers(
+		typed.StringNormalizer{Fields: fieldpath.MakePrefixMatcherOrDie("name")},
+		typed.StringNormalizer{Normalize: strings.ToLower},
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := fieldpath.NewSet(fieldpath.MakePathOrDie("other"))
+	if !c.Modified.Equals(expected) {
+		t.Errorf("expected modified fields:\n%v\ngot:\n%v", expected, c.Modified)
+	}
+}
@@ -0,0 +1,167 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package typed
+
+import (
+	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
+	"sigs.k8s.io/structured-merge-diff/v4/schema"
+	"sigs.k8s.io/structured-merge-diff/v4/value"
+)
+
+// WithNullPolicy configures Merge to treat the explicit nulls of fields
+// according to policy, unless their schema sets another one:
+//   - with schema.NullDeletes, a null field of pso deletes the field, and
+//     null fields are left out of the result;
+//   - with schema.NullIsInvalid, a null field of pso is an error;
+//   - with schema.NullIsValue, nulls are kept, as without this option.
+//
+// Without this option, only the fields whose schema sets a policy follow
+// one, and the nulls of the other fields are kept.
+func WithNullPolicy(policy schema.NullPolicy) MergeOption {
+	return func(opts *mergeOptions) {
+		opts.nullPolicy = policy
+	}
+}
+
+// ToFieldSetOption configures ToFieldSet.
+type ToFieldSetOption func(*toFieldSetOptions)
+
+type toFieldSetOptions struct {
+	nullPolicy    schema.NullPolicy
+	untypedLimits UntypedLimits
+	patterns      []*fieldpath.Pattern
+}
+
+// WithFieldSetNullPolicy configures ToFieldSet to treat explicit nulls like
+// Merge does with WithNullPolicy: null fields which are deleted aren't part
+// of the set, and null fields which are invalid are an error. The policies
+// set by the schema apply without this option too.
+func WithFieldSetNullPolicy(policy schema.NullPolicy) ToFieldSetOption {
+	return func(opts *toFieldSetOptions) {
+		opts.nullPolicy = policy
+	}
+}
+
+// applyNullPolicy returns tv without the null fields whose policy is
+// schema.NullDeletes. If reject is true, the null fields whose policy is
+// schema.NullIsInvalid are errors. tv is returned as is if it has nothing
+// to remove.
+func (tv *TypedValue) applyNullPolicy(policy schema.NullPolicy, reject bool) (*TypedValue, ValidationErrors) {
+	w := nullPolicyWalker{schema: tv.schema, policy: policy, reject: reject}
+	out, changed := w.walk(nil, tv.typeRef, tv.value)
+	if len(w.errs) > 0 {
+		return nil, w.errs
+	}
+	if !changed {
+		return tv, nil
+	}
+	return tv.withValue(value.NewValueInterface(out)), nil
+}
+
+type nullPolicyWalker struct {
+	schema *schema.Schema
+	policy schema.NullPolicy
+	reject bool
+	errs   ValidationErrors
+}
+
+// walk returns the unstructured value of v without its deleted null fields,
+// and whether any was found. The value is only built if it was.
+func (w *nullPolicyWalker) walk(path fieldpath.Path, tr schema.TypeRef, v value.Value) (interface{}, bool) {
+	a, ok := w.schema.Resolve(tr)
+	if !ok || v == nil {
+		return nil, false
+	}
+	switch {
+	case a.Map != nil && v.IsMap():
+		return w.walkMap(path, a.Map, v.AsMap())
+	case a.List != nil && v.IsList():
+		return w.walkList(path, a.List, v.AsList())
+	}
+	return nil, false
+}
+
+func (w *nullPolicyWalker) walkMap(path fieldpath.Path, t *schema.Map, m value.Map) (interface{}, bool) {
+	changed := map[string]interface{}{}
+	deleted := map[string]bool{}
+	m.Iterate(func(key string, val value.Value) bool {
+		tr, policy := t.ElementType, w.policy
+		if sf, ok := t.FindField(key); ok {
+			tr = sf.Type
+			if sf.NullPolicy != "" {
+				policy = sf.NullPolicy
+			}
+		}
+		key2 := key
+		child := append(path[:len(path):len(path)], fieldpath.PathElement{FieldName: &key2})
+		if val.IsNull() {
+			switch policy {
+			case schema.NullDeletes:
+				deleted[key] = true
+			case schema.NullIsInvalid:
+				if w.reject {
+					w.errs = append(w.errs, errorf("explicit null is not allowed").WithPrefix(child.String())...)
+				}
+			}
+			return true
+		}
+		if out, ok := w.walk(child, tr, val); ok {
+			changed[key] = out
+		}
+		return true
+	})
+	if len(changed) == 0 && len(deleted) == 0 {
+		return nil, false
+	}
+	out := make(map[string]interface{}, m.Length()-len(deleted))
+	m.Iterate(func(key string, val value.Value) bool {
+		if deleted[key] {
+			return true
+		}
+		if c, ok := changed[key]; ok {
+			out[key] = c
+		} else {
+			out[key] = val.Unstructured()
+		}
+		return true
+	})
+	return out, true
+}
+
+func (w *nullPolicyWalker) walkList(path fieldpath.Path, t *schema.List, l value.List) (interface{}, bool) {
+	changed := map[int]interface{}{}
+	for i := 0; i < l.Length(); i++ {
+		i2 := i
+		child := append(path[:len(path):len(path)], fieldpath.PathElement{Index: &i2})
+		item := l.At(i)
+		if out, ok := w.walk(child, listElementType(value.HeapAllocator, t, item), item); ok {
+			changed[i] = out
+		}
+	}
+	if len(changed) == 0 {
+		return nil, false
+	}
+	out := make([]interface{}, l.Length())
+	for i := range out {
+		if c, ok := changed[i]; ok {
+			out[i] = c
+		} else {
+			out[i] = l.At(i).Unstructured()
+		}
+	}
+	return out, true
+}
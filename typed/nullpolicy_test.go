@@ -0,0 +1,216 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package typed_test
+
+import (
+	"testing"
+
+	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
+	"sigs.k8s.io/structured-merge-diff/v4/schema"
+	"sigs.k8s.io/structured-merge-diff/v4/typed"
+)
+
+var nullPolicyParser = func() *typed.Parser {
+	parser, err := typed.NewParser(`types:
+- name: type
+  map:
+    fields:
+    - name: a
+      type:
+        scalar: string
+    - name: b
+      type:
+        scalar: string
+    - name: kept
+      type:
+        scalar: string
+      nullPolicy: value
+    - name: required
+      type:
+        scalar: string
+      nullPolicy: invalid
+    - name: dropped
+      type:
+        scalar: string
+      nullPolicy: delete
+    - name: list
+      type:
+        list:
+          elementType:
+            namedType: type
+          elementRelationship: atomic
+`)
+	if err != nil {
+		panic(err)
+	}
+	return parser
+}()
+
+func TestMergeWithNullPolicy(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   schema.NullPolicy
+		lhs      typed.YAMLObject
+		rhs      typed.YAMLObject
+		expected typed.YAMLObject
+		err      bool
+	}{{
+		name:     "default keeps nulls",
+		lhs:      `{"a": "x", "kept": "x"}`,
+		rhs:      `{"a": null, "kept": null}`,
+		expected: `{"a": null, "kept": null}`,
+	}, {
+		name:     "field marker without policy",
+		lhs:      `{"a": "x", "dropped": "x"}`,
+		rhs:      `{"a": null, "dropped": null}`,
+		expected: `{"a": null}`,
+	}, {
+		name: "invalid field marker without policy",
+		lhs:  `{"required": "x"}`,
+		rhs:  `{"required": null}`,
+		err:  true,
+	}, {
+		name:     "value",
+		policy:   schema.NullIsValue,
+		lhs:      `{"a": "x"}`,
+		rhs:      `{"a": null}`,
+		expected: `{"a": null}`,
+	}, {
+		name:     "delete",
+		policy:   schema.NullDeletes,
+		lhs:      `{"a": "x", "b": "y", "kept": "z"}`,
+		rhs:      `{"a": null, "kept": null}`,
+		expected: `{"b": "y", "kept": null}`,
+	}, {
+		name:     "delete nested",
+		policy:   schema.NullDeletes,
+		lhs:      `{"list": [{"a": null, "b": "y"}]}`,
+		rhs:      `{"a": "x"}`,
+		expected: `{"a": "x", "list": [{"b": "y"}]}`,
+	}, {
+		name:   "invalid",
+		policy: schema.NullIsInvalid,
+		lhs:    `{"a": "x"}`,
+		rhs:    `{"a": null}`,
+		err:    true,
+	}, {
+		name:     "invalid nulls of lhs are kept",
+		policy:   schema.NullIsInvalid,
+		lhs:      `{"a": null}`,
+		rhs:      `{"b": "y"}`,
+		expected: `{"a": null, "b": "y"}`,
+	}, {
+		name:   "field marker",
+		policy: schema.NullDeletes,
+		lhs:    `{"required": "x"}`,
+		rhs:    `{"required": null}`,
+		err:    true,
+	}}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			pt := nullPolicyParser.Type("type")
+			lhs, err := pt.FromYAML(tt.lhs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rhs, err := pt.FromYAML(tt.rhs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var opts []typed.MergeOption
+			if tt.policy != "" {
+				opts = append(opts, typed.WithNullPolicy(tt.policy))
+			}
+			got, err := lhs.Merge(rhs, opts...)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("expected an error, got %v", got.AsValue())
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			expected, err := pt.FromYAML(tt.expected)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cmp, err := got.Compare(expected); err != nil || !cmp.IsSame() {
+				t.Errorf("expected %v, got %v", expected.AsValue(), got.AsValue())
+			}
+		})
+	}
+}
+
+func TestToFieldSetWithNullPolicy(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   schema.NullPolicy
+		obj      typed.YAMLObject
+		expected *fieldpath.Set
+		err      bool
+	}{{
+		name:     "default",
+		obj:      `{"a": null, "b": "y"}`,
+		expected: _NS(_P("a"), _P("b")),
+	}, {
+		name:     "field marker without policy",
+		obj:      `{"a": null, "dropped": null}`,
+		expected: _NS(_P("a")),
+	}, {
+		name: "invalid field marker without policy",
+		obj:  `{"required": null}`,
+		err:  true,
+	}, {
+		name:     "delete",
+		policy:   schema.NullDeletes,
+		obj:      `{"a": null, "b": "y", "kept": null}`,
+		expected: _NS(_P("b"), _P("kept")),
+	}, {
+		name:   "invalid",
+		policy: schema.NullDeletes,
+		obj:    `{"required": null}`,
+		err:    true,
+	}}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			tv, err := nullPolicyParser.Type("type").FromYAML(tt.obj)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var opts []typed.ToFieldSetOption
+			if tt.policy != "" {
+				opts = append(opts, typed.WithFieldSetNullPolicy(tt.policy))
+			}
+			got, err := tv.ToFieldSet(opts...)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("expected an error, got %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !got.Equals(tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,326 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package typed
+
+import (
+	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
+	"sigs.k8s.io/structured-merge-diff/v4/schema"
+	"sigs.k8s.io/structured-merge-diff/v4/value"
+)
+
+const (
+	// OverlayDirective is the field of the maps of an overlay patch which
+	// holds their directive, see Overlay.
+	OverlayDirective = "$patch"
+	// DeleteDirective deletes the map from the base.
+	DeleteDirective = "delete"
+	// ReplaceDirective replaces the map of the base, rather than merging
+	// with it.
+	ReplaceDirective = "replace"
+	// DeleteFieldsDirective is the field of the maps of an overlay patch
+	// which lists the names of their fields to delete from the base.
+	DeleteFieldsDirective = "$deleteFields"
+	// ReplaceFieldsDirective is the field of the maps of an overlay patch
+	// which lists the names of their associative lists which replace the
+	// ones of the base, rather than merging with them.
+	ReplaceFieldsDirective = "$replaceFields"
+)
+
+// Directives are the directives of a patch, see Overlay and
+// ExtractDirectives.
+type Directives struct {
+	// Delete are the paths of the maps and fields deleted by the patch.
+	Delete *fieldpath.Set
+	// Replace are the paths of the maps and lists replaced by the patch.
+	Replace *fieldpath.Set
+	// ReplaceRoot is whether the patch replaces the whole object.
+	ReplaceRoot bool
+}
+
+// Empty returns whether there is no directive.
+func (d *Directives) Empty() bool {
+	return d.Delete.Empty() && d.Replace.Empty() && !d.ReplaceRoot
+}
+
+// Overlay returns tv overlaid with patch, like kustomize patches do. Unlike
+// Merge, patch is authoritative for what it mentions: its nulls delete the
+// fields of tv, and its maps can have a directive which deletes them from tv,
+// or replaces the ones of tv rather than merging with them:
+//
+//	ports:
+//	- name: debug
+//	  $patch: delete
+//	selector:
+//	  $patch: replace
+//	  app: web
+//
+// Maps can also list fields to delete from tv, whatever their type, and
+// associative lists which replace the ones of tv rather than merging with
+// them, and must then be set:
+//
+//	$deleteFields: [annotations]
+//	$replaceFields: [finalizers]
+//	finalizers: [example.com/cleanup]
+//
+// Directives can be set in the maps which are fields or items of keyed
+// associative lists, and at the root for replace and the field lists. The
+// key fields of list items can't be deleted, and maps which only delete
+// fields are left out of the patch. Without its directives and
+// deleted maps, patch must be a valid object of the type of tv.
+func (tv *TypedValue) Overlay(patch value.Value) (*TypedValue, error) {
+	typedPatch, d, err := tv.extractDirectives(patch, true)
+	if err != nil {
+		return nil, err
+	}
+	if d.ReplaceRoot {
+		return typedPatch, nil
+	}
+	base := tv
+	if remove := d.Delete.Union(d.Replace); !remove.Empty() {
+		base = tv.RemoveItems(remove)
+	}
+	return base.Merge(typedPatch)
+}
+
+// ExtractDirectives returns patch without its directives nor the maps it
+// deletes, as a value of the type of tv, and its directives. Directives are
+// set as described by Overlay, but unlike there the nulls of patch are kept
+// as they are. It fails if a directive is set where it can't be, e.g. in an
+// atomic map.
+func (tv *TypedValue) ExtractDirectives(patch value.Value) (*TypedValue, *Directives, error) {
+	return tv.extractDirectives(patch, false)
+}
+
+func (tv *TypedValue) extractDirectives(patch value.Value, nullDeletes bool) (*TypedValue, *Directives, error) {
+	w := overlayWalker{
+		schema:      tv.schema,
+		allocator:   value.NewFreelistAllocator(),
+		nullDeletes: nullDeletes,
+		directives:  Directives{Delete: fieldpath.NewSet(), Replace: fieldpath.NewSet()},
+	}
+	cleaned, _, errs := w.walk(tv.typeRef, patch, nil, nil)
+	if len(errs) > 0 {
+		return nil, nil, errs
+	}
+	typedPatch, err := AsTyped(value.NewValueInterface(cleaned), tv.schema, tv.typeRef)
+	if err != nil {
+		return nil, nil, err
+	}
+	return typedPatch, &w.directives, nil
+}
+
+type overlayWalker struct {
+	schema    *schema.Schema
+	allocator value.Allocator
+
+	// nullDeletes is whether the nulls of maps delete their field, which
+	// are then added to the deleted paths.
+	nullDeletes bool
+	directives  Directives
+}
+
+// walk returns the unstructured value of the patch v without its
+// directives, and false if it is deleted. keys are the fields which can't
+// be deleted from v, the keys of the list item it is.
+func (w *overlayWalker) walk(tr schema.TypeRef, v value.Value, path fieldpath.Path, keys []string) (interface{}, bool, ValidationErrors) {
+	if v == nil || v.IsNull() {
+		return nil, true, nil
+	}
+	a, ok := w.schema.Resolve(tr)
+	if !ok {
+		// Validation of the patch reports it.
+		return v.Unstructured(), true, nil
+	}
+	a = deduceAtom(a, v)
+	switch {
+	case a.Map != nil && v.IsMap() && a.Map.ElementRelationship != schema.Atomic:
+		return w.mapValue(a.Map, v.AsMapUsing(w.allocator), path, keys)
+	case a.List != nil && v.IsList() && a.List.ElementRelationship == schema.Associative && len(a.List.Keys) > 0:
+		out, errs := w.list(a.List, v.AsListUsing(w.allocator), path)
+		return out, true, errs
+	case a.Map != nil && hasDirective(v):
+		return nil, false, errorf("%v: directives can't be set in atomic maps", path)
+	case a.List != nil && v.IsList():
+		l := v.AsListUsing(w.allocator)
+		defer w.allocator.Free(l)
+		for i := 0; i < l.Length(); i++ {
+			if hasDirective(l.At(i)) {
+				return nil, false, errorf("%v: directives can't be set in the items of lists without keys", path)
+			}
+		}
+	}
+	return v.Unstructured(), true, nil
+}
+
+// hasDirective returns whether v is a map with a directive.
+func hasDirective(v value.Value) bool {
+	if !v.IsMap() {
+		return false
+	}
+	m := v.AsMap()
+	return m.Has(OverlayDirective) || m.Has(DeleteFieldsDirective) || m.Has(ReplaceFieldsDirective)
+}
+
+// isDirective returns whether the field name of a map holds a directive.
+func isDirective(name string) bool {
+	return name == OverlayDirective || name == DeleteFieldsDirective || name == ReplaceFieldsDirective
+}
+
+func (w *overlayWalker) mapValue(t *schema.Map, m value.Map, path fieldpath.Path, keys []string) (interface{}, bool, ValidationErrors) {
+	defer w.allocator.Free(m)
+	if d, ok := m.Get(OverlayDirective); ok {
+		switch {
+		case !d.IsString():
+			return nil, false, errorf("%v: invalid directive %v", path, value.ToString(d))
+		case d.AsString() == DeleteDirective && len(path) == 0:
+			return nil, false, errorf("the object can't be deleted by a directive")
+		case d.AsString() == DeleteDirective:
+			w.directives.Delete.Insert(path)
+			return nil, false, nil
+		case d.AsString() == ReplaceDirective && len(path) == 0:
+			w.directives.ReplaceRoot = true
+		case d.AsString() == ReplaceDirective:
+			w.directives.Replace.Insert(path)
+		default:
+			return nil, false, errorf("%v: unknown directive %q", path, d.AsString())
+		}
+	}
+	errs := w.fieldDirectives(t, m, path, keys)
+	out := make(map[string]interface{}, m.Length())
+	m.IterateUsing(w.allocator, func(key string, val value.Value) bool {
+		if isDirective(key) {
+			return true
+		}
+		k := key
+		childPath := append(path[:len(path):len(path)], fieldpath.PathElement{FieldName: &k})
+		if w.nullDeletes && val.IsNull() {
+			w.directives.Delete.Insert(childPath)
+			return true
+		}
+		tr := t.ElementType
+		if sf, ok := t.FindField(key); ok {
+			tr = sf.Type
+		}
+		child, keep, childErrs := w.walk(tr, val, childPath, nil)
+		errs = append(errs, childErrs...)
+		if keep {
+			out[key] = child
+		}
+		return true
+	})
+	if len(out) == 0 && m.Length() > 0 && len(path) > 0 && !m.Has(OverlayDirective) {
+		// The map only deletes fields, it doesn't set itself.
+		return nil, false, errs
+	}
+	return out, true, errs
+}
+
+// fieldDirectives adds the fields of m listed by its DeleteFieldsDirective
+// and ReplaceFieldsDirective to the directives.
+func (w *overlayWalker) fieldDirectives(t *schema.Map, m value.Map, path fieldpath.Path, keys []string) ValidationErrors {
+	deleted, errs := directiveFields(m, DeleteFieldsDirective, path)
+	for _, name := range deleted {
+		name := name
+		if isKey(keys, name) {
+			errs = append(errs, errorf("%v: key field %q can't be deleted", path, name)...)
+			continue
+		}
+		if val, ok := m.Get(name); ok && !val.IsNull() {
+			errs = append(errs, errorf("%v: field %q is both set and deleted", path, name)...)
+			continue
+		}
+		w.directives.Delete.Insert(append(path.Copy(), fieldpath.PathElement{FieldName: &name}))
+	}
+	replaced, replaceErrs := directiveFields(m, ReplaceFieldsDirective, path)
+	errs = append(errs, replaceErrs...)
+	for _, name := range replaced {
+		name := name
+		tr := t.ElementType
+		if sf, ok := t.FindField(name); ok {
+			tr = sf.Type
+		}
+		a, _ := w.schema.Resolve(tr)
+		if a.List == nil || a.List.ElementRelationship != schema.Associative {
+			errs = append(errs, errorf("%v: field %q isn't an associative list, it can't be replaced by %v", path, name, ReplaceFieldsDirective)...)
+			continue
+		}
+		if val, ok := m.Get(name); !ok || !val.IsList() {
+			errs = append(errs, errorf("%v: replaced field %q must be set to a list", path, name)...)
+			continue
+		}
+		w.directives.Replace.Insert(append(path.Copy(), fieldpath.PathElement{FieldName: &name}))
+	}
+	return errs
+}
+
+func isKey(keys []string, name string) bool {
+	for _, key := range keys {
+		if key == name {
+			return true
+		}
+	}
+	return false
+}
+
+// directiveFields returns the field names listed by the directive of m, if
+// any.
+func directiveFields(m value.Map, directive string, path fieldpath.Path) ([]string, ValidationErrors) {
+	d, ok := m.Get(directive)
+	if !ok {
+		return nil, nil
+	}
+	if !d.IsList() {
+		return nil, errorf("%v: %v must be a list of field names, got %v", path, directive, value.ToString(d))
+	}
+	l := d.AsList()
+	names := make([]string, 0, l.Length())
+	for i := 0; i < l.Length(); i++ {
+		item := l.At(i)
+		if !item.IsString() {
+			return nil, errorf("%v: %v must be a list of field names, got %v", path, directive, value.ToString(d))
+		}
+		names = append(names, item.AsString())
+	}
+	return names, nil
+}
+
+func (w *overlayWalker) list(t *schema.List, l value.List, path fieldpath.Path) (interface{}, ValidationErrors) {
+	defer w.allocator.Free(l)
+	out := make([]interface{}, 0, l.Length())
+	var errs ValidationErrors
+	keys := t.Keys
+	if t.Discriminator != "" {
+		keys = append([]string{t.Discriminator}, keys...)
+	}
+	folders := keyFolders(t)
+	for i := 0; i < l.Length(); i++ {
+		item := l.AtUsing(w.allocator, i)
+		pe, err := listItemToPathElement(w.allocator, w.schema, t, folders, item)
+		if err != nil {
+			w.allocator.Free(item)
+			errs = append(errs, errorf("%v: element %v: %v", path, i, err)...)
+			continue
+		}
+		child, keep, childErrs := w.walk(listElementType(w.allocator, t, item), item, append(path[:len(path):len(path)], pe), keys)
+		w.allocator.Free(item)
+		errs = append(errs, childErrs...)
+		if keep {
+			out = append(out, child)
+		}
+	}
+	return out, errs
+}
@@ -0,0 +1,251 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package typed_test
+
+import (
+	"strings"
+	"testing"
+
+	"sigs.k8s.io/structured-merge-diff/v4/typed"
+	"sigs.k8s.io/structured-merge-diff/v4/value"
+)
+
+var overlayParser = func() *typed.Parser {
+	parser, err := typed.NewParser(`types:
+- name: service
+  map:
+    fields:
+    - name: name
+      type:
+        scalar: string
+    - name: labels
+      type:
+        map:
+          elementType:
+            scalar: string
+    - name: selector
+      type:
+        map:
+          elementType:
+            scalar: string
+    - name: args
+      type:
+        list:
+          elementType:
+            scalar: string
+          elementRelationship: atomic
+    - name: ports
+      type:
+        list:
+          elementType:
+            namedType: port
+          elementRelationship: associative
+          keys: [name]
+    - name: finalizers
+      type:
+        list:
+          elementType:
+            scalar: string
+          elementRelationship: associative
+- name: port
+  map:
+    fields:
+    - name: name
+      type:
+        scalar: string
+    - name: port
+      type:
+        scalar: numeric
+    - name: protocol
+      type:
+        scalar: string
+`)
+	if err != nil {
+		panic(err)
+	}
+	return parser
+}()
+
+func TestOverlay(t *testing.T) {
+	base := `{
+		"name": "a",
+		"labels": {"app": "web", "tier": "front"},
+		"selector": {"app": "web", "tier": "front"},
+		"args": ["-v", "-q"],
+		"ports": [{"name": "http", "port": 80}, {"name": "debug", "port": 9000}]
+	}`
+	tests := []struct {
+		name     string
+		patch    string
+		expected string
+		err      string
+	}{
+		{
+			name:     "merged",
+			patch:    `{"labels": {"env": "prod"}, "args": ["-x"], "ports": [{"name": "http", "protocol": "TCP"}]}`,
+			expected: `{"name": "a", "labels": {"app": "web", "tier": "front", "env": "prod"}, "selector": {"app": "web", "tier": "front"}, "args": ["-x"], "ports": [{"name": "http", "port": 80, "protocol": "TCP"}, {"name": "debug", "port": 9000}]}`,
+		}, {
+			name:     "nulls delete",
+			patch:    `{"labels": {"tier": null}, "args": null, "ports": [{"name": "http", "port": null, "protocol": "TCP"}]}`,
+			expected: `{"name": "a", "labels": {"app": "web"}, "selector": {"app": "web", "tier": "front"}, "ports": [{"name": "http", "protocol": "TCP"}, {"name": "debug", "port": 9000}]}`,
+		}, {
+			name:     "delete directive",
+			patch:    `{"ports": [{"name": "debug", "$patch": "delete"}, {"name": "https", "port": 443}]}`,
+			expected: `{"name": "a", "labels": {"app": "web", "tier": "front"}, "selector": {"app": "web", "tier": "front"}, "args": ["-v", "-q"], "ports": [{"name": "http", "port": 80}, {"name": "https", "port": 443}]}`,
+		}, {
+			name:     "replace directive",
+			patch:    `{"selector": {"$patch": "replace", "app": "api"}, "labels": {"$patch": "delete"}}`,
+			expected: `{"name": "a", "selector": {"app": "api"}, "args": ["-v", "-q"], "ports": [{"name": "http", "port": 80}, {"name": "debug", "port": 9000}]}`,
+		}, {
+			name:     "replace root",
+			patch:    `{"$patch": "replace", "name": "b"}`,
+			expected: `{"name": "b"}`,
+		}, {
+			name:  "delete root",
+			patch: `{"$patch": "delete"}`,
+			err:   "the object can't be deleted by a directive",
+		}, {
+			name:  "unknown directive",
+			patch: `{"ports": [{"name": "http", "$patch": "merge"}]}`,
+			err:   `.ports[name="http"]: unknown directive "merge"`,
+		}, {
+			name:  "directive in atomic list",
+			patch: `{"args": [{"$patch": "delete"}]}`,
+			err:   ".args: directives can't be set in the items of lists without keys",
+		},
+	}
+	pt := overlayParser.Type("service")
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			tv, err := pt.FromYAML(typed.YAMLObject(base))
+			if err != nil {
+				t.Fatal(err)
+			}
+			patch, err := value.FromJSON([]byte(tt.patch))
+			if err != nil {
+				t.Fatal(err)
+			}
+			out, err := tv.Overlay(patch)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("expected error %q, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			expected, err := pt.FromYAML(typed.YAMLObject(tt.expected))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !value.Equals(out.AsValue(), expected.AsValue()) {
+				t.Errorf("expected:\n%v\ngot:\n%v", value.ToString(expected.AsValue()), value.ToString(out.AsValue()))
+			}
+		})
+	}
+}
+
+func TestOverlayFieldDirectives(t *testing.T) {
+	base := `{
+		"name": "a",
+		"labels": {"app": "web", "tier": "front"},
+		"args": ["-v", "-q"],
+		"ports": [{"name": "http", "port": 80, "protocol": "TCP"}, {"name": "debug", "port": 9000}],
+		"finalizers": ["a", "b"]
+	}`
+	tests := []struct {
+		name     string
+		patch    string
+		expected string
+		err      string
+	}{
+		{
+			name:     "delete fields",
+			patch:    `{"$deleteFields": ["labels", "args"], "ports": [{"name": "http", "$deleteFields": ["protocol", "unknown"]}]}`,
+			expected: `{"name": "a", "ports": [{"name": "http", "port": 80}, {"name": "debug", "port": 9000}], "finalizers": ["a", "b"]}`,
+		}, {
+			name:     "replace lists",
+			patch:    `{"$replaceFields": ["ports", "finalizers"], "ports": [{"name": "https", "port": 443}], "finalizers": ["c"]}`,
+			expected: `{"name": "a", "labels": {"app": "web", "tier": "front"}, "args": ["-v", "-q"], "ports": [{"name": "https", "port": 443}], "finalizers": ["c"]}`,
+		}, {
+			name:     "replace with an empty list",
+			patch:    `{"$replaceFields": ["finalizers"], "finalizers": []}`,
+			expected: `{"name": "a", "labels": {"app": "web", "tier": "front"}, "args": ["-v", "-q"], "ports": [{"name": "http", "port": 80, "protocol": "TCP"}, {"name": "debug", "port": 9000}], "finalizers": []}`,
+		}, {
+			name:  "delete key field",
+			patch: `{"ports": [{"name": "http", "$deleteFields": ["name"]}]}`,
+			err:   `.ports[name="http"]: key field "name" can't be deleted`,
+		}, {
+			name:  "delete set field",
+			patch: `{"$deleteFields": ["name"], "name": "b"}`,
+			err:   `field "name" is both set and deleted`,
+		}, {
+			name:  "invalid field list",
+			patch: `{"$deleteFields": "name"}`,
+			err:   `$deleteFields must be a list of field names`,
+		}, {
+			name:  "replace atomic list",
+			patch: `{"$replaceFields": ["args"], "args": []}`,
+			err:   `field "args" isn't an associative list`,
+		}, {
+			name:  "replace map",
+			patch: `{"$replaceFields": ["labels"], "labels": {}}`,
+			err:   `field "labels" isn't an associative list`,
+		}, {
+			name:  "replace unset list",
+			patch: `{"$replaceFields": ["ports"]}`,
+			err:   `replaced field "ports" must be set to a list`,
+		}, {
+			name:  "field directive in atomic list",
+			patch: `{"args": [{"$deleteFields": ["a"]}]}`,
+			err:   ".args: directives can't be set in the items of lists without keys",
+		},
+	}
+	pt := overlayParser.Type("service")
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			tv, err := pt.FromYAML(typed.YAMLObject(base))
+			if err != nil {
+				t.Fatal(err)
+			}
+			patch, err := value.FromJSON([]byte(tt.patch))
+			if err != nil {
+				t.Fatal(err)
+			}
+			out, err := tv.Overlay(patch)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("expected error %q, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			expected, err := pt.FromYAML(typed.YAMLObject(tt.expected))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !value.Equals(out.AsValue(), expected.AsValue()) {
+				t.Errorf("expected:\n%v\ngot:\n%v", value.ToString(expected.AsValue()), value.ToString(out.AsValue()))
+			}
+		})
+	}
+}
@@ -17,8 +17,14 @@ limitations under the License.
 package typed
 
 import (
+	"bytes"
+	"encoding/gob"
+	"errors"
 	"fmt"
+	"strings"
 
+	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
+	"sigs.k8s.io/structured-merge-diff/v4/internal/bytesconv"
 	"sigs.k8s.io/structured-merge-diff/v4/schema"
 	"sigs.k8s.io/structured-merge-diff/v4/value"
 	yaml "sigs.k8s.io/yaml/goyaml.v2"
@@ -28,19 +34,33 @@ import (
 type YAMLObject string
 
 // Parser implements YAMLParser and allows introspecting the schema.
+//
+// A Parser is safe for concurrent use, provided its Schema isn't modified
+// after construction.
 type Parser struct {
 	Schema schema.Schema
 }
 
-// create builds an unvalidated parser.
-func create(s YAMLObject) (*Parser, error) {
+// create builds an unvalidated parser. check, if not nil, is called before
+// the schema is indexed.
+func create(s YAMLObject, check func(*schema.Schema) error) (*Parser, error) {
 	p := Parser{}
-	err := yaml.Unmarshal([]byte(s), &p.Schema)
-	return &p, err
+	if err := yaml.Unmarshal([]byte(s), &p.Schema); err != nil {
+		return &p, err
+	}
+	if check != nil {
+		if err := check(&p.Schema); err != nil {
+			return nil, err
+		}
+	}
+	// Index the types now rather than on the first lookup, so that the
+	// schema isn't modified once it's shared.
+	p.Schema.FindNamedType("")
+	return &p, nil
 }
 
 func createOrDie(schema YAMLObject) *Parser {
-	p, err := create(schema)
+	p, err := create(schema, nil)
 	if err != nil {
 		panic(fmt.Errorf("failed to create parser: %v", err))
 	}
@@ -49,19 +69,141 @@ func createOrDie(schema YAMLObject) *Parser {
 
 var ssParser = createOrDie(YAMLObject(schema.SchemaSchemaYAML))
 
+// ParserOption configures NewParser.
+type ParserOption func(*parserOptions)
+
+type parserOptions struct {
+	atomicInvalidSets bool
+	warn              func(location string)
+	granularMaps      []fieldpath.Path
+}
+
+// WithAtomicInvalidSets makes NewParser accept the associative lists without
+// keys whose elements aren't scalars, which it otherwise rejects, and make
+// them atomic instead. warn, if not nil, is called with the location of each
+// of them (see schema.Schema.InvalidSets).
+func WithAtomicInvalidSets(warn func(location string)) ParserOption {
+	return func(opts *parserOptions) {
+		opts.atomicInvalidSets = true
+		opts.warn = warn
+	}
+}
+
+// WithGranularMaps makes NewParser override the element relationship of the
+// maps at paths, from any type of the schema, so that their keys are owned
+// one by one, e.g. .metadata.labels (see schema.Schema.MakeMapsGranular).
+// Paths can only be made of field names.
+func WithGranularMaps(paths ...fieldpath.Path) ParserOption {
+	return func(opts *parserOptions) {
+		opts.granularMaps = append(opts.granularMaps, paths...)
+	}
+}
+
 // NewParser will build a YAMLParser from a schema. The schema is validated.
-func NewParser(schema YAMLObject) (*Parser, error) {
-	_, err := ssParser.Type("schema").FromYAML(schema)
+func NewParser(schemaYAML YAMLObject, opts ...ParserOption) (*Parser, error) {
+	options := &parserOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+	_, err := ssParser.Type("schema").FromYAML(schemaYAML)
 	if err != nil {
 		return nil, fmt.Errorf("unable to validate schema: %v", err)
 	}
-	p, err := create(schema)
+	p, err := create(schemaYAML, func(s *schema.Schema) error {
+		for _, path := range options.granularMaps {
+			fields := make([]string, len(path))
+			for i, pe := range path {
+				if pe.FieldName == nil {
+					return fmt.Errorf("invalid granular map %v: only field names are allowed", path)
+				}
+				fields[i] = *pe.FieldName
+			}
+			s.MakeMapsGranular(fields)
+		}
+		if options.atomicInvalidSets {
+			for _, location := range s.MakeInvalidSetsAtomic() {
+				if options.warn != nil {
+					options.warn(location)
+				}
+			}
+			return nil
+		}
+		if invalid := s.InvalidSets(); len(invalid) > 0 {
+			return fmt.Errorf("unable to validate schema: associative lists without keys must have scalar or atomic map elements: %v", strings.Join(invalid, ", "))
+		}
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
 	return p, nil
 }
 
+// CompiledSchema is a validated schema whose indexes are all built (see
+// schema.Schema.Compile). It is immutable, and Parsers created from it share
+// its indexes, which makes creating them almost free.
+type CompiledSchema struct {
+	schema schema.Schema
+}
+
+// CompileSchema validates and compiles a schema.
+func CompileSchema(schemaYAML YAMLObject, opts ...ParserOption) (*CompiledSchema, error) {
+	p, err := NewParser(schemaYAML, opts...)
+	if err != nil {
+		return nil, err
+	}
+	c := &CompiledSchema{}
+	p.Schema.Compile()
+	p.Schema.CopyInto(&c.schema)
+	return c, nil
+}
+
+// Parser returns a new Parser for the compiled schema.
+func (c *CompiledSchema) Parser() *Parser {
+	p := &Parser{}
+	c.schema.CopyInto(&p.Schema)
+	return p
+}
+
+// compiledSchemaMagic starts the binary encoding of compiled schemas, it
+// must change whenever the encoding does.
+const compiledSchemaMagic = "smd-compiled-schema/v1\n"
+
+func init() {
+	// The defaults and enums of schemas hold the values parsed from YAML,
+	// and gob needs the types of the lists and maps among them registered.
+	gob.Register([]interface{}{})
+	gob.Register(map[interface{}]interface{}{})
+	gob.Register(map[string]interface{}{})
+}
+
+// MarshalBinary encodes the compiled schema in a binary format which is much
+// faster to load than YAML, see UnmarshalBinary.
+func (c *CompiledSchema) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(compiledSchemaMagic)
+	if err := gob.NewEncoder(&buf).Encode(c.schema.Types); err != nil {
+		return nil, fmt.Errorf("unable to encode schema: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary decodes a schema encoded by MarshalBinary, and compiles it.
+// The schema isn't validated again, so data must come from a trusted source.
+// It must only be called on a new CompiledSchema.
+func (c *CompiledSchema) UnmarshalBinary(data []byte) error {
+	if !bytes.HasPrefix(data, []byte(compiledSchemaMagic)) {
+		return errors.New("unable to decode schema: not a compiled schema, or encoded by an incompatible version")
+	}
+	var types []schema.TypeDef
+	if err := gob.NewDecoder(bytes.NewReader(data[len(compiledSchemaMagic):])).Decode(&types); err != nil {
+		return fmt.Errorf("unable to decode schema: %v", err)
+	}
+	c.schema.Types = types
+	c.schema.Compile()
+	return nil
+}
+
 // TypeNames returns a list of types this parser understands.
 func (p *Parser) TypeNames() (names []string) {
 	for _, td := range p.Schema.Types {
@@ -79,10 +221,15 @@ func (p *Parser) Type(name string) ParseableType {
 	}
 }
 
-// ParseableType allows for easy production of typed objects.
+// ParseableType allows for easy production of typed objects. It is safe for
+// concurrent use, and so are the TypedValues it produces as long as neither
+// they nor the objects they were created from are modified.
 type ParseableType struct {
 	TypeRef schema.TypeRef
 	Schema  *schema.Schema
+
+	untypedLimits UntypedLimits
+	coercions     *Coercions
 }
 
 // IsValid return true if p's schema and typename are valid.
@@ -92,14 +239,40 @@ func (p ParseableType) IsValid() bool {
 }
 
 // FromYAML parses a yaml string into an object with the current schema
-// and the type "typename" or an error if validation fails.
+// and the type "typename" or an error if validation fails. YAML aliases and
+// merge keys are expanded, within limits, unless RejectYAMLAliases is given.
+// Duplicate keys keep the last value, unless RejectDuplicateKeys is given.
 func (p ParseableType) FromYAML(object YAMLObject, opts ...ValidationOptions) (*TypedValue, error) {
+	rejectAliases, rejectDuplicateKeys := false, false
+	for _, opt := range opts {
+		switch opt {
+		case RejectYAMLAliases:
+			rejectAliases = true
+		case RejectDuplicateKeys:
+			rejectDuplicateKeys = true
+		}
+	}
+	// The decoders only read the document, it doesn't need to be copied.
+	if err := checkYAMLAliases(bytesconv.Bytes(string(object)), rejectAliases); err != nil {
+		return nil, err
+	}
+	if rejectDuplicateKeys {
+		if err := rejectDuplicateYAMLKeys(object); err != nil {
+			return nil, err
+		}
+	}
+	if err := checkJSONUntypedLimits(p.Schema, p.TypeRef, bytesconv.Bytes(string(object)), p.untypedLimits); err != nil {
+		return nil, err
+	}
 	var v interface{}
-	err := yaml.Unmarshal([]byte(object), &v)
+	err := yaml.Unmarshal(bytesconv.Bytes(string(object)), &v)
 	if err != nil {
 		return nil, err
 	}
-	return AsTyped(value.NewValueInterface(v), p.Schema, p.TypeRef, opts...)
+	if err := stringifyYAMLKeys(v, nil); err != nil {
+		return nil, err
+	}
+	return p.asTyped(value.NewValueInterface(v), opts)
 }
 
 // FromUnstructured converts a go "interface{}" type, typically an
@@ -109,20 +282,7 @@ func (p ParseableType) FromYAML(object YAMLObject, opts ...ValidationOptions) (*
 // map[interface{}]interface{}, []interface{}, int types, float types,
 // string or boolean. Nested interface{} must also be one of these types.
 func (p ParseableType) FromUnstructured(in interface{}, opts ...ValidationOptions) (*TypedValue, error) {
-	return AsTyped(value.NewValueInterface(in), p.Schema, p.TypeRef, opts...)
-}
-
-// FromStructured converts a go "interface{}" type, typically an structured object in
-// Kubernetes, to a TypedValue. It will return an error if the resulting object fails
-// schema validation. The provided "interface{}" value must be a pointer so that the
-// value can be modified via reflection. The provided "interface{}" may contain structs
-// and types that are converted to Values by the jsonMarshaler interface.
-func (p ParseableType) FromStructured(in interface{}, opts ...ValidationOptions) (*TypedValue, error) {
-	v, err := value.NewValueReflect(in)
-	if err != nil {
-		return nil, fmt.Errorf("error creating struct value reflector: %v", err)
-	}
-	return AsTyped(v, p.Schema, p.TypeRef, opts...)
+	return p.asTyped(value.NewValueInterface(in), opts)
 }
 
 // DeducedParseableType is a ParseableType that deduces the type from
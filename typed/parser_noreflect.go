@@ -0,0 +1,29 @@
+//go:build smd_noreflect
+// +build smd_noreflect
+
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package typed
+
+import "errors"
+
+// FromStructured is not supported when built with the smd_noreflect tag,
+// which leaves out the reflection backed values. Use FromUnstructured or
+// FromYAML instead.
+func (p ParseableType) FromStructured(in interface{}, opts ...ValidationOptions) (*TypedValue, error) {
+	return nil, errors.New("converting structured objects is not supported when built with smd_noreflect")
+}
@@ -17,12 +17,15 @@ limitations under the License.
 package typed_test
 
 import (
+	"fmt"
 	"io/ioutil"
 	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
 
 	"sigs.k8s.io/structured-merge-diff/v4/typed"
+	"sigs.k8s.io/structured-merge-diff/v4/value"
 	yaml "sigs.k8s.io/yaml/goyaml.v2"
 )
 
@@ -103,3 +106,467 @@ func BenchmarkConvertUnstructured(b *testing.B) {
 		})
 	}
 }
+
+// TestParserConcurrentUse shares a parser and its typed values across
+// goroutines. It's most useful when run with -race.
+func TestParserConcurrentUse(t *testing.T) {
+	s, err := ioutil.ReadFile(testdata("k8s-schema.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parser, err := typed.NewParser(typed.YAMLObject(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pod := parser.Type("io.k8s.api.core.v1.Pod")
+	shared, err := pod.FromYAML(typed.YAMLObject(read(testdata("pod.yaml"))))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 64)
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 4; j++ {
+				tv, err := pod.FromYAML(typed.YAMLObject(read(testdata("pod.yaml"))))
+				if err != nil {
+					errs <- err
+					return
+				}
+				if _, err := tv.ToFieldSet(); err != nil {
+					errs <- err
+					return
+				}
+				if _, err := shared.ToFieldSet(); err != nil {
+					errs <- err
+					return
+				}
+				comparison, err := shared.Compare(tv)
+				if err != nil {
+					errs <- err
+					return
+				}
+				if !comparison.IsSame() {
+					errs <- fmt.Errorf("expected no difference, got %v", comparison)
+					return
+				}
+				if _, err := shared.Merge(tv); err != nil {
+					errs <- err
+					return
+				}
+				if err := shared.Validate(); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+func TestFromYAMLAliases(t *testing.T) {
+	laughs := "a: &a [x, x, x, x, x, x, x, x, x, x]\n"
+	for i, prev := 1, "a"; i < 8; i++ {
+		name := fmt.Sprintf("a%d", i)
+		laughs += fmt.Sprintf("%v: &%v [*%v, *%v, *%v, *%v, *%v, *%v, *%v, *%v, *%v, *%v]\n", name, name, prev, prev, prev, prev, prev, prev, prev, prev, prev, prev)
+		prev = name
+	}
+
+	tests := []struct {
+		name     string
+		object   typed.YAMLObject
+		opts     []typed.ValidationOptions
+		expected string
+		err      string
+	}{{
+		name:     "no aliases",
+		object:   `{"a": [1, 2], "b": {"c": "<<"}}`,
+		expected: `{"a":[1,2],"b":{"c":"<<"}}`,
+	}, {
+		name:     "no aliases rejected",
+		object:   `{"a": [1, 2], "b": {"c": "&*"}}`,
+		opts:     []typed.ValidationOptions{typed.RejectYAMLAliases},
+		expected: `{"a":[1,2],"b":{"c":"&*"}}`,
+	}, {
+		name: "alias",
+		object: `
+a: &list [1, 2]
+b: *list
+`,
+		expected: `{"a":[1,2],"b":[1,2]}`,
+	}, {
+		name: "merge key",
+		object: `
+a: &map {p: 1, q: 2}
+b:
+  <<: *map
+  q: 3
+`,
+		expected: `{"a":{"p":1,"q":2},"b":{"p":1,"q":3}}`,
+	}, {
+		name: "rejected alias",
+		object: `
+a: &list [1, 2]
+b: *list
+`,
+		opts: []typed.ValidationOptions{typed.RejectYAMLAliases},
+		err:  "line 2: YAML anchors are not allowed (anchor &list)",
+	}, {
+		name: "rejected merge key",
+		object: `
+b:
+  <<: {p: 1}
+  q: 3
+`,
+		opts: []typed.ValidationOptions{typed.RejectYAMLAliases},
+		err:  "line 3: YAML merge keys are not allowed",
+	}, {
+		name:   "self reference",
+		object: `a: &a [1, *a]`,
+		err:    "YAML alias *a references itself",
+	}, {
+		name:   "billion laughs",
+		object: typed.YAMLObject(laughs),
+		err:    "YAML aliases expand to more than",
+	}}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			tv, err := typed.DeducedParseableType.FromYAML(tt.object, tt.opts...)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("expected error containing %q, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			expected, err := typed.DeducedParseableType.FromYAML(typed.YAMLObject(tt.expected))
+			if err != nil {
+				t.Fatal(err)
+			}
+			comparison, err := tv.Compare(expected)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !comparison.IsSame() {
+				t.Errorf("expected %v, got %v", tt.expected, comparison)
+			}
+		})
+	}
+}
+
+func TestDuplicateKeys(t *testing.T) {
+	tests := []struct {
+		name     string
+		object   typed.YAMLObject
+		expected []string
+	}{{
+		name:   "none",
+		object: `{"a": 1, "b": {"a": 1}, "c": [{"a": 1}, {"a": 2}]}`,
+	}, {
+		name:     "json",
+		object:   `{"a": 1, "b": {"c": 1, "c": 2}, "a": 3}`,
+		expected: []string{".b.c", ".a"},
+	}, {
+		name: "yaml in list",
+		object: `
+items:
+- name: a
+  value: 1
+- name: b
+  value: 2
+  value: 3
+`,
+		expected: []string{".items[1].value"},
+	}, {
+		name: "merge keys override",
+		object: `
+base: &base {p: 1}
+derived:
+  <<: *base
+  p: 2
+`,
+	}}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			duplicates, err := typed.DuplicateYAMLKeys(tt.object)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, path := range duplicates {
+				got = append(got, path.String())
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.expected) {
+				t.Errorf("expected duplicates %v, got %v", tt.expected, got)
+			}
+
+			_, err = typed.DeducedParseableType.FromYAML(tt.object, typed.RejectDuplicateKeys)
+			if len(tt.expected) == 0 {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+			} else if err == nil || !strings.Contains(err.Error(), tt.expected[0]+": duplicate key") {
+				t.Errorf("expected duplicate key error for %v, got %v", tt.expected[0], err)
+			}
+			if _, err := typed.DeducedParseableType.FromYAML(tt.object); err != nil {
+				t.Errorf("unexpected error without RejectDuplicateKeys: %v", err)
+			}
+		})
+	}
+}
+
+func TestFromYAMLNonStringKeys(t *testing.T) {
+	tests := []struct {
+		name     string
+		object   typed.YAMLObject
+		expected string
+		err      string
+	}{{
+		name:     "scalars",
+		object:   `{1: a, 1.5: b, on: c, null: d}`,
+		expected: `{"1": "a", "1.5": "b", "true": "c", "null": "d"}`,
+	}, {
+		name:     "nested",
+		object:   `{a: [{00: b}]}`,
+		expected: `{"a": [{"0": "b"}]}`,
+	}, {
+		name:   "collision",
+		object: `{"a": {1: b, "1": c}}`,
+		err:    `.a: duplicate key "1"`,
+	}}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			tv, err := typed.DeducedParseableType.FromYAML(tt.object)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("expected error %q, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			expected, err := value.FromJSON([]byte(tt.expected))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !value.Equals(tv.AsValue(), expected) {
+				t.Errorf("expected %v, got %v", value.ToString(expected), value.ToString(tv.AsValue()))
+			}
+		})
+	}
+}
+
+func TestNewParserInvalidSets(t *testing.T) {
+	schemaYAML := typed.YAMLObject(`types:
+- name: root
+  map:
+    fields:
+    - name: items
+      type:
+        list:
+          elementType:
+            map:
+              elementType:
+                scalar: string
+          elementRelationship: associative
+`)
+	_, err := typed.NewParser(schemaYAML)
+	if err == nil || !strings.Contains(err.Error(), "root.items") {
+		t.Fatalf("expected an error about root.items, got %v", err)
+	}
+
+	var warnings []string
+	parser, err := typed.NewParser(schemaYAML, typed.WithAtomicInvalidSets(func(location string) {
+		warnings = append(warnings, location)
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(warnings) != 1 || warnings[0] != "root.items" {
+		t.Errorf("expected a warning for root.items, got %v", warnings)
+	}
+	pt := parser.Type("root")
+	lhs, err := pt.FromYAML(`{"items": [{"a": "x"}, {"b": "y"}]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rhs, err := pt.FromYAML(`{"items": [{"c": "z"}]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := lhs.Merge(rhs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !value.Equals(out.AsValue(), rhs.AsValue()) {
+		t.Errorf("expected the atomic list to be replaced, got %v", out.AsValue())
+	}
+}
+
+func TestCompiledSchema(t *testing.T) {
+	s := read(testdata("k8s-schema.yaml"))
+	compiled, err := typed.CompileSchema(typed.YAMLObject(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := typed.CompileSchema("types: 1"); err == nil {
+		t.Error("expected an invalid schema to be rejected")
+	}
+	parser := compiled.Parser()
+	if !parser.Schema.IsCompiled() {
+		t.Error("expected the parser to share the compiled schema")
+	}
+	tv, err := parser.Type("io.k8s.api.core.v1.Pod").FromYAML(typed.YAMLObject(read(testdata("pod.yaml"))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tv.ToFieldSet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func BenchmarkNewParser(b *testing.B) {
+	s := typed.YAMLObject(read(testdata("k8s-schema.yaml")))
+	b.Run("NewParser", func(b *testing.B) {
+		b.ReportAllocs()
+		for n := 0; n < b.N; n++ {
+			if _, err := typed.NewParser(s); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	compiled, err := typed.CompileSchema(s)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Run("Compiled", func(b *testing.B) {
+		b.ReportAllocs()
+		for n := 0; n < b.N; n++ {
+			compiled.Parser()
+		}
+	})
+}
+
+func TestCompiledSchemaBinary(t *testing.T) {
+	s := read(testdata("k8s-schema.yaml"))
+	compiled, err := typed.CompileSchema(typed.YAMLObject(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := compiled.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded typed.CompiledSchema
+	if err := decoded.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	expected, got := compiled.Parser(), decoded.Parser()
+	if !got.Schema.IsCompiled() {
+		t.Error("expected the decoded schema to be compiled")
+	}
+	if !expected.Schema.Equals(&got.Schema) {
+		t.Error("expected the decoded schema to equal the encoded one")
+	}
+	tv, err := got.Type("io.k8s.api.core.v1.Pod").FromYAML(typed.YAMLObject(read(testdata("pod.yaml"))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tv.ToFieldSet(); err != nil {
+		t.Error(err)
+	}
+
+	for _, data := range [][]byte{nil, []byte("types: []"), data[:len(data)/2]} {
+		if err := new(typed.CompiledSchema).UnmarshalBinary(data); err == nil {
+			t.Errorf("expected %.20q to be rejected", data)
+		}
+	}
+}
+
+func TestCompiledSchemaBinaryDefaults(t *testing.T) {
+	compiled, err := typed.CompileSchema(`types:
+- name: type
+  map:
+    fields:
+    - name: resources
+      type:
+        map:
+          elementType:
+            scalar: string
+      default:
+        cpu: "1"
+        memory: 1Gi
+    - name: args
+      type:
+        list:
+          elementType:
+            scalar: string
+          elementRelationship: atomic
+      default: [a, b]
+    - name: ports
+      type:
+        list:
+          elementType:
+            map:
+              elementType:
+                scalar: untyped
+          elementRelationship: atomic
+      default:
+      - name: http
+        port: 80
+        options: {weight: 1.5, hosts: [a, ~]}
+    - name: policy
+      type:
+        scalar: untyped
+        enum: [Always, 1, 2.5, true]
+      default: 2.5
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := compiled.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded typed.CompiledSchema
+	if err := decoded.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	expected, got := compiled.Parser(), decoded.Parser()
+	if !expected.Schema.Equals(&got.Schema) {
+		t.Errorf("expected the decoded schema to equal the encoded one, got:\n%v", got.Schema.Types)
+	}
+}
+
+func BenchmarkCompiledSchemaUnmarshal(b *testing.B) {
+	compiled, err := typed.CompileSchema(typed.YAMLObject(read(testdata("k8s-schema.yaml"))))
+	if err != nil {
+		b.Fatal(err)
+	}
+	data, err := compiled.MarshalBinary()
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		if err := new(typed.CompiledSchema).UnmarshalBinary(data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
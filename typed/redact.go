@@ -0,0 +1,168 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package typed
+
+import (
+	"fmt"
+
+	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
+	"sigs.k8s.io/structured-merge-diff/v4/schema"
+	"sigs.k8s.io/structured-merge-diff/v4/value"
+)
+
+// RedactedValue is the placeholder of the values removed by Redact.
+const RedactedValue = "<redacted>"
+
+// Redact returns tv with the scalars at the paths which one of the
+// patterns matches, or below them, replaced by RedactedValue, e.g. to log
+// objects holding secrets with the pattern .data.*. The structure of the
+// value is kept: maps keep their fields and lists their items, so that the
+// redacted value still shows what was set.
+//
+// So that the items of lists keep their identity, the key fields of the
+// items of associative lists are kept, e.g. the names of the containers
+// redacted by .spec.containers[*].**, and the items of sets are replaced by
+// RedactedValue followed by their index, e.g. "<redacted:1>", which keeps
+// them distinct without telling anything about them. Nulls are kept too.
+// Numbers and booleans become strings, so the result may not validate
+// against the schema: it is meant to be read, not applied.
+//
+// The parts of tv which no pattern can match are shared with the result.
+func (tv *TypedValue) Redact(patterns ...*fieldpath.Pattern) (*TypedValue, error) {
+	if err := tv.validated(); err != nil {
+		return nil, err
+	}
+	r := redactor{schema: tv.schema, allocator: value.NewFreelistAllocator()}
+	out := r.redact(tv.value, tv.typeRef, fieldpath.StartPatterns(patterns...), false)
+	// Not withValue: the result isn't validated again, since it may not be
+	// valid, and the list index would be of the items of tv.
+	return &TypedValue{value: value.NewValueInterface(out), typeRef: tv.typeRef, schema: tv.schema}, nil
+}
+
+type redactor struct {
+	schema    *schema.Schema
+	allocator value.Allocator
+}
+
+// redact returns the unstructured v, of type tr, redacted where states
+// match. All of v is redacted if all is true.
+func (r *redactor) redact(v value.Value, tr schema.TypeRef, states fieldpath.PatternStates, all bool) interface{} {
+	all = all || states.Matched()
+	if v == nil || v.IsNull() || (!all && states.Failed()) {
+		if v == nil {
+			return nil
+		}
+		return v.Unstructured()
+	}
+	a, ok := r.schema.Resolve(tr)
+	if ok {
+		a = deduceAtom(a, v)
+	}
+	switch {
+	case v.IsMap():
+		return r.redactMap(v, a.Map, states, all)
+	case v.IsList():
+		return r.redactList(v, a.List, states, all)
+	case all:
+		return RedactedValue
+	}
+	return v.Unstructured()
+}
+
+func (r *redactor) redactMap(v value.Value, t *schema.Map, states fieldpath.PatternStates, all bool) interface{} {
+	m := v.AsMapUsing(r.allocator)
+	defer r.allocator.Free(m)
+	out := make(map[string]interface{}, m.Length())
+	m.Iterate(func(key string, val value.Value) bool {
+		var tr schema.TypeRef
+		if t != nil {
+			tr = t.ElementType
+			if sf, ok := t.FindField(key); ok {
+				tr = sf.Type
+			}
+		}
+		out[key] = r.redact(val, tr, states.Next(fieldpath.PathElement{FieldName: &key}), all)
+		return true
+	})
+	return out
+}
+
+func (r *redactor) redactList(v value.Value, t *schema.List, states fieldpath.PatternStates, all bool) interface{} {
+	l := v.AsListUsing(r.allocator)
+	defer r.allocator.Free(l)
+	out := make([]interface{}, l.Length())
+	folders := keyFolders(t)
+	for i := range out {
+		item := l.At(i)
+		var tr schema.TypeRef
+		index := i
+		pe := fieldpath.PathElement{Index: &index}
+		keyed := false
+		if t != nil {
+			tr = listElementType(r.allocator, t, item)
+			if t.ElementRelationship == schema.Associative {
+				if len(t.Keys) == 0 {
+					// The items of sets are their own keys, the redacted
+					// ones must stay distinct.
+					out[i] = item.Unstructured()
+					if all || states.Next(fieldpath.PathElement{Value: &item}).Matched() {
+						out[i] = redactedSetItem(i)
+					}
+					continue
+				}
+				if kpe, err := keyedAssociativeListItemToPathElement(r.allocator, r.schema, t, folders, item); err == nil {
+					pe = kpe
+					keyed = true
+				}
+			}
+		}
+		next := states.Next(pe)
+		out[i] = r.redact(item, tr, next, all)
+		if keyed && (all || !next.Failed()) {
+			// The item was copied, and its keys may be redacted.
+			r.restoreKeys(out[i], item, t)
+		}
+	}
+	return out
+}
+
+// redactedSetItem returns the placeholder of the item of a set at index,
+// which is distinct for distinct items. It isn't derived from the item, so
+// that it tells nothing about it.
+func redactedSetItem(index int) string {
+	return fmt.Sprintf("%s:%d>", RedactedValue[:len(RedactedValue)-1], index)
+}
+
+// restoreKeys sets the key fields of the redacted item of the list t back
+// to those of the original item.
+func (r *redactor) restoreKeys(redacted interface{}, item value.Value, t *schema.List) {
+	out, ok := redacted.(map[string]interface{})
+	if !ok {
+		return
+	}
+	m := item.AsMapUsing(r.allocator)
+	defer r.allocator.Free(m)
+	keys := t.Keys
+	if t.Discriminator != "" {
+		keys = append([]string{t.Discriminator}, keys...)
+	}
+	for _, key := range keys {
+		if val, ok := m.Get(key); ok {
+			out[key] = val.Unstructured()
+		}
+	}
+}
@@ -0,0 +1,245 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package typed_test
+
+import (
+	"strings"
+	"testing"
+
+	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
+	"sigs.k8s.io/structured-merge-diff/v4/typed"
+	"sigs.k8s.io/structured-merge-diff/v4/value"
+)
+
+var redactParser = func() *typed.Parser {
+	parser, err := typed.NewParser(`types:
+- name: pod
+  map:
+    fields:
+    - name: data
+      type:
+        map:
+          elementType:
+            scalar: string
+    - name: containers
+      type:
+        list:
+          elementType:
+            namedType: container
+          elementRelationship: associative
+          keys:
+          - name
+    - name: finalizers
+      type:
+        list:
+          elementType:
+            scalar: string
+          elementRelationship: associative
+    - name: replicas
+      type:
+        scalar: numeric
+- name: container
+  map:
+    fields:
+    - name: name
+      type:
+        scalar: string
+    - name: image
+      type:
+        scalar: string
+    - name: args
+      type:
+        list:
+          elementType:
+            scalar: string
+          elementRelationship: atomic
+    - name: env
+      type:
+        list:
+          elementType:
+            namedType: env
+          elementRelationship: associative
+          keys:
+          - name
+- name: env
+  map:
+    fields:
+    - name: name
+      type:
+        scalar: string
+    - name: value
+      type:
+        scalar: string
+`)
+	if err != nil {
+		panic(err)
+	}
+	return parser
+}()
+
+const redactObject = `
+data:
+  password: hunter2
+  token: abc
+containers:
+- name: app
+  image: nginx
+  args: ["--secret", "s3cr3t"]
+  env:
+  - name: KEY
+    value: v1
+  - name: OTHER
+    value: v2
+finalizers: ["a", "b"]
+replicas: 3
+`
+
+func TestRedact(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []string
+		expected typed.YAMLObject
+	}{{
+		name:     "none",
+		expected: redactObject,
+	}, {
+		name:     "map values",
+		patterns: []string{".data.*"},
+		expected: `
+data:
+  password: <redacted>
+  token: <redacted>
+containers:
+- name: app
+  image: nginx
+  args: ["--secret", "s3cr3t"]
+  env:
+  - name: KEY
+    value: v1
+  - name: OTHER
+    value: v2
+finalizers: ["a", "b"]
+replicas: 3
+`,
+	}, {
+		name:     "keys are kept",
+		patterns: []string{".containers.**", ".replicas"},
+		expected: `
+data:
+  password: hunter2
+  token: abc
+containers:
+- name: app
+  image: <redacted>
+  args: [<redacted>, <redacted>]
+  env:
+  - name: KEY
+    value: <redacted>
+  - name: OTHER
+    value: <redacted>
+finalizers: ["a", "b"]
+replicas: <redacted>
+`,
+	}, {
+		name:     "matched by key",
+		patterns: []string{`.containers[name="app"].env[name="KEY"]`, ".finalizers"},
+		expected: `
+data:
+  password: hunter2
+  token: abc
+containers:
+- name: app
+  image: nginx
+  args: ["--secret", "s3cr3t"]
+  env:
+  - name: KEY
+    value: <redacted>
+  - name: OTHER
+    value: v2
+finalizers: ["<redacted:0>", "<redacted:1>"]
+replicas: 3
+`,
+	}, {
+		name:     "set items",
+		patterns: []string{`.finalizers[="b"]`},
+		expected: `
+data:
+  password: hunter2
+  token: abc
+containers:
+- name: app
+  image: nginx
+  args: ["--secret", "s3cr3t"]
+  env:
+  - name: KEY
+    value: v1
+  - name: OTHER
+    value: v2
+finalizers: ["a", "<redacted:1>"]
+replicas: 3
+`,
+	}, {
+		name:     "key fields",
+		patterns: []string{".containers[*].name", ".containers[*].args[1]"},
+		expected: `
+data:
+  password: hunter2
+  token: abc
+containers:
+- name: app
+  image: nginx
+  args: ["--secret", <redacted>]
+  env:
+  - name: KEY
+    value: v1
+  - name: OTHER
+    value: v2
+finalizers: ["a", "b"]
+replicas: 3
+`,
+	}}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			tv, err := redactParser.Type("pod").FromYAML(redactObject)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var patterns []*fieldpath.Pattern
+			for _, p := range tt.patterns {
+				patterns = append(patterns, fieldpath.ParsePatternOrDie(p))
+			}
+			got, err := tv.Redact(patterns...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Redacted numbers aren't valid for the schema.
+			expected, err := typed.DeducedParseableType.FromYAML(tt.expected)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !value.Equals(got.AsValue(), expected.AsValue()) {
+				gotYAML, _ := got.ToYAML()
+				t.Errorf("expected:\n%v\ngot:\n%s", tt.expected, gotYAML)
+			}
+			// tv is left as it was.
+			if yaml, _ := tv.ToYAML(); strings.Contains(string(yaml), "redacted") {
+				t.Errorf("expected tv not to be modified, got:\n%s", yaml)
+			}
+		})
+	}
+}
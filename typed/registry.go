@@ -0,0 +1,214 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package typed
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// GroupVersionKind identifies the type of an object in a SchemaRegistry.
+type GroupVersionKind struct {
+	Group   string
+	Version string
+	Kind    string
+}
+
+// String returns the GroupVersionKind as "group/version, Kind=kind", like
+// Kubernetes does.
+func (gvk GroupVersionKind) String() string {
+	return fmt.Sprintf("%s/%s, Kind=%s", gvk.Group, gvk.Version, gvk.Kind)
+}
+
+// SchemaRegistry maps GroupVersionKinds to the types of a schema.
+//
+// Lookups don't take any lock: they read an immutable snapshot which writers
+// replace atomically, so that a lookup never sees a partially registered
+// custom resource. Parsers are cached by schema, so kinds and versions which
+// share a schema share a Parser, and a Parser is dropped as soon as no kind
+// uses it anymore. Schemas are compiled, see CompileSchema.
+//
+// A SchemaRegistry must be created with NewSchemaRegistry and is safe for
+// concurrent use.
+type SchemaRegistry struct {
+	opts []ParserOption
+
+	// mu serializes writers; readers only load current.
+	mu      sync.Mutex
+	current atomic.Value // registrySnapshot
+	parsers map[YAMLObject]*cachedParser
+}
+
+type registryEntry struct {
+	schema   YAMLObject
+	typeName string
+	parser   *Parser
+}
+
+type registrySnapshot map[GroupVersionKind]registryEntry
+
+type cachedParser struct {
+	parser *Parser
+	refs   int
+}
+
+// NewSchemaRegistry returns an empty SchemaRegistry. opts are used to build
+// every Parser.
+func NewSchemaRegistry(opts ...ParserOption) *SchemaRegistry {
+	r := &SchemaRegistry{
+		opts:    opts,
+		parsers: map[YAMLObject]*cachedParser{},
+	}
+	r.current.Store(registrySnapshot{})
+	return r
+}
+
+func (r *SchemaRegistry) snapshot() registrySnapshot {
+	return r.current.Load().(registrySnapshot)
+}
+
+// Register maps gvk to the type named typeName in schemaYAML, replacing any
+// previous registration of gvk.
+func (r *SchemaRegistry) Register(gvk GroupVersionKind, schemaYAML YAMLObject, typeName string) error {
+	return r.RegisterKinds(schemaYAML, map[GroupVersionKind]string{gvk: typeName})
+}
+
+// RegisterKinds maps each GroupVersionKind of kinds to the named type of
+// schemaYAML, replacing their previous registrations all at once. This is
+// meant for custom resource definitions, all versions of which should be
+// updated together. Nothing is registered if the schema is invalid or misses
+// any of the types.
+func (r *SchemaRegistry) RegisterKinds(schemaYAML YAMLObject, kinds map[GroupVersionKind]string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	p, err := r.parser(schemaYAML)
+	if err != nil {
+		return err
+	}
+	for gvk, typeName := range kinds {
+		if _, ok := p.Schema.FindNamedType(typeName); !ok {
+			return fmt.Errorf("%v: no type named %q in schema", gvk, typeName)
+		}
+	}
+	old := r.snapshot()
+	next := make(registrySnapshot, len(old)+len(kinds))
+	for gvk, e := range old {
+		next[gvk] = e
+	}
+	for gvk, typeName := range kinds {
+		if e, ok := old[gvk]; ok {
+			r.release(e.schema)
+		}
+		r.acquire(schemaYAML, p)
+		next[gvk] = registryEntry{schema: schemaYAML, typeName: typeName, parser: p}
+	}
+	r.current.Store(next)
+	return nil
+}
+
+// Unregister removes the given kinds from the registry. It reports whether any
+// of them was registered.
+func (r *SchemaRegistry) Unregister(gvks ...GroupVersionKind) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	old := r.snapshot()
+	next := make(registrySnapshot, len(old))
+	for gvk, e := range old {
+		next[gvk] = e
+	}
+	found := false
+	for _, gvk := range gvks {
+		if e, ok := next[gvk]; ok {
+			r.release(e.schema)
+			delete(next, gvk)
+			found = true
+		}
+	}
+	if found {
+		r.current.Store(next)
+	}
+	return found
+}
+
+// Type returns the type registered for gvk.
+func (r *SchemaRegistry) Type(gvk GroupVersionKind) (ParseableType, bool) {
+	e, ok := r.snapshot()[gvk]
+	if !ok {
+		return ParseableType{}, false
+	}
+	return e.parser.Type(e.typeName), true
+}
+
+// Parser returns the Parser of the schema registered for gvk.
+func (r *SchemaRegistry) Parser(gvk GroupVersionKind) (*Parser, bool) {
+	e, ok := r.snapshot()[gvk]
+	return e.parser, ok
+}
+
+// Kinds returns the registered GroupVersionKinds, sorted.
+func (r *SchemaRegistry) Kinds() []GroupVersionKind {
+	s := r.snapshot()
+	kinds := make([]GroupVersionKind, 0, len(s))
+	for gvk := range s {
+		kinds = append(kinds, gvk)
+	}
+	sort.Slice(kinds, func(i, j int) bool {
+		a, b := kinds[i], kinds[j]
+		if a.Group != b.Group {
+			return a.Group < b.Group
+		}
+		if a.Version != b.Version {
+			return a.Version < b.Version
+		}
+		return a.Kind < b.Kind
+	})
+	return kinds
+}
+
+// parser returns the cached Parser of schemaYAML, building it if needed.
+// The parser isn't cached until it's acquired. r.mu must be held.
+func (r *SchemaRegistry) parser(schemaYAML YAMLObject) (*Parser, error) {
+	if c, ok := r.parsers[schemaYAML]; ok {
+		return c.parser, nil
+	}
+	c, err := CompileSchema(schemaYAML, r.opts...)
+	if err != nil {
+		return nil, err
+	}
+	return c.Parser(), nil
+}
+
+func (r *SchemaRegistry) acquire(schemaYAML YAMLObject, p *Parser) {
+	c, ok := r.parsers[schemaYAML]
+	if !ok {
+		c = &cachedParser{parser: p}
+		r.parsers[schemaYAML] = c
+	}
+	c.refs++
+}
+
+func (r *SchemaRegistry) release(schemaYAML YAMLObject) {
+	c := r.parsers[schemaYAML]
+	c.refs--
+	if c.refs == 0 {
+		delete(r.parsers, schemaYAML)
+	}
+}
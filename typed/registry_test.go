@@ -0,0 +1,137 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package typed_test
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+
+	"sigs.k8s.io/structured-merge-diff/v4/typed"
+)
+
+const registrySchemaV1 = typed.YAMLObject(`types:
+- name: widget
+  map:
+    fields:
+    - name: size
+      type:
+        scalar: numeric
+`)
+
+const registrySchemaV2 = typed.YAMLObject(`types:
+- name: widget
+  map:
+    fields:
+    - name: size
+      type:
+        scalar: string
+`)
+
+func TestSchemaRegistry(t *testing.T) {
+	v1 := typed.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Widget"}
+	v1beta1 := typed.GroupVersionKind{Group: "example.com", Version: "v1beta1", Kind: "Widget"}
+	r := typed.NewSchemaRegistry()
+
+	if err := r.RegisterKinds(registrySchemaV1, map[typed.GroupVersionKind]string{v1: "widget", v1beta1: "widget"}); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(r.Kinds(), []typed.GroupVersionKind{v1, v1beta1}) {
+		t.Errorf("unexpected kinds: %v", r.Kinds())
+	}
+	p1, _ := r.Parser(v1)
+	p2, _ := r.Parser(v1beta1)
+	if p1 != p2 {
+		t.Errorf("expected kinds sharing a schema to share a parser")
+	}
+	tv, ok := r.Type(v1)
+	if !ok {
+		t.Fatalf("expected %v to be registered", v1)
+	}
+	if _, err := tv.FromYAML(`{"size": 1}`); err != nil {
+		t.Errorf("expected a valid object: %v", err)
+	}
+
+	// A failed registration doesn't change anything.
+	if err := r.Register(v1, registrySchemaV2, "missing"); err == nil {
+		t.Errorf("expected an error for a missing type")
+	}
+	if err := r.Register(v1, "types: 1", "widget"); err == nil {
+		t.Errorf("expected an error for an invalid schema")
+	}
+	if p, _ := r.Parser(v1); p != p1 {
+		t.Errorf("expected a failed registration to keep the previous schema")
+	}
+
+	// Hot-swapping the schema of both versions.
+	if err := r.RegisterKinds(registrySchemaV2, map[typed.GroupVersionKind]string{v1: "widget", v1beta1: "widget"}); err != nil {
+		t.Fatal(err)
+	}
+	tv, _ = r.Type(v1beta1)
+	if _, err := tv.FromYAML(`{"size": 1}`); err == nil {
+		t.Errorf("expected the new schema to be used")
+	}
+
+	// Parsers are dropped once unused, and rebuilt afterwards.
+	if !r.Unregister(v1, v1beta1) {
+		t.Errorf("expected kinds to be unregistered")
+	}
+	if r.Unregister(v1) {
+		t.Errorf("expected %v to be unregistered already", v1)
+	}
+	if _, ok := r.Type(v1); ok {
+		t.Errorf("expected %v to be unregistered", v1)
+	}
+	if err := r.Register(v1, registrySchemaV1, "widget"); err != nil {
+		t.Fatal(err)
+	}
+	if p, _ := r.Parser(v1); p == p1 {
+		t.Errorf("expected the unused parser to have been dropped")
+	}
+}
+
+func TestSchemaRegistryConcurrentSwap(t *testing.T) {
+	gvk := typed.GroupVersionKind{Version: "v1", Kind: "Widget"}
+	r := typed.NewSchemaRegistry()
+	if err := r.Register(gvk, registrySchemaV1, "widget"); err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				if _, ok := r.Type(gvk); !ok {
+					t.Errorf("expected %v to stay registered", gvk)
+					return
+				}
+			}
+		}()
+		go func(i int) {
+			defer wg.Done()
+			schemas := []typed.YAMLObject{registrySchemaV1, registrySchemaV2}
+			for j := 0; j < 10; j++ {
+				if err := r.Register(gvk, schemas[(i+j)%2], "widget"); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
@@ -71,24 +71,26 @@ func (w *removingWalker) doList(t *schema.List) (errs ValidationErrors) {
 	}
 
 	var newItems []interface{}
+	folders := keyFolders(t)
 	iter := l.RangeUsing(w.allocator)
 	defer w.allocator.Free(iter)
 	for iter.Next() {
 		_, item := iter.Item()
 		// Ignore error because we have already validated this list
-		pe, _ := listItemToPathElement(w.allocator, w.schema, t, item)
+		pe, _ := listItemToPathElement(w.allocator, w.schema, t, folders, item)
 		path, _ := fieldpath.MakePath(pe)
+		elementType := listElementType(w.allocator, t, item)
 		// save items on the path when we shouldExtract
 		// but ignore them when we are removing (i.e. !w.shouldExtract)
 		if w.toRemove.Has(path) {
 			if w.shouldExtract {
-				newItems = append(newItems, removeItemsWithSchema(item, w.toRemove, w.schema, t.ElementType, w.shouldExtract).Unstructured())
+				newItems = append(newItems, removeItemsWithSchema(item, w.toRemove, w.schema, elementType, w.shouldExtract).Unstructured())
 			} else {
 				continue
 			}
 		}
 		if subset := w.toRemove.WithPrefix(pe); !subset.Empty() {
-			item = removeItemsWithSchema(item, subset, w.schema, t.ElementType, w.shouldExtract)
+			item = removeItemsWithSchema(item, subset, w.schema, elementType, w.shouldExtract)
 		} else {
 			// don't save items not on the path when we shouldExtract.
 			if w.shouldExtract {
@@ -116,12 +118,12 @@ func (w *removingWalker) doMap(t *schema.Map) ValidationErrors {
 		return nil
 	}
 
-	// atomic maps should return everything in the case of extract
-	// and nothing in the case of remove (!w.shouldExtract)
+	// atomic maps are owned as a whole: the paths of their items don't
+	// address anything, so the map is returned whole in both the case of
+	// extract and remove (!w.shouldExtract). Removing the map itself is
+	// handled by its parent.
 	if t.ElementRelationship == schema.Atomic {
-		if w.shouldExtract {
-			w.out = w.value.Unstructured()
-		}
+		w.out = w.value.Unstructured()
 		return nil
 	}
 
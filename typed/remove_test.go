@@ -328,6 +328,12 @@ var removeCases = []removeTestCase{{
 		``,
 		// atomic maps should still return everything in the map
 		`{"atomicMap":{"a": "c", "b": "d"}}`,
+	}, {
+		`{"atomicMap":{"a": "c", "b": "d"}}`,
+		_NS(_P("atomicMap", "a")),
+		// the items of atomic maps can't be removed on their own
+		`{"atomicMap":{"a": "c", "b": "d"}}`,
+		`{"atomicMap":{"a": "c", "b": "d"}}`,
 	}},
 }, {
 	name:         "nested types",
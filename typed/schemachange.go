@@ -0,0 +1,219 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package typed
+
+import (
+	"fmt"
+
+	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
+	"sigs.k8s.io/structured-merge-diff/v4/schema"
+	"sigs.k8s.io/structured-merge-diff/v4/value"
+)
+
+// SchemaChange is how changing the schema of an object affects it, see
+// CompareSchemas.
+type SchemaChange struct {
+	// Invalidated are the errors of the object with the new schema that it
+	// didn't have with the old one.
+	Invalidated ValidationErrors
+	// Validated are the errors of the object with the old schema that it
+	// doesn't have anymore with the new one.
+	Validated ValidationErrors
+	// Relationships are the lists and maps of the object which are merged,
+	// and so owned, differently with the new schema.
+	Relationships []RelationshipChange
+}
+
+// Empty returns true if the object is the same with both schemas.
+func (c *SchemaChange) Empty() bool {
+	return len(c.Invalidated) == 0 && len(c.Validated) == 0 && len(c.Relationships) == 0
+}
+
+// RelationshipChange is a list or a map whose element relationship is
+// changed by a schema change. Unset relationships of maps are separable.
+type RelationshipChange struct {
+	Path     fieldpath.Path
+	Old, New schema.ElementRelationship
+}
+
+// String formats the change, e.g. ".spec.ports: atomic -> associative".
+func (c RelationshipChange) String() string {
+	return fmt.Sprintf("%v: %v -> %v", c.Path, c.Old, c.New)
+}
+
+// CompareSchemas reports how v, an object of oldType, is affected when its
+// schema becomes newType, e.g. when a CustomResourceDefinition is updated:
+// which of its fields become valid or invalid, and which of its lists and
+// maps change relationship. The children of atomic lists and maps, which
+// aren't owned one by one, are never reported. Use ReconcileManagedFields
+// to update the ownership recorded with the old schema.
+func CompareSchemas(v value.Value, oldType, newType ParseableType) (*SchemaChange, error) {
+	if !oldType.IsValid() {
+		return nil, fmt.Errorf("invalid old type %v", oldType.TypeRef)
+	}
+	if !newType.IsValid() {
+		return nil, fmt.Errorf("invalid new type %v", newType.TypeRef)
+	}
+	c := &SchemaChange{}
+	oldErrs := validationErrors(AsTypedUnvalidated(v, oldType.Schema, oldType.TypeRef).Validate())
+	newErrs := validationErrors(AsTypedUnvalidated(v, newType.Schema, newType.TypeRef).Validate())
+	c.Invalidated = errorsDifference(newErrs, oldErrs)
+	c.Validated = errorsDifference(oldErrs, newErrs)
+	w := relationshipWalker{old: oldType.Schema, new: newType.Schema}
+	w.walk(v, oldType.TypeRef, newType.TypeRef, fieldpath.Path{})
+	c.Relationships = w.changes
+	return c, nil
+}
+
+// validationErrors returns the errors of err, which is returned by
+// Validate.
+func validationErrors(err error) ValidationErrors {
+	if err == nil {
+		return nil
+	}
+	if errs, ok := err.(ValidationErrors); ok {
+		return errs
+	}
+	return errorf("%v", err)
+}
+
+// errorsDifference returns the errors of lhs which aren't in rhs.
+func errorsDifference(lhs, rhs ValidationErrors) ValidationErrors {
+	seen := make(map[string]bool, len(rhs))
+	for _, err := range rhs {
+		seen[err.Error()] = true
+	}
+	var out ValidationErrors
+	for _, err := range lhs {
+		if !seen[err.Error()] {
+			out = append(out, err)
+		}
+	}
+	return out
+}
+
+// relationshipWalker walks an object with two schemas, and records the
+// lists and maps whose relationship differ.
+type relationshipWalker struct {
+	old, new *schema.Schema
+	changes  []RelationshipChange
+}
+
+func (w *relationshipWalker) walk(v value.Value, oldRef, newRef schema.TypeRef, path fieldpath.Path) {
+	if v == nil || v.IsNull() {
+		return
+	}
+	oldAtom, ok := w.old.Resolve(oldRef)
+	if !ok {
+		return
+	}
+	newAtom, ok := w.new.Resolve(newRef)
+	if !ok {
+		return
+	}
+	oldAtom, newAtom = deduceAtom(oldAtom, v), deduceAtom(newAtom, v)
+	switch {
+	case v.IsMap() && oldAtom.Map != nil && newAtom.Map != nil:
+		oldRel, newRel := mapRelationship(oldAtom.Map), mapRelationship(newAtom.Map)
+		if oldRel != newRel {
+			w.changes = append(w.changes, RelationshipChange{Path: path.Copy(), Old: oldRel, New: newRel})
+		}
+		if oldRel == schema.Atomic || newRel == schema.Atomic {
+			return
+		}
+		v.AsMap().Iterate(func(key string, child value.Value) bool {
+			oldChild, ok1 := fieldTypeRef(oldAtom.Map, key)
+			newChild, ok2 := fieldTypeRef(newAtom.Map, key)
+			if ok1 && ok2 {
+				w.walk(child, oldChild, newChild, append(path, fieldpath.PathElement{FieldName: &key}))
+			}
+			return true
+		})
+	case v.IsList() && oldAtom.List != nil && newAtom.List != nil:
+		oldRel, newRel := oldAtom.List.ElementRelationship, newAtom.List.ElementRelationship
+		if oldRel != newRel {
+			w.changes = append(w.changes, RelationshipChange{Path: path.Copy(), Old: oldRel, New: newRel})
+		}
+		if oldRel != schema.Associative || newRel != schema.Associative {
+			return
+		}
+		l := v.AsList()
+		folders := keyFolders(newAtom.List)
+		for i := 0; i < l.Length(); i++ {
+			child := l.At(i)
+			pe, err := listItemToPathElement(value.NewFreelistAllocator(), w.new, newAtom.List, folders, child)
+			if err != nil {
+				continue
+			}
+			w.walk(child, oldAtom.List.ElementType, newAtom.List.ElementType, append(path, pe))
+		}
+	}
+}
+
+func mapRelationship(m *schema.Map) schema.ElementRelationship {
+	if m.ElementRelationship == "" {
+		return schema.Separable
+	}
+	return m.ElementRelationship
+}
+
+// fieldTypeRef returns the type of the field of m.
+func fieldTypeRef(m *schema.Map, name string) (schema.TypeRef, bool) {
+	if sf, ok := m.FindField(name); ok {
+		return sf.Type, true
+	}
+	return m.ElementType, m.ElementType != schema.TypeRef{}
+}
+
+// ReconcileManagedFields updates the sets of the managers of version,
+// recorded with the old schema, for the relationships changed by c. object
+// is the object with the new schema, and the managers of other versions
+// are left as they are:
+//   - the managers of some children of a list or map that became atomic
+//     own the list or map instead (see CollapseToParent);
+//   - the managers of a list or map that stopped being atomic own its
+//     current leaves instead (see ExpandToLeaves).
+//
+// The managers are modified and returned.
+func (c *SchemaChange) ReconcileManagedFields(managers fieldpath.ManagedFields, version fieldpath.APIVersion, object *TypedValue) (fieldpath.ManagedFields, error) {
+	if len(c.Relationships) == 0 {
+		return managers, nil
+	}
+	var leaves *fieldpath.Set
+	for name, vs := range managers {
+		if vs.APIVersion() != version {
+			continue
+		}
+		set := vs.Set()
+		for _, change := range c.Relationships {
+			switch {
+			case change.New == schema.Atomic:
+				set = CollapseOwnership(set, change.Path)
+			case change.Old == schema.Atomic:
+				if leaves == nil {
+					var err error
+					if leaves, err = object.ToFieldSet(); err != nil {
+						return nil, fmt.Errorf("failed to get the fields of the object: %v", err)
+					}
+				}
+				set = expandOwnership(set, change.Path, leaves)
+			}
+		}
+		managers[name] = fieldpath.WithSet(vs, set, version)
+	}
+	return managers, nil
+}
@@ -0,0 +1,176 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package typed_test
+
+import (
+	"testing"
+
+	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
+	"sigs.k8s.io/structured-merge-diff/v4/schema"
+	"sigs.k8s.io/structured-merge-diff/v4/typed"
+)
+
+func TestCompareSchemas(t *testing.T) {
+	oldParser, err := typed.NewParser(`types:
+- name: object
+  map:
+    fields:
+    - name: replicas
+      type:
+        scalar: numeric
+    - name: labels
+      type:
+        map:
+          elementType:
+            scalar: string
+          elementRelationship: atomic
+    - name: selector
+      type:
+        map:
+          elementType:
+            scalar: string
+    - name: ports
+      type:
+        list:
+          elementType:
+            namedType: port
+          elementRelationship: atomic
+- name: port
+  map:
+    fields:
+    - name: name
+      type:
+        scalar: string
+    - name: port
+      type:
+        scalar: numeric
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newParser, err := typed.NewParser(`types:
+- name: object
+  map:
+    fields:
+    - name: replicas
+      type:
+        scalar: string
+    - name: labels
+      type:
+        map:
+          elementType:
+            scalar: string
+    - name: selector
+      type:
+        map:
+          elementType:
+            scalar: string
+          elementRelationship: atomic
+    - name: ports
+      type:
+        list:
+          elementType:
+            namedType: port
+          elementRelationship: associative
+          keys: [name]
+- name: port
+  map:
+    fields:
+    - name: name
+      type:
+        scalar: string
+    - name: port
+      type:
+        scalar: numeric
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldType, newType := oldParser.Type("object"), newParser.Type("object")
+	obj, err := oldType.FromYAML(`
+replicas: 1
+labels: {app: web, team: x}
+selector: {app: web}
+ports: [{name: http, port: 80}]
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	change, err := typed.CompareSchemas(obj.AsValue(), oldType, newType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(change.Invalidated) != 1 || change.Invalidated[0].Path != ".replicas" {
+		t.Errorf("expected .replicas to be invalidated, got %v", change.Invalidated)
+	}
+	if len(change.Validated) != 0 {
+		t.Errorf("expected no validated field, got %v", change.Validated)
+	}
+	want := []typed.RelationshipChange{
+		{Path: _P("labels"), Old: schema.Atomic, New: schema.Separable},
+		{Path: _P("ports"), Old: schema.Atomic, New: schema.Associative},
+		{Path: _P("selector"), Old: schema.Separable, New: schema.Atomic},
+	}
+	got := map[string]typed.RelationshipChange{}
+	for _, c := range change.Relationships {
+		got[c.Path.String()] = c
+	}
+	if len(got) != len(want) {
+		t.Fatalf("expected relationship changes %v, got %v", want, change.Relationships)
+	}
+	for _, w := range want {
+		if c, ok := got[w.Path.String()]; !ok || c.Old != w.Old || c.New != w.New {
+			t.Errorf("expected %v, got %v", w, c)
+		}
+	}
+
+	newObj, err := newType.FromYAML(`
+replicas: "1"
+labels: {app: web, team: x}
+selector: {app: web}
+ports: [{name: http, port: 80}]
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	managers := fieldpath.ManagedFields{
+		"a": fieldpath.NewVersionedSet(_NS(_P("labels"), _P("selector", "app")), "v1", true),
+		"b": fieldpath.NewVersionedSet(_NS(_P("ports")), "v1", false),
+		"c": fieldpath.NewVersionedSet(_NS(_P("labels")), "v2", false),
+	}
+	managers, err = change.ReconcileManagedFields(managers, "v1", newObj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantManagers := fieldpath.ManagedFields{
+		"a": fieldpath.NewVersionedSet(_NS(
+			_P("labels", "app"),
+			_P("labels", "team"),
+			_P("selector"),
+		), "v1", true),
+		"b": fieldpath.NewVersionedSet(_NS(
+			_P("ports", _KBF("name", "http")),
+			_P("ports", _KBF("name", "http"), "name"),
+			_P("ports", _KBF("name", "http"), "port"),
+		), "v1", false),
+		"c": fieldpath.NewVersionedSet(_NS(_P("labels")), "v2", false),
+	}
+	if !managers.Equals(wantManagers) {
+		t.Errorf("expected managers:\n%v\ngot:\n%v", wantManagers, managers)
+	}
+}
@@ -0,0 +1,104 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package typed
+
+import (
+	"sigs.k8s.io/structured-merge-diff/v4/value"
+)
+
+// ValueStats describes the size of a value, see TypedValue.Stats.
+type ValueStats struct {
+	// Nodes is the number of maps, lists, scalars and nulls of the value,
+	// including the value itself.
+	Nodes int
+	// Depth is the maximum nesting of maps and lists, e.g. 0 for a scalar
+	// and 1 for a map of scalars.
+	Depth int
+	// Maps and Lists are the number of non-empty maps and lists.
+	Maps  int
+	Lists int
+	// Leaves counts the leaves of the value by kind.
+	Leaves LeafCounts
+	// EstimatedBytes is a rough estimate of the memory used by the value
+	// once unstructured, see value.EstimateSize.
+	EstimatedBytes int
+}
+
+// LeafCounts counts the leaves of a value by kind. Empty maps and lists are
+// leaves too.
+type LeafCounts struct {
+	Strings          int
+	Ints             int
+	Floats           int
+	Bools            int
+	Nulls            int
+	EmptyCollections int
+}
+
+// Total returns the total number of leaves.
+func (c LeafCounts) Total() int {
+	return c.Strings + c.Ints + c.Floats + c.Bools + c.Nulls + c.EmptyCollections
+}
+
+// Stats returns the size of tv, so that callers can enforce quotas on
+// objects or model their memory usage.
+func (tv *TypedValue) Stats() ValueStats {
+	var stats ValueStats
+	stats.add(tv.value, 0)
+	stats.EstimatedBytes = value.EstimateSize(tv.value)
+	return stats
+}
+
+func (s *ValueStats) add(v value.Value, depth int) {
+	s.Nodes++
+	if depth > s.Depth {
+		s.Depth = depth
+	}
+	switch {
+	case v.IsMap():
+		m := v.AsMap()
+		if m.Empty() {
+			s.Leaves.EmptyCollections++
+			return
+		}
+		s.Maps++
+		m.Iterate(func(_ string, child value.Value) bool {
+			s.add(child, depth+1)
+			return true
+		})
+	case v.IsList():
+		l := v.AsList()
+		if l.Length() == 0 {
+			s.Leaves.EmptyCollections++
+			return
+		}
+		s.Lists++
+		for i := 0; i < l.Length(); i++ {
+			s.add(l.At(i), depth+1)
+		}
+	case v.IsString():
+		s.Leaves.Strings++
+	case v.IsInt():
+		s.Leaves.Ints++
+	case v.IsFloat():
+		s.Leaves.Floats++
+	case v.IsBool():
+		s.Leaves.Bools++
+	default:
+		s.Leaves.Nulls++
+	}
+}
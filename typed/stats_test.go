@@ -0,0 +1,85 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package typed_test
+
+import (
+	"testing"
+
+	"sigs.k8s.io/structured-merge-diff/v4/typed"
+)
+
+func TestValueStats(t *testing.T) {
+	tests := []struct {
+		name   string
+		object typed.YAMLObject
+		stats  typed.ValueStats
+	}{{
+		name:   "scalar",
+		object: `"abc"`,
+		stats: typed.ValueStats{
+			Nodes:          1,
+			Leaves:         typed.LeafCounts{Strings: 1},
+			EstimatedBytes: 19,
+		},
+	}, {
+		name:   "map",
+		object: `{"a": "xy"}`,
+		stats: typed.ValueStats{
+			Nodes:          2,
+			Depth:          1,
+			Maps:           1,
+			Leaves:         typed.LeafCounts{Strings: 1},
+			EstimatedBytes: 107,
+		},
+	}, {
+		name:   "every kind",
+		object: `{"s": "x", "i": 1, "f": 1.5, "b": true, "n": null, "l": [{}, []], "m": {"l": [1, [2]]}}`,
+		stats: typed.ValueStats{
+			Nodes: 14,
+			Depth: 4,
+			Maps:  2,
+			Lists: 3,
+			Leaves: typed.LeafCounts{
+				Strings:          1,
+				Ints:             3,
+				Floats:           1,
+				Bools:            1,
+				Nulls:            1,
+				EmptyCollections: 2,
+			},
+		},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tv, err := typed.DeducedParseableType.FromYAML(test.object)
+			if err != nil {
+				t.Fatalf("failed to parse object: %v", err)
+			}
+			got := tv.Stats()
+			if test.stats.EstimatedBytes == 0 {
+				got.EstimatedBytes = 0
+			}
+			if got != test.stats {
+				t.Errorf("expected stats %+v, got %+v", test.stats, got)
+			}
+			if n := got.Leaves.Total() + got.Maps + got.Lists; n != got.Nodes {
+				t.Errorf("expected leaves, maps and lists to add up to %v nodes, got %v", got.Nodes, n)
+			}
+		})
+	}
+}
@@ -24,16 +24,32 @@ import (
 	"sigs.k8s.io/structured-merge-diff/v4/value"
 )
 
+// WithFieldSetPatterns configures ToFieldSet to only return the paths which
+// one of the patterns matches. The parts of the value which none can match
+// aren't walked, so that it is cheaper than filtering the whole set, e.g.
+// to find the images of the containers of a pod with
+// .spec.containers[*].image.
+func WithFieldSetPatterns(patterns ...*fieldpath.Pattern) ToFieldSetOption {
+	return func(opts *toFieldSetOptions) {
+		opts.patterns = append(opts.patterns, patterns...)
+	}
+}
+
 var tPool = sync.Pool{
 	New: func() interface{} { return &toFieldSetWalker{} },
 }
 
-func (tv TypedValue) toFieldSetWalker() *toFieldSetWalker {
+func (tv *TypedValue) toFieldSetWalker() *toFieldSetWalker {
 	v := tPool.Get().(*toFieldSetWalker)
 	v.value = tv.value
 	v.schema = tv.schema
 	v.typeRef = tv.typeRef
+	v.index = tv.index
 	v.set = &fieldpath.Set{}
+	v.patterns = nil
+	v.filtered = false
+	v.lazy = tv.deferred()
+	v.descended = false
 	v.allocator = value.NewFreelistAllocator()
 	return v
 }
@@ -43,6 +59,9 @@ func (v *toFieldSetWalker) finished() {
 	v.typeRef = schema.TypeRef{}
 	v.path = nil
 	v.set = nil
+	v.index = nil
+	v.patterns = nil
+	v.lazy = nil
 	tPool.Put(v)
 }
 
@@ -54,6 +73,21 @@ type toFieldSetWalker struct {
 	set  *fieldpath.Set
 	path fieldpath.Path
 
+	// If set, holds the keys of the items of the lists.
+	index *ListIndex
+
+	// If filtered, only the paths which patterns match are in the set,
+	// and the walk stops where they have all failed.
+	filtered bool
+	patterns fieldpath.PatternStates
+
+	// If set, the deferred validation of value, which is validated as it
+	// is walked, see AsTypedLazy.
+	lazy *lazyValidation
+
+	// internal housekeeping--don't set when constructing.
+	descended bool // Set to true if we've descended into the items
+
 	// Allocate only as many walkers as needed for the depth by storing them here.
 	spareWalkers *[]*toFieldSetWalker
 	allocator    value.Allocator
@@ -73,9 +107,25 @@ func (v *toFieldSetWalker) prepareDescent(pe fieldpath.PathElement, tr schema.Ty
 	*v2 = *v
 	v2.typeRef = tr
 	v2.path = append(v2.path, pe)
+	v2.descended = false
+	if v.filtered {
+		v2.patterns = v.patterns.Next(pe)
+	}
 	return v2
 }
 
+// insert adds the path to the set, unless the patterns don't match it.
+func (v *toFieldSetWalker) insert() {
+	if !v.filtered || v.patterns.Matched() {
+		v.set.Insert(v.path)
+	}
+}
+
+// pruned returns true if no pattern can match the paths of the walk.
+func (v *toFieldSetWalker) pruned() bool {
+	return v.filtered && v.patterns.Failed()
+}
+
 func (v *toFieldSetWalker) finishDescent(v2 *toFieldSetWalker) {
 	// if the descent caused a realloc, ensure that we reuse the buffer
 	// for the next sibling.
@@ -83,12 +133,17 @@ func (v *toFieldSetWalker) finishDescent(v2 *toFieldSetWalker) {
 	*v.spareWalkers = append(*v.spareWalkers, v2)
 }
 
-func (v *toFieldSetWalker) toFieldSet() ValidationErrors {
-	return resolveSchema(v.schema, v.typeRef, v.value, v)
+func (v *toFieldSetWalker) toFieldSet(prefixFn func() string) ValidationErrors {
+	if errs := v.lazy.before(v.schema, v.typeRef, v.value); len(errs) > 0 {
+		return errs.WithLazyPrefix(prefixFn)
+	}
+	errs := resolveSchema(v.schema, v.typeRef, v.value, v)
+	errs = append(errs, v.lazy.after(v.schema, v.typeRef, v.value, v.descended)...)
+	return errs.WithLazyPrefix(prefixFn)
 }
 
 func (v *toFieldSetWalker) doScalar(t *schema.Scalar) ValidationErrors {
-	v.set.Insert(v.path)
+	v.insert()
 
 	return nil
 }
@@ -98,14 +153,17 @@ func (v *toFieldSetWalker) visitListItems(t *schema.List, list value.List) (errs
 	seen := fieldpath.MakePathElementSet(list.Length())
 	// Keeps tracks of the PEs we've counted as duplicates
 	duplicates := fieldpath.MakePathElementSet(list.Length())
+	keys := v.index.listItemKeys(v.allocator, v.schema, v.typeRef, t, v.value, list)
 	for i := 0; i < list.Length(); i++ {
 		child := list.At(i)
-		pe, _ := listItemToPathElement(v.allocator, v.schema, t, child)
+		pe, _ := keys.at(i, child)
 		if seen.Has(pe) {
 			if duplicates.Has(pe) {
 				// do nothing
 			} else {
-				v.set.Insert(append(v.path, pe))
+				if !v.filtered || v.patterns.Next(pe).Matched() {
+					v.set.Insert(append(v.path, pe))
+				}
 				duplicates.Insert(pe)
 			}
 		} else {
@@ -113,17 +171,22 @@ func (v *toFieldSetWalker) visitListItems(t *schema.List, list value.List) (errs
 		}
 	}
 
+	v.descended = true
 	for i := 0; i < list.Length(); i++ {
 		child := list.At(i)
-		pe, _ := listItemToPathElement(v.allocator, v.schema, t, child)
+		pe, _ := keys.at(i, child)
 		if duplicates.Has(pe) {
 			continue
 		}
-		v2 := v.prepareDescent(pe, t.ElementType)
+		v2 := v.prepareDescent(pe, listElementType(v.allocator, t, child))
+		if v2.pruned() {
+			v.finishDescent(v2)
+			continue
+		}
 		v2.value = child
-		errs = append(errs, v2.toFieldSet()...)
+		errs = append(errs, v2.toFieldSet(pe.String)...)
 
-		v2.set.Insert(v2.path)
+		v2.insert()
 		v.finishDescent(v2)
 	}
 	return errs
@@ -135,7 +198,7 @@ func (v *toFieldSetWalker) doList(t *schema.List) (errs ValidationErrors) {
 		defer v.allocator.Free(list)
 	}
 	if t.ElementRelationship == schema.Atomic {
-		v.set.Insert(v.path)
+		v.insert()
 		return nil
 	}
 
@@ -149,6 +212,7 @@ func (v *toFieldSetWalker) doList(t *schema.List) (errs ValidationErrors) {
 }
 
 func (v *toFieldSetWalker) visitMapItems(t *schema.Map, m value.Map) (errs ValidationErrors) {
+	v.descended = true
 	m.Iterate(func(key string, val value.Value) bool {
 		pe := fieldpath.PathElement{FieldName: &key}
 
@@ -157,12 +221,16 @@ func (v *toFieldSetWalker) visitMapItems(t *schema.Map, m value.Map) (errs Valid
 			tr = sf.Type
 		}
 		v2 := v.prepareDescent(pe, tr)
+		if v2.pruned() {
+			v.finishDescent(v2)
+			return true
+		}
 		v2.value = val
-		errs = append(errs, v2.toFieldSet()...)
+		errs = append(errs, v2.toFieldSet(pe.String)...)
 		if val.IsNull() || (val.IsMap() && val.AsMap().Length() == 0) {
-			v2.set.Insert(v2.path)
+			v2.insert()
 		} else if _, ok := t.FindField(key); !ok {
-			v2.set.Insert(v2.path)
+			v2.insert()
 		}
 		v.finishDescent(v2)
 		return true
@@ -176,7 +244,7 @@ func (v *toFieldSetWalker) doMap(t *schema.Map) (errs ValidationErrors) {
 		defer v.allocator.Free(m)
 	}
 	if t.ElementRelationship == schema.Atomic {
-		v.set.Insert(v.path)
+		v.insert()
 		return nil
 	}
 
@@ -17,7 +17,9 @@ limitations under the License.
 package typed_test
 
 import (
+	"bytes"
 	"fmt"
+	"strings"
 	"testing"
 
 	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
@@ -261,6 +263,37 @@ var fieldsetCases = []fieldsetTestCase{{
 		)},
 		{`{"atomicList":["a","a","a"]}`, _NS(_P("atomicList"))},
 	},
+}, {
+	name:         "set of atomic maps",
+	rootTypeName: "sets",
+	schema: `types:
+- name: sets
+  map:
+    fields:
+    - name: list
+      type:
+        list:
+          elementType:
+            namedType: item
+          elementRelationship: associative
+- name: item
+  map:
+    fields:
+    - name: name
+      type:
+        scalar: string
+    - name: value
+      type:
+        scalar: string
+    elementRelationship: atomic
+`,
+	pairs: []objSetPair{
+		{`{"list":[]}`, _NS()},
+		{`{"list":[{"name":"a"},{"name":"a","value":"b"}]}`, _NS(
+			_P("list", _V(map[string]interface{}{"name": "a"})),
+			_P("list", _V(map[string]interface{}{"name": "a", "value": "b"})),
+		)},
+	},
 }}
 
 func (tt fieldsetTestCase) test(t *testing.T) {
@@ -296,3 +329,170 @@ func TestToFieldSet(t *testing.T) {
 		})
 	}
 }
+
+func TestToFieldSetExoticKeys(t *testing.T) {
+	parser, err := typed.NewParser(`types:
+- name: root
+  map:
+    fields:
+    - name: keyed
+      type:
+        list:
+          elementType:
+            namedType: item
+          elementRelationship: associative
+          keys: [name]
+    - name: set
+      type:
+        list:
+          elementType:
+            scalar: string
+          elementRelationship: associative
+- name: item
+  map:
+    fields:
+    - name: name
+      type:
+        scalar: string
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	long := strings.Repeat("a", fieldpath.MaxPathElementValueLength+1)
+	obj, err := parser.Type("root").FromUnstructured(map[string]interface{}{
+		"keyed": []interface{}{
+			map[string]interface{}{"name": long},
+			map[string]interface{}{"name": "invalid \xff utf8"},
+		},
+		"set": []interface{}{long, "invalid \xff utf8", "foo"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	set, err := obj.ToFieldSet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if set.Size() != 7 {
+		t.Errorf("expected 7 fields, got:\n%v", set)
+	}
+	b, err := set.ToJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b) > 1024 {
+		t.Errorf("expected the long values to be hashed, got %s", b)
+	}
+	got := &fieldpath.Set{}
+	if err := got.FromJSON(bytes.NewReader(b)); err != nil {
+		t.Fatal(err)
+	}
+	if !got.Equals(set) {
+		t.Errorf("expected the set to round-trip, got:\n%v\nfrom:\n%v", got, set)
+	}
+	// The items can still be removed.
+	removed, err := obj.RemoveItems(set).ToFieldSet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !removed.Equals(_NS(_P("keyed"), _P("set"))) {
+		t.Errorf("expected the items to be removed, got:\n%v", removed)
+	}
+}
+
+func TestToFieldSetWithPatterns(t *testing.T) {
+	parser, err := typed.NewParser(`types:
+- name: pod
+  map:
+    fields:
+    - name: containers
+      type:
+        list:
+          elementType:
+            namedType: container
+          elementRelationship: associative
+          keys:
+          - name
+    - name: labels
+      type:
+        map:
+          elementType:
+            scalar: string
+- name: container
+  map:
+    fields:
+    - name: name
+      type:
+        scalar: string
+    - name: image
+      type:
+        scalar: string
+    - name: args
+      type:
+        list:
+          elementType:
+            scalar: string
+          elementRelationship: atomic
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tv, err := parser.Type("pod").FromYAML(`
+containers:
+- name: a
+  image: nginx
+  args: ["x"]
+- name: b
+  image: busybox
+labels:
+  app: web
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		patterns []string
+		expected *fieldpath.Set
+	}{{
+		patterns: []string{".containers[*].image"},
+		expected: _NS(
+			_P("containers", _KBF("name", "a"), "image"),
+			_P("containers", _KBF("name", "b"), "image"),
+		),
+	}, {
+		patterns: []string{`.containers[name="a"].**`, ".labels.*"},
+		expected: _NS(
+			_P("containers", _KBF("name", "a")),
+			_P("containers", _KBF("name", "a"), "name"),
+			_P("containers", _KBF("name", "a"), "image"),
+			_P("containers", _KBF("name", "a"), "args"),
+			_P("labels", "app"),
+		),
+	}, {
+		patterns: []string{".spec.**"},
+		expected: _NS(),
+	}}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(strings.Join(tt.patterns, ","), func(t *testing.T) {
+			var patterns []*fieldpath.Pattern
+			for _, p := range tt.patterns {
+				patterns = append(patterns, fieldpath.ParsePatternOrDie(p))
+			}
+			got, err := tv.ToFieldSet(typed.WithFieldSetPatterns(patterns...))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !got.Equals(tt.expected) {
+				t.Errorf("expected:\n%v\ngot:\n%v", tt.expected, got)
+			}
+			all, err := tv.ToFieldSet()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if filtered := all.FilterPatterns(patterns...); !filtered.Equals(got) {
+				t.Errorf("expected the same set as filtered, got:\n%v\nand:\n%v", got, filtered)
+			}
+		})
+	}
+}
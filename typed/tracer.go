@@ -0,0 +1,76 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package typed
+
+import (
+	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
+	"sigs.k8s.io/structured-merge-diff/v4/schema"
+)
+
+// MergeSide identifies which object a merged value comes from.
+type MergeSide string
+
+const (
+	// MergeSideLHS means the value was kept from the object being merged
+	// into.
+	MergeSideLHS MergeSide = "lhs"
+	// MergeSideRHS means the value was taken from the object being merged
+	// in, replacing the lhs value if there was one.
+	MergeSideRHS MergeSide = "rhs"
+	// MergeSideBoth means the items of the lhs and rhs lists or maps were
+	// merged one by one. Each item gets its own decision.
+	MergeSideBoth MergeSide = "both"
+)
+
+// MergeDecision records how a single field was merged.
+type MergeDecision struct {
+	// Path is the path of the field.
+	Path fieldpath.Path
+	// Side is where the resulting value comes from.
+	Side MergeSide
+	// Relationship is the element relationship of the list or map at Path,
+	// which decides whether it's replaced as a whole or merged item by
+	// item. It is empty for scalars.
+	Relationship schema.ElementRelationship
+}
+
+// MergeTracer is notified of every decision taken while merging two objects,
+// in the order in which the fields are visited. This is meant to debug why a
+// field ended up with a particular value.
+type MergeTracer interface {
+	TraceMerge(MergeDecision)
+}
+
+// MergeTracerFunc is a function that implements MergeTracer.
+type MergeTracerFunc func(MergeDecision)
+
+// TraceMerge calls f.
+func (f MergeTracerFunc) TraceMerge(d MergeDecision) {
+	f(d)
+}
+
+// trace reports a decision for the field being merged, if tracing.
+func (w *mergingWalker) trace(side MergeSide, relationship schema.ElementRelationship) {
+	if w.tracer == nil {
+		return
+	}
+	w.tracer.TraceMerge(MergeDecision{
+		Path:         w.path.Copy(),
+		Side:         side,
+		Relationship: relationship,
+	})
+}
@@ -17,6 +17,7 @@ limitations under the License.
 package typed
 
 import (
+	"sort"
 	"sync"
 
 	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
@@ -30,6 +31,20 @@ type ValidationOptions int
 const (
 	// AllowDuplicates means that sets and associative lists can have duplicate similar items.
 	AllowDuplicates ValidationOptions = iota
+	// RejectYAMLAliases means that FromYAML fails on documents with YAML
+	// anchors, aliases or merge keys (`<<`), rather than expanding them.
+	// Expansion always fails on aliases that reference themselves or that
+	// expand to too many nodes.
+	RejectYAMLAliases
+	// RejectDuplicateKeys means that FromYAML fails on documents that
+	// repeat a key in a map, rather than keeping the last value. See
+	// DuplicateYAMLKeys.
+	RejectDuplicateKeys
+	// StrictListKeys means that every item of an associative list must set
+	// all the key fields of the list, rather than relying on their default
+	// values, and that the keys must be unique even if AllowDuplicates is
+	// given. Every invalid item is reported, rather than only the first one.
+	StrictListKeys
 )
 
 // extractItemsOptions is the options available when extracting items.
@@ -47,6 +62,70 @@ func WithAppendKeyFields() ExtractItemsOption {
 	}
 }
 
+// mergeOptions is the options available when merging.
+type mergeOptions struct {
+	tracer     MergeTracer
+	nullPolicy schema.NullPolicy
+	budget     MergeBudget
+	share      bool
+}
+
+type MergeOption func(*mergeOptions)
+
+// WithMergeTracer configures Merge to report every merge decision to tracer.
+func WithMergeTracer(tracer MergeTracer) MergeOption {
+	return func(opts *mergeOptions) {
+		opts.tracer = tracer
+	}
+}
+
+// WithSharedValues configures Merge to share the parts of tv which pso
+// doesn't set with the result, rather than copying them, which makes
+// merging small changes into big objects, and comparing the result with tv,
+// much cheaper. These parts aren't validated again either, so tv must be
+// valid (e.g. not built by AsTypedUnvalidated), and neither tv nor the
+// result may be modified afterwards.
+//
+// It has no effect with WithMergeTracer, nor with a WithMergeBudget, which
+// need to walk all of tv.
+func WithSharedValues() MergeOption {
+	return func(opts *mergeOptions) {
+		opts.share = true
+	}
+}
+
+// compareOptions is the options available when comparing.
+type compareOptions struct {
+	ignored           *fieldpath.Set
+	floatTolerance    float64
+	stringNormalizers []StringNormalizer
+	emptyCollections  EmptyCollectionPolicy
+	untypedComparator UntypedComparator
+	untypedMaxNodes   int
+	listChunking      ListChunking
+}
+
+type CompareOption func(*compareOptions)
+
+// WithIgnoredFields configures Compare to leave the fields of ignored, and
+// all of their children, out of the comparison, as
+// Comparison.ExcludeFields does.
+func WithIgnoredFields(ignored *fieldpath.Set) CompareOption {
+	return func(opts *compareOptions) {
+		opts.ignored = ignored
+	}
+}
+
+// WithFloatTolerance configures Compare to consider numeric scalar fields
+// which differ by at most tolerance as unchanged. Integers and floats are
+// always compared by value, e.g. 1 and 1.0 are equal, but conversions and
+// round-trips through JSON or YAML can also slightly change floats.
+func WithFloatTolerance(tolerance float64) CompareOption {
+	return func(opts *compareOptions) {
+		opts.floatTolerance = tolerance
+	}
+}
+
 // AsTyped accepts a value and a type and returns a TypedValue. 'v' must have
 // type 'typeName' in the schema. An error is returned if the v doesn't conform
 // to the schema.
@@ -79,39 +158,141 @@ func AsTypedUnvalidated(v value.Value, s *schema.Schema, typeRef schema.TypeRef)
 	return tv
 }
 
-// TypedValue is a value of some specific type.
+// TypedValue is a value of some specific type. It is always used through
+// pointers, built by AsTyped, AsTypedLazy or by the Parser, and is never
+// modified once built: the operations returning a TypedValue return a new
+// one. A nil TypedValue has no type nor value, which IsNil tells and its
+// accessors (TypeRef, Schema, AsValue and Lookup) return. Alternate
+// implementations of the value itself, e.g. of already validated objects,
+// are value.Value implementations.
+//
+// The methods of TypedValue have pointer receivers. Code which copies
+// TypedValue structs can call them as long as the copy is addressable, but
+// should keep the pointers instead, see LegacyTypedValue. Copies share the
+// deferred validation of the original, see AsTypedLazy.
 type TypedValue struct {
 	value   value.Value
 	typeRef schema.TypeRef
 	schema  *schema.Schema
+	// lazy is the deferred validation of value, nil if it is validated or
+	// unvalidated.
+	lazy *lazyValidation
+	// index holds the keys of the items of the lists of value, if set.
+	index *ListIndex
+}
+
+// LegacyTypedValue has the methods of TypedValue as value methods, for the
+// code written when they had value receivers, which used TypedValue values
+// where they aren't addressable: in interfaces, or as the results of
+// functions. Such code keeps compiling by wrapping them, e.g.
+// LegacyTypedValue{&tv}.
+//
+// Deprecated: use *TypedValue. LegacyTypedValue will be removed in the next
+// release.
+type LegacyTypedValue struct {
+	*TypedValue
+}
+
+// IsNil returns whether tv is nil. Only IsNil and the accessors may be
+// called on a nil TypedValue, its other methods panic.
+func (tv *TypedValue) IsNil() bool {
+	return tv == nil
 }
 
-// TypeRef is the type of the value.
-func (tv TypedValue) TypeRef() schema.TypeRef {
+// TypeRef is the type of the value, empty if tv is nil.
+func (tv *TypedValue) TypeRef() schema.TypeRef {
+	if tv == nil {
+		return schema.TypeRef{}
+	}
 	return tv.typeRef
 }
 
-// AsValue removes the type from the TypedValue and only keeps the value.
-func (tv TypedValue) AsValue() value.Value {
+// AsValue removes the type from the TypedValue and only keeps the value,
+// nil if tv is nil.
+func (tv *TypedValue) AsValue() value.Value {
+	if tv == nil {
+		return nil
+	}
 	return tv.value
 }
 
-// Schema gets the schema from the TypedValue.
-func (tv TypedValue) Schema() *schema.Schema {
+// Lookup returns the value at path in tv, if any. Unlike
+// fieldpath.Path.Lookup, it finds the items of the lists whose keys have
+// collations by their folded keys, like the field sets of tv identify them.
+func (tv *TypedValue) Lookup(path fieldpath.Path) (value.Value, bool) {
+	if tv == nil {
+		return nil, false
+	}
+	v, tr := tv.value, tv.typeRef
+	for _, pe := range path {
+		if v == nil {
+			return nil, false
+		}
+		a, _ := tv.schema.Resolve(tr)
+		var ok bool
+		if a.List != nil && (pe.Key != nil || pe.Value != nil) {
+			v, ok = lookupItem(v, pe, a.List.KeyCollations)
+		} else {
+			v, ok = fieldpath.Path{pe}.Lookup(v)
+		}
+		if !ok {
+			return nil, false
+		}
+		switch {
+		case a.Map != nil && pe.FieldName != nil:
+			tr, _ = typeRefAtPath(a.Map, pe)
+		case a.List != nil:
+			tr = listElementType(value.HeapAllocator, a.List, v)
+		}
+	}
+	return v, true
+}
+
+// lookupItem returns the item of the list v identified by pe.
+func lookupItem(v value.Value, pe fieldpath.PathElement, collations []schema.KeyCollation) (value.Value, bool) {
+	if !v.IsList() {
+		return nil, false
+	}
+	l := v.AsList()
+	for i := 0; i < l.Length(); i++ {
+		if pe.MatchesItemCollated(l.At(i), collations) {
+			return l.At(i), true
+		}
+	}
+	return nil, false
+}
+
+// Schema gets the schema from the TypedValue, nil if tv is nil.
+func (tv *TypedValue) Schema() *schema.Schema {
+	if tv == nil {
+		return nil
+	}
 	return tv.schema
 }
 
+// withValue returns a copy of tv with v as value, deferring its
+// validation if the one of tv is.
+func (tv *TypedValue) withValue(v value.Value) *TypedValue {
+	out := &TypedValue{value: v, typeRef: tv.typeRef, schema: tv.schema, index: tv.index}
+	if tv.lazy != nil {
+		out.lazy = &lazyValidation{opts: tv.lazy.opts}
+	}
+	return out
+}
+
 // Validate returns an error with a list of every spec violation.
-func (tv TypedValue) Validate(opts ...ValidationOptions) error {
+func (tv *TypedValue) Validate(opts ...ValidationOptions) error {
+	return tv.validate(nil, opts)
+}
+
+func (tv *TypedValue) validate(cache *ValidationCache, opts []ValidationOptions) error {
 	w := tv.walker()
-	for _, opt := range opts {
-		switch opt {
-		case AllowDuplicates:
-			w.allowDuplicates = true
-		}
-	}
+	w.cache = cache
+	w.setOptions(opts)
 	defer w.finished()
 	if errs := w.validate(nil); len(errs) != 0 {
+		// The fields of maps are visited in no particular order.
+		sort.SliceStable(errs, func(i, j int) bool { return errs[i].Path < errs[j].Path })
 		return errs
 	}
 	return nil
@@ -119,12 +300,36 @@ func (tv TypedValue) Validate(opts ...ValidationOptions) error {
 
 // ToFieldSet creates a set containing every leaf field and item mentioned, or
 // validation errors, if any were encountered.
-func (tv TypedValue) ToFieldSet() (*fieldpath.Set, error) {
+func (tv *TypedValue) ToFieldSet(opts ...ToFieldSetOption) (*fieldpath.Set, error) {
+	var options toFieldSetOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if err := tv.CheckUntypedLimits(options.untypedLimits); err != nil {
+		return nil, err
+	}
+	// The walk validates all of tv, unless the null policy changes it
+	// or the patterns prune it.
+	lazy := tv.deferred()
+	if options.nullPolicy != "" || tv.schema.HasNullPolicies() {
+		out, errs := tv.applyNullPolicy(options.nullPolicy, true)
+		if len(errs) > 0 {
+			return nil, errs
+		}
+		tv = out
+		lazy = nil
+	}
 	w := tv.toFieldSetWalker()
 	defer w.finished()
-	if errs := w.toFieldSet(); len(errs) != 0 {
+	if options.patterns != nil {
+		w.filtered = true
+		w.patterns = fieldpath.StartPatterns(options.patterns...)
+		lazy = nil
+	}
+	if errs := w.toFieldSet(nil); len(errs) != 0 {
 		return nil, errs
 	}
+	lazy.valid()
 	return w.set, nil
 }
 
@@ -136,12 +341,36 @@ func (tv TypedValue) ToFieldSet() (*fieldpath.Set, error) {
 //   - Container typed elements will have their items ordered:
 //     1. like tv, if pso doesn't change anything in the container
 //     2. like pso, if pso does change something in the container.
+//   - The result is a copy: it shares no map nor list with tv or pso,
+//     unless WithSharedValues is set.
 //
 // tv and pso must both be of the same type (their Schema and TypeRef must
 // match), or an error will be returned. Validation errors will be returned if
 // the objects don't conform to the schema.
-func (tv TypedValue) Merge(pso *TypedValue) (*TypedValue, error) {
-	return merge(&tv, pso, ruleKeepRHS, nil)
+func (tv *TypedValue) Merge(pso *TypedValue, opts ...MergeOption) (*TypedValue, error) {
+	options := &mergeOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+	return tv.mergeWith(pso, options)
+}
+
+func (tv *TypedValue) mergeWith(pso *TypedValue, options *mergeOptions) (*TypedValue, error) {
+	if options.nullPolicy == "" && !tv.schema.HasNullPolicies() {
+		return merge(tv, pso, ruleKeepRHS, nil, options.tracer, newMergeBudgetState(options.budget), options.share, nil)
+	}
+	if _, errs := pso.applyNullPolicy(options.nullPolicy, true); len(errs) > 0 {
+		return nil, errs
+	}
+	out, err := merge(tv, pso, ruleKeepRHS, nil, options.tracer, newMergeBudgetState(options.budget), options.share, nil)
+	if err != nil {
+		return nil, err
+	}
+	out, errs := out.applyNullPolicy(options.nullPolicy, false)
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return out, nil
 }
 
 var cmpwPool = sync.Pool{
@@ -154,14 +383,24 @@ var cmpwPool = sync.Pool{
 // tv and rhs must both be of the same type (their Schema and TypeRef must
 // match), or an error will be returned. Validation errors will be returned if
 // the objects don't conform to the schema.
-func (tv TypedValue) Compare(rhs *TypedValue) (c *Comparison, err error) {
+func (tv *TypedValue) Compare(rhs *TypedValue, opts ...CompareOption) (c *Comparison, err error) {
+	var options compareOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
 	lhs := tv
+	lhsLazy, rhsLazy := tv.deferred(), rhs.deferred()
 	if lhs.schema != rhs.schema {
 		return nil, errorf("expected objects with types from the same schema")
 	}
 	if !lhs.typeRef.Equals(&rhs.typeRef) {
 		return nil, errorf("expected objects of the same type, but got %v and %v", lhs.typeRef, rhs.typeRef)
 	}
+	rhsValue := rhs.value
+	if options.emptyCollections != EmptyIsDistinct {
+		lhs = lhs.NormalizeEmptyCollections(options.emptyCollections)
+		rhsValue = rhs.NormalizeEmptyCollections(options.emptyCollections).value
+	}
 
 	cmpw := cmpwPool.Get().(*compareWalker)
 	defer func() {
@@ -171,14 +410,35 @@ func (tv TypedValue) Compare(rhs *TypedValue) (c *Comparison, err error) {
 		cmpw.typeRef = schema.TypeRef{}
 		cmpw.comparison = nil
 		cmpw.inLeaf = false
+		cmpw.floatTolerance = 0
+		cmpw.stringNormalizers = nil
+		cmpw.untypedComparator = nil
+		cmpw.untypedMaxNodes = 0
+		cmpw.listChunking = ListChunking{}
+		cmpw.index = nil
+		cmpw.lhsLazy = nil
+		cmpw.rhsLazy = nil
+		cmpw.untyped = false
+		cmpw.descended = false
 
 		cmpwPool.Put(cmpw)
 	}()
 
 	cmpw.lhs = lhs.value
-	cmpw.rhs = rhs.value
+	cmpw.rhs = rhsValue
 	cmpw.schema = lhs.schema
 	cmpw.typeRef = lhs.typeRef
+	cmpw.floatTolerance = options.floatTolerance
+	cmpw.stringNormalizers = options.stringNormalizers
+	cmpw.untypedComparator = options.untypedComparator
+	cmpw.untypedMaxNodes = options.untypedMaxNodes
+	cmpw.listChunking = options.listChunking
+	cmpw.index = lhs.index
+	if cmpw.index == nil {
+		cmpw.index = rhs.index
+	}
+	cmpw.lhsLazy = lhsLazy
+	cmpw.rhsLazy = rhsLazy
 	cmpw.comparison = &Comparison{
 		Removed:  fieldpath.NewSet(),
 		Modified: fieldpath.NewSet(),
@@ -192,17 +452,21 @@ func (tv TypedValue) Compare(rhs *TypedValue) (c *Comparison, err error) {
 	if len(errs) > 0 {
 		return nil, errs
 	}
-	return cmpw.comparison, nil
+	if options.emptyCollections == EmptyIsDistinct {
+		// The walk visited, and validated, all of lhs and rhs.
+		lhsLazy.valid()
+		rhsLazy.valid()
+	}
+	return cmpw.comparison.ExcludeFields(options.ignored).ExcludeFields(tv.VolatileFields()), nil
 }
 
 // RemoveItems removes each provided list or map item from the value.
-func (tv TypedValue) RemoveItems(items *fieldpath.Set) *TypedValue {
-	tv.value = removeItemsWithSchema(tv.value, items, tv.schema, tv.typeRef, false)
-	return &tv
+func (tv *TypedValue) RemoveItems(items *fieldpath.Set) *TypedValue {
+	return tv.withValue(removeItemsWithSchema(tv.value, items, tv.schema, tv.typeRef, false))
 }
 
 // ExtractItems returns a value with only the provided list or map items extracted from the value.
-func (tv TypedValue) ExtractItems(items *fieldpath.Set, opts ...ExtractItemsOption) *TypedValue {
+func (tv *TypedValue) ExtractItems(items *fieldpath.Set, opts ...ExtractItemsOption) *TypedValue {
 	options := &extractItemsOptions{}
 	for _, opt := range opts {
 		opt(options)
@@ -233,20 +497,18 @@ func (tv TypedValue) ExtractItems(items *fieldpath.Set, opts ...ExtractItemsOpti
 		}
 	}
 
-	tv.value = removeItemsWithSchema(tv.value, items, tv.schema, tv.typeRef, true)
-	return &tv
+	return tv.withValue(removeItemsWithSchema(tv.value, items, tv.schema, tv.typeRef, true))
 }
 
-func (tv TypedValue) Empty() *TypedValue {
-	tv.value = value.NewValueInterface(nil)
-	return &tv
+func (tv *TypedValue) Empty() *TypedValue {
+	return tv.withValue(value.NewValueInterface(nil))
 }
 
 var mwPool = sync.Pool{
 	New: func() interface{} { return &mergingWalker{} },
 }
 
-func merge(lhs, rhs *TypedValue, rule, postRule mergeRule) (*TypedValue, error) {
+func merge(lhs, rhs *TypedValue, rule, postRule mergeRule, tracer MergeTracer, budget *mergeBudgetState, share bool, comparison *mergeComparison) (*TypedValue, error) {
 	if lhs.schema != rhs.schema {
 		return nil, errorf("expected objects with types from the same schema")
 	}
@@ -263,7 +525,15 @@ func merge(lhs, rhs *TypedValue, rule, postRule mergeRule) (*TypedValue, error)
 		mw.rule = nil
 		mw.postItemHook = nil
 		mw.out = nil
+		mw.tracer = nil
+		mw.budget = nil
+		mw.index = nil
+		mw.share = false
+		mw.lhsLazy = nil
+		mw.rhsLazy = nil
+		mw.comparison = nil
 		mw.inLeaf = false
+		mw.descended = false
 
 		mwPool.Put(mw)
 	}()
@@ -274,18 +544,33 @@ func merge(lhs, rhs *TypedValue, rule, postRule mergeRule) (*TypedValue, error)
 	mw.typeRef = lhs.typeRef
 	mw.rule = rule
 	mw.postItemHook = postRule
+	mw.tracer = tracer
+	mw.budget = budget
+	mw.index = lhs.index
+	mw.share = share
+	lhsLazy, rhsLazy := lhs.deferred(), rhs.deferred()
+	mw.lhsLazy = lhsLazy
+	mw.rhsLazy = rhsLazy
+	mw.comparison = comparison
 	if mw.allocator == nil {
 		mw.allocator = value.NewFreelistAllocator()
 	}
 
 	errs := mw.merge(nil)
+	if budget != nil && budget.err != nil {
+		return nil, budget.err
+	}
 	if len(errs) > 0 {
 		return nil, errs
 	}
+	// The walk visited, and validated, all of lhs and rhs.
+	lhsLazy.valid()
+	rhsLazy.valid()
 
 	out := &TypedValue{
 		schema:  lhs.schema,
 		typeRef: lhs.typeRef,
+		index:   lhs.index,
 	}
 	if mw.out != nil {
 		out.value = value.NewValueInterface(*mw.out)
@@ -0,0 +1,125 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package typed_test
+
+import (
+	"testing"
+
+	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
+	"sigs.k8s.io/structured-merge-diff/v4/schema"
+	"sigs.k8s.io/structured-merge-diff/v4/typed"
+	"sigs.k8s.io/structured-merge-diff/v4/value"
+)
+
+func TestNilTypedValue(t *testing.T) {
+	var tv *typed.TypedValue
+	if !tv.IsNil() {
+		t.Error("expected a nil TypedValue to be nil")
+	}
+	tr := tv.TypeRef()
+	if tv.AsValue() != nil || tv.Schema() != nil || !tr.Equals(&schema.TypeRef{}) {
+		t.Errorf("expected a nil TypedValue to have no value, schema nor type")
+	}
+	if v, ok := tv.Lookup(fieldpath.MakePathOrDie("a")); ok || v != nil {
+		t.Errorf("expected nothing to be found in a nil TypedValue, got %v", v)
+	}
+}
+
+func TestLegacyTypedValue(t *testing.T) {
+	tv, err := typed.DeducedParseableType.FromYAML(`{"a": 1}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The value methods of LegacyTypedValue satisfy the interfaces which
+	// TypedValue values used to.
+	var i interface {
+		AsValue() value.Value
+		Schema() *schema.Schema
+		TypeRef() schema.TypeRef
+		ToFieldSet(...typed.ToFieldSetOption) (*fieldpath.Set, error)
+		RemoveItems(*fieldpath.Set) *typed.TypedValue
+	} = typed.LegacyTypedValue{tv}
+	if !value.Equals(i.AsValue(), tv.AsValue()) {
+		t.Errorf("expected %v, got %v", value.ToString(tv.AsValue()), value.ToString(i.AsValue()))
+	}
+	if _, err := i.ToFieldSet(); err != nil {
+		t.Error(err)
+	}
+	if removed := i.RemoveItems(fieldpath.NewSet()); !value.Equals(removed.AsValue(), tv.AsValue()) {
+		t.Errorf("expected %v, got %v", value.ToString(tv.AsValue()), value.ToString(removed.AsValue()))
+	}
+}
+
+func TestTypedValueCopies(t *testing.T) {
+	tv, err := typed.DeducedParseableType.FromYAML(`{"a": 1}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Copies share the deferred validation.
+	lazy := typed.AsTypedLazy(tv.AsValue(), tv.Schema(), tv.TypeRef())
+	copied := *lazy
+	if _, err := copied.ToFieldSet(); err != nil {
+		t.Fatal(err)
+	}
+	if lazy.IsLazy() {
+		t.Error("expected the validation of the copy to be the one of the original")
+	}
+}
+
+func TestTypedValueNotModified(t *testing.T) {
+	const object = `{"a": 1, "b": {"c": 2}}`
+	tests := []struct {
+		name string
+		op   func(tv *typed.TypedValue) *typed.TypedValue
+	}{
+		{
+			name: "RemoveItems",
+			op: func(tv *typed.TypedValue) *typed.TypedValue {
+				return tv.RemoveItems(_NS(_P("a")))
+			},
+		}, {
+			name: "ExtractItems",
+			op: func(tv *typed.TypedValue) *typed.TypedValue {
+				return tv.ExtractItems(_NS(_P("a")))
+			},
+		}, {
+			name: "Empty",
+			op: func(tv *typed.TypedValue) *typed.TypedValue {
+				return tv.Empty()
+			},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			tv, err := typed.DeducedParseableType.FromYAML(object)
+			if err != nil {
+				t.Fatal(err)
+			}
+			expected, err := typed.DeducedParseableType.FromYAML(object)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if out := tt.op(tv); out == tv {
+				t.Error("expected a new TypedValue")
+			}
+			if !value.Equals(tv.AsValue(), expected.AsValue()) {
+				t.Errorf("expected %v not to be modified, got %v", value.ToString(expected.AsValue()), value.ToString(tv.AsValue()))
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package typed
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"sigs.k8s.io/structured-merge-diff/v4/value"
+)
+
+// UntypedComparator returns true if lhs and rhs, the values of an untyped
+// field, are equal.
+type UntypedComparator func(lhs, rhs value.Value) bool
+
+// WithUntypedComparator configures Compare to compare the values of untyped
+// fields, e.g. x-kubernetes-preserve-unknown-fields subtrees, with cmp
+// rather than by deep equality.
+func WithUntypedComparator(cmp UntypedComparator) CompareOption {
+	return func(opts *compareOptions) {
+		opts.untypedComparator = cmp
+	}
+}
+
+// WithUntypedMaxNodes configures Compare to fail when the value of an
+// untyped field has more than maxNodes nodes, counting every scalar, list
+// item and map entry, rather than spend its time comparing huge blobs.
+// maxNodes isn't enforced if it isn't positive.
+func WithUntypedMaxNodes(maxNodes int) CompareOption {
+	return func(opts *compareOptions) {
+		opts.untypedMaxNodes = maxNodes
+	}
+}
+
+// CanonicalJSONEquals is an UntypedComparator which compares the canonical
+// JSON encodings of the values, with sorted map keys. Unlike deep equality,
+// it can't tell integers from floats which have the same encoding.
+func CanonicalJSONEquals(lhs, rhs value.Value) bool {
+	l, err := json.Marshal(lhs.Unstructured())
+	if err != nil {
+		return false
+	}
+	r, err := json.Marshal(rhs.Unstructured())
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(l, r)
+}
+
+// untypedEquals compares lhs and rhs, which are the values of an untyped
+// field.
+func (w *compareWalker) untypedEquals() (bool, ValidationErrors) {
+	if w.untypedMaxNodes > 0 {
+		if n := countNodes(w.lhs, w.untypedMaxNodes); n > w.untypedMaxNodes {
+			return false, errorf("lhs: untyped value has more than %v nodes", w.untypedMaxNodes)
+		}
+		if n := countNodes(w.rhs, w.untypedMaxNodes); n > w.untypedMaxNodes {
+			return false, errorf("rhs: untyped value has more than %v nodes", w.untypedMaxNodes)
+		}
+	}
+	if w.untypedComparator != nil {
+		return w.untypedComparator(w.lhs, w.rhs), nil
+	}
+	return value.EqualsUsing(w.allocator, w.lhs, w.rhs), nil
+}
+
+// countNodes returns the number of nodes of v, or any number greater than
+// max if it has more.
+func countNodes(v value.Value, max int) int {
+	n := 1
+	switch {
+	case v.IsMap():
+		v.AsMap().Iterate(func(_ string, child value.Value) bool {
+			n += countNodes(child, max-n)
+			return n <= max
+		})
+	case v.IsList():
+		l := v.AsList()
+		for i := 0; i < l.Length() && n <= max; i++ {
+			n += countNodes(l.At(i), max-n)
+		}
+	}
+	return n
+}
@@ -0,0 +1,145 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package typed_test
+
+import (
+	"strings"
+	"testing"
+
+	"sigs.k8s.io/structured-merge-diff/v4/typed"
+	"sigs.k8s.io/structured-merge-diff/v4/value"
+)
+
+var untypedParser = func() *typed.Parser {
+	parser, err := typed.NewParser(`types:
+- name: type
+  map:
+    fields:
+    - name: name
+      type:
+        scalar: string
+    - name: blob
+      type:
+        namedType: __untyped_atomic_
+- name: __untyped_atomic_
+  scalar: untyped
+  list:
+    elementType:
+      namedType: __untyped_atomic_
+    elementRelationship: atomic
+  map:
+    elementType:
+      namedType: __untyped_atomic_
+    elementRelationship: atomic
+`)
+	if err != nil {
+		panic(err)
+	}
+	return parser
+}()
+
+func TestCompareUntyped(t *testing.T) {
+	ignoreCase := func(lhs, rhs value.Value) bool {
+		return strings.EqualFold(value.ToString(lhs), value.ToString(rhs))
+	}
+	tests := []struct {
+		name     string
+		lhs, rhs typed.YAMLObject
+		opts     []typed.CompareOption
+		modified bool
+		err      string
+	}{{
+		name:     "deep equality",
+		lhs:      `{"blob": {"a": 1, "b": [1.0]}}`,
+		rhs:      `{"blob": {"b": [1], "a": 1}}`,
+		modified: false,
+	}, {
+		name:     "canonical JSON",
+		lhs:      `{"blob": {"a": 1, "b": [1.0]}}`,
+		rhs:      `{"blob": {"b": [1], "a": 1}}`,
+		opts:     []typed.CompareOption{typed.WithUntypedComparator(typed.CanonicalJSONEquals)},
+		modified: false,
+	}, {
+		name:     "canonical JSON difference",
+		lhs:      `{"blob": {"a": 1}}`,
+		rhs:      `{"blob": {"a": "1"}}`,
+		opts:     []typed.CompareOption{typed.WithUntypedComparator(typed.CanonicalJSONEquals)},
+		modified: true,
+	}, {
+		name:     "custom comparator",
+		lhs:      `{"blob": "ABC", "name": "ABC"}`,
+		rhs:      `{"blob": "abc", "name": "ABC"}`,
+		opts:     []typed.CompareOption{typed.WithUntypedComparator(ignoreCase)},
+		modified: false,
+	}, {
+		name:     "typed fields use deep equality",
+		lhs:      `{"name": "ABC"}`,
+		rhs:      `{"name": "abc"}`,
+		opts:     []typed.CompareOption{typed.WithUntypedComparator(ignoreCase)},
+		modified: true,
+	}, {
+		name:     "within the node limit",
+		lhs:      `{"blob": [1, 2, 3]}`,
+		rhs:      `{"blob": [1, 2]}`,
+		opts:     []typed.CompareOption{typed.WithUntypedMaxNodes(4)},
+		modified: true,
+	}, {
+		name: "over the node limit",
+		lhs:  `{"blob": [1, 2, 3]}`,
+		rhs:  `{"blob": {"a": [1, 2, 3, 4]}}`,
+		opts: []typed.CompareOption{typed.WithUntypedMaxNodes(4)},
+		err:  "rhs: untyped value has more than 4 nodes",
+	}}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			pt := untypedParser.Type("type")
+			lhs, err := pt.FromYAML(tt.lhs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rhs, err := pt.FromYAML(tt.rhs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cmp, err := lhs.Compare(rhs, tt.opts...)
+			// rhs sets all the fields of lhs, the merge compares them
+			// the same way.
+			_, mergeErr := lhs.MergeAndCompare(rhs, nil, tt.opts)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("expected an error containing %q, got %v", tt.err, err)
+				}
+				if mergeErr == nil || !strings.Contains(mergeErr.Error(), tt.err) {
+					t.Fatalf("expected MergeAndCompare to fail with %q, got %v", tt.err, mergeErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if modified := !cmp.Modified.Empty(); modified != tt.modified {
+				t.Errorf("expected modified to be %v, got %v", tt.modified, cmp)
+			}
+			merged, err := lhs.Merge(rhs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkMergeAndCompare(t, lhs, rhs, merged, tt.opts...)
+		})
+	}
+}
@@ -17,6 +17,7 @@ limitations under the License.
 package typed
 
 import (
+	"strings"
 	"sync"
 
 	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
@@ -28,21 +29,42 @@ var vPool = sync.Pool{
 	New: func() interface{} { return &validatingObjectWalker{} },
 }
 
-func (tv TypedValue) walker() *validatingObjectWalker {
+func (tv *TypedValue) walker() *validatingObjectWalker {
+	return validatingWalker(tv.value, tv.schema, tv.typeRef)
+}
+
+func validatingWalker(val value.Value, s *schema.Schema, tr schema.TypeRef) *validatingObjectWalker {
 	v := vPool.Get().(*validatingObjectWalker)
-	v.value = tv.value
-	v.schema = tv.schema
-	v.typeRef = tv.typeRef
+	v.value = val
+	v.schema = s
+	v.typeRef = tr
 	v.allowDuplicates = false
+	v.strictListKeys = false
+	v.shallow = false
+	v.cache = nil
 	if v.allocator == nil {
 		v.allocator = value.NewFreelistAllocator()
 	}
 	return v
 }
 
+// setOptions configures v with opts.
+func (v *validatingObjectWalker) setOptions(opts []ValidationOptions) {
+	for _, opt := range opts {
+		switch opt {
+		case AllowDuplicates:
+			v.allowDuplicates = true
+		case StrictListKeys:
+			v.strictListKeys = true
+		}
+	}
+}
+
 func (v *validatingObjectWalker) finished() {
+	v.value = nil
 	v.schema = nil
 	v.typeRef = schema.TypeRef{}
+	v.cache = nil
 	vPool.Put(v)
 }
 
@@ -53,6 +75,17 @@ type validatingObjectWalker struct {
 	// If set to true, duplicates will be allowed in
 	// associativeLists/sets.
 	allowDuplicates bool
+	// If set to true, the items of associative lists must have all their
+	// keys and be unique. See StrictListKeys.
+	strictListKeys bool
+	// If set to true, only the value itself is validated, not its
+	// children, except for the duplicate items of lists, which the
+	// walkers validating lazy values as they go don't visit.
+	shallow bool
+	// enum is the Enum of the atom being validated, if any.
+	enum *schema.Enum
+	// cache holds the maps and lists known to be valid, if set.
+	cache *ValidationCache
 
 	// Allocate only as many walkers as needed for the depth by storing them here.
 	spareWalkers *[]*validatingObjectWalker
@@ -82,7 +115,51 @@ func (v *validatingObjectWalker) finishDescent(v2 *validatingObjectWalker) {
 }
 
 func (v *validatingObjectWalker) validate(prefixFn func() string) ValidationErrors {
-	return resolveSchema(v.schema, v.typeRef, v.value, v).WithLazyPrefix(prefixFn)
+	if v.cache == nil {
+		return v.validateUncached(prefixFn)
+	}
+	key, ok := v.cacheKey()
+	if !ok {
+		return v.validateUncached(prefixFn)
+	}
+	if v.cache.valid(key) {
+		return nil
+	}
+	errs := v.validateUncached(prefixFn)
+	if len(errs) == 0 {
+		v.cache.add(key, v.value)
+	}
+	return errs
+}
+
+func (v *validatingObjectWalker) validateUncached(prefixFn func() string) ValidationErrors {
+	a, ok := v.schema.Resolve(v.typeRef)
+	if !ok {
+		return resolveSchema(v.schema, v.typeRef, v.value, v).WithLazyPrefix(prefixFn)
+	}
+	// deduceAtom drops the enum, keep it for doScalar.
+	v.enum = a.Enum
+	deduced := deduceAtom(a, v.value)
+	if shapes := atomShapes(a); len(shapes) > 1 && deduced == a && v.value != nil && !v.value.IsNull() {
+		// None of the shapes of the dual type matched.
+		return errorf("expected %v, got %v", strings.Join(shapes, " or "), value.KindOf(v.value)).WithLazyPrefix(prefixFn)
+	}
+	return handleAtom(deduced, v.typeRef, v).WithLazyPrefix(prefixFn)
+}
+
+// atomShapes returns the names of the shapes of values allowed by a.
+func atomShapes(a schema.Atom) []string {
+	var shapes []string
+	if a.Scalar != nil {
+		shapes = append(shapes, string(*a.Scalar))
+	}
+	if a.List != nil {
+		shapes = append(shapes, "list")
+	}
+	if a.Map != nil {
+		shapes = append(shapes, "map")
+	}
+	return shapes
 }
 
 func validateScalar(t *schema.Scalar, v value.Value, prefix string) (errs ValidationErrors) {
@@ -110,6 +187,10 @@ func validateScalar(t *schema.Scalar, v value.Value, prefix string) (errs Valida
 		if !v.IsFloat() && !v.IsInt() && !v.IsString() && !v.IsBool() {
 			return errorf("%vexpected any scalar, got %v", prefix, v)
 		}
+	case schema.NumericOrString:
+		if !v.IsFloat() && !v.IsInt() && !v.IsString() {
+			return errorf("%vexpected numeric or string, got %v", prefix, value.KindOf(v))
+		}
 	default:
 		return errorf("%vunexpected scalar type in schema: %v", prefix, *t)
 	}
@@ -120,36 +201,152 @@ func (v *validatingObjectWalker) doScalar(t *schema.Scalar) ValidationErrors {
 	if errs := validateScalar(t, v.value, ""); len(errs) > 0 {
 		return errs
 	}
+	if v.enum != nil {
+		return validateEnum(*v.enum, v.value)
+	}
 	return nil
 }
 
+func validateEnum(e schema.Enum, v value.Value) ValidationErrors {
+	if v == nil || v.IsNull() {
+		return nil
+	}
+	allowed := make([]string, 0, len(e))
+	for _, ev := range e {
+		allowedValue := value.NewValueInterface(ev)
+		if value.Equals(v, allowedValue) {
+			return nil
+		}
+		allowed = append(allowed, value.ToString(allowedValue))
+	}
+	return errorf("unsupported value %v, must be one of: %v", value.ToString(v), strings.Join(allowed, ", "))
+}
+
 func (v *validatingObjectWalker) visitListItems(t *schema.List, list value.List) (errs ValidationErrors) {
 	observedKeys := fieldpath.MakePathElementSet(list.Length())
+	var firstIndex map[string]int
+	if v.strictListKeys && t.ElementRelationship == schema.Associative {
+		firstIndex = make(map[string]int, list.Length())
+	}
+	// In shallow mode, the items are only validated if their key is
+	// duplicated: firstItems are the first items of the keys, and
+	// duplicated the keys whose first item is validated.
+	var firstItems fieldpath.PathElementValueMap
+	var duplicated *fieldpath.PathElementSet
+	if v.shallow && t.ElementRelationship == schema.Associative {
+		firstItems = fieldpath.MakePathElementValueMap(list.Length())
+		duplicated = &fieldpath.PathElementSet{}
+	}
+	folders := keyFolders(t)
 	for i := 0; i < list.Length(); i++ {
 		child := list.AtUsing(v.allocator, i)
 		defer v.allocator.Free(child)
 		var pe fieldpath.PathElement
+		duplicate := false
 		if t.ElementRelationship != schema.Associative {
 			pe.Index = &i
 		} else {
+			if v.strictListKeys {
+				if keyErrs := missingListKeys(v.allocator, t, i, child); len(keyErrs) > 0 {
+					errs = append(errs, keyErrs...)
+					continue
+				}
+			}
 			var err error
-			pe, err = listItemToPathElement(v.allocator, v.schema, t, child)
+			pe, err = listItemToPathElement(v.allocator, v.schema, t, folders, child)
 			if err != nil {
 				errs = append(errs, errorf("element %v: %v", i, err.Error())...)
+				if v.strictListKeys {
+					continue
+				}
 				// If we can't construct the path element, we can't
 				// even report errors deeper in the schema, so bail on
 				// this element.
 				return
 			}
-			if observedKeys.Has(pe) && !v.allowDuplicates {
+			duplicate = observedKeys.Has(pe)
+			if v.strictListKeys {
+				key := pe.String()
+				if first, ok := firstIndex[key]; ok {
+					errs = append(errs, errorf("element %v: duplicate entries for key %v (first at element %v)", i, key, first)...)
+				} else {
+					firstIndex[key] = i
+				}
+			} else if duplicate && !v.allowDuplicates {
 				errs = append(errs, errorf("duplicate entries for key %v", pe.String())...)
 			}
 			observedKeys.Insert(pe)
 		}
-		v2 := v.prepareDescent(t.ElementType)
-		v2.value = child
-		errs = append(errs, v2.validate(pe.String)...)
-		v.finishDescent(v2)
+		elementType, typeErrs := discriminatedType(v.allocator, t, child)
+		if len(typeErrs) > 0 {
+			errs = append(errs, typeErrs.WithLazyPrefix(pe.String)...)
+			continue
+		}
+		if v.shallow {
+			if duplicated == nil || !duplicate {
+				if duplicated != nil {
+					firstItems.Insert(pe, child)
+				}
+				continue
+			}
+			if first, ok := firstItems.Get(pe); ok && !duplicated.Has(pe) {
+				duplicated.Insert(pe)
+				// The type of the first item was checked already.
+				firstType, _ := discriminatedType(v.allocator, t, first)
+				errs = append(errs, v.validateItem(firstType, pe, first)...)
+			}
+		}
+		errs = append(errs, v.validateItem(elementType, pe, child)...)
+	}
+	return errs
+}
+
+// validateItem validates child, a list item of type elementType
+// identified by pe, and all of its children.
+func (v *validatingObjectWalker) validateItem(elementType schema.TypeRef, pe fieldpath.PathElement, child value.Value) ValidationErrors {
+	v2 := v.prepareDescent(elementType)
+	v2.value = child
+	v2.shallow = false
+	errs := v2.validate(pe.String)
+	v.finishDescent(v2)
+	return errs
+}
+
+// discriminatedType returns the type of child, an element of the list t, or
+// an error if t is heterogeneous and the discriminator of child selects no
+// type.
+func discriminatedType(a value.Allocator, t *schema.List, child value.Value) (schema.TypeRef, ValidationErrors) {
+	if t.Discriminator == "" || (t.ElementType != schema.TypeRef{}) || !child.IsMap() {
+		return listElementType(a, t, child), nil
+	}
+	m := child.AsMapUsing(a)
+	defer a.Free(m)
+	d, ok := m.Get(t.Discriminator)
+	if !ok || d.IsNull() {
+		return schema.TypeRef{}, errorf("missing discriminator field %q", t.Discriminator)
+	}
+	if !d.IsString() {
+		return schema.TypeRef{}, errorf("discriminator field %q must be a string, got %v", t.Discriminator, value.ToString(d))
+	}
+	tr, ok := t.ElementTypeFor(d.AsString())
+	if !ok {
+		return schema.TypeRef{}, errorf("unknown discriminator value %q", d.AsString())
+	}
+	return tr, nil
+}
+
+// missingListKeys returns an error for each key field of the list that
+// child, the map at index i, doesn't set.
+func missingListKeys(a value.Allocator, t *schema.List, i int, child value.Value) (errs ValidationErrors) {
+	if len(t.Keys) == 0 || !child.IsMap() {
+		return nil
+	}
+	m := child.AsMapUsing(a)
+	defer a.Free(m)
+	for _, key := range t.Keys {
+		if val, ok := m.Get(key); !ok || val.IsNull() {
+			errs = append(errs, errorf("element %v: missing key field %q", i, key)...)
+		}
 	}
 	return errs
 }
@@ -180,6 +377,9 @@ func (v *validatingObjectWalker) visitMapItems(t *schema.Map, m value.Map) (errs
 			errs = append(errs, errorf("field not declared in schema").WithPrefix(pe.String())...)
 			return false
 		}
+		if v.shallow {
+			return true
+		}
 		v2 := v.prepareDescent(tr)
 		v2.value = val
 		// Giving pe.String as a parameter actually increases the allocations.
@@ -200,6 +400,44 @@ func (v *validatingObjectWalker) doMap(t *schema.Map) (errs ValidationErrors) {
 	}
 	defer v.allocator.Free(m)
 	errs = v.visitMapItems(t, m)
+	errs = append(errs, validateFieldConstraints(t, m)...)
 
 	return errs
 }
+
+// validateFieldConstraints checks the dependencies and exclusive groups of
+// the fields of m.
+func validateFieldConstraints(t *schema.Map, m value.Map) (errs ValidationErrors) {
+	if len(t.Dependencies) == 0 && len(t.ExclusiveGroups) == 0 {
+		return nil
+	}
+	isSet := func(name string) bool {
+		v, ok := m.Get(name)
+		return ok && !v.IsNull()
+	}
+	for _, d := range t.Dependencies {
+		if !isSet(d.Field) {
+			continue
+		}
+		for _, required := range d.Requires {
+			if !isSet(required) {
+				errs = append(errs, errorf("requires field %q to be set", required).WithPrefix(fieldpath.PathElement{FieldName: &d.Field}.String())...)
+			}
+		}
+	}
+	for _, g := range t.ExclusiveGroups {
+		var set []string
+		for _, name := range g.Fields {
+			if isSet(name) {
+				set = append(set, name)
+			}
+		}
+		switch {
+		case len(set) > 1:
+			errs = append(errs, errorf("fields %q are mutually exclusive, at most one can be set", set)...)
+		case len(set) == 0 && g.Required:
+			errs = append(errs, errorf("one of fields %q must be set", g.Fields)...)
+		}
+	}
+	return errs
+}
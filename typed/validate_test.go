@@ -237,6 +237,133 @@ var validationCases = []validationTestCase{{
 	}, duplicatesObjects: []typed.YAMLObject{
 		`{"list":[{"key":"a","id":1},{"key":"a","id":1}]}`,
 	},
+}, {
+	name:         "field constraints",
+	rootTypeName: "volume",
+	schema: `types:
+- name: volume
+  map:
+    fields:
+    - name: secret
+      type:
+        scalar: string
+    - name: configMap
+      type:
+        scalar: string
+    - name: emptyDir
+      type:
+        scalar: boolean
+    - name: items
+      type:
+        scalar: string
+    - name: mode
+      type:
+        scalar: numeric
+    dependencies:
+    - field: items
+      requires:
+      - mode
+    exclusiveGroups:
+    - fields:
+      - secret
+      - configMap
+      - emptyDir
+      required: true
+`,
+	validObjects: []typed.YAMLObject{
+		`{"secret":"s"}`,
+		`{"configMap":"c","secret":null}`,
+		`{"emptyDir":true,"mode":1}`,
+		`{"secret":"s","items":"i","mode":1}`,
+	},
+	invalidObjects: []typed.YAMLObject{
+		`{}`,
+		`{"secret":null}`,
+		`{"secret":"s","configMap":"c"}`,
+		`{"secret":"s","items":"i"}`,
+		`{"secret":"s","items":"i","mode":null}`,
+	},
+}, {
+	name:         "scalar enums",
+	rootTypeName: "container",
+	schema: `types:
+- name: container
+  map:
+    fields:
+    - name: pullPolicy
+      type:
+        namedType: pullPolicy
+    - name: replicas
+      type:
+        scalar: numeric
+        enum: [1, 3, 5]
+    - name: ports
+      type:
+        list:
+          elementType:
+            scalar: string
+            enum: [tcp, udp]
+          elementRelationship: atomic
+- name: pullPolicy
+  scalar: string
+  enum: [Always, IfNotPresent, Never]
+`,
+	validObjects: []typed.YAMLObject{
+		`{}`,
+		`{"pullPolicy":"Always"}`,
+		`{"pullPolicy":null}`,
+		`{"replicas":3}`,
+		`{"replicas":5.0}`,
+		`{"ports":["tcp","udp"]}`,
+	},
+	invalidObjects: []typed.YAMLObject{
+		`{"pullPolicy":"always"}`,
+		`{"pullPolicy":""}`,
+		`{"replicas":2}`,
+		`{"replicas":"3"}`,
+		`{"ports":["tcp","sctp"]}`,
+	},
+}, {
+	name:         "dual types",
+	rootTypeName: "service",
+	schema: `types:
+- name: service
+  map:
+    fields:
+    - name: targetPort
+      type:
+        scalar: numericOrString
+    - name: selector
+      type:
+        scalar: string
+        map:
+          elementType:
+            scalar: string
+    - name: ports
+      type:
+        scalar: numeric
+        list:
+          elementType:
+            scalar: numeric
+          elementRelationship: associative
+`,
+	validObjects: []typed.YAMLObject{
+		`{"targetPort":8080}`,
+		`{"targetPort":"http"}`,
+		`{"targetPort":null}`,
+		`{"selector":"app=web"}`,
+		`{"selector":{"app":"web"}}`,
+		`{"ports":80}`,
+		`{"ports":[80,443]}`,
+	},
+	invalidObjects: []typed.YAMLObject{
+		`{"targetPort":true}`,
+		`{"targetPort":[8080]}`,
+		`{"selector":["app=web"]}`,
+		`{"selector":{"app":1}}`,
+		`{"ports":"80"}`,
+		`{"ports":{"http":80}}`,
+	},
 }}
 
 func (tt validationTestCase) test(t *testing.T) {
@@ -307,6 +434,165 @@ func TestSchemaSchema(t *testing.T) {
 	}
 }
 
+func TestFieldConstraintErrors(t *testing.T) {
+	for _, tt := range validationCases {
+		if tt.name != "field constraints" {
+			continue
+		}
+		parser, err := typed.NewParser(tt.schema)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = parser.Type(tt.rootTypeName).FromYAML(`{"secret":"s","configMap":"c","items":"i"}`)
+		expected := `errors:
+  fields ["secret" "configMap"] are mutually exclusive, at most one can be set
+  .items: requires field "mode" to be set`
+		if err == nil || err.Error() != expected {
+			t.Errorf("expected error:\n%v\ngot:\n%v", expected, err)
+		}
+	}
+}
+
+func TestScalarEnumErrors(t *testing.T) {
+	for _, tt := range validationCases {
+		if tt.name != "scalar enums" {
+			continue
+		}
+		parser, err := typed.NewParser(tt.schema)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := `errors:
+  .ports[1]: unsupported value "sctp", must be one of: "tcp", "udp"
+  .pullPolicy: unsupported value "Sometimes", must be one of: "Always", "IfNotPresent", "Never"`
+		// The errors are sorted, whatever the order of the fields.
+		for i := 0; i < 10; i++ {
+			_, err = parser.Type(tt.rootTypeName).FromYAML(`{"pullPolicy":"Sometimes","ports":["tcp","sctp"]}`)
+			if err == nil || err.Error() != expected {
+				t.Fatalf("expected error:\n%v\ngot:\n%v", expected, err)
+			}
+		}
+	}
+}
+
+func TestDualTypeErrors(t *testing.T) {
+	for _, tt := range validationCases {
+		if tt.name != "dual types" {
+			continue
+		}
+		parser, err := typed.NewParser(tt.schema)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for object, expected := range map[typed.YAMLObject]string{
+			`{"targetPort":true}`:      `.targetPort: expected numeric or string, got bool`,
+			`{"selector":["app=web"]}`: `.selector: expected string or map, got list`,
+			`{"ports":{"http":80}}`:    `.ports: expected numeric or list, got map`,
+		} {
+			_, err = parser.Type(tt.rootTypeName).FromYAML(object)
+			if err == nil || err.Error() != expected {
+				t.Errorf("expected error %q, got: %v", expected, err)
+			}
+		}
+	}
+}
+
+func TestStrictListKeys(t *testing.T) {
+	parser, err := typed.NewParser(`types:
+- name: root
+  map:
+    fields:
+    - name: ports
+      type:
+        list:
+          elementType:
+            namedType: port
+          elementRelationship: associative
+          keys: [port, protocol]
+- name: port
+  map:
+    fields:
+    - name: port
+      type:
+        scalar: numeric
+    - name: protocol
+      type:
+        scalar: string
+      default: TCP
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pt := parser.Type("root")
+
+	tests := []struct {
+		name     string
+		object   typed.YAMLObject
+		opts     []typed.ValidationOptions
+		expected []string
+	}{{
+		name:   "valid",
+		object: `{"ports": [{"port": 80, "protocol": "TCP"}, {"port": 80, "protocol": "UDP"}]}`,
+	}, {
+		name:   "defaulted key is accepted by default",
+		object: `{"ports": [{"port": 80}, {"port": 443}]}`,
+		opts:   []typed.ValidationOptions{},
+	}, {
+		name:   "defaulted key",
+		object: `{"ports": [{"port": 80}, {"port": 443, "protocol": "TCP"}]}`,
+		expected: []string{
+			`.ports: element 0: missing key field "protocol"`,
+		},
+	}, {
+		name: "every invalid item",
+		object: `{"ports": [
+			{"port": 80, "protocol": "TCP"},
+			{"protocol": "UDP"},
+			{"port": 80, "protocol": "TCP"},
+			{},
+			{"port": 80, "protocol": "TCP"},
+		]}`,
+		expected: []string{
+			`.ports: element 1: missing key field "port"`,
+			`.ports: element 2: duplicate entries for key [port=80,protocol="TCP"] (first at element 0)`,
+			`.ports: element 3: missing key field "port"`,
+			`.ports: element 3: missing key field "protocol"`,
+			`.ports: element 4: duplicate entries for key [port=80,protocol="TCP"] (first at element 0)`,
+		},
+	}, {
+		name:   "duplicates even if allowed",
+		object: `{"ports": [{"port": 80, "protocol": "TCP"}, {"port": 80, "protocol": "TCP"}]}`,
+		opts:   []typed.ValidationOptions{typed.AllowDuplicates, typed.StrictListKeys},
+		expected: []string{
+			`.ports: element 1: duplicate entries for key [port=80,protocol="TCP"] (first at element 0)`,
+		},
+	}}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			opts := tt.opts
+			if opts == nil {
+				opts = []typed.ValidationOptions{typed.StrictListKeys}
+			}
+			_, err := pt.FromYAML(tt.object, opts...)
+			var got []string
+			if err != nil {
+				errs, ok := err.(typed.ValidationErrors)
+				if !ok {
+					t.Fatalf("expected ValidationErrors, got %v", err)
+				}
+				for _, e := range errs {
+					got = append(got, e.Error())
+				}
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.expected, "\n") {
+				t.Errorf("expected:\n%v\ngot:\n%v", strings.Join(tt.expected, "\n"), strings.Join(got, "\n"))
+			}
+		})
+	}
+}
+
 func BenchmarkValidateStructured(b *testing.B) {
 	type Primitives struct {
 		s string
@@ -0,0 +1,151 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package typed
+
+import (
+	"sync"
+
+	"sigs.k8s.io/structured-merge-diff/v4/internal/lru"
+	"sigs.k8s.io/structured-merge-diff/v4/schema"
+	"sigs.k8s.io/structured-merge-diff/v4/value"
+)
+
+// ValidationCache remembers the maps and lists which are valid, by identity
+// (see value.IdentityOf), so that validating objects sharing their data
+// doesn't validate it again, e.g. the same live object converted for every
+// manager of an apply, or objects derived from it without deep copies. It
+// is safe for concurrent use; a nil ValidationCache caches nothing.
+//
+// The cached values are kept alive by the cache, and must not be modified.
+type ValidationCache struct {
+	lock    sync.Mutex
+	entries *lru.Cache
+	stats   ValidationCacheStats
+}
+
+// ValidationCacheStats are the statistics of a ValidationCache.
+type ValidationCacheStats struct {
+	// Hits is the number of maps and lists found valid in the cache.
+	Hits int64
+	// Misses is the number of maps and lists validated since they weren't
+	// in the cache.
+	Misses int64
+	// Evictions is the number of entries evicted to make room for others.
+	Evictions int64
+	// Entries is the current number of entries.
+	Entries int
+}
+
+// NewValidationCache returns a cache of at most maxEntries valid maps and
+// lists, or of an unbounded number of them if it isn't positive.
+func NewValidationCache(maxEntries int) *ValidationCache {
+	return &ValidationCache{entries: lru.New(maxEntries)}
+}
+
+// AsTyped is like the AsTyped function, but validates v using the cache.
+func (c *ValidationCache) AsTyped(v value.Value, s *schema.Schema, typeRef schema.TypeRef, opts ...ValidationOptions) (*TypedValue, error) {
+	tv := &TypedValue{
+		value:   v,
+		typeRef: typeRef,
+		schema:  s,
+	}
+	if err := c.Validate(tv, opts...); err != nil {
+		return nil, err
+	}
+	return tv, nil
+}
+
+// Validate is like TypedValue.Validate, but skips the maps and lists of tv
+// which are in the cache, and adds the valid ones to it.
+func (c *ValidationCache) Validate(tv *TypedValue, opts ...ValidationOptions) error {
+	return tv.validate(c, opts)
+}
+
+// Stats returns the statistics of the cache.
+func (c *ValidationCache) Stats() ValidationCacheStats {
+	if c == nil {
+		return ValidationCacheStats{}
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	stats := c.stats
+	stats.Entries = c.entries.Len()
+	return stats
+}
+
+// typeKey identifies a type of a schema.
+type typeKey struct {
+	schema              *schema.Schema
+	namedType           string
+	inlined             schema.Atom
+	elementRelationship schema.ElementRelationship
+}
+
+func keyOfType(s *schema.Schema, tr schema.TypeRef) typeKey {
+	key := typeKey{schema: s, inlined: tr.Inlined}
+	if tr.NamedType != nil {
+		key.namedType = *tr.NamedType
+	}
+	if tr.ElementRelationship != nil {
+		key.elementRelationship = *tr.ElementRelationship
+	}
+	return key
+}
+
+// validationKey identifies a map or list validated as a given type, with
+// given options.
+type validationKey struct {
+	typeKey
+	identity        value.Identity
+	allowDuplicates bool
+	strictListKeys  bool
+}
+
+// cacheKey returns the key of the value of v, or false if it has no
+// identity.
+func (v *validatingObjectWalker) cacheKey() (validationKey, bool) {
+	id, ok := value.IdentityOf(v.value)
+	if !ok {
+		return validationKey{}, false
+	}
+	return validationKey{
+		typeKey:         keyOfType(v.schema, v.typeRef),
+		identity:        id,
+		allowDuplicates: v.allowDuplicates,
+		strictListKeys:  v.strictListKeys,
+	}, true
+}
+
+// valid returns whether the value of key is known to be valid.
+func (c *ValidationCache) valid(key validationKey) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if _, ok := c.entries.Get(key); ok {
+		c.stats.Hits++
+		return true
+	}
+	c.stats.Misses++
+	return false
+}
+
+// add records that v, the value of key, is valid. The cache keeps the
+// referent of v rather than v, which may be freed by an allocator.
+func (c *ValidationCache) add(key validationKey, v value.Value) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.stats.Evictions += int64(c.entries.Add(key, value.Referent(v)))
+}
@@ -0,0 +1,119 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package typed_test
+
+import (
+	"runtime"
+	"testing"
+
+	"sigs.k8s.io/structured-merge-diff/v4/typed"
+	"sigs.k8s.io/structured-merge-diff/v4/value"
+)
+
+func TestValidationCache(t *testing.T) {
+	pt := overlayParser.Type("service")
+	ports := []interface{}{
+		map[string]interface{}{"name": "http", "port": 80},
+		map[string]interface{}{"name": "https", "port": 443},
+	}
+	labels := map[string]interface{}{"app": "web"}
+	first := map[string]interface{}{"name": "a", "ports": ports, "labels": labels}
+	// second shares its ports and labels with first.
+	second := map[string]interface{}{"name": "b", "ports": ports, "labels": labels}
+	invalid := map[string]interface{}{"name": "c", "ports": ports, "labels": map[string]interface{}{"app": 1}}
+
+	cache := typed.NewValidationCache(0)
+	validate := func(object map[string]interface{}, valid bool, expected typed.ValidationCacheStats) {
+		t.Helper()
+		_, err := cache.AsTyped(value.NewValueInterface(object), pt.Schema, pt.TypeRef)
+		if valid && err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !valid && err == nil {
+			t.Fatalf("expected %v to be invalid", object)
+		}
+		if stats := cache.Stats(); stats != expected {
+			t.Fatalf("expected stats %+v, got %+v", expected, stats)
+		}
+	}
+	// The object, ports, their two items and labels.
+	validate(first, true, typed.ValidationCacheStats{Misses: 5, Entries: 5})
+	validate(first, true, typed.ValidationCacheStats{Hits: 1, Misses: 5, Entries: 5})
+	validate(second, true, typed.ValidationCacheStats{Hits: 3, Misses: 6, Entries: 6})
+	// Invalid maps aren't cached, nor are the maps containing them.
+	validate(invalid, false, typed.ValidationCacheStats{Hits: 4, Misses: 8, Entries: 6})
+	validate(invalid, false, typed.ValidationCacheStats{Hits: 5, Misses: 10, Entries: 6})
+
+	// Options are part of the keys.
+	if err := cache.Validate(mustAsTyped(t, pt, first), typed.AllowDuplicates); err != nil {
+		t.Fatal(err)
+	}
+	if stats := cache.Stats(); stats.Hits != 5 {
+		t.Errorf("expected no hit with other options, got %+v", stats)
+	}
+}
+
+func TestValidationCacheEvictions(t *testing.T) {
+	pt := overlayParser.Type("service")
+	cache := typed.NewValidationCache(2)
+	for i := 0; i < 3; i++ {
+		object := map[string]interface{}{"labels": map[string]interface{}{"app": "web"}}
+		if _, err := cache.AsTyped(value.NewValueInterface(object), pt.Schema, pt.TypeRef); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expected := typed.ValidationCacheStats{Misses: 6, Evictions: 4, Entries: 2}
+	if stats := cache.Stats(); stats != expected {
+		t.Errorf("expected stats %+v, got %+v", expected, stats)
+	}
+	var nilCache *typed.ValidationCache
+	if _, err := nilCache.AsTyped(value.NewValueInterface(map[string]interface{}{}), pt.Schema, pt.TypeRef); err != nil {
+		t.Fatal(err)
+	}
+	if stats := nilCache.Stats(); stats != (typed.ValidationCacheStats{}) {
+		t.Errorf("expected no stats for a nil cache, got %+v", stats)
+	}
+}
+
+func TestValidationCacheKeepsValuesAlive(t *testing.T) {
+	pt := overlayParser.Type("service")
+	cache := typed.NewValidationCache(0)
+	for i := 0; i < 100; i++ {
+		// The labels are valid and cached, but not the object keeping them,
+		// and they are visited through a wrapper that the allocator reuses:
+		// the cache must keep the labels themselves.
+		object := map[string]interface{}{"name": 1, "labels": map[string]interface{}{"app": "web"}}
+		if _, err := cache.AsTyped(value.NewValueInterface(object), pt.Schema, pt.TypeRef); err == nil {
+			t.Fatalf("expected %v to be invalid", object)
+		}
+	}
+	runtime.GC()
+	for i := 0; i < 100; i++ {
+		invalid := map[string]interface{}{"labels": map[string]interface{}{"app": 1}}
+		if _, err := cache.AsTyped(value.NewValueInterface(invalid), pt.Schema, pt.TypeRef); err == nil {
+			t.Fatalf("expected %v to be invalid", invalid)
+		}
+	}
+}
+
+func mustAsTyped(t *testing.T, pt typed.ParseableType, object map[string]interface{}) *typed.TypedValue {
+	tv, err := typed.AsTyped(value.NewValueInterface(object), pt.Schema, pt.TypeRef)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tv
+}
@@ -0,0 +1,97 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package typed
+
+import (
+	"sync"
+
+	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
+	"sigs.k8s.io/structured-merge-diff/v4/internal/lru"
+	"sigs.k8s.io/structured-merge-diff/v4/schema"
+)
+
+// KubernetesVolatileFields are the fields of the metadata of Kubernetes
+// objects which are maintained by the API server, for schemas which don't
+// mark them volatile. Give them to WithIgnoredFields or to the Ignored
+// fields of merge.UpdaterBuilder to treat them as volatile.
+var KubernetesVolatileFields = fieldpath.NewSet(
+	fieldpath.MakePathOrDie("metadata", "creationTimestamp"),
+	fieldpath.MakePathOrDie("metadata", "generation"),
+	fieldpath.MakePathOrDie("metadata", "resourceVersion"),
+	fieldpath.MakePathOrDie("metadata", "uid"),
+	fieldpath.MakePathOrDie("metadata", "managedFields"),
+)
+
+// VolatileFields returns the paths of the fields of tv's type which are
+// marked volatile in the schema (see schema.StructField.Volatile), and are
+// so left out of comparisons, and of the sets of the managers of a
+// merge.Updater. Only the fields nested in maps have a path: volatile
+// fields of the items of lists are ignored. The set is shared and must not
+// be modified.
+func (tv *TypedValue) VolatileFields() *fieldpath.Set {
+	if tv.schema == nil {
+		return fieldpath.NewSet()
+	}
+	key := keyOfType(tv.schema, tv.typeRef)
+	volatileCache.Lock()
+	set, ok := volatileCache.fields.Get(key)
+	volatileCache.Unlock()
+	if ok {
+		return set.(*fieldpath.Set)
+	}
+	fields := volatileFields(tv.schema, tv.typeRef)
+	volatileCache.Lock()
+	volatileCache.fields.Add(key, fields)
+	volatileCache.Unlock()
+	return fields
+}
+
+// volatileCache holds the volatile fields of the most recently compared
+// types, which are walked once.
+var volatileCache = struct {
+	sync.Mutex
+	fields *lru.Cache
+}{fields: lru.New(1024)}
+
+func volatileFields(s *schema.Schema, tr schema.TypeRef) *fieldpath.Set {
+	fields := fieldpath.NewSet()
+	var walk func(prefix fieldpath.Path, tr schema.TypeRef, visiting map[string]bool)
+	walk = func(prefix fieldpath.Path, tr schema.TypeRef, visiting map[string]bool) {
+		if tr.NamedType != nil {
+			if visiting[*tr.NamedType] {
+				return
+			}
+			visiting[*tr.NamedType] = true
+			defer delete(visiting, *tr.NamedType)
+		}
+		a, ok := s.Resolve(tr)
+		if !ok || a.Map == nil {
+			return
+		}
+		for _, f := range a.Map.Fields {
+			name := f.Name
+			path := append(prefix.Copy(), fieldpath.PathElement{FieldName: &name})
+			if f.Volatile {
+				fields.Insert(path)
+				continue
+			}
+			walk(path, f.Type, visiting)
+		}
+	}
+	walk(nil, tr, map[string]bool{})
+	return fields
+}
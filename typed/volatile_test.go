@@ -0,0 +1,109 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package typed_test
+
+import (
+	"testing"
+
+	"sigs.k8s.io/structured-merge-diff/v4/typed"
+)
+
+var volatileParser = func() *typed.Parser {
+	parser, err := typed.NewParser(`types:
+- name: object
+  map:
+    fields:
+    - name: metadata
+      type:
+        namedType: objectMeta
+    - name: replicas
+      type:
+        scalar: numeric
+    - name: items
+      type:
+        list:
+          elementType:
+            namedType: objectMeta
+          elementRelationship: atomic
+- name: objectMeta
+  map:
+    fields:
+    - name: name
+      type:
+        scalar: string
+    - name: creationTimestamp
+      type:
+        scalar: string
+      volatile: true
+    - name: generation
+      type:
+        scalar: numeric
+      volatile: true
+`)
+	if err != nil {
+		panic(err)
+	}
+	return parser
+}()
+
+func TestVolatileFields(t *testing.T) {
+	pt := volatileParser.Type("object")
+	lhs, err := pt.FromYAML(`{"metadata": {"name": "a", "creationTimestamp": "2024-01-01T00:00:00Z", "generation": 1}, "replicas": 1}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := _NS(_P("metadata", "creationTimestamp"), _P("metadata", "generation"))
+	if got := lhs.VolatileFields(); !got.Equals(want) {
+		t.Errorf("expected volatile fields %v, got %v", want, got)
+	}
+
+	tests := []struct {
+		name  string
+		rhs   typed.YAMLObject
+		empty bool
+	}{{
+		name:  "volatile fields changed",
+		rhs:   `{"metadata": {"name": "a", "creationTimestamp": "2024-02-01T00:00:00Z", "generation": 2}, "replicas": 1}`,
+		empty: true,
+	}, {
+		name:  "volatile fields removed",
+		rhs:   `{"metadata": {"name": "a"}, "replicas": 1}`,
+		empty: true,
+	}, {
+		name: "other field changed",
+		rhs:  `{"metadata": {"name": "a", "generation": 2}, "replicas": 2}`,
+	}}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			rhs, err := pt.FromYAML(tt.rhs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			c, err := lhs.Compare(rhs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.IsSame() != tt.empty {
+				t.Errorf("expected same to be %v, got %v", tt.empty, c)
+			}
+			if c.Modified.Has(_P("metadata", "generation")) {
+				t.Errorf("expected the generation to be ignored, got %v", c)
+			}
+		})
+	}
+}
@@ -0,0 +1,239 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package typed
+
+import (
+	"bytes"
+	"fmt"
+
+	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
+	"sigs.k8s.io/structured-merge-diff/v4/internal/bytesconv"
+	yamlv3 "sigs.k8s.io/yaml/goyaml.v3"
+)
+
+// maxYAMLAliasExpansion is the maximum number of nodes that aliases (and
+// merge keys, which are usually aliases) can add to a YAML document once
+// expanded. This protects against documents that are small but expand
+// exponentially ("billion laughs").
+const maxYAMLAliasExpansion = 100000
+
+// checkYAMLAliases enforces the policy for YAML anchors, aliases and merge
+// keys (`<<`), before the document is decoded. If rejectAliases is set, any
+// of them is an error. Otherwise they are expanded when decoding, and this
+// checks that they don't reference themselves and don't expand past
+// maxYAMLAliasExpansion nodes.
+func checkYAMLAliases(object []byte, rejectAliases bool) error {
+	// Documents without anchors or merge keys, which is most of them,
+	// don't need to be parsed twice.
+	if !bytes.ContainsAny(object, "&*") && !bytes.Contains(object, []byte("<<")) {
+		return nil
+	}
+	var doc yamlv3.Node
+	if err := yamlv3.Unmarshal(object, &doc); err != nil {
+		// Let the decoder report the syntax errors.
+		return nil
+	}
+	c := yamlAliasChecker{
+		rejectAliases: rejectAliases,
+		sizes:         map[*yamlv3.Node]int{},
+		expanding:     map[*yamlv3.Node]bool{},
+	}
+	_, err := c.check(&doc)
+	return err
+}
+
+type yamlAliasChecker struct {
+	rejectAliases bool
+	// expanded is the number of nodes added by expanding aliases so far.
+	expanded int
+	// sizes memoizes the number of nodes of the anchored nodes, so that
+	// aliases aren't walked again every time they are referenced.
+	sizes map[*yamlv3.Node]int
+	// expanding contains the anchored nodes being walked, to detect cycles.
+	expanding map[*yamlv3.Node]bool
+}
+
+// check returns the number of nodes of n, once its aliases are expanded.
+func (c *yamlAliasChecker) check(n *yamlv3.Node) (int, error) {
+	if c.rejectAliases {
+		switch {
+		case n.Kind == yamlv3.AliasNode:
+			return 0, fmt.Errorf("line %d: YAML aliases are not allowed (alias *%v)", n.Line, n.Value)
+		case n.Anchor != "":
+			return 0, fmt.Errorf("line %d: YAML anchors are not allowed (anchor &%v)", n.Line, n.Anchor)
+		}
+	}
+	if n.Kind == yamlv3.AliasNode {
+		return c.checkAlias(n)
+	}
+	if n.Anchor != "" {
+		if size, ok := c.sizes[n]; ok {
+			return size, nil
+		}
+		c.expanding[n] = true
+		defer delete(c.expanding, n)
+	}
+	size := 1
+	for i, child := range n.Content {
+		if c.rejectAliases && n.Kind == yamlv3.MappingNode && i%2 == 0 && isYAMLMergeKey(child) {
+			return 0, fmt.Errorf("line %d: YAML merge keys are not allowed", child.Line)
+		}
+		childSize, err := c.check(child)
+		if err != nil {
+			return 0, err
+		}
+		size += childSize
+	}
+	if n.Anchor != "" {
+		c.sizes[n] = size
+	}
+	return size, nil
+}
+
+func (c *yamlAliasChecker) checkAlias(n *yamlv3.Node) (int, error) {
+	if n.Alias == nil {
+		return 0, fmt.Errorf("line %d: unknown YAML anchor %q", n.Line, n.Value)
+	}
+	if c.expanding[n.Alias] {
+		return 0, fmt.Errorf("line %d: YAML alias *%v references itself", n.Line, n.Value)
+	}
+	size, err := c.check(n.Alias)
+	if err != nil {
+		return 0, err
+	}
+	c.expanded += size
+	if c.expanded > maxYAMLAliasExpansion {
+		return 0, fmt.Errorf("line %d: YAML aliases expand to more than %d nodes", n.Line, maxYAMLAliasExpansion)
+	}
+	return size, nil
+}
+
+func isYAMLMergeKey(n *yamlv3.Node) bool {
+	return n.Kind == yamlv3.ScalarNode && n.Value == "<<" && (n.Tag == "" || n.Tag == "!" || n.ShortTag() == "!!merge")
+}
+
+// DuplicateYAMLKeys returns the path of every key that is repeated in a map
+// of object, which can be either YAML or JSON. FromYAML otherwise silently
+// keeps the last value, unless RejectDuplicateKeys is given. Items of lists
+// are identified by their index, and aliases aren't followed: duplicates in
+// an anchored map are reported under the anchor.
+func DuplicateYAMLKeys(object YAMLObject) ([]fieldpath.Path, error) {
+	var doc yamlv3.Node
+	if err := yamlv3.Unmarshal(bytesconv.Bytes(string(object)), &doc); err != nil {
+		return nil, err
+	}
+	var duplicates []fieldpath.Path
+	findDuplicateYAMLKeys(&doc, fieldpath.Path{}, &duplicates)
+	return duplicates, nil
+}
+
+func findDuplicateYAMLKeys(n *yamlv3.Node, path fieldpath.Path, duplicates *[]fieldpath.Path) {
+	switch n.Kind {
+	case yamlv3.DocumentNode:
+		for _, child := range n.Content {
+			findDuplicateYAMLKeys(child, path, duplicates)
+		}
+	case yamlv3.SequenceNode:
+		for i, child := range n.Content {
+			i := i
+			findDuplicateYAMLKeys(child, append(path, fieldpath.PathElement{Index: &i}), duplicates)
+		}
+	case yamlv3.MappingNode:
+		type key struct{ tag, value string }
+		seen := map[key]bool{}
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			k, v := n.Content[i], n.Content[i+1]
+			if k.Kind != yamlv3.ScalarNode || isYAMLMergeKey(k) {
+				continue
+			}
+			name := k.Value
+			childPath := append(path, fieldpath.PathElement{FieldName: &name})
+			if seen[key{k.ShortTag(), k.Value}] {
+				*duplicates = append(*duplicates, childPath.Copy())
+			}
+			seen[key{k.ShortTag(), k.Value}] = true
+			findDuplicateYAMLKeys(v, childPath, duplicates)
+		}
+	}
+}
+
+// rejectDuplicateYAMLKeys fails if object has duplicate keys.
+func rejectDuplicateYAMLKeys(object YAMLObject) error {
+	duplicates, err := DuplicateYAMLKeys(object)
+	if err != nil {
+		// Let the decoder report the syntax errors.
+		return nil
+	}
+	var errs ValidationErrors
+	for _, path := range duplicates {
+		errs = append(errs, ValidationError{Path: path.String(), ErrorMessage: "duplicate key"})
+	}
+	if len(errs) != 0 {
+		return errs
+	}
+	return nil
+}
+
+// stringifyYAMLKeys replaces the scalar keys of the maps of v which aren't
+// strings, e.g. `1: a` or `on: b`, with their string form, like converting
+// YAML to JSON does. The values ignore the keys which aren't strings, and
+// would otherwise drop them silently. Keys which are maps or lists, or
+// which collide with another key once converted, are errors.
+func stringifyYAMLKeys(v interface{}, path fieldpath.Path) error {
+	switch v := v.(type) {
+	case []interface{}:
+		for i := range v {
+			index := i
+			if err := stringifyYAMLKeys(v[i], append(path[:len(path):len(path)], fieldpath.PathElement{Index: &index})); err != nil {
+				return err
+			}
+		}
+	case map[interface{}]interface{}:
+		var converted []interface{}
+		for k, child := range v {
+			name, ok := k.(string)
+			if !ok {
+				converted = append(converted, k)
+				continue
+			}
+			if err := stringifyYAMLKeys(child, append(path[:len(path):len(path)], fieldpath.PathElement{FieldName: &name})); err != nil {
+				return err
+			}
+		}
+		for _, k := range converted {
+			var name string
+			switch k := k.(type) {
+			case nil:
+				name = "null"
+			case bool, int, int64, uint64, float64:
+				name = fmt.Sprint(k)
+			default:
+				return ValidationErrors{{Path: path.String(), ErrorMessage: fmt.Sprintf("invalid key %v, keys must be scalars", k)}}
+			}
+			if _, ok := v[name]; ok {
+				return ValidationErrors{{Path: path.String(), ErrorMessage: fmt.Sprintf("duplicate key %q", name)}}
+			}
+			child := v[k]
+			delete(v, k)
+			v[name] = child
+			if err := stringifyYAMLKeys(child, append(path[:len(path):len(path)], fieldpath.PathElement{FieldName: &name})); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
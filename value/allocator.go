@@ -31,11 +31,7 @@ type Allocator interface {
 	// to request what they need from the allocator.
 	allocValueUnstructured() *valueUnstructured
 	allocListUnstructuredRange() *listUnstructuredRange
-	allocValueReflect() *valueReflect
-	allocMapReflect() *mapReflect
-	allocStructReflect() *structReflect
-	allocListReflect() *listReflect
-	allocListReflectRange() *listReflectRange
+	reflectAllocator
 }
 
 // HeapAllocator simply allocates objects to the heap. It is the default
@@ -54,26 +50,6 @@ func (p *heapAllocator) allocListUnstructuredRange() *listUnstructuredRange {
 	return &listUnstructuredRange{vv: &valueUnstructured{}}
 }
 
-func (p *heapAllocator) allocValueReflect() *valueReflect {
-	return &valueReflect{}
-}
-
-func (p *heapAllocator) allocStructReflect() *structReflect {
-	return &structReflect{}
-}
-
-func (p *heapAllocator) allocMapReflect() *mapReflect {
-	return &mapReflect{}
-}
-
-func (p *heapAllocator) allocListReflect() *listReflect {
-	return &listReflect{}
-}
-
-func (p *heapAllocator) allocListReflectRange() *listReflectRange {
-	return &listReflectRange{vr: &valueReflect{}}
-}
-
 func (p *heapAllocator) Free(_ interface{}) {}
 
 // NewFreelistAllocator creates freelist based allocator.
@@ -95,21 +71,7 @@ func NewFreelistAllocator() Allocator {
 		listUnstructuredRange: &freelist{new: func() interface{} {
 			return &listUnstructuredRange{vv: &valueUnstructured{}}
 		}},
-		valueReflect: &freelist{new: func() interface{} {
-			return &valueReflect{}
-		}},
-		mapReflect: &freelist{new: func() interface{} {
-			return &mapReflect{}
-		}},
-		structReflect: &freelist{new: func() interface{} {
-			return &structReflect{}
-		}},
-		listReflect: &freelist{new: func() interface{} {
-			return &listReflect{}
-		}},
-		listReflectRange: &freelist{new: func() interface{} {
-			return &listReflectRange{vr: &valueReflect{}}
-		}},
+		reflectFreelists: newReflectFreelists(),
 	}
 }
 
@@ -121,11 +83,7 @@ const freelistMaxSize = 1000
 type freelistAllocator struct {
 	valueUnstructured     *freelist
 	listUnstructuredRange *freelist
-	valueReflect          *freelist
-	mapReflect            *freelist
-	structReflect         *freelist
-	listReflect           *freelist
-	listReflectRange      *freelist
+	reflectFreelists
 }
 
 type freelist struct {
@@ -157,20 +115,8 @@ func (w *freelistAllocator) Free(value interface{}) {
 	case *listUnstructuredRange:
 		v.vv.Value = nil // don't hold references to unstructured objects
 		w.listUnstructuredRange.free(v)
-	case *valueReflect:
-		v.ParentMapKey = nil
-		v.ParentMap = nil
-		w.valueReflect.free(v)
-	case *mapReflect:
-		w.mapReflect.free(v)
-	case *structReflect:
-		w.structReflect.free(v)
-	case *listReflect:
-		w.listReflect.free(v)
-	case *listReflectRange:
-		v.vr.ParentMapKey = nil
-		v.vr.ParentMap = nil
-		w.listReflectRange.free(v)
+	default:
+		w.freeReflect(value)
 	}
 }
 
@@ -181,23 +127,3 @@ func (w *freelistAllocator) allocValueUnstructured() *valueUnstructured {
 func (w *freelistAllocator) allocListUnstructuredRange() *listUnstructuredRange {
 	return w.listUnstructuredRange.allocate().(*listUnstructuredRange)
 }
-
-func (w *freelistAllocator) allocValueReflect() *valueReflect {
-	return w.valueReflect.allocate().(*valueReflect)
-}
-
-func (w *freelistAllocator) allocStructReflect() *structReflect {
-	return w.structReflect.allocate().(*structReflect)
-}
-
-func (w *freelistAllocator) allocMapReflect() *mapReflect {
-	return w.mapReflect.allocate().(*mapReflect)
-}
-
-func (w *freelistAllocator) allocListReflect() *listReflect {
-	return w.listReflect.allocate().(*listReflect)
-}
-
-func (w *freelistAllocator) allocListReflectRange() *listReflectRange {
-	return w.listReflectRange.allocate().(*listReflectRange)
-}
@@ -0,0 +1,32 @@
+//go:build smd_noreflect
+// +build smd_noreflect
+
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package value
+
+// When built with the smd_noreflect tag, the reflection backed Value
+// implementation is left out and there is nothing more to allocate.
+type reflectAllocator interface{}
+
+type reflectFreelists struct{}
+
+func newReflectFreelists() reflectFreelists {
+	return reflectFreelists{}
+}
+
+func (w *freelistAllocator) freeReflect(value interface{}) {}
@@ -0,0 +1,319 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package value
+
+import "fmt"
+
+// Kind is the kind of a value.
+type Kind int
+
+const (
+	NullKind Kind = iota
+	BoolKind
+	IntKind
+	FloatKind
+	StringKind
+	ListKind
+	MapKind
+)
+
+func (k Kind) String() string {
+	switch k {
+	case NullKind:
+		return "null"
+	case BoolKind:
+		return "bool"
+	case IntKind:
+		return "int"
+	case FloatKind:
+		return "float"
+	case StringKind:
+		return "string"
+	case ListKind:
+		return "list"
+	case MapKind:
+		return "map"
+	}
+	return fmt.Sprintf("Kind(%d)", int(k))
+}
+
+// KindOf returns the kind of v, whatever its implementation. A nil Value is
+// null.
+func KindOf(v Value) Kind {
+	switch {
+	case v == nil || v.IsNull():
+		return NullKind
+	case v.IsMap():
+		return MapKind
+	case v.IsList():
+		return ListKind
+	case v.IsString():
+		return StringKind
+	case v.IsInt():
+		return IntKind
+	case v.IsFloat():
+		return FloatKind
+	case v.IsBool():
+		return BoolKind
+	}
+	return NullKind
+}
+
+// Backend is the minimal set of methods needed to provide a new
+// representation of values, e.g. backed by undecoded JSON or by protobuf
+// messages, in addition to the unstructured (NewValueInterface) and
+// reflection (NewValueReflect) ones. FromBackend turns a Backend into a
+// Value, so that implementations don't depend on the full Value, Map and
+// List interfaces, which may grow.
+type Backend interface {
+	// Kind returns the kind of the node.
+	Kind() Kind
+	// Scalar returns the bool, int64, float64 or string held by a scalar
+	// node, or nil for a null node.
+	Scalar() interface{}
+	// Length returns the number of items of a list, or of fields of a map.
+	Length() int
+	// Item returns the item at index i of a list.
+	Item(i int) Backend
+	// Field returns the field key of a map, if it is set.
+	Field(key string) (Backend, bool)
+	// Fields calls fn for each field of a map, until it returns false. It
+	// returns false if fn did.
+	Fields(fn func(key string, value Backend) bool) bool
+}
+
+// MutableBackend is implemented by Backends whose maps can be modified. The
+// maps of other Backends panic when modified.
+type MutableBackend interface {
+	Backend
+	// SetField sets the field key of a map to value.
+	SetField(key string, value Value)
+	// DeleteField removes the field key of a map.
+	DeleteField(key string)
+}
+
+// FromBackend returns a Value reading b.
+func FromBackend(b Backend) Value {
+	return backendValue{b}
+}
+
+type backendValue struct {
+	b Backend
+}
+
+func (v backendValue) IsMap() bool    { return v.b.Kind() == MapKind }
+func (v backendValue) IsList() bool   { return v.b.Kind() == ListKind }
+func (v backendValue) IsBool() bool   { return v.b.Kind() == BoolKind }
+func (v backendValue) IsInt() bool    { return v.b.Kind() == IntKind }
+func (v backendValue) IsFloat() bool  { return v.b.Kind() == FloatKind }
+func (v backendValue) IsString() bool { return v.b.Kind() == StringKind }
+func (v backendValue) IsNull() bool   { return v.b.Kind() == NullKind }
+
+func (v backendValue) AsMap() Map {
+	return v.AsMapUsing(HeapAllocator)
+}
+
+func (v backendValue) AsMapUsing(Allocator) Map {
+	if !v.IsMap() {
+		panic("value is not a map")
+	}
+	return backendMap{v.b}
+}
+
+func (v backendValue) AsList() List {
+	return v.AsListUsing(HeapAllocator)
+}
+
+func (v backendValue) AsListUsing(Allocator) List {
+	if !v.IsList() {
+		panic("value is not a list")
+	}
+	return backendList{v.b}
+}
+
+func (v backendValue) AsBool() bool {
+	if !v.IsBool() {
+		panic("value is not a bool")
+	}
+	return v.b.Scalar().(bool)
+}
+
+func (v backendValue) AsInt() int64 {
+	if !v.IsInt() {
+		panic("value is not an int")
+	}
+	return v.b.Scalar().(int64)
+}
+
+func (v backendValue) AsFloat() float64 {
+	if !v.IsFloat() {
+		panic("value is not a float")
+	}
+	return v.b.Scalar().(float64)
+}
+
+func (v backendValue) AsString() string {
+	if !v.IsString() {
+		panic("value is not a string")
+	}
+	return v.b.Scalar().(string)
+}
+
+func (v backendValue) Unstructured() interface{} {
+	switch v.b.Kind() {
+	case MapKind:
+		m := make(map[string]interface{}, v.b.Length())
+		v.b.Fields(func(key string, child Backend) bool {
+			m[key] = backendValue{child}.Unstructured()
+			return true
+		})
+		return m
+	case ListKind:
+		l := make([]interface{}, v.b.Length())
+		for i := range l {
+			l[i] = backendValue{v.b.Item(i)}.Unstructured()
+		}
+		return l
+	}
+	return v.b.Scalar()
+}
+
+type backendMap struct {
+	b Backend
+}
+
+func (m backendMap) mutable() MutableBackend {
+	mb, ok := m.b.(MutableBackend)
+	if !ok {
+		panic("map is read-only")
+	}
+	return mb
+}
+
+func (m backendMap) Set(key string, val Value) {
+	m.mutable().SetField(key, val)
+}
+
+func (m backendMap) Delete(key string) {
+	m.mutable().DeleteField(key)
+}
+
+func (m backendMap) Get(key string) (Value, bool) {
+	child, ok := m.b.Field(key)
+	if !ok {
+		return nil, false
+	}
+	return backendValue{child}, true
+}
+
+func (m backendMap) GetUsing(_ Allocator, key string) (Value, bool) {
+	return m.Get(key)
+}
+
+func (m backendMap) Has(key string) bool {
+	_, ok := m.b.Field(key)
+	return ok
+}
+
+func (m backendMap) Equals(other Map) bool {
+	return MapEqualsUsing(HeapAllocator, m, other)
+}
+
+func (m backendMap) EqualsUsing(a Allocator, other Map) bool {
+	return MapEqualsUsing(a, m, other)
+}
+
+func (m backendMap) Iterate(fn func(key string, value Value) bool) bool {
+	return m.b.Fields(func(key string, child Backend) bool {
+		return fn(key, backendValue{child})
+	})
+}
+
+func (m backendMap) IterateUsing(_ Allocator, fn func(key string, value Value) bool) bool {
+	return m.Iterate(fn)
+}
+
+func (m backendMap) Length() int {
+	return m.b.Length()
+}
+
+func (m backendMap) Empty() bool {
+	return m.b.Length() == 0
+}
+
+func (m backendMap) Zip(other Map, order MapTraverseOrder, fn func(key string, lhs, rhs Value) bool) bool {
+	return defaultMapZip(HeapAllocator, m, other, order, fn)
+}
+
+func (m backendMap) ZipUsing(a Allocator, other Map, order MapTraverseOrder, fn func(key string, lhs, rhs Value) bool) bool {
+	return defaultMapZip(a, m, other, order, fn)
+}
+
+type backendList struct {
+	b Backend
+}
+
+func (l backendList) Length() int {
+	return l.b.Length()
+}
+
+func (l backendList) At(i int) Value {
+	return backendValue{l.b.Item(i)}
+}
+
+func (l backendList) AtUsing(_ Allocator, i int) Value {
+	return l.At(i)
+}
+
+func (l backendList) Range() ListRange {
+	if l.b.Length() == 0 {
+		return EmptyRange
+	}
+	return &backendListRange{list: l, i: -1}
+}
+
+func (l backendList) RangeUsing(Allocator) ListRange {
+	return l.Range()
+}
+
+func (l backendList) Equals(other List) bool {
+	return ListEqualsUsing(HeapAllocator, l, other)
+}
+
+func (l backendList) EqualsUsing(a Allocator, other List) bool {
+	return ListEqualsUsing(a, l, other)
+}
+
+type backendListRange struct {
+	list backendList
+	i    int
+}
+
+func (r *backendListRange) Next() bool {
+	r.i++
+	return r.i < r.list.Length()
+}
+
+func (r *backendListRange) Item() (index int, value Value) {
+	if r.i < 0 {
+		panic("Item() called before first calling Next()")
+	}
+	if r.i >= r.list.Length() {
+		panic("Item() called on ListRange with no more items")
+	}
+	return r.i, r.list.At(r.i)
+}
@@ -0,0 +1,154 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package value_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"testing"
+
+	"sigs.k8s.io/structured-merge-diff/v4/value"
+)
+
+// jsonBackend is a Backend which decodes JSON lazily, one level at a time.
+type jsonBackend json.RawMessage
+
+func (b jsonBackend) Kind() value.Kind {
+	raw := bytes.TrimSpace(b)
+	switch raw[0] {
+	case '{':
+		return value.MapKind
+	case '[':
+		return value.ListKind
+	case '"':
+		return value.StringKind
+	case 't', 'f':
+		return value.BoolKind
+	case 'n':
+		return value.NullKind
+	}
+	if bytes.ContainsAny(raw, ".eE") {
+		return value.FloatKind
+	}
+	return value.IntKind
+}
+
+func (b jsonBackend) Scalar() interface{} {
+	raw := bytes.TrimSpace(b)
+	switch b.Kind() {
+	case value.IntKind:
+		i, _ := strconv.ParseInt(string(raw), 10, 64)
+		return i
+	case value.FloatKind:
+		f, _ := strconv.ParseFloat(string(raw), 64)
+		return f
+	}
+	var v interface{}
+	json.Unmarshal(raw, &v)
+	return v
+}
+
+func (b jsonBackend) items() []json.RawMessage {
+	var items []json.RawMessage
+	json.Unmarshal(b, &items)
+	return items
+}
+
+func (b jsonBackend) fields() map[string]json.RawMessage {
+	var fields map[string]json.RawMessage
+	json.Unmarshal(b, &fields)
+	return fields
+}
+
+func (b jsonBackend) Length() int {
+	if b.Kind() == value.ListKind {
+		return len(b.items())
+	}
+	return len(b.fields())
+}
+
+func (b jsonBackend) Item(i int) value.Backend {
+	return jsonBackend(b.items()[i])
+}
+
+func (b jsonBackend) Field(key string) (value.Backend, bool) {
+	raw, ok := b.fields()[key]
+	return jsonBackend(raw), ok
+}
+
+func (b jsonBackend) Fields(fn func(key string, value value.Backend) bool) bool {
+	fields := b.fields()
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if !fn(key, jsonBackend(fields[key])) {
+			return false
+		}
+	}
+	return true
+}
+
+func TestFromBackend(t *testing.T) {
+	for _, tt := range []struct {
+		doc  string
+		kind value.Kind
+	}{
+		{`null`, value.NullKind},
+		{`true`, value.BoolKind},
+		{`-3`, value.IntKind},
+		{`2.5`, value.FloatKind},
+		{`"s"`, value.StringKind},
+		{`[]`, value.ListKind},
+		{`{}`, value.MapKind},
+		{`{"a": [1, 2.5, "x", null, {"b": false}], "c": {"d": {}}}`, value.MapKind},
+	} {
+		doc := tt.doc
+		t.Run(doc, func(t *testing.T) {
+			expected, err := value.FromJSON([]byte(doc))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := value.FromBackend(jsonBackend(doc))
+			if value.KindOf(got) != tt.kind {
+				t.Errorf("expected kind %v, got %v", tt.kind, value.KindOf(got))
+			}
+			if !value.Equals(got, expected) || !value.Equals(expected, got) {
+				t.Errorf("expected %v, got %v", value.ToString(expected), value.ToString(got))
+			}
+			if c := value.Compare(got, expected); c != 0 {
+				t.Errorf("expected values to compare equal, got %v", c)
+			}
+			if u := value.NewValueInterface(got.Unstructured()); !value.Equals(u, expected) {
+				t.Errorf("expected unstructured %v, got %v", value.ToString(expected), value.ToString(u))
+			}
+		})
+	}
+}
+
+func TestFromBackendReadOnly(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected setting a field of a read-only backend to panic")
+		}
+	}()
+	value.FromBackend(jsonBackend(`{}`)).AsMap().Set("a", value.NewValueInterface(1))
+}
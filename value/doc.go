@@ -18,4 +18,13 @@ limitations under the License.
 // objects, organized for convenient comparison with a schema (as defined by
 // the sibling schema package). Functions for reading and writing the objects
 // are also provided.
+//
+// Value, Map and List are interfaces, implemented for unstructured objects
+// (NewValueInterface) and Go structs (NewValueReflect). Other representations
+// implement the smaller Backend interface and are adapted with FromBackend,
+// which keeps them working as Value grows.
+//
+// Building with the smd_noreflect tag leaves out the reflection backed
+// implementation of Value (NewValueReflect), which keeps binaries small, e.g.
+// when compiling to WebAssembly. Unstructured values are unaffected.
 package value
@@ -0,0 +1,270 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package value
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// PathStep addresses a child of a map, by key, or of a list, by index. It is
+// created with KeyStep or IndexStep.
+type PathStep struct {
+	key     string
+	index   int
+	isIndex bool
+}
+
+// KeyStep returns a PathStep addressing the field key of a map.
+func KeyStep(key string) PathStep {
+	return PathStep{key: key}
+}
+
+// IndexStep returns a PathStep addressing the item at index i of a list.
+// Set and Delete fail on negative indexes.
+func IndexStep(i int) PathStep {
+	return PathStep{index: i, isIndex: true}
+}
+
+// IsIndex returns true if s addresses a list item.
+func (s PathStep) IsIndex() bool {
+	return s.isIndex
+}
+
+// Key returns the map key addressed by s, if it isn't an index.
+func (s PathStep) Key() string {
+	return s.key
+}
+
+// Index returns the list index addressed by s, or -1 if it isn't an index,
+// e.g. if it is a key or the zero PathStep.
+func (s PathStep) Index() int {
+	if !s.isIndex {
+		return -1
+	}
+	return s.index
+}
+
+func (s PathStep) String() string {
+	if s.IsIndex() {
+		return fmt.Sprintf("[%d]", s.index)
+	}
+	return "." + s.key
+}
+
+// Path is a sequence of steps from a value to one of its descendants.
+type Path []PathStep
+
+func (p Path) String() string {
+	var b strings.Builder
+	for _, s := range p {
+		b.WriteString(s.String())
+	}
+	return b.String()
+}
+
+// Set returns a copy of v where the value at path is replaced with leaf. v
+// isn't modified: only the maps and lists along path are copied, the rest of
+// the new value is shared with v, so v must not be modified afterwards
+// either. Missing or null maps along path are created, and an index equal to
+// the length of a list appends to it. A nil leaf sets null.
+//
+// The siblings along path are shared through their Unstructured form, so
+// the sharing only holds for unstructured values: the siblings of reflected
+// values, and leaf itself, are copied deeply.
+func Set(v Value, path Path, leaf Value) (Value, error) {
+	out, err := set(v, path, 0, leaf)
+	if err != nil {
+		return nil, err
+	}
+	return NewValueInterface(out), nil
+}
+
+func set(v Value, path Path, depth int, leaf Value) (interface{}, error) {
+	if depth == len(path) {
+		if leaf == nil {
+			return nil, nil
+		}
+		return leaf.Unstructured(), nil
+	}
+	step := path[depth]
+	if step.IsIndex() {
+		if v == nil || !v.IsList() {
+			return nil, pathErrorf(path[:depth], "expected list, got %v", describeKind(v))
+		}
+		l := v.AsList()
+		n := l.Length()
+		if step.index < 0 || step.index > n {
+			return nil, pathErrorf(path[:depth+1], "index out of range, list has %d items", n)
+		}
+		var child Value
+		if step.index < n {
+			child = l.At(step.index)
+		}
+		updated, err := set(child, path, depth+1, leaf)
+		if err != nil {
+			return nil, err
+		}
+		items := make([]interface{}, n, n+1)
+		for i := 0; i < n; i++ {
+			if i != step.index {
+				items[i] = l.At(i).Unstructured()
+			}
+		}
+		if step.index == n {
+			items = append(items, updated)
+		} else {
+			items[step.index] = updated
+		}
+		return items, nil
+	}
+
+	if v != nil && !v.IsNull() && !v.IsMap() {
+		return nil, pathErrorf(path[:depth], "expected map, got %v", describeKind(v))
+	}
+	var m Map
+	if v != nil && v.IsMap() {
+		m = v.AsMap()
+	}
+	var child Value
+	if m != nil {
+		child, _ = m.Get(step.key)
+	}
+	updated, err := set(child, path, depth+1, leaf)
+	if err != nil {
+		return nil, err
+	}
+	fields := map[string]interface{}{}
+	if m != nil {
+		fields = make(map[string]interface{}, m.Length()+1)
+		m.Iterate(func(key string, val Value) bool {
+			fields[key] = val.Unstructured()
+			return true
+		})
+	}
+	fields[step.key] = updated
+	return fields, nil
+}
+
+// Delete returns a copy of v without the value at path, which is removed
+// from its map or list. Like Set, it doesn't modify v and shares what it
+// doesn't copy. Deleting a value that doesn't exist returns v unchanged.
+func Delete(v Value, path Path) (Value, error) {
+	if len(path) == 0 {
+		return nil, errors.New("can't delete the root value")
+	}
+	out, found, err := remove(v, path, 0)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return v, nil
+	}
+	return NewValueInterface(out), nil
+}
+
+// remove returns the unstructured value of v without path, and whether path
+// was found.
+func remove(v Value, path Path, depth int) (interface{}, bool, error) {
+	if v == nil || v.IsNull() {
+		return nil, false, nil
+	}
+	step := path[depth]
+	last := depth == len(path)-1
+	if step.IsIndex() {
+		if !v.IsList() {
+			return nil, false, pathErrorf(path[:depth], "expected list, got %v", describeKind(v))
+		}
+		l := v.AsList()
+		n := l.Length()
+		if step.index < 0 {
+			return nil, false, pathErrorf(path[:depth+1], "index out of range, list has %d items", n)
+		}
+		if step.index >= n {
+			return nil, false, nil
+		}
+		var updated interface{}
+		if !last {
+			var found bool
+			var err error
+			updated, found, err = remove(l.At(step.index), path, depth+1)
+			if err != nil || !found {
+				return nil, false, err
+			}
+		}
+		items := make([]interface{}, 0, n)
+		for i := 0; i < n; i++ {
+			switch {
+			case i != step.index:
+				items = append(items, l.At(i).Unstructured())
+			case !last:
+				items = append(items, updated)
+			}
+		}
+		return items, true, nil
+	}
+
+	if !v.IsMap() {
+		return nil, false, pathErrorf(path[:depth], "expected map, got %v", describeKind(v))
+	}
+	m := v.AsMap()
+	child, ok := m.Get(step.key)
+	if !ok {
+		return nil, false, nil
+	}
+	var updated interface{}
+	if !last {
+		var found bool
+		var err error
+		updated, found, err = remove(child, path, depth+1)
+		if err != nil || !found {
+			return nil, false, err
+		}
+	}
+	fields := make(map[string]interface{}, m.Length())
+	m.Iterate(func(key string, val Value) bool {
+		switch {
+		case key != step.key:
+			fields[key] = val.Unstructured()
+		case !last:
+			fields[key] = updated
+		}
+		return true
+	})
+	return fields, true, nil
+}
+
+func describeKind(v Value) string {
+	switch {
+	case v == nil || v.IsNull():
+		return "null"
+	case v.IsMap():
+		return "map"
+	case v.IsList():
+		return "list"
+	}
+	return ToString(v)
+}
+
+func pathErrorf(p Path, format string, args ...interface{}) error {
+	msg := fmt.Sprintf(format, args...)
+	if len(p) == 0 {
+		return errors.New(msg)
+	}
+	return fmt.Errorf("%v: %v", p, msg)
+}
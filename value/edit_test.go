@@ -0,0 +1,222 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package value_test
+
+import (
+	"testing"
+
+	"sigs.k8s.io/structured-merge-diff/v4/value"
+)
+
+func mustFromJSON(t *testing.T, s string) value.Value {
+	t.Helper()
+	v, err := value.FromJSON([]byte(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestSet(t *testing.T) {
+	tests := []struct {
+		name     string
+		object   string
+		path     value.Path
+		leaf     string
+		expected string
+		err      string
+	}{{
+		name:     "root",
+		object:   `{"a":1}`,
+		leaf:     `2`,
+		expected: `2`,
+	}, {
+		name:     "replace field",
+		object:   `{"a":{"b":1,"c":2},"d":3}`,
+		path:     value.Path{value.KeyStep("a"), value.KeyStep("b")},
+		leaf:     `{"x":true}`,
+		expected: `{"a":{"b":{"x":true},"c":2},"d":3}`,
+	}, {
+		name:     "create maps",
+		object:   `{"a":null}`,
+		path:     value.Path{value.KeyStep("a"), value.KeyStep("b"), value.KeyStep("c")},
+		leaf:     `"v"`,
+		expected: `{"a":{"b":{"c":"v"}}}`,
+	}, {
+		name:     "replace item",
+		object:   `{"l":[1,{"k":2},3]}`,
+		path:     value.Path{value.KeyStep("l"), value.IndexStep(1), value.KeyStep("k")},
+		leaf:     `4`,
+		expected: `{"l":[1,{"k":4},3]}`,
+	}, {
+		name:     "append item",
+		object:   `{"l":[1]}`,
+		path:     value.Path{value.KeyStep("l"), value.IndexStep(1)},
+		leaf:     `2`,
+		expected: `{"l":[1,2]}`,
+	}, {
+		name:   "index out of range",
+		object: `{"l":[1]}`,
+		path:   value.Path{value.KeyStep("l"), value.IndexStep(2)},
+		leaf:   `2`,
+		err:    `.l[2]: index out of range, list has 1 items`,
+	}, {
+		name:   "negative index",
+		object: `{"l":[1]}`,
+		path:   value.Path{value.KeyStep("l"), value.IndexStep(-1)},
+		leaf:   `2`,
+		err:    `.l[-1]: index out of range, list has 1 items`,
+	}, {
+		name:   "index of map",
+		object: `{"l":{}}`,
+		path:   value.Path{value.KeyStep("l"), value.IndexStep(0)},
+		leaf:   `2`,
+		err:    `.l: expected list, got map`,
+	}, {
+		name:   "key of scalar",
+		object: `"s"`,
+		path:   value.Path{value.KeyStep("a")},
+		leaf:   `2`,
+		err:    `expected map, got "s"`,
+	}}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			object := mustFromJSON(t, tt.object)
+			got, err := value.Set(object, tt.path, mustFromJSON(t, tt.leaf))
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("expected error %q, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if expected := mustFromJSON(t, tt.expected); !value.Equals(got, expected) {
+				t.Errorf("expected %v, got %v", value.ToString(expected), value.ToString(got))
+			}
+			if !value.Equals(object, mustFromJSON(t, tt.object)) {
+				t.Errorf("input was modified: %v", value.ToString(object))
+			}
+		})
+	}
+}
+
+func TestDelete(t *testing.T) {
+	tests := []struct {
+		name     string
+		object   string
+		path     value.Path
+		expected string
+		err      string
+	}{{
+		name:     "field",
+		object:   `{"a":{"b":1,"c":2},"d":3}`,
+		path:     value.Path{value.KeyStep("a"), value.KeyStep("b")},
+		expected: `{"a":{"c":2},"d":3}`,
+	}, {
+		name:     "item",
+		object:   `{"l":[1,{"k":2},3]}`,
+		path:     value.Path{value.KeyStep("l"), value.IndexStep(1)},
+		expected: `{"l":[1,3]}`,
+	}, {
+		name:     "field of item",
+		object:   `{"l":[1,{"k":2,"j":3}]}`,
+		path:     value.Path{value.KeyStep("l"), value.IndexStep(1), value.KeyStep("k")},
+		expected: `{"l":[1,{"j":3}]}`,
+	}, {
+		name:     "missing",
+		object:   `{"a":{"b":1}}`,
+		path:     value.Path{value.KeyStep("a"), value.KeyStep("x"), value.KeyStep("y")},
+		expected: `{"a":{"b":1}}`,
+	}, {
+		name:     "missing item",
+		object:   `{"l":[1]}`,
+		path:     value.Path{value.KeyStep("l"), value.IndexStep(3)},
+		expected: `{"l":[1]}`,
+	}, {
+		name:   "negative index",
+		object: `{"l":[1]}`,
+		path:   value.Path{value.KeyStep("l"), value.IndexStep(-1)},
+		err:    `.l[-1]: index out of range, list has 1 items`,
+	}, {
+		name:   "root",
+		object: `{"a":1}`,
+		err:    `can't delete the root value`,
+	}, {
+		name:   "key of list",
+		object: `{"l":[1]}`,
+		path:   value.Path{value.KeyStep("l"), value.KeyStep("k")},
+		err:    `.l: expected map, got list`,
+	}}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			object := mustFromJSON(t, tt.object)
+			got, err := value.Delete(object, tt.path)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("expected error %q, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if expected := mustFromJSON(t, tt.expected); !value.Equals(got, expected) {
+				t.Errorf("expected %v, got %v", value.ToString(expected), value.ToString(got))
+			}
+			if !value.Equals(object, mustFromJSON(t, tt.object)) {
+				t.Errorf("input was modified: %v", value.ToString(object))
+			}
+		})
+	}
+}
+
+func TestSetShares(t *testing.T) {
+	object := mustFromJSON(t, `{"a":{"b":1},"l":[{"c":2}]}`)
+	got, err := value.Set(object, value.Path{value.KeyStep("x")}, value.NewValueInterface(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"a", "l"} {
+		before, _ := object.AsMap().Get(key)
+		after, _ := got.AsMap().Get(key)
+		if !value.Same(before, after) {
+			t.Errorf("expected %v to be shared", key)
+		}
+	}
+}
+
+func TestPathStepIndex(t *testing.T) {
+	for _, tt := range []struct {
+		step     value.PathStep
+		expected int
+	}{
+		{value.IndexStep(0), 0},
+		{value.IndexStep(2), 2},
+		{value.KeyStep("a"), -1},
+		{value.PathStep{}, -1},
+	} {
+		if got := tt.step.Index(); got != tt.expected {
+			t.Errorf("expected %v to have index %v, got %v", tt.step, tt.expected, got)
+		}
+	}
+}
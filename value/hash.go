@@ -0,0 +1,89 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package value
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"hash"
+	"math"
+
+	"sigs.k8s.io/structured-merge-diff/v4/internal/bytesconv"
+)
+
+// Hash is a SHA-256 hash of the content of a value, see HashOf.
+type Hash [sha256.Size]byte
+
+// HashOf returns the hash of the content of v. Values with the same hash
+// have the same content, i.e. the same kinds, items and fields, in the same
+// order for lists. Integers and floats have different hashes even if they
+// are Equal, as do nulls and nil.
+func HashOf(v Value) Hash {
+	h := sha256.New()
+	var buf [binary.MaxVarintLen64]byte
+	hashValue(h, buf[:], v)
+	var out Hash
+	h.Sum(out[:0])
+	return out
+}
+
+// hashValue writes an unambiguous representation of v to h, using buf to
+// write numbers.
+func hashValue(h hash.Hash, buf []byte, v Value) {
+	switch {
+	case v == nil:
+		h.Write([]byte{'0'})
+	case v.IsNull():
+		h.Write([]byte{'n'})
+	case v.IsBool() && v.AsBool():
+		h.Write([]byte{'t'})
+	case v.IsBool():
+		h.Write([]byte{'f'})
+	case v.IsInt():
+		h.Write([]byte{'i'})
+		h.Write(buf[:binary.PutVarint(buf, v.AsInt())])
+	case v.IsFloat():
+		h.Write([]byte{'d'})
+		h.Write(buf[:binary.PutUvarint(buf, math.Float64bits(v.AsFloat()))])
+	case v.IsString():
+		h.Write([]byte{'s'})
+		hashString(h, buf, v.AsString())
+	case v.IsList():
+		l := v.AsList()
+		h.Write([]byte{'l'})
+		h.Write(buf[:binary.PutUvarint(buf, uint64(l.Length()))])
+		for i := 0; i < l.Length(); i++ {
+			hashValue(h, buf, l.At(i))
+		}
+	case v.IsMap():
+		m := v.AsMap()
+		h.Write([]byte{'m'})
+		h.Write(buf[:binary.PutUvarint(buf, uint64(m.Length()))])
+		MapZip(m, nil, LexicalKeyOrder, func(key string, val, _ Value) bool {
+			hashString(h, buf, key)
+			hashValue(h, buf, val)
+			return true
+		})
+	}
+}
+
+// hashString writes s to h, prefixed by its length.
+func hashString(h hash.Hash, buf []byte, s string) {
+	h.Write(buf[:binary.PutUvarint(buf, uint64(len(s)))])
+	// Hashes don't retain what they are given.
+	h.Write(bytesconv.Bytes(s))
+}
@@ -0,0 +1,58 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package value_test
+
+import (
+	"testing"
+
+	"sigs.k8s.io/structured-merge-diff/v4/value"
+)
+
+func TestHashOf(t *testing.T) {
+	tests := []struct {
+		name     string
+		lhs, rhs string
+		same     bool
+	}{
+		{"equal maps", `{"a": 1, "b": [1, "x"]}`, `{"b": [1, "x"], "a": 1}`, true},
+		{"different values", `{"a": 1}`, `{"a": 2}`, false},
+		{"different keys", `{"a": 1}`, `{"b": 1}`, false},
+		{"list order", `[1, 2]`, `[2, 1]`, false},
+		{"nested lists", `[[1], 2]`, `[[1, 2]]`, false},
+		{"concatenated strings", `["ab", "c"]`, `["a", "bc"]`, false},
+		{"int and string", `1`, `"1"`, false},
+		{"null and empty map", `null`, `{}`, false},
+		{"empty map and list", `{}`, `[]`, false},
+		{"booleans", `true`, `false`, false},
+	}
+	for _, tt := range tests {
+		lhs, err := value.FromJSON([]byte(tt.lhs))
+		if err != nil {
+			t.Fatal(err)
+		}
+		rhs, err := value.FromJSON([]byte(tt.rhs))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if same := value.HashOf(lhs) == value.HashOf(rhs); same != tt.same {
+			t.Errorf("%v: expected same hashes to be %v, got %v", tt.name, tt.same, same)
+		}
+	}
+	if value.HashOf(value.NewValueInterface(int64(1))) == value.HashOf(value.NewValueInterface(float64(1))) {
+		t.Error("expected an int and a float to have different hashes")
+	}
+}
@@ -1,3 +1,6 @@
+//go:build !smd_noreflect
+// +build !smd_noreflect
+
 /*
 Copyright 2019 The Kubernetes Authors.
 
@@ -0,0 +1,141 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package value
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"reflect"
+)
+
+// Fingerprinter is implemented by values which know a fingerprint of their
+// content, e.g. a cryptographic hash of the bytes they were decoded from.
+// Values with the same fingerprint must be equal, so fingerprints must be
+// collision resistant.
+type Fingerprinter interface {
+	// Fingerprint returns the fingerprint of the value, or "" if it isn't
+	// known.
+	Fingerprint() string
+}
+
+// fingerprintedValue is a value with a fingerprint, see WithFingerprint.
+type fingerprintedValue struct {
+	Value
+	fingerprint string
+}
+
+func (v fingerprintedValue) Fingerprint() string {
+	return v.fingerprint
+}
+
+// WithFingerprint returns v with fingerprint as its fingerprint, which must
+// only be shared by values equal to v (see Fingerprinter).
+func WithFingerprint(v Value, fingerprint string) Value {
+	return fingerprintedValue{Value: v, fingerprint: fingerprint}
+}
+
+// FromJSONFingerprinted is like FromJSON, but the value has the SHA-256 hash
+// of input as fingerprint, so that the values decoded from the same bytes,
+// e.g. the same object read twice, are Same without comparing their content.
+func FromJSONFingerprinted(input []byte) (Value, error) {
+	v, err := FromJSON(input)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(input)
+	return WithFingerprint(v, "sha256:"+hex.EncodeToString(sum[:])), nil
+}
+
+// Same returns true if lhs and rhs are known to be equal without looking at
+// their content: because they have the same fingerprint, or because they
+// are maps or lists sharing the same data: unstructured maps and lists, or
+// the maps, slices and addressable structs of reflected values. A false
+// result doesn't mean that they differ, Equals tells that.
+//
+// It is cheap, and is used to skip walking identical sub-trees of objects,
+// which are common when an object is derived from another one without
+// copying it deeply.
+func Same(lhs, rhs Value) bool {
+	l, ok := IdentityOf(lhs)
+	if !ok {
+		return false
+	}
+	r, ok := IdentityOf(rhs)
+	return ok && l == r
+}
+
+// Identity identifies the data of a value without looking at its content,
+// see IdentityOf. Identities are comparable, and can be used as map keys.
+type Identity struct {
+	fingerprint string
+	typ         reflect.Type
+	pointer     uintptr
+	length      int
+}
+
+// IdentityOf returns the identity of v, such that the values with the same
+// identity are Same, or false if it has none.
+//
+// The identity of a value with a fingerprint is its fingerprint. Otherwise
+// the identity of a map or list is its address, which can be reused by
+// another map or list once it is garbage collected: the holders of
+// identities should keep their referents too, see Referent.
+func IdentityOf(v Value) (Identity, bool) {
+	if v == nil {
+		return Identity{}, false
+	}
+	if f, ok := v.(Fingerprinter); ok {
+		if fp := f.Fingerprint(); fp != "" {
+			return Identity{fingerprint: fp}, true
+		}
+		v = unwrapFingerprinted(v)
+	}
+	u, ok := v.(*valueUnstructured)
+	if !ok {
+		return reflectIdentityOf(v)
+	}
+	switch u.Value.(type) {
+	case map[string]interface{}, map[interface{}]interface{}, []interface{}:
+	default:
+		return Identity{}, false
+	}
+	rv := reflect.ValueOf(u.Value)
+	return Identity{typ: rv.Type(), pointer: rv.Pointer(), length: rv.Len()}, true
+}
+
+// Referent returns the data identified by the identity of v, or nil if it
+// has none or if it is a fingerprint, which no other value can reuse.
+// Holding the referent keeps the identity from being reused, whereas v may
+// be a wrapper that an Allocator frees and reuses.
+func Referent(v Value) interface{} {
+	if id, ok := IdentityOf(v); !ok || id.fingerprint != "" {
+		return nil
+	}
+	v = unwrapFingerprinted(v)
+	if u, ok := v.(*valueUnstructured); ok {
+		return u.Value
+	}
+	return reflectReferent(v)
+}
+
+// unwrapFingerprinted returns the value wrapped by WithFingerprint, or v.
+func unwrapFingerprinted(v Value) Value {
+	if f, ok := v.(fingerprintedValue); ok {
+		return f.Value
+	}
+	return v
+}
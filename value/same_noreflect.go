@@ -0,0 +1,30 @@
+//go:build smd_noreflect
+// +build smd_noreflect
+
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package value
+
+// reflectIdentityOf has nothing to identify without reflected values.
+func reflectIdentityOf(v Value) (Identity, bool) {
+	return Identity{}, false
+}
+
+// reflectReferent is never called without reflected values.
+func reflectReferent(v Value) interface{} {
+	return nil
+}
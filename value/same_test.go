@@ -0,0 +1,83 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package value_test
+
+import (
+	"testing"
+
+	"sigs.k8s.io/structured-merge-diff/v4/value"
+)
+
+func TestSame(t *testing.T) {
+	m := map[string]interface{}{"a": 1}
+	l := []interface{}{1, 2}
+	tests := []struct {
+		name     string
+		lhs, rhs value.Value
+		expected bool
+	}{
+		{"same map", value.NewValueInterface(m), value.NewValueInterface(m), true},
+		{"equal maps", value.NewValueInterface(m), value.NewValueInterface(map[string]interface{}{"a": 1}), false},
+		{"same list", value.NewValueInterface(l), value.NewValueInterface(l), true},
+		{"shorter list", value.NewValueInterface(l), value.NewValueInterface(l[:1]), false},
+		{"scalars", value.NewValueInterface(1), value.NewValueInterface(1), false},
+		{"map and null", value.NewValueInterface(m), value.NewValueInterface(nil), false},
+		{"nil", nil, value.NewValueInterface(m), false},
+		{"same fingerprints", value.WithFingerprint(value.NewValueInterface(1), "x"), value.WithFingerprint(value.NewValueInterface(2), "x"), true},
+		{"different fingerprints", value.WithFingerprint(value.NewValueInterface(m), "x"), value.WithFingerprint(value.NewValueInterface(m), "y"), false},
+		{"unknown fingerprints", value.WithFingerprint(value.NewValueInterface(m), ""), value.WithFingerprint(value.NewValueInterface(m), ""), true},
+	}
+	for _, tt := range tests {
+		if got := value.Same(tt.lhs, tt.rhs); got != tt.expected {
+			t.Errorf("%v: expected %v, got %v", tt.name, tt.expected, got)
+		}
+		li, lok := value.IdentityOf(tt.lhs)
+		ri, rok := value.IdentityOf(tt.rhs)
+		if got := lok && rok && li == ri; got != tt.expected {
+			t.Errorf("%v: expected identities to be equal: %v, got %v", tt.name, tt.expected, got)
+		}
+	}
+}
+
+func TestReferent(t *testing.T) {
+	m := map[string]interface{}{"a": 1}
+	if r, ok := value.Referent(value.NewValueInterface(m)).(map[string]interface{}); !ok || !value.Same(value.NewValueInterface(r), value.NewValueInterface(m)) {
+		t.Errorf("expected the referent of a map to be the map, got %v", r)
+	}
+	if r := value.Referent(value.NewValueInterface(1)); r != nil {
+		t.Errorf("expected no referent for a scalar, got %v", r)
+	}
+}
+
+func TestFromJSONFingerprinted(t *testing.T) {
+	decode := func(data string) value.Value {
+		v, err := value.FromJSONFingerprinted([]byte(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	if !value.Same(decode(`{"a": [1, 2]}`), decode(`{"a": [1, 2]}`)) {
+		t.Error("expected values decoded from the same bytes to be Same")
+	}
+	if value.Same(decode(`{"a": [1, 2]}`), decode(`{"a": [1, 3]}`)) {
+		t.Error("expected values decoded from different bytes not to be Same")
+	}
+	if v := decode(`{"a": [1, 2]}`); !v.IsMap() || v.AsMap().Length() != 1 {
+		t.Errorf("expected the decoded map, got %v", value.ToString(v))
+	}
+}
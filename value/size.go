@@ -0,0 +1,58 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package value
+
+// Rough sizes, in bytes, of the parts of unstructured values on 64-bit
+// platforms.
+const (
+	interfaceSize    = 16
+	stringHeaderSize = 16
+	sliceHeaderSize  = 24
+	numberSize       = 8
+	mapHeaderSize    = 48
+	mapEntryOverhead = 8
+)
+
+// EstimateSize returns a rough estimate of the memory, in bytes, used by the
+// unstructured form of v (see Value.Unstructured), not counting the
+// interface which holds v itself. It is meant for quotas and capacity
+// planning: the actual usage depends on the Go runtime, and on the
+// representation of v, e.g. values backed by structs don't allocate maps.
+func EstimateSize(v Value) int {
+	switch {
+	case v.IsMap():
+		n := mapHeaderSize
+		v.AsMap().Iterate(func(key string, child Value) bool {
+			n += stringHeaderSize + len(key) + interfaceSize + mapEntryOverhead + EstimateSize(child)
+			return true
+		})
+		return n
+	case v.IsList():
+		l := v.AsList()
+		n := sliceHeaderSize + l.Length()*interfaceSize
+		for i := 0; i < l.Length(); i++ {
+			n += EstimateSize(l.At(i))
+		}
+		return n
+	case v.IsString():
+		return stringHeaderSize + len(v.AsString())
+	case v.IsInt(), v.IsFloat():
+		return numberSize
+	}
+	// Booleans and nulls don't allocate.
+	return 0
+}
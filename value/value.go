@@ -180,6 +180,9 @@ func EqualsUsing(a Allocator, lhs, rhs Value) bool {
 	}
 	if lhs.IsList() {
 		if rhs.IsList() {
+			if Same(lhs, rhs) {
+				return true
+			}
 			lhsList := lhs.AsListUsing(a)
 			defer a.Free(lhsList)
 			rhsList := rhs.AsListUsing(a)
@@ -192,6 +195,9 @@ func EqualsUsing(a Allocator, lhs, rhs Value) bool {
 	}
 	if lhs.IsMap() {
 		if rhs.IsMap() {
+			if Same(lhs, rhs) {
+				return true
+			}
 			lhsList := lhs.AsMapUsing(a)
 			defer a.Free(lhsList)
 			rhsList := rhs.AsMapUsing(a)
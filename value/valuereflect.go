@@ -1,3 +1,6 @@
+//go:build !smd_noreflect
+// +build !smd_noreflect
+
 /*
 Copyright 2019 The Kubernetes Authors.
 
@@ -292,3 +295,27 @@ func (r valueReflect) Unstructured() interface{} {
 		panic(fmt.Sprintf("value of type %s is not a supported by value reflector", val.Type()))
 	}
 }
+
+// reflectIdentityOf returns the identity of the maps, slices and
+// addressable structs of reflected values.
+func reflectIdentityOf(v Value) (Identity, bool) {
+	r, ok := v.(*valueReflect)
+	if !ok {
+		return Identity{}, false
+	}
+	switch r.kind {
+	case mapType, listType:
+		return Identity{typ: r.Value.Type(), pointer: r.Value.Pointer(), length: r.Value.Len()}, true
+	case structMapType:
+		if !r.Value.CanAddr() {
+			return Identity{}, false
+		}
+		return Identity{typ: r.Value.Type(), pointer: r.Value.UnsafeAddr()}, true
+	}
+	return Identity{}, false
+}
+
+// reflectReferent returns the reflected data of v, for Referent.
+func reflectReferent(v Value) interface{} {
+	return v.(*valueReflect).Value
+}
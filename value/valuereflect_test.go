@@ -1,3 +1,6 @@
+//go:build !smd_noreflect
+// +build !smd_noreflect
+
 /*
 Copyright 2019 The Kubernetes Authors.
 
@@ -760,3 +763,37 @@ func TestMapZip(t *testing.T) {
 		})
 	}
 }
+
+func TestReflectSame(t *testing.T) {
+	type inner struct {
+		A string `json:"a"`
+	}
+	type outer struct {
+		Inner inner             `json:"inner"`
+		Map   map[string]string `json:"map"`
+		List  []string          `json:"list"`
+	}
+	o := &outer{Inner: inner{A: "a"}, Map: map[string]string{"k": "v"}, List: []string{"x"}}
+	other := &outer{Inner: inner{A: "a"}, Map: map[string]string{"k": "v"}, List: []string{"x"}}
+	field := func(o *outer, name string) Value {
+		v, ok := MustReflect(o).AsMap().Get(name)
+		if !ok {
+			t.Fatalf("missing field %v", name)
+		}
+		return v
+	}
+	for _, name := range []string{"inner", "map", "list"} {
+		if !Same(field(o, name), field(o, name)) {
+			t.Errorf("expected %v of the same object to be Same", name)
+		}
+		if Same(field(o, name), field(other, name)) {
+			t.Errorf("expected %v of equal objects not to be Same", name)
+		}
+	}
+	if !Same(MustReflect(o), MustReflect(o)) {
+		t.Error("expected the same object to be Same")
+	}
+	if Same(field(o, "map"), NewValueInterface(map[string]interface{}{"k": "v"})) {
+		t.Error("expected a reflected and an unstructured map not to be Same")
+	}
+}